@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ipfscore "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/corehttp"
+	ipath "github.com/ipfs/go-ipfs/path"
+)
+
+// BlocklistFile is the file in the repo listing the CIDs and peer IDs the
+// gateway refuses to serve, one per line. It is reread whenever it changes.
+const BlocklistFile = "blocklist"
+
+// Blocklist is a set of CIDs and peer IDs loaded from a file
+type Blocklist struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	size    int64
+	entries map[string]bool
+}
+
+// NewBlocklist returns a blocklist backed by the file at path, which does not
+// need to exist yet
+func NewBlocklist(path string) *Blocklist {
+	return &Blocklist{path: path}
+}
+
+// Blocked reports whether any of ids is on the list
+func (b *Blocklist) Blocked(ids ...string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.reload()
+	for _, id := range ids {
+		if b.entries[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether nothing is blocked
+func (b *Blocklist) Empty() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.reload()
+	return len(b.entries) == 0
+}
+
+func (b *Blocklist) reload() {
+	fi, err := os.Stat(b.path)
+	if err != nil {
+		b.entries = nil
+		b.modTime = time.Time{}
+		return
+	}
+	if fi.ModTime().Equal(b.modTime) && fi.Size() == b.size {
+		return
+	}
+	f, err := os.Open(b.path)
+	if err != nil {
+		log.Errorf("Reading blocklist %s: %s", b.path, err)
+		return
+	}
+	defer f.Close()
+	b.entries = parseBlocklist(f)
+	b.modTime = fi.ModTime()
+	b.size = fi.Size()
+}
+
+// parseBlocklist reads one entry per line, skipping blank lines and
+// comments starting with #
+func parseBlocklist(r io.Reader) map[string]bool {
+	entries := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries[line] = true
+	}
+	return entries
+}
+
+// BlocklistOption refuses /ipfs/ and /ipns/ requests for blocked content with
+// 410 Gone. A request is blocked when the CID or peer ID at the root of its
+// path, or the CID the full path resolves to, is on the list.
+func BlocklistOption(path string) corehttp.ServeOption {
+	blocklist := NewBlocklist(path)
+	return func(n *ipfscore.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		childMux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if blocked(n, blocklist, r) {
+				http.Error(w, "Blocked by content policy", http.StatusGone)
+				return
+			}
+			childMux.ServeHTTP(w, r)
+		})
+		return childMux, nil
+	}
+}
+
+func blocked(n *ipfscore.IpfsNode, blocklist *Blocklist, r *http.Request) bool {
+	p := r.URL.Path
+	if !strings.HasPrefix(p, "/ipfs/") && !strings.HasPrefix(p, "/ipns/") {
+		return false
+	}
+	if blocklist.Empty() {
+		return false
+	}
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) < 2 {
+		return false
+	}
+	if blocklist.Blocked(segments[1]) {
+		return true
+	}
+	parsed, err := ipath.ParsePath(p)
+	if err != nil {
+		return false
+	}
+	// Let the gateway report paths that do not resolve
+	c, err := ipfscore.ResolveToCid(r.Context(), n.Namesys, n.Resolver, parsed)
+	if err != nil {
+		return false
+	}
+	return blocklist.Blocked(c.String())
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	entries := parseBlocklist(strings.NewReader("# blocked content\nzb2rhjqhgN4Pv1SJFNpCQMjv2h8PQEGqAioMhkZjkKDyPW5E2\n\n  QmfQkD8pBSBCBxWEwFSu4XaDVSWK6bjnNuaWZjMyQbyDub \n"))
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(entries))
+	}
+	if !entries["QmfQkD8pBSBCBxWEwFSu4XaDVSWK6bjnNuaWZjMyQbyDub"] {
+		t.Error("Expected the peer ID to be trimmed and blocked")
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := path.Join(dir, BlocklistFile)
+	b := NewBlocklist(p)
+	if !b.Empty() {
+		t.Error("Expected a missing file to block nothing")
+	}
+	if err := ioutil.WriteFile(p, []byte("QmA\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Blocked("QmB", "QmA") {
+		t.Error("Expected QmA to be blocked")
+	}
+	if err := ioutil.WriteFile(p, []byte("QmB\nQmC\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(p, time.Now(), time.Now().Add(time.Second))
+	if b.Blocked("QmA") || !b.Blocked("QmC") {
+		t.Error("Expected the changed file to be reloaded")
+	}
+	os.Remove(p)
+	if !b.Empty() {
+		t.Error("Expected a removed file to block nothing")
+	}
+}
@@ -98,6 +98,10 @@ func post(i *jsonAPIHandler, path string, w http.ResponseWriter, r *http.Request
 		i.POSTImportListings(w, r)
 	case strings.HasPrefix(path, "/ob/purgecache"):
 		i.POSTPurgeCache(w, r)
+	case strings.HasPrefix(path, "/ob/peers/connect"):
+		i.POSTPeersConnect(w, r)
+	case strings.HasPrefix(path, "/ob/peers/disconnect"):
+		i.POSTPeersDisconnect(w, r)
 	default:
 		ErrorResponse(w, http.StatusNotFound, "Not Found")
 	}
@@ -109,6 +113,8 @@ func get(i *jsonAPIHandler, path string, w http.ResponseWriter, r *http.Request)
 		i.GETStatus(w, r)
 	case strings.HasPrefix(path, "/ob/peers"):
 		i.GETPeers(w, r)
+	case strings.HasPrefix(path, "/ob/bandwidth"):
+		i.GETBandwidth(w, r)
 	case strings.HasPrefix(path, "/ob/config"):
 		i.GETConfig(w, r)
 	case strings.HasPrefix(path, "/wallet/address"):
@@ -228,3 +234,28 @@ func gatewayAllowedPath(path, method string) bool {
 	}
 	return false
 }
+
+// walletOnlyPath reports whether the route works without the marketplace
+// protocol running: the wallet, node configuration and what is read from
+// the datastore
+func walletOnlyPath(path, method string) bool {
+	if strings.HasPrefix(path, "/wallet/") {
+		return true
+	}
+	allowedGets := []string{"/ob/config", "/ob/peers", "/ob/bandwidth", "/ob/settings", "/ob/closestpeers", "/ob/exchangerate", "/ob/notifications", "/ob/chatmessages", "/ob/chatconversations", "/ob/healthcheck"}
+	allowedPosts := []string{"/ob/shutdown", "/ob/marknotificationasread", "/ob/marknotificationsasread"}
+	if method == "GET" {
+		for _, p := range allowedGets {
+			if strings.HasPrefix(path, p) {
+				return true
+			}
+		}
+	} else if method == "POST" {
+		for _, p := range allowedPosts {
+			if strings.HasPrefix(path, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package api
+
+import "testing"
+
+func TestWalletOnlyPath(t *testing.T) {
+	for _, c := range []struct {
+		method, path string
+		allowed      bool
+	}{
+		{"GET", "/wallet/balance", true},
+		{"POST", "/wallet/spend", true},
+		{"GET", "/ob/config", true},
+		{"POST", "/ob/shutdown", true},
+		{"GET", "/ob/status", false},
+		{"POST", "/ob/purchase", false},
+		{"POST", "/ob/chat", false},
+		{"PUT", "/ob/settings", false},
+	} {
+		if got := walletOnlyPath(c.path, c.method); got != c.allowed {
+			t.Errorf("Expected %s %s allowed without the marketplace to be %v", c.method, c.path, c.allowed)
+		}
+	}
+}
@@ -58,27 +58,75 @@ type JsonAPIConfig struct {
 type jsonAPIHandler struct {
 	config JsonAPIConfig
 	node   *core.OpenBazaarNode
+
+	// fulfillLock makes checking a sale can be fulfilled and fulfilling it
+	// one step, so the same fulfillment sent twice is only accepted once
+	fulfillLock sync.Mutex
 }
 
 func newJsonAPIHandler(node *core.OpenBazaarNode, authCookie http.Cookie, config repo.APIConfig) (*jsonAPIHandler, error) {
+	i := &jsonAPIHandler{
+		config: newJsonAPIConfig(authCookie, config),
+		node:   node,
+	}
+	return i, nil
+}
+
+func newJsonAPIConfig(authCookie http.Cookie, config repo.APIConfig) JsonAPIConfig {
 	allowedIPs := make(map[string]bool)
 	for _, ip := range config.AllowedIPs {
 		allowedIPs[ip] = true
 	}
-	i := &jsonAPIHandler{
-		config: JsonAPIConfig{
-			Enabled:       config.Enabled,
-			Cors:          config.CORS,
-			Headers:       config.HTTPHeaders,
-			Authenticated: config.Authenticated,
-			AllowedIPs:    allowedIPs,
-			Cookie:        authCookie,
-			Username:      config.Username,
-			Password:      config.Password,
-		},
-		node: node,
+	return JsonAPIConfig{
+		Enabled:       config.Enabled,
+		Cors:          config.CORS,
+		Headers:       config.HTTPHeaders,
+		Authenticated: config.Authenticated,
+		AllowedIPs:    allowedIPs,
+		Cookie:        authCookie,
+		Username:      config.Username,
+		Password:      config.Password,
 	}
-	return i, nil
+}
+
+// allowedIP reports whether the request comes from an address allowed to
+// use the API
+func (c JsonAPIConfig) allowedIP(r *http.Request) bool {
+	if len(c.AllowedIPs) == 0 {
+		return true
+	}
+	remoteAddr := strings.Split(r.RemoteAddr, ":")
+	return c.AllowedIPs[remoteAddr[0]]
+}
+
+// authorized reports whether the request carries the auth cookie or the
+// credentials the API takes, if it is authenticated
+func (c JsonAPIConfig) authorized(r *http.Request) bool {
+	if !c.Authenticated {
+		return true
+	}
+	if c.Username == "" || c.Password == "" {
+		cookie, err := r.Cookie("OpenBazaar_Auth_Cookie")
+		return err == nil && c.Cookie.Value == cookie.Value
+	}
+	username, password, ok := r.BasicAuth()
+	h := sha256.Sum256([]byte(password))
+	password = hex.EncodeToString(h[:])
+	return ok && username == c.Username && strings.ToLower(password) == strings.ToLower(c.Password)
+}
+
+// Authenticated serves h only to the requests the API would take: from the
+// allowed IPs and with the auth cookie or credentials it requires
+func Authenticated(h http.Handler, authCookie http.Cookie, config repo.APIConfig) http.Handler {
+	c := newJsonAPIConfig(authCookie, config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.allowedIP(r) || !c.authorized(r) {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "403 - Forbidden")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
 }
 
 func (i *jsonAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -92,13 +140,10 @@ func (i *jsonAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "403 - Forbidden")
 		return
 	}
-	if len(i.config.AllowedIPs) > 0 {
-		remoteAddr := strings.Split(r.RemoteAddr, ":")
-		if !i.config.AllowedIPs[remoteAddr[0]] {
-			w.WriteHeader(http.StatusForbidden)
-			fmt.Fprint(w, "403 - Forbidden")
-			return
-		}
+	if !i.config.allowedIP(r) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "403 - Forbidden")
+		return
 	}
 
 	if i.config.Cors != nil {
@@ -111,29 +156,10 @@ func (i *jsonAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.Header()[k] = v.([]string)
 	}
 
-	if i.config.Authenticated {
-		if i.config.Username == "" || i.config.Password == "" {
-			cookie, err := r.Cookie("OpenBazaar_Auth_Cookie")
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
-				fmt.Fprint(w, "403 - Forbidden")
-				return
-			}
-			if i.config.Cookie.Value != cookie.Value {
-				w.WriteHeader(http.StatusForbidden)
-				fmt.Fprint(w, "403 - Forbidden")
-				return
-			}
-		} else {
-			username, password, ok := r.BasicAuth()
-			h := sha256.Sum256([]byte(password))
-			password = hex.EncodeToString(h[:])
-			if !ok || username != i.config.Username || strings.ToLower(password) != strings.ToLower(i.config.Password) {
-				w.WriteHeader(http.StatusForbidden)
-				fmt.Fprint(w, "403 - Forbidden")
-				return
-			}
-		}
+	if !i.config.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "403 - Forbidden")
+		return
 	}
 
 	// Stop here if its Preflighted OPTIONS request
@@ -156,6 +182,11 @@ func (i *jsonAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	w.Header().Add("Content-Type", "application/json")
+	if i.node.Service == nil && !walletOnlyPath(u.Path, r.Method) {
+		// Started with --disablemarketplace, or not started yet
+		ErrorResponse(w, http.StatusServiceUnavailable, "Marketplace is not running")
+		return
+	}
 	switch r.Method {
 	case "GET":
 		get(i, u.String(), w, r)
@@ -714,6 +745,64 @@ func (i *jsonAPIHandler) GETPeers(w http.ResponseWriter, r *http.Request) {
 	SanitizedResponse(w, string(peerJson))
 }
 
+func (i *jsonAPIHandler) GETBandwidth(w http.ResponseWriter, r *http.Request) {
+	if i.node.IpfsNode.Reporter == nil {
+		ErrorResponse(w, http.StatusServiceUnavailable, "bandwidth is not metered")
+		return
+	}
+	stats := i.node.IpfsNode.Reporter.GetBandwidthTotals()
+	bw := struct {
+		TotalIn  int64   `json:"totalIn"`
+		TotalOut int64   `json:"totalOut"`
+		RateIn   float64 `json:"rateIn"`
+		RateOut  float64 `json:"rateOut"`
+	}{stats.TotalIn, stats.TotalOut, stats.RateIn, stats.RateOut}
+	ret, err := json.MarshalIndent(bw, "", "    ")
+	if err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	SanitizedResponse(w, string(ret))
+}
+
+type peerAddrs struct {
+	Addrs []string `json:"addrs"`
+}
+
+func (i *jsonAPIHandler) POSTPeersConnect(w http.ResponseWriter, r *http.Request) {
+	var p peerAddrs
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(p.Addrs) == 0 {
+		ErrorResponse(w, http.StatusBadRequest, "no addrs to connect to")
+		return
+	}
+	if err := ipfs.SwarmConnect(i.node.Context, p.Addrs); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	SanitizedResponse(w, `{}`)
+}
+
+func (i *jsonAPIHandler) POSTPeersDisconnect(w http.ResponseWriter, r *http.Request) {
+	var p peerAddrs
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(p.Addrs) == 0 {
+		ErrorResponse(w, http.StatusBadRequest, "no addrs to disconnect from")
+		return
+	}
+	if err := ipfs.SwarmDisconnect(i.node.Context, p.Addrs); err != nil {
+		ErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	SanitizedResponse(w, `{}`)
+}
+
 func (i *jsonAPIHandler) POSTFollow(w http.ResponseWriter, r *http.Request) {
 	type PeerId struct {
 		ID string `json:"id"`
@@ -870,10 +959,11 @@ func (i *jsonAPIHandler) POSTSpendCoins(w http.ResponseWriter, r *http.Request)
 
 func (i *jsonAPIHandler) GETConfig(w http.ResponseWriter, r *http.Request) {
 	type cfg struct {
-		PeerId         string `json:"peerID"`
-		CryptoCurrency string `json:"cryptoCurrency"`
-		Testnet        bool   `json:"testnet"`
-		Tor            bool   `json:"tor"`
+		PeerId         string   `json:"peerID"`
+		CryptoCurrency string   `json:"cryptoCurrency"`
+		Testnet        bool     `json:"testnet"`
+		Tor            bool     `json:"tor"`
+		Features       []string `json:"features"`
 	}
 
 	testnet := false
@@ -884,7 +974,7 @@ func (i *jsonAPIHandler) GETConfig(w http.ResponseWriter, r *http.Request) {
 	if i.node.TorDialer != nil {
 		usingTor = true
 	}
-	c := cfg{i.node.IpfsNode.Identity.Pretty(), strings.ToUpper(i.node.Wallet.CurrencyCode()), testnet, usingTor}
+	c := cfg{i.node.IpfsNode.Identity.Pretty(), strings.ToUpper(i.node.Wallet.CurrencyCode()), testnet, usingTor, core.Features()}
 	ser, err := json.MarshalIndent(c, "", "    ")
 	if err != nil {
 		ErrorResponse(w, http.StatusInternalServerError, err.Error())
@@ -1855,6 +1945,8 @@ func (i *jsonAPIHandler) POSTOrderFulfill(w http.ResponseWriter, r *http.Request
 		ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	i.fulfillLock.Lock()
+	defer i.fulfillLock.Unlock()
 	contract, state, _, records, _, err := i.node.Datastore.Sales().GetByOrderId(fulfill.OrderId)
 	if err != nil {
 		ErrorResponse(w, http.StatusNotFound, "order not found")
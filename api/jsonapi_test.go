@@ -1,9 +1,14 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/repo"
 )
 
 func TestMain(m *testing.M) {
@@ -220,3 +225,27 @@ func Test404(t *testing.T) {
 		{"DELETE", "/ob/a", "{}", 404, notFoundJSON},
 	})
 }
+
+func TestAuthenticated(t *testing.T) {
+	h := sha256.Sum256([]byte("secret"))
+	config := repo.APIConfig{Authenticated: true, Username: "alice", Password: hex.EncodeToString(h[:])}
+	debug := Authenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), http.Cookie{}, config)
+	for _, c := range []struct {
+		username, password string
+		status             int
+	}{
+		{"", "", http.StatusForbidden},
+		{"alice", "wrong", http.StatusForbidden},
+		{"alice", "secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/debug/pprof/heap", nil)
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		w := httptest.NewRecorder()
+		debug.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("Expected %d as %q with password %q, got %d", c.status, c.username, c.password, w.Code)
+		}
+	}
+}
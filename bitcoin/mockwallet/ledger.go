@@ -0,0 +1,110 @@
+package mockwallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The wallet keeps no coins of its own, it asks a ledger over HTTP. These are
+// the bodies the ledger takes and answers with; scripts and transactions are
+// hex encoded.
+
+// Script registers an output script with the ledger for a wallet. Own
+// scripts pay the wallet, the rest are watched only.
+type Script struct {
+	Wallet string `json:"wallet"`
+	Script string `json:"script"`
+	Own    bool   `json:"own"`
+}
+
+// Utxo is an unspent output paying a wallet
+type Utxo struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Value  int64  `json:"value"`
+	Script string `json:"script"`
+
+	// Height is the block the output was confirmed in, 0 while unconfirmed
+	Height int32 `json:"height"`
+}
+
+// Tx is a transaction paying or spending a script of a wallet
+type Tx struct {
+	Txid   string    `json:"txid"`
+	Raw    string    `json:"raw"`
+	Height int32     `json:"height"`
+	Time   time.Time `json:"time"`
+
+	// Prevouts are the outputs the inputs spend, in input order
+	Prevouts []Prevout `json:"prevouts"`
+}
+
+// Prevout is an output spent by a transaction
+type Prevout struct {
+	Script string `json:"script"`
+	Value  int64  `json:"value"`
+}
+
+// Tip is the best block of the ledger
+type Tip struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// Broadcast sends a transaction of a wallet. The ledger answers a refused
+// one with a status outside 2xx and the reason as the body.
+type Broadcast struct {
+	Wallet string `json:"wallet"`
+	Raw    string `json:"raw"`
+}
+
+// ledger is a client for the ledger at a host:port
+type ledger struct {
+	url    string
+	client *http.Client
+}
+
+func (l *ledger) get(path string, query url.Values, v interface{}) error {
+	if query != nil {
+		path += "?" + query.Encode()
+	}
+	resp, err := l.client.Get(l.url + path)
+	if err != nil {
+		return err
+	}
+	return decode(resp, v)
+}
+
+func (l *ledger) post(path string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := l.client.Post(l.url+path, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	return decode(resp, v)
+}
+
+func decode(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		if reason := strings.TrimSpace(string(b)); reason != "" {
+			return errors.New(reason)
+		}
+		return fmt.Errorf("ledger returned %d", resp.StatusCode)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,929 @@
+// Package mockwallet is a wallet whose coins are kept by a ledger reached
+// over HTTP instead of the bitcoin network. The ledger decides what the
+// wallet holds, when its transactions confirm and which of its sends fail,
+// so tests can drive a node into states the network cannot produce on
+// demand. Keys, addresses and signatures are real, the chain is not.
+package mockwallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/spvwallet"
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+	hd "github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcutil/txsort"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/op/go-logging"
+	b39 "github.com/tyler-smith/go-bip39"
+)
+
+var log = logging.MustGetLogger("mockwallet")
+
+// pollInterval is how often the wallet asks the ledger for new transactions
+const pollInterval = 250 * time.Millisecond
+
+// ErrInsufficientFunds is returned by Spend when the wallet holds too little.
+// It is worded as the spvwallet words it, which clients match on.
+var ErrInsufficientFunds = errors.New("insuffient funds")
+
+// fees are the fees per byte by level
+var fees = map[spvwallet.FeeLevel]uint64{
+	spvwallet.PRIOIRTY: 50,
+	spvwallet.NORMAL:   20,
+	spvwallet.ECONOMIC: 10,
+	spvwallet.FEE_BUMP: 100,
+}
+
+type MockWallet struct {
+	params           *chaincfg.Params
+	ledger           *ledger
+	id               string
+	masterPrivateKey *hd.ExtendedKey
+	masterPublicKey  *hd.ExtendedKey
+
+	lock      sync.Mutex
+	keys      map[string]*hd.ExtendedKey
+	next      map[spvwallet.KeyPurpose]uint32
+	listeners []func(spvwallet.TransactionCallback)
+	seen      map[string]int32
+	done      chan struct{}
+}
+
+// NewMockWallet returns a wallet keeping its coins on the ledger at
+// ledgerAddr, a host:port. Its keys derive from mnemonic like those of the
+// other wallets, and the addresses it handed out before are picked up from
+// the ledger, so a restarted node finds its coins.
+func NewMockWallet(mnemonic string, params *chaincfg.Params, ledgerAddr string) *MockWallet {
+	seed := b39.NewSeed(mnemonic, "")
+	mPrivKey, _ := hd.NewMaster(seed, params)
+	mPubKey, _ := mPrivKey.Neuter()
+
+	w := &MockWallet{
+		params:           params,
+		ledger:           &ledger{url: "http://" + ledgerAddr, client: &http.Client{Timeout: 10 * time.Second}},
+		id:               mPubKey.String(),
+		masterPrivateKey: mPrivKey,
+		masterPublicKey:  mPubKey,
+		keys:             make(map[string]*hd.ExtendedKey),
+		next:             make(map[spvwallet.KeyPurpose]uint32),
+		seen:             make(map[string]int32),
+		done:             make(chan struct{}),
+	}
+	if err := w.resume(); err != nil {
+		log.Errorf("Could not reach the ledger at %s: %s", ledgerAddr, err)
+	}
+	return w
+}
+
+// resume derives the keys of the addresses registered with the ledger, so
+// the wallet continues where it left off
+func (w *MockWallet) resume() error {
+	var scripts []string
+	if err := w.ledger.get("/scripts", url.Values{"wallet": {w.id}}, &scripts); err != nil {
+		return err
+	}
+	registered := make(map[string]bool)
+	for _, s := range scripts {
+		registered[s] = true
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, purpose := range []spvwallet.KeyPurpose{spvwallet.EXTERNAL, spvwallet.INTERNAL} {
+		var i uint32
+		for ; ; i++ {
+			key, addr, err := w.derive(purpose, i)
+			if err != nil {
+				return err
+			}
+			script, err := txscript.PayToAddrScript(addr)
+			if err != nil {
+				return err
+			}
+			if !registered[hex.EncodeToString(script)] {
+				break
+			}
+			w.keys[addr.EncodeAddress()] = key
+		}
+		if i > 0 {
+			w.next[purpose] = i - 1
+		}
+	}
+	return nil
+}
+
+func (w *MockWallet) derive(purpose spvwallet.KeyPurpose, index uint32) (*hd.ExtendedKey, btc.Address, error) {
+	account, err := w.masterPrivateKey.Child(hd.HardenedKeyStart + 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := account.Child(uint32(purpose))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := chain.Child(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr, err := key.Address(w.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, addr, nil
+}
+
+// address returns the address at index, registering it with the ledger the
+// first time. The lock must be held.
+func (w *MockWallet) address(purpose spvwallet.KeyPurpose, index uint32) btc.Address {
+	key, addr, err := w.derive(purpose, index)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	if _, ok := w.keys[addr.EncodeAddress()]; ok {
+		return addr
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	if err := w.ledger.post("/scripts", Script{Wallet: w.id, Script: hex.EncodeToString(script), Own: true}, nil); err != nil {
+		log.Errorf("Could not register %s with the ledger: %s", addr, err)
+		return addr
+	}
+	w.keys[addr.EncodeAddress()] = key
+	return addr
+}
+
+// keyFor returns the key of a script paying the wallet, nil for others
+func (w *MockWallet) keyFor(script []byte) *hd.ExtendedKey {
+	addr, err := w.ScriptToAddress(script)
+	if err != nil {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.keys[addr.EncodeAddress()]
+}
+
+func (w *MockWallet) Start() {
+	go w.poll()
+}
+
+func (w *MockWallet) poll() {
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+		}
+		w.notify()
+	}
+}
+
+// notify calls the listeners with the transactions that are new or were
+// confirmed since the last call
+func (w *MockWallet) notify() {
+	txs, err := w.txs()
+	if err != nil {
+		log.Warningf("Could not reach the ledger: %s", err)
+		return
+	}
+	for _, tx := range txs {
+		w.lock.Lock()
+		height, ok := w.seen[tx.Txid]
+		w.seen[tx.Txid] = tx.Height
+		listeners := w.listeners
+		w.lock.Unlock()
+		if ok && height == tx.Height {
+			continue
+		}
+		cb, err := w.callback(tx)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		for _, l := range listeners {
+			l(cb)
+		}
+	}
+}
+
+func (w *MockWallet) txs() ([]Tx, error) {
+	var txs []Tx
+	err := w.ledger.get("/txs", url.Values{"wallet": {w.id}}, &txs)
+	return txs, err
+}
+
+func (w *MockWallet) utxos() ([]Utxo, error) {
+	var utxos []Utxo
+	err := w.ledger.get("/utxos", url.Values{"wallet": {w.id}}, &utxos)
+	return utxos, err
+}
+
+// callback describes a transaction of the ledger as the wallet sees it
+func (w *MockWallet) callback(tx Tx) (spvwallet.TransactionCallback, error) {
+	cb := spvwallet.TransactionCallback{Height: tx.Height, Timestamp: tx.Time, WatchOnly: true}
+	msg, err := decodeTx(tx.Raw)
+	if err != nil {
+		return cb, err
+	}
+	txid := msg.TxHash()
+	cb.Txid = txid.CloneBytes()
+	for i, out := range msg.TxOut {
+		cb.Outputs = append(cb.Outputs, spvwallet.TransactionOutput{ScriptPubKey: out.PkScript, Value: out.Value, Index: uint32(i)})
+		if w.keyFor(out.PkScript) != nil {
+			cb.Value += out.Value
+			cb.WatchOnly = false
+		}
+	}
+	for i, in := range msg.TxIn {
+		if i >= len(tx.Prevouts) {
+			break
+		}
+		script, err := hex.DecodeString(tx.Prevouts[i].Script)
+		if err != nil {
+			return cb, err
+		}
+		cb.Inputs = append(cb.Inputs, spvwallet.TransactionInput{
+			OutpointHash:       in.PreviousOutPoint.Hash.CloneBytes(),
+			OutpointIndex:      in.PreviousOutPoint.Index,
+			LinkedScriptPubKey: script,
+			Value:              tx.Prevouts[i].Value,
+		})
+		if w.keyFor(script) != nil {
+			cb.Value -= tx.Prevouts[i].Value
+			cb.WatchOnly = false
+		}
+	}
+	return cb, nil
+}
+
+func decodeTx(raw string) (*wire.MsgTx, error) {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// broadcast sends tx to the ledger, which may refuse it
+func (w *MockWallet) broadcast(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	if err := w.ledger.post("/broadcast", Broadcast{Wallet: w.id, Raw: hex.EncodeToString(buf.Bytes())}, nil); err != nil {
+		return nil, err
+	}
+	txid := tx.TxHash()
+	return &txid, nil
+}
+
+func (w *MockWallet) Params() *chaincfg.Params {
+	return w.params
+}
+
+func (w *MockWallet) CurrencyCode() string {
+	if w.params.Name == chaincfg.MainNetParams.Name {
+		return "btc"
+	} else {
+		return "tbtc"
+	}
+}
+
+func (w *MockWallet) IsDust(amount int64) bool {
+	return txrules.IsDustAmount(btc.Amount(amount), 25, txrules.DefaultRelayFeePerKb)
+}
+
+func (w *MockWallet) MasterPrivateKey() *hd.ExtendedKey {
+	return w.masterPrivateKey
+}
+
+func (w *MockWallet) MasterPublicKey() *hd.ExtendedKey {
+	return w.masterPublicKey
+}
+
+func (w *MockWallet) CurrentAddress(purpose spvwallet.KeyPurpose) btc.Address {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.address(purpose, w.next[purpose])
+}
+
+func (w *MockWallet) NewAddress(purpose spvwallet.KeyPurpose) btc.Address {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.next[purpose]++
+	return w.address(purpose, w.next[purpose])
+}
+
+func (w *MockWallet) DecodeAddress(addr string) (btc.Address, error) {
+	return btc.DecodeAddress(addr, w.params)
+}
+
+func (w *MockWallet) ScriptToAddress(script []byte) (btc.Address, error) {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, w.params)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("unknown script")
+	}
+	return addrs[0], nil
+}
+
+func (w *MockWallet) AddressToScript(addr btc.Address) ([]byte, error) {
+	return txscript.PayToAddrScript(addr)
+}
+
+func (w *MockWallet) HasKey(addr btc.Address) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, ok := w.keys[addr.EncodeAddress()]
+	return ok
+}
+
+func (w *MockWallet) Balance() (confirmed, unconfirmed int64) {
+	utxos, err := w.utxos()
+	if err != nil {
+		log.Error(err)
+		return 0, 0
+	}
+	for _, u := range utxos {
+		if u.Height > 0 {
+			confirmed += u.Value
+		} else {
+			unconfirmed += u.Value
+		}
+	}
+	return confirmed, unconfirmed
+}
+
+func (w *MockWallet) Transactions() ([]spvwallet.Txn, error) {
+	var ret []spvwallet.Txn
+	txs, err := w.txs()
+	if err != nil {
+		return ret, err
+	}
+	for _, tx := range txs {
+		t, err := w.txn(tx)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+func (w *MockWallet) txn(tx Tx) (spvwallet.Txn, error) {
+	cb, err := w.callback(tx)
+	if err != nil {
+		return spvwallet.Txn{}, err
+	}
+	raw, err := hex.DecodeString(tx.Raw)
+	if err != nil {
+		return spvwallet.Txn{}, err
+	}
+	return spvwallet.Txn{
+		Txid:      tx.Txid,
+		Value:     cb.Value,
+		Height:    tx.Height,
+		Timestamp: tx.Time,
+		WatchOnly: cb.WatchOnly,
+		Bytes:     raw,
+	}, nil
+}
+
+// find returns the transaction of the wallet with txid
+func (w *MockWallet) find(txid chainhash.Hash) (Tx, error) {
+	txs, err := w.txs()
+	if err != nil {
+		return Tx{}, err
+	}
+	for _, tx := range txs {
+		if tx.Txid == txid.String() {
+			return tx, nil
+		}
+	}
+	return Tx{}, fmt.Errorf("transaction %s not found", txid)
+}
+
+func (w *MockWallet) GetTransaction(txid chainhash.Hash) (spvwallet.Txn, error) {
+	tx, err := w.find(txid)
+	if err != nil {
+		return spvwallet.Txn{}, err
+	}
+	return w.txn(tx)
+}
+
+func (w *MockWallet) GetConfirmations(txid chainhash.Hash) (uint32, uint32, error) {
+	tx, err := w.find(txid)
+	if err != nil {
+		return 0, 0, err
+	}
+	if tx.Height <= 0 {
+		return 0, 0, nil
+	}
+	height, _ := w.ChainTip()
+	return height - uint32(tx.Height) + 1, uint32(tx.Height), nil
+}
+
+func (w *MockWallet) ChainTip() (uint32, chainhash.Hash) {
+	var ch chainhash.Hash
+	var tip Tip
+	if err := w.ledger.get("/tip", nil, &tip); err != nil {
+		return uint32(0), ch
+	}
+	h, err := chainhash.NewHashFromStr(tip.Hash)
+	if err != nil {
+		return uint32(0), ch
+	}
+	return tip.Height, *h
+}
+
+func (w *MockWallet) GetFeePerByte(feeLevel spvwallet.FeeLevel) uint64 {
+	if fee, ok := fees[feeLevel]; ok {
+		return fee
+	}
+	return fees[spvwallet.NORMAL]
+}
+
+func (w *MockWallet) Spend(amount int64, addr btc.Address, feeLevel spvwallet.FeeLevel) (*chainhash.Hash, error) {
+	if w.IsDust(amount) {
+		return nil, errors.New("Amount is below dust threshold")
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := w.utxos()
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxOut(wire.NewTxOut(amount, script))
+
+	// Select coins until they pay for the amount and the fee of the inputs
+	feePerByte := int64(w.GetFeePerByte(feeLevel))
+	prevScripts := make(map[wire.OutPoint][]byte)
+	var total, fee int64
+	for _, u := range utxos {
+		h, err := chainhash.NewHashFromStr(u.Txid)
+		if err != nil {
+			return nil, err
+		}
+		prevScript, err := hex.DecodeString(u.Script)
+		if err != nil {
+			return nil, err
+		}
+		op := wire.NewOutPoint(h, u.Index)
+		tx.AddTxIn(wire.NewTxIn(op, nil, nil))
+		prevScripts[*op] = prevScript
+		total += u.Value
+		fee = int64(spvwallet.EstimateSerializeSize(len(tx.TxIn), tx.TxOut, true, spvwallet.P2PKH)) * feePerByte
+		if total >= amount+fee {
+			break
+		}
+	}
+	if total < amount+fee || len(tx.TxIn) == 0 {
+		return nil, ErrInsufficientFunds
+	}
+	if change := total - amount - fee; !w.IsDust(change) {
+		changeScript, err := txscript.PayToAddrScript(w.CurrentAddress(spvwallet.INTERNAL))
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(change, changeScript))
+	}
+
+	// BIP 69 sorting
+	txsort.InPlaceSort(tx)
+
+	if err := w.sign(tx, prevScripts); err != nil {
+		return nil, err
+	}
+	return w.broadcast(tx)
+}
+
+// sign signs the p2pkh inputs of tx with the keys of the wallet
+func (w *MockWallet) sign(tx *wire.MsgTx, prevScripts map[wire.OutPoint][]byte) error {
+	getKey := txscript.KeyClosure(func(addr btc.Address) (*btcec.PrivateKey, bool, error) {
+		w.lock.Lock()
+		key, ok := w.keys[addr.EncodeAddress()]
+		w.lock.Unlock()
+		if !ok {
+			return nil, false, errors.New("Not found")
+		}
+		privKey, err := key.ECPrivKey()
+		if err != nil {
+			return nil, false, err
+		}
+		return privKey, true, nil
+	})
+	getScript := txscript.ScriptClosure(func(addr btc.Address) ([]byte, error) {
+		return []byte{}, nil
+	})
+	for i, txIn := range tx.TxIn {
+		script, err := txscript.SignTxOutput(w.params,
+			tx, i, prevScripts[txIn.PreviousOutPoint], txscript.SigHashAll, getKey,
+			getScript, txIn.SignatureScript)
+		if err != nil {
+			return errors.New("Failed to sign transaction")
+		}
+		txIn.SignatureScript = script
+	}
+	return nil
+}
+
+func (w *MockWallet) BumpFee(txid chainhash.Hash) (*chainhash.Hash, error) {
+	tx, err := w.find(txid)
+	if err != nil {
+		return nil, spvwallet.BumpFeeNotFoundError
+	}
+	if tx.Height > 0 {
+		return nil, spvwallet.BumpFeeAlreadyConfirmedError
+	}
+	utxos, err := w.utxos()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range utxos {
+		if u.Txid != txid.String() {
+			continue
+		}
+		script, err := hex.DecodeString(u.Script)
+		if err != nil {
+			continue
+		}
+		key := w.keyFor(script)
+		if key == nil {
+			continue
+		}
+		op := wire.NewOutPoint(&txid, u.Index)
+		utxo := spvwallet.Utxo{
+			Op:           *op,
+			Value:        u.Value,
+			ScriptPubkey: script,
+		}
+		return w.SweepAddress([]spvwallet.Utxo{utxo}, nil, key, nil, spvwallet.FEE_BUMP)
+	}
+	return nil, spvwallet.BumpFeeNotFoundError
+}
+
+func (w *MockWallet) EstimateFee(ins []spvwallet.TransactionInput, outs []spvwallet.TransactionOutput, feePerByte uint64) uint64 {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, out := range outs {
+		output := wire.NewTxOut(out.Value, out.ScriptPubKey)
+		tx.TxOut = append(tx.TxOut, output)
+	}
+	estimatedSize := spvwallet.EstimateSerializeSize(len(ins), tx.TxOut, false, spvwallet.P2PKH)
+	fee := estimatedSize * int(feePerByte)
+	return uint64(fee)
+}
+
+// multisigTx builds the unsigned transaction spending escrow ins to outs, the
+// fee split over the outputs
+func multisigTx(ins []spvwallet.TransactionInput, outs []spvwallet.TransactionOutput, redeemScript []byte, feePerByte uint64) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(1)
+	for _, in := range ins {
+		ch, err := chainhash.NewHashFromStr(hex.EncodeToString(in.OutpointHash))
+		if err != nil {
+			return nil, err
+		}
+		outpoint := wire.NewOutPoint(ch, in.OutpointIndex)
+		input := wire.NewTxIn(outpoint, []byte{}, [][]byte{})
+		tx.TxIn = append(tx.TxIn, input)
+	}
+	for _, out := range outs {
+		output := wire.NewTxOut(out.Value, out.ScriptPubKey)
+		tx.TxOut = append(tx.TxOut, output)
+	}
+
+	// Subtract fee
+	txType := spvwallet.P2SH_2of3_Multisig
+	_, err := spvwallet.LockTimeFromRedeemScript(redeemScript)
+	if err == nil {
+		txType = spvwallet.P2SH_Multisig_Timelock_2Sigs
+	}
+	estimatedSize := spvwallet.EstimateSerializeSize(len(ins), tx.TxOut, false, txType)
+	fee := estimatedSize * int(feePerByte)
+	if len(tx.TxOut) > 0 {
+		feePerOutput := fee / len(tx.TxOut)
+		for _, output := range tx.TxOut {
+			output.Value -= int64(feePerOutput)
+		}
+	}
+
+	// BIP 69 sorting
+	txsort.InPlaceSort(tx)
+	return tx, nil
+}
+
+func (w *MockWallet) CreateMultisigSignature(ins []spvwallet.TransactionInput, outs []spvwallet.TransactionOutput, key *hd.ExtendedKey, redeemScript []byte, feePerByte uint64) ([]spvwallet.Signature, error) {
+	var sigs []spvwallet.Signature
+	tx, err := multisigTx(ins, outs, redeemScript, feePerByte)
+	if err != nil {
+		return sigs, err
+	}
+	signingKey, err := key.ECPrivKey()
+	if err != nil {
+		return sigs, err
+	}
+
+	hashes := txscript.NewTxSigHashes(tx)
+	for i := range tx.TxIn {
+		sig, err := txscript.RawTxInWitnessSignature(tx, hashes, i, ins[i].Value, redeemScript, txscript.SigHashAll, signingKey)
+		if err != nil {
+			continue
+		}
+		bs := spvwallet.Signature{InputIndex: uint32(i), Signature: sig}
+		sigs = append(sigs, bs)
+	}
+	return sigs, nil
+}
+
+func (w *MockWallet) Multisign(ins []spvwallet.TransactionInput, outs []spvwallet.TransactionOutput, sigs1 []spvwallet.Signature, sigs2 []spvwallet.Signature, redeemScript []byte, feePerByte uint64, broadcast bool) ([]byte, error) {
+	tx, err := multisigTx(ins, outs, redeemScript, feePerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if time locked
+	var timeLocked bool
+	if redeemScript[0] == txscript.OP_IF {
+		timeLocked = true
+	}
+
+	for i, input := range tx.TxIn {
+		var sig1 []byte
+		var sig2 []byte
+		for _, sig := range sigs1 {
+			if int(sig.InputIndex) == i {
+				sig1 = sig.Signature
+				break
+			}
+		}
+		for _, sig := range sigs2 {
+			if int(sig.InputIndex) == i {
+				sig2 = sig.Signature
+				break
+			}
+		}
+
+		witness := wire.TxWitness{[]byte{}, sig1, sig2}
+
+		if timeLocked {
+			witness = append(witness, []byte{0x01})
+		}
+		witness = append(witness, redeemScript)
+		input.Witness = witness
+	}
+	if broadcast {
+		if _, err := w.broadcast(tx); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	tx.BtcEncode(&buf, wire.ProtocolVersion, wire.WitnessEncoding)
+	return buf.Bytes(), nil
+}
+
+func (w *MockWallet) SweepAddress(utxos []spvwallet.Utxo, address *btc.Address, key *hd.ExtendedKey, redeemScript *[]byte, feeLevel spvwallet.FeeLevel) (*chainhash.Hash, error) {
+	var internalAddr btc.Address
+	if address != nil {
+		internalAddr = *address
+	} else {
+		internalAddr = w.CurrentAddress(spvwallet.INTERNAL)
+	}
+	script, err := txscript.PayToAddrScript(internalAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var val int64
+	var inputs []*wire.TxIn
+	additionalPrevScripts := make(map[wire.OutPoint][]byte)
+	for _, u := range utxos {
+		val += u.Value
+		in := wire.NewTxIn(&u.Op, []byte{}, [][]byte{})
+		inputs = append(inputs, in)
+		additionalPrevScripts[u.Op] = u.ScriptPubkey
+	}
+	out := wire.NewTxOut(val, script)
+
+	txType := spvwallet.P2PKH
+	if redeemScript != nil {
+		txType = spvwallet.P2SH_1of2_Multisig
+		_, err := spvwallet.LockTimeFromRedeemScript(*redeemScript)
+		if err == nil {
+			txType = spvwallet.P2SH_Multisig_Timelock_1Sig
+		}
+	}
+	estimatedSize := spvwallet.EstimateSerializeSize(len(utxos), []*wire.TxOut{out}, false, txType)
+
+	// Calculate the fee
+	feePerByte := int(w.GetFeePerByte(feeLevel))
+	fee := estimatedSize * feePerByte
+
+	outVal := val - int64(fee)
+	if outVal < 0 {
+		outVal = 0
+	}
+	out.Value = outVal
+
+	tx := &wire.MsgTx{
+		Version:  wire.TxVersion,
+		TxIn:     inputs,
+		TxOut:    []*wire.TxOut{out},
+		LockTime: 0,
+	}
+
+	// BIP 69 sorting
+	txsort.InPlaceSort(tx)
+
+	// Sign tx
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	pk := privKey.PubKey().SerializeCompressed()
+	addressPub, err := btc.NewAddressPubKey(pk, w.params)
+	if err != nil {
+		return nil, err
+	}
+
+	getKey := txscript.KeyClosure(func(addr btc.Address) (*btcec.PrivateKey, bool, error) {
+		if addressPub.EncodeAddress() == addr.EncodeAddress() {
+			wif, err := btc.NewWIF(privKey, w.params, true)
+			if err != nil {
+				return nil, false, err
+			}
+			return wif.PrivKey, wif.CompressPubKey, nil
+		}
+		return nil, false, errors.New("Not found")
+	})
+	getScript := txscript.ScriptClosure(func(addr btc.Address) ([]byte, error) {
+		if redeemScript == nil {
+			return []byte{}, nil
+		}
+		return *redeemScript, nil
+	})
+
+	// Check if time locked
+	var timeLocked bool
+	if redeemScript != nil {
+		rs := *redeemScript
+		if rs[0] == txscript.OP_IF {
+			timeLocked = true
+			tx.Version = 2
+		}
+		for _, txIn := range tx.TxIn {
+			locktime, err := spvwallet.LockTimeFromRedeemScript(*redeemScript)
+			if err != nil {
+				return nil, err
+			}
+			txIn.Sequence = locktime
+		}
+	}
+
+	hashes := txscript.NewTxSigHashes(tx)
+	for i, txIn := range tx.TxIn {
+		if redeemScript == nil {
+			prevOutScript := additionalPrevScripts[txIn.PreviousOutPoint]
+			script, err := txscript.SignTxOutput(w.params,
+				tx, i, prevOutScript, txscript.SigHashAll, getKey,
+				getScript, txIn.SignatureScript)
+			if err != nil {
+				return nil, errors.New("Failed to sign transaction")
+			}
+			txIn.SignatureScript = script
+		} else {
+			sig, err := txscript.RawTxInWitnessSignature(tx, hashes, i, utxos[i].Value, *redeemScript, txscript.SigHashAll, privKey)
+			if err != nil {
+				return nil, err
+			}
+			var witness wire.TxWitness
+			if timeLocked {
+				witness = wire.TxWitness{sig, []byte{}}
+			} else {
+				witness = wire.TxWitness{[]byte{}, sig}
+			}
+			witness = append(witness, *redeemScript)
+			txIn.Witness = witness
+		}
+	}
+	return w.broadcast(tx)
+}
+
+func (w *MockWallet) GenerateMultisigScript(keys []hd.ExtendedKey, threshold int, timeout time.Duration, timeoutKey *hd.ExtendedKey) (addr btc.Address, redeemScript []byte, err error) {
+	if uint32(timeout.Hours()) > 0 && timeoutKey == nil {
+		return nil, nil, errors.New("Timeout key must be non nil when using an escrow timeout")
+	}
+
+	if len(keys) < threshold {
+		return nil, nil, fmt.Errorf("unable to generate multisig script with "+
+			"%d required signatures when there are only %d public "+
+			"keys available", threshold, len(keys))
+	}
+
+	var ecKeys []*btcec.PublicKey
+	for _, key := range keys {
+		ecKey, err := key.ECPubKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		ecKeys = append(ecKeys, ecKey)
+	}
+
+	builder := txscript.NewScriptBuilder()
+	if uint32(timeout.Hours()) == 0 {
+
+		builder.AddInt64(int64(threshold))
+		for _, key := range ecKeys {
+			builder.AddData(key.SerializeCompressed())
+		}
+		builder.AddInt64(int64(len(ecKeys)))
+		builder.AddOp(txscript.OP_CHECKMULTISIG)
+
+	} else {
+		ecKey, err := timeoutKey.ECPubKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		sequenceLock := blockchain.LockTimeToSequence(false, uint32(timeout.Hours()*6))
+		builder.AddOp(txscript.OP_IF)
+		builder.AddInt64(int64(threshold))
+		for _, key := range ecKeys {
+			builder.AddData(key.SerializeCompressed())
+		}
+		builder.AddInt64(int64(len(ecKeys)))
+		builder.AddOp(txscript.OP_CHECKMULTISIG)
+		builder.AddOp(txscript.OP_ELSE).
+			AddInt64(int64(sequenceLock)).
+			AddOp(txscript.OP_CHECKSEQUENCEVERIFY).
+			AddOp(txscript.OP_DROP).
+			AddData(ecKey.SerializeCompressed()).
+			AddOp(txscript.OP_CHECKSIG).
+			AddOp(txscript.OP_ENDIF)
+	}
+	redeemScript, err = builder.Script()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	witnessProgram := sha256.Sum256(redeemScript)
+
+	addr, err = btc.NewAddressWitnessScriptHash(witnessProgram[:], w.params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, redeemScript, nil
+}
+
+func (w *MockWallet) AddWatchedScript(script []byte) error {
+	return w.ledger.post("/scripts", Script{Wallet: w.id, Script: hex.EncodeToString(script)}, nil)
+}
+
+func (w *MockWallet) AddTransactionListener(callback func(spvwallet.TransactionCallback)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.listeners = append(w.listeners, callback)
+}
+
+// ReSyncBlockchain calls the listeners with every transaction of the wallet
+// again; the ledger misses none
+func (w *MockWallet) ReSyncBlockchain(fromHeight int32) {
+	w.lock.Lock()
+	w.seen = make(map[string]int32)
+	w.lock.Unlock()
+}
+
+func (w *MockWallet) Close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+}
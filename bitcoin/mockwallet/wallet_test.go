@@ -0,0 +1,278 @@
+package mockwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenBazaar/spvwallet"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+)
+
+const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+var params = &chaincfg.RegressionNetParams
+
+// fakeLedger answers the wallet with the coins and transactions it is given
+// and records what the wallet broadcasts
+type fakeLedger struct {
+	lock    sync.Mutex
+	scripts []string
+	utxos   []Utxo
+	txs     []Tx
+	tip     Tip
+	refuse  string
+	sent    []string
+}
+
+func (f *fakeLedger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var v interface{}
+	switch r.Method + " " + r.URL.Path {
+	case "GET /scripts":
+		v = f.scripts
+	case "POST /scripts":
+		var s Script
+		json.NewDecoder(r.Body).Decode(&s)
+		if s.Own {
+			f.scripts = append(f.scripts, s.Script)
+		}
+	case "GET /utxos":
+		v = f.utxos
+	case "GET /txs":
+		v = f.txs
+	case "GET /tip":
+		v = f.tip
+	case "POST /broadcast":
+		if f.refuse != "" {
+			http.Error(w, f.refuse, http.StatusBadRequest)
+			return
+		}
+		var b Broadcast
+		json.NewDecoder(r.Body).Decode(&b)
+		f.sent = append(f.sent, b.Raw)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+func newWallet(t *testing.T, f *fakeLedger) (*MockWallet, func()) {
+	srv := httptest.NewServer(f)
+	return NewMockWallet(mnemonic, params, strings.TrimPrefix(srv.URL, "http://")), srv.Close
+}
+
+// payTo returns a transaction paying sat to addr and its hex encoding
+func payTo(t *testing.T, addr btc.Address, sat int64) (*wire.MsgTx, string) {
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(sat, script))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return tx, hex.EncodeToString(buf.Bytes())
+}
+
+// utxo is an output of sat paying addr, confirmed at height
+func utxo(t *testing.T, addr btc.Address, sat int64, height int32) Utxo {
+	tx, _ := payTo(t, addr, sat)
+	return Utxo{Txid: tx.TxHash().String(), Value: sat, Script: hex.EncodeToString(tx.TxOut[0].PkScript), Height: height}
+}
+
+func payee(t *testing.T) btc.Address {
+	addr, err := btc.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestBalance(t *testing.T) {
+	f := &fakeLedger{}
+	w, done := newWallet(t, f)
+	defer done()
+	addr := w.CurrentAddress(spvwallet.EXTERNAL)
+	f.utxos = []Utxo{utxo(t, addr, 1e6, 5), utxo(t, addr, 2e5, 0), utxo(t, addr, 3e5, 7)}
+	confirmed, unconfirmed := w.Balance()
+	if confirmed != 13e5 || unconfirmed != 2e5 {
+		t.Errorf("Expected 1300000 confirmed and 200000 unconfirmed, got %d and %d", confirmed, unconfirmed)
+	}
+
+	// A restarted wallet finds the address it handed out
+	restarted, done := newWallet(t, f)
+	defer done()
+	if got := restarted.CurrentAddress(spvwallet.EXTERNAL); got.String() != addr.String() {
+		t.Errorf("Expected the restarted wallet to be at %s, got %s", addr, got)
+	}
+}
+
+func TestSpend(t *testing.T) {
+	f := &fakeLedger{}
+	w, done := newWallet(t, f)
+	defer done()
+	u := utxo(t, w.CurrentAddress(spvwallet.EXTERNAL), 1e6, 5)
+	f.utxos = []Utxo{u}
+	to := payee(t)
+
+	if _, err := w.Spend(100, to, spvwallet.NORMAL); err == nil {
+		t.Error("Expected spending dust to fail")
+	}
+	if _, err := w.Spend(1e6, to, spvwallet.NORMAL); err != ErrInsufficientFunds {
+		t.Errorf("Expected spending the balance and a fee to fail, got %v", err)
+	}
+	if len(f.sent) != 0 {
+		t.Fatalf("Expected nothing broadcast, got %d transactions", len(f.sent))
+	}
+
+	txid, err := w.Spend(4e5, to, spvwallet.NORMAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.sent) != 1 {
+		t.Fatalf("Expected 1 transaction broadcast, got %d", len(f.sent))
+	}
+	tx, err := decodeTx(f.sent[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxHash() != *txid {
+		t.Errorf("Expected %s broadcast, got %s", txid, tx.TxHash())
+	}
+	var paid, change int64
+	for _, out := range tx.TxOut {
+		addr, err := w.ScriptToAddress(out.PkScript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case addr.String() == to.String():
+			paid += out.Value
+		case w.HasKey(addr):
+			change += out.Value
+		default:
+			t.Errorf("Expected outputs to the payee and the wallet, got one to %s", addr)
+		}
+	}
+	if paid != 4e5 {
+		t.Errorf("Expected 400000 paid, got %d", paid)
+	}
+	script, _ := txscript.PayToAddrScript(to)
+	paying := []*wire.TxOut{wire.NewTxOut(4e5, script)}
+	fee := int64(spvwallet.EstimateSerializeSize(1, paying, true, spvwallet.P2PKH)) * int64(w.GetFeePerByte(spvwallet.NORMAL))
+	if change != 1e6-4e5-fee {
+		t.Errorf("Expected %d change, got %d", 1e6-4e5-fee, change)
+	}
+
+	// The input is signed with the key of the output it spends
+	prevScript, _ := hex.DecodeString(u.Script)
+	vm, err := txscript.NewEngine(prevScript, tx, 0, txscript.StandardVerifyFlags, nil, nil, u.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Errorf("Expected a valid signature, got %v", err)
+	}
+}
+
+func TestSpendRefused(t *testing.T) {
+	f := &fakeLedger{refuse: "node is offline"}
+	w, done := newWallet(t, f)
+	defer done()
+	f.utxos = []Utxo{utxo(t, w.CurrentAddress(spvwallet.EXTERNAL), 1e6, 5)}
+	if _, err := w.Spend(4e5, payee(t), spvwallet.NORMAL); err == nil || err.Error() != "node is offline" {
+		t.Errorf("Expected the reason of the ledger, got %v", err)
+	}
+}
+
+func TestGetConfirmations(t *testing.T) {
+	f := &fakeLedger{tip: Tip{Height: 12, Hash: chainhash.Hash{12}.String()}}
+	w, done := newWallet(t, f)
+	defer done()
+	addr := w.CurrentAddress(spvwallet.EXTERNAL)
+	mined, rawMined := payTo(t, addr, 1e6)
+	pending, rawPending := payTo(t, addr, 2e6)
+	f.txs = []Tx{
+		{Txid: mined.TxHash().String(), Raw: rawMined, Height: 10},
+		{Txid: pending.TxHash().String(), Raw: rawPending},
+	}
+
+	for _, c := range []struct {
+		txid   chainhash.Hash
+		confs  uint32
+		height uint32
+	}{
+		{mined.TxHash(), 3, 10},
+		{pending.TxHash(), 0, 0},
+	} {
+		confs, height, err := w.GetConfirmations(c.txid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confs != c.confs || height != c.height {
+			t.Errorf("Expected %d confirmations at height %d for %s, got %d at %d", c.confs, c.height, c.txid, confs, height)
+		}
+	}
+	if _, _, err := w.GetConfirmations(chainhash.Hash{7}); err == nil {
+		t.Error("Expected confirmations of a transaction not in the wallet to fail")
+	}
+	if height, hash := w.ChainTip(); height != 12 || hash != (chainhash.Hash{12}) {
+		t.Errorf("Expected the tip at 12, got %d %s", height, hash)
+	}
+
+	txn, err := w.GetTransaction(mined.TxHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.Value != 1e6 || txn.Height != 10 || txn.WatchOnly {
+		t.Errorf("Expected a payment of 1000000 at 10, got %+v", txn)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	f := &fakeLedger{}
+	w, done := newWallet(t, f)
+	defer done()
+	var got []spvwallet.TransactionCallback
+	w.AddTransactionListener(func(cb spvwallet.TransactionCallback) {
+		got = append(got, cb)
+	})
+	tx, raw := payTo(t, w.CurrentAddress(spvwallet.EXTERNAL), 1e6)
+	f.txs = []Tx{{Txid: tx.TxHash().String(), Raw: raw}}
+
+	// Listeners hear of a transaction once, and again when it confirms
+	w.notify()
+	w.notify()
+	f.txs[0].Height = 3
+	w.notify()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 callbacks, got %d", len(got))
+	}
+	for i, height := range []int32{0, 3} {
+		if got[i].Height != height || got[i].Value != 1e6 || got[i].WatchOnly {
+			t.Errorf("Expected a payment of 1000000 at %d, got %+v", height, got[i])
+		}
+	}
+
+	// Resyncing tells them again
+	w.ReSyncBlockchain(0)
+	w.notify()
+	if len(got) != 3 {
+		t.Errorf("Expected a callback after a resync, got %d", len(got))
+	}
+}
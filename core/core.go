@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sync"
 	"time"
 
 	bstk "github.com/OpenBazaar/go-blockstackclient"
@@ -37,7 +38,10 @@ var log = logging.MustGetLogger("core")
 
 var Node *OpenBazaarNode
 
-var inflightPublishRequests int
+var (
+	inflightPublishRequests int
+	publishLock             sync.Mutex
+)
 
 type OpenBazaarNode struct {
 	// Context for issuing IPFS commands
@@ -121,14 +125,19 @@ func (n *OpenBazaarNode) SeedNode() error {
 }
 
 func (n *OpenBazaarNode) publish(hash string) {
-	if inflightPublishRequests == 0 {
+	publishLock.Lock()
+	first := inflightPublishRequests == 0
+	inflightPublishRequests++
+	publishLock.Unlock()
+	if first {
 		n.Broadcast <- notifications.StatusNotification{"publishing"}
 	}
-	var err error
-	inflightPublishRequests++
-	_, err = ipfs.Publish(n.Context, hash)
+	_, err := ipfs.Publish(n.Context, hash)
+	publishLock.Lock()
 	inflightPublishRequests--
-	if inflightPublishRequests == 0 {
+	last := inflightPublishRequests == 0
+	publishLock.Unlock()
+	if last {
 		if err != nil {
 			log.Error(err)
 			n.Broadcast <- notifications.StatusNotification{"error publishing"}
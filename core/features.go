@@ -0,0 +1,31 @@
+package core
+
+import (
+	"os"
+	"strings"
+)
+
+// FeaturesEnv is the environment variable listing the experimental features
+// enabled on this node, comma separated
+const FeaturesEnv = "OPENBAZAAR_FEATURES"
+
+// Features returns the experimental features enabled through FeaturesEnv
+func Features() []string {
+	var features []string
+	for _, f := range strings.Split(os.Getenv(FeaturesEnv), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// FeatureEnabled reports whether the experimental feature name is enabled
+func FeatureEnabled(name string) bool {
+	for _, f := range Features() {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	defer os.Unsetenv(FeaturesEnv)
+	os.Setenv(FeaturesEnv, "fast-sync, ,new-chat")
+	if f := Features(); !reflect.DeepEqual(f, []string{"fast-sync", "new-chat"}) {
+		t.Errorf("Expected [fast-sync new-chat], got %v", f)
+	}
+	if !FeatureEnabled("new-chat") || FeatureEnabled("fast") {
+		t.Error("Expected only listed features to be enabled")
+	}
+	os.Unsetenv(FeaturesEnv)
+	if f := Features(); len(f) != 0 {
+		t.Errorf("Expected no features, got %v", f)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"bytes"
 	"github.com/OpenBazaar/openbazaar-go/ipfs"
+	"github.com/OpenBazaar/openbazaar-go/net"
 	"github.com/OpenBazaar/openbazaar-go/pb"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
@@ -33,8 +34,10 @@ func (n *OpenBazaarNode) sendMessage(peerId string, k *libp2p.PubKey, message pb
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	sent := time.Now()
 	err = n.Service.SendMessage(ctx, p, &message)
 	if err != nil {
+		net.RecordSendAttempt(message.MessageType, net.AttemptOffline, sent)
 		if err := n.SendOfflineMessage(p, k, &message); err != nil {
 			return err
 		}
@@ -15,3 +15,26 @@ func ConnectedPeers(ctx commands.Context) ([]string, error) {
 	}
 	return *res.Output().(*[]string), nil
 }
+
+// SwarmConnect opens connections to the peers at the full multiaddrs, e.g.
+// /ip4/127.0.0.1/tcp/4001/ipfs/Qm...
+func SwarmConnect(ctx commands.Context, addrs []string) error {
+	return swarm(ctx, "connect", addrs)
+}
+
+// SwarmDisconnect closes the connections to the peers at the multiaddrs.
+// Peers may be dialed again later as the node needs them.
+func SwarmDisconnect(ctx commands.Context, addrs []string) error {
+	return swarm(ctx, "disconnect", addrs)
+}
+
+func swarm(ctx commands.Context, cmd string, addrs []string) error {
+	args := append([]string{"swarm", cmd}, addrs...)
+	req, c, err := NewRequest(ctx, args)
+	if err != nil {
+		return err
+	}
+	res := commands.NewResponse(req)
+	c.Run(req, res)
+	return res.Error()
+}
@@ -23,6 +23,7 @@ var localCommands = map[string]*cmds.Command{
 	"commands": commandsClientCmd,
 }
 
+// Root is only read once filled in here, requests are built concurrently
 func init() {
 	Root.Subcommands = localCommands
 	for k, v := range commands.Root.Subcommands {
@@ -33,12 +34,6 @@ func init() {
 }
 
 func NewRequest(ctx cmds.Context, args []string) (cmds.Request, *cmds.Command, error) {
-	Root.Subcommands = localCommands
-	for k, v := range commands.Root.Subcommands {
-		if _, found := Root.Subcommands[k]; !found {
-			Root.Subcommands[k] = v
-		}
-	}
 	req, cmd, _, err := cli.Parse(args, nil, Root)
 	cctx := context.Background()
 	rerr := req.SetRootContext(cctx)
@@ -50,12 +45,6 @@ func NewRequest(ctx cmds.Context, args []string) (cmds.Request, *cmds.Command, e
 }
 
 func NewRequestWithTimeout(ctx cmds.Context, args []string, timeout time.Duration) (cmds.Request, *cmds.Command, error) {
-	Root.Subcommands = localCommands
-	for k, v := range commands.Root.Subcommands {
-		if _, found := Root.Subcommands[k]; !found {
-			Root.Subcommands[k] = v
-		}
-	}
 	req, cmd, _, err := cli.Parse(args, nil, Root)
 	cctx, _ := context.WithTimeout(context.Background(), timeout)
 	rerr := req.SetRootContext(cctx)
@@ -0,0 +1,46 @@
+package net
+
+import (
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/pb"
+	prometheus "gx/ipfs/QmX3QZ5jHEPidwUrymXV1iSCSUhdGxj15sm2gP4jKMef7B/client_golang/prometheus"
+)
+
+// Ways a message can be handed to a peer
+const (
+	AttemptDirect  = "direct"
+	AttemptRetry   = "retry"
+	AttemptOffline = "offline"
+)
+
+var (
+	sendAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "openbazaar",
+		Subsystem: "net",
+		Name:      "message_send_attempts_total",
+		Help:      "Attempts at delivering a message by message type and attempt kind",
+	}, []string{"type", "attempt"})
+
+	resendInterval = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "openbazaar",
+		Subsystem: "net",
+		Name:      "message_resend_interval_seconds",
+		Help:      "Time between an attempt at delivering a message and the previous attempt at the same message",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"type"})
+)
+
+func init() {
+	prometheus.MustRegister(sendAttempts, resendInterval)
+}
+
+// RecordSendAttempt counts an attempt at delivering a message. previous is
+// the time of the last attempt at the same message and is zero for the first
+// one.
+func RecordSendAttempt(t pb.Message_MessageType, attempt string, previous time.Time) {
+	sendAttempts.WithLabelValues(t.String(), attempt).Inc()
+	if !previous.IsZero() {
+		resendInterval.WithLabelValues(t.String()).Observe(time.Since(previous).Seconds())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/OpenBazaar/openbazaar-go/net"
 	"github.com/OpenBazaar/openbazaar-go/pb"
 )
 
@@ -102,6 +103,8 @@ func (ms *messageSender) SendMessage(ctx context.Context, pmes *pb.Message) erro
 }
 
 func (ms *messageSender) writeMessage(pmes *pb.Message) error {
+	sent := time.Now()
+	net.RecordSendAttempt(pmes.MessageType, net.AttemptDirect, time.Time{})
 	err := ms.w.WriteMsg(pmes)
 	if err != nil {
 		// If the other side isnt expecting us to be reusing streams, we're gonna
@@ -115,6 +118,7 @@ func (ms *messageSender) writeMessage(pmes *pb.Message) error {
 			return err
 		}
 
+		net.RecordSendAttempt(pmes.MessageType, net.AttemptRetry, sent)
 		if err := ms.w.WriteMsg(pmes); err != nil {
 			return err
 		}
@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"io/ioutil"
 	"net/http"
+	"net/http/pprof"
 	"net/url"
 	"strings"
 
@@ -32,6 +33,7 @@ import (
 	"github.com/OpenBazaar/openbazaar-go/bitcoin/bitcoind"
 	"github.com/OpenBazaar/openbazaar-go/bitcoin/exchange"
 	lis "github.com/OpenBazaar/openbazaar-go/bitcoin/listeners"
+	"github.com/OpenBazaar/openbazaar-go/bitcoin/mockwallet"
 	"github.com/OpenBazaar/openbazaar-go/core"
 	"github.com/OpenBazaar/openbazaar-go/ipfs"
 	obnet "github.com/OpenBazaar/openbazaar-go/net"
@@ -122,7 +124,10 @@ type Start struct {
 	DualStack            bool     `long:"dualstack" description:"Automatically configure the daemon to run as a Tor hidden service IN ADDITION to using the clear internet. Requires Tor to be running. WARNING: this mode is not private"`
 	DisableWallet        bool     `long:"disablewallet" description:"disable the wallet functionality of the node"`
 	DisableExchangeRates bool     `long:"disableexchangerates" description:"disable the exchange rate service to prevent api queries"`
+	DisableMarketplace   bool     `long:"disablemarketplace" description:"run the wallet and IPFS only, without the marketplace protocol, message retriever and pointer republisher"`
 	Storage              string   `long:"storage" description:"set the outgoing message storage option [self-hosted, dropbox] default=self-hosted"`
+	Profile              bool     `long:"profile" description:"serve Go runtime profiles under /debug/pprof on the gateway, behind the API's authentication"`
+	Metrics              bool     `long:"metrics" description:"serve Prometheus metrics under /debug/metrics/prometheus on the gateway, behind the API's authentication"`
 }
 type Opts struct {
 	Version bool `short:"v" long:"version" description:"Print the version number and exit"`
@@ -392,6 +397,10 @@ func (x *Start) Execute(args []string) error {
 		return errors.New("Invalid combination of tor and dual stack modes")
 	}
 
+	if x.DisableMarketplace && x.DisableWallet {
+		return errors.New("Invalid combination of disabled marketplace and wallet")
+	}
+
 	isTestnet := false
 	if x.Testnet || x.Regtest {
 		isTestnet = true
@@ -678,6 +687,9 @@ func (x *Start) Execute(args []string) error {
 
 	log.Info("Peer ID: ", nd.Identity.Pretty())
 	printSwarmAddrs(nd)
+	if features := core.Features(); len(features) > 0 {
+		log.Info("Experimental features: ", strings.Join(features, ", "))
+	}
 
 	// Get current directory root hash
 	_, ipnskey := namesys.IpnsKeysForID(nd.Identity)
@@ -765,6 +777,14 @@ func (x *Start) Execute(args []string) error {
 			usetor = true
 		}
 		wallet = bitcoind.NewBitcoindWallet(mn, &params, repoPath, walletCfg.TrustedPeer, walletCfg.Binary, walletCfg.RPCUser, walletCfg.RPCPassword, usetor, controlPort)
+	case "mock":
+		if params.Name == chaincfg.MainNetParams.Name {
+			return errors.New("The mock wallet cannot be used on mainnet")
+		}
+		if walletCfg.TrustedPeer == "" {
+			return errors.New("The address of the ledger must be set as the trusted peer when using the mock wallet")
+		}
+		wallet = mockwallet.NewMockWallet(mn, &params, walletCfg.TrustedPeer)
 	default:
 		log.Fatal("Unknown wallet type")
 	}
@@ -789,12 +809,21 @@ func (x *Start) Execute(args []string) error {
 		return err
 	}
 	addr, err := gatewayMaddr.ValueForProtocol(ma.P_IP4)
+	if err != nil {
+		addr, err = gatewayMaddr.ValueForProtocol(ma.P_IP6)
+	}
+	if err != nil {
+		// A unix socket is only reachable from this host
+		if _, uerr := gatewayMaddr.ValueForProtocol(ma.P_UNIX); uerr == nil {
+			addr, err = "127.0.0.1", nil
+		}
+	}
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 	// Override config file preference if this is Mainnet, open internet and API enabled
-	if addr != "127.0.0.1" && wallet.Params().Name == chaincfg.MainNetParams.Name && apiConfig.Enabled {
+	if addr != "127.0.0.1" && addr != "::1" && wallet.Params().Name == chaincfg.MainNetParams.Name && apiConfig.Enabled {
 		apiConfig.Authenticated = true
 	}
 	for _, ip := range x.AllowIP {
@@ -910,22 +939,29 @@ func (x *Start) Execute(args []string) error {
 		return errors.New("SSL cert and key files must be set when SSL is enabled")
 	}
 
-	gateway, err := newHTTPGateway(core.Node, authCookie, *apiConfig)
+	gateway, err := newHTTPGateway(core.Node, authCookie, *apiConfig, x.Profile, x.Metrics)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 
 	go func() {
-		core.Node.Service = service.New(core.Node, ctx, sqliteDB)
-		MR := ret.NewMessageRetriever(sqliteDB, ctx, nd, bm, core.Node.Service, 14, torDialer, core.Node.CrosspostGateways, core.Node.SendOfflineAck)
-		go MR.Run()
-		core.Node.MessageRetriever = MR
-		PR := rep.NewPointerRepublisher(nd, sqliteDB, core.Node.IsModerator)
-		go PR.Run()
-		core.Node.PointerRepublisher = PR
+		var MR *ret.MessageRetriever
+		if !x.DisableMarketplace {
+			core.Node.Service = service.New(core.Node, ctx, sqliteDB)
+			MR = ret.NewMessageRetriever(sqliteDB, ctx, nd, bm, core.Node.Service, 14, torDialer, core.Node.CrosspostGateways, core.Node.SendOfflineAck)
+			go MR.Run()
+			core.Node.MessageRetriever = MR
+			PR := rep.NewPointerRepublisher(nd, sqliteDB, core.Node.IsModerator)
+			go PR.Run()
+			core.Node.PointerRepublisher = PR
+		} else {
+			log.Notice("Marketplace disabled, running the wallet only")
+		}
 		if !x.DisableWallet {
-			MR.Wait()
+			if MR != nil {
+				MR.Wait()
+			}
 			TL := lis.NewTransactionListener(core.Node.Datastore, core.Node.Broadcast, core.Node.Wallet)
 			WL := lis.NewWalletListener(core.Node.Datastore, core.Node.Broadcast)
 			wallet.AddTransactionListener(TL.OnTransactionReceived)
@@ -935,8 +971,10 @@ func (x *Start) Execute(args []string) error {
 			go su.Start()
 			go wallet.Start()
 		}
-		core.Node.UpdateFollow()
-		core.Node.SeedNode()
+		if !x.DisableMarketplace {
+			core.Node.UpdateFollow()
+			core.Node.SeedNode()
+		}
 	}()
 
 	// Start gateway
@@ -994,7 +1032,7 @@ func (d *DummyListener) Close() error {
 }
 
 // Collects options, creates listener, prints status message and starts serving requests
-func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config repo.APIConfig) (*api.Gateway, error) {
+func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config repo.APIConfig, profile, metrics bool) (*api.Gateway, error) {
 	// Get API configuration
 	cfg, err := node.Context.GetConfig()
 	if err != nil {
@@ -1006,26 +1044,44 @@ func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config re
 	if err != nil {
 		return nil, fmt.Errorf("newHTTPGateway: invalid gateway address: %q (err: %s)", cfg.Addresses.Gateway, err)
 	}
-	var gwLis manet.Listener
-	if config.SSL {
-		netAddr, err := manet.ToNetAddr(gatewayMaddr)
-		if err != nil {
-			return nil, err
+	var gwLis net.Listener
+	if socketPath, err := gatewayMaddr.ValueForProtocol(ma.P_UNIX); err == nil {
+		// manet cannot listen on unix sockets so serve the API on a plain one
+		if config.SSL {
+			return nil, errors.New("newHTTPGateway: SSL is not supported on a unix socket gateway")
 		}
-		gwLis, err = manet.WrapNetListener(&DummyListener{netAddr})
+		// Clear the socket a previous run left behind
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("newHTTPGateway: removing stale unix socket %s failed: %s", socketPath, err)
+		}
+		gwLis, err = net.Listen("unix", socketPath)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("newHTTPGateway: listening on unix socket %s failed: %s", socketPath, err)
 		}
+		log.Infof("Gateway/API server listening on %s\n", gatewayMaddr)
 	} else {
-		gwLis, err = manet.Listen(gatewayMaddr)
-		if err != nil {
-			return nil, fmt.Errorf("newHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
+		var maLis manet.Listener
+		if config.SSL {
+			netAddr, err := manet.ToNetAddr(gatewayMaddr)
+			if err != nil {
+				return nil, err
+			}
+			maLis, err = manet.WrapNetListener(&DummyListener{netAddr})
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			maLis, err = manet.Listen(gatewayMaddr)
+			if err != nil {
+				return nil, fmt.Errorf("newHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
+			}
 		}
-	}
 
-	// We might have listened to /tcp/0 - let's see what we are listing on
-	gatewayMaddr = gwLis.Multiaddr()
-	log.Infof("Gateway/API server listening on %s\n", gatewayMaddr)
+		// We might have listened to /tcp/0 - let's see what we are listing on
+		gatewayMaddr = maLis.Multiaddr()
+		log.Infof("Gateway/API server listening on %s\n", gatewayMaddr)
+		gwLis = maLis.NetListener()
+	}
 
 	// Setup an options slice
 	var opts = []corehttp.ServeOption{
@@ -1033,6 +1089,7 @@ func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config re
 		corehttp.CommandsROOption(node.Context),
 		corehttp.VersionOption(),
 		corehttp.IPNSHostnameOption(),
+		api.BlocklistOption(path.Join(node.RepoPath, api.BlocklistFile)),
 		corehttp.GatewayOption(node.Resolver, config.Authenticated, config.AllowedIPs, authCookie, config.Username, config.Password, cfg.Gateway.Writable, "/ipfs", "/ipns"),
 	}
 
@@ -1040,6 +1097,10 @@ func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config re
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
 
+	if profile || metrics {
+		opts = append(opts, debugOption(authCookie, config, profile, metrics))
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("newHTTPGateway: ConstructNode() failed: %s", err)
 	}
@@ -1055,7 +1116,29 @@ func newHTTPGateway(node *core.OpenBazaarNode, authCookie http.Cookie, config re
 	apiFileFormatter := logging.NewBackendFormatter(apiFile, fileLogFormat)
 	ml := logging.MultiLogger(apiFileFormatter)
 
-	return api.NewGateway(node, authCookie, gwLis.NetListener(), config, ml, opts...)
+	return api.NewGateway(node, authCookie, gwLis, config, ml, opts...)
+}
+
+// debugOption serves the net/http/pprof handlers and the Prometheus metrics
+// under /debug, when asked for, behind the same authentication as the API
+func debugOption(authCookie http.Cookie, config repo.APIConfig, profile, metrics bool) corehttp.ServeOption {
+	return func(n *ipfscore.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		debugMux := http.NewServeMux()
+		if profile {
+			debugMux.HandleFunc("/debug/pprof/", pprof.Index)
+			debugMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+			debugMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+			debugMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+			debugMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		}
+		if metrics {
+			if _, err := corehttp.MetricsScrapingOption("/debug/metrics/prometheus")(n, l, debugMux); err != nil {
+				return nil, err
+			}
+		}
+		mux.Handle("/debug/", api.Authenticated(debugMux, authCookie, config))
+		return mux, nil
+	}
 }
 
 /* Returns the directory to store repo data in.
@@ -14,6 +14,10 @@ import (
 
 var log = logging.MustGetLogger("db")
 
+// Driver is the database/sql driver used to open the node database. Tests may
+// point it at a wrapping driver to inject faults into the SQLite layer.
+var Driver = "sqlite3"
+
 type SQLiteDatastore struct {
 	config          repo.Config
 	followers       repo.Followers
@@ -46,7 +50,7 @@ func Create(repoPath, password string, testnet bool) (*SQLiteDatastore, error) {
 	} else {
 		dbPath = path.Join(repoPath, "datastore", "mainnet.db")
 	}
-	conn, err := sql.Open("sqlite3", dbPath)
+	conn, err := sql.Open(Driver, dbPath)
 	if err != nil {
 		return nil, err
 	}
@@ -380,6 +384,15 @@ func (c *ConfigDB) GetIdentityKey() ([]byte, error) {
 	return identityKey, nil
 }
 
+// SetIdentityKey replaces the identity key. The node gets a new peer ID the
+// next time it starts.
+func (c *ConfigDB) SetIdentityKey(identityKey []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("update config set value=? where key=?", identityKey, "identityKey")
+	return err
+}
+
 func (c *ConfigDB) GetCreationDate() (time.Time, error) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
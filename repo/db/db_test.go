@@ -52,6 +52,22 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestSetIdentityKey(t *testing.T) {
+	config := testDB.config.(*ConfigDB)
+	err := config.SetIdentityKey([]byte("New Key"))
+	if err != nil {
+		t.Error(err)
+	}
+	defer config.SetIdentityKey([]byte("Private Key"))
+	pk, err := testDB.config.GetIdentityKey()
+	if err != nil {
+		t.Error(err)
+	}
+	if string(pk) != "New Key" {
+		t.Error("Config returned wrong identity key")
+	}
+}
+
 func TestInterface(t *testing.T) {
 	if testDB.Config() != testDB.config {
 		t.Error("Config() return wrong value")
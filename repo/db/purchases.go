@@ -32,16 +32,11 @@ func (p *PurchasesDB) Put(orderID string, contract pb.RicardianContract, state p
 		OrigName:     false,
 	}
 	out, err := m.MarshalToString(&contract)
-
-	tx, err := p.db.Begin()
 	if err != nil {
 		return err
 	}
+
 	stm := `insert or replace into purchases(orderID, contract, state, read, timestamp, total, thumbnail, vendorID, vendorBlockchainID, title, shippingName, shippingAddress, paymentAddr, funded, transactions) values(?,?,?,?,?,?,?,?,?,?,?,?,?,(select funded from purchases where orderID="` + orderID + `"),(select transactions from purchases where orderID="` + orderID + `"))`
-	stmt, err := tx.Prepare(stm)
-	if err != nil {
-		return err
-	}
 	blockchainID := contract.VendorListings[0].VendorID.BlockchainID
 	shippingName := ""
 	shippingAddress := ""
@@ -55,8 +50,7 @@ func (p *PurchasesDB) Put(orderID string, contract pb.RicardianContract, state p
 	} else if contract.BuyerOrder.Payment.Method == pb.Order_Payment_ADDRESS_REQUEST {
 		paymentAddr = contract.VendorOrderConfirmation.PaymentAddress
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(
+	return execTx(p.db, stm,
 		orderID,
 		out,
 		int(state),
@@ -71,12 +65,6 @@ func (p *PurchasesDB) Put(orderID string, contract pb.RicardianContract, state p
 		shippingAddress,
 		paymentAddr,
 	)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	tx.Commit()
-	return nil
 }
 
 func (p *PurchasesDB) MarkAsRead(orderID string) error {
@@ -111,11 +99,10 @@ func (p *PurchasesDB) UpdateFunding(orderId string, funded bool, records []*spvw
 	if err != nil {
 		return err
 	}
-	_, err = p.db.Exec("update purchases set funded=?, transactions=? where orderID=?", fundedInt, string(serializedTransactions), orderId)
-	if err != nil {
+	return retryBusy(func() error {
+		_, err := p.db.Exec("update purchases set funded=?, transactions=? where orderID=?", fundedInt, string(serializedTransactions), orderId)
 		return err
-	}
-	return nil
+	})
 }
 
 func (p *PurchasesDB) Delete(orderID string) error {
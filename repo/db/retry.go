@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	sqlite3 "github.com/mutecomm/go-sqlcipher"
+)
+
+// busyRetries bounds how many times a write is tried again while the
+// database is busy or locked, waiting twice as long each time
+const busyRetries = 6
+
+const busyBackoff = 10 * time.Millisecond
+
+// retryBusy runs fn again while it fails because the database is busy or
+// locked, so an order update contending for the database is not dropped
+func retryBusy(fn func() error) error {
+	err := fn()
+	for i := uint(0); i < busyRetries && isBusy(err); i++ {
+		time.Sleep(busyBackoff << i)
+		err = fn()
+	}
+	return err
+}
+
+// execTx runs stm with args in a transaction of its own, retried as a whole
+// while the database is busy or locked
+func execTx(db *sql.DB, stm string, args ...interface{}) error {
+	return retryBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(stm)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		defer stmt.Close()
+		if _, err := stmt.Exec(args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+func isBusy(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
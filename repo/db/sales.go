@@ -32,16 +32,11 @@ func (s *SalesDB) Put(orderID string, contract pb.RicardianContract, state pb.Or
 		OrigName:     false,
 	}
 	out, err := m.MarshalToString(&contract)
-
-	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+
 	stm := `insert or replace into sales(orderID, contract, state, read, timestamp, total, thumbnail, buyerID, buyerBlockchainID, title, shippingName, shippingAddress, paymentAddr, funded, transactions) values(?,?,?,?,?,?,?,?,?,?,?,?,?,(select funded from sales where orderID="` + orderID + `"),(select transactions from sales where orderID="` + orderID + `"))`
-	stmt, err := tx.Prepare(stm)
-	if err != nil {
-		return err
-	}
 
 	blockchainID := contract.BuyerOrder.BuyerID.BlockchainID
 	shippingName := ""
@@ -56,8 +51,7 @@ func (s *SalesDB) Put(orderID string, contract pb.RicardianContract, state pb.Or
 	} else if contract.BuyerOrder.Payment.Method == pb.Order_Payment_ADDRESS_REQUEST {
 		address = contract.VendorOrderConfirmation.PaymentAddress
 	}
-	defer stmt.Close()
-	_, err = stmt.Exec(
+	return execTx(s.db, stm,
 		orderID,
 		out,
 		int(state),
@@ -72,12 +66,6 @@ func (s *SalesDB) Put(orderID string, contract pb.RicardianContract, state pb.Or
 		shippingAddress,
 		address,
 	)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	tx.Commit()
-	return nil
 }
 
 func (s *SalesDB) MarkAsRead(orderID string) error {
@@ -112,11 +100,10 @@ func (s *SalesDB) UpdateFunding(orderId string, funded bool, records []*spvwalle
 	if err != nil {
 		return err
 	}
-	_, err = s.db.Exec("update sales set funded=?, transactions=? where orderID=?", fundedInt, string(serializedTransactions), orderId)
-	if err != nil {
+	return retryBusy(func() error {
+		_, err := s.db.Exec("update sales set funded=?, transactions=? where orderID=?", fundedInt, string(serializedTransactions), orderId)
 		return err
-	}
-	return nil
+	})
 }
 
 func (s *SalesDB) Delete(orderID string) error {
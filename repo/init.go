@@ -70,7 +70,7 @@ func DoInit(repoRoot string, nBitsForKeypair int, testnet bool, password string,
 		return err
 	}
 
-	return initializeIpnsKeyspace(repoRoot, identityKey)
+	return InitializeIpnsKeyspace(repoRoot, identityKey)
 }
 
 func maybeCreateOBDirectories(repoRoot string) error {
@@ -147,7 +147,9 @@ func checkWriteable(dir string) error {
 	return err
 }
 
-func initializeIpnsKeyspace(repoRoot string, privKeyBytes []byte) error {
+// InitializeIpnsKeyspace publishes an empty root for the identity key into the
+// repo's datastore so the node can start with that identity
+func InitializeIpnsKeyspace(repoRoot string, privKeyBytes []byte) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
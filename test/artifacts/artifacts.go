@@ -0,0 +1,146 @@
+// Package artifacts stores what runs leave behind, reports, manifests,
+// failures, logs and profiles, in a local directory or an object store, so
+// runs on many CI runners can gather their results in one place
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Store keeps artifacts by name, a slash separated path such as
+// <run>/report.html
+type Store interface {
+	// Put stores what r reads under name, replacing any artifact of that
+	// name, and returns where it can be found
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+}
+
+// Open returns the store at location:
+//
+//	/some/dir, file:///some/dir  a local directory
+//	s3://bucket/prefix           an S3 bucket, see S3Options
+//	gs://bucket/prefix           a Google Cloud Storage bucket, see S3Options
+//
+// Object stores take ?endpoint= and ?region= to reach S3 compatible
+// servers, e.g. s3://results?endpoint=http://127.0.0.1:9000 for minio.
+func Open(location string) (Store, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Plain paths, Windows drive letters included
+		return Dir(location), nil
+	}
+	switch u.Scheme {
+	case "file":
+		return Dir(u.Path), nil
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("artifacts: %s names no bucket", location)
+		}
+		opts := S3Options{
+			Bucket:   u.Host,
+			Prefix:   strings.Trim(u.Path, "/"),
+			Endpoint: u.Query().Get("endpoint"),
+			Region:   u.Query().Get("region"),
+		}
+		if u.Scheme == "gs" {
+			opts = opts.gcs()
+		}
+		return NewS3(opts.fromEnv(u.Scheme))
+	}
+	return nil, fmt.Errorf("artifacts: unknown store %s, expected a directory, s3:// or gs://", location)
+}
+
+// Dir is a local directory of artifacts
+type Dir string
+
+// Put writes the artifact to a file under the directory, creating its
+// parents
+func (d Dir) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	p, err := d.path(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	return p, f.Close()
+}
+
+func (d Dir) path(name string) (string, error) {
+	clean, err := clean(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(string(d), filepath.FromSlash(clean)), nil
+}
+
+// clean refuses names escaping the store
+func clean(name string) (string, error) {
+	c := path.Clean(strings.TrimPrefix(name, "/"))
+	if c == "." || c == ".." || strings.HasPrefix(c, "../") {
+		return "", fmt.Errorf("artifacts: bad name %q", name)
+	}
+	return c, nil
+}
+
+// PutFile stores the file at p under name
+func PutFile(ctx context.Context, s Store, name, p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return s.Put(ctx, name, f)
+}
+
+// PutDir stores every file under dir under prefix, keeping their relative
+// paths, and returns where each went
+func PutDir(ctx context.Context, s Store, prefix, dir string) ([]string, error) {
+	var stored []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		loc, err := PutFile(ctx, s, path.Join(prefix, filepath.ToSlash(rel)), p)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, loc)
+		return nil
+	})
+	return stored, err
+}
+
+// Under returns the store keeping artifacts of s under prefix, e.g. the ID
+// of a run
+func Under(s Store, prefix string) Store {
+	return under{s, prefix}
+}
+
+type under struct {
+	Store
+	prefix string
+}
+
+func (u under) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	return u.Store.Put(ctx, path.Join(u.prefix, name), r)
+}
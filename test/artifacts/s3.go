@@ -0,0 +1,188 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options configures a store in an S3 compatible bucket: AWS S3, minio,
+// or Google Cloud Storage through its XML API with HMAC keys
+type S3Options struct {
+	Bucket string
+
+	// Prefix is prepended to the names of artifacts, none when empty
+	Prefix string
+
+	// Endpoint is the server, https://s3.<Region>.amazonaws.com when
+	// empty. Buckets are addressed by path, which every S3 compatible
+	// server takes.
+	Endpoint string
+
+	// Region signs the requests, us-east-1 when empty
+	Region string
+
+	// AccessKey and SecretKey sign the requests, SessionToken is set with
+	// temporary credentials. Open reads them from AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN for s3://, and from
+	// GCS_ACCESS_KEY_ID and GCS_SECRET_ACCESS_KEY for gs://.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// Client sends the requests, one timing out after 5 minutes when nil
+	Client *http.Client
+}
+
+// gcs points the options at Google Cloud Storage
+func (o S3Options) gcs() S3Options {
+	if o.Endpoint == "" {
+		o.Endpoint = "https://storage.googleapis.com"
+	}
+	if o.Region == "" {
+		o.Region = "auto"
+	}
+	return o
+}
+
+func (o S3Options) fromEnv(scheme string) S3Options {
+	if scheme == "gs" {
+		o.AccessKey = os.Getenv("GCS_ACCESS_KEY_ID")
+		o.SecretKey = os.Getenv("GCS_SECRET_ACCESS_KEY")
+		return o
+	}
+	o.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	o.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	o.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	if o.Region == "" {
+		o.Region = os.Getenv("AWS_REGION")
+	}
+	return o
+}
+
+// S3 is a bucket of artifacts
+type S3 struct {
+	opts     S3Options
+	endpoint *url.URL
+
+	// now dates the signatures
+	now func() time.Time
+}
+
+// NewS3 returns the store in the bucket
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("artifacts: no bucket")
+	}
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, fmt.Errorf("artifacts: no credentials for bucket %s", opts.Bucket)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("artifacts: bad endpoint %q", opts.Endpoint)
+	}
+	return &S3{opts: opts, endpoint: u, now: time.Now}, nil
+}
+
+// Put uploads the artifact as an object, reading it whole to sign it
+func (s *S3) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	clean, err := clean(name)
+	if err != nil {
+		return "", err
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	key := path.Join(s.opts.Prefix, clean)
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.opts.Bucket, key)
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(len(body))
+	s.sign(req, body)
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("artifacts: uploading %s: %s", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("artifacts: uploading %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return u.String(), nil
+}
+
+// sign signs the request with AWS signature version 4
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if s.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signed := strings.Join(names, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := day + "/" + s.opts.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + s.opts.SecretKey)
+	for _, part := range []string{day, s.opts.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	src, err := ioutil.TempDir("", "backup-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	files := map[string]string{
+		"config":                 `{"Identity": {}}`,
+		"datastore/mainnet.db":   "sqlite",
+		"root/listings/tee.json": "{}",
+		"repo.lock":              "",
+		"logs/ob.log":            "log line",
+	}
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var archive bytes.Buffer
+	if err := Create(src, &archive); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := ioutil.TempDir("", "backup-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if err := Restore(&archive, dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dst, name))
+		if name == "repo.lock" || name == "logs/ob.log" {
+			if err == nil {
+				t.Errorf("Expected %s to be left out", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s was not restored: %s", name, err)
+		} else if string(b) != content {
+			t.Errorf("%s restored as %q, expected %q", name, b, content)
+		}
+	}
+}
+
+func TestRestoreRejectsEscapingPaths(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0600, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+	gz.Close()
+
+	dst, err := ioutil.TempDir("", "backup-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if err := Restore(&archive, dst); err == nil {
+		t.Error("Expected an error for a path outside the restore directory")
+	}
+}
@@ -0,0 +1,105 @@
+// Package bench measures latency distributions of harness flows and compares
+// them against service level objectives
+package bench
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary is the latency distribution of a benchmark
+type Summary struct {
+	Name     string
+	Runs     int
+	Failures int
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%s: %d runs, %d failed, p50 %s p95 %s p99 %s max %s", s.Name, s.Runs, s.Failures,
+		s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+}
+
+// Measure calls fn runs times and summarizes how long the successful calls
+// took. progress, when not nil, is called after every run.
+func Measure(name string, runs int, fn func() error, progress func(run int, d time.Duration, err error)) Summary {
+	var durations []time.Duration
+	failures := 0
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		err := fn()
+		d := time.Since(start)
+		if err != nil {
+			failures++
+		} else {
+			durations = append(durations, d)
+		}
+		if progress != nil {
+			progress(i+1, d, err)
+		}
+	}
+	return Summarize(name, durations, failures)
+}
+
+// Summarize computes the percentiles of the given durations
+func Summarize(name string, durations []time.Duration, failures int) Summary {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s := Summary{
+		Name:     name,
+		Runs:     len(durations) + failures,
+		Failures: failures,
+		P50:      Percentile(sorted, 50),
+		P95:      Percentile(sorted, 95),
+		P99:      Percentile(sorted, 99),
+	}
+	if len(sorted) > 0 {
+		s.Max = sorted[len(sorted)-1]
+	}
+	return s
+}
+
+// Percentile returns the nearest rank percentile of sorted durations
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// Check returns an error listing every threshold of the objective the
+// summary exceeds
+func (o SLO) Check(s Summary) error {
+	var errs []string
+	check := func(name string, got, limit time.Duration) {
+		if limit > 0 && got > limit {
+			errs = append(errs, fmt.Sprintf("%s %s exceeds %s", name, got.Round(time.Millisecond), limit))
+		}
+	}
+	check("p50", s.P50, o.P50.Duration)
+	check("p95", s.P95, o.P95.Duration)
+	check("p99", s.P99, o.P99.Duration)
+	if s.Runs > 0 {
+		rate := float64(s.Failures) / float64(s.Runs)
+		if rate > o.MaxFailureRate {
+			errs = append(errs, fmt.Sprintf("failure rate %.2f exceeds %.2f", rate, o.MaxFailureRate))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s regressed: %s", s.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
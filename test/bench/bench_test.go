@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 100; i++ {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	s := Summarize("checkout", durations, 0)
+	if s.P50 != 50*time.Second || s.P95 != 95*time.Second || s.P99 != 99*time.Second || s.Max != 100*time.Second {
+		t.Errorf("Unexpected summary %s", s)
+	}
+	if Percentile(nil, 50) != 0 {
+		t.Error("Expected zero percentile of no durations")
+	}
+	if Percentile([]time.Duration{time.Second}, 99) != time.Second {
+		t.Error("Expected the only duration for any percentile")
+	}
+}
+
+func TestMeasureCountsFailures(t *testing.T) {
+	n := 0
+	s := Measure("checkout", 4, func() error {
+		n++
+		if n%2 == 0 {
+			return errors.New("failed")
+		}
+		return nil
+	}, nil)
+	if s.Runs != 4 || s.Failures != 2 {
+		t.Errorf("Expected 4 runs with 2 failures, got %d with %d", s.Runs, s.Failures)
+	}
+}
+
+func TestSLOCheck(t *testing.T) {
+	var o SLO
+	if err := json.Unmarshal([]byte(`{"p50": "1s", "p95": "2s", "maxFailureRate": 0.1}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	ok := Summary{Name: "checkout", Runs: 10, Failures: 1, P50: time.Second, P95: 2 * time.Second, P99: time.Minute}
+	if err := o.Check(ok); err != nil {
+		t.Error(err)
+	}
+	slow := ok
+	slow.P95 = 3 * time.Second
+	if err := o.Check(slow); err == nil {
+		t.Error("Expected p95 regression")
+	}
+	flaky := ok
+	flaky.Failures = 2
+	if err := o.Check(flaky); err == nil {
+		t.Error("Expected failure rate regression")
+	}
+}
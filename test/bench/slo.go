@@ -0,0 +1,74 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Duration is a time.Duration written as a string such as "12s" in JSON
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// SLO is the latency objective a benchmark must meet. Zero thresholds are
+// not checked.
+type SLO struct {
+	P50            Duration `json:"p50"`
+	P95            Duration `json:"p95"`
+	P99            Duration `json:"p99"`
+	MaxFailureRate float64  `json:"maxFailureRate"`
+}
+
+// SLOs are the committed objectives, measured on a clean regtest network of
+// freshly started nodes. Raise them only together with the change that
+// makes the flow slower on purpose.
+var SLOs = map[string]SLO{
+	"checkout": {
+		P50:            Duration{10 * time.Second},
+		P95:            Duration{30 * time.Second},
+		P99:            Duration{60 * time.Second},
+		MaxFailureRate: 0.01,
+	},
+}
+
+// LoadSLOs reads objectives from a JSON file mapping benchmark names to
+// SLOs, falling back to the committed ones for benchmarks it does not list
+func LoadSLOs(path string) (map[string]SLO, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]SLO)
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	ret := make(map[string]SLO)
+	for name, o := range SLOs {
+		ret[name] = o
+	}
+	for name, o := range loaded {
+		ret[name] = o
+	}
+	return ret, nil
+}
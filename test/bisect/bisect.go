@@ -0,0 +1,105 @@
+// Package bisect finds the commit that broke a scenario by binary search
+// over the commits between one it passes at and one it fails at
+package bisect
+
+import "fmt"
+
+// Verdict is what testing a commit tells
+type Verdict int
+
+const (
+	// Good is a commit the scenario passes at
+	Good Verdict = iota
+
+	// Bad is a commit the scenario fails at
+	Bad
+
+	// Skip is a commit that cannot be tested, e.g. one that does not build
+	Skip
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Good:
+		return "good"
+	case Bad:
+		return "bad"
+	case Skip:
+		return "skip"
+	}
+	return fmt.Sprintf("Verdict(%d)", int(v))
+}
+
+// Step is a commit tested during a search
+type Step struct {
+	Commit  string
+	Verdict Verdict
+}
+
+// Result is where a search ended
+type Result struct {
+	// First is the first bad commit, empty when skipped commits leave more
+	// than one candidate
+	First string
+
+	// Candidates are the commits the first bad one is among, oldest first,
+	// just First when it was found
+	Candidates []string
+
+	// Steps are the commits tested, in order
+	Steps []Step
+}
+
+// Search finds the first bad commit among commits, oldest first, which
+// follow a good commit and end with a bad one. test is called on a commit
+// at most once and not on the last.
+func Search(commits []string, test func(commit string) (Verdict, error)) (*Result, error) {
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("bisect: no commits")
+	}
+	r := new(Result)
+	// good is the last commit known good, -1 for the one before commits,
+	// and bad the first known bad
+	good, bad := -1, len(commits)-1
+	skipped := make(map[int]bool)
+	for {
+		next := pick(good, bad, skipped)
+		if next < 0 {
+			break
+		}
+		v, err := test(commits[next])
+		if err != nil {
+			return r, fmt.Errorf("bisect: testing %s: %s", commits[next], err)
+		}
+		r.Steps = append(r.Steps, Step{commits[next], v})
+		switch v {
+		case Good:
+			good = next
+		case Bad:
+			bad = next
+		case Skip:
+			skipped[next] = true
+		default:
+			return r, fmt.Errorf("bisect: testing %s: %s", commits[next], v)
+		}
+	}
+	r.Candidates = commits[good+1 : bad+1]
+	if len(r.Candidates) == 1 {
+		r.First = r.Candidates[0]
+	}
+	return r, nil
+}
+
+// pick returns the untested commit between good and bad closest to the
+// middle, -1 when there is none
+func pick(good, bad int, skipped map[int]bool) int {
+	mid := good + (bad-good)/2
+	for d := 0; mid-d > good || mid+d < bad; d++ {
+		for _, i := range []int{mid - d, mid + d} {
+			if i > good && i < bad && !skipped[i] {
+				return i
+			}
+		}
+	}
+	return -1
+}
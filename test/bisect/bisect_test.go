@@ -0,0 +1,92 @@
+package bisect
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func commits(n int) []string {
+	var ret []string
+	for i := 0; i < n; i++ {
+		ret = append(ret, fmt.Sprintf("c%d", i))
+	}
+	return ret
+}
+
+// broken tests commits as bad from first on, skipping those in skip, and
+// fails on any commit tested twice
+func broken(t *testing.T, all []string, first int, skip ...int) func(string) (Verdict, error) {
+	tested := make(map[string]bool)
+	return func(c string) (Verdict, error) {
+		if tested[c] {
+			t.Errorf("%s tested twice", c)
+		}
+		tested[c] = true
+		if c == all[len(all)-1] {
+			t.Errorf("Expected the known bad commit not to be tested")
+		}
+		for i, cc := range all {
+			if cc != c {
+				continue
+			}
+			for _, s := range skip {
+				if s == i {
+					return Skip, nil
+				}
+			}
+			if i >= first {
+				return Bad, nil
+			}
+			return Good, nil
+		}
+		return 0, fmt.Errorf("no commit %s", c)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	all := commits(100)
+	for _, first := range []int{0, 1, 37, 98, 99} {
+		r, err := Search(all, broken(t, all, first))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.First != all[first] {
+			t.Errorf("Expected %s first bad, got %q of %v", all[first], r.First, r.Candidates)
+		}
+		if len(r.Steps) > 7 {
+			t.Errorf("Expected at most 7 steps for 100 commits, took %d", len(r.Steps))
+		}
+	}
+}
+
+func TestSearchSkips(t *testing.T) {
+	all := commits(20)
+	// The first bad commit is found around a skipped one
+	r, err := Search(all, broken(t, all, 10, 8, 11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.First != "c10" {
+		t.Errorf("Expected c10 first bad, got %q of %v", r.First, r.Candidates)
+	}
+
+	// Skips before it leave the candidates
+	r, err = Search(all, broken(t, all, 10, 8, 9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c8", "c9", "c10"}; r.First != "" || !reflect.DeepEqual(r.Candidates, want) {
+		t.Errorf("Expected candidates %v, got %q of %v", want, r.First, r.Candidates)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	_, err := Search(commits(10), func(string) (Verdict, error) {
+		return 0, errors.New("no network")
+	})
+	if err == nil {
+		t.Error("Expected a failing test to end the search")
+	}
+}
@@ -0,0 +1,214 @@
+// Package build builds openbazaard at commits of a checkout. Binaries are
+// cached by commit, so bisecting and comparing runs never build a commit
+// twice.
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// importPath is where the sources are put in the GOPATH of a build, so the
+// imports of the tree and its vendored packages resolve to them
+const importPath = "github.com/OpenBazaar/openbazaar-go"
+
+// Builder builds openbazaard from a git checkout
+type Builder struct {
+	// Repo is the checkout, the current directory when empty. Only its
+	// history is read: commits are built from a copy.
+	Repo string
+
+	// Cache is where binaries are kept by commit, openbazaar-testnodes/builds
+	// in the user's cache dir when empty
+	Cache string
+
+	// Go is the go command, go on the PATH when empty
+	Go string
+
+	// Log receives the output of the builds, which is dropped when nil
+	Log io.Writer
+}
+
+// Resolve returns the commit rev names, e.g. a tag or HEAD~3
+func (b *Builder) Resolve(rev string) (string, error) {
+	out, err := b.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("build: no commit %s", rev)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Commits returns the commits after good up to and including bad, oldest
+// first, following first parents so each one was once the tip of the
+// branch
+func (b *Builder) Commits(good, bad string) ([]string, error) {
+	g, err := b.Resolve(good)
+	if err != nil {
+		return nil, err
+	}
+	bd, err := b.Resolve(bad)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.git("merge-base", "--is-ancestor", g, bd); err != nil {
+		return nil, fmt.Errorf("build: %s is not an ancestor of %s", good, bad)
+	}
+	out, err := b.git("rev-list", "--first-parent", "--ancestry-path", "--reverse", g+".."+bd)
+	if err != nil {
+		return nil, err
+	}
+	commits := strings.Fields(string(out))
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("build: no commits between %s and %s", good, bad)
+	}
+	return commits, nil
+}
+
+// Build returns openbazaard built at rev, from the cache if it was built
+// before
+func (b *Builder) Build(ctx context.Context, rev string) (string, error) {
+	commit, err := b.Resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	cache := b.Cache
+	if cache == "" {
+		if cache, err = cacheDir(); err != nil {
+			return "", err
+		}
+	}
+	binary := filepath.Join(cache, commit, "openbazaard")
+	if _, err := os.Stat(binary); err == nil {
+		return binary, nil
+	}
+
+	gopath, err := ioutil.TempDir("", "testnodes-build")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(gopath)
+	src := filepath.Join(gopath, "src", filepath.FromSlash(importPath))
+	if err := b.checkout(ctx, commit, src); err != nil {
+		return "", fmt.Errorf("build: checking out %s: %s", commit, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return "", err
+	}
+	// Built next to where it is cached, so a failed build never leaves a
+	// binary there
+	tmp := filepath.Join(filepath.Dir(binary), ".openbazaard")
+	goCmd := b.Go
+	if goCmd == "" {
+		goCmd = "go"
+	}
+	cmd := exec.CommandContext(ctx, goCmd, "build", "-o", tmp, ".")
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), "GOPATH="+gopath, "GO111MODULE=off")
+	cmd.Stdout = b.Log
+	cmd.Stderr = b.Log
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("build: building %s: %s", commit, err)
+	}
+	if err := os.Rename(tmp, binary); err != nil {
+		return "", err
+	}
+	return binary, nil
+}
+
+// checkout writes the tree of commit to dir
+func (b *Builder) checkout(ctx context.Context, commit, dir string) error {
+	cmd := exec.CommandContext(ctx, "git", "archive", "--format=tar", commit)
+	cmd.Dir = b.Repo
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := untar(out, dir); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// untar writes the directories, files and symlinks of a tar stream to dir
+func untar(r io.Reader, dir string) error {
+	t := tar.NewReader(r)
+	for {
+		hdr, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(name, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("%s is outside the tree", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(name, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(name, t, os.FileMode(hdr.Mode)&0777)
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(name), 0755); err == nil {
+				err = os.Symlink(hdr.Linkname, name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeFile(name string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (b *Builder) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = b.Repo
+	return cmd.Output()
+}
+
+// cacheDir is openbazaar-testnodes/builds under $XDG_CACHE_HOME, or
+// ~/.cache
+func cacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "openbazaar-testnodes", "builds"), nil
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("no cache dir given and no $HOME")
+	}
+	return filepath.Join(home, ".cache", "openbazaar-testnodes", "builds"), nil
+}
@@ -0,0 +1,98 @@
+package build
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGo builds by copying version.txt of the tree to the output and
+// counting the builds in builds next to it, failing on trees without one
+const fakeGo = `#!/bin/sh
+echo built >> "$(dirname "$0")/builds"
+exec cp version.txt "$3"
+`
+
+// repo makes a checkout with a commit per version, the last without a
+// version.txt when broken is set, and returns the commits oldest first
+func repo(t *testing.T, dir string, versions []string, broken bool) []string {
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	var commits []string
+	for i, v := range versions {
+		if broken && i == len(versions)-1 {
+			git("rm", "-q", "version.txt")
+		} else {
+			if err := ioutil.WriteFile(filepath.Join(dir, "version.txt"), []byte(v), 0644); err != nil {
+				t.Fatal(err)
+			}
+			git("add", "version.txt")
+		}
+		git("commit", "-q", "-m", v)
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+	return commits
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnodes-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "repo")
+	os.Mkdir(src, 0755)
+	commits := repo(t, src, []string{"v1", "v2", "v3", "v4"}, true)
+	goCmd := filepath.Join(dir, "go")
+	if err := ioutil.WriteFile(goCmd, []byte(fakeGo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	b := &Builder{Repo: src, Cache: filepath.Join(dir, "cache"), Go: goCmd}
+
+	got, err := b.Commits(commits[0], "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != strings.Join(commits[1:], " ") {
+		t.Errorf("Expected the commits after the first oldest first, got %v", got)
+	}
+	if _, err := b.Commits(commits[2], commits[1]); err == nil {
+		t.Error("Expected a good commit after the bad one to be refused")
+	}
+
+	for i := 0; i < 2; i++ {
+		binary, err := b.Build(context.Background(), "HEAD~1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, "cache", commits[2], "openbazaard"); binary != want {
+			t.Errorf("Expected the binary cached at %s, got %s", want, binary)
+		}
+		if out, err := ioutil.ReadFile(binary); err != nil || string(out) != "v3" {
+			t.Errorf("Expected the build of v3, got %q, %v", out, err)
+		}
+	}
+	if builds, _ := ioutil.ReadFile(filepath.Join(dir, "builds")); string(builds) != "built\n" {
+		t.Errorf("Expected a single build, got %q", builds)
+	}
+
+	if _, err := b.Build(context.Background(), "HEAD"); err == nil {
+		t.Error("Expected the broken commit to fail to build")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache", commits[3], "openbazaard")); !os.IsNotExist(err) {
+		t.Errorf("Expected no binary cached for the broken commit, got %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+// Package chain runs a regtest bitcoind for the wallets of test nodes, so
+// purchase tests can fund, confirm and spend for real. It finds bitcoind on
+// the PATH or downloads a release, starts it on free ports with a throwaway
+// datadir and mines past coinbase maturity before handing it over. The
+// daemons of the other coins openbazaard has wallets for run the same way,
+// see Coins and StartMatrix.
+package chain
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+	"github.com/OpenBazaar/openbazaar-go/test/regtest"
+)
+
+// stopTimeout bounds how long the daemon may take to shut down before it is
+// killed
+const stopTimeout = 30 * time.Second
+
+// Options configures a started chain
+type Options struct {
+	// Coin is the coin whose daemon runs, Bitcoin when its Code is empty
+	Coin Coin
+
+	// Binary is the daemon to run. When empty the Daemon of the coin on the
+	// PATH is run, or for Bitcoin a release downloaded with Fetch if there
+	// is none.
+	Binary string
+
+	// Version is the release downloaded when there is no binary, Version
+	// when empty
+	Version string
+
+	// Cache is where downloaded releases are kept, see Fetch
+	Cache string
+
+	// Dir is the datadir, a temp dir removed by Close when empty
+	Dir string
+
+	// Host is the address the daemon listens on and wallets sync from,
+	// 127.0.0.1 when empty. Docker nodes reach the host at the gateway of
+	// their network, which then has to be given.
+	Host string
+}
+
+// Chain is a running regtest daemon
+type Chain struct {
+	// Coin is the coin it runs
+	Coin Coin
+
+	// RPC is a client for its RPC server
+	RPC *regtest.Bitcoind
+
+	// P2P is the host:port wallets sync from
+	P2P string
+
+	// Log is where its output goes
+	Log string
+
+	cmd    *exec.Cmd
+	dir    string
+	temp   bool
+	exited chan struct{}
+	err    error
+
+	mu       sync.Mutex
+	withheld bool
+}
+
+// Start runs the daemon of the coin on regtest and waits until it answers
+// and has coins to spend
+func Start(ctx context.Context, o Options) (*Chain, error) {
+	if o.Host == "" {
+		o.Host = "127.0.0.1"
+	}
+	if o.Coin.Code == "" {
+		o.Coin = Bitcoin
+	}
+	binary, err := o.binary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &Chain{Coin: o.Coin, dir: o.Dir, exited: make(chan struct{})}
+	if c.dir == "" {
+		tmp, err := ioutil.TempDir("", "testnodes-chain")
+		if err != nil {
+			return nil, err
+		}
+		c.dir, c.temp = tmp, true
+	} else if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, err
+	}
+	p2p, err := freePort(o.Host)
+	if err != nil {
+		c.cleanup()
+		return nil, err
+	}
+	rpc, err := freePort(o.Host)
+	if err != nil {
+		c.cleanup()
+		return nil, err
+	}
+	c.P2P = net.JoinHostPort(o.Host, strconv.Itoa(p2p))
+	c.RPC = regtest.New("http://"+net.JoinHostPort(o.Host, strconv.Itoa(rpc)), "testnodes", "testnodes")
+	c.RPC.LegacyGenerate = o.Coin.LegacyGenerate
+	c.Log = filepath.Join(c.dir, filepath.Base(binary)+".log")
+	log, err := os.OpenFile(c.Log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		c.cleanup()
+		return nil, err
+	}
+	defer log.Close()
+
+	c.cmd = exec.Command(binary, append(args(c.dir, o.Host, p2p, rpc, c.RPC.Username, c.RPC.Password), o.Coin.Args...)...)
+	c.cmd.Stdout = log
+	c.cmd.Stderr = log
+	if err := c.cmd.Start(); err != nil {
+		c.cleanup()
+		return nil, err
+	}
+	go func() {
+		c.err = c.cmd.Wait()
+		close(c.exited)
+	}()
+
+	wait, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.exited:
+			cancel()
+		case <-wait.Done():
+		}
+	}()
+	if err := c.RPC.Wait(wait); err != nil {
+		select {
+		case <-c.exited:
+			err = fmt.Errorf("%s exited during boot: %v, see %s", filepath.Base(binary), c.err, c.Log)
+		default:
+		}
+		c.Close()
+		return nil, err
+	}
+	if err := c.RPC.Mature(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("maturing the chain: %s", err)
+	}
+	return c, nil
+}
+
+// binary returns the daemon to run
+func (o Options) binary(ctx context.Context) (string, error) {
+	if o.Binary != "" {
+		return o.Binary, nil
+	}
+	if o.Coin.Daemon == "" {
+		return "", fmt.Errorf("chain: %s needs its daemon given as the Binary", o.Coin.Code)
+	}
+	binary, err := exec.LookPath(o.Coin.Daemon)
+	if err == nil {
+		return binary, nil
+	}
+	if o.Coin.Code != Bitcoin.Code {
+		return "", fmt.Errorf("chain: no %s on the PATH", o.Coin.Daemon)
+	}
+	if binary, err = Fetch(ctx, o.Cache, o.Version); err != nil {
+		return "", fmt.Errorf("chain: no bitcoind on the PATH and none downloaded: %s", err)
+	}
+	return binary, nil
+}
+
+// args are the daemon arguments for a regtest chain in dir that talks to
+// nothing but the wallets syncing from it, shared by every coin
+func args(dir, host string, p2p, rpc int, user, password string) []string {
+	return []string{
+		"-regtest",
+		"-datadir=" + dir,
+		"-server",
+		"-listen",
+		"-bind=" + net.JoinHostPort(host, strconv.Itoa(p2p)),
+		"-rpcbind=" + host,
+		"-rpcallowip=" + host,
+		"-rpcport=" + strconv.Itoa(rpc),
+		"-rpcuser=" + user,
+		"-rpcpassword=" + password,
+		// Looks up the payments of node wallets by txid, which are not
+		// the daemon's own
+		"-txindex",
+		"-dnsseed=0",
+		"-discover=0",
+		"-upnp=0",
+		"-printtoconsole",
+	}
+}
+
+// Wallet points the wallet of a node at the chain. Only the bitcoin wallet
+// of nodes with a single one can be, other coins need a wallet per coin,
+// see CoinWallet.
+func (c *Chain) Wallet() nodes.Option {
+	if c.Coin.Code != Bitcoin.Code {
+		return c.CoinWallet()
+	}
+	return nodes.WithRegtestWallet(c.P2P)
+}
+
+// CoinWallet points the wallet for the coin of a node running a wallet per
+// coin at the chain
+func (c *Chain) CoinWallet() nodes.Option {
+	return nodes.WithCoinWallet(c.Coin.Code, c.P2P)
+}
+
+// GenerateBlocks mines n blocks, even while blocks are withheld
+func (c *Chain) GenerateBlocks(n int) error {
+	return c.RPC.Generate(n)
+}
+
+// Fund sends coins to address and mines a block to confirm it, unless
+// blocks are withheld
+func (c *Chain) Fund(address string, coins float64) error {
+	if err := c.RPC.Call("sendtoaddress", nil, address, coins); err != nil {
+		return err
+	}
+	if c.Withheld() {
+		return nil
+	}
+	return c.RPC.Generate(1)
+}
+
+// Send sends sat of the smallest unit of the coin to address and mines a
+// block to confirm it, unless blocks are withheld
+func (c *Chain) Send(address string, sat int64) error {
+	return c.Fund(address, float64(sat)/1e8)
+}
+
+// Withhold stops the chain mining blocks to confirm what it funds, so
+// every transaction stays unconfirmed until Release, GenerateBlocks or
+// MineUntilConfirmed. Nothing else mines on a regtest chain.
+func (c *Chain) Withhold() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.withheld = true
+}
+
+// Withheld tells whether blocks are withheld
+func (c *Chain) Withheld() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.withheld
+}
+
+// Release lifts Withhold and mines a block confirming every transaction
+// held in the meantime
+func (c *Chain) Release() error {
+	c.mu.Lock()
+	c.withheld = false
+	c.mu.Unlock()
+	return c.RPC.Generate(1)
+}
+
+// Confirmations returns how many blocks confirm the transaction, 0 while it
+// is in the mempool
+func (c *Chain) Confirmations(txid string) (int, error) {
+	var tx struct {
+		Confirmations int `json:"confirmations"`
+	}
+	if err := c.RPC.Call("getrawtransaction", &tx, txid, 1); err != nil {
+		return 0, err
+	}
+	return tx.Confirmations, nil
+}
+
+// MineUntilConfirmed mines blocks until the transaction has n
+// confirmations, even while blocks are withheld. It mines every other
+// transaction in the mempool along with it.
+func (c *Chain) MineUntilConfirmed(txid string, n int) error {
+	confs, err := c.Confirmations(txid)
+	if err != nil {
+		return fmt.Errorf("chain: %s: %s", txid, err)
+	}
+	if confs >= n {
+		return nil
+	}
+	if err := c.RPC.Generate(n - confs); err != nil {
+		return err
+	}
+	if confs, err = c.Confirmations(txid); err != nil {
+		return fmt.Errorf("chain: %s: %s", txid, err)
+	}
+	if confs < n {
+		return fmt.Errorf("chain: %s has %d confirmations after mining, not %d, so it was left out of the blocks", txid, confs, n)
+	}
+	return nil
+}
+
+// Close stops the daemon, killing it if it does not stop in time, and removes
+// the datadir if it was a temp dir
+func (c *Chain) Close() error {
+	var err error
+	if c.cmd != nil && c.cmd.Process != nil {
+		select {
+		case <-c.exited:
+		default:
+			c.cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-c.exited:
+			case <-time.After(stopTimeout):
+				c.cmd.Process.Kill()
+				<-c.exited
+				err = fmt.Errorf("chain: %s did not stop in time and was killed", filepath.Base(c.cmd.Path))
+			}
+		}
+	}
+	if rerr := c.cleanup(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (c *Chain) cleanup() error {
+	if !c.temp {
+		return nil
+	}
+	return os.RemoveAll(c.dir)
+}
+
+// freePort returns a port nothing listens on at host
+func freePort(host string) (int, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
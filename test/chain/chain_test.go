@@ -0,0 +1,254 @@
+package chain
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/regtest"
+)
+
+const fakeBitcoind = "#!/bin/sh\necho bitcoind\n"
+
+// release serves a release of version holding a fake bitcoind, listing sum
+// as its SHA256 or the real one when empty, and counts the downloads
+func release(t *testing.T, version, sum string, downloads *int) *httptest.Server {
+	platform, ok := platforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		t.Skip("no bitcoind release for this platform")
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	body := []byte(fakeBitcoind)
+	name := fmt.Sprintf("bitcoin-%s/bin/bitcoind", version)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	archive := buf.Bytes()
+	if sum == "" {
+		h := sha256.Sum256(archive)
+		sum = hex.EncodeToString(h[:])
+	}
+	archiveName := fmt.Sprintf("bitcoin-%s-%s.tar.gz", version, platform)
+	sums := fmt.Sprintf("-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\n%s  bitcoin-%s-osx.dmg\n%s  %s\n-----BEGIN PGP SIGNATURE-----\n",
+		strings.Repeat("0", 64), version, sum, archiveName)
+
+	dir := fmt.Sprintf("/bitcoin-core-%s/", version)
+	mux := http.NewServeMux()
+	mux.HandleFunc(dir+"SHA256SUMS.asc", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sums))
+	})
+	mux.HandleFunc(dir+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		*downloads++
+		w.Write(archive)
+	})
+	s := httptest.NewServer(mux)
+	releases = s.URL
+	return s
+}
+
+func TestFetch(t *testing.T) {
+	downloads := 0
+	s := release(t, "0.99.0", "", &downloads)
+	defer s.Close()
+	defer func() { releases = "https://bitcoincore.org/bin" }()
+	cache, err := ioutil.TempDir("", "testnodes-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+
+	binary, err := Fetch(context.Background(), cache, "0.99.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(cache, "bitcoin-0.99.0", "bitcoind"); binary != want {
+		t.Errorf("Expected bitcoind at %s, got %s", want, binary)
+	}
+	got, err := ioutil.ReadFile(binary)
+	if err != nil || string(got) != fakeBitcoind {
+		t.Errorf("Expected the bitcoind of the release, got %q, %v", got, err)
+	}
+	if info, err := os.Stat(binary); err != nil || info.Mode()&0100 == 0 {
+		t.Errorf("Expected bitcoind to be executable, got %v, %v", info.Mode(), err)
+	}
+
+	if _, err := Fetch(context.Background(), cache, "0.99.0"); err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 1 {
+		t.Errorf("Expected the release downloaded once, got %d", downloads)
+	}
+}
+
+func TestFetchChecksumMismatch(t *testing.T) {
+	downloads := 0
+	s := release(t, "0.99.0", strings.Repeat("ab", 32), &downloads)
+	defer s.Close()
+	defer func() { releases = "https://bitcoincore.org/bin" }()
+	cache, err := ioutil.TempDir("", "testnodes-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+
+	if _, err := Fetch(context.Background(), cache, "0.99.0"); err == nil || !strings.Contains(err.Error(), "SHA256SUMS") {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "bitcoin-0.99.0", "bitcoind")); !os.IsNotExist(err) {
+		t.Errorf("Expected no bitcoind kept, got %v", err)
+	}
+	if _, err := Fetch(context.Background(), cache, "0.98.0"); err == nil {
+		t.Error("Expected a release that is not published to fail")
+	}
+}
+
+func TestStartExitingBitcoind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnodes-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "bitcoind")
+	script := "#!/bin/sh\necho \"Error: Cannot obtain a lock on data directory\" >&2\nexit 1\n"
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = Start(ctx, Options{Binary: binary, Dir: filepath.Join(dir, "data")})
+	if err == nil || !strings.Contains(err.Error(), "exited during boot") {
+		t.Fatalf("Expected bitcoind exiting to fail the start, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Expected the start to fail as soon as bitcoind exited, took %s", time.Since(start))
+	}
+	log, err := ioutil.ReadFile(filepath.Join(dir, "data", "bitcoind.log"))
+	if err != nil || !strings.Contains(string(log), "Cannot obtain a lock") {
+		t.Errorf("Expected the output of bitcoind in its log, got %q, %v", log, err)
+	}
+}
+
+func TestStartCoinDaemon(t *testing.T) {
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	if _, err := Start(context.Background(), Options{Coin: BitcoinCash}); err == nil || !strings.Contains(err.Error(), "BCH needs its daemon") {
+		t.Errorf("Expected a Bitcoin Cash chain without its daemon to be refused, got %v", err)
+	}
+	os.Setenv("PATH", "")
+	if _, err := Start(context.Background(), Options{Coin: Litecoin}); err == nil || !strings.Contains(err.Error(), "no litecoind") {
+		t.Errorf("Expected a Litecoin chain without litecoind to be refused rather than fetched, got %v", err)
+	}
+	if c, err := LookupCoin("zec"); err != nil || c.Daemon != "zcashd" || !c.LegacyGenerate {
+		t.Errorf("Expected ZEC to run zcashd, got %+v, %v", c, err)
+	}
+}
+
+// fakeChain answers the RPCs Chain sends with a mempool and block heights
+// of transactions, and counts the blocks mined
+func fakeChain(t *testing.T, mined *int) *httptest.Server {
+	heights := make(map[string]int)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "getnewaddress":
+			result = "mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M"
+		case "sendtoaddress":
+			txid := fmt.Sprintf("tx%d", len(heights))
+			heights[txid] = 0
+			result = txid
+		case "generatetoaddress":
+			n := int(req.Params[0].(float64))
+			for i := 0; i < n; i++ {
+				*mined++
+				for txid, h := range heights {
+					if h == 0 {
+						heights[txid] = *mined
+					}
+				}
+			}
+		case "getrawtransaction":
+			h, ok := heights[req.Params[0].(string)]
+			if !ok {
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": -5, "message": "No such mempool or blockchain transaction"}})
+				return
+			}
+			tx := map[string]interface{}{}
+			if h > 0 {
+				tx["confirmations"] = *mined - h + 1
+			}
+			result = tx
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+}
+
+func TestWithhold(t *testing.T) {
+	mined := 0
+	s := fakeChain(t, &mined)
+	defer s.Close()
+	c := &Chain{RPC: regtest.New(s.URL, "", "")}
+
+	c.Withhold()
+	if err := c.Send("mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M", 1e6); err != nil {
+		t.Fatal(err)
+	}
+	if confs, err := c.Confirmations("tx0"); err != nil || confs != 0 || mined != 0 {
+		t.Errorf("Expected the payment held unconfirmed, got %d confirmations and %d blocks, %v", confs, mined, err)
+	}
+	if err := c.MineUntilConfirmed("tx0", 3); err != nil {
+		t.Fatal(err)
+	}
+	if confs, _ := c.Confirmations("tx0"); confs != 3 || mined != 3 {
+		t.Errorf("Expected 3 confirmations from 3 blocks, got %d from %d", confs, mined)
+	}
+	if err := c.MineUntilConfirmed("tx0", 2); err != nil || mined != 3 {
+		t.Errorf("Expected a confirmed payment to need no blocks, mined %d, %v", mined, err)
+	}
+	if err := c.MineUntilConfirmed("tx9", 1); err == nil {
+		t.Error("Expected an unknown transaction to fail")
+	}
+
+	if err := c.Send("mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M", 1e6); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if confs, _ := c.Confirmations("tx1"); confs != 1 {
+		t.Errorf("Expected the held payment confirmed on release, got %d confirmations", confs)
+	}
+	if err := c.Send("mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M", 1e6); err != nil {
+		t.Fatal(err)
+	}
+	if confs, _ := c.Confirmations("tx2"); confs != 1 {
+		t.Errorf("Expected payments confirmed once released, got %d confirmations", confs)
+	}
+}
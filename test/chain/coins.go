@@ -0,0 +1,59 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Coin is a currency whose regtest daemon a chain can run
+type Coin struct {
+	// Code is the currency code node wallets know the coin by
+	Code string
+
+	// Daemon is the binary looked up on the PATH when none is given, empty
+	// when the name is ambiguous and the binary has to be given
+	Daemon string
+
+	// Args are passed to the daemon on top of the ones every coin takes
+	Args []string
+
+	// LegacyGenerate mines with generate, for daemons forked before
+	// generatetoaddress
+	LegacyGenerate bool
+}
+
+// spvArgs serve the SPV wallets of the nodes, which need bloom filters, off
+// by default in newer releases, and let the daemon's own wallet send
+// without fee estimates
+var spvArgs = []string{"-peerbloomfilters=1", "-fallbackfee=0.0002"}
+
+var (
+	// Bitcoin runs bitcoind of Bitcoin Core, downloaded with Fetch if
+	// there is none
+	Bitcoin = Coin{Code: "BTC", Daemon: "bitcoind", Args: spvArgs}
+
+	// BitcoinCash runs bitcoind of Bitcoin ABC. It has the name of the
+	// Bitcoin Core one, so it has to be given.
+	BitcoinCash = Coin{Code: "BCH", Args: spvArgs}
+
+	// Litecoin runs litecoind
+	Litecoin = Coin{Code: "LTC", Daemon: "litecoind", Args: spvArgs}
+
+	// Zcash runs zcashd, which needs the proving parameters fetched with
+	// zcash-fetch-params even on regtest. It predates -peerbloomfilters and
+	// -fallbackfee, which it would refuse.
+	Zcash = Coin{Code: "ZEC", Daemon: "zcashd", LegacyGenerate: true}
+)
+
+// Coins are the coins a chain can run, the ones openbazaard has wallets for
+var Coins = []Coin{Bitcoin, BitcoinCash, Litecoin, Zcash}
+
+// LookupCoin returns the coin of code, e.g. LTC
+func LookupCoin(code string) (Coin, error) {
+	for _, c := range Coins {
+		if strings.EqualFold(c.Code, code) {
+			return c, nil
+		}
+	}
+	return Coin{}, fmt.Errorf("chain: no coin %q", code)
+}
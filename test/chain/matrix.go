@@ -0,0 +1,75 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// Matrix is a chain per coin, for nodes running a wallet of each
+type Matrix map[string]*Chain
+
+// StartMatrix starts a chain for the coin of every options, stopping the
+// ones started when one fails
+func StartMatrix(ctx context.Context, opts ...Options) (Matrix, error) {
+	m := make(Matrix)
+	for _, o := range opts {
+		if o.Coin.Code == "" {
+			o.Coin = Bitcoin
+		}
+		if m[o.Coin.Code] != nil {
+			m.Close()
+			return nil, fmt.Errorf("chain: %s started twice", o.Coin.Code)
+		}
+		c, err := Start(ctx, o)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("starting the %s chain: %s", o.Coin.Code, err)
+		}
+		m[o.Coin.Code] = c
+	}
+	return m, nil
+}
+
+// Coins returns the codes of the coins of the matrix in the order of Coins
+func (m Matrix) Coins() []string {
+	var codes []string
+	for _, c := range Coins {
+		if m[c.Code] != nil {
+			codes = append(codes, c.Code)
+		}
+	}
+	return codes
+}
+
+// Wallets points the wallet for every coin of a node at its chain
+func (m Matrix) Wallets() nodes.Option {
+	return func(o *nodes.Options) {
+		for _, c := range m {
+			c.CoinWallet()(o)
+		}
+	}
+}
+
+// Fund sends coins of the coin of code to address and mines a block to
+// confirm it
+func (m Matrix) Fund(code, address string, coins float64) error {
+	c := m[code]
+	if c == nil {
+		return fmt.Errorf("chain: no %s chain", code)
+	}
+	return c.Fund(address, coins)
+}
+
+// Close stops every chain, returning the first error
+func (m Matrix) Close() error {
+	var err error
+	for code, c := range m {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+		delete(m, code)
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+// Package chaos parses and plays fault schedules, the timed fault sequences
+// a scenario declares next to its steps, e.g.
+//
+//	at T+10s partition {vendor}
+//	at T+30s heal
+//	at T+40s restart buyer
+//
+// Entries are separated by newlines, commas or semicolons. The package only
+// knows the syntax and the timing; what partitioning or restarting means is
+// up to the function Play runs each event with.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Actions are the faults a schedule may name
+const (
+	// Partition splits the network: each group is cut off from every node
+	// outside it. A single group is cut off from the rest of the network.
+	Partition = "partition"
+	// Heal undoes every partition
+	Heal = "heal"
+
+	Pause   = "pause"
+	Resume  = "resume"
+	Stop    = "stop"
+	Restart = "restart"
+)
+
+// Event is one fault of a schedule
+type Event struct {
+	// At is the offset from the start of the schedule
+	At     time.Duration
+	Action string
+
+	// Targets are the node names or roles the action applies to
+	Targets []string
+
+	// Groups are the sides of a partition
+	Groups [][]string
+}
+
+func (e Event) String() string {
+	args := e.Targets
+	for _, g := range e.Groups {
+		args = append(args, "{"+strings.Join(g, " ")+"}")
+	}
+	return strings.TrimSpace(fmt.Sprintf("at T+%s %s %s", e.At, e.Action, strings.Join(args, " ")))
+}
+
+// Parse reads a schedule and returns its events ordered by offset, keeping
+// the written order of events at the same offset
+func Parse(schedule string) ([]Event, error) {
+	var events []Event
+	for _, entry := range entries(schedule) {
+		e, err := parseEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("chaos: %q: %s", entry, err)
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	return events, nil
+}
+
+// entries splits a schedule at separators outside braces
+func entries(schedule string) []string {
+	var ret []string
+	depth, start := 0, 0
+	flush := func(end int) {
+		if e := strings.TrimSpace(schedule[start:end]); e != "" {
+			ret = append(ret, e)
+		}
+		start = end + 1
+	}
+	for i, r := range schedule {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '\n', ',', ';':
+			if depth == 0 {
+				flush(i)
+			}
+		}
+	}
+	flush(len(schedule))
+	return ret
+}
+
+func parseEntry(entry string) (Event, error) {
+	fields := strings.Fields(strings.NewReplacer("{", " { ", "}", " } ").Replace(entry))
+	if len(fields) < 3 || fields[0] != "at" || !strings.HasPrefix(fields[1], "T+") {
+		return Event{}, fmt.Errorf("expected at T+<duration> <action>")
+	}
+	at, err := time.ParseDuration(strings.TrimPrefix(fields[1], "T+"))
+	if err != nil {
+		return Event{}, err
+	}
+	e := Event{At: at, Action: fields[2]}
+	args := fields[3:]
+	switch e.Action {
+	case Partition:
+		if e.Groups, err = groups(args); err != nil {
+			return Event{}, err
+		}
+	case Heal:
+		if len(args) > 0 {
+			return Event{}, fmt.Errorf("heal takes no targets")
+		}
+	case Pause, Resume, Stop, Restart:
+		if len(args) == 0 {
+			return Event{}, fmt.Errorf("%s needs a target", e.Action)
+		}
+		for _, a := range args {
+			if a == "{" || a == "}" {
+				return Event{}, fmt.Errorf("%s takes targets, not groups", e.Action)
+			}
+		}
+		e.Targets = args
+	default:
+		return Event{}, fmt.Errorf("unknown action %s", e.Action)
+	}
+	return e, nil
+}
+
+// groups parses "{ a b } { c }" into [[a b] [c]]
+func groups(args []string) ([][]string, error) {
+	var ret [][]string
+	var cur []string
+	open := false
+	for _, a := range args {
+		switch {
+		case a == "{" && !open:
+			open, cur = true, nil
+		case a == "}" && open:
+			if len(cur) == 0 {
+				return nil, fmt.Errorf("empty group")
+			}
+			ret = append(ret, cur)
+			open = false
+		case a == "{" || a == "}" || !open:
+			return nil, fmt.Errorf("partition takes groups like {vendor buyer-1}")
+		default:
+			cur = append(cur, a)
+		}
+	}
+	if open || len(ret) == 0 {
+		return nil, fmt.Errorf("partition takes groups like {vendor buyer-1}")
+	}
+	return ret, nil
+}
+
+// Clock is what schedules are played on. The wall clock is used by Play,
+// simulations pass their virtual clock to PlayOn.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+// Wall is the wall clock
+var Wall Clock = wallClock{}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time                         { return time.Now() }
+func (wallClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Play runs every event with do at its offset from now, one after another,
+// and returns the first error. It returns nil once ctx is done, as a
+// scenario that finishes early simply cuts its schedule short.
+func Play(ctx context.Context, events []Event, do func(context.Context, Event) error) error {
+	return PlayOn(ctx, Wall, events, do)
+}
+
+// PlayOn is Play with offsets measured on clock
+func PlayOn(ctx context.Context, clock Clock, events []Event, do func(context.Context, Event) error) error {
+	start := clock.Now()
+	for _, e := range events {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.After(start.Add(e.At).Sub(clock.Now())):
+		}
+		if err := do(ctx, e); err != nil {
+			return fmt.Errorf("%s: %s", e, err)
+		}
+	}
+	return nil
+}
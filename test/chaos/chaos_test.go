@@ -0,0 +1,73 @@
+package chaos
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	events, err := Parse(`
+		at T+40s restart buyer
+		at T+10s partition {vendor}, at T+30s heal; at T+10s pause moderator-1 buyer-2
+		at T+1m partition {vendor-1 buyer-1} {vendor-2}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{At: 10 * time.Second, Action: Partition, Groups: [][]string{{"vendor"}}},
+		{At: 10 * time.Second, Action: Pause, Targets: []string{"moderator-1", "buyer-2"}},
+		{At: 30 * time.Second, Action: Heal},
+		{At: 40 * time.Second, Action: Restart, Targets: []string{"buyer"}},
+		{At: time.Minute, Action: Partition, Groups: [][]string{{"vendor-1", "buyer-1"}, {"vendor-2"}}},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+	if s := events[4].String(); s != "at T+1m0s partition {vendor-1 buyer-1} {vendor-2}" {
+		t.Errorf("Unexpected string %q", s)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"partition {vendor}",
+		"at 10s heal",
+		"at T+ten heal",
+		"at T+1s explode vendor",
+		"at T+1s heal vendor",
+		"at T+1s restart",
+		"at T+1s restart {buyer}",
+		"at T+1s partition vendor",
+		"at T+1s partition {vendor",
+		"at T+1s partition {}",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestPlay(t *testing.T) {
+	events := []Event{
+		{At: 0, Action: Pause, Targets: []string{"a"}},
+		{At: 50 * time.Millisecond, Action: Resume, Targets: []string{"a"}},
+		{At: time.Hour, Action: Heal},
+	}
+	start := time.Now()
+	var played []time.Duration
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := Play(ctx, events, func(ctx context.Context, e Event) error {
+		played = append(played, time.Since(start))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(played) != 2 || played[1] < 50*time.Millisecond {
+		t.Errorf("Expected two events, the second after 50ms, got %v", played)
+	}
+}
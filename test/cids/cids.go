@@ -0,0 +1,235 @@
+// Package cids computes the content identifiers the node assigns to content,
+// so scenarios can check that a hash returned by the API names exactly the
+// bytes they sent instead of only checking that some hash came back.
+//
+// It depends on nothing from go-ipfs and can be used from tests that do not
+// link a node.
+package cids
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// Codecs of the blocks the node writes
+const (
+	Raw         = 0x55
+	DagProtobuf = 0x70
+)
+
+// Multihash function codes
+const (
+	Identity = 0x00
+	SHA1     = 0x11
+	SHA2_256 = 0x12
+	SHA2_512 = 0x13
+)
+
+// Importer defaults of `ipfs add`, which the node uses for every file it adds
+const (
+	ChunkSize    = 262144
+	LinksPerNode = 174
+)
+
+// unixfsFile is the unixfs DataType of a file node
+const unixfsFile = 2
+
+// Multihash returns the multihash of content with the given hash function
+func Multihash(content []byte, mhCode uint64) ([]byte, error) {
+	var digest []byte
+	switch mhCode {
+	case Identity:
+		digest = content
+	case SHA1:
+		d := sha1.Sum(content)
+		digest = d[:]
+	case SHA2_256:
+		d := sha256.Sum256(content)
+		digest = d[:]
+	case SHA2_512:
+		d := sha512.Sum512(content)
+		digest = d[:]
+	default:
+		return nil, fmt.Errorf("cids: unsupported multihash code 0x%x", mhCode)
+	}
+	var b bytes.Buffer
+	putUvarint(&b, mhCode)
+	putUvarint(&b, uint64(len(digest)))
+	b.Write(digest)
+	return b.Bytes(), nil
+}
+
+// ExpectCID returns the base58 CIDv1 of content stored as a single block
+// with the given codec and hash function, e.g. ExpectCID(b, Raw, SHA2_256)
+// for a file the node added that fits in one chunk
+func ExpectCID(content []byte, codec, mhCode uint64) (string, error) {
+	c, err := cidBytes(content, codec, mhCode)
+	if err != nil {
+		return "", err
+	}
+	return Encode(c), nil
+}
+
+// ExpectFileCID returns the CID the node assigns to a file with content,
+// as `ipfs add --cid-version 1` does for images and listings: raw leaves of
+// ChunkSize bytes under a balanced tree of unixfs nodes with up to
+// LinksPerNode links each
+func ExpectFileCID(content []byte) string {
+	var leaves []dagNode
+	for off := 0; off < len(content); off += ChunkSize {
+		end := off + ChunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		chunk := content[off:end]
+		c, _ := cidBytes(chunk, Raw, SHA2_256)
+		leaves = append(leaves, dagNode{cid: c, tsize: uint64(len(chunk)), fileSize: uint64(len(chunk))})
+	}
+	return Encode(balanced(leaves).cid)
+}
+
+// V0 returns the base58 CIDv0 of a dag-pb block, the form the node uses
+// for peer IDs and older content
+func V0(block []byte) string {
+	mh, _ := Multihash(block, SHA2_256)
+	return base58.Encode(mh)
+}
+
+// Encode returns the base58btc multibase string of a binary CID
+func Encode(cid []byte) string {
+	return "z" + base58.Encode(cid)
+}
+
+// Parse decodes a base58btc CIDv1 as returned by Encode into its codec and
+// multihash. The multihash must be complete, so arbitrary strings starting
+// with z are rejected.
+func Parse(s string) (codec uint64, mh []byte, err error) {
+	if len(s) < 2 || s[0] != 'z' {
+		return 0, nil, errors.New("cids: not a base58btc CID")
+	}
+	b := base58.Decode(s[1:])
+	version, n := binary.Uvarint(b)
+	if n <= 0 || version != 1 {
+		return 0, nil, errors.New("cids: not a CIDv1")
+	}
+	b = b[n:]
+	codec, n = binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, errors.New("cids: truncated codec")
+	}
+	mh = b[n:]
+	_, n = binary.Uvarint(mh)
+	if n <= 0 {
+		return 0, nil, errors.New("cids: truncated multihash")
+	}
+	length, m := binary.Uvarint(mh[n:])
+	if m <= 0 || uint64(len(mh)-n-m) != length {
+		return 0, nil, errors.New("cids: multihash length mismatch")
+	}
+	return codec, mh, nil
+}
+
+func cidBytes(content []byte, codec, mhCode uint64) ([]byte, error) {
+	mh, err := Multihash(content, mhCode)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	putUvarint(&b, 1)
+	putUvarint(&b, codec)
+	b.Write(mh)
+	return b.Bytes(), nil
+}
+
+// dagNode is a block of a file DAG as its parent links to it
+type dagNode struct {
+	cid      []byte
+	tsize    uint64
+	fileSize uint64
+}
+
+// balanced lays leaves out the way the go-ipfs balanced importer does: the
+// first leaf is the root, then every level adds a root linking the previous
+// one followed by as many new subtrees of the level below as fit
+func balanced(leaves []dagNode) dagNode {
+	if len(leaves) == 0 {
+		return fileNode(nil)
+	}
+	root, next := leaves[0], 1
+	for level := 1; next < len(leaves); level++ {
+		children := []dagNode{root}
+		for len(children) < LinksPerNode && next < len(leaves) {
+			children = append(children, subtree(leaves, &next, level-1))
+		}
+		root = fileNode(children)
+	}
+	return root
+}
+
+func subtree(leaves []dagNode, next *int, depth int) dagNode {
+	if depth == 0 {
+		l := leaves[*next]
+		*next++
+		return l
+	}
+	var children []dagNode
+	for len(children) < LinksPerNode && *next < len(leaves) {
+		children = append(children, subtree(leaves, next, depth-1))
+	}
+	return fileNode(children)
+}
+
+// fileNode encodes a dag-pb node holding a unixfs file made of children
+func fileNode(children []dagNode) dagNode {
+	var fileSize uint64
+	for _, c := range children {
+		fileSize += c.fileSize
+	}
+	var unixfs bytes.Buffer
+	putField(&unixfs, 1, unixfsFile)
+	putField(&unixfs, 3, fileSize)
+	for _, c := range children {
+		putField(&unixfs, 4, c.fileSize)
+	}
+
+	// dag-pb writes the links before the data
+	var block bytes.Buffer
+	tsize := uint64(0)
+	for _, c := range children {
+		var link bytes.Buffer
+		putBytes(&link, 1, c.cid)
+		putBytes(&link, 2, nil)
+		putField(&link, 3, c.tsize)
+		putBytes(&block, 2, link.Bytes())
+		tsize += c.tsize
+	}
+	putBytes(&block, 1, unixfs.Bytes())
+
+	c, _ := cidBytes(block.Bytes(), DagProtobuf, SHA2_256)
+	return dagNode{cid: c, tsize: tsize + uint64(block.Len()), fileSize: fileSize}
+}
+
+func putUvarint(b *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+// putField writes a protobuf varint field
+func putField(b *bytes.Buffer, field int, v uint64) {
+	putUvarint(b, uint64(field<<3))
+	putUvarint(b, v)
+}
+
+// putBytes writes a protobuf length delimited field
+func putBytes(b *bytes.Buffer, field int, v []byte) {
+	putUvarint(b, uint64(field<<3|2))
+	putUvarint(b, uint64(len(v)))
+	b.Write(v)
+}
@@ -0,0 +1,81 @@
+package cids
+
+import (
+	"bytes"
+	"encoding/base32"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestExpectCID(t *testing.T) {
+	got, err := ExpectCID([]byte("hello world"), Raw, SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same CID as printed by `ipfs add --cid-version 1 --raw-leaves`
+	b32 := "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"
+	s := strings.ToUpper(b32[1:])
+	want, err := base32.StdEncoding.DecodeString(s + strings.Repeat("=", (8-len(s)%8)%8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Encode(want) {
+		t.Errorf("Expected %s, got %s", Encode(want), got)
+	}
+}
+
+func TestUnsupportedMultihash(t *testing.T) {
+	if _, err := ExpectCID([]byte("x"), Raw, 0xb220); err == nil {
+		t.Error("Expected an error for blake2b")
+	}
+}
+
+func TestV0(t *testing.T) {
+	// the unixfs node of an empty file
+	if got := V0([]byte{0x0a, 0x04, 0x08, 0x02, 0x18, 0x00}); got != "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH" {
+		t.Errorf("Expected the empty file hash, got %s", got)
+	}
+}
+
+func TestExpectFileCID(t *testing.T) {
+	if got := ExpectFileCID(nil); got != "zdj7WiLc855B1KPRgV7Fh8ivjuAhePE1tuJafmxH5HmmSjqaD" {
+		t.Errorf("Expected the CIDv1 of the empty file, got %s", got)
+	}
+	empty := base58.Decode(ExpectFileCID(nil)[1:])
+	if !bytes.Equal(empty[2:], base58.Decode("QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH")) {
+		t.Error("Expected the empty file to hash like its CIDv0")
+	}
+
+	small := bytes.Repeat([]byte{1}, ChunkSize)
+	raw, _ := ExpectCID(small, Raw, SHA2_256)
+	if got := ExpectFileCID(small); got != raw {
+		t.Errorf("Expected a single chunk to be a raw leaf %s, got %s", raw, got)
+	}
+
+	large := bytes.Repeat([]byte{1}, ChunkSize+1)
+	root := base58.Decode(ExpectFileCID(large)[1:])
+	if root[1] != DagProtobuf {
+		t.Errorf("Expected a dag-pb root over two chunks, got codec 0x%x", root[1])
+	}
+	if ExpectFileCID(large) != ExpectFileCID(append([]byte(nil), large...)) {
+		t.Error("Expected the same CID for the same content")
+	}
+}
+
+func TestParse(t *testing.T) {
+	c := ExpectFileCID(bytes.Repeat([]byte{1}, ChunkSize+1))
+	codec, mh, err := Parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec != DagProtobuf || len(mh) != 34 || mh[0] != SHA2_256 {
+		t.Errorf("Expected a dag-pb sha2-256 CID, got codec 0x%x multihash %x", codec, mh)
+	}
+	for _, s := range []string{"", "z", "zebra", "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH", c[:len(c)-1]} {
+		if _, _, err := Parse(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"net/url"
+	"time"
+)
+
+// ChatMessage is a message as returned by GET /ob/chatmessages
+type ChatMessage struct {
+	MessageID string    `json:"messageId"`
+	PeerID    string    `json:"peerId"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	Outgoing  bool      `json:"outgoing"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SendChat sends a direct message to peerID and returns its message ID
+func (c *Client) SendChat(peerID, subject, message string) (string, error) {
+	var ret struct {
+		MessageID string `json:"messageId"`
+	}
+	err := c.postJSON("/ob/chat", map[string]string{
+		"peerId":  peerID,
+		"subject": subject,
+		"message": message,
+	}, &ret)
+	if err != nil {
+		return "", err
+	}
+	return ret.MessageID, nil
+}
+
+// ChatMessages returns the conversation with peerID
+func (c *Client) ChatMessages(peerID string) ([]ChatMessage, error) {
+	return c.ChatThread(peerID, "")
+}
+
+// ChatThread returns the messages with peerID sent under subject. The node
+// keeps each subject, e.g. an order ID, as a separate thread and the empty
+// subject is the direct conversation.
+func (c *Client) ChatThread(peerID, subject string) ([]ChatMessage, error) {
+	p := "/ob/chatmessages/" + peerID
+	if subject != "" {
+		p += "?subject=" + url.QueryEscape(subject)
+	}
+	var messages []ChatMessage
+	if err := c.GetJSON(p, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
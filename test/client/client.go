@@ -0,0 +1,360 @@
+// Package client is a small Go client for the REST API of a test node
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the request timeout used by clients created with New
+const DefaultTimeout = 30 * time.Second
+
+// Client issues authenticated requests against a single node's API
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	Cookie   *http.Cookie
+	HTTP     *http.Client
+
+	// Latencies, when set, records the response time of every request
+	Latencies *Latencies
+
+	// Limiter, when set, throttles the requests sent to the node
+	Limiter *Limiter
+
+	// SocketPath is the unix socket requests are sent over, if the node
+	// serves its API on one
+	SocketPath string
+
+	// Dial, when set, connects to the node in place of dialing the host of
+	// BaseURL, e.g. over in-memory pipes
+	Dial func(network, addr string) (net.Conn, error)
+
+	// Observer, when set, is shown every response, e.g. to check it
+	// against the API description
+	Observer Observer
+
+	// Lifecycle, when set, holds requests while the client is paused, see
+	// WithMobileProfile
+	Lifecycle *Lifecycle
+
+	// Version is the openbazaard release the node runs, empty when unknown.
+	// Scenarios covering several releases branch on it with AtLeast.
+	Version string
+}
+
+// Observer is shown the responses read by a client
+type Observer interface {
+	Observe(method, path string, status int, body []byte)
+}
+
+// Observers shows every response to each of them in turn
+type Observers []Observer
+
+func (o Observers) Observe(method, path string, status int, body []byte) {
+	for _, ob := range o {
+		ob.Observe(method, path, status, body)
+	}
+}
+
+// Response is a fully read API response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// New returns a client for the API rooted at baseURL, e.g. http://127.0.0.1:4002
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// NewUnix returns a client for a node serving its API on the unix socket at
+// socketPath
+func NewUnix(socketPath string) *Client {
+	return &Client{
+		BaseURL:    "http://unix",
+		HTTP:       &http.Client{Timeout: DefaultTimeout, Transport: &http.Transport{Dial: unixDialer(socketPath)}},
+		SocketPath: socketPath,
+	}
+}
+
+// NewDialer returns a client reaching the node named in baseURL with dial
+func NewDialer(baseURL string, dial func(network, addr string) (net.Conn, error)) *Client {
+	c := New(baseURL)
+	c.HTTP.Transport = &http.Transport{Dial: dial}
+	c.Dial = dial
+	return c
+}
+
+// dialer returns the function connecting to the node, nil for the default
+func (c *Client) dialer() func(network, addr string) (net.Conn, error) {
+	if c.Dial != nil {
+		return c.Dial
+	}
+	if c.SocketPath != "" {
+		return unixDialer(c.SocketPath)
+	}
+	return nil
+}
+
+// unixDialer ignores the address of the URL and connects to the socket
+func unixDialer(socketPath string) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		return net.Dial("unix", socketPath)
+	}
+}
+
+// WithAuth sets the basic auth credentials and returns the client
+func (c *Client) WithAuth(username, password string) *Client {
+	c.Username = username
+	c.Password = password
+	return c
+}
+
+// WithCookie sets the auth cookie sent with every request and returns the client
+func (c *Client) WithCookie(cookie *http.Cookie) *Client {
+	c.Cookie = cookie
+	return c
+}
+
+// WithTimeout returns a copy of the client that gives up on requests after d
+func (c *Client) WithTimeout(d time.Duration) *Client {
+	cp := *c
+	cp.HTTP = &http.Client{Timeout: d, Transport: c.HTTP.Transport}
+	return &cp
+}
+
+// Get issues a GET request
+func (c *Client) Get(path string) (*Response, error) {
+	return c.Do("GET", path, nil)
+}
+
+// Post issues a POST request with the given body
+func (c *Client) Post(path string, body interface{}) (*Response, error) {
+	return c.Do("POST", path, body)
+}
+
+// Put issues a PUT request with the given body
+func (c *Client) Put(path string, body interface{}) (*Response, error) {
+	return c.Do("PUT", path, body)
+}
+
+// Patch issues a PATCH request with the given body
+func (c *Client) Patch(path string, body interface{}) (*Response, error) {
+	return c.Do("PATCH", path, body)
+}
+
+// Delete issues a DELETE request
+func (c *Client) Delete(path string) (*Response, error) {
+	return c.Do("DELETE", path, nil)
+}
+
+// Do issues a request. A string or []byte body is sent as is, anything else
+// is encoded as JSON.
+func (c *Client) Do(method, path string, body interface{}) (*Response, error) {
+	req, err := c.NewRequest(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.Send(req)
+}
+
+// GetJSON issues a GET request and decodes the JSON response into v
+func (c *Client) GetJSON(path string, v interface{}) error {
+	resp, err := c.Get(path)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	return resp.Decode(v)
+}
+
+// NewRequest builds an authenticated request without sending it
+func (c *Client) NewRequest(method, path string, body interface{}) (*http.Request, error) {
+	r, err := encodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.Cookie != nil {
+		req.AddCookie(c.Cookie)
+	}
+	return req, nil
+}
+
+// Send issues a prepared request and reads the full response
+func (c *Client) Send(req *http.Request) (*Response, error) {
+	if c.Limiter != nil {
+		release := c.Limiter.Acquire()
+		defer release()
+	}
+	start := time.Now()
+	var resp *http.Response
+	var b []byte
+	var err error
+	if c.Lifecycle != nil {
+		resp, b, err = c.Lifecycle.send(c.HTTP, req)
+	} else {
+		resp, b, err = read(c.HTTP, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Latencies != nil {
+		c.Latencies.Observe(req.Method, req.URL.Path, time.Since(start))
+	}
+	if c.Observer != nil {
+		c.Observer.Observe(req.Method, req.URL.Path, resp.StatusCode, b)
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       b,
+	}, nil
+}
+
+// read issues req and reads the full body
+func read(c *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, b, nil
+}
+
+// OK reports whether the response has a 2xx status code
+func (r *Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Decode unmarshals the JSON body into v
+func (r *Response) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Err returns an error describing a non-2xx response, or nil
+func (r *Response) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("api returned %d: %s", r.StatusCode, strings.TrimSpace(string(r.Body)))
+}
+
+func encodeBody(body interface{}) (io.Reader, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.NewReader(b), nil
+	case []byte:
+		return bytes.NewReader(b), nil
+	default:
+		out, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(out), nil
+	}
+}
+
+// Peers returns the swarm addresses of the peers the node is connected to
+func (c *Client) Peers() ([]string, error) {
+	resp, err := c.Get("/ob/peers")
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	var peers []string
+	if err := resp.Decode(&peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// ConnectPeer dials the peer at the full swarm address, e.g.
+// /ip4/127.0.0.1/tcp/4001/ipfs/Qm...
+func (c *Client) ConnectPeer(addr string) error {
+	return c.swarm("/ob/peers/connect", addr)
+}
+
+// DisconnectPeer closes the connection to the peer at the address, as
+// returned by Peers. The node may dial the peer again later.
+func (c *Client) DisconnectPeer(addr string) error {
+	return c.swarm("/ob/peers/disconnect", addr)
+}
+
+func (c *Client) swarm(path, addr string) error {
+	resp, err := c.Post(path, map[string][]string{"addrs": {addr}})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Bandwidth is the swarm traffic of a node. Totals are in bytes since it
+// started, rates in bytes per second averaged over the last seconds.
+type Bandwidth struct {
+	TotalIn  int64   `json:"totalIn"`
+	TotalOut int64   `json:"totalOut"`
+	RateIn   float64 `json:"rateIn"`
+	RateOut  float64 `json:"rateOut"`
+}
+
+// Bandwidth returns the traffic the node has sent and received over its
+// swarm connections
+func (c *Client) Bandwidth() (*Bandwidth, error) {
+	resp, err := c.Get("/ob/bandwidth")
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	var bw Bandwidth
+	if err := resp.Decode(&bw); err != nil {
+		return nil, err
+	}
+	return &bw, nil
+}
+
+// ConnectedTo reports whether the node has an open connection to peerID
+func (c *Client) ConnectedTo(peerID string) (bool, error) {
+	peers, err := c.Peers()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range peers {
+		if strings.HasSuffix(p, "/ipfs/"+peerID) || p == peerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
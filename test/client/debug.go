@@ -0,0 +1,46 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Goroutines counts the node's goroutines with a frame in a function whose
+// name contains match, e.g. "api.(*connection).reader". The node must serve
+// its runtime profiles.
+func (c *Client) Goroutines(match string) (int, error) {
+	b, err := c.GetBytes("/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		return 0, err
+	}
+	return countGoroutines(b, match), nil
+}
+
+// countGoroutines parses a debug=1 goroutine profile, made of blocks that
+// start with "<count> @ <pcs>" followed by one "#" line per frame
+func countGoroutines(profile []byte, match string) int {
+	total, count, matched := 0, 0, false
+	s := bufio.NewScanner(bytes.NewReader(profile))
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.Contains(line, " @ "):
+			if matched {
+				total += count
+			}
+			n, err := strconv.Atoi(strings.SplitN(line, " ", 2)[0])
+			count, matched = n, false
+			if err != nil {
+				count = 0
+			}
+		case strings.HasPrefix(line, "#") && strings.Contains(line, match):
+			matched = true
+		}
+	}
+	if matched {
+		total += count
+	}
+	return total
+}
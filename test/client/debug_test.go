@@ -0,0 +1,28 @@
+package client
+
+import "testing"
+
+const goroutineProfile = `goroutine profile: total 9
+4 @ 0x42d0da 0x42d18e 0x405d5f
+#	0x8e2a11	github.com/OpenBazaar/openbazaar-go/api.(*connection).reader+0x51	/go/src/github.com/OpenBazaar/openbazaar-go/api/ws.go:51
+#	0x8e3b0e	github.com/OpenBazaar/openbazaar-go/api.wsHandler.ServeHTTP+0x56e	/go/src/github.com/OpenBazaar/openbazaar-go/api/ws.go:148
+
+3 @ 0x42d0da 0x43c8b4 0x8e2c0e
+#	0x8e2c0d	github.com/OpenBazaar/openbazaar-go/api.(*connection).writer+0x8d	/go/src/github.com/OpenBazaar/openbazaar-go/api/ws.go:72
+
+2 @ 0x42d0da 0x42d18e
+#	0x6f1e2b	net/http.(*conn).serve+0x58b	/usr/local/go/src/net/http/server.go:1801
+`
+
+func TestCountGoroutines(t *testing.T) {
+	for match, want := range map[string]int{
+		"api.(*connection).reader": 4,
+		"api.(*connection)":        7,
+		"net/http":                 2,
+		"nothing":                  0,
+	} {
+		if got := countGoroutines([]byte(goroutineProfile), match); got != want {
+			t.Errorf("%s: expected %d goroutines, got %d", match, want, got)
+		}
+	}
+}
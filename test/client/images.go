@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ImageSizes are the sizes every avatar and header is stored in
+var ImageSizes = []string{"tiny", "small", "medium", "large", "original"}
+
+// ImageHashes are the hashes of the resized copies of an image
+type ImageHashes struct {
+	Tiny     string `json:"tiny"`
+	Small    string `json:"small"`
+	Medium   string `json:"medium"`
+	Large    string `json:"large"`
+	Original string `json:"original"`
+}
+
+// Size returns the hash of the given size
+func (h *ImageHashes) Size(size string) string {
+	switch size {
+	case "tiny":
+		return h.Tiny
+	case "small":
+		return h.Small
+	case "medium":
+		return h.Medium
+	case "large":
+		return h.Large
+	case "original":
+		return h.Original
+	}
+	return ""
+}
+
+// Profile is the part of a profile the test harness looks at
+type Profile struct {
+	PeerID       string       `json:"peerID"`
+	Name         string       `json:"name"`
+	AvatarHashes *ImageHashes `json:"avatarHashes"`
+	HeaderHashes *ImageHashes `json:"headerHashes"`
+}
+
+// SetAvatar uploads a base64 encoded image as the avatar
+func (c *Client) SetAvatar(base64Image string) (*ImageHashes, error) {
+	h := new(ImageHashes)
+	if err := c.postJSON("/ob/avatar", map[string]string{"avatar": base64Image}, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetHeader uploads a base64 encoded image as the profile header
+func (c *Client) SetHeader(base64Image string) (*ImageHashes, error) {
+	h := new(ImageHashes)
+	if err := c.postJSON("/ob/header", map[string]string{"header": base64Image}, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// UploadImage stores a base64 encoded product image under filename and
+// returns the hashes of its resized copies
+func (c *Client) UploadImage(filename, base64Image string) (*ImageHashes, error) {
+	var ret []struct {
+		Hashes ImageHashes `json:"hashes"`
+	}
+	body := []map[string]string{{"filename": filename, "image": base64Image}}
+	if err := c.postJSON("/ob/images", body, &ret); err != nil {
+		return nil, err
+	}
+	if len(ret) != 1 {
+		return nil, fmt.Errorf("uploading %s returned %d images", filename, len(ret))
+	}
+	return &ret[0].Hashes, nil
+}
+
+// Profile returns the node's own profile when peerID is empty, otherwise
+// the profile of peerID, from the node's cache if useCache is set
+func (c *Client) Profile(peerID string, useCache bool) (*Profile, error) {
+	p := "/ob/profile"
+	if peerID != "" {
+		p += "/" + peerID + "?usecache=" + strconv.FormatBool(useCache)
+	}
+	profile := new(Profile)
+	if err := c.GetJSON(p, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// CreateProfile creates the node's profile, which must not exist yet
+func (c *Client) CreateProfile(profile interface{}) error {
+	return c.postJSON("/ob/profile", profile, nil)
+}
+
+// UpdateProfile replaces the node's profile, which must exist, and
+// republishes it
+func (c *Client) UpdateProfile(profile interface{}) error {
+	resp, err := c.Put("/ob/profile", profile)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Image returns the content of an image by hash
+func (c *Client) Image(hash string) ([]byte, error) {
+	return c.GetBytes("/ob/images/" + hash)
+}
+
+// Avatar returns the avatar of peerID in the given size
+func (c *Client) Avatar(peerID, size string, useCache bool) ([]byte, error) {
+	return c.GetBytes(fmt.Sprintf("/ob/avatar/%s/%s?usecache=%t", peerID, size, useCache))
+}
+
+// Header returns the profile header of peerID in the given size
+func (c *Client) Header(peerID, size string, useCache bool) ([]byte, error) {
+	return c.GetBytes(fmt.Sprintf("/ob/header/%s/%s?usecache=%t", peerID, size, useCache))
+}
+
+// GetBytes issues a GET request and returns the raw body of a 2xx response
+func (c *Client) GetBytes(path string) ([]byte, error) {
+	resp, err := c.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
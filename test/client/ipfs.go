@@ -0,0 +1,73 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Refs lists the hashes of the blocks linked from hash through the read-only
+// IPFS API served next to the node's REST API
+func (c *Client) Refs(hash string, recursive, unique bool) ([]string, error) {
+	q := url.Values{}
+	q.Set("arg", hash)
+	q.Set("recursive", strconv.FormatBool(recursive))
+	q.Set("unique", strconv.FormatBool(unique))
+	resp, err := c.Get("/api/v0/refs?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	return decodeRefs(resp.Body)
+}
+
+// decodeRefs parses the stream of {"Ref", "Err"} objects returned by refs
+func decodeRefs(body []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	var refs []string
+	for {
+		var r struct {
+			Ref string
+			Err string
+		}
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.Err != "" {
+			return nil, errors.New(r.Err)
+		}
+		refs = append(refs, r.Ref)
+	}
+}
+
+// RootHash resolves the IPNS name of peerID, the node's own when empty, to
+// the hash of its published root directory
+func (c *Client) RootHash(peerID string) (string, error) {
+	if peerID == "" {
+		var err error
+		if peerID, err = c.PeerID(); err != nil {
+			return "", err
+		}
+	}
+	var ret struct {
+		Path string
+	}
+	if err := c.GetJSON("/api/v0/name/resolve?arg="+url.QueryEscape(peerID), &ret); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(ret.Path, "/ipfs/") {
+		return "", fmt.Errorf("%s resolved to %q", peerID, ret.Path)
+	}
+	return strings.TrimPrefix(ret.Path, "/ipfs/"), nil
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRefs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/refs" || r.URL.Query().Get("arg") != "QmRoot" || r.URL.Query().Get("unique") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("{\"Ref\":\"QmA\",\"Err\":\"\"}\n{\"Ref\":\"QmB\",\"Err\":\"\"}\n"))
+	}))
+	defer ts.Close()
+
+	refs, err := New(ts.URL).Refs("QmRoot", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(refs, []string{"QmA", "QmB"}) {
+		t.Errorf("Expected [QmA QmB], got %v", refs)
+	}
+}
+
+func TestRefsError(t *testing.T) {
+	if _, err := decodeRefs([]byte(`{"Ref":"","Err":"merkledag: not found"}`)); err == nil {
+		t.Error("Expected the ref error to be returned")
+	}
+}
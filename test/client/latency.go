@@ -0,0 +1,150 @@
+package client
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+)
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// reservoirSize bounds the samples kept per endpoint to estimate its
+// percentiles, so a long run uses constant memory
+const reservoirSize = 1024
+
+// Latencies collects response times per endpoint. One instance may be shared
+// by the clients of every node in a run.
+type Latencies struct {
+	lock      sync.Mutex
+	endpoints map[string]*endpointSamples
+	rand      *rand.Rand
+}
+
+// endpointSamples counts the requests of an endpoint per bucket and keeps a
+// uniform sample of their durations
+type endpointSamples struct {
+	count     int
+	buckets   []int
+	max       time.Duration
+	reservoir []time.Duration
+}
+
+// NewLatencies returns an empty collector
+func NewLatencies() *Latencies {
+	return &Latencies{
+		endpoints: make(map[string]*endpointSamples),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// EndpointLatency is the latency histogram of one endpoint
+type EndpointLatency struct {
+	Endpoint string
+	Count    int
+
+	// Buckets holds the number of requests per LatencyBuckets entry, plus
+	// one last bucket for slower requests. They are not cumulative.
+	Buckets []int
+
+	// The percentiles are exact up to reservoirSize requests and estimated
+	// from a uniform sample of them past that
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Observe records one request
+func (l *Latencies) Observe(method, path string, d time.Duration) {
+	key := Endpoint(method, path)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e := l.endpoints[key]
+	if e == nil {
+		e = &endpointSamples{buckets: make([]int, len(LatencyBuckets)+1)}
+		l.endpoints[key] = e
+	}
+	e.count++
+	e.buckets[sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })]++
+	if d > e.max {
+		e.max = d
+	}
+	// Reservoir sampling keeps every request with the same probability
+	if len(e.reservoir) < reservoirSize {
+		e.reservoir = append(e.reservoir, d)
+	} else if i := l.rand.Intn(e.count); i < reservoirSize {
+		e.reservoir[i] = d
+	}
+}
+
+// Endpoints returns the histogram of every endpoint, sorted by name
+func (l *Latencies) Endpoints() []EndpointLatency {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var ret []EndpointLatency
+	for endpoint, e := range l.endpoints {
+		sorted := append([]time.Duration(nil), e.reservoir...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		ret = append(ret, EndpointLatency{
+			Endpoint: endpoint,
+			Count:    e.count,
+			Buckets:  append([]int(nil), e.buckets...),
+			P50:      bench.Percentile(sorted, 50),
+			P95:      bench.Percentile(sorted, 95),
+			P99:      bench.Percentile(sorted, 99),
+			Max:      e.max,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Endpoint < ret[j].Endpoint })
+	return ret
+}
+
+// multiSegmentRoutes are the API routes naming more than one segment after
+// their namespace
+var multiSegmentRoutes = map[string]bool{
+	"/ob/peers/connect":    true,
+	"/ob/peers/disconnect": true,
+}
+
+// Endpoint names the endpoint of a request: its route, with every segment
+// after it, such as peer IDs, hashes, numbers and listing slugs, replaced by
+// :id so requests for different records share a histogram
+func Endpoint(method, path string) string {
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+	}
+	segments := strings.Split(path, "/")
+	// The leading empty segment, the namespace and the route name, except
+	// on the gateway where the namespace is the route
+	route := 3
+	switch {
+	case len(segments) > 1 && (segments[1] == "ipfs" || segments[1] == "ipns"):
+		route = 2
+	case len(segments) > 3 && multiSegmentRoutes[strings.Join(segments[:4], "/")]:
+		route = 4
+	}
+	for i := route; i < len(segments); i++ {
+		if segments[i] != "" {
+			segments[i] = ":id"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
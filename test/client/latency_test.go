@@ -0,0 +1,80 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	for path, want := range map[string]string{
+		"/ob/profile": "GET /ob/profile",
+		"/ob/listing/QmQBKFBLDuvz3NZNeSPqWCGRYH2wjxT7BQEE6CWdRkBEBf/my-slug": "GET /ob/listing/:id/:id",
+		"/ob/listing/QmQBKFBLDuvz3NZNeSPqWCGRYH2wjxT7BQEE6CWdRkBEBf/other":   "GET /ob/listing/:id/:id",
+		"/ob/listing/my-slug":                                              "GET /ob/listing/:id",
+		"/ob/followers?offsetId=abc&limit=10":                              "GET /ob/followers",
+		"/ob/marknotificationasread/42":                                    "GET /ob/marknotificationasread/:id",
+		"/ob/peers/connect":                                                "GET /ob/peers/connect",
+		"/ob/peers/QmQBKFBLDuvz3NZNeSPqWCGRYH2wjxT7BQEE6CWdRkBEBf":         "GET /ob/peers/:id",
+		"/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn/images/tiny": "GET /ipfs/:id/:id/:id",
+		"/wallet/address/":                                                 "GET /wallet/address/",
+	} {
+		if got := Endpoint("GET", path); got != want {
+			t.Errorf("Endpoint(%s) = %s, expected %s", path, got, want)
+		}
+	}
+}
+
+func TestLatencies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+	c.Latencies = NewLatencies()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get("/ob/profile"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Latencies.Observe("POST", "/ob/purchase", 20*time.Second)
+
+	endpoints := c.Latencies.Endpoints()
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(endpoints))
+	}
+	if endpoints[0].Endpoint != "GET /ob/profile" || endpoints[0].Count != 3 {
+		t.Errorf("Unexpected endpoint %+v", endpoints[0])
+	}
+	slow := endpoints[1]
+	if slow.Buckets[len(LatencyBuckets)] != 1 || slow.P99 != 20*time.Second {
+		t.Errorf("Expected the slow request in the overflow bucket, got %+v", slow)
+	}
+}
+
+func TestLatenciesBounded(t *testing.T) {
+	l := NewLatencies()
+	// Uniformly up to 10s
+	n := 10 * reservoirSize
+	for i := 1; i <= n; i++ {
+		l.Observe("GET", "/ob/profile", time.Duration(i)*10*time.Second/time.Duration(n))
+	}
+	if kept := len(l.endpoints["GET /ob/profile"].reservoir); kept != reservoirSize {
+		t.Errorf("Expected %d samples kept, got %d", reservoirSize, kept)
+	}
+	e := l.Endpoints()[0]
+	if e.Count != n || e.Max != 10*time.Second {
+		t.Errorf("Expected %d requests up to 10s, got %d up to %s", n, e.Count, e.Max)
+	}
+	total := 0
+	for _, c := range e.Buckets {
+		total += c
+	}
+	if total != n || e.Buckets[len(LatencyBuckets)-1] != n/2 {
+		t.Errorf("Expected every request counted in its bucket, got %v", e.Buckets)
+	}
+	if e.P50 < 4*time.Second || e.P50 > 6*time.Second {
+		t.Errorf("Expected a median near 5s, got %s", e.P50)
+	}
+}
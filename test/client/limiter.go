@@ -0,0 +1,60 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter caps the rate and the number of concurrent requests sent to a node
+// so bulk seeding does not overload the node under test and skew results.
+// Share one limiter between every client of the same node.
+type Limiter struct {
+	interval time.Duration
+	slots    chan struct{}
+
+	lock sync.Mutex
+	next time.Time
+}
+
+// NewLimiter allows rate requests per second with at most maxInFlight of
+// them outstanding. A non-positive value disables that cap.
+func NewLimiter(rate float64, maxInFlight int) *Limiter {
+	l := new(Limiter)
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Acquire blocks until a request may be sent and returns the function that
+// must be called once its response was read
+func (l *Limiter) Acquire() (release func()) {
+	if l.slots != nil {
+		l.slots <- struct{}{}
+	}
+	if l.interval > 0 {
+		l.lock.Lock()
+		now := time.Now()
+		wait := l.next.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		l.next = now.Add(wait + l.interval)
+		l.lock.Unlock()
+		time.Sleep(wait)
+	}
+	return func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+}
+
+// WithLimiter sets the limiter every request waits on and returns the client
+func (c *Client) WithLimiter(l *Limiter) *Client {
+	c.Limiter = l
+	return c
+}
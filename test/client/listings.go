@@ -0,0 +1,52 @@
+package client
+
+// ListingSummary is an entry of a node's listing index
+type ListingSummary struct {
+	Hash       string   `json:"hash"`
+	Slug       string   `json:"slug"`
+	Title      string   `json:"title"`
+	Categories []string `json:"categories"`
+}
+
+// Listings returns the listing index of the node, or of another peer when
+// peerID is not empty
+func (c *Client) Listings(peerID string) ([]ListingSummary, error) {
+	p := "/ob/listings"
+	if peerID != "" {
+		p += "/" + peerID
+	}
+	resp, err := c.Get(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+	var listings []ListingSummary
+	if err := resp.Decode(&listings); err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+// Listing fetches a listing of another peer by slug, resolving the peer's
+// IPNS record, or by hash, and returns the raw signed listing
+func (c *Client) Listing(peerID, slugOrHash string) ([]byte, error) {
+	return c.GetBytes("/ob/listing/" + peerID + "/" + slugOrHash)
+}
+
+// ListingTags fetches a listing of another peer like Listing and returns
+// its tags, which unlike categories are not part of the listing index
+func (c *Client) ListingTags(peerID, slugOrHash string) ([]string, error) {
+	var sl struct {
+		Listing struct {
+			Item struct {
+				Tags []string `json:"tags"`
+			} `json:"item"`
+		} `json:"listing"`
+	}
+	if err := c.GetJSON("/ob/listing/"+peerID+"/"+slugOrHash, &sl); err != nil {
+		return nil, err
+	}
+	return sl.Listing.Item.Tags, nil
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MobileProfile is how a phone app talks to its node: every request on a
+// fresh connection, with a short timeout, and nothing at all while the app
+// is in the background
+type MobileProfile struct {
+	// Timeout gives up on a request, DefaultMobileTimeout when zero
+	Timeout time.Duration
+}
+
+// DefaultMobileTimeout is what mobile HTTP stacks commonly give a request
+const DefaultMobileTimeout = 10 * time.Second
+
+// WithMobileProfile switches the client to the profile and returns it.
+// Requests go through the client's Lifecycle, which starts in the
+// foreground.
+func (c *Client) WithMobileProfile(p MobileProfile) *Client {
+	if p.Timeout == 0 {
+		p.Timeout = DefaultMobileTimeout
+	}
+	t := &http.Transport{DisableKeepAlives: true, Dial: c.dialer()}
+	c.HTTP = &http.Client{Timeout: p.Timeout, Transport: t}
+	c.Lifecycle = NewLifecycle()
+	return c
+}
+
+// Lifecycle moves a client between foreground and background like a phone
+// app. Pausing aborts the requests in flight, as suspending the app does,
+// and holds new ones until Resume. Aborted requests are sent again after
+// Resume, as apps retry what was cut off.
+type Lifecycle struct {
+	lock   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// resumed is closed when the app comes back to the foreground
+	resumed chan struct{}
+}
+
+// NewLifecycle returns a lifecycle in the foreground
+func NewLifecycle() *Lifecycle {
+	l := new(Lifecycle)
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	l.resumed = make(chan struct{})
+	close(l.resumed)
+	return l
+}
+
+// Pause sends the app to the background
+func (l *Lifecycle) Pause() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	select {
+	case <-l.resumed:
+	default:
+		return
+	}
+	l.cancel()
+	l.resumed = make(chan struct{})
+}
+
+// Resume brings the app back to the foreground
+func (l *Lifecycle) Resume() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	select {
+	case <-l.resumed:
+		return
+	default:
+	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	close(l.resumed)
+}
+
+// foreground waits until the app is in the foreground and returns a context
+// that is cancelled when it is paused next
+func (l *Lifecycle) foreground() context.Context {
+	for {
+		l.lock.Lock()
+		resumed, ctx := l.resumed, l.ctx
+		l.lock.Unlock()
+		<-resumed
+		if ctx.Err() == nil {
+			return ctx
+		}
+	}
+}
+
+// send issues req in the foreground, sending it again if a pause aborted it
+func (l *Lifecycle) send(c *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	for {
+		ctx := l.foreground()
+		r := req.WithContext(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, nil, err
+			}
+			r.Body = body
+		}
+		resp, b, err := read(c, r)
+		if err != nil && ctx.Err() != nil {
+			continue
+		}
+		return resp, b, err
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		// The first attempt hangs until the client goes away
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL).WithMobileProfile(MobileProfile{Timeout: 3 * time.Second})
+	if !c.HTTP.Transport.(*http.Transport).DisableKeepAlives {
+		t.Error("Expected a fresh connection per request")
+	}
+	type result struct {
+		resp *Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := c.Post("/echo", "hello")
+		done <- result{resp, err}
+	}()
+	for atomic.LoadInt32(&attempts) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Lifecycle.Pause()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case r := <-done:
+		t.Fatalf("Expected the request to wait while paused, got %v", r.err)
+	default:
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("Expected no request while paused, got %d attempts", n)
+	}
+	c.Lifecycle.Resume()
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if string(r.resp.Body) != "hello" {
+		t.Errorf("Expected the body to be sent again, got %q", r.resp.Body)
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("Expected 2 attempts, got %d", n)
+	}
+}
+
+func TestMobileTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL).WithMobileProfile(MobileProfile{Timeout: 100 * time.Millisecond})
+	if _, err := c.Get("/slow"); err == nil {
+		t.Error("Expected the request to time out")
+	}
+}
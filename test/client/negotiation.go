@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Negotiation is the content negotiation headers of a request. An empty
+// Accept is not sent, an empty AcceptEncoding asks for identity.
+type Negotiation struct {
+	Accept         string
+	AcceptEncoding string
+}
+
+// Negotiated is a response read with the encoding left as sent by the node
+type Negotiated struct {
+	*Response
+
+	// Encoding is the Content-Encoding of the response, empty for identity
+	Encoding string
+
+	// Raw is the body as received, Body the body after decoding it
+	Raw []byte
+}
+
+// GetNegotiated issues a GET request with the negotiation headers. Setting
+// Accept-Encoding stops the transport from decompressing transparently, so
+// the body is decoded here and a body that does not match its
+// Content-Encoding is an error.
+func (c *Client) GetNegotiated(path string, n Negotiation) (*Negotiated, error) {
+	req, err := c.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if n.Accept != "" {
+		req.Header.Set("Accept", n.Accept)
+	}
+	// An explicit identity keeps the transport from asking for gzip itself
+	enc := n.AcceptEncoding
+	if enc == "" {
+		enc = "identity"
+	}
+	req.Header.Set("Accept-Encoding", enc)
+	resp, err := c.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Negotiated{Response: resp, Raw: resp.Body, Encoding: resp.Header.Get("Content-Encoding")}
+	decoded, err := decodeBody(ret.Encoding, ret.Raw)
+	if err != nil {
+		return nil, err
+	}
+	ret.Response = &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: decoded}
+	return ret, nil
+}
+
+// Check returns an error if the response breaks the negotiation: an
+// encoding that was not accepted, a compressed body without Vary, or a
+// successful body that is not the JSON its Content-Type claims
+func (n *Negotiated) Check(req Negotiation) error {
+	if n.Encoding != "" && n.Encoding != "identity" {
+		if !AcceptsEncoding(req.AcceptEncoding, n.Encoding) {
+			return fmt.Errorf("response encoded with %s, request accepted %q", n.Encoding, req.AcceptEncoding)
+		}
+		if !strings.Contains(strings.ToLower(n.Header.Get("Vary")), "accept-encoding") {
+			return fmt.Errorf("response encoded with %s without Vary: Accept-Encoding", n.Encoding)
+		}
+	}
+	if !n.OK() {
+		return nil
+	}
+	ct, _, err := mime.ParseMediaType(n.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("bad Content-Type %q: %s", n.Header.Get("Content-Type"), err)
+	}
+	if ct == "application/json" {
+		var v interface{}
+		if err := json.Unmarshal(n.Body, &v); err != nil {
+			return fmt.Errorf("body labelled %s is not valid JSON: %s", ct, err)
+		}
+	}
+	return nil
+}
+
+// AcceptsEncoding reports whether an Accept-Encoding header value allows the
+// coding, honouring q=0 and the * wildcard
+func AcceptsEncoding(header, coding string) bool {
+	coding = strings.ToLower(coding)
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		allowed := true
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(p[len("q="):], 64); err == nil && q == 0 {
+				allowed = false
+			}
+		}
+		switch name {
+		case coding:
+			return allowed
+		case "*":
+			wildcard = allowed
+		}
+	}
+	return wildcard
+}
+
+// decodeBody undoes a Content-Encoding
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("body marked gzip does not decode: %s", err)
+		}
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("body marked gzip does not decode: %s", err)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+}
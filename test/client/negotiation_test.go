@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header, coding string
+		want           bool
+	}{
+		{"gzip", "gzip", true},
+		{"GZIP, deflate", "gzip", true},
+		{"identity", "gzip", false},
+		{"gzip;q=0, identity", "gzip", false},
+		{"gzip; q=0.000", "gzip", false},
+		{"gzip;q=0.5", "gzip", true},
+		{"*", "br", true},
+		{"*;q=0, gzip", "br", false},
+		{"", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := AcceptsEncoding(tt.header, tt.coding); got != tt.want {
+			t.Errorf("AcceptsEncoding(%q, %q) = %t, expected %t", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
+
+func negotiationServer(gzipped, vary bool, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !gzipped || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte(body))
+			return
+		}
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		gz.Write([]byte(body))
+		gz.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		if vary {
+			w.Header().Set("Vary", "Accept-Encoding")
+		}
+		w.Write(b.Bytes())
+	}))
+}
+
+func TestGetNegotiatedGzip(t *testing.T) {
+	ts := negotiationServer(true, true, `{"ok":true}`)
+	defer ts.Close()
+
+	req := Negotiation{AcceptEncoding: "gzip"}
+	n, err := New(ts.URL).GetNegotiated("/ob/config", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Encoding != "gzip" || bytes.Equal(n.Raw, n.Body) {
+		t.Fatal("Expected a gzip encoded body")
+	}
+	if string(n.Body) != `{"ok":true}` {
+		t.Errorf("Unexpected decoded body %s", n.Body)
+	}
+	if err := n.Check(req); err != nil {
+		t.Error(err)
+	}
+	if err := n.Check(Negotiation{AcceptEncoding: "identity"}); err == nil {
+		t.Error("Expected an error for an encoding that was not accepted")
+	}
+
+	n, err = New(ts.URL).GetNegotiated("/ob/config", Negotiation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Encoding != "" {
+		t.Errorf("Expected identity when no encoding is accepted, got %s", n.Encoding)
+	}
+}
+
+func TestNegotiatedCheck(t *testing.T) {
+	ts := negotiationServer(true, false, `{"ok":true}`)
+	defer ts.Close()
+	req := Negotiation{AcceptEncoding: "gzip"}
+	n, err := New(ts.URL).GetNegotiated("/", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Check(req); err == nil {
+		t.Error("Expected an error for a compressed response without Vary")
+	}
+
+	ts = negotiationServer(false, false, `<html>`)
+	defer ts.Close()
+	n, err = New(ts.URL).GetNegotiated("/", Negotiation{Accept: "text/html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Check(Negotiation{Accept: "text/html"}); err == nil {
+		t.Error("Expected an error for HTML labelled as JSON")
+	}
+}
+
+func TestGetNegotiatedBadGzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+	if _, err := New(ts.URL).GetNegotiated("/", Negotiation{AcceptEncoding: "gzip"}); err == nil {
+		t.Error("Expected an error for a plain body marked gzip")
+	}
+}
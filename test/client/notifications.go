@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Notification is a stored notification as returned by GET /ob/notifications
+type Notification struct {
+	ID      string `json:"notificationId"`
+	Type    string `json:"type"`
+	PeerID  string `json:"peerId"`
+	OrderID string `json:"orderId"`
+	Read    bool   `json:"-"`
+}
+
+// Notifications returns every stored notification, newest first, and the
+// number of unread ones
+func (c *Client) Notifications() ([]Notification, int, error) {
+	var resp struct {
+		Unread        int `json:"unread"`
+		Notifications []struct {
+			Notification Notification `json:"notification"`
+			Read         bool         `json:"read"`
+		} `json:"notifications"`
+	}
+	if err := c.GetJSON("/ob/notifications?limit=-1", &resp); err != nil {
+		return nil, 0, err
+	}
+	ret := make([]Notification, len(resp.Notifications))
+	for i, n := range resp.Notifications {
+		ret[i] = n.Notification
+		ret[i].Read = n.Read
+	}
+	return ret, resp.Unread, nil
+}
+
+// MarkNotificationRead marks a single notification as read
+func (c *Client) MarkNotificationRead(id string) error {
+	return c.postJSON("/ob/marknotificationasread/"+id, nil, nil)
+}
+
+// Socket is a subscription to the node's websocket
+type Socket struct {
+	conn *websocket.Conn
+}
+
+// Subscribe opens the node's websocket with the client's credentials
+func (c *Client) Subscribe() (*Socket, error) {
+	u := "ws" + strings.TrimPrefix(c.BaseURL, "http") + "/ws"
+	header := http.Header{}
+	if c.Username != "" {
+		req, _ := http.NewRequest("GET", u, nil)
+		req.SetBasicAuth(c.Username, c.Password)
+		header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+	if c.Cookie != nil {
+		header.Set("Cookie", c.Cookie.String())
+	}
+	dialer := websocket.DefaultDialer
+	if dial := c.dialer(); dial != nil {
+		dialer = &websocket.Dialer{NetDial: dial}
+	}
+	conn, _, err := dialer.Dial(u, header)
+	if err != nil {
+		return nil, err
+	}
+	return &Socket{conn: conn}, nil
+}
+
+// Next returns the next message pushed by the node, or an error once the
+// timeout passes or the connection drops
+func (s *Socket) Next(timeout time.Duration) ([]byte, error) {
+	s.conn.SetReadDeadline(time.Now().Add(timeout))
+	_, msg, err := s.conn.ReadMessage()
+	return msg, err
+}
+
+// NextNotification skips other messages until a notification arrives
+func (s *Socket) NextNotification(timeout time.Duration) (*Notification, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := s.Next(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		var wrapper struct {
+			Notification *Notification `json:"notification"`
+		}
+		if json.Unmarshal(msg, &wrapper) == nil && wrapper.Notification != nil {
+			return wrapper.Notification, nil
+		}
+	}
+}
+
+// Close closes the subscription
+func (s *Socket) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,346 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PurchaseResponse is returned by POST /ob/purchase
+type PurchaseResponse struct {
+	PaymentAddress string `json:"paymentAddress"`
+	Amount         uint64 `json:"amount"`
+	VendorOnline   bool   `json:"vendorOnline"`
+	OrderID        string `json:"orderId"`
+}
+
+// PeerID returns the node's own peer ID as reported by GET /ob/config
+func (c *Client) PeerID() (string, error) {
+	var cfg struct {
+		PeerID string `json:"peerID"`
+	}
+	if err := c.GetJSON("/ob/config", &cfg); err != nil {
+		return "", err
+	}
+	return cfg.PeerID, nil
+}
+
+// Features returns the experimental features enabled on the node
+func (c *Client) Features() ([]string, error) {
+	var cfg struct {
+		Features []string `json:"features"`
+	}
+	if err := c.GetJSON("/ob/config", &cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Features, nil
+}
+
+// Config is what GET /ob/config reports about the node
+type Config struct {
+	PeerID         string   `json:"peerID"`
+	CryptoCurrency string   `json:"cryptoCurrency"`
+	Testnet        bool     `json:"testnet"`
+	Tor            bool     `json:"tor"`
+	Features       []string `json:"features"`
+}
+
+// Config returns the node's configuration
+func (c *Client) Config() (*Config, error) {
+	cfg := new(Config)
+	if err := c.GetJSON("/ob/config", cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// CreateListing posts a listing and returns its slug
+func (c *Client) CreateListing(listing interface{}) (string, error) {
+	var ret struct {
+		Slug string `json:"slug"`
+	}
+	if err := c.postJSON("/ob/listing", listing, &ret); err != nil {
+		return "", err
+	}
+	return ret.Slug, nil
+}
+
+// UpdateListing replaces the listing of the slug in the body and re-signs it
+func (c *Client) UpdateListing(listing interface{}) error {
+	resp, err := c.Put("/ob/listing", listing)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// SetModerator publishes moderator settings, making the node a moderator
+func (c *Client) SetModerator(settings interface{}) error {
+	resp, err := c.Put("/ob/moderator", settings)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// StopModerating withdraws the node's moderator settings
+func (c *Client) StopModerating() error {
+	resp, err := c.Delete("/ob/moderator")
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// Purchase places an order
+func (c *Client) Purchase(order interface{}) (*PurchaseResponse, error) {
+	ret := new(PurchaseResponse)
+	if err := c.postJSON("/ob/purchase", order, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Spend sends amount satoshis from the node's wallet to address
+func (c *Client) Spend(address string, amount uint64) error {
+	return c.postJSON("/wallet/spend", map[string]interface{}{
+		"address":  address,
+		"amount":   amount,
+		"feeLevel": "NORMAL",
+	}, nil)
+}
+
+// OrderState returns the state of an order as reported by GET /ob/order
+func (c *Client) OrderState(orderID string) (string, error) {
+	var order struct {
+		State string `json:"state"`
+	}
+	if err := c.GetJSON("/ob/order/"+orderID, &order); err != nil {
+		return "", err
+	}
+	return order.State, nil
+}
+
+// CaseState returns the state of a dispute as reported by GET /ob/case on
+// the moderator
+func (c *Client) CaseState(orderID string) (string, error) {
+	var cs struct {
+		State string `json:"state"`
+	}
+	if err := c.GetJSON("/ob/case/"+orderID, &cs); err != nil {
+		return "", err
+	}
+	return cs.State, nil
+}
+
+// CaseClaim returns the claim of a dispute on the moderator and whether the
+// buyer opened it
+func (c *Client) CaseClaim(orderID string) (claim string, buyerOpened bool, err error) {
+	var cs struct {
+		Claim       string `json:"claim"`
+		BuyerOpened bool   `json:"buyerOpened"`
+	}
+	if err := c.GetJSON("/ob/case/"+orderID, &cs); err != nil {
+		return "", false, err
+	}
+	return cs.Claim, cs.BuyerOpened, nil
+}
+
+// DisputeClaim returns the claim of the dispute in the node's copy of the
+// order contract, empty when the order is not disputed
+func (c *Client) DisputeClaim(orderID string) (string, error) {
+	var order struct {
+		Contract struct {
+			Dispute struct {
+				Claim string `json:"claim"`
+			} `json:"dispute"`
+		} `json:"contract"`
+	}
+	if err := c.GetJSON("/ob/order/"+orderID, &order); err != nil {
+		return "", err
+	}
+	return order.Contract.Dispute.Claim, nil
+}
+
+// Case is a dispute as listed by GET /ob/cases on the moderator. The case ID
+// is the ID of the disputed order.
+type Case struct {
+	ID          string `json:"caseId"`
+	State       string `json:"state"`
+	BuyerOpened bool   `json:"buyerOpened"`
+}
+
+// Cases returns every dispute the moderator has on file
+func (c *Client) Cases() ([]Case, error) {
+	var resp struct {
+		Cases []Case `json:"cases"`
+	}
+	if err := c.GetJSON("/ob/cases", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Cases, nil
+}
+
+// Orders returns the IDs of every purchase and sale on the node
+func (c *Client) Orders() ([]string, error) {
+	var purchases struct {
+		Purchases []struct {
+			OrderID string `json:"orderId"`
+		} `json:"purchases"`
+	}
+	if err := c.GetJSON("/ob/purchases", &purchases); err != nil {
+		return nil, err
+	}
+	var sales struct {
+		Sales []struct {
+			OrderID string `json:"orderId"`
+		} `json:"sales"`
+	}
+	if err := c.GetJSON("/ob/sales", &sales); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, p := range purchases.Purchases {
+		ids = append(ids, p.OrderID)
+	}
+	for _, s := range sales.Sales {
+		ids = append(ids, s.OrderID)
+	}
+	return ids, nil
+}
+
+// Transaction is a row of the node's purchases or sales
+type Transaction struct {
+	OrderID   string `json:"orderId"`
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+	Total     uint64 `json:"total"`
+	BuyerID   string `json:"buyerId"`
+	VendorID  string `json:"vendorId"`
+	State     string `json:"state"`
+	Moderated bool   `json:"moderated"`
+}
+
+// Purchases returns every purchase as listed by GET /ob/purchases
+func (c *Client) Purchases() ([]Transaction, error) {
+	var resp struct {
+		Purchases []Transaction `json:"purchases"`
+	}
+	if err := c.GetJSON("/ob/purchases", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Purchases, nil
+}
+
+// Sales returns every sale as listed by GET /ob/sales
+func (c *Client) Sales() ([]Transaction, error) {
+	var resp struct {
+		Sales []Transaction `json:"sales"`
+	}
+	if err := c.GetJSON("/ob/sales", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sales, nil
+}
+
+// Order returns the raw JSON of an order as reported by GET /ob/order
+func (c *Client) Order(orderID string) ([]byte, error) {
+	return c.GetBytes("/ob/order/" + orderID)
+}
+
+// WaitOrderState polls the order until it reaches want, returning an error
+// naming the last seen state if ctx is done first
+func (c *Client) WaitOrderState(ctx context.Context, orderID, want string) error {
+	return c.wait(ctx, orderID, want, c.OrderState)
+}
+
+// WaitCaseState polls the moderator's case until it reaches want
+func (c *Client) WaitCaseState(ctx context.Context, orderID, want string) error {
+	return c.wait(ctx, orderID, want, c.CaseState)
+}
+
+func (c *Client) wait(ctx context.Context, orderID, want string, state func(string) (string, error)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var last string
+	var lastErr error
+	for {
+		last, lastErr = state(orderID)
+		if lastErr == nil && last == want {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("order %s on %s never reached %s: %s", orderID, c.BaseURL, want, lastErr)
+			}
+			return fmt.Errorf("order %s on %s stuck in %s, expected %s", orderID, c.BaseURL, last, want)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ConfirmOrder confirms a PENDING order as the vendor, or rejects it
+func (c *Client) ConfirmOrder(orderID string, reject bool) error {
+	return c.postJSON("/ob/orderconfirmation", map[string]interface{}{"orderId": orderID, "reject": reject}, nil)
+}
+
+// CancelOrder cancels a PENDING direct order as the buyer
+func (c *Client) CancelOrder(orderID string) error {
+	return c.postJSON("/ob/ordercancel", map[string]string{"orderId": orderID}, nil)
+}
+
+// RefundOrder refunds the buyer as the vendor
+func (c *Client) RefundOrder(orderID string) error {
+	return c.postJSON("/ob/refund", map[string]string{"orderId": orderID}, nil)
+}
+
+// FulfillOrder sends a fulfillment as the vendor
+func (c *Client) FulfillOrder(fulfillment interface{}) error {
+	return c.postJSON("/ob/orderfulfillment", fulfillment, nil)
+}
+
+// CompleteOrder completes a fulfilled order as the buyer
+func (c *Client) CompleteOrder(completion interface{}) error {
+	return c.postJSON("/ob/ordercompletion", completion, nil)
+}
+
+// ReleaseEscrow has the vendor claim the funds of a fulfilled moderated
+// order once the escrow timeout has passed
+func (c *Client) ReleaseEscrow(orderID string) error {
+	return c.postJSON("/ob/releaseescrow", map[string]string{"orderId": orderID}, nil)
+}
+
+// OpenDispute opens a dispute on a moderated order
+func (c *Client) OpenDispute(orderID, claim string) error {
+	return c.postJSON("/ob/opendispute", map[string]string{"orderId": orderID, "claim": claim}, nil)
+}
+
+// CloseDispute resolves a dispute as the moderator
+func (c *Client) CloseDispute(orderID, resolution string, buyerPercentage, vendorPercentage float32) error {
+	return c.postJSON("/ob/closedispute", CloseDisputeRequest(orderID, resolution, buyerPercentage, vendorPercentage), nil)
+}
+
+// CloseDisputeRequest returns the body of a POST /ob/closedispute
+func CloseDisputeRequest(orderID, resolution string, buyerPercentage, vendorPercentage float32) map[string]interface{} {
+	return map[string]interface{}{
+		"OrderID":          orderID,
+		"Resolution":       resolution,
+		"BuyerPercentage":  buyerPercentage,
+		"VendorPercentage": vendorPercentage,
+	}
+}
+
+func (c *Client) postJSON(path string, body, v interface{}) error {
+	resp, err := c.Post(path, body)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	return resp.Decode(v)
+}
@@ -0,0 +1,61 @@
+package client
+
+import "net/http"
+
+// EnsureSettings creates empty settings on a node that has none yet, which
+// endpoints storing into the settings, such as blocking a node, need
+func (c *Client) EnsureSettings() error {
+	resp, err := c.Get("/ob/settings")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return resp.Err()
+	}
+	return c.postJSON("/ob/settings", map[string]interface{}{}, nil)
+}
+
+// BlockNode adds peerID to the node's blocked nodes, whose messages it drops
+func (c *Client) BlockNode(peerID string) error {
+	return c.postJSON("/ob/blocknode/"+peerID, nil, nil)
+}
+
+// UnblockNode removes peerID from the node's blocked nodes
+func (c *Client) UnblockNode(peerID string) error {
+	resp, err := c.Delete("/ob/blocknode/" + peerID)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// BlockedNodes returns the peer IDs stored as blocked in the settings
+func (c *Client) BlockedNodes() ([]string, error) {
+	var settings struct {
+		BlockedNodes []string `json:"blockedNodes"`
+	}
+	if err := c.GetJSON("/ob/settings", &settings); err != nil {
+		return nil, err
+	}
+	return settings.BlockedNodes, nil
+}
+
+// SMTPSettings configure the email notifications a node sends
+type SMTPSettings struct {
+	Notifications  bool   `json:"notifications"`
+	ServerAddress  string `json:"serverAddress"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	SenderEmail    string `json:"senderEmail"`
+	RecipientEmail string `json:"recipientEmail"`
+}
+
+// SetSMTP stores the email notification settings, leaving the other
+// settings as they are. The node must have settings, see EnsureSettings.
+func (c *Client) SetSMTP(s SMTPSettings) error {
+	resp, err := c.Patch("/ob/settings", map[string]interface{}{"smtpSettings": s})
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
@@ -0,0 +1,29 @@
+package client
+
+// Follow starts following peerID
+func (c *Client) Follow(peerID string) error {
+	return c.postJSON("/ob/follow", map[string]string{"id": peerID}, nil)
+}
+
+// Followers returns the peer IDs following the node
+func (c *Client) Followers() ([]string, error) {
+	var followers []string
+	if err := c.GetJSON("/ob/followers", &followers); err != nil {
+		return nil, err
+	}
+	return followers, nil
+}
+
+// Following returns the peer IDs the node follows
+func (c *Client) Following() ([]string, error) {
+	var following []string
+	if err := c.GetJSON("/ob/following", &following); err != nil {
+		return nil, err
+	}
+	return following, nil
+}
+
+// Unfollow stops following peerID
+func (c *Client) Unfollow(peerID string) error {
+	return c.postJSON("/ob/unfollow", map[string]string{"id": peerID}, nil)
+}
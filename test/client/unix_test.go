@@ -0,0 +1,41 @@
+package client
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "api.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"peerID": "QmUnix"}`))
+	}))
+
+	c := NewUnix(sock)
+	var cfg struct {
+		PeerID string `json:"peerID"`
+	}
+	if err := c.GetJSON("/ob/config", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PeerID != "QmUnix" {
+		t.Errorf("Expected QmUnix, got %s", cfg.PeerID)
+	}
+	if err := c.WithTimeout(DefaultTimeout).GetJSON("/ob/config", &cfg); err != nil {
+		t.Errorf("Expected a copy of the client to keep using the socket: %s", err)
+	}
+}
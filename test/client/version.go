@@ -0,0 +1,47 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AtLeast reports whether the node runs version or a later release. Nodes
+// of unknown version are assumed to be current.
+func (c *Client) AtLeast(version string) bool {
+	return c.Version == "" || CompareVersions(c.Version, version) >= 0
+}
+
+// CompareVersions orders dotted release versions like 0.12.4 numerically,
+// returning -1, 0 or 1. A leading v and any -suffix are ignored.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,28 @@
+package client
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"0.12.4", "0.13.0", -1},
+		{"0.13.0", "0.12.4", 1},
+		{"v0.13", "0.13.0", 0},
+		{"0.10.0", "0.9.9", 1},
+		{"0.13.0-rc1", "0.13.0", 0},
+	} {
+		if got := CompareVersions(c.a, c.b); got != c.want {
+			t.Errorf("CompareVersions(%s, %s): expected %d, got %d", c.a, c.b, c.want, got)
+		}
+	}
+	c := New("http://127.0.0.1")
+	if !c.AtLeast("9.9.9") {
+		t.Error("Expected a node of unknown version to be current")
+	}
+	c.Version = "0.12.4"
+	if c.AtLeast("0.13.0") || !c.AtLeast("0.12") {
+		t.Error("Expected 0.12.4 to be at least 0.12 but not 0.13.0")
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+// Balance is the wallet balance in satoshis as reported by GET /wallet/balance
+type Balance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// Total returns the confirmed and unconfirmed balance
+func (b Balance) Total() int64 {
+	return b.Confirmed + b.Unconfirmed
+}
+
+// WalletAddress returns the current receiving address of the node's wallet
+func (c *Client) WalletAddress() (string, error) {
+	var ret struct {
+		Address string `json:"address"`
+	}
+	if err := c.GetJSON("/wallet/address", &ret); err != nil {
+		return "", err
+	}
+	return ret.Address, nil
+}
+
+// Mnemonic returns the seed the node's wallet keys are derived from
+func (c *Client) Mnemonic() (string, error) {
+	var ret struct {
+		Mnemonic string `json:"mnemonic"`
+	}
+	if err := c.GetJSON("/wallet/mnemonic", &ret); err != nil {
+		return "", err
+	}
+	return ret.Mnemonic, nil
+}
+
+// Balance returns the balance of the node's wallet
+func (c *Client) Balance() (Balance, error) {
+	var b Balance
+	err := c.GetJSON("/wallet/balance", &b)
+	return b, err
+}
+
+// CoinAddress returns the current receiving address of the node's wallet
+// for coin, on releases running a wallet per coin
+func (c *Client) CoinAddress(coin string) (string, error) {
+	var ret struct {
+		Address string `json:"address"`
+	}
+	if err := c.GetJSON("/wallet/address/"+coin, &ret); err != nil {
+		return "", err
+	}
+	return ret.Address, nil
+}
+
+// CoinBalance returns the balance of the node's wallet for coin, on
+// releases running a wallet per coin
+func (c *Client) CoinBalance(coin string) (Balance, error) {
+	var b Balance
+	err := c.GetJSON("/wallet/balance/"+coin, &b)
+	return b, err
+}
+
+// SpendCoin sends amount of the smallest unit of coin from the node's
+// wallet for it to address, on releases running a wallet per coin
+func (c *Client) SpendCoin(coin, address string, amount uint64) error {
+	return c.postJSON("/wallet/spend", map[string]interface{}{
+		"wallet":   coin,
+		"address":  address,
+		"amount":   amount,
+		"feeLevel": "NORMAL",
+	}, nil)
+}
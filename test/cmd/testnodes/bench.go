@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+	"github.com/OpenBazaar/openbazaar-go/test/startup"
+)
+
+type Bench struct{}
+
+type BenchCheckout struct {
+	Nodes    []string      `short:"n" long:"node" description:"a node to run against as role=url or role:name=url, needs a vendor and a buyer"`
+	Username string        `short:"u" long:"username" description:"API username"`
+	Password string        `short:"p" long:"password" description:"API password"`
+	Runs     int           `short:"r" long:"runs" default:"100" description:"number of checkouts to measure"`
+	Timeout  time.Duration `short:"t" long:"timeout" default:"5m" description:"give up on a single checkout after this long"`
+	SLO      string        `long:"slo" description:"JSON file with thresholds overriding the committed ones"`
+}
+
+func (x *BenchCheckout) Execute(args []string) error {
+	slos := bench.SLOs
+	if x.SLO != "" {
+		var err error
+		if slos, err = bench.LoadSLOs(x.SLO); err != nil {
+			return err
+		}
+	}
+	slo, ok := slos["checkout"]
+	if !ok {
+		return errors.New("no objective for checkout")
+	}
+	net, err := attach(x.Nodes, x.Username, x.Password)
+	if err != nil {
+		return err
+	}
+	vendors, buyers := net.Role("vendor"), net.Role("buyer")
+	if len(vendors) == 0 || len(buyers) == 0 {
+		return errors.New("checkout benchmark needs a vendor and a buyer node")
+	}
+	stamp("", 0, net)
+
+	summary := bench.Measure("checkout", x.Runs, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+		defer cancel()
+		_, err := harness.Checkout(ctx, vendors[0], buyers[0])
+		return err
+	}, func(run int, d time.Duration, err error) {
+		if err != nil {
+			fmt.Printf("run %d failed after %s: %s\n", run, d.Round(time.Millisecond), err)
+		}
+	})
+	fmt.Println(summary)
+	return slo.Check(summary)
+}
+
+type BenchPropagation struct {
+	Nodes    []string      `short:"n" long:"node" description:"a node to run against as role=url or role:name=url, the first vendor publishes and every other node observes"`
+	Username string        `short:"u" long:"username" description:"API username"`
+	Password string        `short:"p" long:"password" description:"API password"`
+	Sizes    []int         `short:"s" long:"size" default:"5" default:"20" default:"50" description:"network sizes to measure, including the vendor, may be repeated"`
+	Runs     int           `short:"r" long:"runs" default:"3" description:"listings published per network size"`
+	Timeout  time.Duration `short:"t" long:"timeout" default:"10m" description:"give up on a listing after this long"`
+	Interval time.Duration `short:"i" long:"interval" default:"500ms" description:"how often observers poll for the listing"`
+	Out      string        `short:"o" long:"out" default:"." description:"directory the CSV is written to, headed by a # manifest line"`
+}
+
+func (x *BenchPropagation) Execute(args []string) error {
+	net, err := attach(x.Nodes, x.Username, x.Password)
+	if err != nil {
+		return err
+	}
+	vendors := net.Role("vendor")
+	if len(vendors) == 0 {
+		return errors.New("propagation benchmark needs a vendor node")
+	}
+	vendor := vendors[0]
+	var observers []harness.Node
+	for _, n := range net.Nodes {
+		if n != vendor {
+			observers = append(observers, n)
+		}
+	}
+	for _, size := range x.Sizes {
+		if size < 2 || size-1 > len(observers) {
+			return fmt.Errorf("network size %d needs %d observers, got %d", size, size-1, len(observers))
+		}
+	}
+
+	name := filepath.Join(x.Out, fmt.Sprintf("propagation-%s.csv", time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := stamp("", 0, net).WriteComment(f); err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"size", "run", "slug", "observer", "resolved", "seconds"})
+
+	for _, size := range x.Sizes {
+		var durations []time.Duration
+		failures := 0
+		for run := 1; run <= x.Runs; run++ {
+			ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+			p, err := harness.ListingPropagation(ctx, vendor, observers[:size-1], x.Interval)
+			cancel()
+			if err != nil {
+				return err
+			}
+			for _, o := range p.Observers {
+				d, ok := p.Resolved[o]
+				if !ok {
+					failures++
+					d = x.Timeout
+				} else {
+					durations = append(durations, d)
+				}
+				w.Write([]string{strconv.Itoa(size), strconv.Itoa(run), p.Slug, o, strconv.FormatBool(ok), strconv.FormatFloat(d.Seconds(), 'f', 3, 64)})
+			}
+			w.Flush()
+		}
+		fmt.Println(bench.Summarize(fmt.Sprintf("propagation/%d", size), durations, failures))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	fmt.Println("wrote", name)
+	return nil
+}
+
+type BenchStartup struct {
+	Binary    string        `short:"b" long:"binary" required:"true" description:"the openbazaard binary to boot"`
+	Sizes     []string      `short:"s" long:"size" description:"repo sizes to measure, default all of empty, 1k-listings and 10k-orders"`
+	Runs      int           `short:"r" long:"runs" default:"3" description:"boots per repo size, the median is compared"`
+	Testnet   bool          `long:"testnet" description:"create testnet repos"`
+	Baseline  string        `long:"baseline" default:"startup-baseline.json" description:"JSON file with the accepted boot times"`
+	Tolerance float64       `long:"tolerance" default:"0.2" description:"how much slower than the baseline a boot may be, as a fraction"`
+	Update    bool          `long:"update" description:"record the measured times as the new baseline instead of comparing"`
+	Timeout   time.Duration `short:"t" long:"timeout" default:"30m" description:"give up on a repo size after this long"`
+}
+
+func (x *BenchStartup) Execute(args []string) error {
+	sizes, err := x.sizes()
+	if err != nil {
+		return err
+	}
+	m := manifest.New(time.Now().UTC().Format(time.RFC3339), "local")
+	if err := m.AddBinary(x.Binary); err != nil {
+		return err
+	}
+	fmt.Println(m.Stamp())
+	baseline, err := startup.LoadBaseline(x.Baseline)
+	if os.IsNotExist(err) {
+		fmt.Printf("no baseline at %s, recording one\n", x.Baseline)
+		baseline, x.Update = make(startup.Baseline), true
+	} else if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, size := range sizes {
+		var timings []startup.Timing
+		for run := 0; run < x.Runs; run++ {
+			t, err := x.measure(size)
+			if err != nil {
+				return fmt.Errorf("%s: %s", size.Name, err)
+			}
+			timings = append(timings, t)
+		}
+		t := startup.Median(timings)
+		fmt.Printf("%s: init %s, cold boot %s, cold start %s, warm restart %s\n", size.Name,
+			t.Init.Round(time.Millisecond), t.Cold.Round(time.Millisecond), t.ColdStart().Round(time.Millisecond), t.Warm.Round(time.Millisecond))
+		if x.Update {
+			baseline.Record(size.Name, t)
+		} else if err := baseline.Check(size.Name, t, x.Tolerance); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if x.Update {
+		return baseline.Save(x.Baseline)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("startup regressed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (x *BenchStartup) measure(size startup.RepoSize) (startup.Timing, error) {
+	dir, err := ioutil.TempDir("", "ob-startup-"+size.Name+"-")
+	if err != nil {
+		return startup.Timing{}, err
+	}
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	return startup.Measure(ctx, x.Binary, dir, size, x.Testnet)
+}
+
+func (x *BenchStartup) sizes() ([]startup.RepoSize, error) {
+	if len(x.Sizes) == 0 {
+		return startup.Sizes, nil
+	}
+	var ret []startup.RepoSize
+	for _, name := range x.Sizes {
+		found := false
+		for _, s := range startup.Sizes {
+			if s.Name == name {
+				ret = append(ret, s)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown repo size %q", name)
+		}
+	}
+	return ret, nil
+}
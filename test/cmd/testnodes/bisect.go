@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bisect"
+	"github.com/OpenBazaar/openbazaar-go/test/build"
+	"github.com/OpenBazaar/openbazaar-go/test/chain"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+type Bisect struct {
+	Scenario string        `long:"scenario" required:"true" description:"the scenario to bisect with, or a pattern matching exactly one, e.g. 'regression/stuck-*'"`
+	Good     string        `long:"good" required:"true" description:"a revision the scenario passes at, e.g. v0.12.0"`
+	Bad      string        `long:"bad" default:"HEAD" description:"a revision the scenario fails at"`
+	Repo     string        `long:"repo" description:"the openbazaar-go checkout to build from, the current directory by default"`
+	Cache    string        `long:"cache" description:"where built binaries are kept by commit, openbazaar-testnodes/builds in the user's cache dir by default"`
+	Vendors  int           `long:"vendors" default:"1" description:"vendors in each network"`
+	Buyers   int           `long:"buyers" default:"1" description:"buyers in each network"`
+	Runs     int           `long:"runs" default:"1" description:"run the scenario this many times on each commit, which is bad if any run fails, to catch flaky regressions"`
+	Seed     int64         `long:"seed" description:"seed the random fixtures of every run with this, the time of each by default"`
+	Bitcoind string        `long:"bitcoind" description:"the bitcoind running the regtest chain of each network's wallets, one on the PATH or a downloaded release by default"`
+	Timeout  time.Duration `short:"t" long:"timeout" default:"30m" description:"give up on each run after this long"`
+}
+
+func (x *Bisect) Execute(args []string) error {
+	scenarios, err := match([]string{x.Scenario})
+	if err != nil {
+		return err
+	}
+	if len(scenarios) != 1 {
+		return fmt.Errorf("%q matches %d scenarios, bisect with one", x.Scenario, len(scenarios))
+	}
+	if x.Vendors < 1 && x.Buyers < 1 {
+		return errors.New("networks need at least one node")
+	}
+	if x.Runs < 1 {
+		x.Runs = 1
+	}
+	b := &build.Builder{Repo: x.Repo, Cache: x.Cache, Log: os.Stderr}
+	commits, err := b.Commits(x.Good, x.Bad)
+	if err != nil {
+		return err
+	}
+	test := func(commit string) (bisect.Verdict, error) {
+		return x.test(b, commit, scenarios[0])
+	}
+
+	// Check the ends, or the search blames a commit for nothing
+	for _, end := range []struct {
+		rev  string
+		want bisect.Verdict
+	}{{x.Bad, bisect.Bad}, {x.Good, bisect.Good}} {
+		v, err := test(end.rev)
+		if err != nil {
+			return err
+		}
+		if v != end.want {
+			return fmt.Errorf("%s is %s, not %s", end.rev, v, end.want)
+		}
+	}
+	fmt.Printf("bisecting %d commits between %s and %s with %s\n", len(commits), x.Good, x.Bad, scenarios[0].Name)
+	r, err := bisect.Search(commits, test)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	for _, s := range r.Steps {
+		fmt.Printf("%-5s %s\n", s.Verdict, s.Commit)
+	}
+	if r.First != "" {
+		fmt.Printf("\n%s is the first bad commit\n", r.First)
+		return nil
+	}
+	fmt.Println("\ncommits that could not be tested leave the first bad commit among:")
+	for _, c := range r.Candidates {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// test builds the commit and runs the scenario on a fresh network of it.
+// Commits that do not build or whose nodes do not start are skipped.
+func (x *Bisect) test(b *build.Builder, commit string, s harness.Scenario) (bisect.Verdict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	binary, err := b.Build(ctx, commit)
+	if err != nil {
+		fmt.Printf("skip  %s: %s\n", commit, err)
+		return bisect.Skip, nil
+	}
+	for i := 0; i < x.Runs; i++ {
+		r, err := x.run(ctx, binary, s)
+		if err != nil {
+			fmt.Printf("skip  %s: %s\n", commit, err)
+			return bisect.Skip, nil
+		}
+		fmt.Printf("%s: %s\n", commit, r)
+		switch {
+		case r.Skipped != "":
+			return bisect.Skip, fmt.Errorf("%s skipped the scenario: %s", commit, r.Skipped)
+		case r.Err != nil:
+			return bisect.Bad, nil
+		}
+	}
+	return bisect.Good, nil
+}
+
+// run runs the scenario once on a fresh network of binary, its wallets on a
+// regtest chain of their own. Unlike the mock wallet, regtest wallets work
+// with the binaries of old commits.
+func (x *Bisect) run(ctx context.Context, binary string, s harness.Scenario) (harness.Result, error) {
+	c, err := chain.Start(ctx, chain.Options{Binary: x.Bitcoind})
+	if err != nil {
+		return harness.Result{}, err
+	}
+	defer c.Close()
+	m, err := nodes.NewManager(binary, "")
+	if err != nil {
+		return harness.Result{}, err
+	}
+	defer m.Close()
+	net, err := spawnNetwork(ctx, m.WithOptions(c.Wallet()), c, x.Vendors, x.Buyers)
+	if err != nil {
+		return harness.Result{}, err
+	}
+	stamp("", x.Seed, net)
+	return harness.Run(ctx, net, []harness.Scenario{s})[0], nil
+}
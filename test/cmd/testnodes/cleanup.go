@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/leftovers"
+)
+
+// cleanupSettle is how long containers and processes the command stopped
+// get to go away before they count as left behind
+const cleanupSettle = 10 * time.Second
+
+// cleanupBefore is what was on the host when --check-cleanup was parsed,
+// before the command ran
+var cleanupBefore *leftovers.Snapshot
+
+func snapshotBeforeCleanup() {
+	cleanupBefore = leftovers.Take(context.Background(), leftovers.Options{})
+}
+
+// checkCleanup prints a report of what the command left behind on the host
+// and fails if it left anything
+func checkCleanup() error {
+	deadline := time.Now().Add(cleanupSettle)
+	for {
+		left := leftovers.Take(context.Background(), leftovers.Options{}).Since(cleanupBefore)
+		if left.Err() == nil || time.Now().After(deadline) {
+			if err := left.Write(os.Stderr); err != nil {
+				return err
+			}
+			return left.Err()
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/differential"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/ledger"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+type Compare struct {
+	A         string        `short:"a" long:"a" required:"true" description:"the openbazaard binary of the baseline, e.g. built from master"`
+	B         string        `short:"b" long:"b" required:"true" description:"the openbazaard binary to compare with it, e.g. built from the change"`
+	LabelA    string        `long:"label-a" description:"what to call the baseline in the diff, e.g. its commit; the version it reports by default"`
+	LabelB    string        `long:"label-b" description:"what to call the other binary in the diff; the version it reports by default"`
+	Vendors   int           `long:"vendors" default:"1" description:"vendors in each network"`
+	Buyers    int           `long:"buyers" default:"1" description:"buyers in each network"`
+	Seed      int64         `long:"seed" description:"seed the random fixtures of both runs with this, the time by default"`
+	Tolerance float64       `long:"tolerance" default:"0.5" description:"how much slower or faster a step may be on b, as a fraction, before it is reported"`
+	MinDelta  time.Duration `long:"min-delta" default:"1s" description:"ignore timing differences smaller than this"`
+	JSON      string        `long:"json" description:"also write the diff with both end states to this JSON file"`
+	Timeout   time.Duration `short:"t" long:"timeout" default:"30m" description:"give up on each run after this long"`
+}
+
+func (x *Compare) Execute(args []string) error {
+	scenarios, err := match(args)
+	if err != nil {
+		return err
+	}
+	if len(scenarios) == 0 {
+		return errors.New("no scenario matches")
+	}
+	if x.Vendors < 1 && x.Buyers < 1 {
+		return errors.New("networks need at least one node")
+	}
+	if x.Seed == 0 {
+		x.Seed = time.Now().UnixNano()
+	}
+	a, err := x.side(x.LabelA, x.A, scenarios)
+	if err != nil {
+		return fmt.Errorf("running a: %s", err)
+	}
+	b, err := x.side(x.LabelB, x.B, scenarios)
+	if err != nil {
+		return fmt.Errorf("running b: %s", err)
+	}
+	if a.Label == b.Label {
+		// Two builds of the same release
+		a.Label, b.Label = x.A, x.B
+	}
+
+	d := differential.Compare(a, b, differential.Options{Tolerance: x.Tolerance, MinDelta: x.MinDelta})
+	fmt.Println()
+	if err := d.Write(os.Stdout); err != nil {
+		return err
+	}
+	if x.JSON != "" {
+		out, err := d.JSON()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(x.JSON, append(out, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return d.Err()
+}
+
+// side runs the scenarios with the seed on a network of binary, its wallets
+// on a mock ledger of their own, and reads what it ended with
+func (x *Compare) side(label, binary string, scenarios []harness.Scenario) (*differential.Side, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	l, err := ledger.Start("")
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	m, err := nodes.NewManager(binary, "")
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	net, err := spawnNetwork(ctx, m.WithOptions(l.Wallet(), nodes.WithMetrics()), l, x.Vendors, x.Buyers)
+	if err != nil {
+		return nil, err
+	}
+	steps := differential.NewSteps()
+	net.Metrics = metrics.NewRecorder(steps)
+	version := ""
+	for _, p := range m.Processes() {
+		version = p.Version
+	}
+	if label == "" {
+		label = version
+	}
+	if label == "" {
+		label = binary
+	}
+	stamp(label, x.Seed, net)
+
+	s := &differential.Side{
+		Label:    label,
+		Binary:   binary,
+		Version:  version,
+		Outcomes: make(map[string]string),
+		Nodes:    make(map[string]*differential.NodeState),
+	}
+	for _, r := range harness.Run(ctx, net, scenarios) {
+		fmt.Printf("%s: %s\n", label, r)
+		switch {
+		case r.Skipped != "":
+			s.Outcomes[r.Scenario.Name] = "skipped: " + r.Skipped
+		case r.Err != nil:
+			s.Outcomes[r.Scenario.Name] = r.Err.Error()
+		default:
+			s.Outcomes[r.Scenario.Name] = ""
+		}
+	}
+	if err := net.Metrics.Flush(); err != nil {
+		return nil, err
+	}
+	s.Steps = steps.Durations()
+	for _, n := range net.Nodes {
+		state, err := differential.Capture(n.Client())
+		if err != nil {
+			return nil, fmt.Errorf("reading the end state of %s: %s", n.Name(), err)
+		}
+		s.Nodes[n.Name()] = state
+	}
+	return s, nil
+}
+
+// buyerFunds is what spawnNetwork funds each buyer with, enough for the
+// purchases of any scenario
+const buyerFunds = 10 * 1e8
+
+// spawnNetwork spawns the vendors and buyers of a network on m and, when
+// faucet is not nil, funds every buyer from it. m must run the wallets on
+// what faucet pays to.
+func spawnNetwork(ctx context.Context, m *nodes.Manager, faucet harness.Faucet, vendors, buyers int) (*harness.Network, error) {
+	net := &harness.Network{Faucet: faucet}
+	for _, role := range []struct {
+		name  string
+		count int
+	}{{"vendor", vendors}, {"buyer", buyers}} {
+		if role.count < 1 {
+			continue
+		}
+		procs, err := m.Spawn(ctx, role.count)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range procs {
+			net.Nodes = append(net.Nodes, harness.NewLocalNode(fmt.Sprintf("%s-%d", role.name, i+1), role.name, p))
+		}
+	}
+	if faucet == nil {
+		return net, nil
+	}
+	for _, n := range net.Role("buyer") {
+		if err := net.Fund(ctx, n, buyerFunds); err != nil {
+			return nil, err
+		}
+	}
+	return net, nil
+}
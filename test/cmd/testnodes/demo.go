@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/chain"
+	"github.com/OpenBazaar/openbazaar-go/test/feeapi"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/ledger"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+	"github.com/OpenBazaar/openbazaar-go/test/regtest"
+)
+
+type Demo struct {
+	Binary      string        `short:"b" long:"binary" default:"openbazaard" description:"the openbazaard binary to run the nodes with"`
+	Dir         string        `short:"d" long:"dir" description:"where to create the node repos, a temp dir by default"`
+	Vendors     int           `long:"vendors" default:"2" description:"number of vendors"`
+	Buyers      int           `long:"buyers" default:"1" description:"number of buyers"`
+	Listings    int           `long:"listings" default:"3" description:"listings published by each vendor"`
+	Orders      int           `long:"orders" default:"1" description:"orders each buyer completes with each vendor"`
+	Bitcoind    string        `long:"bitcoind" description:"RPC URL of a regtest bitcoind, e.g. http://127.0.0.1:18443; without it the wallets are disabled and no orders are placed"`
+	RPCUser     string        `long:"rpc-user" description:"bitcoind RPC username"`
+	RPCPassword string        `long:"rpc-password" description:"bitcoind RPC password"`
+	TrustedPeer string        `long:"trusted-peer" default:"127.0.0.1:18444" description:"P2P address of the regtest bitcoind the wallets sync from"`
+	Chain       bool          `long:"chain" description:"start a regtest bitcoind for the wallets instead of using --bitcoind, downloading it if there is none on the PATH; local nodes only"`
+	MockWallet  bool          `long:"mock-wallet" description:"run the wallets on a mock ledger instead of bitcoind, funding them out of thin air; local nodes only"`
+	FeeAPI      bool          `long:"fee-api" description:"point the wallets at a local fee estimate API serving typical estimates instead of none; local nodes only"`
+	DockerImage string        `long:"docker-image" description:"run every node in a container of this image instead of as a child process; --trusted-peer must then be reachable from the containers"`
+	SSHHosts    []string      `long:"ssh-host" description:"spread the nodes over this host, reached with ssh as user@host or user@host=public-ip, may be repeated; its swarm ports must be reachable from the other hosts and --trusted-peer from all of them"`
+	CPUs        float64       `long:"cpus" description:"cap every node at this many cores, e.g. 0.5; local nodes need cgroups and so Linux and root"`
+	MemoryMB    uint64        `long:"memory-mb" description:"cap the memory of every node at this many MiB, swap included"`
+	Upload      uint64        `long:"upload-kbit" description:"cap the upload of every node at this many kbit/s, e.g. 256; needs --docker-image"`
+	Download    uint64        `long:"download-kbit" description:"cap the download of every node at this many kbit/s; needs --docker-image"`
+	Bootstrap   int           `long:"bootstrap-nodes" description:"make the first this many nodes the only bootstrap peers of the rest, rather than every node bootstrapping from all before it"`
+	Timeout     time.Duration `long:"timeout" default:"10m" description:"how long seeding may take"`
+	Keep        bool          `short:"k" long:"keep" description:"keep the repos after shutting down"`
+}
+
+func (x *Demo) Execute(args []string) error {
+	dir := x.Dir
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "testnodes-demo")
+		if err != nil {
+			return err
+		}
+		dir = tmp
+	}
+	if !x.Keep {
+		defer os.RemoveAll(dir)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+
+	var btc *regtest.Bitcoind
+	var opts []nodes.Option
+	runner := nodes.Local
+	if x.DockerImage != "" && len(x.SSHHosts) > 0 {
+		return errors.New("nodes run either in docker or over ssh")
+	}
+	if x.Chain && (x.Bitcoind != "" || x.DockerImage != "" || len(x.SSHHosts) > 0) {
+		return errors.New("--chain runs bitcoind for local nodes and replaces --bitcoind")
+	}
+	if x.MockWallet && (x.Chain || x.Bitcoind != "" || x.DockerImage != "" || len(x.SSHHosts) > 0) {
+		return errors.New("--mock-wallet runs the wallets of local nodes and replaces --chain and --bitcoind")
+	}
+	if x.FeeAPI && (x.DockerImage != "" || len(x.SSHHosts) > 0) {
+		return errors.New("--fee-api serves the wallets of local nodes only")
+	}
+	if x.DockerImage != "" {
+		shaping := x.Upload > 0 || x.Download > 0
+		docker, err := nodes.NewDockerRunner(ctx, nodes.DockerOptions{Image: x.DockerImage, Shaping: shaping})
+		if err != nil {
+			return err
+		}
+		defer docker.Close()
+		runner = docker
+		opts = append(opts, nodes.WithRunner(docker))
+	}
+	if len(x.SSHHosts) > 0 {
+		var hosts []nodes.SSHHost
+		for _, h := range x.SSHHosts {
+			parts := strings.SplitN(h, "=", 2)
+			host := nodes.SSHHost{Dest: parts[0]}
+			if len(parts) == 2 {
+				host.Addr = parts[1]
+			}
+			hosts = append(hosts, host)
+		}
+		remote, err := nodes.NewSSHRunner(nodes.SSHOptions{Hosts: hosts})
+		if err != nil {
+			return err
+		}
+		if !x.Keep {
+			defer remote.Close()
+		}
+		runner = remote
+		opts = append(opts, nodes.WithRunner(remote))
+	}
+	if x.CPUs > 0 || x.MemoryMB > 0 || x.Upload > 0 || x.Download > 0 {
+		opts = append(opts, nodes.WithLimits(nodes.Limits{CPUs: x.CPUs, Memory: x.MemoryMB << 20, Upload: x.Upload * 1000, Download: x.Download * 1000}))
+	}
+	var mock *ledger.Ledger
+	if x.MockWallet {
+		l, err := ledger.Start("")
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		mock = l
+		opts = append(opts, l.Wallet())
+		fmt.Printf("started a mock ledger at %s\n", l.Addr())
+	} else if x.Chain {
+		c, err := chain.Start(ctx, chain.Options{})
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		btc = c.RPC
+		opts = append(opts, c.Wallet())
+		fmt.Printf("started a regtest bitcoind, RPC at %s, log %s\n", c.RPC.URL, c.Log)
+	} else if x.Bitcoind != "" {
+		btc = regtest.New(x.Bitcoind, x.RPCUser, x.RPCPassword)
+		if err := btc.Wait(ctx); err != nil {
+			return err
+		}
+		if err := btc.Mature(); err != nil {
+			return fmt.Errorf("preparing bitcoind: %s", err)
+		}
+		opts = append(opts, nodes.WithRegtestWallet(x.TrustedPeer))
+	} else {
+		fmt.Println("no --bitcoind, --chain or --mock-wallet given, wallets are disabled and no orders will be placed")
+	}
+
+	if x.FeeAPI {
+		fees, err := feeapi.Start("")
+		if err != nil {
+			return err
+		}
+		defer fees.Close()
+		opts = append(opts, fees.FeeAPI())
+		fmt.Printf("started a fee API at %s\n", fees.URL())
+	}
+
+	net := new(harness.Network)
+	var started []*nodes.Process
+	defer func() {
+		for _, p := range started {
+			p.Stop()
+		}
+	}()
+	var bootstrap []string
+	for _, role := range []struct {
+		name  string
+		count int
+	}{{"vendor", x.Vendors}, {"buyer", x.Buyers}} {
+		for i := 1; i <= role.count; i++ {
+			name := fmt.Sprintf("%s-%d", role.name, i)
+			repoDir := filepath.Join(dir, name)
+			if err := runner.Init(ctx, x.Binary, repoDir, true); err != nil {
+				return err
+			}
+			p, err := nodes.Start(ctx, x.Binary, repoDir, append(opts, nodes.WithBootstrap(bootstrap...))...)
+			if err != nil {
+				return fmt.Errorf("starting %s: %s", name, err)
+			}
+			if x.Bootstrap == 0 || len(started) < x.Bootstrap {
+				bootstrap = append(bootstrap, p.SwarmAddrs...)
+			}
+			started = append(started, p)
+			net.Nodes = append(net.Nodes, harness.NewLocalNode(name, role.name, p))
+			fmt.Printf("started %s\n", name)
+		}
+	}
+
+	o := harness.DemoOptions{Listings: x.Listings, Orders: x.Orders, Funds: int64(x.Orders*x.Vendors+1) * 1e8}
+	switch {
+	case mock != nil:
+		net.Faucet = mock
+	case btc != nil:
+		net.Faucet = btc
+	}
+	demo, err := harness.SeedDemo(ctx, net, o)
+	if err != nil {
+		return fmt.Errorf("seeding the demo: %s", err)
+	}
+
+	fmt.Printf("\nseeded %d listings per vendor and %d completed orders\n\n", x.Listings, len(demo.Orders))
+	for i, n := range net.Nodes {
+		c := n.Client()
+		fmt.Printf("%-10s %s/ipns/%s\n", n.Name(), c.BaseURL, n.PeerID())
+		fmt.Printf("%-10s host %s\n", "", started[i].Hostname)
+		fmt.Printf("%-10s api %s, repo %s\n\n", "", c.BaseURL, filepath.Join(dir, n.Name()))
+	}
+	fmt.Println("press ctrl-c to shut the network down")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	return nil
+}
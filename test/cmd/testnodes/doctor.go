@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/OpenBazaar/openbazaar-go/test/doctor"
+)
+
+type Doctor struct {
+	Binary string `short:"b" long:"binary" default:"openbazaard" description:"the openbazaard binary nodes are started from"`
+	Nodes  int    `short:"n" long:"nodes" default:"10" description:"how many nodes the host should be able to run at once"`
+}
+
+func (x *Doctor) Execute(args []string) error {
+	results := doctor.Check(doctor.Options{Binary: x.Binary, Nodes: x.Nodes})
+	if err := doctor.Write(os.Stdout, results); err != nil {
+		return err
+	}
+	if doctor.Failed(results) {
+		return errors.New("this host cannot run test networks, see the fixes above")
+	}
+	return nil
+}
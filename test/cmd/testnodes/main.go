@@ -0,0 +1,115 @@
+// Command testnodes runs scenarios from the harness library against a set of
+// running nodes, e.g.
+//
+//	testnodes run --node vendor=http://127.0.0.1:4002 --node buyer=http://127.0.0.1:4102 'regression/*'
+//	testnodes run --report report.html --artifacts s3://ci-results/testnodes --node vendor=http://127.0.0.1:4002 --node buyer=http://127.0.0.1:4102 'regression/*'
+//	testnodes sweep --node vendor=http://127.0.0.1:4002 --node buyer=http://127.0.0.1:4102 --param latency=0s,100ms,500ms regression/stuck-awaiting-payment
+//	testnodes compare -a ./openbazaard-master -b ./openbazaard-change --seed 42 'regression/*'
+//	testnodes bisect --scenario regression/stuck-awaiting-payment --good v0.12.0 --bad master
+//	testnodes doctor --nodes 20
+//	testnodes demo --bitcoind http://127.0.0.1:18443 --rpc-user ob --rpc-password ob
+//	testnodes bench checkout --runs 100 --node vendor=http://127.0.0.1:4002 --node buyer=http://127.0.0.1:4102
+//	testnodes --check-cleanup demo --docker-image openbazaar-runtime
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	_ "github.com/OpenBazaar/openbazaar-go/test/scenarios/races"
+	_ "github.com/OpenBazaar/openbazaar-go/test/scenarios/regression"
+)
+
+type Opts struct {
+	Cleanup func() `long:"check-cleanup" description:"fail if the command leaves node processes, docker containers or networks, network namespaces, iptables rules, loopback qdiscs or temp dirs behind, printing a report of them"`
+}
+
+var opts Opts
+var runScenarios Run
+var listScenarios List
+var sweepScenario Sweep
+var compareBinaries Compare
+var bisectCommits Bisect
+var summarizeFailures Failures
+var migrateCorpus Migrate
+var captureRelease Capture
+var checkHost Doctor
+var demoNetwork Demo
+var benchmarks Bench
+var benchCheckout BenchCheckout
+var benchPropagation BenchPropagation
+var benchStartup BenchStartup
+
+var parser = flags.NewParser(&opts, flags.Default)
+
+func main() {
+	opts.Cleanup = snapshotBeforeCleanup
+	parser.AddCommand("run",
+		"run scenarios",
+		"Runs every registered scenario matching the given patterns against the nodes passed with --node",
+		&runScenarios)
+	parser.AddCommand("list",
+		"list scenarios",
+		"Lists the registered scenarios matching the given patterns",
+		&listScenarios)
+	parser.AddCommand("sweep",
+		"sweep a scenario over parameters",
+		"Runs one scenario at every combination of the --param values, e.g. node count and latency, and prints the outcome of each as a matrix",
+		&sweepScenario)
+	parser.AddCommand("compare",
+		"compare two binaries",
+		"Runs the scenarios with the same seed on a fresh network of each binary and diffs their outcomes, end states, step timings and message counts, failing on any difference but timing",
+		&compareBinaries)
+	parser.AddCommand("bisect",
+		"find the commit that broke a scenario",
+		"Builds the commits between --good and --bad, caching the binaries by commit, and binary searches them for the first one the scenario fails at on a fresh network; commits that do not build are skipped",
+		&bisectCommits)
+	parser.AddCommand("failures",
+		"summarize recorded failures",
+		"Groups the failures recorded with run --failures by scenario, step, error class and node role and prints each distinct failure mode",
+		&summarizeFailures)
+	parser.AddCommand("migrate",
+		"migrate the repo corpus",
+		"Boots the binary on every captured release in the migration corpus, or only on the given releases, and checks that their records survive",
+		&migrateCorpus)
+	parser.AddCommand("capture",
+		"add a release to the repo corpus",
+		"Records the listings, orders, followers and chat of a running node, shuts it down and adds its repo to the migration corpus",
+		&captureRelease)
+	parser.AddCommand("doctor",
+		"check the host environment",
+		"Checks for the binary, free ports, open file limits, docker and the kernel features used for network emulation and explains how to fix what is missing",
+		&checkHost)
+	parser.AddCommand("demo",
+		"run a seeded demo network",
+		"Starts a small network, gives it vendors with listings and, with --bitcoind, completed orders, and prints a gateway URL for every node to browse",
+		&demoNetwork)
+	benchCmd, _ := parser.AddCommand("bench",
+		"run latency benchmarks",
+		"Measures latency percentiles of a flow on a clean network and fails when they exceed the committed objectives",
+		&benchmarks)
+	benchCmd.AddCommand("checkout",
+		"benchmark checkout",
+		"Measures how long a full direct checkout takes, from publishing the listing until both sides see the order funded",
+		&benchCheckout)
+	benchCmd.AddCommand("propagation",
+		"benchmark listing propagation",
+		"Publishes listings on the vendor and measures how long the other nodes take to resolve them in networks of each size, writing one CSV row per observer",
+		&benchPropagation)
+	benchCmd.AddCommand("startup",
+		"benchmark node boot time",
+		"Times init, a cold boot and a warm restart of the binary on empty and filled repos and compares the medians against a recorded baseline",
+		&benchStartup)
+	_, err := parser.Parse()
+	if cleanupBefore != nil {
+		if cerr := checkCleanup(); cerr != nil {
+			fmt.Fprintln(os.Stderr, cerr)
+			err = cerr
+		}
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+)
+
+// stamp seeds math/rand, which scenarios draw fixtures from, with seed or
+// the time when zero and starts the manifest of a run against the attached
+// nodes, printing its summary
+func stamp(runID string, seed int64, net *harness.Network) *manifest.Manifest {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rand.Seed(seed)
+	if runID == "" {
+		runID = time.Now().UTC().Format(time.RFC3339)
+	}
+	m := manifest.New(runID, "remote")
+	m.Seeds["math/rand"] = seed
+	net.Provenance(m)
+	fmt.Println(m.Stamp())
+	return m
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/migration"
+)
+
+type Migrate struct {
+	Binary   string `short:"b" long:"binary" default:"openbazaard" description:"the openbazaard binary to migrate the corpus with"`
+	Corpus   string `short:"c" long:"corpus" default:"test/migration/corpus" description:"the corpus directory"`
+	Username string `short:"u" long:"username" description:"API username for repos with authentication turned on"`
+	Password string `short:"p" long:"password" description:"API password"`
+	Keep     bool   `short:"k" long:"keep" description:"keep the migrated repos for inspection"`
+}
+
+type Capture struct {
+	API      string `short:"a" long:"api" default:"http://127.0.0.1:4002" description:"API address of the running release"`
+	Version  string `short:"v" long:"version" required:"true" description:"the release the node is running"`
+	Repo     string `short:"r" long:"repo" required:"true" description:"the data directory of the running node"`
+	Corpus   string `short:"c" long:"corpus" default:"test/migration/corpus" description:"the corpus directory"`
+	Username string `short:"u" long:"username" description:"API username"`
+	Password string `short:"p" long:"password" description:"API password"`
+}
+
+func (x *Migrate) Execute(args []string) error {
+	entries, err := migration.Load(x.Corpus)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		entries = filterVersions(entries, args)
+	}
+	if len(entries) == 0 {
+		return errors.New("corpus is empty")
+	}
+	opts := migration.Options{
+		Binary:   x.Binary,
+		Username: x.Username,
+		Password: x.Password,
+		Keep:     x.Keep,
+	}
+	failed := 0
+	for _, r := range migration.Run(context.Background(), opts, entries) {
+		fmt.Println(r)
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d releases failed to migrate", failed, len(entries))
+	}
+	return nil
+}
+
+func (x *Capture) Execute(args []string) error {
+	c := client.New(x.API)
+	if x.Username != "" {
+		c.WithAuth(x.Username, x.Password)
+	}
+	exp, err := migration.Capture(c, x.Version)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Post("/ob/shutdown", nil); err != nil {
+		return err
+	}
+	// The node exits about a second after answering the shutdown
+	for i := 0; i < 30; i++ {
+		if _, err := c.PeerID(); err != nil {
+			return migration.Add(x.Corpus, x.Repo, exp)
+		}
+		time.Sleep(time.Second)
+	}
+	return errors.New("node did not shut down")
+}
+
+func filterVersions(entries []migration.Entry, versions []string) []migration.Entry {
+	want := make(map[string]bool)
+	for _, v := range versions {
+		want[v] = true
+	}
+	var ret []migration.Entry
+	for _, e := range entries {
+		if want[e.Version] {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/artifacts"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fingerprint"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+	"github.com/OpenBazaar/openbazaar-go/test/report"
+	"github.com/OpenBazaar/openbazaar-go/test/schema"
+)
+
+type Run struct {
+	Nodes    []string      `short:"n" long:"node" description:"a node to run against as role=url or role:name=url, with @implementation after the name for nodes not running openbazaar-go, may be repeated"`
+	Username string        `short:"u" long:"username" description:"API username"`
+	Password string        `short:"p" long:"password" description:"API password"`
+	Timeout  time.Duration `short:"t" long:"timeout" default:"30m" description:"give up on the whole run after this long"`
+	Report   string        `short:"r" long:"report" description:"write an HTML report with scenario results and API response times to this file"`
+	Rate     float64       `long:"rate" description:"max API requests per second sent to each node, 0 for no limit"`
+	InFlight int           `long:"max-inflight" description:"max concurrent API requests to each node, 0 for no limit"`
+	Failures string        `long:"failures" description:"append the failures of this run to this file for testnodes failures to aggregate"`
+	RunID    string        `long:"run-id" description:"identifies this run in the failures file and manifest, the start time by default"`
+	Seed     int64         `long:"seed" description:"seed the random fixtures with this to reproduce a run, the time by default"`
+	Manifest string        `long:"manifest" description:"write the run's manifest of binaries, seeds, coin backends and host as JSON to this file; the report and failures file embed it too"`
+	Schema   string        `long:"schema" description:"fail scenarios whose API responses drift from this OpenAPI description, e.g. test/schema/openapi.json"`
+	Sweep    bool          `long:"sweep-content" description:"after each scenario, fetch the content its listings and orders reference from another node and check it hashes to its CID"`
+	Verify   int           `long:"verify-workers" description:"how many documents and hashes --sweep-content fetches and checks at once, the number of CPUs by default"`
+	Impls    []string      `long:"implementation" description:"declare a server implementation nodes may run as name=capability,..., may be repeated; scenarios needing other capabilities are skipped"`
+	Strict   bool          `long:"strict" description:"fail scenarios on any API error outside steps expecting one and on notifications they do not expect"`
+	Store    string        `long:"artifacts" description:"push the manifest, report, failures file and heap profiles of the run under its ID to this store: a directory, s3://bucket/prefix or gs://bucket/prefix, with ?endpoint= for minio and other S3 compatible servers"`
+}
+
+type Failures struct {
+	Args struct {
+		Files []string `positional-arg-name:"file" required:"1"`
+	} `positional-args:"yes"`
+}
+
+type List struct{}
+
+func (x *Run) Execute(args []string) error {
+	scenarios, err := match(args)
+	if err != nil {
+		return err
+	}
+	if len(scenarios) == 0 {
+		return errors.New("no scenario matches")
+	}
+	for _, d := range x.Impls {
+		impl, err := parseImplementation(d)
+		if err != nil {
+			return err
+		}
+		harness.RegisterImplementation(impl)
+	}
+	net, err := attach(x.Nodes, x.Username, x.Password)
+	if err != nil {
+		return err
+	}
+	if x.Schema != "" {
+		spec, err := schema.Load(x.Schema)
+		if err != nil {
+			return err
+		}
+		net.Schema = schema.NewChecker(spec)
+	}
+	if x.Strict {
+		net.Strict = harness.NewStrict()
+	}
+	net.SweepContent = x.Sweep
+	net.Verifiers = x.Verify
+	latencies := client.NewLatencies()
+	for _, n := range net.Nodes {
+		n.Client().Latencies = latencies
+		var observers client.Observers
+		if net.Schema != nil {
+			observers = append(observers, net.Schema)
+		}
+		if net.Strict != nil {
+			observers = append(observers, net.Strict)
+		}
+		if len(observers) > 0 {
+			n.Client().Observer = observers
+		}
+		if x.Rate > 0 || x.InFlight > 0 {
+			n.Client().WithLimiter(client.NewLimiter(x.Rate, x.InFlight))
+		}
+	}
+
+	m := stamp(x.RunID, x.Seed, net)
+	if x.Manifest != "" {
+		if err := ioutil.WriteFile(x.Manifest, []byte(m.JSON()+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	var store artifacts.Store
+	if x.Store != "" {
+		s, err := artifacts.Open(x.Store)
+		if err != nil {
+			return err
+		}
+		store = artifacts.Under(s, m.Run)
+		net.Artifacts = store
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	start := time.Now()
+	results := harness.Run(ctx, net, scenarios)
+	failed, skipped := 0, 0
+	for _, r := range results {
+		fmt.Println(r)
+		if r.Err != nil {
+			failed++
+		}
+		if r.Skipped != "" {
+			skipped++
+		}
+	}
+	if x.Report != "" {
+		if err := writeReport(x.Report, start, results, latencies, m); err != nil {
+			return err
+		}
+	}
+	if x.Failures != "" {
+		if err := recordFailures(x.Failures, m, start, results); err != nil {
+			return err
+		}
+	}
+	if store != nil {
+		if err := x.pushArtifacts(store, m); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(scenarios))
+	}
+	if skipped == len(results) {
+		return fmt.Errorf("all %d scenarios skipped, the nodes' implementations lack what they require", skipped)
+	}
+	return nil
+}
+
+func (x *List) Execute(args []string) error {
+	scenarios, err := match(args)
+	if err != nil {
+		return err
+	}
+	for _, s := range scenarios {
+		fmt.Printf("%s (v%d)\t%s\n", s.Name, s.Version, s.Description)
+	}
+	for _, c := range harness.Checkers() {
+		fmt.Printf("check/%s\trun after every scenario\n", c.Name())
+	}
+	return nil
+}
+
+// match returns the scenarios matching any of the patterns, or all of them
+// when no pattern is given
+func match(patterns []string) ([]harness.Scenario, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"*/*"}
+	}
+	seen := make(map[string]bool)
+	var ret []harness.Scenario
+	for _, p := range patterns {
+		scenarios, err := harness.Scenarios(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range scenarios {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (x *Failures) Execute(args []string) error {
+	var failures []fingerprint.Failure
+	for _, f := range x.Args.Files {
+		loaded, err := fingerprint.Load(f)
+		if err != nil {
+			return err
+		}
+		failures = append(failures, loaded...)
+	}
+	return fingerprint.WriteSummary(os.Stdout, failures)
+}
+
+// pushArtifacts stores the manifest of the run, and the report and
+// failures file when they were written
+func (x *Run) pushArtifacts(store artifacts.Store, m *manifest.Manifest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	loc, err := store.Put(ctx, "manifest.json", strings.NewReader(m.JSON()+"\n"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("manifest pushed to %s\n", loc)
+	for _, f := range []struct{ name, path string }{{"report.html", x.Report}, {"failures.jsonl", x.Failures}} {
+		if f.path == "" {
+			continue
+		}
+		loc, err := artifacts.PutFile(ctx, store, f.name, f.path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s pushed to %s\n", f.name, loc)
+	}
+	return nil
+}
+
+// recordFailures appends the failed scenarios of a run to path
+func recordFailures(path string, m *manifest.Manifest, start time.Time, results []harness.Result) error {
+	var failures []fingerprint.Failure
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		failures = append(failures, fingerprint.Failure{
+			Run:      m.Run,
+			Time:     start,
+			Scenario: r.Scenario.Name,
+			Step:     r.Step,
+			Message:  r.Err.Error(),
+			Manifest: m,
+		})
+	}
+	return fingerprint.Append(path, failures)
+}
+
+// writeReport renders the results of a run as HTML
+func writeReport(path string, start time.Time, results []harness.Result, latencies *client.Latencies, m *manifest.Manifest) error {
+	r := &report.Report{
+		Title:     "testnodes run " + start.Format("2006-01-02 15:04"),
+		Started:   start,
+		Duration:  time.Since(start),
+		Endpoints: latencies.Endpoints(),
+		Manifest:  m,
+	}
+	for _, res := range results {
+		r.Implementations = res.Implementations
+		s := report.Scenario{Name: res.Scenario.Name, Version: res.Scenario.Version, Duration: res.Duration, Features: res.Features, Topology: res.Topology, Skipped: res.Skipped}
+		if res.Err != nil {
+			s.Err = res.Err.Error()
+		}
+		r.Scenarios = append(r.Scenarios, s)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.WriteHTML(f)
+}
+
+// attach builds a network from --node values
+func attach(nodes []string, username, password string) (*harness.Network, error) {
+	net := new(harness.Network)
+	count := make(map[string]int)
+	for _, n := range nodes {
+		role, name, implName, url, err := parseNode(n)
+		if err != nil {
+			return nil, err
+		}
+		impl, err := harness.LookupImplementation(implName)
+		if err != nil {
+			return nil, err
+		}
+		count[role]++
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", role, count[role])
+		}
+		c := client.New(url)
+		if username != "" {
+			c.WithAuth(username, password)
+		}
+		node, err := harness.NewRemoteNode(name, role, c)
+		if err != nil {
+			return nil, fmt.Errorf("attaching to %s at %s: %s", name, url, err)
+		}
+		net.Nodes = append(net.Nodes, node.WithImplementation(impl))
+	}
+	return net, nil
+}
+
+// parseNode splits a --node value into role, optional name, implementation
+// and API url. The implementation is openbazaar-go unless given.
+func parseNode(s string) (role, name, impl, url string, err error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", "", fmt.Errorf("invalid node %q, expected role=url", s)
+	}
+	role, url = parts[0], parts[1]
+	impl = harness.OpenBazaarGo.Name
+	if i := strings.Index(role, "@"); i >= 0 {
+		role, impl = role[:i], role[i+1:]
+	}
+	if i := strings.Index(role, ":"); i >= 0 {
+		role, name = role[:i], role[i+1:]
+	}
+	if role == "" || impl == "" {
+		return "", "", "", "", fmt.Errorf("invalid node %q, expected role[:name][@implementation]=url", s)
+	}
+	return role, name, impl, url, nil
+}
+
+// parseImplementation reads an --implementation value, name=capability,...
+func parseImplementation(s string) (harness.Implementation, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return harness.Implementation{}, fmt.Errorf("invalid implementation %q, expected name=capability,...", s)
+	}
+	impl := harness.Implementation{Name: parts[0]}
+	for _, c := range strings.Split(parts[1], ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			impl.Capabilities = append(impl.Capabilities, c)
+		}
+	}
+	return impl, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+	"github.com/OpenBazaar/openbazaar-go/test/sweep"
+)
+
+// Parameters applied by the sweep command itself rather than the scenario
+const (
+	// paramNodes runs the scenario on the first n nodes passed with --node
+	paramNodes = "nodes"
+
+	// paramLatency delays loopback traffic with netem
+	paramLatency = "latency"
+
+	// paramJitter varies the loopback delay of each packet by up to this much
+	paramJitter = "jitter"
+
+	// paramLoss drops this percentage of loopback packets
+	paramLoss = "loss"
+)
+
+type Sweep struct {
+	Nodes    []string      `short:"n" long:"node" description:"a node to run against as role=url or role:name=url, may be repeated"`
+	Username string        `short:"u" long:"username" description:"API username"`
+	Password string        `short:"p" long:"password" description:"API password"`
+	Params   []string      `short:"P" long:"param" description:"a parameter and its values as name=v1,v2, may be repeated; nodes, latency, jitter and loss are applied by the harness, anything else must be read by the scenario"`
+	Timeout  time.Duration `short:"t" long:"timeout" default:"2h" description:"give up on the whole sweep after this long"`
+	CSV      string        `long:"csv" description:"also write the matrix to this CSV file, headed by a # manifest line"`
+	Args     struct {
+		Scenario string `positional-arg-name:"scenario" required:"1"`
+	} `positional-args:"yes"`
+}
+
+func (x *Sweep) Execute(args []string) error {
+	scenarios, err := harness.Scenarios(x.Args.Scenario)
+	if err != nil {
+		return err
+	}
+	if len(scenarios) != 1 {
+		return fmt.Errorf("%q matches %d scenarios, a sweep runs exactly one", x.Args.Scenario, len(scenarios))
+	}
+	s := scenarios[0]
+	known := map[string]bool{paramNodes: true, paramLatency: true, paramJitter: true, paramLoss: true}
+	for _, p := range s.Params {
+		known[p] = true
+	}
+	var params []sweep.Param
+	for _, arg := range x.Params {
+		p, err := sweep.ParseParam(arg)
+		if err != nil {
+			return err
+		}
+		if !known[p.Name] {
+			return fmt.Errorf("%s does not read parameter %s", s.Name, p.Name)
+		}
+		params = append(params, p)
+	}
+	if len(params) == 0 {
+		return errors.New("nothing to sweep, pass at least one --param")
+	}
+	net, err := attach(x.Nodes, x.Username, x.Password)
+	if err != nil {
+		return err
+	}
+
+	m := stamp("", 0, net)
+
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	grid := sweep.Grid(params...)
+	fmt.Printf("sweeping %s over %d points\n", s.Name, len(grid))
+	cells := harness.Sweep(ctx, s, grid, func(ctx context.Context, p sweep.Point) (*harness.Network, func() error, error) {
+		return x.setup(ctx, net, p)
+	})
+	if err := sweep.WriteMatrix(os.Stdout, params, cells); err != nil {
+		return err
+	}
+	if x.CSV != "" {
+		f, err := os.Create(x.CSV)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := m.WriteComment(f); err != nil {
+			return err
+		}
+		if err := sweep.WriteCSV(f, params, cells); err != nil {
+			return err
+		}
+	}
+	for _, c := range cells {
+		if c.Err != nil {
+			return errors.New("some points failed")
+		}
+	}
+	return nil
+}
+
+// setup picks the nodes and shapes the traffic for one point
+func (x *Sweep) setup(ctx context.Context, net *harness.Network, p sweep.Point) (*harness.Network, func() error, error) {
+	count, err := p.Int(paramNodes, len(net.Nodes))
+	if err != nil {
+		return nil, nil, err
+	}
+	sub, err := net.Subset(count)
+	if err != nil {
+		return nil, nil, err
+	}
+	var i netem.Impairment
+	if i.Delay, err = p.Duration(paramLatency, 0); err != nil {
+		return nil, nil, err
+	}
+	if i.Jitter, err = p.Duration(paramJitter, 0); err != nil {
+		return nil, nil, err
+	}
+	if i.Loss, err = p.Float(paramLoss, 0); err != nil {
+		return nil, nil, err
+	}
+	if i == (netem.Impairment{}) {
+		return sub, nil, nil
+	}
+	restore, err := netem.Impair(ctx, netem.Loopback, i)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, restore, nil
+}
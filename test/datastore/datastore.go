@@ -0,0 +1,81 @@
+// Package datastore opens the IPFS datastore backends in-process test nodes
+// can run on, so the same scenarios cover every backend.
+package datastore
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	ds2 "github.com/ipfs/go-ipfs/thirdparty/datastore2"
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+	syncds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/sync"
+	mount "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore/syncmount"
+	flatfs "gx/ipfs/QmXZEfbEv9sXG9JnLoMNhREDMDgkq5Jd7uWJ7d77VJ4pxn/go-ds-flatfs"
+	levelds "gx/ipfs/QmaHHmfEozrrotyhyN44omJouyuEtx6ahddqV6W5yRaUSQ/go-ds-leveldb"
+)
+
+// Backend names a datastore implementation
+type Backend string
+
+const (
+	// Memory keeps everything in a map, the default for in-process nodes
+	Memory Backend = "memory"
+
+	// FlatFS is the layout of a real repo: blocks in flatfs, everything else
+	// in leveldb
+	FlatFS Backend = "flatfs"
+
+	// LevelDB keeps blocks and metadata in a single leveldb
+	LevelDB Backend = "leveldb"
+
+	// Badger is not vendored in this tree, opening it fails
+	Badger Backend = "badger"
+)
+
+// Datastore is what a repo needs from its datastore, the same as
+// go-ipfs/repo.Datastore
+type Datastore interface {
+	ds.Batching
+	io.Closer
+}
+
+// Backends are the backends Open supports
+var Backends = []Backend{Memory, FlatFS, LevelDB}
+
+// Open returns a datastore of the given backend. On-disk backends store their
+// files in dir, which must exist; Memory ignores it.
+func Open(b Backend, dir string) (Datastore, error) {
+	switch b {
+	case Memory, "":
+		return ds2.CloserWrap(syncds.MutexWrap(ds.NewMapDatastore())), nil
+	case FlatFS:
+		blocks, err := flatfs.CreateOrOpen(filepath.Join(dir, "blocks"), flatfs.NextToLast(2), false)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := levelds.NewDatastore(filepath.Join(dir, "datastore"), nil)
+		if err != nil {
+			blocks.Close()
+			return nil, err
+		}
+		return mount.New([]mount.Mount{
+			{Prefix: ds.NewKey("/blocks"), Datastore: blocks},
+			{Prefix: ds.NewKey("/"), Datastore: meta},
+		}), nil
+	case LevelDB:
+		return levelds.NewDatastore(filepath.Join(dir, "datastore"), nil)
+	default:
+		return nil, fmt.Errorf("datastore: %s backend is not available", b)
+	}
+}
+
+// Parse returns the backend with the given name
+func Parse(name string) (Backend, error) {
+	for _, b := range Backends {
+		if string(b) == name {
+			return b, nil
+		}
+	}
+	return "", fmt.Errorf("datastore: unknown backend %q", name)
+}
@@ -0,0 +1,48 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ds "gx/ipfs/QmRWDav6mzWseLWeYfVd5fvUKiVe9xNH29YfMF438fG364/go-datastore"
+)
+
+func TestBackends(t *testing.T) {
+	for _, b := range Backends {
+		dir, err := ioutil.TempDir("", "datastore")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		d, err := Open(b, dir)
+		if err != nil {
+			t.Fatalf("%s: %s", b, err)
+		}
+		for _, k := range []string{"/blocks/CIQA4XCGRCRTCCHV7XSGAZPZJOAOHLPOI6IQR3H6YQ", "/local/filesroot"} {
+			key := ds.NewKey(k)
+			if err := d.Put(key, []byte("value")); err != nil {
+				t.Fatalf("%s: put %s: %s", b, k, err)
+			}
+			v, err := d.Get(key)
+			if err != nil {
+				t.Fatalf("%s: get %s: %s", b, k, err)
+			}
+			if string(v.([]byte)) != "value" {
+				t.Errorf("%s: expected value, got %s", b, v)
+			}
+		}
+		if err := d.Close(); err != nil {
+			t.Errorf("%s: %s", b, err)
+		}
+	}
+}
+
+func TestBadgerUnavailable(t *testing.T) {
+	if _, err := Open(Badger, ""); err == nil {
+		t.Error("Expected an error opening badger")
+	}
+	if _, err := Parse("badger"); err == nil {
+		t.Error("Expected badger to be rejected")
+	}
+}
@@ -0,0 +1,313 @@
+// Package dbfault injects locked-database and busy errors into the SQLite
+// layer of in-process test nodes, and crashes it at chosen statements.
+package dbfault
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/repo/db"
+	sqlite3 "github.com/mutecomm/go-sqlcipher"
+)
+
+// DriverName is the database/sql driver registered by this package
+const DriverName = "sqlite3-fault"
+
+var (
+	active   *Injector
+	activeMu sync.Mutex
+)
+
+func init() {
+	sql.Register(DriverName, &faultDriver{parent: &sqlite3.SQLiteDriver{}})
+}
+
+// Injector decides which statements fail and records every attempt made
+// against a matching statement
+type Injector struct {
+	lock     sync.Mutex
+	rules    []*rule
+	previous string
+}
+
+type rule struct {
+	match    string
+	code     sqlite3.ErrNo
+	times    int
+	attempts int
+	injected int
+
+	// Crash rules take down the databases whose path contains dsn at the
+	// times-th matching statement
+	crash   bool
+	dsn     string
+	crashed bool
+}
+
+// Install points the node database at the fault driver and returns the
+// injector used for every connection opened afterwards
+func Install() *Injector {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	i := &Injector{previous: db.Driver}
+	active = i
+	db.Driver = DriverName
+	return i
+}
+
+// Uninstall restores the previous database driver
+func (i *Injector) Uninstall() {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if active == i {
+		active = nil
+	}
+	db.Driver = i.previous
+}
+
+// Busy fails the next n statements containing match with SQLITE_BUSY.
+// A non-positive n fails every matching statement.
+func (i *Injector) Busy(match string, n int) {
+	i.add(match, sqlite3.ErrBusy, n)
+}
+
+// Locked fails the next n statements containing match with SQLITE_LOCKED.
+// A non-positive n fails every matching statement.
+func (i *Injector) Locked(match string, n int) {
+	i.add(match, sqlite3.ErrLocked, n)
+}
+
+// Crash kills the databases whose path contains dsn, every one when it is
+// empty, at the n-th statement containing match: neither it nor any later
+// statement runs and transactions open at that point are never committed,
+// as if the node had been killed just before it. Commits match "commit".
+// The database on disk is then what the node finds when it restarts.
+func (i *Injector) Crash(dsn, match string, n int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.rules = append(i.rules, &rule{match: strings.ToLower(match), code: sqlite3.ErrIoErr, times: n, crash: true, dsn: dsn})
+}
+
+// Crashed reports whether a crash rule took a database down
+func (i *Injector) Crashed() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for _, r := range i.rules {
+		if r.crashed {
+			return true
+		}
+	}
+	return false
+}
+
+// Attempts returns how many times a statement containing match was executed
+func (i *Injector) Attempts(match string) int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	total := 0
+	for _, r := range i.find(match) {
+		total += r.attempts
+	}
+	return total
+}
+
+// Injected returns how many faults were returned for statements containing match
+func (i *Injector) Injected(match string) int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	total := 0
+	for _, r := range i.find(match) {
+		total += r.injected
+	}
+	return total
+}
+
+// CheckRetried returns an error unless every fault injected for match was
+// followed by another attempt at the same statement, which is what the node
+// must do to avoid dropping the write
+func (i *Injector) CheckRetried(match string) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	rules := i.find(match)
+	if len(rules) == 0 {
+		return fmt.Errorf("dbfault: no rule registered for %q", match)
+	}
+	for _, r := range rules {
+		if r.injected == 0 {
+			return fmt.Errorf("dbfault: %q was never executed", r.match)
+		}
+		if r.attempts <= r.injected {
+			return fmt.Errorf("dbfault: %q failed %d times and was not retried", r.match, r.injected)
+		}
+	}
+	return nil
+}
+
+// Reset removes all rules and counters
+func (i *Injector) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.rules = nil
+}
+
+func (i *Injector) add(match string, code sqlite3.ErrNo, n int) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.rules = append(i.rules, &rule{match: strings.ToLower(match), code: code, times: n})
+}
+
+func (i *Injector) find(match string) []*rule {
+	var ret []*rule
+	for _, r := range i.rules {
+		if r.match == strings.ToLower(match) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// check records an attempt at query on the database at dsn and returns the
+// fault to inject, if any
+func (i *Injector) check(dsn, query string) error {
+	if i == nil {
+		return nil
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	q := strings.ToLower(query)
+	for _, r := range i.rules {
+		if r.crashed && strings.Contains(dsn, r.dsn) {
+			return sqlite3.Error{Code: r.code}
+		}
+	}
+	var err error
+	for _, r := range i.rules {
+		if !strings.Contains(q, r.match) || !strings.Contains(dsn, r.dsn) {
+			continue
+		}
+		r.attempts++
+		switch {
+		case r.crash:
+			if r.attempts == r.times {
+				r.crashed = true
+				r.injected++
+				err = sqlite3.Error{Code: r.code}
+			}
+		case err == nil && (r.times <= 0 || r.injected < r.times):
+			r.injected++
+			err = sqlite3.Error{Code: r.code}
+		}
+	}
+	return err
+}
+
+func current() *Injector {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return active
+}
+
+type faultDriver struct {
+	parent driver.Driver
+}
+
+func (d *faultDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.parent.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: c, dsn: dsn}, nil
+}
+
+type faultConn struct {
+	driver.Conn
+	dsn string
+}
+
+func (c *faultConn) Prepare(query string) (driver.Stmt, error) {
+	s, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &faultStmt{Stmt: s, query: query, dsn: c.dsn}, nil
+}
+
+func (c *faultConn) Begin() (driver.Tx, error) {
+	if err := current().check(c.dsn, "begin"); err != nil {
+		return nil, err
+	}
+	tx, err := c.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &faultTx{Tx: tx, dsn: c.dsn}, nil
+}
+
+// faultTx rolls back instead of committing on a crashed database, leaving
+// the database file as a killed node would
+type faultTx struct {
+	driver.Tx
+	dsn string
+}
+
+func (t *faultTx) Commit() error {
+	if err := current().check(t.dsn, "commit"); err != nil {
+		t.Tx.Rollback()
+		return err
+	}
+	return t.Tx.Commit()
+}
+
+func (c *faultConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.Execer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := current().check(c.dsn, query); err != nil {
+		return nil, err
+	}
+	return execer.Exec(query, args)
+}
+
+func (c *faultConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.Queryer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := current().check(c.dsn, query); err != nil {
+		return nil, err
+	}
+	return queryer.Query(query, args)
+}
+
+type faultStmt struct {
+	driver.Stmt
+	query string
+	dsn   string
+}
+
+func (s *faultStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := current().check(s.dsn, s.query); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(args)
+}
+
+func (s *faultStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := current().check(s.dsn, s.query); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(args)
+}
+
+// IsBusy reports whether err is a busy or locked error from SQLite
+func IsBusy(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	if !ok {
+		return false
+	}
+	return e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked
+}
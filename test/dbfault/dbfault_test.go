@@ -0,0 +1,153 @@
+package dbfault
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/repo/db"
+)
+
+const insertFollower = "insert into followers(peerID, proof) values(?,?)"
+
+func openTestDB(t *testing.T) *sql.DB {
+	conn, err := sql.Open(DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec("create table followers (peerID text primary key not null, proof blob);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestInstall(t *testing.T) {
+	injector := Install()
+	if db.Driver != DriverName {
+		t.Errorf("Expected driver %s, got %s", DriverName, db.Driver)
+	}
+	injector.Uninstall()
+	if db.Driver != "sqlite3" {
+		t.Errorf("Expected driver sqlite3, got %s", db.Driver)
+	}
+}
+
+func TestBusyIsRetried(t *testing.T) {
+	injector := Install()
+	defer injector.Uninstall()
+	conn := openTestDB(t)
+	defer conn.Close()
+
+	injector.Busy("insert into followers", 2)
+	var err error
+	for i := 0; i < 3; i++ {
+		_, err = conn.Exec(insertFollower, "abc", []byte("proof"))
+		if err == nil {
+			break
+		}
+		if !IsBusy(err) {
+			t.Fatalf("Expected busy error, got %s", err)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := injector.Injected("insert into followers"); n != 2 {
+		t.Errorf("Expected 2 injected faults, got %d", n)
+	}
+	if n := injector.Attempts("insert into followers"); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+	if err := injector.CheckRetried("insert into followers"); err != nil {
+		t.Error(err)
+	}
+
+	var count int
+	if err := conn.QueryRow("select count(*) from followers").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 follower, got %d", count)
+	}
+}
+
+func TestDroppedWriteIsReported(t *testing.T) {
+	injector := Install()
+	defer injector.Uninstall()
+	conn := openTestDB(t)
+	defer conn.Close()
+
+	injector.Locked("insert into followers", 1)
+	stmt, err := conn.Prepare(insertFollower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec("abc", []byte("proof"))
+	if !IsBusy(err) {
+		t.Fatalf("Expected locked error, got %v", err)
+	}
+	if err := injector.CheckRetried("insert into followers"); err == nil {
+		t.Error("Expected an error for a write that was never retried")
+	}
+}
+
+func TestCrash(t *testing.T) {
+	injector := Install()
+	defer injector.Uninstall()
+	dir, err := ioutil.TempDir("", "dbfault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crash.db")
+	conn, err := sql.Open(DriverName, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("create table followers (peerID text primary key not null, proof blob);"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(insertFollower, "first", []byte("proof")); err != nil {
+		t.Fatal(err)
+	}
+
+	injector.Crash(dir, "insert into followers", 2)
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(insertFollower, "second", []byte("proof")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(insertFollower, "third", []byte("proof")); err == nil {
+		t.Error("Expected the statement at the crash point to fail")
+	}
+	if !injector.Crashed() {
+		t.Error("Expected the database to have crashed")
+	}
+	if err := tx.Commit(); err == nil {
+		t.Error("Expected the commit after the crash to fail")
+	}
+	if _, err := conn.Exec("select count(*) from followers"); err == nil {
+		t.Error("Expected every statement after the crash to fail")
+	}
+	conn.Close()
+
+	injector.Reset()
+	conn, err = sql.Open(DriverName, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var count int
+	if err := conn.QueryRow("select count(*) from followers").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected only the follower committed before the crash, got %d", count)
+	}
+}
@@ -0,0 +1,378 @@
+// Package differential compares two runs of the same seeded scenarios, each
+// on a network of a different openbazaard build, and reports where they
+// part: scenario outcomes, the records every node ends up with, step timings
+// and the app messages the nodes sent. It makes an A/B check of a risky
+// protocol change one command.
+package differential
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+)
+
+// Kinds of differences
+const (
+	Outcome  = "outcome"
+	State    = "state"
+	Timing   = "timing"
+	Messages = "messages"
+)
+
+const (
+	// metricsPath is where nodes serve their Prometheus metrics
+	metricsPath = "/debug/metrics/prometheus"
+
+	// sendAttempts counts the app messages a node tried to send, by type
+	sendAttempts = "openbazaar_net_message_send_attempts_total"
+
+	// stepLatency is what the harness records the duration of steps as
+	stepLatency = "step_latency_seconds"
+)
+
+// Side is what one run ended with
+type Side struct {
+	// Label names the side in the diff, e.g. the commit it was built from
+	Label   string `json:"label"`
+	Binary  string `json:"binary"`
+	Version string `json:"version"`
+
+	// Outcomes are the errors of the scenarios by name, empty for the ones
+	// that passed
+	Outcomes map[string]string `json:"outcomes"`
+
+	// Steps are how long every step took, summed over the times it ran
+	Steps map[string]bench.Duration `json:"steps"`
+
+	// Nodes are the end states of the nodes by name
+	Nodes map[string]*NodeState `json:"nodes"`
+}
+
+// NodeState is what a node holds once the scenarios ran. Order IDs and
+// timestamps differ between any two runs, so orders are counted by state.
+type NodeState struct {
+	Listings      []string       `json:"listings"`
+	Purchases     map[string]int `json:"purchases"`
+	Sales         map[string]int `json:"sales"`
+	Followers     int            `json:"followers"`
+	Following     int            `json:"following"`
+	Notifications map[string]int `json:"notifications"`
+
+	// Messages are the app messages the node tried to send by type,
+	// retries included, or nil when it serves no metrics
+	Messages map[string]int `json:"messages"`
+}
+
+// Capture reads the end state of a node
+func Capture(c *client.Client) (*NodeState, error) {
+	s := &NodeState{}
+	listings, err := c.Listings("")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listings {
+		s.Listings = append(s.Listings, l.Slug)
+	}
+	sort.Strings(s.Listings)
+	purchases, err := c.Purchases()
+	if err != nil {
+		return nil, err
+	}
+	s.Purchases = byState(purchases)
+	sales, err := c.Sales()
+	if err != nil {
+		return nil, err
+	}
+	s.Sales = byState(sales)
+	followers, err := c.Followers()
+	if err != nil {
+		return nil, err
+	}
+	following, err := c.Following()
+	if err != nil {
+		return nil, err
+	}
+	s.Followers, s.Following = len(followers), len(following)
+	notifications, _, err := c.Notifications()
+	if err != nil {
+		return nil, err
+	}
+	s.Notifications = make(map[string]int)
+	for _, n := range notifications {
+		s.Notifications[n.Type]++
+	}
+	if b, err := c.GetBytes(metricsPath); err == nil {
+		points, err := metrics.ParseExposition(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("reading metrics: %s", err)
+		}
+		s.Messages = make(map[string]int)
+		for _, p := range points {
+			if p.Name == sendAttempts {
+				s.Messages[p.Tags["type"]] += int(p.Value)
+			}
+		}
+	}
+	return s, nil
+}
+
+func byState(txs []client.Transaction) map[string]int {
+	ret := make(map[string]int)
+	for _, tx := range txs {
+		ret[tx.State]++
+	}
+	return ret
+}
+
+// Steps is a metrics sink summing the step latencies the harness records,
+// to be added to the recorder of the network a side runs on
+type Steps struct {
+	lock  sync.Mutex
+	steps map[string]time.Duration
+}
+
+// NewSteps returns an empty sink
+func NewSteps() *Steps {
+	return &Steps{steps: make(map[string]time.Duration)}
+}
+
+// Write adds the step latencies among points
+func (s *Steps) Write(points []metrics.Point) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, p := range points {
+		if p.Name == stepLatency {
+			s.steps[p.Tags["step"]] += time.Duration(p.Value * float64(time.Second))
+		}
+	}
+	return nil
+}
+
+// Durations returns the summed step latencies by step
+func (s *Steps) Durations() map[string]bench.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make(map[string]bench.Duration, len(s.steps))
+	for step, d := range s.steps {
+		ret[step] = bench.Duration{Duration: d}
+	}
+	return ret
+}
+
+// Difference is one way the sides part
+type Difference struct {
+	Kind string `json:"kind"`
+
+	// Subject is the scenario, step or node the difference is in
+	Subject string `json:"subject"`
+
+	// Key is what differs about the subject, e.g. sales/COMPLETED
+	Key string `json:"key,omitempty"`
+
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+func (d Difference) String() string {
+	subject := d.Subject
+	if d.Key != "" {
+		subject += " " + d.Key
+	}
+	return fmt.Sprintf("%-8s %s: %s -> %s", d.Kind, subject, d.A, d.B)
+}
+
+// Diff is where the sides part
+type Diff struct {
+	A           *Side        `json:"a"`
+	B           *Side        `json:"b"`
+	Differences []Difference `json:"differences"`
+}
+
+// Options bound which timing differences count
+type Options struct {
+	// Tolerance is how much slower or faster than A a step of B may be
+	// as a fraction, e.g. 0.5 for 50%
+	Tolerance float64
+
+	// MinDelta is the smallest timing difference reported however large
+	// the fraction, so quick steps do not flap
+	MinDelta time.Duration
+}
+
+// Compare returns where b parts from a. Outcomes, end states and message
+// counts must match exactly; steps must take as long within the options.
+func Compare(a, b *Side, o Options) *Diff {
+	d := &Diff{A: a, B: b}
+	add := func(kind, subject, key, va, vb string) {
+		d.Differences = append(d.Differences, Difference{Kind: kind, Subject: subject, Key: key, A: va, B: vb})
+	}
+	for _, name := range keys(a.Outcomes, b.Outcomes) {
+		ea, oka := a.Outcomes[name]
+		eb, okb := b.Outcomes[name]
+		if ea != eb || oka != okb {
+			add(Outcome, name, "", outcome(ea, oka), outcome(eb, okb))
+		}
+	}
+	for _, name := range keys(a.Nodes, b.Nodes) {
+		na, nb := a.Nodes[name], b.Nodes[name]
+		if na == nil || nb == nil {
+			add(State, name, "", present(na != nil), present(nb != nil))
+			continue
+		}
+		compareNode(name, na, nb, add)
+	}
+	for _, step := range keys(a.Steps, b.Steps) {
+		ta, oka := a.Steps[step]
+		tb, okb := b.Steps[step]
+		if !oka || !okb {
+			add(Timing, step, "", ran(ta, oka), ran(tb, okb))
+			continue
+		}
+		delta := tb.Duration - ta.Duration
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta >= o.MinDelta && float64(delta) > o.Tolerance*float64(ta.Duration) {
+			add(Timing, step, "", ta.Round(time.Millisecond).String(), tb.Round(time.Millisecond).String())
+		}
+	}
+	return d
+}
+
+func compareNode(name string, a, b *NodeState, add func(kind, subject, key, va, vb string)) {
+	if fmt.Sprint(a.Listings) != fmt.Sprint(b.Listings) {
+		add(State, name, "listings", fmt.Sprint(a.Listings), fmt.Sprint(b.Listings))
+	}
+	for _, c := range []struct {
+		key  string
+		a, b map[string]int
+	}{
+		{"purchases", a.Purchases, b.Purchases},
+		{"sales", a.Sales, b.Sales},
+		{"notifications", a.Notifications, b.Notifications},
+	} {
+		for _, k := range keys(c.a, c.b) {
+			if c.a[k] != c.b[k] {
+				add(State, name, c.key+"/"+k, strconv.Itoa(c.a[k]), strconv.Itoa(c.b[k]))
+			}
+		}
+	}
+	if a.Followers != b.Followers {
+		add(State, name, "followers", strconv.Itoa(a.Followers), strconv.Itoa(b.Followers))
+	}
+	if a.Following != b.Following {
+		add(State, name, "following", strconv.Itoa(a.Following), strconv.Itoa(b.Following))
+	}
+	if a.Messages == nil || b.Messages == nil {
+		return
+	}
+	for _, t := range keys(a.Messages, b.Messages) {
+		if a.Messages[t] != b.Messages[t] {
+			add(Messages, name, t, strconv.Itoa(a.Messages[t]), strconv.Itoa(b.Messages[t]))
+		}
+	}
+}
+
+// Write prints the differences, one per line, or that there are none
+func (d *Diff) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "a: %s (%s)\nb: %s (%s)\n", d.A.Label, d.A.Version, d.B.Label, d.B.Version); err != nil {
+		return err
+	}
+	if len(d.Differences) == 0 {
+		_, err := fmt.Fprintln(w, "no differences")
+		return err
+	}
+	for _, diff := range d.Differences {
+		if _, err := fmt.Fprintln(w, diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSON encodes the diff with both sides, indented
+func (d *Diff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "    ")
+}
+
+// Err fails if the sides part in anything but timing, which varies from run
+// to run by nature and is reported only
+func (d *Diff) Err() error {
+	counts := make(map[string]int)
+	for _, diff := range d.Differences {
+		if diff.Kind != Timing {
+			counts[diff.Kind]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s and %s differ in %d outcomes, %d states and %d message counts",
+		d.A.Label, d.B.Label, counts[Outcome], counts[State], counts[Messages])
+}
+
+// keys returns the keys of the maps, which must have string keys, sorted
+// and without duplicates
+func keys(maps ...interface{}) []string {
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		switch m := m.(type) {
+		case map[string]string:
+			for k := range m {
+				seen[k] = true
+			}
+		case map[string]int:
+			for k := range m {
+				seen[k] = true
+			}
+		case map[string]bench.Duration:
+			for k := range m {
+				seen[k] = true
+			}
+		case map[string]*NodeState:
+			for k := range m {
+				seen[k] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(seen))
+	for k := range seen {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func outcome(err string, ran bool) string {
+	switch {
+	case !ran:
+		return "not run"
+	case err == "":
+		return "ok"
+	default:
+		return "FAIL: " + err
+	}
+}
+
+func present(ok bool) string {
+	if ok {
+		return "present"
+	}
+	return "missing"
+}
+
+func ran(d bench.Duration, ok bool) string {
+	if !ok {
+		return "not run"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,153 @@
+package differential
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+)
+
+func side(label string) *Side {
+	return &Side{
+		Label:    label,
+		Outcomes: map[string]string{"checkout": "", "dispute": ""},
+		Steps: map[string]bench.Duration{
+			"checkout":          {Duration: 10 * time.Second},
+			"checkout/purchase": {Duration: 50 * time.Millisecond},
+		},
+		Nodes: map[string]*NodeState{
+			"vendor-1": {
+				Listings: []string{"a", "b"},
+				Sales:    map[string]int{"COMPLETED": 1},
+				Messages: map[string]int{"ORDER_CONFIRMATION": 1},
+			},
+			"buyer-1": {
+				Purchases:     map[string]int{"COMPLETED": 1},
+				Notifications: map[string]int{"order": 1},
+				Messages:      map[string]int{"ORDER": 1},
+			},
+		},
+	}
+}
+
+func TestCompareSame(t *testing.T) {
+	d := Compare(side("a"), side("b"), Options{Tolerance: 0.5, MinDelta: time.Second})
+	if len(d.Differences) != 0 || d.Err() != nil {
+		t.Errorf("Expected identical sides to match, got %v, %v", d.Differences, d.Err())
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a, b := side("a"), side("b")
+	b.Outcomes["dispute"] = "timed out"
+	b.Steps["checkout"] = bench.Duration{Duration: 20 * time.Second}
+	b.Steps["checkout/purchase"] = bench.Duration{Duration: 500 * time.Millisecond}
+	b.Nodes["vendor-1"].Sales = map[string]int{"AWAITING_PAYMENT": 1}
+	b.Nodes["buyer-1"].Messages["ORDER"] = 3
+	delete(b.Nodes, "vendor-1")
+	b.Nodes["vendor-2"] = a.Nodes["vendor-1"]
+
+	d := Compare(a, b, Options{Tolerance: 0.5, MinDelta: time.Second})
+	var got []string
+	for _, diff := range d.Differences {
+		got = append(got, diff.String())
+	}
+	want := []string{
+		"outcome  dispute: ok -> FAIL: timed out",
+		"messages buyer-1 ORDER: 1 -> 3",
+		"state    vendor-1: present -> missing",
+		"state    vendor-2: missing -> present",
+		"timing   checkout: 10s -> 20s",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if err := d.Err(); err == nil || !strings.Contains(err.Error(), "1 outcomes, 2 states and 1 message counts") {
+		t.Errorf("Expected the diff to fail on everything but timing, got %v", err)
+	}
+
+	b = side("b")
+	b.Nodes["vendor-1"].Sales = map[string]int{"AWAITING_PAYMENT": 1}
+	d = Compare(side("a"), b, Options{})
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"state    vendor-1 sales/AWAITING_PAYMENT: 0 -> 1", "state    vendor-1 sales/COMPLETED: 1 -> 0"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %q in\n%s", line, buf.String())
+		}
+	}
+
+	timingOnly := side("b")
+	timingOnly.Steps["checkout"] = bench.Duration{Duration: time.Minute}
+	if d := Compare(side("a"), timingOnly, Options{Tolerance: 0.5}); len(d.Differences) != 1 || d.Err() != nil {
+		t.Errorf("Expected timing to be reported without failing, got %v, %v", d.Differences, d.Err())
+	}
+}
+
+func TestSteps(t *testing.T) {
+	s := NewSteps()
+	rec := metrics.NewRecorder(s)
+	rec.Timing("step_latency_seconds", time.Second, map[string]string{"step": "checkout", "status": "ok"})
+	rec.Timing("step_latency_seconds", 2*time.Second, map[string]string{"step": "checkout", "status": "ok"})
+	rec.Record("memory_bytes", 1, nil)
+	if err := rec.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	got := s.Durations()
+	if len(got) != 1 || got["checkout"].Duration != 3*time.Second {
+		t.Errorf("Expected checkout to have taken 3s, got %v", got)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	responses := map[string]interface{}{
+		"/ob/listings":  []map[string]string{{"slug": "tshirt"}, {"slug": "mug"}},
+		"/ob/purchases": map[string]interface{}{"purchases": []map[string]string{{"state": "COMPLETED"}, {"state": "COMPLETED"}}},
+		"/ob/sales":     map[string]interface{}{"sales": []map[string]string{{"state": "DISPUTED"}}},
+		"/ob/followers": []string{"QmA", "QmB"},
+		"/ob/following": []string{},
+		"/ob/notifications": map[string]interface{}{
+			"notifications": []map[string]interface{}{
+				{"notification": map[string]string{"type": "order"}},
+				{"notification": map[string]string{"type": "follow"}},
+				{"notification": map[string]string{"type": "order"}},
+			},
+		},
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath {
+			w.Write([]byte(`# TYPE openbazaar_net_message_send_attempts_total counter
+openbazaar_net_message_send_attempts_total{attempt="direct",type="ORDER"} 2
+openbazaar_net_message_send_attempts_total{attempt="retry",type="ORDER"} 1
+openbazaar_net_message_send_attempts_total{attempt="direct",type="CHAT"} 4
+`))
+			return
+		}
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer s.Close()
+
+	state, err := Capture(client.New(s.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(state)
+	want := `{"listings":["mug","tshirt"],"purchases":{"COMPLETED":2},"sales":{"DISPUTED":1},"followers":2,"following":0,"notifications":{"follow":1,"order":2},"messages":{"CHAT":4,"ORDER":3}}`
+	if string(b) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, b)
+	}
+}
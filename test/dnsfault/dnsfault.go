@@ -0,0 +1,303 @@
+// Package dnsfault replaces the Go resolver of in-process test nodes with one
+// the harness controls. Lookups can be made to fail or stall per host while
+// every other query is forwarded to the system name server.
+//
+// The resolver is process wide, so every in-process node sees the same rules.
+package dnsfault
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("dnsfault")
+
+// DNS response codes returned for failing lookups
+const (
+	rcodeServFail = 2
+	rcodeNXDomain = 3
+)
+
+// Resolver answers DNS queries according to its rules
+type Resolver struct {
+	// Upstream is the name server unmatched queries are forwarded to over
+	// TCP, the first nameserver in /etc/resolv.conf when empty
+	Upstream string
+
+	lock     sync.Mutex
+	rules    []*rule
+	lookups  map[string]int
+	previous *net.Resolver
+}
+
+type rule struct {
+	pattern string
+	rcode   int
+	delay   time.Duration
+	hang    bool
+}
+
+// ExchangeRateHosts are the hosts the node fetches bitcoin prices from
+var ExchangeRateHosts = []string{
+	"ticker.openbazaar.org",
+	"bitpay.com",
+	"blockchain.info",
+	"api.bitcoincharts.com",
+}
+
+// SeedHosts returns the DNS seeds the wallet bootstraps its bitcoin peers
+// from on the given network
+func SeedHosts(params *chaincfg.Params) []string {
+	var hosts []string
+	for _, s := range params.DNSSeeds {
+		hosts = append(hosts, s.Host)
+	}
+	return hosts
+}
+
+// Install points net.DefaultResolver at a new resolver and returns it
+func Install() *Resolver {
+	r := &Resolver{lookups: make(map[string]int), previous: net.DefaultResolver}
+	net.DefaultResolver = &net.Resolver{PreferGo: true, Dial: r.dial}
+	return r
+}
+
+// Uninstall restores the resolver that was in place before Install
+func (r *Resolver) Uninstall() {
+	net.DefaultResolver = r.previous
+}
+
+// Fail makes lookups of hosts matching pattern return NXDOMAIN. A pattern
+// starting with *. matches every subdomain.
+func (r *Resolver) Fail(pattern string) {
+	r.add(&rule{pattern: pattern, rcode: rcodeNXDomain})
+}
+
+// ServFail makes lookups of hosts matching pattern return SERVFAIL, which
+// resolvers treat as a temporary error
+func (r *Resolver) ServFail(pattern string) {
+	r.add(&rule{pattern: pattern, rcode: rcodeServFail})
+}
+
+// Delay holds lookups of hosts matching pattern for d before answering them
+func (r *Resolver) Delay(pattern string, d time.Duration) {
+	r.add(&rule{pattern: pattern, delay: d})
+}
+
+// Hang never answers lookups of hosts matching pattern, leaving callers to
+// their own timeouts
+func (r *Resolver) Hang(pattern string) {
+	r.add(&rule{pattern: pattern, hang: true})
+}
+
+// Reset removes all rules and counters
+func (r *Resolver) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rules = nil
+	r.lookups = make(map[string]int)
+}
+
+// Lookups returns how many queries were made for host
+func (r *Resolver) Lookups(host string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lookups[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+func (r *Resolver) add(ru *rule) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ru.pattern = strings.ToLower(ru.pattern)
+	r.rules = append(r.rules, ru)
+}
+
+// match records a query for host and returns the first rule matching it
+func (r *Resolver) match(host string) *rule {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	r.lookups[host]++
+	for _, ru := range r.rules {
+		if ru.pattern == host || (strings.HasPrefix(ru.pattern, "*.") && strings.HasSuffix(host, ru.pattern[1:])) {
+			return ru
+		}
+	}
+	return nil
+}
+
+// dial hands the Go resolver one end of a pipe. A conn that is not a
+// net.PacketConn makes it use TCP framing, which is what serve speaks.
+func (r *Resolver) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go r.serve(server)
+	return client, nil
+}
+
+func (r *Resolver) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		query, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		resp, err := r.answer(query)
+		if err != nil {
+			log.Debugf("answering query: %s", err)
+			return
+		}
+		if resp == nil {
+			// Hang until the resolver gives up and closes its end
+			io.Copy(ioutil.Discard, conn)
+			return
+		}
+		if err := writeFrame(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+func (r *Resolver) answer(query []byte) ([]byte, error) {
+	host, err := questionName(query)
+	if err != nil {
+		return nil, err
+	}
+	ru := r.match(host)
+	if ru != nil {
+		switch {
+		case ru.hang:
+			return nil, nil
+		case ru.rcode != 0:
+			return errorResponse(query, ru.rcode), nil
+		case ru.delay > 0:
+			time.Sleep(ru.delay)
+		}
+	}
+	return r.forward(query)
+}
+
+// forward relays the query to the upstream name server
+func (r *Resolver) forward(query []byte) ([]byte, error) {
+	upstream := r.Upstream
+	if upstream == "" {
+		var err error
+		if upstream, err = systemNameServer(); err != nil {
+			return errorResponse(query, rcodeServFail), nil
+		}
+	}
+	conn, err := net.DialTimeout("tcp", upstream, 5*time.Second)
+	if err != nil {
+		return errorResponse(query, rcodeServFail), nil
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := writeFrame(conn, query); err != nil {
+		return errorResponse(query, rcodeServFail), nil
+	}
+	resp, err := readFrame(conn)
+	if err != nil {
+		return errorResponse(query, rcodeServFail), nil
+	}
+	return resp, nil
+}
+
+// errorResponse turns a query into a response carrying only rcode
+func errorResponse(query []byte, rcode int) []byte {
+	resp := append([]byte(nil), query...)
+	// QR=1, keep opcode and RD, RA=1
+	resp[2] = 0x80 | (query[2] & 0x79)
+	resp[3] = 0x80 | byte(rcode)
+	// Zero the answer, authority and additional counts and drop everything
+	// after the question
+	for i := 6; i < 12; i++ {
+		resp[i] = 0
+	}
+	end, _ := questionEnd(query)
+	return resp[:end]
+}
+
+// questionName returns the name asked for in the first question
+func questionName(msg []byte) (string, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", errors.New("dns message without question")
+	}
+	var labels []string
+	i := 12
+	for {
+		if i >= len(msg) {
+			return "", errors.New("truncated question")
+		}
+		n := int(msg[i])
+		if n == 0 {
+			break
+		}
+		if n&0xc0 != 0 || i+1+n > len(msg) {
+			return "", errors.New("malformed question name")
+		}
+		labels = append(labels, string(msg[i+1:i+1+n]))
+		i += 1 + n
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// questionEnd returns the offset just past the first question
+func questionEnd(msg []byte) (int, error) {
+	i := 12
+	for i < len(msg) && msg[i] != 0 {
+		i += 1 + int(msg[i])
+	}
+	i += 5
+	if i > len(msg) {
+		return 0, errors.New("truncated question")
+	}
+	return i, nil
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeFrame(w io.Writer, msg []byte) error {
+	if len(msg) > 0xffff {
+		return fmt.Errorf("dns message of %d bytes too large", len(msg))
+	}
+	frame := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(frame, uint16(len(msg)))
+	copy(frame[2:], msg)
+	_, err := w.Write(frame)
+	return err
+}
+
+// systemNameServer returns the first name server of /etc/resolv.conf
+func systemNameServer() (string, error) {
+	b, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", errors.New("no nameserver in /etc/resolv.conf")
+}
@@ -0,0 +1,99 @@
+package dnsfault
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// fakeUpstream answers every A query over TCP with 10.1.2.3
+func fakeUpstream(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				query, err := readFrame(conn)
+				if err != nil {
+					return
+				}
+				end, err := questionEnd(query)
+				if err != nil {
+					return
+				}
+				resp := append([]byte(nil), query[:end]...)
+				resp[2], resp[3] = 0x81, 0x80
+				qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+				if qtype == 1 {
+					binary.BigEndian.PutUint16(resp[6:8], 1)
+					// pointer to the question name, type A, class IN, ttl 60, 4 bytes
+					resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 10, 1, 2, 3)
+				}
+				writeFrame(conn, resp)
+			}(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestResolver(t *testing.T) {
+	r := Install()
+	defer r.Uninstall()
+	r.Upstream = fakeUpstream(t)
+
+	addrs, err := net.LookupHost("ticker.openbazaar.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.1.2.3" {
+		t.Errorf("Expected forwarded answer 10.1.2.3, got %v", addrs)
+	}
+
+	r.Fail("*.openbazaar.org")
+	_, err = net.LookupHost("ticker.openbazaar.org")
+	dnsErr, ok := err.(*net.DNSError)
+	if !ok {
+		t.Fatalf("Expected a DNS error, got %v", err)
+	}
+	if dnsErr.Temporary() {
+		t.Error("Expected NXDOMAIN to be a permanent error")
+	}
+	if r.Lookups("ticker.openbazaar.org") < 2 {
+		t.Errorf("Expected lookups to be counted, got %d", r.Lookups("ticker.openbazaar.org"))
+	}
+
+	r.Reset()
+	r.Delay("bitpay.com", 200*time.Millisecond)
+	start := time.Now()
+	if _, err := net.LookupHost("bitpay.com"); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("Expected the lookup to be delayed")
+	}
+
+	r.Hang("blockchain.info")
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, "blockchain.info"); err == nil {
+		t.Error("Expected a hanging lookup to time out")
+	}
+}
+
+func TestSeedHosts(t *testing.T) {
+	hosts := SeedHosts(&chaincfg.TestNet3Params)
+	if len(hosts) == 0 {
+		t.Error("Expected testnet DNS seeds")
+	}
+}
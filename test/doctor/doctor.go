@@ -0,0 +1,247 @@
+// Package doctor checks that the host can run test networks and explains how
+// to fix what is missing
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	OK Status = iota
+	// Warn means optional features, e.g. network emulation, are unavailable
+	Warn
+	// Fail means test networks will not run
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	default:
+		return "ok"
+	}
+}
+
+// Result is the outcome of one check with a hint on fixing it
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Fix    string
+}
+
+// Options says what the checks should expect
+type Options struct {
+	// Binary is the openbazaard binary nodes are started from
+	Binary string
+
+	// Nodes is how many nodes the host should be able to run at once
+	Nodes int
+}
+
+// filesPerNode is a generous estimate of the descriptors a node holds open:
+// swarm connections, the database, flatfs blocks and the API
+const filesPerNode = 512
+
+// Check runs every check
+func Check(o Options) []Result {
+	if o.Nodes <= 0 {
+		o.Nodes = 10
+	}
+	return []Result{
+		checkBinary(o.Binary),
+		checkPorts(o.Nodes),
+		checkIPv6(),
+		checkFiles(o.Nodes),
+		checkTool("docker", []string{"info"}, "install docker and add your user to the docker group to use container runners"),
+		checkTool("tc", []string{"qdisc", "show"}, "install iproute2 to emulate latency and loss"),
+		checkNetem(),
+		checkNamespaces(),
+	}
+}
+
+// Failed reports whether any check failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints one line per check and the fix for each that did not pass
+func Write(w io.Writer, results []Result) error {
+	for _, r := range results {
+		if _, err := fmt.Fprintf(w, "%-4s  %-12s %s\n", r.Status, r.Name, r.Detail); err != nil {
+			return err
+		}
+		if r.Status != OK && r.Fix != "" {
+			if _, err := fmt.Fprintf(w, "      %-12s fix: %s\n", "", r.Fix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkBinary(binary string) Result {
+	r := Result{Name: "openbazaard"}
+	if binary == "" {
+		binary = "openbazaard"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s not found", binary)
+		r.Fix = "go build -o openbazaard . in the repo root and pass --binary, or put it on your PATH"
+		return r
+	}
+	out, err := exec.Command(path, "--help").CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "start") {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%s does not run: %s", path, err)
+		r.Fix = "rebuild the binary for this platform"
+		return r
+	}
+	r.Detail = path
+	return r
+}
+
+// checkPorts listens on two loopback ports per node, the API and the swarm
+func checkPorts(nodes int) Result {
+	r := Result{Name: "ports"}
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for i := 0; i < nodes*2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			r.Status, r.Detail = Fail, fmt.Sprintf("only %d of %d loopback ports could be opened: %s", i, nodes*2, err)
+			r.Fix = "stop leftover nodes or widen net.ipv4.ip_local_port_range"
+			return r
+		}
+		listeners = append(listeners, l)
+	}
+	r.Detail = fmt.Sprintf("%d free loopback ports", nodes*2)
+	if lo, hi, err := portRange(); err == nil && hi-lo+1 < nodes*2 {
+		r.Status, r.Detail = Warn, fmt.Sprintf("ephemeral port range %d-%d is small for %d nodes", lo, hi, nodes)
+		r.Fix = "sysctl -w net.ipv4.ip_local_port_range='10000 65000'"
+	}
+	return r
+}
+
+func portRange() (int, int, error) {
+	b, err := ioutil.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return 0, 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("unexpected port range %q", b)
+	}
+	lo, err := strconv.Atoi(f[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := strconv.Atoi(f[1])
+	return lo, hi, err
+}
+
+func checkIPv6() Result {
+	r := Result{Name: "ipv6"}
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		r.Status, r.Detail = Warn, "cannot listen on ::1"
+		r.Fix = "enable IPv6 on the loopback interface to run the ipv6-only and dual-stack scenarios"
+		return r
+	}
+	l.Close()
+	r.Detail = "::1 available"
+	return r
+}
+
+func checkFiles(nodes int) Result {
+	r := Result{Name: "open files"}
+	soft, err := openFileLimit()
+	if err != nil {
+		r.Status, r.Detail = Warn, err.Error()
+		return r
+	}
+	need := uint64(nodes * filesPerNode)
+	r.Detail = fmt.Sprintf("limit %d, %d nodes need about %d", soft, nodes, need)
+	if soft < need {
+		r.Status = Fail
+		r.Fix = fmt.Sprintf("ulimit -n %d", need)
+	}
+	return r
+}
+
+func checkTool(name string, args []string, fix string) Result {
+	r := Result{Name: name, Fix: fix}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		r.Status, r.Detail = Warn, "not installed"
+		return r
+	}
+	if out, err := exec.Command(path, args...).CombinedOutput(); err != nil {
+		r.Status, r.Detail = Warn, fmt.Sprintf("%s %s failed: %s", name, strings.Join(args, " "), firstLine(out, err))
+		return r
+	}
+	r.Detail = path
+	return r
+}
+
+func checkNetem() Result {
+	r := Result{Name: "netem", Fix: "modprobe sch_netem, or install your distribution's extra kernel modules"}
+	if _, err := os.Stat("/sys/module/sch_netem"); err == nil {
+		r.Detail = "sch_netem loaded"
+		return r
+	}
+	if out, err := exec.Command("modinfo", "sch_netem").CombinedOutput(); err != nil {
+		r.Status, r.Detail = Warn, "sch_netem unavailable: "+firstLine(out, err)
+		return r
+	}
+	r.Detail = "sch_netem available, loaded on first use"
+	return r
+}
+
+func checkNamespaces() Result {
+	r := Result{Name: "namespaces", Fix: "sysctl -w kernel.unprivileged_userns_clone=1, or run the harness as root"}
+	if _, err := os.Stat("/proc/self/ns/net"); err != nil {
+		r.Status, r.Detail = Warn, "network namespaces unsupported"
+		return r
+	}
+	if os.Geteuid() == 0 {
+		r.Detail = "running as root"
+		return r
+	}
+	if out, err := exec.Command("unshare", "--user", "--net", "true").CombinedOutput(); err != nil {
+		r.Status, r.Detail = Warn, "cannot create unprivileged network namespaces: "+firstLine(out, err)
+		return r
+	}
+	r.Detail = "unprivileged network namespaces available"
+	return r
+}
+
+func firstLine(out []byte, err error) string {
+	s := strings.TrimSpace(string(out))
+	if s == "" {
+		return err.Error()
+	}
+	return strings.SplitN(s, "\n", 2)[0]
+}
@@ -0,0 +1,32 @@
+package doctor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMissingBinaryFails(t *testing.T) {
+	r := checkBinary("/nonexistent/openbazaard")
+	if r.Status != Fail || r.Fix == "" {
+		t.Errorf("Expected a failure with a fix, got %+v", r)
+	}
+	if !Failed([]Result{{Status: Warn}, r}) {
+		t.Error("Expected the results to count as failed")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	err := Write(&b, []Result{
+		{Name: "ports", Detail: "20 free loopback ports"},
+		{Name: "netem", Status: Warn, Detail: "sch_netem unavailable", Fix: "modprobe sch_netem"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], "fix: modprobe sch_netem") {
+		t.Errorf("Unexpected output:\n%s", b.String())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package doctor
+
+import "syscall"
+
+func openFileLimit() (uint64, error) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	return uint64(rl.Cur), nil
+}
@@ -0,0 +1,7 @@
+package doctor
+
+import "errors"
+
+func openFileLimit() (uint64, error) {
+	return 0, errors.New("open file limit unknown on windows")
+}
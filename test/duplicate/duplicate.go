@@ -0,0 +1,149 @@
+// Package duplicate wraps the network service of in-process test nodes so that
+// every marketplace message is delivered twice. Running a scenario once with
+// and once without it is a generic idempotency check for message handlers.
+package duplicate
+
+import (
+	"context"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/core"
+	"github.com/OpenBazaar/openbazaar-go/net"
+	"github.com/OpenBazaar/openbazaar-go/pb"
+	"github.com/op/go-logging"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+var log = logging.MustGetLogger("duplicate")
+
+// Marketplace are the message types duplicated unless the service is told
+// otherwise. Pings and offline acks are left alone, they carry no state.
+var Marketplace = []pb.Message_MessageType{
+	pb.Message_CHAT,
+	pb.Message_FOLLOW,
+	pb.Message_UNFOLLOW,
+	pb.Message_ORDER,
+	pb.Message_ORDER_REJECT,
+	pb.Message_ORDER_CANCEL,
+	pb.Message_ORDER_CONFIRMATION,
+	pb.Message_ORDER_FULFILLMENT,
+	pb.Message_ORDER_COMPLETION,
+	pb.Message_DISPUTE_OPEN,
+	pb.Message_DISPUTE_UPDATE,
+	pb.Message_DISPUTE_CLOSE,
+	pb.Message_REFUND,
+	pb.Message_MODERATOR_ADD,
+	pb.Message_MODERATOR_REMOVE,
+}
+
+// Service is a net.NetworkService that sends every matching message a
+// second time right after the first one went out. Messages queued for
+// offline delivery bypass the service and are not duplicated.
+type Service struct {
+	net.NetworkService
+
+	lock    sync.Mutex
+	types   map[pb.Message_MessageType]bool
+	enabled bool
+	sent    map[pb.Message_MessageType]int
+	failed  map[pb.Message_MessageType]int
+}
+
+// Wrap returns a service duplicating the given message types, or the
+// marketplace types if none are given
+func Wrap(s net.NetworkService, types ...pb.Message_MessageType) *Service {
+	if len(types) == 0 {
+		types = Marketplace
+	}
+	d := &Service{
+		NetworkService: s,
+		types:          make(map[pb.Message_MessageType]bool),
+		enabled:        true,
+		sent:           make(map[pb.Message_MessageType]int),
+		failed:         make(map[pb.Message_MessageType]int),
+	}
+	for _, t := range types {
+		d.types[t] = true
+	}
+	return d
+}
+
+// Install replaces the node's network service with a duplicating one
+func Install(n *core.OpenBazaarNode, types ...pb.Message_MessageType) *Service {
+	if d, ok := n.Service.(*Service); ok {
+		return d
+	}
+	d := Wrap(n.Service, types...)
+	n.Service = d
+	return d
+}
+
+// Uninstall restores the node's original network service
+func Uninstall(n *core.OpenBazaarNode) {
+	if d, ok := n.Service.(*Service); ok {
+		n.Service = d.NetworkService
+	}
+}
+
+// SetEnabled turns duplication on or off without unwrapping the service
+func (d *Service) SetEnabled(enabled bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.enabled = enabled
+}
+
+// Duplicates returns how many extra copies of the message type were sent
+func (d *Service) Duplicates(t pb.Message_MessageType) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.sent[t]
+}
+
+// Failed returns how many extra copies of the message type could not be
+// delivered
+func (d *Service) Failed(t pb.Message_MessageType) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.failed[t]
+}
+
+// SendMessage sends the message and then sends it again
+func (d *Service) SendMessage(ctx context.Context, p peer.ID, pmes *pb.Message) error {
+	if err := d.NetworkService.SendMessage(ctx, p, pmes); err != nil {
+		return err
+	}
+	if d.duplicate(pmes.MessageType) {
+		d.record(pmes.MessageType, d.NetworkService.SendMessage(ctx, p, pmes))
+	}
+	return nil
+}
+
+// SendRequest sends the request, then sends it again and returns the first
+// response. The response to the copy is discarded.
+func (d *Service) SendRequest(ctx context.Context, p peer.ID, pmes *pb.Message) (*pb.Message, error) {
+	resp, err := d.NetworkService.SendRequest(ctx, p, pmes)
+	if err != nil {
+		return nil, err
+	}
+	if d.duplicate(pmes.MessageType) {
+		_, err := d.NetworkService.SendRequest(ctx, p, pmes)
+		d.record(pmes.MessageType, err)
+	}
+	return resp, nil
+}
+
+func (d *Service) duplicate(t pb.Message_MessageType) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.enabled && d.types[t]
+}
+
+func (d *Service) record(t pb.Message_MessageType, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.sent[t]++
+	if err != nil {
+		d.failed[t]++
+		log.Debugf("duplicate %s not delivered: %s", t, err)
+	}
+}
@@ -0,0 +1,115 @@
+package escrow
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+)
+
+// RPC calls a bitcoind, e.g. a regtest.Bitcoind
+type RPC interface {
+	Call(method string, v interface{}, params ...interface{}) error
+}
+
+// UTXO is an unspent output paying an escrow
+type UTXO struct {
+	TxID          string
+	Vout          uint32
+	Sat           int64
+	Confirmations int64
+}
+
+// Watch has bitcoind track the escrow, rescanning the chain for what was
+// paid to it before
+func (e *Escrow) Watch(rpc RPC) error {
+	return rpc.Call("importaddress", nil, e.Address, "escrow "+e.OrderID, true)
+}
+
+// Unspent returns the outputs paying the escrow that bitcoind sees unspent
+// with at least minConf confirmations, mempool ones included when zero. The
+// escrow must be watched.
+func (e *Escrow) Unspent(rpc RPC, minConf int) ([]UTXO, error) {
+	var unspent []struct {
+		TxID          string  `json:"txid"`
+		Vout          uint32  `json:"vout"`
+		Amount        float64 `json:"amount"`
+		Confirmations int64   `json:"confirmations"`
+	}
+	if err := rpc.Call("listunspent", &unspent, minConf, 9999999, []string{e.Address}, true); err != nil {
+		return nil, err
+	}
+	utxos := make([]UTXO, len(unspent))
+	for i, u := range unspent {
+		sat, err := btc.NewAmount(u.Amount)
+		if err != nil {
+			return nil, err
+		}
+		utxos[i] = UTXO{TxID: u.TxID, Vout: u.Vout, Sat: int64(sat), Confirmations: u.Confirmations}
+	}
+	return utxos, nil
+}
+
+// Balance returns the satoshis the escrow holds on chain with at least
+// minConf confirmations
+func (e *Escrow) Balance(rpc RPC, minConf int) (int64, error) {
+	utxos, err := e.Unspent(rpc, minConf)
+	if err != nil {
+		return 0, err
+	}
+	var sat int64
+	for _, u := range utxos {
+		sat += u.Sat
+	}
+	return sat, nil
+}
+
+// Payouts returns what the transaction txid pays each address, to check a
+// release against the payout the nodes report. bitcoind must index
+// transactions or have the transaction in its mempool.
+func Payouts(rpc RPC, txid string) (map[string]int64, error) {
+	var tx struct {
+		Vout []struct {
+			Value        float64 `json:"value"`
+			ScriptPubKey struct {
+				Address   string   `json:"address"`
+				Addresses []string `json:"addresses"`
+			} `json:"scriptPubKey"`
+		} `json:"vout"`
+	}
+	if err := rpc.Call("getrawtransaction", &tx, txid, true); err != nil {
+		return nil, err
+	}
+	payouts := make(map[string]int64)
+	for _, out := range tx.Vout {
+		addr := out.ScriptPubKey.Address
+		if addr == "" && len(out.ScriptPubKey.Addresses) == 1 {
+			addr = out.ScriptPubKey.Addresses[0]
+		}
+		if addr == "" {
+			continue
+		}
+		sat, err := btc.NewAmount(out.Value)
+		if err != nil {
+			return nil, err
+		}
+		payouts[addr] += int64(sat)
+	}
+	return payouts, nil
+}
+
+// Broadcast sends the transaction to bitcoind's mempool and returns its ID.
+// bitcoind refusing it, e.g. a claim before the timeout, is an error.
+func Broadcast(rpc RPC, tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	var txid string
+	if err := rpc.Call("sendrawtransaction", &txid, hex.EncodeToString(buf.Bytes())); err != nil {
+		return "", fmt.Errorf("escrow: broadcasting %s: %s", tx.TxHash(), err)
+	}
+	return txid, nil
+}
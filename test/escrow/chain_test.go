@@ -0,0 +1,50 @@
+package escrow
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// fakeRPC answers calls with canned JSON by method
+type fakeRPC map[string]string
+
+func (f fakeRPC) Call(method string, v interface{}, params ...interface{}) error {
+	resp, ok := f[method]
+	if !ok {
+		return fmt.Errorf("no %s", method)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(resp), v)
+}
+
+func TestBalance(t *testing.T) {
+	e := &Escrow{Address: "bcrt1qescrow"}
+	rpc := fakeRPC{"listunspent": `[
+		{"txid": "aa", "vout": 0, "amount": 0.006, "confirmations": 3},
+		{"txid": "bb", "vout": 1, "amount": 0.00000001, "confirmations": 0}]`}
+	sat, err := e.Balance(rpc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sat != 600001 {
+		t.Errorf("Expected 600001 in escrow, got %d", sat)
+	}
+}
+
+func TestPayouts(t *testing.T) {
+	// Older bitcoind lists addresses, newer an address
+	rpc := fakeRPC{"getrawtransaction": `{"vout": [
+		{"value": 0.007, "scriptPubKey": {"addresses": ["bcrt1qbuyer"]}},
+		{"value": 0.002, "scriptPubKey": {"address": "bcrt1qmoderator"}},
+		{"value": 0, "scriptPubKey": {}}]}`}
+	payouts, err := Payouts(rpc, "cc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payouts) != 2 || payouts["bcrt1qbuyer"] != 7e5 || payouts["bcrt1qmoderator"] != 2e5 {
+		t.Errorf("Expected 700000 to the buyer and 200000 to the moderator, got %v", payouts)
+	}
+}
@@ -0,0 +1,315 @@
+// Package escrow inspects and spends the 2-of-3 multisig escrows moderated
+// orders are paid to, on a regtest chain, so dispute payouts can be checked
+// against the chain rather than against what the nodes report
+package escrow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	btc "github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// Params are the network escrow addresses are encoded for, the one nodes
+// started with a regtest wallet run on
+var Params = &chaincfg.RegressionNetParams
+
+// Threshold is how many of the escrow keys release it
+const Threshold = 2
+
+// Escrow is the address a moderated order is paid to
+type Escrow struct {
+	OrderID string
+
+	// Address is the P2WSH address of Script
+	Address string
+
+	// Script is the witness script: 2-of-3 multisig over the buyer, vendor
+	// and moderator keys, with a branch letting the vendor claim alone
+	// after Timeout when the listing sets an escrow timeout
+	Script []byte
+
+	// Chaincode derives the escrow keys from the master keys of the nodes
+	Chaincode []byte
+
+	// Amount is what the order asks to be paid, in satoshis
+	Amount uint64
+
+	// Keys are the compressed escrow public keys of the buyer, vendor and
+	// moderator, in the order of Script
+	Keys [3][]byte
+
+	// Timeout is how many blocks an output must be deep before the vendor
+	// can claim it alone, zero when the escrow has no timeout
+	Timeout uint32
+}
+
+// Key indices in Escrow.Keys
+const (
+	Buyer = iota
+	Vendor
+	Moderator
+)
+
+// order is the part of GET /ob/order escrows are read from
+type order struct {
+	Contract struct {
+		VendorListings []struct {
+			VendorID struct {
+				Pubkeys struct {
+					Bitcoin []byte `json:"bitcoin"`
+				} `json:"pubkeys"`
+			} `json:"vendorID"`
+		} `json:"vendorListings"`
+		BuyerOrder struct {
+			BuyerID struct {
+				Pubkeys struct {
+					Bitcoin []byte `json:"bitcoin"`
+				} `json:"pubkeys"`
+			} `json:"buyerID"`
+			Payment struct {
+				Method       string `json:"method"`
+				Amount       uint64 `json:"amount"`
+				Chaincode    string `json:"chaincode"`
+				Address      string `json:"address"`
+				RedeemScript string `json:"redeemScript"`
+				ModeratorKey []byte `json:"moderatorKey"`
+			} `json:"payment"`
+		} `json:"buyerOrder"`
+	} `json:"contract"`
+}
+
+// FromOrder reads the escrow of a moderated order from a node party to it.
+// It trusts nothing the node says about the escrow: the keys in the script
+// must derive from the master keys of buyer and vendor in the contract and
+// the chaincode, and the address must be the one of the script.
+func FromOrder(c *client.Client, orderID string) (*Escrow, error) {
+	raw, err := c.Order(orderID)
+	if err != nil {
+		return nil, err
+	}
+	var o order
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, fmt.Errorf("escrow: order %s: %s", orderID, err)
+	}
+	p := o.Contract.BuyerOrder.Payment
+	if p.Method != "MODERATED" {
+		return nil, fmt.Errorf("escrow: order %s is paid %s, not to an escrow", orderID, p.Method)
+	}
+	if len(o.Contract.VendorListings) == 0 {
+		return nil, fmt.Errorf("escrow: order %s has no listing", orderID)
+	}
+	e, err := Parse(p.Address, p.RedeemScript, p.Chaincode)
+	if err != nil {
+		return nil, fmt.Errorf("escrow: order %s: %s", orderID, err)
+	}
+	e.OrderID, e.Amount = orderID, p.Amount
+	for _, k := range []struct {
+		who    int
+		master []byte
+	}{
+		{Buyer, o.Contract.BuyerOrder.BuyerID.Pubkeys.Bitcoin},
+		{Vendor, o.Contract.VendorListings[0].VendorID.Pubkeys.Bitcoin},
+	} {
+		pub, err := PublicKey(k.master, e.Chaincode)
+		if err != nil {
+			return nil, fmt.Errorf("escrow: order %s: %s", orderID, err)
+		}
+		if !bytes.Equal(pub, e.Keys[k.who]) {
+			return nil, fmt.Errorf("escrow: order %s pays to %s, whose %s key does not derive from the contract", orderID, e.Address, names[k.who])
+		}
+	}
+	if !bytes.Equal(p.ModeratorKey, e.Keys[Moderator]) {
+		return nil, fmt.Errorf("escrow: order %s pays to %s, whose moderator key is not the one in the contract", orderID, e.Address)
+	}
+	return e, nil
+}
+
+var names = [3]string{"buyer", "vendor", "moderator"}
+
+// Parse checks that address is the P2WSH address of the escrow script and
+// reads its keys and timeout. Script and chaincode are hex encoded.
+func Parse(address, script, chaincode string) (*Escrow, error) {
+	s, err := hex.DecodeString(script)
+	if err != nil {
+		return nil, fmt.Errorf("bad script: %s", err)
+	}
+	cc, err := hex.DecodeString(chaincode)
+	if err != nil || len(cc) != 32 {
+		return nil, fmt.Errorf("bad chaincode %q", chaincode)
+	}
+	e := &Escrow{Script: s, Chaincode: cc}
+	if err := e.parseScript(); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(s)
+	addr, err := btc.NewAddressWitnessScriptHash(hash[:], Params)
+	if err != nil {
+		return nil, err
+	}
+	e.Address = addr.EncodeAddress()
+	if e.Address != address {
+		return nil, fmt.Errorf("address %s is not the one of its script, %s", address, e.Address)
+	}
+	return e, nil
+}
+
+// parseScript reads the keys and timeout of the script, which must be the
+// one spvwallet generates for them
+func (e *Escrow) parseScript() error {
+	s := e.Script
+	timelocked := len(s) > 0 && s[0] == txscript.OP_IF
+	if timelocked {
+		s = s[1:]
+	}
+	// OP_2 <key> <key> <key> OP_3 OP_CHECKMULTISIG
+	if len(s) < 1+3*34+2 || s[0] != txscript.OP_2 {
+		return errors.New("script is not a 2-of-3 multisig")
+	}
+	s = s[1:]
+	for i := range e.Keys {
+		if s[0] != txscript.OP_DATA_33 {
+			return errors.New("script is not a 2-of-3 multisig of compressed keys")
+		}
+		e.Keys[i] = s[1:34]
+		s = s[34:]
+	}
+	if timelocked {
+		// OP_ELSE <timeout> OP_CHECKSEQUENCEVERIFY OP_DROP <vendor key>
+		// OP_CHECKSIG OP_ENDIF
+		if len(s) < 3 || s[2] != txscript.OP_ELSE {
+			return errors.New("script has no timeout branch")
+		}
+		timeout, err := scriptNum(s[3:])
+		if err != nil {
+			return err
+		}
+		e.Timeout = timeout
+	}
+	want, err := e.build()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, e.Script) {
+		return errors.New("script is not an escrow script")
+	}
+	return nil
+}
+
+// scriptNum reads the number pushed at the start of s
+func scriptNum(s []byte) (uint32, error) {
+	switch {
+	case len(s) == 0:
+		return 0, errors.New("script ends early")
+	case s[0] >= txscript.OP_1 && s[0] <= txscript.OP_16:
+		return uint32(s[0]-txscript.OP_1) + 1, nil
+	case s[0] >= 1 && s[0] <= 4 && len(s) > int(s[0]):
+		var n uint32
+		for i := int(s[0]); i >= 1; i-- {
+			n = n<<8 | uint32(s[i])
+		}
+		return n, nil
+	}
+	return 0, errors.New("script has no timeout")
+}
+
+// build rebuilds the script from the keys and timeout
+func (e *Escrow) build() ([]byte, error) {
+	b := txscript.NewScriptBuilder()
+	if e.Timeout > 0 {
+		b.AddOp(txscript.OP_IF)
+	}
+	b.AddInt64(Threshold)
+	for _, k := range e.Keys {
+		b.AddData(k)
+	}
+	b.AddInt64(int64(len(e.Keys)))
+	b.AddOp(txscript.OP_CHECKMULTISIG)
+	if e.Timeout > 0 {
+		b.AddOp(txscript.OP_ELSE).
+			AddInt64(int64(e.Timeout)).
+			AddOp(txscript.OP_CHECKSEQUENCEVERIFY).
+			AddOp(txscript.OP_DROP).
+			AddData(e.Keys[Vendor]).
+			AddOp(txscript.OP_CHECKSIG).
+			AddOp(txscript.OP_ENDIF)
+	}
+	return b.Script()
+}
+
+// escrowKey is the extended key escrow keys are the first child of: the
+// master key of a node with the chaincode of the escrow
+func escrowKey(version, key, chaincode []byte, private bool) (*hdkeychain.ExtendedKey, error) {
+	return hdkeychain.NewExtendedKey(version, key, chaincode, []byte{0, 0, 0, 0}, 0, 0, private).Child(0)
+}
+
+// PublicKey derives the compressed escrow public key of the node whose
+// compressed master public key, as in its profile and contracts, is master
+func PublicKey(master, chaincode []byte) ([]byte, error) {
+	k, err := escrowKey(Params.HDPublicKeyID[:], master, chaincode, false)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := k.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return pub.SerializeCompressed(), nil
+}
+
+// Key derives the escrow private key of the node whose wallet was created
+// from mnemonic
+func Key(mnemonic string, chaincode []byte) (*btcec.PrivateKey, error) {
+	master, err := hdkeychain.NewMaster(bip39.NewSeed(mnemonic, ""), Params)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := master.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	k, err := escrowKey(Params.HDPrivateKeyID[:], priv.Serialize(), chaincode, true)
+	if err != nil {
+		return nil, err
+	}
+	return k.ECPrivKey()
+}
+
+// NodeKey derives the escrow private key of a node party to the escrow from
+// the mnemonic its wallet reports
+func (e *Escrow) NodeKey(c *client.Client) (*btcec.PrivateKey, error) {
+	mnemonic, err := c.Mnemonic()
+	if err != nil {
+		return nil, err
+	}
+	k, err := Key(mnemonic, e.Chaincode)
+	if err != nil {
+		return nil, err
+	}
+	if e.index(k.PubKey().SerializeCompressed()) < 0 {
+		return nil, fmt.Errorf("escrow: %s holds no key of %s", c.BaseURL, e.Address)
+	}
+	return k, nil
+}
+
+// index returns the position of the public key in Keys, -1 when it is not
+// one of them
+func (e *Escrow) index(pub []byte) int {
+	for i, k := range e.Keys {
+		if bytes.Equal(k, pub) {
+			return i
+		}
+	}
+	return -1
+}
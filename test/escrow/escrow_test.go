@@ -0,0 +1,227 @@
+package escrow
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/tyler-smith/go-bip39"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+var mnemonics = [3]string{
+	"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+	"legal winner thank year wave sausage worth useful legal winner thank yellow",
+	"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+}
+
+var chaincode = sha256.Sum256([]byte("chaincode"))
+
+// master returns the compressed master public key of the wallet created
+// from mnemonic, as contracts carry it
+func master(t *testing.T, mnemonic string) []byte {
+	m, err := hdkeychain.NewMaster(bip39.NewSeed(mnemonic, ""), Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := m.ECPubKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub.SerializeCompressed()
+}
+
+// generate builds the escrow script and address the way the buyer's wallet
+// does, with a timeout of hours when not zero
+func generate(t *testing.T, hours int64) (address string, script []byte) {
+	var keys [3][]byte
+	for i, m := range mnemonics {
+		k, err := PublicKey(master(t, m), chaincode[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = k
+	}
+	b := txscript.NewScriptBuilder()
+	if hours > 0 {
+		b.AddOp(txscript.OP_IF)
+	}
+	b.AddInt64(2).AddData(keys[0]).AddData(keys[1]).AddData(keys[2]).AddInt64(3).AddOp(txscript.OP_CHECKMULTISIG)
+	if hours > 0 {
+		b.AddOp(txscript.OP_ELSE).AddInt64(hours * 6).AddOp(txscript.OP_CHECKSEQUENCEVERIFY).AddOp(txscript.OP_DROP).
+			AddData(keys[1]).AddOp(txscript.OP_CHECKSIG).AddOp(txscript.OP_ENDIF)
+	}
+	script, err := b.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(script)
+	addr, err := btc.NewAddressWitnessScriptHash(hash[:], Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), script
+}
+
+func keys(t *testing.T) [3]*btcec.PrivateKey {
+	var ret [3]*btcec.PrivateKey
+	for i, m := range mnemonics {
+		k, err := Key(m, chaincode[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret[i] = k
+	}
+	return ret
+}
+
+// verify runs the scripts of every input of tx
+func verify(t *testing.T, e *Escrow, tx *wire.MsgTx, utxos []UTXO) error {
+	hashes := txscript.NewTxSigHashes(tx)
+	for i := range tx.TxIn {
+		vm, err := txscript.NewEngine(e.PkScript(), tx, i, txscript.StandardVerifyFlags, nil, hashes, utxos[i].Sat)
+		if err != nil {
+			return err
+		}
+		if err := vm.Execute(); err != nil {
+			return fmt.Errorf("input %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	for _, hours := range []int64{0, 1, 48} {
+		address, script := generate(t, hours)
+		e, err := Parse(address, hex.EncodeToString(script), hex.EncodeToString(chaincode[:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Timeout != uint32(hours*6) {
+			t.Errorf("Expected a timeout of %d blocks, got %d", hours*6, e.Timeout)
+		}
+		if _, err := Parse(address, hex.EncodeToString(script[:len(script)-1]), hex.EncodeToString(chaincode[:])); err == nil {
+			t.Error("Expected a truncated script to be refused")
+		}
+	}
+	address, _ := generate(t, 0)
+	_, other := generate(t, 1)
+	if _, err := Parse(address, hex.EncodeToString(other), hex.EncodeToString(chaincode[:])); err == nil {
+		t.Error("Expected an address of another script to be refused")
+	}
+}
+
+func TestRelease(t *testing.T) {
+	k := keys(t)
+	utxos := []UTXO{
+		{TxID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", Vout: 0, Sat: 6e5},
+		{TxID: "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", Vout: 1, Sat: 4e5},
+	}
+	payout, _ := generate(t, 0)
+	for _, hours := range []int64{0, 48} {
+		address, script := generate(t, hours)
+		e, err := Parse(address, hex.EncodeToString(script), hex.EncodeToString(chaincode[:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs := []Output{{payout, 7e5}, {payout, 2e5}}
+		// Keys are taken in any order
+		tx, err := e.Release(utxos, outs, k[Moderator], k[Buyer])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verify(t, e, tx, utxos); err != nil {
+			t.Errorf("Expected the release by buyer and moderator to be valid, got %s", err)
+		}
+		if _, err := e.Release(utxos, outs, k[Buyer], k[Buyer]); err == nil {
+			t.Error("Expected a release signed twice by one key to be refused")
+		}
+		if _, err := e.Release(utxos, []Output{{payout, 2e6}}, k[Buyer], k[Vendor]); err == nil {
+			t.Error("Expected a release paying more than the escrow holds to be refused")
+		}
+	}
+}
+
+func TestClaim(t *testing.T) {
+	k := keys(t)
+	utxos := []UTXO{{TxID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", Vout: 0, Sat: 1e6}}
+	payout, _ := generate(t, 0)
+	address, script := generate(t, 48)
+	e, err := Parse(address, hex.EncodeToString(script), hex.EncodeToString(chaincode[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := e.Claim(utxos, []Output{{payout, 9e5}}, k[Vendor])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(t, e, tx, utxos); err != nil {
+		t.Errorf("Expected the claim of the vendor to be valid, got %s", err)
+	}
+	// Spending before the timeout fails the lock
+	tx.TxIn[0].Sequence = e.Timeout - 1
+	if err := verify(t, e, tx, utxos); err == nil {
+		t.Error("Expected a claim before the timeout to fail")
+	}
+	if _, err := e.Claim(utxos, []Output{{payout, 9e5}}, k[Buyer]); err == nil {
+		t.Error("Expected a claim by the buyer to be refused")
+	}
+}
+
+func TestFromOrder(t *testing.T) {
+	address, script := generate(t, 48)
+	moderatorKey, err := PublicKey(master(t, mnemonics[Moderator]), chaincode[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.StdEncoding.EncodeToString
+	order := func(buyer string) string {
+		return fmt.Sprintf(`{"contract": {
+			"vendorListings": [{"vendorID": {"pubkeys": {"bitcoin": %q}}}],
+			"buyerOrder": {"buyerID": {"pubkeys": {"bitcoin": %q}}, "payment": {
+				"method": "MODERATED", "amount": 120000, "chaincode": %q, "address": %q, "redeemScript": %q, "moderatorKey": %q}}},
+			"state": "AWAITING_PAYMENT"}`,
+			b64(master(t, mnemonics[Vendor])), buyer, hex.EncodeToString(chaincode[:]), address, hex.EncodeToString(script), b64(moderatorKey))
+	}
+	body := order(b64(master(t, mnemonics[Buyer])))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wallet/mnemonic" {
+			fmt.Fprintf(w, `{"mnemonic": %q}`, mnemonics[Buyer])
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	c := client.New(srv.URL)
+
+	e, err := FromOrder(c, "QmOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Address != address || e.Amount != 120000 || e.Timeout != 288 {
+		t.Errorf("Expected the escrow at %s of 120000 with a 288 block timeout, got %s of %d with %d", address, e.Address, e.Amount, e.Timeout)
+	}
+	k, err := e.NodeKey(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := keys(t)[Buyer]; !k.PubKey().IsEqual(want.PubKey()) {
+		t.Error("Expected the buyer's escrow key from its mnemonic")
+	}
+
+	// A contract whose buyer does not hold the key in the script
+	body = order(b64(master(t, mnemonics[Moderator])))
+	if _, err := FromOrder(c, "QmOrder"); err == nil {
+		t.Error("Expected an escrow not derived from the contract to be refused")
+	}
+}
@@ -0,0 +1,148 @@
+package escrow
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+)
+
+// Output is a payout of a transaction spending an escrow
+type Output struct {
+	Address string
+	Sat     int64
+}
+
+// Release crafts the transaction paying outs from the escrow outputs utxos
+// through its multisig branch, signed with two of the escrow keys, e.g. the
+// ones of the buyer and moderator for a dispute payout. The fee is what the
+// outputs leave of the inputs.
+func (e *Escrow) Release(utxos []UTXO, outs []Output, keys ...*btcec.PrivateKey) (*wire.MsgTx, error) {
+	if len(keys) != Threshold {
+		return nil, fmt.Errorf("escrow: releasing takes %d keys, got %d", Threshold, len(keys))
+	}
+	// Signatures are checked in the order of the keys in the script
+	signers := make([]int, len(keys))
+	for i, k := range keys {
+		signers[i] = e.index(k.PubKey().SerializeCompressed())
+		if signers[i] < 0 {
+			return nil, fmt.Errorf("escrow: key %d is not a key of %s", i, e.Address)
+		}
+	}
+	if signers[0] == signers[1] {
+		return nil, fmt.Errorf("escrow: releasing takes two different keys")
+	}
+	if signers[0] > signers[1] {
+		keys = []*btcec.PrivateKey{keys[1], keys[0]}
+	}
+	tx, err := e.tx(1, 0, utxos, outs)
+	if err != nil {
+		return nil, err
+	}
+	return e.sign(tx, utxos, func(sig func(*btcec.PrivateKey) ([]byte, error)) (wire.TxWitness, error) {
+		// CHECKMULTISIG pops one element too many
+		witness := wire.TxWitness{nil}
+		for _, k := range keys {
+			s, err := sig(k)
+			if err != nil {
+				return nil, err
+			}
+			witness = append(witness, s)
+		}
+		if e.Timeout > 0 {
+			witness = append(witness, []byte{1})
+		}
+		return append(witness, e.Script), nil
+	})
+}
+
+// Claim crafts the transaction paying outs from the escrow outputs utxos
+// through its timeout branch, signed with the vendor's key alone. bitcoind
+// accepts it once every output is Timeout blocks deep.
+func (e *Escrow) Claim(utxos []UTXO, outs []Output, vendor *btcec.PrivateKey) (*wire.MsgTx, error) {
+	if e.Timeout == 0 {
+		return nil, errors.New("escrow: no timeout, the vendor cannot claim alone")
+	}
+	if e.index(vendor.PubKey().SerializeCompressed()) != Vendor {
+		return nil, fmt.Errorf("escrow: not the vendor key of %s", e.Address)
+	}
+	// Relative lock times need version 2 transactions
+	tx, err := e.tx(2, e.Timeout, utxos, outs)
+	if err != nil {
+		return nil, err
+	}
+	return e.sign(tx, utxos, func(sig func(*btcec.PrivateKey) ([]byte, error)) (wire.TxWitness, error) {
+		s, err := sig(vendor)
+		if err != nil {
+			return nil, err
+		}
+		// An empty element takes the OP_ELSE branch
+		return wire.TxWitness{s, nil, e.Script}, nil
+	})
+}
+
+// tx builds the unsigned transaction with inputs in the order of utxos
+func (e *Escrow) tx(version int32, sequence uint32, utxos []UTXO, outs []Output) (*wire.MsgTx, error) {
+	if len(utxos) == 0 {
+		return nil, errors.New("escrow: nothing to spend")
+	}
+	tx := wire.NewMsgTx(version)
+	var in, out int64
+	for _, u := range utxos {
+		hash, err := chainhash.NewHashFromStr(u.TxID)
+		if err != nil {
+			return nil, err
+		}
+		input := wire.NewTxIn(wire.NewOutPoint(hash, u.Vout), nil, nil)
+		if sequence > 0 {
+			input.Sequence = sequence
+		}
+		tx.AddTxIn(input)
+		in += u.Sat
+	}
+	for _, o := range outs {
+		addr, err := btc.DecodeAddress(o.Address, Params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(o.Sat, script))
+		out += o.Sat
+	}
+	if out > in {
+		return nil, fmt.Errorf("escrow: paying %d out of %d", out, in)
+	}
+	return tx, nil
+}
+
+// sign sets the witness of every input to what witness builds from the
+// signatures of the input
+func (e *Escrow) sign(tx *wire.MsgTx, utxos []UTXO, witness func(sig func(*btcec.PrivateKey) ([]byte, error)) (wire.TxWitness, error)) (*wire.MsgTx, error) {
+	hashes := txscript.NewTxSigHashes(tx)
+	for i := range tx.TxIn {
+		sig := func(k *btcec.PrivateKey) ([]byte, error) {
+			return txscript.RawTxInWitnessSignature(tx, hashes, i, utxos[i].Sat, e.Script, txscript.SigHashAll, k)
+		}
+		w, err := witness(sig)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].Witness = w
+	}
+	return tx, nil
+}
+
+// PkScript is the output script paying the escrow
+func (e *Escrow) PkScript() []byte {
+	hash := sha256.Sum256(e.Script)
+	script, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(hash[:]).Script()
+	return script
+}
@@ -0,0 +1,156 @@
+// Package feeapi serves a fee estimate API for the wallets of test nodes to
+// point at in place of the public one, so tests can feed them absurd
+// estimates or make the API fail. It speaks the format of
+// bitcoinfees.21.co, the default FeeAPI of openbazaard.
+package feeapi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// Fees are the estimates served, in satoshis per byte. The wallets take a
+// zero estimate, or one above their MaxFee, as MaxFee.
+type Fees struct {
+	FastestFee  uint64 `json:"fastestFee"`
+	HalfHourFee uint64 `json:"halfHourFee"`
+	HourFee     uint64 `json:"hourFee"`
+}
+
+var (
+	// Typical are estimates of a quiet network, what the API serves when
+	// started
+	Typical = Fees{FastestFee: 60, HalfHourFee: 40, HourFee: 20}
+
+	// Absurd are estimates no wallet should pay
+	Absurd = Fees{FastestFee: 1e6, HalfHourFee: 1e6, HourFee: 1e6}
+
+	// Dust are estimates below what nodes relay
+	Dust = Fees{FastestFee: 1, HalfHourFee: 1, HourFee: 1}
+)
+
+// Failure is a way the API fails instead of serving estimates
+type Failure int
+
+const (
+	// None serves the estimates
+	None Failure = iota
+
+	// ServerError answers 500
+	ServerError
+
+	// Malformed answers 200 with a body that is not JSON
+	Malformed
+
+	// Hang answers nothing until the wallet gives up, which it does after
+	// 10 seconds, or until Hold passes
+	Hang
+)
+
+func (f Failure) String() string {
+	switch f {
+	case None:
+		return "none"
+	case ServerError:
+		return "server-error"
+	case Malformed:
+		return "malformed"
+	case Hang:
+		return "hang"
+	}
+	return fmt.Sprintf("Failure(%d)", int(f))
+}
+
+// Hold bounds how long a hanging API holds a request
+const Hold = 30 * time.Second
+
+// Server is a running fee API
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+
+	mu       sync.Mutex
+	fees     Fees
+	failure  Failure
+	requests int
+}
+
+// Start serves the API on a free port of host, 127.0.0.1 when empty
+func Start(host string) (*Server, error) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: ln, done: make(chan struct{}), fees: Typical}
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handle)}
+	go s.server.Serve(ln)
+	return s, nil
+}
+
+// URL is what node wallets are pointed at
+func (s *Server) URL() string {
+	return "http://" + s.listener.Addr().String() + "/api/v1/fees/recommended"
+}
+
+// FeeAPI returns the option pointing the wallet of a node at the API
+func (s *Server) FeeAPI() nodes.Option {
+	return nodes.WithFeeAPI(s.URL())
+}
+
+// Set serves fees from now on and clears any failure
+func (s *Server) Set(fees Fees) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fees = fees
+	s.failure = None
+}
+
+// Fail makes the API fail as f from now on, None restoring the estimates
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failure = f
+}
+
+// Requests returns how many requests the API got
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Close stops the API, releasing hanging requests
+func (s *Server) Close() error {
+	close(s.done)
+	return s.server.Close()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	fees, failure := s.fees, s.failure
+	s.mu.Unlock()
+	switch failure {
+	case ServerError:
+		http.Error(w, "fee estimates unavailable", http.StatusInternalServerError)
+	case Malformed:
+		w.Write([]byte("<html>Service Unavailable</html>"))
+	case Hang:
+		select {
+		case <-time.After(Hold):
+		case <-s.done:
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"fastestFee":%d,"halfHourFee":%d,"hourFee":%d}`, fees.FastestFee, fees.HalfHourFee, fees.HourFee)
+	}
+}
@@ -0,0 +1,55 @@
+package feeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	s, err := Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	client := &http.Client{Timeout: time.Second}
+	get := func() (*Fees, int, error) {
+		resp, err := client.Get(s.URL())
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+		fees := new(Fees)
+		return fees, resp.StatusCode, json.NewDecoder(resp.Body).Decode(fees)
+	}
+
+	fees, _, err := get()
+	if err != nil || *fees != Typical {
+		t.Errorf("Expected typical estimates at start, got %+v, %v", fees, err)
+	}
+	s.Set(Absurd)
+	if fees, _, err := get(); err != nil || *fees != Absurd {
+		t.Errorf("Expected absurd estimates, got %+v, %v", fees, err)
+	}
+
+	s.Fail(ServerError)
+	if _, code, _ := get(); code != http.StatusInternalServerError {
+		t.Errorf("Expected a server error, got %d", code)
+	}
+	s.Fail(Malformed)
+	if _, code, err := get(); code != http.StatusOK || err == nil {
+		t.Errorf("Expected a malformed 200, got %d, %v", code, err)
+	}
+	s.Fail(Hang)
+	if _, _, err := get(); err == nil {
+		t.Error("Expected a hanging API to time the client out")
+	}
+	s.Set(Dust)
+	if fees, _, err := get(); err != nil || *fees != Dust {
+		t.Errorf("Expected setting estimates to clear the failure, got %+v, %v", fees, err)
+	}
+	if n := s.Requests(); n != 6 {
+		t.Errorf("Expected 6 requests, got %d", n)
+	}
+}
@@ -0,0 +1,186 @@
+// Package fingerprint groups scenario failures from many runs into distinct
+// failure modes, so a soak report counts modes rather than raw failures
+package fingerprint
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+)
+
+// Failure is one failed scenario of a run
+type Failure struct {
+	Run      string    `json:"run"`
+	Time     time.Time `json:"time"`
+	Scenario string    `json:"scenario"`
+	Step     string    `json:"step"`
+	Message  string    `json:"message"`
+
+	// Manifest is where the failed run came from
+	Manifest *manifest.Manifest `json:"manifest,omitempty"`
+}
+
+var (
+	// base58 peer IDs, CIDs and order IDs
+	idPattern = regexp.MustCompile(`\b(Qm|zb2|zdj|bafy)[1-9A-HJ-NP-Za-km-z]{20,}\b`)
+	// harness node names such as vendor-1
+	nodePattern = regexp.MustCompile(`\b([a-z]+)-\d+\b`)
+	hexPattern  = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-f]{16,}\b`)
+	addrPattern = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b|\[[0-9a-f:]+\](:\d+)?`)
+	durPattern  = regexp.MustCompile(`\b(\d+(\.\d+)?(ns|µs|us|ms|s|m|h))+\b`)
+	numPattern  = regexp.MustCompile(`\b\d+\b`)
+)
+
+// Class strips the run specific details from an error message, such as IDs,
+// addresses, durations, numbers and node numbering, leaving what kind of
+// error it was
+func Class(message string) string {
+	s := idPattern.ReplaceAllString(message, "<id>")
+	s = addrPattern.ReplaceAllString(s, "<addr>")
+	s = hexPattern.ReplaceAllString(s, "<hex>")
+	s = durPattern.ReplaceAllString(s, "<duration>")
+	s = nodePattern.ReplaceAllString(s, "$1")
+	return numPattern.ReplaceAllString(s, "<n>")
+}
+
+// Role returns the role of the first node named in the message, e.g. vendor
+// for "vendor-2 never saw the order", or an empty string
+func Role(message string) string {
+	m := nodePattern.FindStringSubmatch(message)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// Fingerprint hashes the scenario, step, error class and node role of the
+// failure
+func (f Failure) Fingerprint() string {
+	h := sha256.Sum256([]byte(strings.Join([]string{f.Scenario, f.Step, Class(f.Message), Role(f.Message)}, "\x00")))
+	return hex.EncodeToString(h[:6])
+}
+
+// Mode is a distinct way scenarios failed, with every run it was seen in
+type Mode struct {
+	Fingerprint string
+	Scenario    string
+	Step        string
+	Role        string
+	Class       string
+	Example     string
+	Count       int
+	Runs        []string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+}
+
+// Aggregate groups failures by fingerprint, most frequent first
+func Aggregate(failures []Failure) []*Mode {
+	byPrint := make(map[string]*Mode)
+	var modes []*Mode
+	for _, f := range failures {
+		fp := f.Fingerprint()
+		m, ok := byPrint[fp]
+		if !ok {
+			m = &Mode{
+				Fingerprint: fp,
+				Scenario:    f.Scenario,
+				Step:        f.Step,
+				Role:        Role(f.Message),
+				Class:       Class(f.Message),
+				Example:     f.Message,
+				FirstSeen:   f.Time,
+				LastSeen:    f.Time,
+			}
+			byPrint[fp] = m
+			modes = append(modes, m)
+		}
+		m.Count++
+		if len(m.Runs) == 0 || m.Runs[len(m.Runs)-1] != f.Run {
+			m.Runs = append(m.Runs, f.Run)
+		}
+		if f.Time.Before(m.FirstSeen) {
+			m.FirstSeen = f.Time
+		}
+		if f.Time.After(m.LastSeen) {
+			m.LastSeen = f.Time
+		}
+	}
+	sort.SliceStable(modes, func(i, j int) bool { return modes[i].Count > modes[j].Count })
+	return modes
+}
+
+// Append adds failures to a JSON lines file shared by many runs
+func Append(path string, failures []Failure) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, failure := range failures {
+		if err := enc.Encode(failure); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Load reads failures written by Append
+func Load(path string) ([]Failure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var failures []Failure
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var failure Failure
+		if err := json.Unmarshal(scanner.Bytes(), &failure); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		failures = append(failures, failure)
+	}
+	return failures, scanner.Err()
+}
+
+// WriteSummary prints how many distinct failure modes the failures fall
+// into, and each mode with its count
+func WriteSummary(w io.Writer, failures []Failure) error {
+	modes := Aggregate(failures)
+	runs := make(map[string]bool)
+	for _, f := range failures {
+		runs[f.Run] = true
+	}
+	if _, err := fmt.Fprintf(w, "%d distinct failure modes in %d failures across %d runs\n", len(modes), len(failures), len(runs)); err != nil {
+		return err
+	}
+	for _, m := range modes {
+		step := m.Scenario
+		if m.Step != "" && m.Step != m.Scenario {
+			step += " / " + m.Step
+		}
+		if m.Role != "" {
+			step += " (" + m.Role + ")"
+		}
+		if _, err := fmt.Fprintf(w, "\n%s  %dx in %d runs, last %s\n  %s\n  %s\n", m.Fingerprint, m.Count, len(m.Runs), m.LastSeen.Format("2006-01-02 15:04"), step, m.Class); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package fingerprint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClass(t *testing.T) {
+	a := "vendor-1: order QmZ6zKyoWfFWRp3ob7nXZGBJ3zVwSzSGaN39AtJqL2bFtK is AWAITING_PAYMENT after 2m0s, expected 3 of 4 at 127.0.0.1:4002"
+	b := "vendor-3: order QmQBKFBLDuvz3NZNeSPqWCGRYH2wjxT7BQEE6CWdRkBEBf is AWAITING_PAYMENT after 45.5s, expected 1 of 2 at 10.0.0.4:4102"
+	if Class(a) != Class(b) {
+		t.Errorf("Expected the same class, got\n%s\n%s", Class(a), Class(b))
+	}
+	if Role(a) != "vendor" {
+		t.Errorf("Expected role vendor, got %q", Role(a))
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Now()
+	var failures []Failure
+	for run := 0; run < 3; run++ {
+		id := string('a' + rune(run))
+		failures = append(failures,
+			Failure{Run: id, Time: now, Scenario: "checkout", Step: "pay", Message: "buyer-1: spend failed after 3 attempts"},
+			Failure{Run: id, Time: now, Scenario: "checkout", Step: "pay", Message: "buyer-2: spend failed after 5 attempts"},
+			Failure{Run: id, Time: now, Scenario: "chat", Message: "vendor-1 never saw message 17"},
+		)
+	}
+	failures = append(failures, Failure{Run: "c", Time: now, Scenario: "chat", Message: "buyer-1 never saw message 3"})
+	modes := Aggregate(failures)
+	if len(modes) != 3 {
+		t.Fatalf("Expected 3 modes, got %d", len(modes))
+	}
+	if modes[0].Count != 6 || len(modes[0].Runs) != 3 {
+		t.Errorf("Expected the spend failure 6 times in 3 runs, got %d in %v", modes[0].Count, modes[0].Runs)
+	}
+
+	dir, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "failures.jsonl")
+	if err := Append(path, failures[:5]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(path, failures[5:]); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteSummary(&b, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "3 distinct failure modes in 10 failures across 3 runs") {
+		t.Errorf("Unexpected summary:\n%s", b.String())
+	}
+}
@@ -0,0 +1,71 @@
+package fixtures
+
+// Networks addresses are valid on. Regtest nodes take testnet addresses,
+// bech32 ones included.
+const (
+	Mainnet = "mainnet"
+	Testnet = "testnet"
+)
+
+// Address is an entry of the address corpus
+type Address struct {
+	Coin    string
+	Network string
+	Address string
+
+	// Valid is whether a node of the coin on the network must take the
+	// address
+	Valid bool
+
+	// Kind says what sort of address it is or what is wrong with it
+	Kind string
+}
+
+// Addresses is the corpus of valid and invalid wallet addresses per coin
+// and network, checked against btcutil. Invalid entries cover the wrong
+// network, broken checksums and the bech32 rules of BIP 173.
+var Addresses = []Address{
+	{"BTC", Mainnet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", true, "p2pkh"},
+	{"BTC", Mainnet, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", true, "p2sh"},
+	{"BTC", Mainnet, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", true, "bech32 p2wpkh"},
+	{"BTC", Mainnet, "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3", true, "bech32 p2wsh"},
+	{"BTC", Mainnet, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", true, "bech32 upper case"},
+	{"BTC", Mainnet, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", false, "testnet p2pkh prefix"},
+	{"BTC", Mainnet, "2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", false, "testnet p2sh prefix"},
+	{"BTC", Mainnet, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", false, "testnet bech32 prefix"},
+	{"BTC", Mainnet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", false, "base58 checksum"},
+	{"BTC", Mainnet, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", false, "bech32 checksum"},
+	{"BTC", Mainnet, "bc1qW508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false, "bech32 mixed case"},
+	{"BTC", Mainnet, "tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty", false, "bech32 unknown prefix"},
+	{"BTC", Mainnet, "bc1rw5uspcuh", false, "bech32 program too short"},
+	{"BTC", Mainnet, "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", false, "bech32 v0 program of 16 bytes"},
+	{"BTC", Mainnet, "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx", false, "bech32 witness v1"},
+	{"BTC", Mainnet, " 1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", false, "leading space"},
+	{"BTC", Mainnet, "not-an-address", false, "garbage"},
+	{"BTC", Mainnet, "", false, "empty"},
+
+	{"BTC", Testnet, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", true, "p2pkh"},
+	{"BTC", Testnet, "mfX6kF9N9SjZ4suVAMyLytjEYnjJwmfKdF", true, "p2pkh"},
+	{"BTC", Testnet, "2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", true, "p2sh"},
+	{"BTC", Testnet, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", true, "bech32 p2wpkh"},
+	{"BTC", Testnet, "tb1qqqrsu9guyv4rzwplgex4gkmzd9c8wl59wc5mk4", true, "bech32 p2wpkh"},
+	{"BTC", Testnet, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", false, "mainnet p2pkh prefix"},
+	{"BTC", Testnet, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", false, "mainnet p2sh prefix"},
+	{"BTC", Testnet, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false, "mainnet bech32 prefix"},
+	{"BTC", Testnet, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfo", false, "base58 checksum"},
+	{"BTC", Testnet, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsy", false, "bech32 checksum"},
+	{"BTC", Testnet, "tb1qW508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", false, "bech32 mixed case"},
+	{"BTC", Testnet, "not-an-address", false, "garbage"},
+	{"BTC", Testnet, "", false, "empty"},
+}
+
+// AddressesFor returns the corpus entries of the coin on the network
+func AddressesFor(coin, network string) []Address {
+	var ret []Address
+	for _, a := range Addresses {
+		if a.Coin == coin && a.Network == network {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,27 @@
+package fixtures
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+func TestAddressCorpus(t *testing.T) {
+	params := map[string][]*chaincfg.Params{
+		Mainnet: {&chaincfg.MainNetParams},
+		Testnet: {&chaincfg.TestNet3Params, &chaincfg.RegressionNetParams},
+	}
+	for _, a := range Addresses {
+		for _, p := range params[a.Network] {
+			decoded, err := btcutil.DecodeAddress(a.Address, p)
+			valid := err == nil && decoded.IsForNet(p)
+			if valid != a.Valid {
+				t.Errorf("%s on %s (%s): expected valid %v, btcutil says %v (%v)", a.Address, p.Name, a.Kind, a.Valid, valid, err)
+			}
+		}
+	}
+	if len(AddressesFor("BTC", Testnet)) == 0 || len(AddressesFor("BTC", "signet")) != 0 {
+		t.Error("Unexpected corpus selection")
+	}
+}
@@ -0,0 +1,43 @@
+package fixtures
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chat limits enforced by POST /ob/chat, in bytes. The API has no
+// attachments, so a chat is at most a subject and a message of these sizes.
+const (
+	MaxChatSubject = 500
+	MaxChatMessage = 20000
+)
+
+// chatText mixes one to four byte characters and a newline so truncation
+// or re-encoding anywhere on the way shows up as a changed message
+const chatText = "Größe L, 颜色 红, 🚚 shipped\n"
+
+// LargeChat returns a subject and message of exactly the maximum size
+func LargeChat() (subject, message string) {
+	return fill(MaxChatSubject), fill(MaxChatMessage)
+}
+
+// OversizedChat returns a message one byte over the maximum size
+func OversizedChat() string {
+	return fill(MaxChatMessage) + "x"
+}
+
+// fill repeats chatText up to n bytes without splitting a character and pads
+// the rest with ASCII
+func fill(n int) string {
+	var b bytes.Buffer
+	for b.Len() < n {
+		for _, r := range chatText {
+			if b.Len()+utf8.RuneLen(r) > n {
+				return b.String() + strings.Repeat(".", n-b.Len())
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
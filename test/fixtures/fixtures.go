@@ -0,0 +1,335 @@
+// Package fixtures holds the request bodies used by the qa suite so Go
+// scenarios post exactly what the python tests do
+package fixtures
+
+import "encoding/json"
+
+// Listing returns the physical good listing from qa/testdata/listing.json
+func Listing() map[string]interface{} {
+	return decode(listingJSON)
+}
+
+// ModeratedListing returns the listing with the given moderators attached
+func ModeratedListing(moderators ...string) map[string]interface{} {
+	l := Listing()
+	mods := make([]interface{}, len(moderators))
+	for n, m := range moderators {
+		mods[n] = m
+	}
+	l["moderators"] = mods
+	return l
+}
+
+// DirectOrder returns a purchase of one item of listingHash without a
+// moderator
+func DirectOrder(listingHash string) map[string]interface{} {
+	o := decode(orderDirectJSON)
+	o["items"].([]interface{})[0].(map[string]interface{})["listingHash"] = listingHash
+	return o
+}
+
+// ModeratedOrder returns a purchase of one item of listingHash moderated by
+// moderator
+func ModeratedOrder(listingHash, moderator string) map[string]interface{} {
+	o := DirectOrder(listingHash)
+	o["moderator"] = moderator
+	return o
+}
+
+// Fulfillment returns a physical delivery fulfillment for the order
+func Fulfillment(orderID, slug string) map[string]interface{} {
+	f := decode(fulfillmentJSON)
+	f["orderId"] = orderID
+	f["slug"] = slug
+	return f
+}
+
+// Completion returns an order completion with a single rating for slug
+func Completion(orderID, slug string) map[string]interface{} {
+	c := decode(completionJSON)
+	c["orderId"] = orderID
+	c["ratings"].([]interface{})[0].(map[string]interface{})["slug"] = slug
+	return c
+}
+
+// Moderation returns the moderator settings from qa/testdata/moderation.json
+func Moderation() map[string]interface{} {
+	return decode(moderationJSON)
+}
+
+// decode returns a fresh copy of a fixture so callers may modify it
+func decode(s string) map[string]interface{} {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		panic("fixtures: " + err.Error())
+	}
+	return m
+}
+
+const listingJSON = `{
+	"slug": "",
+	"metadata": {
+		"version": 1,
+		"contractType": "PHYSICAL_GOOD",
+		"format": "FIXED_PRICE",
+		"expiry": "2030-08-17T04:52:19.000Z",
+		"pricingCurrency": "tbtc"
+	},
+	"item": {
+		"title": "Ron Swanson Tshirt",
+		"description": "Kick ass ron swanson tshirt in yellow",
+		"processingTime": "1 to 2 Business days",
+		"price": 12000000,
+		"tags": [
+			"tshirts",
+			"clothing",
+			"ron swanson"
+		],
+		"images": [
+			{
+				"tiny": "zb2rhjqhgN4Pv1SJFNpCQMjv2h8PQEGqAioMhkZjkKDyPW5E2",
+				"small": "zb2rhXn3SHBuEXkHxrupGfjKcuMewMdUJyN6jLMYDEzCyue15",
+				"medium": "zb2rhcdaKnikBPyNT83ngqnkaAuunqCR7craN34iNKJruXhDF",
+				"large": "zb2rhbQY5U167P6jic6c7UZ1x6AXXcmrhfcKKeNbJdwGJxXJi",
+				"original": "zb2rhdTuwSdUe3DP9KuHjiwhoM5ppb6ws4i6j2rWFF3yTTP92",
+				"filename": "swanson.jpg"
+			}
+		],
+		"categories": [
+			"clothing"
+		],
+		"grams": 28,
+		"condition": "New",
+		"options": [
+			{
+				"name": "Size",
+				"description": "What size do you want your shirt?",
+				"variants": [
+					{
+						"name": "Small",
+						"image": {
+							"tiny": "zb2rhjqhgN4Pv1SJFNpCQMjv2h8PQEGqAioMhkZjkKDyPW5E2",
+							"small": "zb2rhXn3SHBuEXkHxrupGfjKcuMewMdUJyN6jLMYDEzCyue15",
+							"medium": "zb2rhcdaKnikBPyNT83ngqnkaAuunqCR7craN34iNKJruXhDF",
+							"large": "zb2rhbQY5U167P6jic6c7UZ1x6AXXcmrhfcKKeNbJdwGJxXJi",
+							"original": "zb2rhdTuwSdUe3DP9KuHjiwhoM5ppb6ws4i6j2rWFF3yTTP92",
+							"filename": "swanson.jpg"
+						}
+					},
+					{
+						"name": "Medium"
+					},
+					{
+						"name": "Large"
+					},
+					{
+						"name": "XL"
+					}
+				]
+			},
+			{
+				"name": "Color",
+				"description": "What color do you want your shirt?",
+				"variants": [
+					{
+						"name": "Red"
+					},
+					{
+						"name": "Yellow"
+					}
+				]
+			}
+		],
+		"skus": [
+			{
+				"variantCombo": [0,0],
+				"productID": "932-33-2945",
+				"surcharge": 0,
+				"quantity": 12
+			},
+			{
+				"variantCombo": [0,1],
+				"surcharge": 0,
+				"quantity": 100
+			},
+			{
+				"variantCombo": [1,0],
+				"productID": "123-99-1111",
+				"surcharge": 0,
+				"quantity": 44
+			},
+			{
+				"variantCombo": [1,1],
+				"productID": "229-00-3333",
+				"surcharge": 0,
+				"quantity": 19
+			},
+			{
+				"variantCombo": [2,0],
+				"productID": "987-54-3456",
+				"surcharge": 0,
+				"quantity": 7
+			},
+			{
+				"variantCombo": [2,1],
+				"surcharge": 0,
+				"quantity": 3
+			},
+			{
+				"variantCombo": [3,0],
+				"surcharge": 1000,
+				"quantity": 16
+			},
+			{
+				"variantCombo": [3,1],
+				"surcharge": 1000,
+				"quantity": 12
+			}
+		]
+	},
+	"shippingOptions": [
+	{
+		"name": "Domestic Shipping",
+		"type": "FIXED_PRICE",
+		"regions": [
+			"UNITED_STATES"
+		],
+		"services": [
+			{
+				"name": "Standard",
+				"price": 6000000,
+				"estimatedDelivery": "4-6 days"
+			},
+			{
+				"name": "Express",
+				"price": 12000000,
+				"estimatedDelivery": "1-3 days"
+			}
+		]
+	},
+	{
+		"name": "International Shipping",
+		"type": "FIXED_PRICE",
+		"regions": [
+			"ALL"
+		],
+		"services": [
+			{
+				"name": "Standard",
+				"price": 8000000,
+				"estimatedDelivery": "6-8 days"
+			},
+			{
+				"name": "Express",
+				"price": 150000000,
+				"estimatedDelivery": "2-3 days"
+			}
+		],
+		"shippingRules": {
+			"ruleType": "QUANTITY_DISCOUNT",
+			"rules": [
+				{
+					"price": 10000000,
+					"minRange": 5,
+					"maxRange": 10
+				},
+				{
+					"price": 200000,
+					"minRange": 11,
+					"maxRange": 20
+				}
+			]
+		 }
+	}
+	],
+	"taxes": [
+	{
+		"taxType": "Sales tax",
+		"taxRegions": [
+			"UNITED_STATES"
+		],
+		"taxShipping": true,
+		"percentage": 7
+	}
+	],
+	"coupons": [
+	{
+		"title": "10% off",
+		"discountCode": "radio",
+		"percentDiscount": 10.0
+	}
+	],
+	"moderators": [],
+	"termsAndConditions": "NA",
+	"refundPolicy": "No refuns for you. All sales are final."
+}`
+
+const orderDirectJSON = `{
+	"shipTo": "Seymour Butts",
+	"address": "31 Spooner Street",
+	"city": "Quahog",
+	"state": "RI",
+	"postalCode": "00093",
+	"countryCode": "UNITED_STATES",
+	"addressNotes": "",
+	"moderator": "",
+	"items": [
+		{
+			"listingHash": "",
+			"quantity": 1,
+			"options": [
+				{
+					"name": "Color",
+					"value": "Red"
+				},
+				{
+					"name": "Size",
+					"value": "Large"
+				}
+			],
+			"shipping": {
+					"name": "Domestic Shipping",
+					"service": "Standard"
+			},
+			"memo": "thanks!",
+			"coupons": ["discount"]
+		}
+	]
+}`
+
+const fulfillmentJSON = `{
+    "orderId": "",
+    "slug": "",
+    "physicalDelivery": [
+        {
+	    "shipper": "UPS",
+	    "trackingNumber": "1234"
+	    }
+    ]
+}`
+
+const completionJSON = `{
+  "orderId": "",
+  "ratings": [
+      {
+          "slug": "",
+          "overall": 4,
+          "quality": 5,
+          "description": 5,
+          "customerService": 4,
+          "deliverySpeed": 3,
+          "review": "I love it!",
+          "anonymous": true
+      }
+  ]
+}`
+
+const moderationJSON = `{
+	"description": "I am a moderator!!!",
+	"termsAndConditions": "I moderate stuff",
+	"languages": ["english"],
+	"fee": {
+			"feeType": "PERCENTAGE",
+			"percentage": 10.0
+	}
+}`
@@ -0,0 +1,110 @@
+package fixtures
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFixturesAreCopies(t *testing.T) {
+	a := DirectOrder("QmListing")
+	b := DirectOrder("QmOther")
+	itemA := a["items"].([]interface{})[0].(map[string]interface{})
+	itemB := b["items"].([]interface{})[0].(map[string]interface{})
+	if itemA["listingHash"] != "QmListing" || itemB["listingHash"] != "QmOther" {
+		t.Error("Orders share state")
+	}
+}
+
+func TestModeratedOrder(t *testing.T) {
+	o := ModeratedOrder("QmListing", "QmModerator")
+	if o["moderator"] != "QmModerator" {
+		t.Errorf("Expected moderator QmModerator, got %v", o["moderator"])
+	}
+	l := ModeratedListing("QmModerator")
+	mods := l["moderators"].([]interface{})
+	if len(mods) != 1 || mods[0] != "QmModerator" {
+		t.Errorf("Unexpected moderators %v", mods)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := Completion("order", "ron-swanson-tshirt")
+	rating := c["ratings"].([]interface{})[0].(map[string]interface{})
+	if c["orderId"] != "order" || rating["slug"] != "ron-swanson-tshirt" {
+		t.Error("Completion not filled in")
+	}
+	if Fulfillment("order", "slug")["orderId"] != "order" {
+		t.Error("Fulfillment not filled in")
+	}
+	if Listing()["slug"] != "" || Moderation()["description"] == "" {
+		t.Error("Unexpected fixture content")
+	}
+}
+
+func TestLargeChat(t *testing.T) {
+	subject, message := LargeChat()
+	if len(subject) != MaxChatSubject || len(message) != MaxChatMessage {
+		t.Errorf("Expected %d and %d bytes, got %d and %d", MaxChatSubject, MaxChatMessage, len(subject), len(message))
+	}
+	if !utf8.ValidString(subject) || !utf8.ValidString(message) {
+		t.Error("Expected valid UTF-8")
+	}
+	if utf8.RuneCountInString(message) == len(message) {
+		t.Error("Expected multibyte characters in the message")
+	}
+	if len(OversizedChat()) != MaxChatMessage+1 {
+		t.Errorf("Expected %d bytes, got %d", MaxChatMessage+1, len(OversizedChat()))
+	}
+}
+
+func TestTaxonomy(t *testing.T) {
+	tx := Taxonomy{Depth: 3, Fanout: 2, Tags: 4}
+	if err := tx.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Leaves() != 8 {
+		t.Errorf("Expected 8 leaves, got %d", tx.Leaves())
+	}
+	if len(tx.Categories()) != 2+4+8 {
+		t.Errorf("Expected 14 categories, got %d", len(tx.Categories()))
+	}
+	path := tx.Path(5)
+	want := []string{"cat-2", "cat-2.1", "cat-2.1.2"}
+	for i := range want {
+		if path[i] != want[i] {
+			t.Fatalf("Expected path %v, got %v", want, path)
+		}
+	}
+
+	l := tx.StoreListing(13)
+	item := l["item"].(map[string]interface{})
+	if item["title"] != StoreTitle(13) {
+		t.Errorf("Unexpected title %v", item["title"])
+	}
+	if c := item["categories"].([]interface{}); len(c) != 3 || c[2] != "cat-2.1.2" {
+		t.Errorf("Unexpected categories %v", c)
+	}
+	tags := item["tags"].([]interface{})
+	seen := make(map[interface{}]bool)
+	for _, tag := range tags {
+		if seen[tag] {
+			t.Errorf("Duplicate tag %v", tag)
+		}
+		seen[tag] = true
+	}
+	if len(tags) != 4 {
+		t.Errorf("Expected the tag count capped at the pool size, got %d", len(tags))
+	}
+}
+
+func TestTaxonomyLimits(t *testing.T) {
+	if err := (Taxonomy{Depth: MaxCategories + 1, Fanout: 1}).Validate(); err == nil {
+		t.Error("Expected an error for a tree deeper than the category limit")
+	}
+	if err := (Taxonomy{Depth: 10, Fanout: 2}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (Taxonomy{Depth: 10, Fanout: 100}).Validate(); err == nil {
+		t.Error("Expected an error for category names over the word limit")
+	}
+}
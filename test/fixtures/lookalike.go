@@ -0,0 +1,116 @@
+package fixtures
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Lookalike is a handle or peer ID a reader could take for another one
+type Lookalike struct {
+	Value string
+
+	// Kind says how it differs from the original
+	Kind string
+
+	// Malformed is set on peer IDs that do not even decode
+	Malformed bool
+}
+
+// Handle is the profile handle of the genuine vendor in lookalike fixtures.
+// Handles may not contain @, which clients prepend.
+const Handle = "tees"
+
+// homoglyphs are Cyrillic letters rendered like Latin ones, escaped so they
+// do not pass for Latin here either
+var homoglyphs = map[rune]rune{
+	'a': '\u0430', 'c': '\u0441', 'e': '\u0435', 'i': '\u0456',
+	'o': '\u043e', 'p': '\u0440', 'x': '\u0445', 'y': '\u0443',
+}
+
+// digits are digits read as letters
+var digits = map[rune]rune{
+	'b': '8', 'e': '3', 'g': '9', 'i': '1', 'l': '1', 'o': '0', 's': '5', 't': '7',
+}
+
+// LookalikeHandles returns handles that read like handle but are not it.
+// Every one of them passes the profile validation of openbazaard.
+func LookalikeHandles(handle string) []Lookalike {
+	var ret []Lookalike
+	add := func(value, kind string) {
+		if value != handle {
+			ret = append(ret, Lookalike{Value: value, Kind: kind})
+		}
+	}
+	add(strings.ToUpper(handle[:1])+handle[1:], "capitalised")
+	add(replaceFirst(handle, homoglyphs), "cyrillic homoglyph")
+	add(replaceFirst(handle, digits), "digit for a letter")
+	add(handle[:1]+"\u200b"+handle[1:], "zero-width space")
+	add(handle+" ", "trailing space")
+	add(strings.Map(fullWidth, handle), "full-width letters")
+	add("\u202e"+reverse(handle), "right-to-left override of the reversed handle")
+	return ret
+}
+
+// base58Alphabet is the alphabet peer IDs are encoded in
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// LookalikePeerIDs returns peer IDs that read like peerID but are not it.
+// The well-formed ones name peers that do not exist.
+func LookalikePeerIDs(peerID string) []Lookalike {
+	var ret []Lookalike
+	last := len(peerID) - 1
+	// Changes to the second half leave the multihash prefix and length
+	// alone, so the ID still decodes
+	mid := len(peerID) / 2
+	for i := last; i >= mid; i-- {
+		if j := strings.IndexByte(base58Alphabet, peerID[i]); j >= 0 {
+			ret = append(ret, Lookalike{Value: peerID[:i] + string(base58Alphabet[(j+1)%len(base58Alphabet)]) + peerID[i+1:], Kind: "one character off"})
+			break
+		}
+	}
+	for i := mid; i < last; i++ {
+		if peerID[i] != peerID[i+1] {
+			ret = append(ret, Lookalike{Value: peerID[:i] + string(peerID[i+1]) + string(peerID[i]) + peerID[i+2:], Kind: "adjacent characters swapped"})
+			break
+		}
+	}
+	for i := mid; i <= last; i++ {
+		c := rune(peerID[i])
+		flipped := unicode.ToUpper(c)
+		if flipped == c {
+			flipped = unicode.ToLower(c)
+		}
+		if flipped != c && strings.ContainsRune(base58Alphabet, flipped) {
+			ret = append(ret, Lookalike{Value: peerID[:i] + string(flipped) + peerID[i+1:], Kind: "case of a character flipped"})
+			break
+		}
+	}
+	if i := strings.IndexByte(peerID, 'm'); i >= 0 {
+		ret = append(ret, Lookalike{Value: peerID[:i] + "rn" + peerID[i+1:], Kind: "rn for m", Malformed: true})
+	}
+	return append(ret, Lookalike{Value: peerID[:last], Kind: "last character dropped", Malformed: true})
+}
+
+func replaceFirst(s string, with map[rune]rune) string {
+	for i, r := range s {
+		if w, ok := with[unicode.ToLower(r)]; ok {
+			return s[:i] + string(w) + s[i+len(string(r)):]
+		}
+	}
+	return s
+}
+
+func fullWidth(r rune) rune {
+	if r > ' ' && r <= '~' {
+		return r - '!' + '\uff01'
+	}
+	return r
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
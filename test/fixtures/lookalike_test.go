@@ -0,0 +1,53 @@
+package fixtures
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestLookalikeHandles(t *testing.T) {
+	handles := LookalikeHandles(Handle)
+	if len(handles) < 7 {
+		t.Fatalf("Expected a lookalike of every kind, got %v", handles)
+	}
+	seen := map[string]bool{Handle: true}
+	for _, h := range handles {
+		if seen[h.Value] {
+			t.Errorf("%s lookalike %q is not new", h.Kind, h.Value)
+		}
+		seen[h.Value] = true
+		// What openbazaard validates profiles with
+		if strings.Contains(h.Value, "@") || len(h.Value) > 40 || !utf8.ValidString(h.Value) {
+			t.Errorf("%s lookalike %q would be refused as a handle", h.Kind, h.Value)
+		}
+	}
+}
+
+func TestLookalikePeerIDs(t *testing.T) {
+	const id = "QmXiz9xo6Hf2ysmb2hBNfbrKWtyvqV9yqrmv9MbBEhWumG"
+	if !decodes(id) {
+		t.Fatalf("%s does not decode", id)
+	}
+	ids := LookalikePeerIDs(id)
+	if len(ids) != 5 {
+		t.Fatalf("Expected five lookalikes, got %v", ids)
+	}
+	for _, l := range ids {
+		if l.Value == id {
+			t.Errorf("%s lookalike is the peer ID itself", l.Kind)
+		}
+		if decodes(l.Value) == l.Malformed {
+			t.Errorf("Expected %s lookalike %s to be malformed %v", l.Kind, l.Value, l.Malformed)
+		}
+	}
+}
+
+// decodes tells whether id is a base58 sha2-256 multihash, as peer IDs of
+// RSA keys are
+func decodes(id string) bool {
+	b := base58.Decode(id)
+	return len(b) == 34 && b[0] == 0x12 && b[1] == 0x20 && base58.Encode(b) == id
+}
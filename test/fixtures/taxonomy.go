@@ -0,0 +1,142 @@
+package fixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Listing limits enforced by POST /ob/listing that bound a taxonomy
+const (
+	MaxCategories = 10
+	MaxTags       = 10
+	MaxWord       = 40
+)
+
+// Taxonomy is a category tree with Fanout children under every category,
+// Depth levels deep, plus a pool of tags. Categories are flat strings in a
+// listing, so a listing carries every category on the path from the root
+// to its leaf and filtering by any category finds the whole subtree.
+type Taxonomy struct {
+	Depth  int
+	Fanout int
+
+	// Tags is the size of the tag pool, each listing gets up to MaxTags of it
+	Tags int
+}
+
+// Validate returns an error if listings of the taxonomy would be rejected
+func (t Taxonomy) Validate() error {
+	switch {
+	case t.Depth < 1 || t.Fanout < 1:
+		return fmt.Errorf("taxonomy needs a depth and fanout of at least 1")
+	case t.Depth > MaxCategories:
+		return fmt.Errorf("depth %d exceeds the %d categories a listing may have", t.Depth, MaxCategories)
+	case len(t.longest()) > MaxWord:
+		return fmt.Errorf("category names of depth %d exceed %d characters", t.Depth, MaxWord)
+	}
+	return nil
+}
+
+// Leaves returns the number of leaf categories
+func (t Taxonomy) Leaves() int {
+	n := 1
+	for i := 0; i < t.Depth; i++ {
+		n *= t.Fanout
+	}
+	return n
+}
+
+// Path returns the categories from the root to leaf, e.g. cat-2, cat-2.1,
+// cat-2.1.3
+func (t Taxonomy) Path(leaf int) []string {
+	idx := t.leafPath(leaf)
+	path := make([]string, len(idx))
+	for i := range idx {
+		path[i] = t.category(idx[:i+1])
+	}
+	return path
+}
+
+// Categories returns every category of the tree, parents before children
+func (t Taxonomy) Categories() []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for level := 1; level <= t.Depth; level++ {
+		for leaf := 0; leaf < t.Leaves(); leaf++ {
+			c := t.Path(leaf)[level-1]
+			if !seen[c] {
+				seen[c] = true
+				ret = append(ret, c)
+			}
+		}
+	}
+	return ret
+}
+
+// ListingTags returns the tags of the nth listing of a store. Listings walk
+// the pool so every tag is shared by several listings and tag sets overlap.
+func (t Taxonomy) ListingTags(n int) []string {
+	if t.Tags == 0 {
+		return nil
+	}
+	count := n%MaxTags + 1
+	if count > t.Tags {
+		count = t.Tags
+	}
+	tags := make([]string, count)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag-%d", (n*3+i)%t.Tags)
+	}
+	return tags
+}
+
+// StoreListing returns the nth listing of a store with the taxonomy. The
+// listing is filed under leaf n modulo the number of leaves.
+func (t Taxonomy) StoreListing(n int) map[string]interface{} {
+	l := Listing()
+	item := l["item"].(map[string]interface{})
+	item["title"] = StoreTitle(n)
+	categories := make([]interface{}, 0, t.Depth)
+	for _, c := range t.Path(n % t.Leaves()) {
+		categories = append(categories, c)
+	}
+	item["categories"] = categories
+	tags := make([]interface{}, 0, MaxTags)
+	for _, tag := range t.ListingTags(n) {
+		tags = append(tags, tag)
+	}
+	item["tags"] = tags
+	return l
+}
+
+// StoreTitle is the title of the nth store listing
+func StoreTitle(n int) string {
+	return fmt.Sprintf("taxonomy item %d", n)
+}
+
+// leafPath returns the child index taken at every level to reach leaf
+func (t Taxonomy) leafPath(leaf int) []int {
+	idx := make([]int, t.Depth)
+	for i := t.Depth - 1; i >= 0; i-- {
+		idx[i] = leaf%t.Fanout + 1
+		leaf /= t.Fanout
+	}
+	return idx
+}
+
+// longest returns the name of the last, and longest, leaf category
+func (t Taxonomy) longest() string {
+	idx := make([]int, t.Depth)
+	for i := range idx {
+		idx[i] = t.Fanout
+	}
+	return t.category(idx)
+}
+
+func (t Taxonomy) category(idx []int) string {
+	parts := make([]string, len(idx))
+	for i, n := range idx {
+		parts[i] = fmt.Sprint(n)
+	}
+	return "cat-" + strings.Join(parts, ".")
+}
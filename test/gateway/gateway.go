@@ -0,0 +1,143 @@
+// Package gateway checks how a node's IPFS gateway serves store content:
+// directory indexes, range requests and content types.
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// ChunkSize is the size of the leaf blocks files are split into when added,
+// ranges crossing a multiple of it span two blocks
+const ChunkSize = 256 * 1024
+
+var hrefPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+// DirectoryIndex fetches the generated HTML index of dir, a /ipfs/ or /ipns/
+// path, and returns the names of the entries it links to
+func DirectoryIndex(c *client.Client, dir string) ([]string, error) {
+	dir = strings.TrimRight(dir, "/")
+	resp, err := c.Get(dir + "/")
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, fmt.Errorf("index of %s: %s", dir, err)
+	}
+	if err := mediaType(resp, "text/html"); err != nil {
+		return nil, fmt.Errorf("index of %s: %s", dir, err)
+	}
+	var names []string
+	for _, m := range hrefPattern.FindAllSubmatch(resp.Body, -1) {
+		u, err := url.Parse(string(m[1]))
+		if err != nil {
+			continue
+		}
+		// Skip the back link and the stylesheet and icon links
+		if path.Dir(u.Path) != dir || path.Base(u.Path) == ".." {
+			continue
+		}
+		names = append(names, path.Base(u.Path))
+	}
+	return names, nil
+}
+
+// CheckIndex returns an error unless the index of dir lists every name
+func CheckIndex(c *client.Client, dir string, names ...string) error {
+	listed, err := DirectoryIndex(c, dir)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if !contains(listed, n) {
+			return fmt.Errorf("index of %s lists %v, missing %s", dir, listed, n)
+		}
+	}
+	return nil
+}
+
+// CheckRange requests bytes first to last, inclusive, of p and returns an
+// error unless the gateway answers 206 with exactly that slice of full
+func CheckRange(c *client.Client, p string, full []byte, first, last int) error {
+	req, err := c.NewRequest("GET", p, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	resp, err := c.Send(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 206 {
+		return fmt.Errorf("range %d-%d of %s returned %d, expected 206", first, last, p, resp.StatusCode)
+	}
+	want := fmt.Sprintf("bytes %d-%d/%d", first, last, len(full))
+	if got := resp.Header.Get("Content-Range"); got != want {
+		return fmt.Errorf("range %d-%d of %s has Content-Range %q, expected %q", first, last, p, got, want)
+	}
+	if !bytes.Equal(resp.Body, full[first:last+1]) {
+		return fmt.Errorf("range %d-%d of %s returned the wrong %d bytes", first, last, p, len(resp.Body))
+	}
+	return nil
+}
+
+// CheckRanges requests the start, the end and a slice crossing the first
+// block boundary of p, whose full content is full
+func CheckRanges(c *client.Client, p string, full []byte) error {
+	if len(full) <= ChunkSize {
+		return fmt.Errorf("%s is %d bytes, too small to span several blocks", p, len(full))
+	}
+	for _, r := range [][2]int{
+		{0, 99},
+		{ChunkSize - 100, ChunkSize + 99},
+		{len(full) - 100, len(full) - 1},
+	} {
+		if err := CheckRange(c, p, full, r[0], r[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckContentType fetches p and returns an error unless its Content-Type
+// has the media type want
+func CheckContentType(c *client.Client, p, want string) error {
+	resp, err := c.Get(p)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+	if err := mediaType(resp, want); err != nil {
+		return fmt.Errorf("%s: %s", p, err)
+	}
+	return nil
+}
+
+func mediaType(resp *client.Response, want string) error {
+	got, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q: %s", resp.Header.Get("Content-Type"), err)
+	}
+	if got != want {
+		return fmt.Errorf("Content-Type is %s, expected %s", got, want)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
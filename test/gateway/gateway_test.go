@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+const index = `<html><head><link rel="stylesheet" href="/ipfs/QmAssets/style.css"></head><body>
+<a href="/ipfs/QmRoot/..">..</a>
+<a href="/ipfs/QmRoot/images">images</a>
+<a href="/ipfs/QmRoot/listings.json">listings.json</a>
+<a href="/ipfs/QmRoot/my%20file.txt">my file.txt</a>
+</body></html>`
+
+func newGateway(t *testing.T, content []byte) *client.Client {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ipfs/QmRoot/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(index))
+		case strings.HasPrefix(r.URL.Path, "/ipfs/QmRoot/"):
+			http.ServeContent(w, r, r.URL.Path, time.Unix(1, 0), bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return client.New(ts.URL)
+}
+
+func TestDirectoryIndex(t *testing.T) {
+	c := newGateway(t, nil)
+	names, err := DirectoryIndex(c, "/ipfs/QmRoot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "images,listings.json,my file.txt" {
+		t.Errorf("Expected images, listings.json and my file.txt, got %v", names)
+	}
+	if err := CheckIndex(c, "/ipfs/QmRoot", "images", "profile.json"); err == nil {
+		t.Error("Expected an error for the missing profile.json")
+	}
+}
+
+func TestCheckRanges(t *testing.T) {
+	content := make([]byte, ChunkSize*2)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	c := newGateway(t, content)
+	if err := CheckRanges(c, "/ipfs/QmRoot/large.jpg", content); err != nil {
+		t.Error(err)
+	}
+	other := append([]byte{}, content...)
+	other[ChunkSize] ^= 0xff
+	if err := CheckRanges(c, "/ipfs/QmRoot/large.jpg", other); err == nil {
+		t.Error("Expected an error for mismatching content")
+	}
+}
+
+func TestCheckContentType(t *testing.T) {
+	c := newGateway(t, []byte(`{}`))
+	if err := CheckContentType(c, "/ipfs/QmRoot/listings.json", "application/json"); err != nil {
+		t.Error(err)
+	}
+	if err := CheckContentType(c, "/ipfs/QmRoot/listings.json", "image/jpeg"); err == nil {
+		t.Error("Expected an error for the wrong content type")
+	}
+}
@@ -0,0 +1,147 @@
+// Package graph writes large follower graphs straight into a stopped node's
+// database and walks the paginated follower endpoints to check them. Real
+// nodes would take hours to follow a store tens of thousands of times.
+package graph
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"path"
+
+	"github.com/btcsuite/btcutil/base58"
+	_ "github.com/mutecomm/go-sqlcipher"
+)
+
+// Options describes the graph to generate
+type Options struct {
+	Followers int
+	Following int
+
+	// Churn removes this many random followers and followings after inserting
+	// them, leaving the gaps a long lived node accumulates
+	Churn int
+}
+
+// Graph is what was written, newest first, which is the order the API
+// returns it in
+type Graph struct {
+	Followers []string
+	Following []string
+}
+
+// Seed adds a generated graph to the database of the repo at repoPath. The
+// node must not be running.
+func Seed(repoPath, password string, testnet bool, opts Options) (*Graph, error) {
+	name := "mainnet.db"
+	if testnet {
+		name = "testnet.db"
+	}
+	conn, err := sql.Open("sqlite3", path.Join(repoPath, "datastore", name))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if password != "" {
+		conn.Exec("pragma key='" + password + "';")
+	}
+	return seed(conn, opts)
+}
+
+func seed(conn *sql.DB, opts Options) (*Graph, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	followers, err := insert(tx, "insert into followers(peerID, proof) values(?,?)", opts.Followers, true)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	following, err := insert(tx, "insert into following(peerID) values(?)", opts.Following, false)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	followers, err = churn(tx, "followers", followers, opts.Churn)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	following, err = churn(tx, "following", following, opts.Churn)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Graph{Followers: reverse(followers), Following: reverse(following)}, nil
+}
+
+func insert(tx *sql.Tx, query string, n int, withProof bool) ([]string, error) {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id := RandomPeerID()
+		var err error
+		if withProof {
+			_, err = stmt.Exec(id, randomBytes(64))
+		} else {
+			_, err = stmt.Exec(id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// churn deletes n entries spread evenly over ids and returns the rest
+func churn(tx *sql.Tx, table string, ids []string, n int) ([]string, error) {
+	if n <= 0 || len(ids) == 0 {
+		return ids, nil
+	}
+	if n > len(ids) {
+		n = len(ids)
+	}
+	step := len(ids) / n
+	kept := make([]string, 0, len(ids)-n)
+	deleted := 0
+	for i, id := range ids {
+		if deleted < n && i%step == step/2 {
+			if _, err := tx.Exec("delete from "+table+" where peerID=?", id); err != nil {
+				return nil, err
+			}
+			deleted++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return kept, nil
+}
+
+// RandomPeerID returns a well formed base58 peer ID that belongs to nobody
+func RandomPeerID() string {
+	// sha2-256 multihash of 32 random bytes
+	b := append([]byte{0x12, 0x20}, randomBytes(32)...)
+	return base58.Encode(b)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func reverse(ids []string) []string {
+	ret := make([]string, len(ids))
+	for i, id := range ids {
+		ret[len(ids)-1-i] = id
+	}
+	return ret
+}
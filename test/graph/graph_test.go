@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`create table followers (peerID text primary key not null, proof blob);
+	create table following (peerID text primary key not null);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestSeed(t *testing.T) {
+	conn := openTestDB(t)
+	defer conn.Close()
+	g, err := seed(conn, Options{Followers: 1000, Following: 10, Churn: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Followers) != 990 || len(g.Following) != 0 {
+		t.Fatalf("Expected 990 followers and 0 following, got %d and %d", len(g.Followers), len(g.Following))
+	}
+	var count int
+	conn.QueryRow("select count(*) from followers").Scan(&count)
+	if count != 990 {
+		t.Errorf("Expected 990 rows, got %d", count)
+	}
+	var newest string
+	conn.QueryRow("select peerID from followers order by rowid desc limit 1").Scan(&newest)
+	if newest != g.Followers[0] {
+		t.Error("Graph is not ordered newest first")
+	}
+	if !strings.HasPrefix(RandomPeerID(), "Qm") {
+		t.Error("Random peer ID is not a sha2-256 multihash")
+	}
+}
+
+func pagedServer(ids []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start := 0
+		if offset := r.URL.Query().Get("offsetId"); offset != "" {
+			for i, id := range ids {
+				if id == offset {
+					start = i + 1
+				}
+			}
+		}
+		end := start + limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+		b, _ := json.Marshal(ids[start:end])
+		w.Write(b)
+	}))
+}
+
+func TestWalk(t *testing.T) {
+	var ids []string
+	for i := 0; i < 95; i++ {
+		ids = append(ids, RandomPeerID())
+	}
+	ts := pagedServer(ids)
+	defer ts.Close()
+
+	stats, err := Walk(client.New(ts.URL), "/ob/followers", ids, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pages != 11 || stats.Total != 95 {
+		t.Errorf("Expected 11 pages and 95 entries, got %d and %d", stats.Pages, stats.Total)
+	}
+
+	want := append([]string{}, ids...)
+	want[42] = RandomPeerID()
+	if _, err := Walk(client.New(ts.URL), "/ob/followers", want, 10, 0); err == nil || !strings.Contains(err.Error(), "entry 42") {
+		t.Errorf("Expected mismatch at entry 42, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// PageStats summarises a full walk over a paginated list
+type PageStats struct {
+	Pages      int
+	Total      int
+	MaxLatency time.Duration
+	P95Latency time.Duration
+	MaxBytes   int
+}
+
+// Walk pages through path, e.g. /ob/followers, pageSize entries at a time
+// using offsetId the way the client does, and checks that the concatenated
+// pages equal want: nothing skipped, repeated or reordered. Any page slower
+// than maxLatency fails the walk.
+func Walk(c *client.Client, path string, want []string, pageSize int, maxLatency time.Duration) (*PageStats, error) {
+	stats := new(PageStats)
+	var latencies []time.Duration
+	var got []string
+	offset := ""
+	for {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(pageSize))
+		if offset != "" {
+			q.Set("offsetId", offset)
+		}
+		start := time.Now()
+		resp, err := c.Get(path + "?" + q.Encode())
+		elapsed := time.Since(start)
+		if err != nil {
+			return stats, err
+		}
+		if err := resp.Err(); err != nil {
+			return stats, err
+		}
+		var page []string
+		if err := json.Unmarshal(resp.Body, &page); err != nil {
+			return stats, err
+		}
+		latencies = append(latencies, elapsed)
+		stats.Pages++
+		if elapsed > stats.MaxLatency {
+			stats.MaxLatency = elapsed
+		}
+		if len(resp.Body) > stats.MaxBytes {
+			stats.MaxBytes = len(resp.Body)
+		}
+		if maxLatency > 0 && elapsed > maxLatency {
+			return stats, fmt.Errorf("page %d of %s took %s, limit is %s", stats.Pages, path, elapsed, maxLatency)
+		}
+		if len(page) > pageSize {
+			return stats, fmt.Errorf("page %d of %s has %d entries, limit was %d", stats.Pages, path, len(page), pageSize)
+		}
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, page...)
+		if len(got) > len(want) {
+			return stats, fmt.Errorf("%s returned more than the %d expected entries", path, len(want))
+		}
+		offset = page[len(page)-1]
+	}
+	stats.Total = len(got)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.P95Latency = bench.Percentile(latencies, 95)
+	return stats, compare(got, want)
+}
+
+// compare reports the first position at which got and want differ
+func compare(got, want []string) error {
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if got[i] != want[i] {
+			return fmt.Errorf("entry %d is %s, expected %s", i, got[i], want[i])
+		}
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("walked %d entries, expected %d", len(got), len(want))
+	}
+	return nil
+}
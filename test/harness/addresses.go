@@ -0,0 +1,102 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// unfundable is more satoshis than will ever exist, so spends probing an
+// address fail after it is decoded and never move coins
+const unfundable = 21e14 + 1
+
+// AddressValidation offers every address of the fixtures corpus for the
+// buyer's coin and network as the refund address of a purchase and as the
+// destination of a spend. Both must take exactly the valid addresses. Vendor
+// payout addresses are picked by the node itself, so the spend endpoint
+// stands in for them as the other place an address enters the node.
+func AddressValidation() Scenario {
+	return Scenario{
+		Name:        "address-validation",
+		Description: "refund and spend addresses are accepted or rejected according to the address corpus, the same by both endpoints",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			cfg, err := buyer.Client().Config()
+			if err != nil {
+				return err
+			}
+			coin, network := cfg.CryptoCurrency, fixtures.Mainnet
+			if cfg.Testnet {
+				coin, network = strings.TrimPrefix(coin, "T"), fixtures.Testnet
+			}
+			corpus := fixtures.AddressesFor(coin, network)
+			if len(corpus) == 0 {
+				return fmt.Errorf("no addresses in the corpus for %s on %s", coin, network)
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+
+			var wrong []string
+			for _, a := range corpus {
+				var refund, spend bool
+				err := net.Step("refund-address/"+a.Kind, func() error {
+					order := fixtures.DirectOrder(hash)
+					order["refundAddress"] = a.Address
+					_, err := buyer.Client().Purchase(order)
+					refund = err == nil
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				err = net.Step("spend-address/"+a.Kind, func() error {
+					resp, err := buyer.Client().Post("/wallet/spend", map[string]interface{}{
+						"address":  a.Address,
+						"amount":   uint64(unfundable),
+						"feeLevel": "NORMAL",
+					})
+					if err != nil {
+						return err
+					}
+					// A bad address is a 400, a good one fails to fund
+					spend = resp.StatusCode != 400
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				for _, e := range []struct {
+					endpoint string
+					accepted bool
+				}{{"purchase refund", refund}, {"spend", spend}} {
+					if e.accepted != a.Valid {
+						wrong = append(wrong, fmt.Sprintf("%s %s %q (%s)", e.endpoint, verdict(e.accepted), a.Address, a.Kind))
+					}
+				}
+			}
+			if len(wrong) > 0 {
+				return fmt.Errorf("%s on %s: %s", coin, network, strings.Join(wrong, "; "))
+			}
+			return nil
+		},
+	}
+}
+
+func verdict(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "rejected"
+}
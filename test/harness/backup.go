@@ -0,0 +1,86 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// Backuper is implemented by nodes whose repo the harness can archive.
+// Backup stops the node so the archive is consistent; Restore starts a new
+// node from an archive, standing in for the same store moved to another host.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) error
+	Restore(ctx context.Context, r io.Reader) (Node, error)
+}
+
+// BackupRestore funds an order, backs up the vendor and restores it as a new
+// node, which replaces the vendor in the network. The restored vendor must
+// keep its peer ID and be able to fulfill the order, and the buyer must be
+// able to complete it.
+func BackupRestore(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "backup-restore",
+		Description: "an order in flight completes after the vendor is restored from a backup",
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			backuper, ok := vendor.(Backuper)
+			if !ok {
+				return fmt.Errorf("node %s cannot be backed up", vendor.Name())
+			}
+			waitCtx, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			order, err := Checkout(waitCtx, vendor, buyer)
+			if err != nil {
+				return err
+			}
+
+			var archive bytes.Buffer
+			if err := backuper.Backup(ctx, &archive); err != nil {
+				return fmt.Errorf("backing up %s: %s", vendor.Name(), err)
+			}
+			restored, err := backuper.Restore(ctx, &archive)
+			if err != nil {
+				return fmt.Errorf("restoring %s: %s", vendor.Name(), err)
+			}
+			net.replace(vendor, restored)
+			if restored.PeerID() != vendor.PeerID() {
+				return fmt.Errorf("restored vendor has peer ID %s, expected %s", restored.PeerID(), vendor.PeerID())
+			}
+			if err := WaitState(waitCtx, order.ID, "AWAITING_FULFILLMENT", restored); err != nil {
+				return fmt.Errorf("order lost in the backup: %s", err)
+			}
+
+			if err := restored.Client().FulfillOrder(fixtures.Fulfillment(order.ID, order.Slug)); err != nil {
+				return fmt.Errorf("fulfilling from the restored vendor: %s", err)
+			}
+			if err := WaitState(waitCtx, order.ID, "FULFILLED", restored, buyer); err != nil {
+				return err
+			}
+			if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, order.Slug)); err != nil {
+				return fmt.Errorf("completing the order: %s", err)
+			}
+			return WaitState(waitCtx, order.ID, "COMPLETED", buyer, restored)
+		},
+	}
+}
+
+// replace swaps a node of the network for another, e.g. after a restore
+func (n *Network) replace(old, replacement Node) {
+	for i, nd := range n.Nodes {
+		if nd == old {
+			n.Nodes[i] = replacement
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errNotStored = errors.New("message not stored")
+
+// Ban has n block peerID, the same as a user blocking it in the client
+func Ban(n Node, peerID string) error {
+	if err := n.Client().EnsureSettings(); err != nil {
+		return fmt.Errorf("settings of %s: %s", n.Name(), err)
+	}
+	if err := n.Client().BlockNode(peerID); err != nil {
+		return fmt.Errorf("%s blocking %s: %s", n.Name(), peerID, err)
+	}
+	return nil
+}
+
+// Unban lifts a ban set with Ban
+func Unban(n Node, peerID string) error {
+	if err := n.Client().UnblockNode(peerID); err != nil {
+		return fmt.Errorf("%s unblocking %s: %s", n.Name(), peerID, err)
+	}
+	return nil
+}
+
+// AssertDropped sends a chat message from sender to n and fails if n stores
+// it within quiet, which is how long the message is given to arrive
+func AssertDropped(ctx context.Context, n, sender Node, quiet time.Duration) error {
+	id, err := sender.Client().SendChat(n.PeerID(), "", fmt.Sprintf("sent while banned at %s", time.Now().Format(time.RFC3339Nano)))
+	if err != nil {
+		// the send failing is as good as the message being dropped
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, quiet)
+	defer cancel()
+	stored := poll(ctx, func() error {
+		if !isStored(n, sender.PeerID(), id) {
+			return errNotStored
+		}
+		return nil
+	})
+	if stored == nil {
+		return fmt.Errorf("%s stored message %s from banned peer %s", n.Name(), id, sender.Name())
+	}
+	return nil
+}
+
+// isStored reports whether n stores a message with the given ID from peerID
+func isStored(n Node, peerID, messageID string) bool {
+	messages, err := n.Client().ChatMessages(peerID)
+	if err != nil {
+		return false
+	}
+	for _, m := range messages {
+		if m.MessageID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerBan has the vendor ban the buyer and checks that the buyer's messages
+// are dropped, also after the vendor restarts, and delivered again once the
+// ban is lifted. Bans in the node are manual and last until lifted; there is
+// no ban window and no automatic banning of misbehaving peers to trigger.
+func PeerBan(quiet, settle time.Duration) Scenario {
+	if quiet == 0 {
+		quiet = 30 * time.Second
+	}
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "peer-ban",
+		Description: "a banned peer's messages are dropped until the ban is lifted",
+		Restarts:    true,
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			deliver := func(text string) error {
+				id, err := buyer.Client().SendChat(vendor.PeerID(), "", text)
+				if err != nil {
+					return err
+				}
+				settleCtx, cancel := context.WithTimeout(ctx, settle)
+				defer cancel()
+				return poll(settleCtx, func() error {
+					if !isStored(vendor, buyer.PeerID(), id) {
+						return fmt.Errorf("message %s from %s never reached %s", id, buyer.Name(), vendor.Name())
+					}
+					return nil
+				})
+			}
+			if err := net.Step("before ban", func() error { return deliver("before the ban") }); err != nil {
+				return err
+			}
+			if err := Ban(vendor, buyer.PeerID()); err != nil {
+				return err
+			}
+			if err := net.Step("banned", func() error { return AssertDropped(ctx, vendor, buyer, quiet) }); err != nil {
+				return err
+			}
+			if r, ok := vendor.(Restarter); ok {
+				if err := r.Restart(ctx); err != nil {
+					return err
+				}
+				if err := net.Step("banned after restart", func() error { return AssertDropped(ctx, vendor, buyer, quiet) }); err != nil {
+					return err
+				}
+			}
+			if err := Unban(vendor, buyer.PeerID()); err != nil {
+				return err
+			}
+			return net.Step("after unban", func() error { return deliver("after the ban") })
+		},
+	}
+}
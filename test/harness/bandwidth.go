@@ -0,0 +1,91 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// capTransfer is how long the image the vendor serves should take at its cap
+const capTransfer = 15 * time.Second
+
+// BandwidthCap has the buyer fetch an image of noise from the vendor, whose
+// upload is capped at upload bits per second, e.g. with nodes.Limits, and
+// checks the throughput the vendor's stats report for it: what it sent
+// divided by how long the fetch took must stay within a fifth over the cap,
+// leaving room for the bucket's burst. The throughput is recorded as
+// effective_upload_bits_per_second.
+func BandwidthCap(upload uint64) Scenario {
+	return Scenario{
+		Name:        "bandwidth-cap",
+		Description: "a vendor with capped upload serves content no faster than the cap",
+		Run: func(ctx context.Context, net *Network) error {
+			if upload == 0 {
+				return fmt.Errorf("scenario needs the upload cap of the vendor")
+			}
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			// Noise does not compress, so the image is about as large as
+			// its pixels
+			side := int(math.Sqrt(float64(upload) / 8 * capTransfer.Seconds() / 3))
+			img, err := noiseImage(side, side)
+			if err != nil {
+				return err
+			}
+			hashes, err := vendor.Client().UploadImage("noise.png", img)
+			if err != nil {
+				return fmt.Errorf("uploading an image to %s: %s", vendor.Name(), err)
+			}
+			before, err := vendor.Client().Bandwidth()
+			if err != nil {
+				return err
+			}
+			start := time.Now()
+			err = net.Step("fetch", func() error {
+				_, err := buyer.Client().WithTimeout(20 * capTransfer).Image(hashes.Original)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("%s fetching %s from %s: %s", buyer.Name(), hashes.Original, vendor.Name(), err)
+			}
+			elapsed := time.Since(start)
+			after, err := vendor.Client().Bandwidth()
+			if err != nil {
+				return err
+			}
+
+			sent := after.TotalOut - before.TotalOut
+			effective := float64(sent) * 8 / elapsed.Seconds()
+			net.Metrics.Record("effective_upload_bits_per_second", effective, map[string]string{"node": vendor.Name()})
+			if float64(sent)*8 < float64(upload)*capTransfer.Seconds()/3 {
+				return fmt.Errorf("%s sent only %d bytes serving the image, too little to measure its throughput", vendor.Name(), sent)
+			}
+			if effective > 1.2*float64(upload) {
+				return fmt.Errorf("%s sent %d bytes in %s, %.0f bit/s against a cap of %d", vendor.Name(), sent, elapsed, effective, upload)
+			}
+			return nil
+		},
+	}
+}
+
+// noiseImage returns a base64 encoded PNG of random pixels
+func noiseImage(width, height int) (string, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rand.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,126 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// Blocklister is implemented by nodes whose gateway blocklist the harness can
+// replace at runtime
+type Blocklister interface {
+	SetBlocklist(entries ...string) error
+}
+
+// AssertRefused returns an error unless the gateway of c refuses p as
+// blocked content
+func AssertRefused(c *client.Client, p string) error {
+	resp, err := c.Get(p)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusGone {
+		return fmt.Errorf("%s returned %d, expected %d for blocked content", p, resp.StatusCode, http.StatusGone)
+	}
+	return nil
+}
+
+// AssertServed returns an error unless the gateway of c serves p
+func AssertServed(c *client.Client, p string) error {
+	resp, err := c.Get(p)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return fmt.Errorf("%s: %s", p, err)
+	}
+	return nil
+}
+
+// ContentBlocklist blocks an image of the first vendor on a serving node, by
+// CID and then by the vendor's peer ID, and asserts the blocked content is
+// refused, directly and through the vendor's published root, while other
+// content keeps being served. The serving node is the first other node that
+// implements Blocklister, or the vendor itself.
+func ContentBlocklist(deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = time.Minute
+	}
+	return Scenario{
+		Name:        "content-blocklist",
+		Description: "blocked CIDs and peer IDs are refused by the gateway while other content serves",
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor := vendors[0]
+			server := vendor
+			for _, n := range net.Nodes {
+				if _, ok := n.(Blocklister); ok && n != vendor {
+					server = n
+					break
+				}
+			}
+			blocker, ok := server.(Blocklister)
+			if !ok {
+				return fmt.Errorf("node %s cannot manage its blocklist", server.Name())
+			}
+			defer blocker.SetBlocklist()
+
+			var imgs [2]*client.ImageHashes
+			for n, filename := range []string{"blocked.jpg", "allowed.jpg"} {
+				img, err := RandomImage(400, 400)
+				if err != nil {
+					return err
+				}
+				if imgs[n], err = vendor.Client().UploadImage(filename, img); err != nil {
+					return fmt.Errorf("uploading %s to %s: %s", filename, vendor.Name(), err)
+				}
+			}
+			blocked := "/ipfs/" + imgs[0].Original
+			allowed := "/ipfs/" + imgs[1].Original
+			viaRoot := "/ipns/" + vendor.PeerID() + "/images/original/blocked.jpg"
+
+			c := server.Client()
+			ctx, cancel := context.WithTimeout(ctx, deadline)
+			defer cancel()
+			if err := poll(ctx, func() error { return AssertServed(c, viaRoot) }); err != nil {
+				return fmt.Errorf("%s never served the vendor's images: %s", server.Name(), err)
+			}
+
+			if err := blocker.SetBlocklist(imgs[0].Original); err != nil {
+				return err
+			}
+			for _, p := range []string{blocked, viaRoot} {
+				if err := AssertRefused(c, p); err != nil {
+					return err
+				}
+			}
+			if err := AssertServed(c, allowed); err != nil {
+				return err
+			}
+
+			if server != vendor {
+				if err := blocker.SetBlocklist(vendor.PeerID()); err != nil {
+					return err
+				}
+				if err := AssertRefused(c, "/ipns/"+vendor.PeerID()+"/images/original/allowed.jpg"); err != nil {
+					return err
+				}
+				if err := AssertServed(c, allowed); err != nil {
+					return err
+				}
+			}
+
+			if err := blocker.SetBlocklist(); err != nil {
+				return err
+			}
+			return AssertServed(c, blocked)
+		},
+	}
+}
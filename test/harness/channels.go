@@ -0,0 +1,246 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/smtpsink"
+)
+
+// emailSubjects maps notification types to the subject of the email the
+// node sends for them. Types missing here are never emailed.
+var emailSubjects = map[string]string{
+	"order":             "Order received",
+	"payment":           "Payment received",
+	"orderConfirmation": "Order confirmed",
+	"cancel":            "Order cancelled",
+	"refund":            "Payment refunded",
+	"fulfillment":       "Order fulfilled",
+	"orderComplete":     "Order completed",
+	"disputeOpen":       "Dispute opened",
+	"disputeUpdate":     "Dispute updated",
+	"disputeClose":      "Dispute closed",
+}
+
+// NotificationParity compares the three channels a node notifies through:
+// the websocket, the stored list polled from GET /ob/notifications and the
+// email sent to the SMTP server in its settings. The node has no server sent
+// events or long polling endpoint, so these are all of them. Buyer and
+// vendor are pointed at sink and walk an order to COMPLETED; afterwards every
+// pushed notification must be stored and every stored one pushed, and every
+// pushed notification with an email form must have been mailed exactly once.
+func NotificationParity(sink *smtpsink.Server, settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "notification-parity",
+		Description: "websocket, polled and email notifications report the same events",
+		Requires:    []string{CapNotifications},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			parties := []Node{vendor, buyer}
+			sockets := make(map[string]*pushed)
+			before := make(map[string]map[string]bool)
+			for _, n := range parties {
+				if err := n.Client().EnsureSettings(); err != nil {
+					return err
+				}
+				if err := n.Client().SetSMTP(smtpSettings(n, sink)); err != nil {
+					return fmt.Errorf("pointing %s at the SMTP sink: %s", n.Name(), err)
+				}
+				stored, _, err := n.Client().Notifications()
+				if err != nil {
+					return err
+				}
+				before[n.Name()] = make(map[string]bool)
+				for _, s := range stored {
+					before[n.Name()][s.ID] = true
+				}
+				ws, err := n.Client().Subscribe()
+				if err != nil {
+					return err
+				}
+				p := collect(ws)
+				defer p.close()
+				sockets[n.Name()] = p
+			}
+
+			order, err := Checkout(ctx, vendor, buyer)
+			if err != nil {
+				return err
+			}
+			if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, order.Slug)); err != nil {
+				return err
+			}
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			if err := WaitState(wait, order.ID, "FULFILLED", buyer, vendor); err != nil {
+				return err
+			}
+			if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, order.Slug)); err != nil {
+				return err
+			}
+			if err := WaitState(wait, order.ID, "COMPLETED", buyer, vendor); err != nil {
+				return err
+			}
+
+			for _, n := range parties {
+				p := sockets[n.Name()]
+				err := poll(wait, func() error {
+					return channelParity(n, p.notifications(), before[n.Name()], sink.To(recipient(n)))
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func recipient(n Node) string {
+	return n.Name() + "@testnodes.invalid"
+}
+
+func smtpSettings(n Node, sink *smtpsink.Server) client.SMTPSettings {
+	return client.SMTPSettings{
+		Notifications:  true,
+		ServerAddress:  sink.Addr(),
+		Username:       n.Name(),
+		Password:       n.Name(),
+		SenderEmail:    recipient(n),
+		RecipientEmail: recipient(n),
+	}
+}
+
+// channelParity returns an error describing the first notification missing
+// from a channel
+func channelParity(n Node, ws []client.Notification, before map[string]bool, mails []smtpsink.Message) error {
+	if len(ws) == 0 {
+		return fmt.Errorf("no notifications pushed to %s", n.Name())
+	}
+	stored, _, err := n.Client().Notifications()
+	if err != nil {
+		return err
+	}
+	storedByID := make(map[string]client.Notification)
+	for _, s := range stored {
+		if !before[s.ID] {
+			storedByID[s.ID] = s
+		}
+	}
+	pushedByID := make(map[string]client.Notification)
+	for _, p := range ws {
+		if p.ID == "" {
+			continue
+		}
+		pushedByID[p.ID] = p
+		s, ok := storedByID[p.ID]
+		if !ok {
+			return fmt.Errorf("%s notification %s pushed to %s but not stored", p.Type, p.ID, n.Name())
+		}
+		if s.Type != p.Type || s.OrderID != p.OrderID {
+			return fmt.Errorf("notification %s is %s/%s on the websocket of %s but %s/%s when polled", p.ID, p.Type, p.OrderID, n.Name(), s.Type, s.OrderID)
+		}
+	}
+	for id, s := range storedByID {
+		if _, ok := pushedByID[id]; !ok {
+			return fmt.Errorf("%s notification %s stored on %s but never pushed", s.Type, id, n.Name())
+		}
+	}
+
+	want := make(map[string]int)
+	for _, p := range ws {
+		if subject, ok := emailSubjects[p.Type]; ok {
+			want[subject+" "+p.OrderID]++
+		}
+	}
+	got := make(map[string]int)
+	for _, m := range mails {
+		subject := strings.TrimSpace(strings.TrimPrefix(m.Subject, "[OpenBazaar]"))
+		got[subject+" "+mailOrderID(m.Body)]++
+	}
+	for k, w := range want {
+		if got[k] != w {
+			return fmt.Errorf("%d emails for %q to %s, %d pushed", got[k], k, n.Name(), w)
+		}
+	}
+	for k, g := range got {
+		if want[k] == 0 {
+			return fmt.Errorf("%d emails for %q to %s without a pushed notification", g, k, n.Name())
+		}
+	}
+	return nil
+}
+
+// mailOrderID returns the order ID quoted in an email body. The order email
+// names the order on an "Order ID:" line, all others quote it.
+func mailOrderID(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "Order ID: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Order ID: "))
+		}
+	}
+	parts := strings.Split(body, "\"")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// pushed collects every notification arriving on a websocket
+type pushed struct {
+	ws   *client.Socket
+	lock sync.Mutex
+	got  []client.Notification
+	done chan struct{}
+}
+
+func collect(ws *client.Socket) *pushed {
+	p := &pushed{ws: ws, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		for {
+			n, err := ws.NextNotification(time.Hour)
+			if err != nil {
+				return
+			}
+			p.lock.Lock()
+			p.got = append(p.got, *n)
+			p.lock.Unlock()
+		}
+	}()
+	return p
+}
+
+// notifications returns what arrived so far, ordered by ID so retries of
+// a failed comparison report stable errors
+func (p *pushed) notifications() []client.Notification {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ret := append([]client.Notification(nil), p.got...)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret
+}
+
+// arrived returns what arrived so far in arrival order
+func (p *pushed) arrived() []client.Notification {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]client.Notification(nil), p.got...)
+}
+
+func (p *pushed) close() {
+	p.ws.Close()
+	<-p.done
+}
@@ -0,0 +1,112 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// LargeChat exchanges chat messages of the maximum size in both directions
+// and checks that both ends store them byte for byte, before and after both
+// restart, and that a message one byte over the limit is rejected
+func LargeChat(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "large-chat",
+		Description: "maximum size chat messages arrive intact and survive a restart of both ends",
+		Restarts:    true,
+		Requires:    []string{CapChat},
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			subject, message := fixtures.LargeChat()
+			var sent []sentChat
+			for _, pair := range [][2]Node{{buyer, vendor}, {vendor, buyer}} {
+				from, to := pair[0], pair[1]
+				id, err := from.Client().SendChat(to.PeerID(), subject, message)
+				if err != nil {
+					return fmt.Errorf("sending a maximum size message from %s: %s", from.Name(), err)
+				}
+				sent = append(sent, sentChat{id: id, from: from, to: to})
+			}
+
+			resp, err := buyer.Client().Post("/ob/chat", map[string]string{
+				"peerId":  vendor.PeerID(),
+				"message": fixtures.OversizedChat(),
+			})
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				return fmt.Errorf("a message over %d bytes returned %d, expected 400", fixtures.MaxChatMessage, resp.StatusCode)
+			}
+
+			settleCtx, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			check := func(when string) error {
+				for _, c := range sent {
+					for _, end := range []struct{ node, peer Node }{{c.from, c.to}, {c.to, c.from}} {
+						err := poll(settleCtx, func() error {
+							return sameChat(end.node, end.peer.PeerID(), c.id, subject, message)
+						})
+						if err != nil {
+							return fmt.Errorf("%s: %s", when, err)
+						}
+					}
+				}
+				return nil
+			}
+			if err := net.Step("delivery", func() error { return check("after delivery") }); err != nil {
+				return err
+			}
+			for _, n := range []Node{vendor, buyer} {
+				r, ok := n.(Restarter)
+				if !ok {
+					return fmt.Errorf("node %s cannot be restarted", n.Name())
+				}
+				if err := r.Restart(ctx); err != nil {
+					return err
+				}
+			}
+			return net.Step("after restart", func() error { return check("after restart") })
+		},
+	}
+}
+
+type sentChat struct {
+	id       string
+	from, to Node
+}
+
+// sameChat returns an error unless n stores the message with peerID with
+// exactly the given subject and content
+func sameChat(n Node, peerID, messageID, subject, message string) error {
+	messages, err := n.Client().ChatThread(peerID, subject)
+	if err != nil {
+		return err
+	}
+	var m *client.ChatMessage
+	for i := range messages {
+		if messages[i].MessageID == messageID {
+			m = &messages[i]
+		}
+	}
+	switch {
+	case m == nil:
+		return fmt.Errorf("message %s missing on %s", messageID, n.Name())
+	case m.Subject != subject:
+		return fmt.Errorf("message %s on %s has a %d byte subject, expected the %d bytes sent", messageID, n.Name(), len(m.Subject), len(subject))
+	case m.Message != message:
+		return fmt.Errorf("message %s on %s has %d bytes, expected the %d bytes sent", messageID, n.Name(), len(m.Message), len(message))
+	}
+	return nil
+}
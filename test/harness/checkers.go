@@ -0,0 +1,76 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Checker is an invariant Run checks after every scenario that passed, e.g.
+// a compliance rule every store must keep. Checkers are registered once,
+// usually from the init function of the package that ships them.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, net *Network) error
+}
+
+// CheckerFunc adapts a function to a Checker
+func CheckerFunc(name string, check func(ctx context.Context, net *Network) error) Checker {
+	return checkerFunc{name, check}
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context, net *Network) error
+}
+
+func (c checkerFunc) Name() string { return c.name }
+
+func (c checkerFunc) Check(ctx context.Context, net *Network) error {
+	return c.check(ctx, net)
+}
+
+var checkers = make(map[string]Checker)
+
+// RegisterChecker adds a checker run after every scenario. Names must be
+// unique.
+func RegisterChecker(c Checker) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := checkers[c.Name()]; ok {
+		panic("harness: checker " + c.Name() + " registered twice")
+	}
+	checkers[c.Name()] = c
+}
+
+// Checkers returns the registered checkers sorted by name
+func Checkers() []Checker {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	ret := make([]Checker, 0, len(checkers))
+	for _, c := range checkers {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return ret
+}
+
+// runCheckers runs every registered checker as a step of its own and
+// reports all that failed
+func (n *Network) runCheckers(ctx context.Context) error {
+	var failed []string
+	for _, c := range Checkers() {
+		c := c
+		err := n.Step("check/"+c.Name(), func() error {
+			return c.Check(ctx, n)
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", c.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("checks failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// CrossCoinOptions configures the cross-coin scenario
+type CrossCoinOptions struct {
+	// Coins are the currency codes of the wallets the nodes run, e.g. the
+	// ones of a chain.Matrix. It is required.
+	Coins []string
+
+	// Fund sends coins of a coin to an address and mines them, e.g.
+	// chain.Matrix.Fund. It is required.
+	Fund func(coin, address string, coins float64) error
+
+	// Settle bounds how long wallets and orders may take to catch up, two
+	// minutes if zero
+	Settle time.Duration
+}
+
+// CrossCoin runs the order flow in every coin of a vendor accepting them
+// all, on the first vendor and buyer, whose nodes must run a wallet per
+// coin, see nodes.WithCoinWallet. A listing priced in a coin must be
+// purchasable and payable in it while accepting the others, and both sides
+// must see each order funded. A listing accepting one coin only must be
+// refused a purchase paying in another.
+//
+// Orders are paid in the coin the listing is priced in: the nodes run with
+// exchange rates disabled, so they cannot convert between coins.
+func CrossCoin(opts CrossCoinOptions) Scenario {
+	if opts.Settle == 0 {
+		opts.Settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:          "cross-coin",
+		Description:   "a vendor accepting several coins is paid in each of them",
+		Requires:      []string{CapListings, CapOrders},
+		Notifications: []string{"order", "payment"},
+		Run: func(ctx context.Context, net *Network) error {
+			if len(opts.Coins) == 0 || opts.Fund == nil {
+				return fmt.Errorf("scenario needs the coins of the wallets and a way to fund them")
+			}
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+			accepted := make([]interface{}, len(opts.Coins))
+			for i, coin := range opts.Coins {
+				accepted[i] = regtestCode(coin)
+			}
+
+			for _, coin := range opts.Coins {
+				coin := coin
+				err := net.Step("cross-coin/fund/"+coin, func() error {
+					before, err := buyer.Client().CoinBalance(coin)
+					if err != nil {
+						return err
+					}
+					addr, err := buyer.Client().CoinAddress(coin)
+					if err != nil {
+						return err
+					}
+					if err := opts.Fund(coin, addr, 1); err != nil {
+						return fmt.Errorf("funding the %s wallet of %s: %s", coin, buyer.Name(), err)
+					}
+					return poll(wait, func() error {
+						b, err := buyer.Client().CoinBalance(coin)
+						if err != nil {
+							return err
+						}
+						if b.Total() < before.Total()+1e8 {
+							return fmt.Errorf("%s wallet of %s holds %d, expected %d once funded", coin, buyer.Name(), b.Total(), before.Total()+1e8)
+						}
+						return nil
+					})
+				})
+				if err != nil {
+					return err
+				}
+
+				err = net.Step("cross-coin/order/"+coin, func() error {
+					hash, err := coinListing(vendor, coin, accepted)
+					if err != nil {
+						return err
+					}
+					order := fixtures.DirectOrder(hash)
+					order["paymentCoin"] = regtestCode(coin)
+					resp, err := buyer.Client().Purchase(order)
+					if err != nil {
+						return fmt.Errorf("purchase by %s in %s: %s", buyer.Name(), coin, err)
+					}
+					if err := buyer.Client().SpendCoin(coin, resp.PaymentAddress, resp.Amount); err != nil {
+						return fmt.Errorf("paying order %s in %s from %s: %s", resp.OrderID, coin, buyer.Name(), err)
+					}
+					return WaitState(wait, resp.OrderID, "AWAITING_FULFILLMENT", buyer, vendor)
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(opts.Coins) < 2 {
+				return nil
+			}
+			only, other := opts.Coins[0], opts.Coins[1]
+			return net.Expect("cross-coin/not-accepted", func() error {
+				hash, err := coinListing(vendor, only, []interface{}{regtestCode(only)})
+				if err != nil {
+					return err
+				}
+				order := fixtures.DirectOrder(hash)
+				order["paymentCoin"] = regtestCode(other)
+				if _, err := buyer.Client().Purchase(order); err == nil {
+					return fmt.Errorf("%s purchased in %s a listing accepting %s only", buyer.Name(), other, only)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// regtestCode is the code of coin in listings and orders of regtest nodes,
+// which price and pay in testnet coins like the fixtures
+func regtestCode(coin string) string {
+	return "T" + strings.ToUpper(coin)
+}
+
+// coinListing publishes the fixture listing on the vendor priced in coin
+// and accepting the coins of accepted, and returns its hash
+func coinListing(vendor Node, coin string, accepted []interface{}) (string, error) {
+	listing := fixtures.Listing()
+	listing["item"].(map[string]interface{})["title"] = coin + " tee"
+	metadata := listing["metadata"].(map[string]interface{})
+	metadata["pricingCurrency"] = regtestCode(coin)
+	metadata["acceptedCurrencies"] = accepted
+	slug, err := vendor.Client().CreateListing(listing)
+	if err != nil {
+		return "", fmt.Errorf("creating a %s listing on %s: %s", coin, vendor.Name(), err)
+	}
+	return listingHash(vendor, slug)
+}
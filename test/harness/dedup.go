@@ -0,0 +1,132 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// NoiseImage returns a base64 encoded PNG of random pixels. Unlike
+// RandomImage it barely compresses, so its larger sizes span several blocks.
+func NoiseImage(width, height int) (string, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// AssertSharedBlocks checks that two uploads of the same image produced the
+// same hash for every size and that, within the deadline, the vendor's
+// published root references every block of each size once per upload. The
+// uploads then share a single pinned copy instead of storing the image twice.
+func AssertSharedBlocks(ctx context.Context, vendor Node, a, b *client.ImageHashes, deadline time.Duration) error {
+	c := vendor.Client()
+	blocks := make(map[string]string)
+	for _, size := range client.ImageSizes {
+		if a.Size(size) != b.Size(size) {
+			return fmt.Errorf("%s image was stored as %s and %s, expected shared blocks", size, a.Size(size), b.Size(size))
+		}
+		refs, err := c.Refs(a.Size(size), true, true)
+		if err != nil {
+			return fmt.Errorf("listing blocks of %s image %s: %s", size, a.Size(size), err)
+		}
+		blocks[a.Size(size)] = size
+		for _, r := range refs {
+			blocks[r] = size
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	return poll(ctx, func() error {
+		root, err := c.RootHash("")
+		if err != nil {
+			return err
+		}
+		refs, err := c.Refs(root, true, false)
+		if err != nil {
+			return err
+		}
+		count := make(map[string]int)
+		for _, r := range refs {
+			count[r]++
+		}
+		for hash, size := range blocks {
+			if count[hash] < 2 {
+				return fmt.Errorf("block %s of the %s image is referenced %d times from root %s, expected once per upload", hash, size, count[hash], root)
+			}
+		}
+		return nil
+	})
+}
+
+// ImageDedup has the first vendor upload the same image under two file names
+// and publish a listing with each, then asserts both listings share the
+// image's blocks
+func ImageDedup(deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = time.Minute
+	}
+	return Scenario{
+		Name:        "image-dedup",
+		Description: "two listings with the same image share its blocks on the vendor",
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor := vendors[0]
+			img, err := NoiseImage(1200, 1200)
+			if err != nil {
+				return err
+			}
+			var hashes []*client.ImageHashes
+			for n, filename := range []string{"dedup-a.png", "dedup-b.png"} {
+				h, err := vendor.Client().UploadImage(filename, img)
+				if err != nil {
+					return fmt.Errorf("uploading %s to %s: %s", filename, vendor.Name(), err)
+				}
+				hashes = append(hashes, h)
+				listing := listingWithImage(fmt.Sprintf("Dedup listing %d", n+1), filename, h)
+				if _, err := vendor.Client().CreateListing(listing); err != nil {
+					return fmt.Errorf("creating listing on %s: %s", vendor.Name(), err)
+				}
+			}
+			return AssertSharedBlocks(ctx, vendor, hashes[0], hashes[1], deadline)
+		},
+	}
+}
+
+// listingWithImage returns the fixture listing titled title with the image
+// as its only picture
+func listingWithImage(title, filename string, h *client.ImageHashes) map[string]interface{} {
+	l := fixtures.Listing()
+	item := l["item"].(map[string]interface{})
+	item["title"] = title
+	item["images"] = []interface{}{map[string]interface{}{
+		"filename": filename,
+		"tiny":     h.Tiny,
+		"small":    h.Small,
+		"medium":   h.Medium,
+		"large":    h.Large,
+		"original": h.Original,
+	}}
+	return l
+}
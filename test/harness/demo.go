@@ -0,0 +1,113 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// DemoOptions says what SeedDemo fills a network with
+type DemoOptions struct {
+	// Listings is how many listings each vendor publishes
+	Listings int
+
+	// Orders is how many orders each buyer completes with each vendor
+	Orders int
+
+	// Funds are the satoshis each buyer is funded with from the Faucet of
+	// the network. No orders are placed on networks without one, which is
+	// the case on networks without a wallet.
+	Funds int64
+}
+
+// Demo is what SeedDemo published and ordered
+type Demo struct {
+	// Listings are the slugs published by each vendor, by node name
+	Listings map[string][]string
+
+	Orders []*Order
+}
+
+// SeedDemo gives every node a profile, publishes listings with generated
+// pictures on each vendor and walks orders from every buyer through to
+// COMPLETED, so the network has something to browse
+func SeedDemo(ctx context.Context, net *Network, o DemoOptions) (*Demo, error) {
+	demo := &Demo{Listings: make(map[string][]string)}
+	for _, n := range net.Nodes {
+		if err := n.Client().CreateProfile(map[string]interface{}{"name": n.Name()}); err != nil {
+			return nil, fmt.Errorf("creating profile of %s: %s", n.Name(), err)
+		}
+	}
+	vendors := net.Role("vendor")
+	for _, v := range vendors {
+		for i := 0; i < o.Listings; i++ {
+			img, err := RandomImage(600, 400)
+			if err != nil {
+				return nil, err
+			}
+			filename := fmt.Sprintf("%s-%d.png", v.Name(), i+1)
+			h, err := v.Client().UploadImage(filename, img)
+			if err != nil {
+				return nil, fmt.Errorf("uploading %s to %s: %s", filename, v.Name(), err)
+			}
+			slug, err := v.Client().CreateListing(listingWithImage(fmt.Sprintf("%s item %d", v.Name(), i+1), filename, h))
+			if err != nil {
+				return nil, fmt.Errorf("creating listing on %s: %s", v.Name(), err)
+			}
+			demo.Listings[v.Name()] = append(demo.Listings[v.Name()], slug)
+		}
+	}
+	if net.Faucet == nil || o.Orders == 0 {
+		return demo, nil
+	}
+	for _, b := range net.Role("buyer") {
+		if err := net.Fund(ctx, b, o.Funds); err != nil {
+			return nil, err
+		}
+		for _, v := range vendors {
+			slugs := demo.Listings[v.Name()]
+			if len(slugs) == 0 {
+				continue
+			}
+			for i := 0; i < o.Orders; i++ {
+				order, err := completeOrder(ctx, v, b, slugs[i%len(slugs)])
+				if err != nil {
+					return nil, err
+				}
+				demo.Orders = append(demo.Orders, order)
+			}
+		}
+	}
+	return demo, nil
+}
+
+// completeOrder has the buyer purchase the vendor's listing and takes the
+// order through payment, fulfillment and completion
+func completeOrder(ctx context.Context, vendor, buyer Node, slug string) (*Order, error) {
+	hash, err := listingHash(vendor, slug)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+	if err != nil {
+		return nil, fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+	}
+	order := &Order{ID: resp.OrderID, ListingHash: hash, Slug: slug, Payment: resp}
+	if err := PayOrder(buyer, order); err != nil {
+		return nil, err
+	}
+	if err := WaitState(ctx, order.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+		return nil, err
+	}
+	if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, slug)); err != nil {
+		return nil, fmt.Errorf("fulfilling order %s on %s: %s", order.ID, vendor.Name(), err)
+	}
+	if err := WaitState(ctx, order.ID, "FULFILLED", vendor, buyer); err != nil {
+		return nil, err
+	}
+	if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, slug)); err != nil {
+		return nil, fmt.Errorf("completing order %s on %s: %s", order.ID, buyer.Name(), err)
+	}
+	return order, WaitState(ctx, order.ID, "COMPLETED", buyer, vendor)
+}
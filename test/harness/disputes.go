@@ -0,0 +1,154 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// ConcurrentDisputes has buyer and vendor open a dispute on the same funded
+// order at the same instant. With latency set, every packet on the loopback
+// interface is delayed by it so each dispute is in flight while the other
+// party opens its own. At least one request must be accepted, the moderator
+// must end up with exactly one case for the order, and buyer, vendor and
+// moderator must all record the same claim.
+func ConcurrentDisputes(latency, settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "concurrent-disputes",
+		Description: "disputes opened by both parties at once yield one case both agree on",
+		Requires:    []string{CapOrders, CapDisputes},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			moderators := net.Role("moderator")
+			if len(moderators) == 0 {
+				return fmt.Errorf("scenario needs a moderator")
+			}
+			moderator := moderators[0]
+
+			order, err := PlaceOrder(vendor, buyer, moderator)
+			if err != nil {
+				return err
+			}
+			if err := PayOrder(buyer, order); err != nil {
+				return err
+			}
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			if err := WaitState(wait, order.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+				return err
+			}
+
+			if latency > 0 {
+				restore, err := netem.Delay(ctx, netem.Loopback, latency)
+				if err != nil {
+					return err
+				}
+				defer restore()
+			}
+			accepted, err := openDisputes(order.ID, buyer, vendor)
+			if err != nil {
+				return err
+			}
+			if len(accepted) == 0 {
+				return fmt.Errorf("neither party could open a dispute on order %s", order.ID)
+			}
+
+			if err := WaitState(wait, order.ID, "DISPUTED", buyer, vendor); err != nil {
+				return err
+			}
+			if err := moderator.Client().WaitCaseState(wait, order.ID, "DISPUTED"); err != nil {
+				return err
+			}
+			return sameDispute(wait, order.ID, moderator, accepted, buyer, vendor)
+		},
+	}
+}
+
+// disputeClaim is the claim a party files in ConcurrentDisputes, distinct
+// per party so the claim on record tells who won
+func disputeClaim(n Node) string {
+	return "opened by " + n.Name()
+}
+
+// openDisputes fires a dispute from every party at once and returns the
+// parties whose request was accepted
+func openDisputes(orderID string, parties ...Node) ([]Node, error) {
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		accepted []Node
+	)
+	start := make(chan struct{})
+	for _, p := range parties {
+		req, err := p.Client().NewRequest("POST", "/ob/opendispute", map[string]string{"orderId": orderID, "claim": disputeClaim(p)})
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func(p Node) {
+			defer wg.Done()
+			<-start
+			resp, err := p.Client().Send(req)
+			if err == nil && resp.OK() {
+				lock.Lock()
+				accepted = append(accepted, p)
+				lock.Unlock()
+			}
+		}(p)
+	}
+	close(start)
+	wg.Wait()
+	return accepted, nil
+}
+
+// sameDispute waits for the moderator to hold a single case for the order
+// and for every party to record the claim of that case. When only one party
+// got its dispute accepted the case must be the one it opened.
+func sameDispute(ctx context.Context, orderID string, moderator Node, accepted []Node, parties ...Node) error {
+	return poll(ctx, func() error {
+		cases, err := moderator.Client().Cases()
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, c := range cases {
+			if c.ID == orderID {
+				count++
+			}
+		}
+		if count != 1 {
+			return fmt.Errorf("moderator %s has %d cases for order %s, expected 1", moderator.Name(), count, orderID)
+		}
+		claim, buyerOpened, err := moderator.Client().CaseClaim(orderID)
+		if err != nil {
+			return err
+		}
+		if len(accepted) == 1 {
+			if want := disputeClaim(accepted[0]); claim != want {
+				return fmt.Errorf("moderator case claims %q, expected the only accepted dispute %q", claim, want)
+			}
+			if buyerOpened != (accepted[0].Role() == "buyer") {
+				return fmt.Errorf("moderator case has buyerOpened=%t but only %s opened a dispute", buyerOpened, accepted[0].Name())
+			}
+		}
+		for _, p := range parties {
+			got, err := p.Client().DisputeClaim(orderID)
+			if err != nil {
+				return err
+			}
+			if got != claim {
+				return fmt.Errorf("%s records claim %q, the moderator's case has %q", p.Name(), got, claim)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,273 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/chaos"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// expiredListing is how a buyer refuses to purchase a lapsed listing
+const expiredListing = "Listing expiration must be in the future"
+
+// ExpiryOptions times the listing expiry scenarios. The boundary is set on
+// the network's Clock, which the nodes must read too: they validate expiry
+// against their own time, so with real nodes that is the wall clock and the
+// scenarios wait the boundary out.
+type ExpiryOptions struct {
+	// Lead is how far ahead the listings expire once created, 45 seconds
+	// if zero
+	Lead time.Duration
+
+	// Resign is how long before the expiry the vendor re-signs, 20 seconds
+	// if zero
+	Resign time.Duration
+
+	// Margin is how long past the expiry purchases are tried, 5 seconds if
+	// zero
+	Margin time.Duration
+}
+
+func (o ExpiryOptions) withDefaults() ExpiryOptions {
+	if o.Lead == 0 {
+		o.Lead = 45 * time.Second
+	}
+	if o.Resign == 0 {
+		o.Resign = 20 * time.Second
+	}
+	if o.Margin == 0 {
+		o.Margin = 5 * time.Second
+	}
+	return o
+}
+
+// ListingResign has the vendor publish a listing about to expire and
+// re-sign it with a later expiry ahead of the boundary. The buyer must see
+// the new signature in the vendor's index before the old one expires, and
+// purchasing it must work on both sides of the boundary.
+func ListingResign(opts ExpiryOptions) Scenario {
+	opts = opts.withDefaults()
+	return Scenario{
+		Name:          "listing-expiry/resign",
+		Description:   "a listing re-signed before it expires is republished in time and stays purchasable",
+		Notifications: []string{"order"},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			if opts.Resign >= opts.Lead {
+				return fmt.Errorf("re-signing %s before the expiry leaves no time after creating the listing %s before it", opts.Resign, opts.Lead)
+			}
+			clock := net.clock()
+			expiry := clock.Now().Add(opts.Lead).Truncate(time.Second)
+
+			// The index misses the listing until it propagates, so the
+			// steps polling it expect errors
+			var listing map[string]interface{}
+			var slug, signed string
+			err = net.Expect("expiry/create", func() error {
+				var err error
+				if listing, slug, err = expiringListing(vendor, "Re-signed tee", expiry); err != nil {
+					return err
+				}
+				signed, err = seenHash(ctx, clock, expiry, buyer, vendor, slug, "")
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := net.Step("expiry/before", func() error {
+				return purchaseSeen(buyer, signed)
+			}); err != nil {
+				return err
+			}
+
+			if err := sleepUntil(ctx, clock, expiry.Add(-opts.Resign)); err != nil {
+				return err
+			}
+			err = net.Expect("expiry/resign", func() error {
+				listing["slug"] = slug
+				setExpiry(listing, expiry.Add(24*time.Hour))
+				if err := vendor.Client().UpdateListing(listing); err != nil {
+					return fmt.Errorf("re-signing %s on %s: %s", slug, vendor.Name(), err)
+				}
+				resigned, err := seenHash(ctx, clock, expiry, buyer, vendor, slug, signed)
+				if err != nil {
+					return fmt.Errorf("%s still saw the signature of %s expiring at %s: %s", buyer.Name(), slug, expiry.Format(time.RFC3339), err)
+				}
+				signed = resigned
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := sleepUntil(ctx, clock, expiry.Add(opts.Margin)); err != nil {
+				return err
+			}
+			return net.Step("expiry/after", func() error {
+				hash, err := listingHashOn(buyer, vendor, slug)
+				if err != nil {
+					return err
+				}
+				if hash != signed {
+					return fmt.Errorf("%s sees %s as %s past the expiry, re-signed as %s", buyer.Name(), slug, hash, signed)
+				}
+				return purchaseSeen(buyer, hash)
+			})
+		},
+	}
+}
+
+// ListingLapse has the vendor publish a listing about to expire and leave
+// it. Purchases must go through up to the boundary and be refused as
+// expired past it, rather than fail early or not at all.
+func ListingLapse(opts ExpiryOptions) Scenario {
+	opts = opts.withDefaults()
+	return Scenario{
+		Name:          "listing-expiry/lapse",
+		Description:   "a listing left to expire is purchasable up to its expiry and refused past it",
+		Notifications: []string{"order"},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			if opts.Margin >= opts.Lead {
+				return fmt.Errorf("purchasing %s before the expiry leaves no time after creating the listing %s before it", opts.Margin, opts.Lead)
+			}
+			clock := net.clock()
+			expiry := clock.Now().Add(opts.Lead).Truncate(time.Second)
+
+			var signed string
+			err = net.Expect("expiry/create", func() error {
+				_, slug, err := expiringListing(vendor, "Lapsing tee", expiry)
+				if err != nil {
+					return err
+				}
+				signed, err = seenHash(ctx, clock, expiry, buyer, vendor, slug, "")
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			// Purchase as close to the boundary as a purchase can take
+			last := expiry.Add(-opts.Margin)
+			if err := sleepUntil(ctx, clock, last); err != nil {
+				return err
+			}
+			if err := net.Step("expiry/before", func() error {
+				return purchaseSeen(buyer, signed)
+			}); err != nil {
+				return err
+			}
+
+			if err := sleepUntil(ctx, clock, expiry.Add(opts.Margin)); err != nil {
+				return err
+			}
+			return net.Expect("expiry/after", func() error {
+				_, err := buyer.Client().Purchase(fixtures.DirectOrder(signed))
+				if err == nil {
+					return fmt.Errorf("%s purchased a listing that expired at %s", buyer.Name(), expiry.Format(time.RFC3339))
+				}
+				if !strings.Contains(err.Error(), expiredListing) {
+					return fmt.Errorf("expected %s to refuse the lapsed listing as expired, got %s", buyer.Name(), err)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// clock is the clock scenarios time themselves on
+func (n *Network) clock() chaos.Clock {
+	if n.Clock != nil {
+		return n.Clock
+	}
+	return chaos.Wall
+}
+
+// sleepUntil waits for clock to reach t
+func sleepUntil(ctx context.Context, clock chaos.Clock, t time.Time) error {
+	select {
+	case <-clock.After(t.Sub(clock.Now())):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expiringListing publishes the fixture listing titled title on the vendor,
+// expiring at expiry, and returns it with its slug
+func expiringListing(vendor Node, title string, expiry time.Time) (map[string]interface{}, string, error) {
+	listing := fixtures.Listing()
+	listing["item"].(map[string]interface{})["title"] = title
+	setExpiry(listing, expiry)
+	slug, err := vendor.Client().CreateListing(listing)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating listing on %s: %s", vendor.Name(), err)
+	}
+	return listing, slug, nil
+}
+
+func setExpiry(listing map[string]interface{}, expiry time.Time) {
+	listing["metadata"].(map[string]interface{})["expiry"] = expiry.UTC().Format(time.RFC3339)
+}
+
+// listingHashOn returns the hash of the listing of vendor in its index as
+// buyer fetches it
+func listingHashOn(buyer, vendor Node, slug string) (string, error) {
+	listings, err := buyer.Client().Listings(vendor.PeerID())
+	if err != nil {
+		return "", err
+	}
+	for _, l := range listings {
+		if l.Slug == slug {
+			return l.Hash, nil
+		}
+	}
+	return "", fmt.Errorf("listing %s missing from the index of %s as %s sees it", slug, vendor.Name(), buyer.Name())
+}
+
+// seenHash waits until buyer sees the listing of vendor under a hash other
+// than old, and fails once clock passes deadline
+func seenHash(ctx context.Context, clock chaos.Clock, deadline time.Time, buyer, vendor Node, slug, old string) (string, error) {
+	var hash string
+	check := func() error {
+		var err error
+		if hash, err = listingHashOn(buyer, vendor, slug); err != nil {
+			return err
+		}
+		if hash == old {
+			return fmt.Errorf("%s sees %s as %s", buyer.Name(), slug, old)
+		}
+		return nil
+	}
+	for {
+		err := check()
+		if err == nil {
+			return hash, nil
+		}
+		if !clock.Now().Before(deadline) {
+			return "", err
+		}
+		select {
+		case <-clock.After(time.Second):
+		case <-ctx.Done():
+			return "", err
+		}
+	}
+}
+
+// purchaseSeen has buyer purchase the listing with hash
+func purchaseSeen(buyer Node, hash string) error {
+	if _, err := buyer.Client().Purchase(fixtures.DirectOrder(hash)); err != nil {
+		return fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// entry is the harness's own record of an order it placed
+type entry struct {
+	order    *Order
+	quantity int
+	state    string
+}
+
+// OrderExport places orders of different quantities, paying all but the
+// last, and checks the purchases of the buyer and the sales of the vendor
+// against the harness's ledger: every order listed once with the listing,
+// counterparty, state and total it was placed with, and the totals summing
+// to what the buyer was asked to pay. The node exports orders only as these
+// lists, so they are what an accounting export is taken from.
+func OrderExport(quantities ...int) Scenario {
+	if len(quantities) == 0 {
+		quantities = []int{1, 2, 3}
+	}
+	return Scenario{
+		Name:        "order-export",
+		Description: "purchase and sale listings agree with the orders placed, row by row and in total",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+			listings, err := vendor.Client().Listings("")
+			if err != nil {
+				return err
+			}
+			var title string
+			for _, l := range listings {
+				if l.Slug == slug {
+					title = l.Title
+				}
+			}
+
+			var ledger []entry
+			for i, q := range quantities {
+				e := entry{quantity: q, state: "AWAITING_FULFILLMENT"}
+				if i == len(quantities)-1 {
+					e.state = "AWAITING_PAYMENT"
+				}
+				err := net.Step(fmt.Sprintf("order/%d", q), func() error {
+					order := fixtures.DirectOrder(hash)
+					order["items"].([]interface{})[0].(map[string]interface{})["quantity"] = q
+					resp, err := buyer.Client().Purchase(order)
+					if err != nil {
+						return err
+					}
+					e.order = &Order{ID: resp.OrderID, ListingHash: hash, Slug: slug, Payment: resp}
+					if e.state == "AWAITING_FULFILLMENT" {
+						if err := PayOrder(buyer, e.order); err != nil {
+							return err
+						}
+					}
+					return WaitState(ctx, e.order.ID, e.state, buyer, vendor)
+				})
+				if err != nil {
+					return err
+				}
+				ledger = append(ledger, e)
+			}
+
+			purchases, err := buyer.Client().Purchases()
+			if err != nil {
+				return err
+			}
+			sales, err := vendor.Client().Sales()
+			if err != nil {
+				return err
+			}
+			var wrong []string
+			for _, side := range []struct {
+				name, counterparty string
+				rows               []client.Transaction
+				id                 func(client.Transaction) string
+			}{
+				{"purchases of " + buyer.Name(), vendor.PeerID(), purchases, func(t client.Transaction) string { return t.VendorID }},
+				{"sales of " + vendor.Name(), buyer.PeerID(), sales, func(t client.Transaction) string { return t.BuyerID }},
+			} {
+				var paid, listed uint64
+				for _, e := range ledger {
+					paid += e.order.Payment.Amount
+					var rows []client.Transaction
+					for _, t := range side.rows {
+						if t.OrderID == e.order.ID {
+							rows = append(rows, t)
+						}
+					}
+					if len(rows) != 1 {
+						wrong = append(wrong, fmt.Sprintf("%s list order %s %d times", side.name, e.order.ID, len(rows)))
+						continue
+					}
+					t := rows[0]
+					listed += t.Total
+					for _, f := range []struct {
+						field     string
+						got, want interface{}
+					}{
+						{"slug", t.Slug, slug},
+						{"title", t.Title, title},
+						{"total", t.Total, e.order.Payment.Amount},
+						{"counterparty", side.id(t), side.counterparty},
+						{"state", t.State, e.state},
+						{"moderated", t.Moderated, false},
+					} {
+						if f.got != f.want {
+							wrong = append(wrong, fmt.Sprintf("%s: order %s of %d has %s %v, expected %v", side.name, e.order.ID, e.quantity, f.field, f.got, f.want))
+						}
+					}
+				}
+				if listed != paid {
+					wrong = append(wrong, fmt.Sprintf("%s total %d, the orders were for %d", side.name, listed, paid))
+				}
+			}
+			if len(wrong) > 0 {
+				return fmt.Errorf("%s", strings.Join(wrong, "; "))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// IPv6Only checks a network whose nodes were started with
+// nodes.WithIPv6Only: every API answers, every swarm connection is over
+// IPv6 and the buyer can fetch the vendor's listings by peer ID, which needs
+// the vendor to be discovered and dialed without IPv4
+func IPv6Only() Scenario {
+	return addressFamily("network/ipv6-only", "/ip6/")
+}
+
+// DualStack checks a network whose nodes were started with
+// nodes.WithDualStack the same way, allowing connections over either family
+func DualStack() Scenario {
+	return addressFamily("network/dual-stack", "/ip4/", "/ip6/")
+}
+
+func addressFamily(name string, prefixes ...string) Scenario {
+	return Scenario{
+		Name:        name,
+		Description: fmt.Sprintf("discovery, dialing and the API work with swarm addresses %s", strings.Join(prefixes, ", ")),
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			if _, err := vendor.Client().CreateListing(fixtures.Listing()); err != nil {
+				return err
+			}
+			err = poll(ctx, func() error {
+				listings, err := buyer.Client().Listings(vendor.PeerID())
+				if err != nil {
+					return err
+				}
+				if len(listings) == 0 {
+					return fmt.Errorf("%s sees no listings of %s", buyer.Name(), vendor.Name())
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, n := range net.Nodes {
+				peers, err := n.Client().Peers()
+				if err != nil {
+					return fmt.Errorf("%s API: %s", n.Name(), err)
+				}
+				if len(peers) == 0 {
+					return fmt.Errorf("%s has no swarm connections", n.Name())
+				}
+				for _, p := range peers {
+					if !hasAnyPrefix(p, prefixes) {
+						return fmt.Errorf("%s is connected over %s", n.Name(), p)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+)
+
+// Faucet sends coins to wallet addresses and has them confirmed, e.g. a
+// chain.Chain, or the ledger.Ledger of mock wallets
+type Faucet interface {
+	Send(address string, sat int64) error
+}
+
+// FaucetFunc makes a function a Faucet
+type FaucetFunc func(address string, sat int64) error
+
+// Send calls f
+func (f FaucetFunc) Send(address string, sat int64) error {
+	return f(address, sat)
+}
+
+// Fund sends sat satoshis from the network's Faucet to the wallet of node
+// and waits until the node counts them confirmed
+func (n *Network) Fund(ctx context.Context, node Node, sat int64) error {
+	if n.Faucet == nil {
+		return fmt.Errorf("network has no faucet to fund %s from", node.Name())
+	}
+	before, err := node.Client().Balance()
+	if err != nil {
+		return fmt.Errorf("balance of %s: %s", node.Name(), err)
+	}
+	addr, err := node.Client().WalletAddress()
+	if err != nil {
+		return fmt.Errorf("wallet address of %s: %s", node.Name(), err)
+	}
+	if err := n.Faucet.Send(addr, sat); err != nil {
+		return fmt.Errorf("funding %s: %s", node.Name(), err)
+	}
+	return poll(ctx, func() error {
+		b, err := node.Client().Balance()
+		if err != nil {
+			return err
+		}
+		if b.Confirmed < before.Confirmed+sat {
+			return fmt.Errorf("wallet of %s holds %d confirmed, expected %d once funded", node.Name(), b.Confirmed, before.Confirmed+sat)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/OpenBazaar/openbazaar-go/test/chaos"
+)
+
+// withFaults runs fn while playing the fault schedule, failing if either
+// fails. The schedule is cut short when fn returns.
+func (n *Network) withFaults(ctx context.Context, schedule string, fn func(context.Context, *Network) error) error {
+	if schedule == "" {
+		return fn(ctx, n)
+	}
+	events, err := chaos.Parse(schedule)
+	if err != nil {
+		return err
+	}
+	f := &faults{net: n, paused: make(map[Node]bool)}
+	for _, e := range events {
+		if _, err := f.resolve(e); err != nil {
+			return fmt.Errorf("%s: %s", e, err)
+		}
+	}
+	defer f.cleanup(ctx)
+
+	playCtx, stop := context.WithCancel(ctx)
+	played := make(chan error, 1)
+	go func() {
+		played <- n.Step("faults", func() error {
+			if n.Clock != nil {
+				return chaos.PlayOn(playCtx, n.Clock, events, f.do)
+			}
+			return chaos.Play(playCtx, events, f.do)
+		})
+	}()
+	err = fn(ctx, n)
+	stop()
+	if perr := <-played; err == nil && perr != nil {
+		err = fmt.Errorf("fault schedule: %s", perr)
+	}
+	return err
+}
+
+// faults applies schedule events to a network, partitioning it with
+// Network.Partition
+type faults struct {
+	net    *Network
+	paused map[Node]bool
+}
+
+// resolve returns the nodes the event's targets name, by name or role
+func (f *faults) resolve(e chaos.Event) ([][]Node, error) {
+	names := [][]string{e.Targets}
+	if e.Action == chaos.Partition {
+		names = e.Groups
+	}
+	var ret [][]Node
+	for _, group := range names {
+		var nodes []Node
+		for _, name := range group {
+			if nd := f.net.Node(name); nd != nil {
+				nodes = append(nodes, nd)
+				continue
+			}
+			role := f.net.Role(name)
+			if len(role) == 0 {
+				return nil, fmt.Errorf("no node or role named %s", name)
+			}
+			nodes = append(nodes, role...)
+		}
+		ret = append(ret, nodes)
+	}
+	return ret, nil
+}
+
+func (f *faults) do(ctx context.Context, e chaos.Event) error {
+	groups, err := f.resolve(e)
+	if err != nil {
+		return err
+	}
+	switch e.Action {
+	case chaos.Partition:
+		return f.net.Partition(groups...)
+	case chaos.Heal:
+		return f.net.Heal()
+	}
+	for _, nd := range groups[0] {
+		if err := f.apply(ctx, e.Action, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *faults) apply(ctx context.Context, action string, nd Node) error {
+	switch action {
+	case chaos.Pause, chaos.Resume:
+		p, ok := nd.(Pauser)
+		if !ok {
+			return fmt.Errorf("%s cannot be paused", nd.Name())
+		}
+		if action == chaos.Resume {
+			delete(f.paused, nd)
+			return p.Resume(ctx)
+		}
+		f.paused[nd] = true
+		return p.Pause(ctx)
+	case chaos.Stop:
+		s, ok := nd.(Stopper)
+		if !ok {
+			return fmt.Errorf("%s cannot be stopped", nd.Name())
+		}
+		return s.Stop(ctx)
+	case chaos.Restart:
+		r, ok := nd.(Restarter)
+		if !ok {
+			return fmt.Errorf("%s cannot be restarted", nd.Name())
+		}
+		delete(f.paused, nd)
+		return r.Restart(ctx)
+	}
+	return fmt.Errorf("unknown action %s", action)
+}
+
+// cleanup resumes paused nodes and heals partitions so later scenarios start
+// from a whole network. Stopped nodes are left to the scenario.
+func (f *faults) cleanup(ctx context.Context) {
+	for nd := range f.paused {
+		nd.(Pauser).Resume(ctx)
+	}
+	f.net.Heal()
+}
@@ -0,0 +1,72 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// FeatureSwitcher is implemented by nodes that can be restarted with a
+// different set of experimental features enabled
+type FeatureSwitcher interface {
+	SetFeatures(ctx context.Context, features []string) error
+}
+
+// withFeatures enables the features on every node lacking one of them, runs
+// fn with the features active across the network and restores the previous
+// sets afterwards. Nodes that do not report their features are assumed to
+// have none.
+func (n *Network) withFeatures(ctx context.Context, required []string, fn func(active []string) error) error {
+	type switched struct {
+		sw       FeatureSwitcher
+		previous []string
+	}
+	var restore []switched
+	defer func() {
+		for _, s := range restore {
+			s.sw.SetFeatures(ctx, s.previous)
+		}
+	}()
+
+	union := make(map[string]bool)
+	for _, nd := range n.Nodes {
+		current, _ := nd.Client().Features()
+		missing := false
+		for _, f := range required {
+			if !contains(current, f) {
+				missing = true
+			}
+		}
+		if missing {
+			sw, ok := nd.(FeatureSwitcher)
+			if !ok {
+				return fmt.Errorf("node %s lacks features %v and cannot switch them on", nd.Name(), required)
+			}
+			want := mergeFeatures(current, required)
+			if err := sw.SetFeatures(ctx, want); err != nil {
+				return fmt.Errorf("enabling features %v on %s: %s", required, nd.Name(), err)
+			}
+			restore = append(restore, switched{sw, current})
+			current = want
+		}
+		for _, f := range current {
+			union[f] = true
+		}
+	}
+	var active []string
+	for f := range union {
+		active = append(active, f)
+	}
+	sort.Strings(active)
+	return fn(active)
+}
+
+func mergeFeatures(a, b []string) []string {
+	ret := append([]string{}, a...)
+	for _, f := range b {
+		if !contains(ret, f) {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
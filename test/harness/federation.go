@@ -0,0 +1,172 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// Federation checks two marketplaces run as private swarms, see
+// nodes.WithSwarm, joined only by a bridge operator with a node of role
+// bridge in each. A libp2p host belongs to one swarm, so the operator is
+// dual-homed through its two nodes rather than one.
+//
+// No node may be connected to a node of the other swarm, even when told to
+// dial it, and the buyer, in the swarm the vendor is not in, must not see
+// the vendor's listings. The only path across is the bridge: its node in
+// the buyer's swarm lists what the vendor sells, the buyer orders from it,
+// and its node in the vendor's swarm places the same order with the vendor.
+// Both orders must reach AWAITING_PAYMENT within settle, two minutes if
+// zero, and no node may hold a sale or purchase with a node of the other
+// swarm.
+func Federation(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "federation",
+		Description: "two private marketplaces only trade through a bridge operator",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			swarms := make(map[Node]string)
+			members := make(map[string]map[string]bool)
+			for _, nd := range net.Nodes {
+				s, ok := nd.(Swarmer)
+				if !ok {
+					return fmt.Errorf("scenario needs to know the swarm of %s", nd.Name())
+				}
+				swarms[nd] = s.Swarm()
+				if members[s.Swarm()] == nil {
+					members[s.Swarm()] = make(map[string]bool)
+				}
+				members[s.Swarm()][nd.PeerID()] = true
+			}
+			if len(members) != 2 {
+				return fmt.Errorf("scenario needs nodes in two swarms, not %d", len(members))
+			}
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor := vendors[0]
+			inSwarm := func(role string, vendorSide bool) Node {
+				for _, nd := range net.Role(role) {
+					if (swarms[nd] == swarms[vendor]) == vendorSide {
+						return nd
+					}
+				}
+				return nil
+			}
+			buyer, bridgeIn, bridgeOut := inSwarm("buyer", false), inSwarm("bridge", true), inSwarm("bridge", false)
+			if buyer == nil || bridgeIn == nil || bridgeOut == nil {
+				return fmt.Errorf("scenario needs a buyer outside the swarm of %s and a bridge in both swarms", vendor.Name())
+			}
+
+			err := net.Step("federation/isolation", func() error {
+				for _, a := range net.Nodes {
+					for _, b := range net.Nodes {
+						if swarms[a] == swarms[b] {
+							continue
+						}
+						connect(a, b)
+						if connected, err := a.Client().ConnectedTo(b.PeerID()); err != nil || connected {
+							return fmt.Errorf("%s of swarm %q connected to %s of swarm %q", a.Name(), swarms[a], b.Name(), swarms[b])
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+			if listings, err := buyer.Client().WithTimeout(30 * time.Second).Listings(vendor.PeerID()); err == nil && len(listings) > 0 {
+				return fmt.Errorf("%s sees the listings of %s across swarms", buyer.Name(), vendor.Name())
+			}
+
+			var bridgeHash string
+			err = net.Step("federation/relist", func() error {
+				wait, cancel := context.WithTimeout(ctx, settle)
+				defer cancel()
+				err := poll(wait, func() error {
+					if _, err := bridgeIn.Client().Listing(vendor.PeerID(), hash); err != nil {
+						return fmt.Errorf("%s fetching listing %s of %s: %s", bridgeIn.Name(), hash, vendor.Name(), err)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				bridgeSlug, err := bridgeOut.Client().CreateListing(fixtures.Listing())
+				if err != nil {
+					return err
+				}
+				if bridgeHash, err = listingHash(bridgeOut, bridgeSlug); err != nil {
+					return err
+				}
+				return poll(wait, func() error {
+					if _, err := buyer.Client().Listing(bridgeOut.PeerID(), bridgeHash); err != nil {
+						return fmt.Errorf("%s fetching listing %s of %s: %s", buyer.Name(), bridgeHash, bridgeOut.Name(), err)
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			err = net.Step("federation/order", func() error {
+				wait, cancel := context.WithTimeout(ctx, settle)
+				defer cancel()
+				resp, err := buyer.Client().Purchase(fixtures.DirectOrder(bridgeHash))
+				if err != nil {
+					return fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+				}
+				if err := WaitState(wait, resp.OrderID, "AWAITING_PAYMENT", buyer, bridgeOut); err != nil {
+					return err
+				}
+				forwarded, err := bridgeIn.Client().Purchase(fixtures.DirectOrder(hash))
+				if err != nil {
+					return fmt.Errorf("purchase by %s: %s", bridgeIn.Name(), err)
+				}
+				return WaitState(wait, forwarded.OrderID, "AWAITING_PAYMENT", bridgeIn, vendor)
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, nd := range net.Nodes {
+				own := members[swarms[nd]]
+				sales, err := nd.Client().Sales()
+				if err != nil {
+					return err
+				}
+				for _, s := range sales {
+					if !own[s.BuyerID] {
+						return fmt.Errorf("%s of swarm %q sold %s to %s of another swarm", nd.Name(), swarms[nd], s.OrderID, s.BuyerID)
+					}
+				}
+				purchases, err := nd.Client().Purchases()
+				if err != nil {
+					return err
+				}
+				for _, p := range purchases {
+					if !own[p.VendorID] {
+						return fmt.Errorf("%s of swarm %q bought %s from %s of another swarm", nd.Name(), swarms[nd], p.OrderID, p.VendorID)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,195 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/feeapi"
+)
+
+// FeeAPI is the fee estimate API the wallets query, see feeapi.Server
+type FeeAPI interface {
+	Set(fees feeapi.Fees)
+	Fail(f feeapi.Failure)
+	Requests() int
+}
+
+// FeeFaultsOptions configures the fee-faults scenario
+type FeeFaultsOptions struct {
+	// API is the one the wallets of the nodes query, see nodes.WithFeeAPI.
+	// It is required.
+	API FeeAPI
+
+	// MaxFee is the most the wallets pay per byte, 2000 satoshis, the
+	// default of openbazaard, if zero
+	MaxFee int64
+
+	// Cache is how long wallets keep the estimates they fetched, a minute,
+	// the cache of spvwallet, if zero
+	Cache time.Duration
+
+	// Funds are the satoshis the vendor and buyer are each funded with
+	// from the network's Faucet, 1e8 if zero
+	Funds int64
+
+	// Settle bounds how long each order may take to be paid and refunded,
+	// two minutes if zero
+	Settle time.Duration
+}
+
+// maxPaymentSize bounds the bytes of the transactions of the scenario,
+// which spend a few inputs to two outputs
+const maxPaymentSize = 1000
+
+// feeMode is what the fee API does for one order of the fee-faults scenario
+type feeMode struct {
+	name    string
+	fees    feeapi.Fees
+	failure feeapi.Failure
+}
+
+// FeeFaults runs an order to its refund for each way the fee API
+// misbehaves, on the first vendor and buyer, whose wallets must query the
+// API. The API fails with a server error, a malformed answer and no
+// answer, then serves typical, absurdly high, dust and zero estimates. The
+// order total must not depend on the estimates, the payment must cost no
+// more than MaxFee per byte on top of it, and the refund must return the
+// full total to the buyer. The wallet must have asked the API in each
+// case, or it was not tested.
+func FeeFaults(opts FeeFaultsOptions) Scenario {
+	if opts.MaxFee == 0 {
+		opts.MaxFee = 2000
+	}
+	if opts.Cache == 0 {
+		opts.Cache = time.Minute
+	}
+	if opts.Funds == 0 {
+		opts.Funds = 1e8
+	}
+	if opts.Settle == 0 {
+		opts.Settle = 2 * time.Minute
+	}
+	// Failures come first: wallets answer from their cache while it is
+	// fresh, and only estimates fill it
+	modes := []feeMode{
+		{name: "server-error", failure: feeapi.ServerError},
+		{name: "malformed", failure: feeapi.Malformed},
+		{name: "hang", failure: feeapi.Hang},
+		{name: "typical", fees: feeapi.Typical},
+		{name: "absurd", fees: feeapi.Absurd},
+		{name: "dust", fees: feeapi.Dust},
+		{name: "zero"},
+	}
+	return Scenario{
+		Name:          "fee-faults",
+		Description:   "orders are paid and refunded whatever the fee API estimates",
+		Requires:      []string{CapListings, CapOrders},
+		Notifications: []string{"order", "payment", "refund"},
+		Run: func(ctx context.Context, net *Network) error {
+			if opts.API == nil {
+				return fmt.Errorf("scenario needs the fee API of the wallets")
+			}
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			defer opts.API.Set(feeapi.Typical)
+
+			err = net.Step("fee-faults/fund", func() error {
+				wait, cancel := context.WithTimeout(ctx, opts.Settle)
+				defer cancel()
+				// The vendor pays the fee of refunds out of its own coins
+				for _, n := range []Node{vendor, buyer} {
+					if err := net.Fund(wait, n, opts.Funds); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			var total uint64
+			cached := false
+			for _, m := range modes {
+				m := m
+				if cached {
+					select {
+					case <-time.After(opts.Cache):
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				if m.failure != feeapi.None {
+					opts.API.Fail(m.failure)
+				} else {
+					opts.API.Set(m.fees)
+				}
+				cached = m.failure == feeapi.None
+				err := net.Step("fee-faults/"+m.name, func() error {
+					wait, cancel := context.WithTimeout(ctx, opts.Settle)
+					defer cancel()
+					requests := opts.API.Requests()
+					o, err := PlaceOrder(vendor, buyer, nil)
+					if err != nil {
+						return err
+					}
+					if total == 0 {
+						total = o.Payment.Amount
+					} else if o.Payment.Amount != total {
+						return fmt.Errorf("order %s totals %d, expected %d whatever the fee estimates", o.ID, o.Payment.Amount, total)
+					}
+
+					before, err := buyer.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if err := PayOrder(buyer, o); err != nil {
+						return err
+					}
+					if err := WaitState(wait, o.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+						return err
+					}
+					paid, err := buyer.Client().Balance()
+					if err != nil {
+						return err
+					}
+					fee := before.Total() - paid.Total() - int64(o.Payment.Amount)
+					if fee < 0 || fee > opts.MaxFee*maxPaymentSize {
+						return fmt.Errorf("paying order %s cost %s %d in fees, expected at most %d", o.ID, buyer.Name(), fee, opts.MaxFee*maxPaymentSize)
+					}
+
+					if err := vendor.Client().RefundOrder(o.ID); err != nil {
+						return fmt.Errorf("refund of %s by %s: %s", o.ID, vendor.Name(), err)
+					}
+					if err := WaitState(wait, o.ID, "REFUNDED", vendor, buyer); err != nil {
+						return err
+					}
+					err = poll(wait, func() error {
+						b, err := buyer.Client().Balance()
+						if err != nil {
+							return err
+						}
+						if b.Total() < paid.Total()+int64(o.Payment.Amount) {
+							return fmt.Errorf("wallet of %s holds %d, expected %d once refunded", buyer.Name(), b.Total(), paid.Total()+int64(o.Payment.Amount))
+						}
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+					if opts.API.Requests() == requests {
+						return fmt.Errorf("no wallet asked the fee API, the nodes do not query it")
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,132 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// Order is an order placed by a scenario
+type Order struct {
+	ID          string
+	ListingHash string
+	Slug        string
+	Payment     *client.PurchaseResponse
+}
+
+// VendorAndBuyer returns the first vendor and buyer of the network
+func VendorAndBuyer(net *Network) (Node, Node, error) {
+	vendors, buyers := net.Role("vendor"), net.Role("buyer")
+	if len(vendors) == 0 || len(buyers) == 0 {
+		return nil, nil, fmt.Errorf("scenario needs a vendor and a buyer")
+	}
+	return vendors[0], buyers[0], nil
+}
+
+// PlaceOrder publishes the fixture listing on the vendor and has the buyer
+// purchase it. The order is moderated when moderator is not nil.
+func PlaceOrder(vendor, buyer, moderator Node) (*Order, error) {
+	listing := fixtures.Listing()
+	if moderator != nil {
+		if err := moderator.Client().SetModerator(fixtures.Moderation()); err != nil {
+			return nil, fmt.Errorf("making %s a moderator: %s", moderator.Name(), err)
+		}
+		listing = fixtures.ModeratedListing(moderator.PeerID())
+	}
+	slug, err := vendor.Client().CreateListing(listing)
+	if err != nil {
+		return nil, fmt.Errorf("creating listing on %s: %s", vendor.Name(), err)
+	}
+	hash, err := listingHash(vendor, slug)
+	if err != nil {
+		return nil, err
+	}
+	order := fixtures.DirectOrder(hash)
+	if moderator != nil {
+		order = fixtures.ModeratedOrder(hash, moderator.PeerID())
+	}
+	resp, err := buyer.Client().Purchase(order)
+	if err != nil {
+		return nil, fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+	}
+	return &Order{ID: resp.OrderID, ListingHash: hash, Slug: slug, Payment: resp}, nil
+}
+
+// PayOrder funds the order from the buyer's wallet, or has a Payer buyer
+// pay it
+func PayOrder(buyer Node, o *Order) error {
+	spend := buyer.Client().Spend
+	if p, ok := buyer.(Payer); ok {
+		spend = p.Pay
+	}
+	if err := spend(o.Payment.PaymentAddress, o.Payment.Amount); err != nil {
+		return fmt.Errorf("paying order %s from %s: %s", o.ID, buyer.Name(), err)
+	}
+	return nil
+}
+
+// WaitState waits for every node to report the order in the given state
+func WaitState(ctx context.Context, orderID, state string, nodes ...Node) error {
+	for _, n := range nodes {
+		if err := n.Client().WaitOrderState(ctx, orderID, state); err != nil {
+			return fmt.Errorf("%s: %s", n.Name(), err)
+		}
+	}
+	return nil
+}
+
+func listingHash(vendor Node, slug string) (string, error) {
+	listings, err := vendor.Client().Listings("")
+	if err != nil {
+		return "", err
+	}
+	for _, l := range listings {
+		if l.Slug == slug {
+			return l.Hash, nil
+		}
+	}
+	return "", fmt.Errorf("listing %s missing from the index of %s", slug, vendor.Name())
+}
+
+// Checkout runs a full direct checkout: the vendor publishes a listing, the
+// buyer purchases and pays for it, and both sides see the order funded
+func Checkout(ctx context.Context, vendor, buyer Node) (*Order, error) {
+	order, err := PlaceOrder(vendor, buyer, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := PayOrder(buyer, order); err != nil {
+		return nil, err
+	}
+	if err := WaitState(ctx, order.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CompleteOrder runs Checkout, has the vendor fulfill and the buyer complete
+// the order, and waits up to settle for each side to see it COMPLETED
+func CompleteOrder(ctx context.Context, vendor, buyer Node, settle time.Duration) (*Order, error) {
+	order, err := Checkout(ctx, vendor, buyer)
+	if err != nil {
+		return nil, err
+	}
+	if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, order.Slug)); err != nil {
+		return nil, err
+	}
+	wait, cancel := context.WithTimeout(ctx, settle)
+	defer cancel()
+	if err := WaitState(wait, order.ID, "FULFILLED", buyer, vendor); err != nil {
+		return nil, err
+	}
+	if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, order.Slug)); err != nil {
+		return nil, err
+	}
+	if err := WaitState(wait, order.ID, "COMPLETED", buyer, vendor); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
@@ -0,0 +1,92 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/peers"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// GarbageOptions configures the garbage provider scenario
+type GarbageOptions struct {
+	// Deadline is how long a buyer may take to fetch a listing
+	Deadline time.Duration
+
+	// ExpectBan additionally requires the buyer to drop its connection to the
+	// rogue peer once the fetch completed
+	ExpectBan bool
+}
+
+// GarbageProvider has a rogue peer fetch and announce every listing of the
+// first vendor, then answer block requests with bytes that do not match the
+// requested multihash. The rogue should be a bootstrap peer of the buyer so
+// it is asked early. The buyer must reject the bad blocks and still fetch
+// each listing intact from the vendor.
+func GarbageProvider(rogue *peers.Peer, opts GarbageOptions) Scenario {
+	if opts.Deadline == 0 {
+		opts.Deadline = 90 * time.Second
+	}
+	return Scenario{
+		Name:        "garbage-provider",
+		Description: "blocks that fail hash validation are rejected and fetched elsewhere",
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			listings, err := vendor.Client().Listings("")
+			if err != nil {
+				return err
+			}
+			if len(listings) == 0 {
+				return fmt.Errorf("vendor %s has no listings to corrupt", vendor.Name())
+			}
+			for _, l := range listings {
+				c, err := cid.Decode(l.Hash)
+				if err != nil {
+					return err
+				}
+				if _, err := rogue.Node.Blocks.GetBlock(ctx, c); err != nil {
+					return err
+				}
+			}
+			rogue.SetCorrupt(true)
+
+			c := buyer.Client().WithTimeout(opts.Deadline)
+			for _, l := range listings {
+				resp, err := c.Get("/ob/listing/" + vendor.PeerID() + "/" + l.Hash)
+				if err != nil {
+					return fmt.Errorf("buyer did not fetch %s within %s: %s", l.Slug, opts.Deadline, err)
+				}
+				if err := resp.Err(); err != nil {
+					return fmt.Errorf("fetching %s: %s", l.Slug, err)
+				}
+				var listing struct {
+					Listing struct {
+						Slug string `json:"slug"`
+					} `json:"listing"`
+				}
+				if err := resp.Decode(&listing); err != nil {
+					return fmt.Errorf("buyer returned an undecodable listing for %s: %s", l.Slug, err)
+				}
+				if listing.Listing.Slug != l.Slug {
+					return fmt.Errorf("buyer returned listing %q for %s", listing.Listing.Slug, l.Slug)
+				}
+			}
+
+			if !opts.ExpectBan {
+				return nil
+			}
+			connected, err := buyer.Client().ConnectedTo(rogue.ID().Pretty())
+			if err != nil {
+				return err
+			}
+			if connected {
+				return fmt.Errorf("buyer is still connected to garbage provider %s", rogue.ID().Pretty())
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/gateway"
+)
+
+// GatewayContent has the first vendor publish a listing with a large image
+// and checks, through its gateway, the directory index of the published
+// root, range requests across block boundaries of the original image and the
+// content types of the listing and images
+func GatewayContent(deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = time.Minute
+	}
+	return Scenario{
+		Name:        "gateway-content",
+		Description: "the gateway serves directory indexes, byte ranges and content types of store content",
+		Requires:    []string{CapGateway},
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			c := vendors[0].Client()
+			img, err := NoiseImage(1200, 1200)
+			if err != nil {
+				return err
+			}
+			const filename = "gateway.jpg"
+			h, err := c.UploadImage(filename, img)
+			if err != nil {
+				return fmt.Errorf("uploading image to %s: %s", vendors[0].Name(), err)
+			}
+			slug, err := c.CreateListing(listingWithImage("Gateway listing", filename, h))
+			if err != nil {
+				return fmt.Errorf("creating listing on %s: %s", vendors[0].Name(), err)
+			}
+
+			// The root is republished after the listing is saved
+			ctx, cancel := context.WithTimeout(ctx, deadline)
+			defer cancel()
+			var root string
+			err = poll(ctx, func() error {
+				if root, err = c.RootHash(""); err != nil {
+					return err
+				}
+				return gateway.CheckIndex(c, "/ipfs/"+root+"/listings", slug+".json")
+			})
+			if err != nil {
+				return err
+			}
+			if err := gateway.CheckIndex(c, "/ipfs/"+root, "listings", "images", "listings.json"); err != nil {
+				return err
+			}
+			if err := gateway.CheckIndex(c, "/ipfs/"+root+"/images/original", filename); err != nil {
+				return err
+			}
+
+			full, err := c.GetBytes("/ipfs/" + h.Original)
+			if err != nil {
+				return err
+			}
+			if err := gateway.CheckRanges(c, "/ipfs/"+h.Original, full); err != nil {
+				return err
+			}
+
+			for p, want := range map[string]string{
+				"/ipfs/" + root + "/listings.json":               "application/json",
+				"/ipfs/" + root + "/listings/" + slug + ".json":  "application/json",
+				"/ipfs/" + root + "/images/original/" + filename: "image/jpeg",
+				"/ipfs/" + root + "/images/tiny/" + filename:     "image/jpeg",
+			} {
+				if err := gateway.CheckContentType(c, p, want); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
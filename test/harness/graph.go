@@ -0,0 +1,95 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/graph"
+)
+
+// MemoryReporter is implemented by nodes that can report their resident
+// memory in bytes
+type MemoryReporter interface {
+	MemoryUsage() (uint64, error)
+}
+
+// LargeGraphOptions bounds the cost of listing a large follower graph
+type LargeGraphOptions struct {
+	PageSize int
+
+	// MaxLatency is the slowest a single page may be
+	MaxLatency time.Duration
+
+	// MaxMemoryGrowth is how much the node's resident memory may grow while
+	// every page is listed. It is only checked on nodes that report memory.
+	MaxMemoryGrowth uint64
+}
+
+// DefaultLargeGraphOptions are the bounds used when none are given
+var DefaultLargeGraphOptions = LargeGraphOptions{
+	PageSize:        100,
+	MaxLatency:      500 * time.Millisecond,
+	MaxMemoryGrowth: 64 << 20,
+}
+
+// LargeGraph walks the followers and following lists of the first vendor,
+// whose repo was seeded with g before it started, and checks pagination,
+// latency and memory
+func LargeGraph(g *graph.Graph, opts LargeGraphOptions) Scenario {
+	if opts.PageSize == 0 {
+		opts = DefaultLargeGraphOptions
+	}
+	return Scenario{
+		Name:        "large-graph",
+		Description: fmt.Sprintf("list %d followers and %d followings page by page", len(g.Followers), len(g.Following)),
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor := vendors[0]
+			mem, reportsMemory := vendor.(MemoryReporter)
+			var before uint64
+			if reportsMemory {
+				var err error
+				if before, err = mem.MemoryUsage(); err != nil {
+					return err
+				}
+			}
+			for _, list := range []struct {
+				path string
+				want []string
+			}{
+				{"/ob/followers", g.Followers},
+				{"/ob/following", g.Following},
+			} {
+				var stats *graph.PageStats
+				err := net.Step("walk "+list.path, func() error {
+					var err error
+					stats, err = graph.Walk(vendor.Client(), list.path, list.want, opts.PageSize, opts.MaxLatency)
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("%s: %s", list.path, err)
+				}
+				tags := map[string]string{"path": list.path}
+				net.Metrics.Timing("page_latency_p95_seconds", stats.P95Latency, tags)
+				net.Metrics.Timing("page_latency_max_seconds", stats.MaxLatency, tags)
+			}
+			if !reportsMemory {
+				return nil
+			}
+			after, err := mem.MemoryUsage()
+			if err != nil {
+				return err
+			}
+			net.Metrics.Record("resident_memory_bytes", float64(after), map[string]string{"node": vendor.Name()})
+			if after > before && after-before > opts.MaxMemoryGrowth {
+				return fmt.Errorf("listing the graph grew %s from %d to %d bytes, limit is %d", vendor.Name(), before, after, opts.MaxMemoryGrowth)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// LocalGuests starts guests for the network as nodes.StartGuest processes
+// of binary, bootstrapping from the nodes of the network that know their
+// swarm addresses
+func LocalGuests(net *Network, binary string, opts ...nodes.Option) func(context.Context, string) (Node, func() error, error) {
+	return func(ctx context.Context, name string) (Node, func() error, error) {
+		var bootstrap []string
+		for _, nd := range net.Nodes {
+			if a, ok := nd.(Addresser); ok {
+				bootstrap = append(bootstrap, a.SwarmAddrs()...)
+			}
+		}
+		g, err := nodes.StartGuest(ctx, binary, append(opts, nodes.WithBootstrap(bootstrap...))...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("starting guest %s: %s", name, err)
+		}
+		return NewLocalNode(name, "guest", g.Process), g.Discard, nil
+	}
+}
+
+// GuestCheckout has a guest, a node started for the occasion with a fresh
+// identity, buy from the vendor. The guest has no profile and the vendor
+// cannot resolve anything of it before the purchase, yet the order must
+// reach AWAITING_PAYMENT on both within settle, two minutes if zero, and
+// the vendor must keep the sale under the guest's peer ID after the guest
+// is discarded.
+func GuestCheckout(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "guest-checkout",
+		Description: "a vendor takes an order from a throwaway buyer it has never seen",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor := vendors[0]
+			if net.Guest == nil {
+				return fmt.Errorf("scenario needs the network to start guests")
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+
+			guest, discard, err := net.Guest(ctx, "guest-1")
+			if err != nil {
+				return err
+			}
+			discarded := false
+			defer func() {
+				if !discarded {
+					discard()
+				}
+			}()
+			err = net.Expect("guest/no-profile", func() error {
+				if _, err := guest.Client().Profile("", false); err == nil {
+					return fmt.Errorf("guest %s has a profile", guest.PeerID())
+				}
+				if _, err := vendor.Client().Profile(guest.PeerID(), false); err == nil {
+					return fmt.Errorf("%s resolved a profile of guest %s before it bought anything", vendor.Name(), guest.PeerID())
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			var orderID string
+			err = net.Step("guest/purchase", func() error {
+				resp, err := guest.Client().Purchase(fixtures.DirectOrder(hash))
+				if err != nil {
+					return fmt.Errorf("purchase by guest %s: %s", guest.PeerID(), err)
+				}
+				orderID = resp.OrderID
+				wait, cancel := context.WithTimeout(ctx, settle)
+				defer cancel()
+				return WaitState(wait, orderID, "AWAITING_PAYMENT", guest, vendor)
+			})
+			if err != nil {
+				return err
+			}
+
+			discarded = true
+			if err := discard(); err != nil {
+				return fmt.Errorf("discarding guest %s: %s", guest.PeerID(), err)
+			}
+			sales, err := vendor.Client().Sales()
+			if err != nil {
+				return err
+			}
+			for _, s := range sales {
+				if s.OrderID == orderID {
+					if s.BuyerID != guest.PeerID() {
+						return fmt.Errorf("%s recorded sale %s to %s, not guest %s", vendor.Name(), orderID, s.BuyerID, guest.PeerID())
+					}
+					return vendor.Client().WaitOrderState(ctx, orderID, "AWAITING_PAYMENT")
+				}
+			}
+			return fmt.Errorf("%s lost sale %s once guest %s was gone", vendor.Name(), orderID, guest.PeerID())
+		},
+	}
+}
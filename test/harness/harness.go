@@ -0,0 +1,192 @@
+// Package harness ties running test nodes, scenarios and checks together
+package harness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/artifacts"
+	"github.com/OpenBazaar/openbazaar-go/test/chaos"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+	"github.com/OpenBazaar/openbazaar-go/test/schema"
+)
+
+// Node is a running node under test
+type Node interface {
+	// Name is the harness assigned name, e.g. vendor-1
+	Name() string
+
+	// Role is what the node does in the scenario, e.g. vendor or buyer
+	Role() string
+
+	// PeerID is the node's base58 encoded peer ID
+	PeerID() string
+
+	// Client returns an API client for the node
+	Client() *client.Client
+}
+
+// Restarter is implemented by nodes that can be restarted in place, keeping
+// their repo and identity
+type Restarter interface {
+	Restart(ctx context.Context) error
+}
+
+// Stopper is implemented by nodes that can be shut down without losing their
+// repo
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// Pauser is implemented by nodes that can be suspended in place: their
+// connections stay open but they stop answering until resumed
+type Pauser interface {
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+}
+
+// Configurer is implemented by nodes whose config can be changed, see
+// nodes.Process.SetConfig
+type Configurer interface {
+	SetConfig(ctx context.Context, path string, value interface{}) error
+}
+
+// Addresser is implemented by nodes that know the swarm addresses other
+// nodes can dial them at
+type Addresser interface {
+	SwarmAddrs() []string
+}
+
+// Delayer is implemented by nodes whose traffic to a peer can be delayed.
+// DelayTo adds d to what the node sends peer, zero removing the delay.
+type Delayer interface {
+	DelayTo(ctx context.Context, peer Node, d time.Duration) error
+}
+
+// Impairer is implemented by nodes whose traffic to a peer can be jittered
+// and dropped as well as delayed. ImpairTo replaces what the node does to
+// the packets it sends peer, the zero Impairment removing it.
+type Impairer interface {
+	ImpairTo(ctx context.Context, peer Node, i netem.Impairment) error
+}
+
+// Translator is implemented by nodes that may sit behind a NAT, see
+// nodes.WithNAT
+type Translator interface {
+	NAT() nodes.NAT
+}
+
+// Onioner is implemented by nodes that may use Tor, see nodes.WithTor
+type Onioner interface {
+	Tor() nodes.Tor
+}
+
+// Swarmer is implemented by nodes that may be in a private swarm, see
+// nodes.WithSwarm. Swarm is empty for the public one.
+type Swarmer interface {
+	Swarm() string
+}
+
+// Payer is implemented by nodes without a wallet backend to spend from,
+// which are told of the payment of an order instead, see sim.Network.Pay
+type Payer interface {
+	Pay(addr string, amount uint64) error
+}
+
+// Network is the set of nodes a scenario runs against
+type Network struct {
+	Nodes []Node
+
+	// Metrics receives step latencies and sampled gauges, it may be nil
+	Metrics *metrics.Recorder
+
+	// ProfileDir is where heap profiles of nodes that exceed their memory
+	// ceiling are written, the system temp dir when empty
+	ProfileDir string
+
+	// Artifacts, when set, is where heap profiles are stored instead of
+	// ProfileDir
+	Artifacts artifacts.Store
+
+	// Schema, when set, checks the API responses of every node. Drift seen
+	// while a scenario runs fails it.
+	Schema *schema.Checker
+
+	// SweepContent, when set, fetches every content hash that listings and
+	// orders started referencing during a scenario from a node that does
+	// not reference it, and fails the scenario if the bytes served do not
+	// hash back to it
+	SweepContent bool
+
+	// Strict, when set, fails scenarios on node log warnings, API errors
+	// outside steps expecting them and unexpected notifications
+	Strict *Strict
+
+	// Verifiers bounds how many listings, orders and content hashes the
+	// content sweep fetches and checks at once, the number of CPUs when
+	// zero
+	Verifiers int
+
+	// Clock is what fault schedules are played on, the wall clock when nil
+	Clock chaos.Clock
+
+	// Faucet funds node wallets, see Fund. Networks without wallets have
+	// none.
+	Faucet Faucet
+
+	// Guest starts a short-lived node with a fresh identity that keeps
+	// nothing once discarded, see LocalGuests. Scenarios modelling guest
+	// checkouts need it.
+	Guest func(ctx context.Context, name string) (guest Node, discard func() error, err error)
+
+	ceilings []memoryCeiling
+
+	stepLock   sync.Mutex
+	failedStep string
+
+	partitionLock sync.Mutex
+	banned        map[[2]Node]bool
+}
+
+// Node returns the node with the given name or nil
+func (n *Network) Node(name string) Node {
+	for _, nd := range n.Nodes {
+		if nd.Name() == name {
+			return nd
+		}
+	}
+	return nil
+}
+
+// Role returns every node with the given role
+func (n *Network) Role(role string) []Node {
+	var ret []Node
+	for _, nd := range n.Nodes {
+		if nd.Role() == role {
+			ret = append(ret, nd)
+		}
+	}
+	return ret
+}
+
+// Step runs fn as a named scenario step and records its latency
+func (n *Network) Step(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	status := "ok"
+	if err != nil {
+		status = "failed"
+		// Steps nest, the innermost one returns first
+		n.stepLock.Lock()
+		if n.failedStep == "" {
+			n.failedStep = name
+		}
+		n.stepLock.Unlock()
+	}
+	n.Metrics.Timing("step_latency_seconds", time.Since(start), map[string]string{"step": name, "status": status})
+	return err
+}
@@ -0,0 +1,101 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// HungPeer suspends the vendor after it published a listing, so it stays
+// connected to the buyer but never answers. Looking up the vendor's profile
+// and listings, and purchasing from it, must each return within timeout
+// instead of waiting on the hung peer, and the purchase must report the
+// vendor offline. Once the vendor is resumed the order has to reach it and
+// be paid within settle. The vendor must implement Pauser.
+func HungPeer(timeout, settle time.Duration) Scenario {
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	if settle == 0 {
+		settle = 5 * time.Minute
+	}
+	return Scenario{
+		Name:        "hung-peer",
+		Description: "calls involving a connected but unresponsive vendor time out and the order resumes once it answers",
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			pauser, ok := vendor.(Pauser)
+			if !ok {
+				return fmt.Errorf("vendor %s cannot be paused", vendor.Name())
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+			connected, err := buyer.Client().ConnectedTo(vendor.PeerID())
+			if err != nil {
+				return err
+			}
+			if !connected {
+				return fmt.Errorf("%s is not connected to %s before pausing it", buyer.Name(), vendor.Name())
+			}
+
+			if err := pauser.Pause(ctx); err != nil {
+				return err
+			}
+			paused := true
+			defer func() {
+				if paused {
+					pauser.Resume(ctx)
+				}
+			}()
+
+			c := buyer.Client().WithTimeout(timeout)
+			for _, call := range []struct{ name, path string }{
+				{"profile", "/ob/profile/" + vendor.PeerID() + "?usecache=false"},
+				{"listings", "/ob/listings/" + vendor.PeerID()},
+				{"listing", "/ob/listing/" + vendor.PeerID() + "/" + slug},
+			} {
+				start := time.Now()
+				if _, err := c.Get(call.path); err != nil {
+					return fmt.Errorf("GET %s on %s with %s hung: %s", call.path, buyer.Name(), vendor.Name(), err)
+				}
+				net.Metrics.Timing("hung_peer_call_seconds", time.Since(start), map[string]string{"call": call.name})
+			}
+
+			start := time.Now()
+			resp, err := c.Purchase(fixtures.DirectOrder(hash))
+			if err != nil {
+				return fmt.Errorf("purchase from hung %s: %s", vendor.Name(), err)
+			}
+			net.Metrics.Timing("hung_peer_call_seconds", time.Since(start), map[string]string{"call": "purchase"})
+			if resp.VendorOnline {
+				return fmt.Errorf("purchase reported hung %s online", vendor.Name())
+			}
+
+			if err := pauser.Resume(ctx); err != nil {
+				return err
+			}
+			paused = false
+			order := &Order{ID: resp.OrderID, ListingHash: hash, Slug: slug, Payment: resp}
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			if err := WaitState(wait, order.ID, "AWAITING_PAYMENT", vendor); err != nil {
+				return fmt.Errorf("order never reached the resumed vendor: %s", err)
+			}
+			if err := PayOrder(buyer, order); err != nil {
+				return err
+			}
+			return WaitState(wait, order.ID, "AWAITING_FULFILLMENT", buyer, vendor)
+		},
+	}
+}
@@ -0,0 +1,230 @@
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// Duplicator is implemented by nodes that can deliver every marketplace
+// message they send twice, see the test/duplicate package
+type Duplicator interface {
+	SetDuplicate(enabled bool) error
+}
+
+// NetworkFactory starts a fresh network for one run of the idempotency gate.
+// With duplicate set every node must send each marketplace message twice.
+// The returned function tears the network down.
+type NetworkFactory func(ctx context.Context, duplicate bool) (*Network, func(), error)
+
+// EndState is the part of a node's state that must not depend on how many
+// times each message was delivered. Order IDs and peer IDs differ between
+// runs, so records are reduced to counts and peers to harness node names.
+type EndState struct {
+	Purchases     map[string]int
+	Sales         map[string]int
+	Cases         map[string]int
+	Followers     []string
+	Following     []string
+	Chat          map[string]int
+	Notifications map[string]int
+}
+
+// CaptureEndState reads the end state of every node in the network
+func CaptureEndState(net *Network) (map[string]*EndState, error) {
+	names := make(map[string]string)
+	for _, n := range net.Nodes {
+		names[n.PeerID()] = n.Name()
+	}
+	states := make(map[string]*EndState)
+	for _, n := range net.Nodes {
+		s, err := captureEndState(n.Client(), names)
+		if err != nil {
+			return nil, fmt.Errorf("capturing state of %s: %s", n.Name(), err)
+		}
+		states[n.Name()] = s
+	}
+	return states, nil
+}
+
+func captureEndState(c *client.Client, names map[string]string) (*EndState, error) {
+	s := &EndState{Chat: make(map[string]int), Notifications: make(map[string]int)}
+	var err error
+	if s.Purchases, err = countStates(c, "/ob/purchases", "purchases"); err != nil {
+		return nil, err
+	}
+	if s.Sales, err = countStates(c, "/ob/sales", "sales"); err != nil {
+		return nil, err
+	}
+	if s.Cases, err = countStates(c, "/ob/cases", "cases"); err != nil {
+		return nil, err
+	}
+	followers, err := c.Followers()
+	if err != nil {
+		return nil, err
+	}
+	s.Followers = nodeNames(followers, names)
+	following, err := c.Following()
+	if err != nil {
+		return nil, err
+	}
+	s.Following = nodeNames(following, names)
+
+	var conversations []struct {
+		PeerID string `json:"peerId"`
+	}
+	if err := c.GetJSON("/ob/chatconversations", &conversations); err != nil {
+		return nil, err
+	}
+	for _, conv := range conversations {
+		messages, err := c.ChatMessages(conv.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		s.Chat[nodeName(conv.PeerID, names)] += len(messages)
+	}
+
+	notifications, _, err := c.Notifications()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notifications {
+		s.Notifications[n.Type]++
+	}
+	return s, nil
+}
+
+// Diff lists every field that differs from other
+func (s *EndState) Diff(other *EndState) []string {
+	var diffs []string
+	a, b := reflect.ValueOf(*s), reflect.ValueOf(*other)
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			diffs = append(diffs, fmt.Sprintf("%s %v != %v", a.Type().Field(i).Name, a.Field(i).Interface(), b.Field(i).Interface()))
+		}
+	}
+	return diffs
+}
+
+// Idempotency runs the scenario against a fresh network, then again against
+// a fresh network whose nodes deliver every marketplace message twice, and
+// fails unless every node ends up in the same state in both runs. settle is
+// how long to wait after each run for duplicates still in flight.
+func Idempotency(s Scenario, factory NetworkFactory, settle time.Duration) Scenario {
+	return Scenario{
+		Name:        "idempotency/" + s.Name,
+		Description: s.Description + ", with every message delivered twice",
+		Version:     s.Version,
+		Run: func(ctx context.Context, _ *Network) error {
+			baseline, err := runForState(ctx, s, factory, false, settle)
+			if err != nil {
+				return fmt.Errorf("baseline run: %s", err)
+			}
+			duplicated, err := runForState(ctx, s, factory, true, settle)
+			if err != nil {
+				return fmt.Errorf("duplicated run: %s", err)
+			}
+			var errs []string
+			for _, name := range sortedNames(baseline, duplicated) {
+				want, got := baseline[name], duplicated[name]
+				if want == nil || got == nil {
+					errs = append(errs, fmt.Sprintf("%s only present in one run", name))
+					continue
+				}
+				for _, d := range want.Diff(got) {
+					errs = append(errs, fmt.Sprintf("%s: %s", name, d))
+				}
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("end state changed when messages were duplicated: %s", strings.Join(errs, "; "))
+			}
+			return nil
+		},
+	}
+}
+
+func runForState(ctx context.Context, s Scenario, factory NetworkFactory, duplicate bool, settle time.Duration) (map[string]*EndState, error) {
+	net, teardown, err := factory(ctx, duplicate)
+	if err != nil {
+		return nil, err
+	}
+	defer teardown()
+	if duplicate {
+		for _, n := range net.Nodes {
+			d, ok := n.(Duplicator)
+			if !ok {
+				return nil, fmt.Errorf("node %s cannot duplicate messages", n.Name())
+			}
+			if err := d.SetDuplicate(true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.Run(ctx, net); err != nil {
+		return nil, err
+	}
+	select {
+	case <-time.After(settle):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return CaptureEndState(net)
+}
+
+// countStates returns how many records of an order list are in each state
+func countStates(c *client.Client, path, key string) (map[string]int, error) {
+	var resp map[string]json.RawMessage
+	if err := c.GetJSON(path, &resp); err != nil {
+		return nil, err
+	}
+	var records []struct {
+		State string `json:"state"`
+	}
+	if len(resp[key]) > 0 {
+		if err := json.Unmarshal(resp[key], &records); err != nil {
+			return nil, err
+		}
+	}
+	counts := make(map[string]int)
+	for _, r := range records {
+		counts[r.State]++
+	}
+	return counts, nil
+}
+
+func nodeName(peerID string, names map[string]string) string {
+	if name, ok := names[peerID]; ok {
+		return name
+	}
+	return "external"
+}
+
+func nodeNames(peerIDs []string, names map[string]string) []string {
+	ret := []string{}
+	for _, p := range peerIDs {
+		ret = append(ret, nodeName(p, names))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func sortedNames(states ...map[string]*EndState) []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, m := range states {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				ret = append(ret, name)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
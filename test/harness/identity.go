@@ -0,0 +1,201 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// IdentityRotator is implemented by nodes that can be stopped, given a fresh
+// identity key and started again with the rest of their repo intact.
+// PeerID reports the new ID once RotateIdentity returns.
+type IdentityRotator interface {
+	RotateIdentity(ctx context.Context) error
+}
+
+// IdentityRotation has the first vendor regenerate its identity after a buyer
+// followed it and placed an order. A new peer ID is a new store as far as the
+// network is concerned, so afterwards:
+//
+//   - the vendor still has its listings, its sale and its followers
+//   - the buyer keeps the purchase and the follow under the old peer ID
+//   - the old peer ID never resolves to a profile claiming the new one
+//   - the new peer ID serves the vendor's profile once it is re-saved
+func IdentityRotation(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:            "identity-rotation",
+		Description:     "a vendor regenerating its keys keeps its store and order history",
+		RotatesIdentity: true,
+		Version:         1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			rotator, ok := vendor.(IdentityRotator)
+			if !ok {
+				return fmt.Errorf("node %s cannot rotate its identity", vendor.Name())
+			}
+			oldID := vendor.PeerID()
+			if err := buyer.Client().Follow(oldID); err != nil {
+				return err
+			}
+			order, err := PlaceOrder(vendor, buyer, nil)
+			if err != nil {
+				return err
+			}
+			waitCtx, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			if err := WaitState(waitCtx, order.ID, "AWAITING_PAYMENT", buyer, vendor); err != nil {
+				return err
+			}
+			if err := waitFollower(waitCtx, vendor.Client(), buyer.PeerID()); err != nil {
+				return err
+			}
+			var profile struct {
+				Name string `json:"name"`
+			}
+			if err := vendor.Client().GetJSON("/ob/profile", &profile); err != nil {
+				return err
+			}
+			listingsBefore, err := vendor.Client().Listings("")
+			if err != nil {
+				return err
+			}
+
+			if err := rotator.RotateIdentity(ctx); err != nil {
+				return err
+			}
+			newID := vendor.PeerID()
+			if newID == oldID {
+				return fmt.Errorf("vendor kept peer ID %s after rotating its identity", oldID)
+			}
+
+			// Store content
+			listingsAfter, err := vendor.Client().Listings("")
+			if err != nil {
+				return err
+			}
+			if err := sameSlugs(listingsBefore, listingsAfter); err != nil {
+				return err
+			}
+			if err := WaitState(waitCtx, order.ID, "AWAITING_PAYMENT", vendor, buyer); err != nil {
+				return fmt.Errorf("order history lost: %s", err)
+			}
+			var purchase struct {
+				Contract struct {
+					VendorListings []struct {
+						VendorID struct {
+							PeerID string `json:"peerID"`
+						} `json:"vendorID"`
+					} `json:"vendorListings"`
+				} `json:"contract"`
+			}
+			if err := buyer.Client().GetJSON("/ob/order/"+order.ID, &purchase); err != nil {
+				return err
+			}
+			for _, l := range purchase.Contract.VendorListings {
+				if l.VendorID.PeerID != oldID {
+					return fmt.Errorf("buyer's purchase names vendor %s, expected the original %s", l.VendorID.PeerID, oldID)
+				}
+			}
+			followers, err := vendor.Client().Followers()
+			if err != nil {
+				return err
+			}
+			if !contains(followers, buyer.PeerID()) {
+				return fmt.Errorf("vendor lost follower %s", buyer.PeerID())
+			}
+			following, err := buyer.Client().Following()
+			if err != nil {
+				return err
+			}
+			if !contains(following, oldID) {
+				return fmt.Errorf("buyer no longer follows %s", oldID)
+			}
+
+			// Resolution
+			resp, err := vendor.Client().Patch("/ob/profile", map[string]interface{}{})
+			if err != nil {
+				return err
+			}
+			if err := resp.Err(); err != nil {
+				return fmt.Errorf("re-saving the profile: %s", err)
+			}
+			resp, err = buyer.Client().Get("/ob/profile/" + oldID)
+			if err != nil {
+				return err
+			}
+			switch resp.StatusCode {
+			case http.StatusNotFound:
+			case http.StatusOK:
+				var stale struct {
+					PeerID string `json:"peerID"`
+				}
+				if err := resp.Decode(&stale); err != nil {
+					return err
+				}
+				if stale.PeerID != oldID {
+					return fmt.Errorf("old peer ID %s resolved to profile of %s", oldID, stale.PeerID)
+				}
+			default:
+				return fmt.Errorf("fetching the old profile returned %d: %s", resp.StatusCode, resp.Body)
+			}
+			var fresh struct {
+				PeerID string `json:"peerID"`
+				Name   string `json:"name"`
+			}
+			if err := buyer.Client().GetJSON("/ob/profile/"+newID, &fresh); err != nil {
+				return fmt.Errorf("fetching the rotated profile: %s", err)
+			}
+			if fresh.PeerID != newID || fresh.Name != profile.Name {
+				return fmt.Errorf("rotated profile is %s/%q, expected %s/%q", fresh.PeerID, fresh.Name, newID, profile.Name)
+			}
+			return nil
+		},
+	}
+}
+
+func waitFollower(ctx context.Context, c *client.Client, peerID string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		followers, err := c.Followers()
+		if err == nil && contains(followers, peerID) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s never became a follower", peerID)
+		case <-ticker.C:
+		}
+	}
+}
+
+func sameSlugs(before, after []client.ListingSummary) error {
+	have := make(map[string]bool)
+	for _, l := range after {
+		have[l.Slug] = true
+	}
+	for _, l := range before {
+		if !have[l.Slug] {
+			return fmt.Errorf("listing %s lost after identity rotation", l.Slug)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
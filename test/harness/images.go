@@ -0,0 +1,187 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// ProfileImages are the hashes returned when setting a node's avatar and header
+type ProfileImages struct {
+	Avatar *client.ImageHashes
+	Header *client.ImageHashes
+}
+
+// SetProfileImages uploads a freshly generated avatar and header so their
+// hashes differ from anything cached by other nodes
+func SetProfileImages(n Node) (*ProfileImages, error) {
+	avatar, err := RandomImage(200, 200)
+	if err != nil {
+		return nil, err
+	}
+	header, err := RandomImage(630, 180)
+	if err != nil {
+		return nil, err
+	}
+	imgs := new(ProfileImages)
+	if imgs.Avatar, err = n.Client().SetAvatar(avatar); err != nil {
+		return nil, fmt.Errorf("setting avatar on %s: %s", n.Name(), err)
+	}
+	if imgs.Header, err = n.Client().SetHeader(header); err != nil {
+		return nil, fmt.Errorf("setting header on %s: %s", n.Name(), err)
+	}
+	return imgs, nil
+}
+
+// RandomImage returns a base64 encoded PNG of a single random colour
+func RandomImage(width, height int) (string, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	c := color.RGBA{uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), 255}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// AssertImagesPropagated checks, within the deadline, that the observer's
+// cached copy of the owner's profile carries the expected image hashes and
+// that every size can be fetched through the observer's avatar and header
+// endpoints and through the gateway at gatewayURL with the same content the
+// owner serves. An empty gatewayURL uses the observer's own gateway.
+func AssertImagesPropagated(ctx context.Context, owner, observer Node, imgs *ProfileImages, gatewayURL string, deadline time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	gateway := observer.Client()
+	if gatewayURL != "" {
+		gateway = client.New(gatewayURL).WithTimeout(deadline)
+	}
+
+	err := poll(ctx, func() error {
+		p, err := observer.Client().Profile(owner.PeerID(), true)
+		if err != nil {
+			return err
+		}
+		if err := sameHashes("avatar", p.AvatarHashes, imgs.Avatar); err != nil {
+			return err
+		}
+		return sameHashes("header", p.HeaderHashes, imgs.Header)
+	})
+	if err != nil {
+		return fmt.Errorf("%s never saw the images of %s: %s", observer.Name(), owner.Name(), err)
+	}
+
+	for _, kind := range []struct {
+		name   string
+		hashes *client.ImageHashes
+		fetch  func(peerID, size string, useCache bool) ([]byte, error)
+	}{
+		{"avatar", imgs.Avatar, observer.Client().Avatar},
+		{"header", imgs.Header, observer.Client().Header},
+	} {
+		for _, size := range client.ImageSizes {
+			hash := kind.hashes.Size(size)
+			want, err := owner.Client().Image(hash)
+			if err != nil {
+				return fmt.Errorf("%s cannot serve its own %s %s: %s", owner.Name(), size, kind.name, err)
+			}
+			sources := []struct {
+				via   string
+				fetch func() ([]byte, error)
+			}{
+				{"profile path", func() ([]byte, error) { return kind.fetch(owner.PeerID(), size, true) }},
+				{"gateway", func() ([]byte, error) { return gateway.GetBytes("/ipfs/" + hash) }},
+			}
+			for _, src := range sources {
+				err := poll(ctx, func() error {
+					got, err := src.fetch()
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(got, want) {
+						return fmt.Errorf("content differs from %s", hash)
+					}
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("%s %s of %s via %s: %s", size, kind.name, owner.Name(), src.via, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ImagePropagation sets new profile images on the first vendor and asserts
+// every other node can retrieve them within the deadline
+func ImagePropagation(gatewayURL string, deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "image-propagation",
+		Description: "avatar and header images reach other nodes and the gateway",
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			owner := vendors[0]
+			imgs, err := SetProfileImages(owner)
+			if err != nil {
+				return err
+			}
+			for _, n := range net.Nodes {
+				if n == owner {
+					continue
+				}
+				if err := AssertImagesPropagated(ctx, owner, n, imgs, gatewayURL, deadline); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func sameHashes(kind string, got, want *client.ImageHashes) error {
+	if got == nil {
+		return fmt.Errorf("profile has no %s", kind)
+	}
+	if *got != *want {
+		return fmt.Errorf("%s hashes %+v, expected %+v", kind, *got, *want)
+	}
+	return nil
+}
+
+// poll calls fn every second until it succeeds or ctx is done, returning the
+// last error in that case
+func poll(ctx context.Context, fn func() error) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package harness
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/test/chaos"
+)
+
+// Capabilities a server implementation may support. Scenarios list the ones
+// they need in Requires.
+const (
+	// CapListings is publishing, fetching and purchasing listings
+	CapListings = "listings"
+
+	// CapOrders is the direct order lifecycle: confirm, fulfill, refund,
+	// cancel and complete
+	CapOrders = "orders"
+
+	// CapDisputes is moderated orders, opening and closing disputes
+	CapDisputes = "disputes"
+
+	// CapChat is chat messages between peers
+	CapChat = "chat"
+
+	// CapNotifications is the notifications websocket and its polled
+	// counterpart
+	CapNotifications = "notifications"
+
+	// CapGateway is serving store content by path and CID on the gateway
+	CapGateway = "gateway"
+
+	// CapFeatures is toggling experimental features through the API.
+	// Scenarios with Features require it without listing it.
+	CapFeatures = "features"
+)
+
+// Implementation is a server implementation nodes can run, e.g. openbazaar-go
+// or another daemon speaking the same protocol, and the capabilities of it
+// the harness can exercise. Pointing the suite at nodes of different
+// implementations checks they interoperate.
+type Implementation struct {
+	Name         string
+	Capabilities []string
+}
+
+// Supports tells whether the implementation has the capability
+func (i Implementation) Supports(capability string) bool {
+	for _, c := range i.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenBazaarGo is the reference implementation, which has every capability.
+// Nodes that do not say otherwise run it.
+var OpenBazaarGo = Implementation{
+	Name: "openbazaar-go",
+	Capabilities: []string{
+		CapListings, CapOrders, CapDisputes, CapChat, CapNotifications, CapGateway, CapFeatures,
+	},
+}
+
+var (
+	implementations   = map[string]Implementation{OpenBazaarGo.Name: OpenBazaarGo}
+	implementationsMu sync.Mutex
+)
+
+// RegisterImplementation makes an implementation known by name, replacing
+// any registered before under the same name
+func RegisterImplementation(impl Implementation) {
+	implementationsMu.Lock()
+	defer implementationsMu.Unlock()
+	implementations[impl.Name] = impl
+}
+
+// LookupImplementation returns the implementation registered under name
+func LookupImplementation(name string) (Implementation, error) {
+	implementationsMu.Lock()
+	defer implementationsMu.Unlock()
+	impl, ok := implementations[name]
+	if !ok {
+		return Implementation{}, fmt.Errorf("unknown implementation %q", name)
+	}
+	return impl, nil
+}
+
+// Implementer is implemented by nodes that run something other than
+// openbazaar-go
+type Implementer interface {
+	Implementation() Implementation
+}
+
+// ImplementationOf returns the implementation the node runs
+func ImplementationOf(n Node) Implementation {
+	if i, ok := n.(Implementer); ok {
+		return i.Implementation()
+	}
+	return OpenBazaarGo
+}
+
+// Implementations returns the names of the implementations the network's
+// nodes run, sorted
+func (n *Network) Implementations() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, nd := range n.Nodes {
+		name := ImplementationOf(nd).Name
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unsupported returns why the network cannot run the scenario, empty when
+// every node has every capability it requires and can be stopped, restarted
+// or given a new identity if the scenario does so
+func (n *Network) unsupported(s Scenario) string {
+	required := s.Requires
+	if len(s.Features) > 0 {
+		required = append(append([]string(nil), required...), CapFeatures)
+	}
+	stops, restarts := s.Stops, s.Restarts
+	if events, err := chaos.Parse(s.Faults); err == nil {
+		for _, e := range events {
+			stops = stops || e.Action == chaos.Stop
+			restarts = restarts || e.Action == chaos.Restart
+		}
+	}
+	var missing []string
+	for _, nd := range n.Nodes {
+		impl := ImplementationOf(nd)
+		for _, c := range required {
+			if !impl.Supports(c) {
+				missing = append(missing, fmt.Sprintf("%s (%s) lacks %s", nd.Name(), impl.Name, c))
+			}
+		}
+		if _, ok := nd.(Restarter); restarts && !ok {
+			missing = append(missing, fmt.Sprintf("%s cannot be restarted", nd.Name()))
+		}
+		if _, ok := nd.(Stopper); stops && !ok {
+			missing = append(missing, fmt.Sprintf("%s cannot be stopped", nd.Name()))
+		}
+		if _, ok := nd.(IdentityRotator); s.RotatesIdentity && !ok {
+			missing = append(missing, fmt.Sprintf("%s cannot rotate its identity", nd.Name()))
+		}
+	}
+	return strings.Join(missing, ", ")
+}
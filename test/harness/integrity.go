@@ -0,0 +1,205 @@
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/test/cids"
+)
+
+// contentRefs maps every content hash referenced by the listings and orders
+// of any node to the names of the nodes referencing it. Listings and orders
+// are fetched on the verification workers.
+func (n *Network) contentRefs(ctx context.Context) (map[string]map[string]bool, error) {
+	// A document is a listing or order of a node and the hashes in it
+	type document struct {
+		nd     Node
+		what   string
+		fetch  func() ([]byte, error)
+		hashes []string
+	}
+	docs := make([][]*document, len(n.Nodes))
+	errs := n.verify(ctx, len(n.Nodes), func(i int) error {
+		nd := n.Nodes[i]
+		c := nd.Client()
+		listings, err := c.Listings("")
+		if err != nil {
+			return fmt.Errorf("listing index of %s: %s", nd.Name(), err)
+		}
+		for _, l := range listings {
+			slug := l.Slug
+			d := &document{nd: nd, what: "listing " + slug, fetch: func() ([]byte, error) { return c.Listing(nd.PeerID(), slug) }}
+			// The index hash names the signed listing itself
+			if _, _, err := cids.Parse(l.Hash); err == nil {
+				d.hashes = append(d.hashes, l.Hash)
+			}
+			docs[i] = append(docs[i], d)
+		}
+		orders, err := c.Orders()
+		if err != nil {
+			return fmt.Errorf("orders of %s: %s", nd.Name(), err)
+		}
+		for _, id := range orders {
+			id := id
+			docs[i] = append(docs[i], &document{nd: nd, what: "order " + id, fetch: func() ([]byte, error) { return c.Order(id) }})
+		}
+		return nil
+	})
+	if err := firstErr(ctx, errs); err != nil {
+		return nil, err
+	}
+	var all []*document
+	for _, ds := range docs {
+		all = append(all, ds...)
+	}
+	errs = n.verify(ctx, len(all), func(i int) error {
+		d := all[i]
+		body, err := d.fetch()
+		if err != nil {
+			return fmt.Errorf("%s of %s: %s", d.what, d.nd.Name(), err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return fmt.Errorf("%s of %s: %s", d.what, d.nd.Name(), err)
+		}
+		d.hashes = hashesIn(v, d.hashes)
+		return nil
+	})
+	if err := firstErr(ctx, errs); err != nil {
+		return nil, err
+	}
+	refs := make(map[string]map[string]bool)
+	for _, d := range all {
+		for _, h := range d.hashes {
+			if refs[h] == nil {
+				refs[h] = make(map[string]bool)
+			}
+			refs[h][d.nd.Name()] = true
+		}
+	}
+	return refs, nil
+}
+
+// hashesIn appends every string in the decoded JSON value v that parses as
+// a content hash
+func hashesIn(v interface{}, ret []string) []string {
+	switch v := v.(type) {
+	case string:
+		if codec, _, err := cids.Parse(v); err == nil && (codec == cids.Raw || codec == cids.DagProtobuf) {
+			ret = append(ret, v)
+		}
+	case []interface{}:
+		for _, e := range v {
+			ret = hashesIn(e, ret)
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			ret = hashesIn(e, ret)
+		}
+	}
+	return ret
+}
+
+// sweepContent fetches every content hash referenced now but not in before
+// through the gateway of a node that does not reference it, and checks that
+// the bytes served hash back to it. Content is fetched from a referencing
+// node only when every node references it. Hashes are fetched and checked
+// on the verification workers, and every one that fails is reported.
+func (n *Network) sweepContent(ctx context.Context, before map[string]map[string]bool) error {
+	after, err := n.contentRefs(ctx)
+	if err != nil {
+		return err
+	}
+	var hashes []string
+	for h := range after {
+		if before[h] == nil {
+			hashes = append(hashes, h)
+		}
+	}
+	sort.Strings(hashes)
+	errs := n.verify(ctx, len(hashes), func(i int) error {
+		h := hashes[i]
+		from := n.Nodes[0]
+		for _, nd := range n.Nodes {
+			if !after[h][nd.Name()] {
+				from = nd
+				break
+			}
+		}
+		body, err := from.Client().GetBytes("/ipfs/" + h)
+		if err != nil {
+			return fmt.Errorf("fetching %s from %s: %s", h, from.Name(), err)
+		}
+		if got := cids.ExpectFileCID(body); got != h {
+			return fmt.Errorf("%s served %d bytes for %s that hash to %s", from.Name(), len(body), h, got)
+		}
+		return nil
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// verify runs check on 0 to count-1 on at most Verifiers workers and returns
+// the error of each, nil for those not run because ctx was done
+func (n *Network) verify(ctx context.Context, count int, check func(i int) error) []error {
+	workers := n.Verifiers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > count {
+		workers = count
+	}
+	errs := make([]error, count)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = check(i)
+			}
+		}()
+	}
+feed:
+	for i := 0; i < count; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return errs
+}
+
+// firstErr returns ctx's error if it is done, else the first of errs
+func firstErr(ctx context.Context, errs []error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// LANDiscovery checks a network whose nodes were started with
+// nodes.WithLANOnly: with no bootstrap peers every node must find the others
+// by mDNS within discover, thirty seconds if zero, the buyer must fetch the
+// vendor's listings by peer ID and no node may be connected to a peer outside
+// the network.
+func LANDiscovery(discover time.Duration) Scenario {
+	if discover == 0 {
+		discover = 30 * time.Second
+	}
+	return Scenario{
+		Name:        "lan-discovery",
+		Description: "nodes of a local network marketplace find each other by mDNS and nothing else",
+		Requires:    []string{CapListings},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			members := make(map[string]bool)
+			for _, nd := range net.Nodes {
+				members[nd.PeerID()] = true
+			}
+
+			err = net.Step("lan/discover", func() error {
+				wait, cancel := context.WithTimeout(ctx, discover)
+				defer cancel()
+				return poll(wait, func() error {
+					var alone []string
+					for _, nd := range net.Nodes {
+						for _, other := range net.Nodes {
+							if nd == other {
+								continue
+							}
+							if connected, err := nd.Client().ConnectedTo(other.PeerID()); err != nil || !connected {
+								alone = append(alone, nd.Name()+" to "+other.Name())
+							}
+						}
+					}
+					if len(alone) > 0 {
+						return fmt.Errorf("not discovered by mDNS within %s: %s", discover, strings.Join(alone, ", "))
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			err = net.Step("lan/listings", func() error {
+				if _, err := vendor.Client().CreateListing(fixtures.Listing()); err != nil {
+					return err
+				}
+				wait, cancel := context.WithTimeout(ctx, discover)
+				defer cancel()
+				return poll(wait, func() error {
+					listings, err := buyer.Client().Listings(vendor.PeerID())
+					if err != nil {
+						return err
+					}
+					if len(listings) == 0 {
+						return fmt.Errorf("%s sees no listings of %s", buyer.Name(), vendor.Name())
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, nd := range net.Nodes {
+				peers, err := nd.Client().Peers()
+				if err != nil {
+					return fmt.Errorf("%s API: %s", nd.Name(), err)
+				}
+				for _, addr := range peers {
+					i := strings.LastIndex(addr, "/ipfs/")
+					if i < 0 || !members[addr[i+len("/ipfs/"):]] {
+						return fmt.Errorf("%s of a LAN-only network is connected to %s", nd.Name(), addr)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
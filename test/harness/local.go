@@ -0,0 +1,113 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/identity"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// LocalNode is a node process started by the harness on a repo it owns. It
+// can be stopped and restarted with the same repo and identity, and paused.
+type LocalNode struct {
+	name string
+	role string
+	p    *nodes.Process
+}
+
+// NewLocalNode names a process started with nodes.Start or a Manager
+func NewLocalNode(name, role string, p *nodes.Process) *LocalNode {
+	return &LocalNode{name: name, role: role, p: p}
+}
+
+func (n *LocalNode) Name() string           { return n.name }
+func (n *LocalNode) Role() string           { return n.role }
+func (n *LocalNode) PeerID() string         { return n.p.PeerID }
+func (n *LocalNode) Client() *client.Client { return n.p.Client }
+
+// Process returns the process running the node
+func (n *LocalNode) Process() *nodes.Process { return n.p }
+
+// SwarmAddrs are the full addresses other nodes dial the node at
+func (n *LocalNode) SwarmAddrs() []string { return n.p.SwarmAddrs }
+
+// NAT is what the node process sits behind
+func (n *LocalNode) NAT() nodes.NAT { return n.p.NAT }
+
+// Tor is how the node process uses Tor
+func (n *LocalNode) Tor() nodes.Tor { return n.p.Tor }
+
+// Swarm is the private swarm the node process is in
+func (n *LocalNode) Swarm() string { return n.p.Swarm }
+
+// Log is the file the output of the node process goes to
+func (n *LocalNode) Log() string { return n.p.Log() }
+
+// Stop shuts the node down, keeping its repo
+func (n *LocalNode) Stop(ctx context.Context) error {
+	return n.p.Stop()
+}
+
+// Restart starts the node again on its repo, stopping it first if it runs
+func (n *LocalNode) Restart(ctx context.Context) error {
+	return n.p.Restart(ctx)
+}
+
+// RotateIdentity stops the node, gives its repo a fresh identity key and
+// starts it again under the new peer ID
+func (n *LocalNode) RotateIdentity(ctx context.Context) error {
+	if err := n.p.Stop(); err != nil {
+		return err
+	}
+	// Repos initialized for testnet keep their datastore apart
+	_, err := os.Stat(filepath.Join(n.p.RepoDir, "datastore", "testnet.db"))
+	peerID, err := identity.Rotate(n.p.RepoDir, "", err == nil)
+	if err != nil {
+		return fmt.Errorf("%s: rotating identity: %s", n.name, err)
+	}
+	if err := n.p.SetPeerID(peerID); err != nil {
+		return err
+	}
+	return n.p.Restart(ctx)
+}
+
+// Pause suspends the node process
+func (n *LocalNode) Pause(ctx context.Context) error {
+	return n.p.Pause()
+}
+
+// Resume continues the suspended node process
+func (n *LocalNode) Resume(ctx context.Context) error {
+	return n.p.Resume()
+}
+
+// SetConfig changes the node's config and restarts it on the change
+func (n *LocalNode) SetConfig(ctx context.Context, path string, value interface{}) error {
+	return n.p.SetConfig(ctx, path, value)
+}
+
+// DelayTo delays the packets the node sends peer, a LocalNode under the same
+// runner, see nodes.Process.DelayTo
+func (n *LocalNode) DelayTo(ctx context.Context, peer Node, d time.Duration) error {
+	p, ok := peer.(*LocalNode)
+	if !ok {
+		return fmt.Errorf("%s cannot delay its traffic to %s", n.name, peer.Name())
+	}
+	return n.p.DelayTo(ctx, p.p, d)
+}
+
+// ImpairTo impairs the packets the node sends peer, a LocalNode under the
+// same runner, see nodes.Process.ImpairTo
+func (n *LocalNode) ImpairTo(ctx context.Context, peer Node, i netem.Impairment) error {
+	p, ok := peer.(*LocalNode)
+	if !ok {
+		return fmt.Errorf("%s cannot impair its traffic to %s", n.name, peer.Name())
+	}
+	return n.p.ImpairTo(ctx, p.p, i)
+}
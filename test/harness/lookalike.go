@@ -0,0 +1,159 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// lookalikeName is the profile name both vendors of the lookalike scenario
+// go by
+const lookalikeName = "Tee Emporium"
+
+// LookalikeOptions configures the lookalike scenario
+type LookalikeOptions struct {
+	// Handles are the handles the impostor takes in turn,
+	// fixtures.LookalikeHandles of fixtures.Handle if empty
+	Handles []fixtures.Lookalike
+
+	// Settle bounds how long listings and orders may take to reach the
+	// other side, two minutes if zero
+	Settle time.Duration
+}
+
+// Lookalike has the second vendor pass for the first: the same profile
+// name, a handle reading like the first's and the same listing. The buyer
+// purchases from the genuine vendor and from the impostor under each
+// lookalike handle. Every order contract must bind to the peer ID of the
+// vendor whose listing was bought and carry that vendor's handle byte for
+// byte, and the order must reach that vendor and not the other, whatever
+// the handles look like. Peer IDs reading like the genuine vendor's must
+// never resolve to its profile.
+func Lookalike(opts LookalikeOptions) Scenario {
+	if len(opts.Handles) == 0 {
+		opts.Handles = fixtures.LookalikeHandles(fixtures.Handle)
+	}
+	if opts.Settle == 0 {
+		opts.Settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:          "lookalike-vendor",
+		Description:   "orders bind to the peer ID of the vendor bought from, not to a handle reading like it",
+		Requires:      []string{CapListings, CapOrders},
+		Notifications: []string{"order"},
+		Run: func(ctx context.Context, net *Network) error {
+			vendors, buyers := net.Role("vendor"), net.Role("buyer")
+			if len(vendors) < 2 || len(buyers) == 0 {
+				return fmt.Errorf("scenario needs two vendors and a buyer")
+			}
+			genuine, impostor, buyer := vendors[0], vendors[1], buyers[0]
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+
+			err := net.Step("lookalike/genuine", func() error {
+				return lookalikeOrder(wait, net, buyer, genuine, impostor, fixtures.Handle)
+			})
+			if err != nil {
+				return err
+			}
+			for _, h := range opts.Handles {
+				h := h
+				err := net.Step("lookalike/handle/"+strings.Replace(h.Kind, " ", "-", -1), func() error {
+					return lookalikeOrder(wait, net, buyer, impostor, genuine, h.Value)
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			// Nothing answers for the lookalike IDs, so fetching them fails
+			// one way or another
+			return net.Expect("lookalike/peer-ids", func() error {
+				for _, id := range fixtures.LookalikePeerIDs(genuine.PeerID()) {
+					resp, err := buyer.Client().Get("/ob/profile/" + id.Value + "?usecache=false")
+					if err != nil || resp.StatusCode != http.StatusOK {
+						continue
+					}
+					var profile struct {
+						PeerID string `json:"peerID"`
+					}
+					if err := resp.Decode(&profile); err != nil {
+						continue
+					}
+					if profile.PeerID == genuine.PeerID() {
+						return fmt.Errorf("%s resolved %s lookalike %s to the profile of %s", buyer.Name(), id.Kind, id.Value, genuine.Name())
+					}
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// lookalikeOrder has vendor take handle and publish the fixture listing,
+// and buyer purchase it once it sees it. The contract must name vendor
+// under handle and the order reach vendor and not other.
+func lookalikeOrder(ctx context.Context, net *Network, buyer, vendor, other Node, handle string) error {
+	if err := setProfile(vendor, map[string]interface{}{"name": lookalikeName, "handle": handle}); err != nil {
+		return err
+	}
+	// Listings carry the handle their vendor had when signing them
+	slug, err := vendor.Client().CreateListing(fixtures.Listing())
+	if err != nil {
+		return fmt.Errorf("creating listing on %s: %s", vendor.Name(), err)
+	}
+	var hash string
+	err = net.Expect("lookalike/listing", func() error {
+		return poll(ctx, func() error {
+			var err error
+			hash, err = listingHashOn(buyer, vendor, slug)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+	if err != nil {
+		return fmt.Errorf("purchase by %s from %s: %s", buyer.Name(), vendor.Name(), err)
+	}
+
+	var order struct {
+		Contract struct {
+			VendorListings []struct {
+				VendorID struct {
+					PeerID       string `json:"peerID"`
+					BlockchainID string `json:"blockchainID"`
+				} `json:"vendorID"`
+			} `json:"vendorListings"`
+		} `json:"contract"`
+	}
+	if err := buyer.Client().GetJSON("/ob/order/"+resp.OrderID, &order); err != nil {
+		return err
+	}
+	if len(order.Contract.VendorListings) == 0 {
+		return fmt.Errorf("order %s of %s has no listings", resp.OrderID, buyer.Name())
+	}
+	for _, l := range order.Contract.VendorListings {
+		if l.VendorID.PeerID != vendor.PeerID() {
+			return fmt.Errorf("order %s bought from %s binds to %s", resp.OrderID, vendor.Name(), l.VendorID.PeerID)
+		}
+		if l.VendorID.BlockchainID != handle {
+			return fmt.Errorf("order %s bought from %s names it %q, expected %q", resp.OrderID, vendor.Name(), l.VendorID.BlockchainID, handle)
+		}
+	}
+
+	if err := WaitState(ctx, resp.OrderID, "AWAITING_PAYMENT", vendor); err != nil {
+		return err
+	}
+	return net.Expect("lookalike/other", func() error {
+		if _, err := other.Client().Order(resp.OrderID); err == nil {
+			return fmt.Errorf("order %s bought from %s reached %s", resp.OrderID, vendor.Name(), other.Name())
+		}
+		return nil
+	})
+}
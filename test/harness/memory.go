@@ -0,0 +1,133 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MB is a megabyte, for memory ceilings
+const MB = 1 << 20
+
+// MemorySampleInterval is how often the resident memory of nodes is sampled
+// while a scenario runs
+var MemorySampleInterval = 5 * time.Second
+
+type memoryCeiling struct {
+	node  Node
+	limit uint64
+}
+
+// WithMemoryCeiling fails any scenario during which the node's resident
+// memory exceeds limit bytes, e.g. WithMemoryCeiling(vendor, 512*MB). A heap
+// profile is captured when the ceiling is hit, which requires the node to be
+// started with --profile. The node must implement MemoryReporter.
+func (n *Network) WithMemoryCeiling(node Node, limit uint64) *Network {
+	n.ceilings = append(n.ceilings, memoryCeiling{node: node, limit: limit})
+	return n
+}
+
+// watchMemory runs fn while sampling the memory of every node that reports
+// it. The samples go to the metrics recorder and fn's context is canceled as
+// soon as a node exceeds its ceiling.
+func (n *Network) watchMemory(ctx context.Context, fn func(ctx context.Context) error) error {
+	var reporters []Node
+	for _, nd := range n.Nodes {
+		if _, ok := nd.(MemoryReporter); ok {
+			reporters = append(reporters, nd)
+		}
+	}
+	for _, c := range n.ceilings {
+		if _, ok := c.node.(MemoryReporter); !ok {
+			return fmt.Errorf("memory ceiling set on %s, which does not report memory", c.node.Name())
+		}
+	}
+	if len(reporters) == 0 || (n.Metrics == nil && len(n.ceilings) == 0) {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		exceeded error
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(MemorySampleInterval)
+		defer ticker.Stop()
+		for {
+			for _, nd := range reporters {
+				rss, err := nd.(MemoryReporter).MemoryUsage()
+				if err != nil {
+					continue
+				}
+				n.Metrics.Record("node_rss_bytes", float64(rss), map[string]string{"node": nd.Name()})
+				if err := n.checkCeiling(nd, rss); err != nil {
+					once.Do(func() {
+						exceeded = err
+						cancel()
+					})
+				}
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	err := fn(ctx)
+	close(done)
+	wg.Wait()
+	if exceeded != nil {
+		return exceeded
+	}
+	return err
+}
+
+// checkCeiling returns an error naming the captured heap profile when rss
+// exceeds a ceiling set on the node
+func (n *Network) checkCeiling(nd Node, rss uint64) error {
+	for _, c := range n.ceilings {
+		if c.node != nd || rss <= c.limit {
+			continue
+		}
+		msg := fmt.Sprintf("%s uses %d MB, ceiling is %d MB", nd.Name(), rss/MB, c.limit/MB)
+		profile, err := n.heapProfile(nd)
+		if err != nil {
+			return fmt.Errorf("%s, heap profile not captured: %s", msg, err)
+		}
+		return fmt.Errorf("%s, heap profile written to %s", msg, profile)
+	}
+	return nil
+}
+
+// heapProfile downloads a heap profile from the node's pprof endpoint
+func (n *Network) heapProfile(nd Node) (string, error) {
+	b, err := nd.Client().GetBytes("/debug/pprof/heap")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%d.heap", nd.Name(), time.Now().Unix())
+	if n.Artifacts != nil {
+		return n.Artifacts.Put(context.Background(), "profiles/"+name, bytes.NewReader(b))
+	}
+	dir := n.ProfileDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	name = filepath.Join(dir, name)
+	if err := ioutil.WriteFile(name, b, 0644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
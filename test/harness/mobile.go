@@ -0,0 +1,136 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// MobileOptions configures how buyers behave like phone apps
+type MobileOptions struct {
+	Profile client.MobileProfile
+
+	// Foreground and Background are how long the app stays in the
+	// foreground and then in the background, over and over, 20s and 5s
+	// when zero. A negative Background never pauses the app.
+	Foreground time.Duration
+	Background time.Duration
+}
+
+// Mobile runs s with every buyer's client switched to the mobile profile
+// while it goes to the background and comes back on a fixed cycle. Requests
+// cut off by a pause are sent again once the app is back, so any failure is
+// one the node caused, e.g. a purchase that was created twice or never.
+func Mobile(s Scenario, opts MobileOptions) Scenario {
+	if opts.Foreground == 0 {
+		opts.Foreground = 20 * time.Second
+	}
+	if opts.Background == 0 {
+		opts.Background = 5 * time.Second
+	}
+	run := s.Run
+	s.Name = "mobile/" + s.Name
+	s.Description += ", with the buyer on a mobile client"
+	s.Run = func(ctx context.Context, net *Network) error {
+		buyers := net.Role("buyer")
+		if len(buyers) == 0 {
+			return fmt.Errorf("scenario needs a buyer")
+		}
+		var lifecycles []*client.Lifecycle
+		for _, b := range buyers {
+			c := b.Client()
+			saved := *c
+			defer func() { *c = saved }()
+			lifecycles = append(lifecycles, c.WithMobileProfile(opts.Profile).Lifecycle)
+		}
+		if opts.Background > 0 {
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				cycle(lifecycles, opts.Foreground, opts.Background, stop)
+			}()
+			defer func() {
+				close(stop)
+				<-done
+			}()
+		}
+		return run(ctx, net)
+	}
+	return s
+}
+
+// cycle pauses and resumes the lifecycles until stop is closed, leaving them
+// in the foreground
+func cycle(lifecycles []*client.Lifecycle, foreground, background time.Duration, stop chan struct{}) {
+	defer func() {
+		for _, l := range lifecycles {
+			l.Resume()
+		}
+	}()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(foreground):
+		}
+		for _, l := range lifecycles {
+			l.Pause()
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(background):
+		}
+		for _, l := range lifecycles {
+			l.Resume()
+		}
+	}
+}
+
+// DirectPurchase walks a direct order from checkout to COMPLETED and checks
+// the buyer has exactly one purchase for it
+func DirectPurchase(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "direct-purchase",
+		Description: "a direct order goes from checkout to completed",
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			order, err := CompleteOrder(ctx, vendor, buyer, settle)
+			if err != nil {
+				return err
+			}
+			orders, err := buyer.Client().Orders()
+			if err != nil {
+				return err
+			}
+			seen := 0
+			for _, id := range orders {
+				if id == order.ID {
+					seen++
+				}
+			}
+			if seen != 1 {
+				return fmt.Errorf("order %s listed %d times on %s", order.ID, seen, buyer.Name())
+			}
+			return nil
+		},
+	}
+}
+
+// MobilePurchases are the purchase scenarios run with a mobile buyer
+func MobilePurchases(opts MobileOptions, settle time.Duration) []Scenario {
+	return []Scenario{
+		Mobile(DirectPurchase(settle), opts),
+		Mobile(OfflineBuyer(0, settle), opts),
+		Mobile(ConcurrentDisputes(0, settle), opts),
+	}
+}
@@ -0,0 +1,131 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/model"
+)
+
+// ModelCheck runs every action sequence the model allows, each on a fresh
+// direct order between the first vendor and buyer. After placing the order
+// and after each step, both nodes must report the state the model predicts
+// within settle, and every action the model forbids in that state must be
+// refused without changing it. All sequences run; the scenario fails with
+// every sequence where the nodes and the model disagree.
+func ModelCheck(m model.Model, depth int, settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "order-model",
+		Description: "orders follow the spec's state machine for every allowed sequence of actions",
+		Requires:    []string{CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			seqs, err := m.Sequences(depth)
+			if err != nil {
+				return err
+			}
+			var disagreements []string
+			for _, seq := range seqs {
+				r := &modelRun{model: m, vendor: vendor, buyer: buyer, settle: settle}
+				err := net.Step("order-model/"+seq.String(), func() error {
+					return r.run(ctx, seq)
+				})
+				if err != nil {
+					disagreements = append(disagreements, fmt.Sprintf("%s: %s", seq, err))
+				}
+			}
+			if len(disagreements) > 0 {
+				return fmt.Errorf("%d of %d sequences disagree with the model:\n%s", len(disagreements), len(seqs), strings.Join(disagreements, "\n"))
+			}
+			return nil
+		},
+	}
+}
+
+// modelRun walks one sequence on its own order
+type modelRun struct {
+	model  model.Model
+	vendor Node
+	buyer  Node
+	settle time.Duration
+	order  *Order
+}
+
+func (r *modelRun) run(ctx context.Context, seq model.Sequence) error {
+	var err error
+	if r.order, err = PlaceOrder(r.vendor, r.buyer, nil); err != nil {
+		return err
+	}
+	if err := r.check(ctx, "placing the order", r.model.Initial); err != nil {
+		return err
+	}
+	for _, t := range seq {
+		if err := r.do(t.Action); err != nil {
+			return fmt.Errorf("%s refused %s in %s: %s", t.Actor, t.Action, t.From, err)
+		}
+		if err := r.check(ctx, t.Action, t.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check waits for both nodes to reach state after the action, then tries
+// every action the model forbids there
+func (r *modelRun) check(ctx context.Context, after, state string) error {
+	wait, cancel := context.WithTimeout(ctx, r.settle)
+	defer cancel()
+	if err := WaitState(wait, r.order.ID, state, r.buyer, r.vendor); err != nil {
+		return fmt.Errorf("after %s: %s", after, err)
+	}
+	for _, action := range r.model.Forbidden(state) {
+		// Paying is a wallet spend, nothing the order can refuse
+		if action == "pay" {
+			continue
+		}
+		if err := r.do(action); err == nil {
+			return fmt.Errorf("%s accepted in %s", action, state)
+		}
+		for _, n := range []Node{r.buyer, r.vendor} {
+			got, err := n.Client().OrderState(r.order.ID)
+			if err != nil {
+				return err
+			}
+			if got != state {
+				return fmt.Errorf("refused %s moved %s from %s to %s", action, n.Name(), state, got)
+			}
+		}
+	}
+	return nil
+}
+
+// do performs the action as the party the spec gives it to
+func (r *modelRun) do(action string) error {
+	id, slug := r.order.ID, r.order.Slug
+	switch action {
+	case "pay":
+		return PayOrder(r.buyer, r.order)
+	case "confirm":
+		return r.vendor.Client().ConfirmOrder(id, false)
+	case "reject":
+		return r.vendor.Client().ConfirmOrder(id, true)
+	case "cancel":
+		return r.buyer.Client().CancelOrder(id)
+	case "fulfill":
+		return r.vendor.Client().FulfillOrder(fixtures.Fulfillment(id, slug))
+	case "refund":
+		return r.vendor.Client().RefundOrder(id)
+	case "complete":
+		return r.buyer.Client().CompleteOrder(fixtures.Completion(id, slug))
+	}
+	return fmt.Errorf("no way to %s an order", action)
+}
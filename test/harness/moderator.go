@@ -0,0 +1,128 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// escrowTimeoutBlocks is how many blocks the escrow of a fixture order stays
+// locked. Outside mainnet the node defaults listings to a one hour timeout
+// and counts six blocks an hour.
+const escrowTimeoutBlocks = 6
+
+// ModeratorFallbackOptions configures the moderator unavailability scenario
+type ModeratorFallbackOptions struct {
+	// Retired has the moderator withdraw its moderator settings instead of
+	// going offline
+	Retired bool
+
+	// Mine mines blocks on the chain the wallets of the nodes follow, e.g.
+	// regtest.Bitcoind.Generate. It is required, the escrow timeout can
+	// only run out on chain.
+	Mine func(blocks int) error
+
+	// Settle bounds how long orders and wallets may take to catch up
+	Settle time.Duration
+}
+
+// ModeratorUnavailable has the moderator of a fulfilled order go offline, or
+// stop moderating, while the buyer never completes. Without a moderator to
+// sign a payout the vendor's only way to the funds is the escrow timeout: a
+// release before it runs out must be refused, one after it must sweep the
+// escrow into the vendor's wallet.
+func ModeratorUnavailable(opts ModeratorFallbackOptions) Scenario {
+	if opts.Settle == 0 {
+		opts.Settle = 5 * time.Minute
+	}
+	return Scenario{
+		Name:        "moderator-unavailable",
+		Description: "the vendor falls back to the escrow timeout when the moderator is gone",
+		Stops:       !opts.Retired,
+		Requires:    []string{CapOrders, CapDisputes},
+		Run: func(ctx context.Context, net *Network) error {
+			if opts.Mine == nil {
+				return fmt.Errorf("scenario needs a way to mine blocks")
+			}
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			moderators := net.Role("moderator")
+			if len(moderators) == 0 {
+				return fmt.Errorf("scenario needs a moderator")
+			}
+			moderator := moderators[0]
+
+			order, err := PlaceOrder(vendor, buyer, moderator)
+			if err != nil {
+				return err
+			}
+			if err := PayOrder(buyer, order); err != nil {
+				return err
+			}
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+			if err := WaitState(wait, order.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+				return err
+			}
+			if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, order.Slug)); err != nil {
+				return err
+			}
+			if err := WaitState(wait, order.ID, "FULFILLED", buyer, vendor); err != nil {
+				return err
+			}
+
+			if opts.Retired {
+				if err := moderator.Client().StopModerating(); err != nil {
+					return fmt.Errorf("retiring %s: %s", moderator.Name(), err)
+				}
+			} else {
+				s, ok := moderator.(Stopper)
+				if !ok {
+					return fmt.Errorf("node %s cannot be stopped", moderator.Name())
+				}
+				if err := s.Stop(ctx); err != nil {
+					return err
+				}
+			}
+
+			release := map[string]string{"orderId": order.ID}
+			resp, err := vendor.Client().Post("/ob/releaseescrow", release)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusUnauthorized {
+				return fmt.Errorf("releasing escrow before the timeout returned %d, expected %d: %s", resp.StatusCode, http.StatusUnauthorized, resp.Body)
+			}
+
+			before, err := vendor.Client().Balance()
+			if err != nil {
+				return err
+			}
+			if err := opts.Mine(escrowTimeoutBlocks); err != nil {
+				return fmt.Errorf("mining past the escrow timeout: %s", err)
+			}
+			// The vendor's wallet needs to see the new blocks before the
+			// timelock counts as expired
+			if err := poll(wait, func() error {
+				return vendor.Client().ReleaseEscrow(order.ID)
+			}); err != nil {
+				return fmt.Errorf("releasing escrow after %d blocks: %s", escrowTimeoutBlocks, err)
+			}
+			return poll(wait, func() error {
+				after, err := vendor.Client().Balance()
+				if err != nil {
+					return err
+				}
+				if after.Total() <= before.Total() {
+					return fmt.Errorf("wallet of %s still holds %d after the escrow release, had %d before", vendor.Name(), after.Total(), before.Total())
+				}
+				return nil
+			})
+		},
+	}
+}
@@ -0,0 +1,113 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// NATFallback checks that a vendor behind a NAT, see nodes.WithNAT, stays
+// reachable for the buyer the way the node can manage it. The buyer must
+// never hold a connection it dialed to the vendor's own addresses, since
+// the NAT lets none in, while the vendor's own dials to the buyer must get
+// out. With every connection between them closed, a chat message from the
+// buyer must still reach the vendor within deliver, two minutes if zero,
+// through a connection the vendor opens or as an offline message; the
+// libp2p the node runs has no circuit relay to fall back to.
+//
+// The NATed vendor cannot be the bootstrap peer of the others, so it has to
+// be started after a node they can reach.
+func NATFallback(deliver time.Duration) Scenario {
+	if deliver == 0 {
+		deliver = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "nat-fallback",
+		Description: "a vendor behind a NAT cannot be dialed but still gets the buyer's messages",
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			t, ok := vendor.(Translator)
+			if !ok || t.NAT() == nodes.NoNAT {
+				return fmt.Errorf("scenario needs a vendor behind a NAT")
+			}
+			a, ok := vendor.(Addresser)
+			if !ok || len(a.SwarmAddrs()) == 0 {
+				return fmt.Errorf("scenario needs the swarm address of %s", vendor.Name())
+			}
+			// direct returns the address the buyer reached the vendor on
+			// by dialing through its NAT, if it did
+			direct := func() string {
+				peers, err := buyer.Client().Peers()
+				if err != nil {
+					return ""
+				}
+				for _, addr := range peers {
+					for _, own := range a.SwarmAddrs() {
+						if addr == own || strings.HasPrefix(own, addr+"/") {
+							return addr
+						}
+					}
+				}
+				return ""
+			}
+
+			err = net.Step("nat/inbound", func() error {
+				disconnect(vendor, buyer)
+				disconnect(buyer, vendor)
+				connect(buyer, vendor)
+				if addr := direct(); addr != "" {
+					return fmt.Errorf("%s dialed %s at %s through its %s NAT", buyer.Name(), vendor.Name(), addr, t.NAT())
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			err = net.Step("nat/outbound", func() error {
+				wait, cancel := context.WithTimeout(ctx, time.Minute)
+				defer cancel()
+				return poll(wait, func() error {
+					connect(vendor, buyer)
+					if connected, err := buyer.Client().ConnectedTo(vendor.PeerID()); err != nil || !connected {
+						return fmt.Errorf("%s behind a %s NAT could not connect to %s", vendor.Name(), t.NAT(), buyer.Name())
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			return net.Step("nat/delivery", func() error {
+				disconnect(vendor, buyer)
+				disconnect(buyer, vendor)
+				id, err := buyer.Client().SendChat(vendor.PeerID(), "", fmt.Sprintf("sent through a %s NAT at %s", t.NAT(), time.Now().Format(time.RFC3339Nano)))
+				if err != nil {
+					return fmt.Errorf("%s sending a message to %s: %s", buyer.Name(), vendor.Name(), err)
+				}
+				wait, cancel := context.WithTimeout(ctx, deliver)
+				defer cancel()
+				err = poll(wait, func() error {
+					if !isStored(vendor, buyer.PeerID(), id) {
+						return fmt.Errorf("message %s from %s never reached %s behind a %s NAT", id, buyer.Name(), vendor.Name(), t.NAT())
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				if addr := direct(); addr != "" {
+					return fmt.Errorf("%s dialed %s at %s through its %s NAT to deliver", buyer.Name(), vendor.Name(), addr, t.NAT())
+				}
+				return nil
+			})
+		},
+	}
+}
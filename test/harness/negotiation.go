@@ -0,0 +1,94 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// negotiations are the header combinations sent to every endpoint, from
+// what browsers and mobile HTTP stacks send to values no server supports
+var negotiations = []client.Negotiation{
+	{AcceptEncoding: "gzip"},
+	{AcceptEncoding: "gzip, deflate, br"},
+	{AcceptEncoding: "gzip;q=0, identity"},
+	{AcceptEncoding: "br"},
+	{AcceptEncoding: "*"},
+	{Accept: "application/json"},
+	{Accept: "*/*"},
+	{Accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", AcceptEncoding: "gzip, deflate"},
+	{Accept: "application/xml"},
+	{Accept: "image/webp"},
+}
+
+// defaultNegotiationPaths are read only endpoints whose body does not change
+// between two requests
+var defaultNegotiationPaths = []string{"/ob/config", "/ob/listings", "/ob/followers", "/ob/following"}
+
+// ContentNegotiation requests every path from every node with each set of
+// Accept and Accept-Encoding headers. A response may only be encoded with a
+// coding the request accepted and must then carry Vary: Accept-Encoding and
+// decode cleanly. Once decoded it must match the plain response, unless the
+// node refused the Accept value with 406.
+func ContentNegotiation(paths ...string) Scenario {
+	if len(paths) == 0 {
+		paths = defaultNegotiationPaths
+	}
+	return Scenario{
+		Name:        "content-negotiation",
+		Description: "compressed and unusual Accept requests get correctly negotiated responses",
+		Run: func(ctx context.Context, net *Network) error {
+			for _, n := range net.Nodes {
+				for _, p := range paths {
+					if err := negotiate(n, p); err != nil {
+						return fmt.Errorf("%s GET %s: %s", n.Name(), p, err)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func negotiate(n Node, path string) error {
+	plain, err := n.Client().GetNegotiated(path, client.Negotiation{})
+	if err != nil {
+		return err
+	}
+	if err := plain.Check(client.Negotiation{}); err != nil {
+		return err
+	}
+	for _, req := range negotiations {
+		resp, err := n.Client().GetNegotiated(path, req)
+		if err != nil {
+			return fmt.Errorf("%+v: %s", req, err)
+		}
+		if err := resp.Check(req); err != nil {
+			return fmt.Errorf("%+v: %s", req, err)
+		}
+		if resp.StatusCode == http.StatusNotAcceptable {
+			continue
+		}
+		if resp.StatusCode != plain.StatusCode {
+			return fmt.Errorf("%+v: returned %d, %d without negotiation", req, resp.StatusCode, plain.StatusCode)
+		}
+		if !sameJSON(resp.Body, plain.Body) {
+			return fmt.Errorf("%+v: decoded body differs from the plain response", req)
+		}
+	}
+	return nil
+}
+
+// sameJSON compares two bodies as JSON, or byte for byte if either is not
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,134 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// NotificationPersistence fills the vendor's notification list through real
+// traffic, marks one notification read and restarts the vendor. Afterwards
+// the list, the read flags and the unread count must be unchanged, and a
+// fresh websocket subscription must receive new notifications exactly once.
+func NotificationPersistence(deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = time.Minute
+	}
+	return Scenario{
+		Name:        "notification-persistence",
+		Description: "unread notifications survive a restart and the websocket resubscribes",
+		Restarts:    true,
+		Requires:    []string{CapNotifications},
+		Version:     1,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			restarter, ok := vendor.(Restarter)
+			if !ok {
+				return fmt.Errorf("node %s cannot be restarted", vendor.Name())
+			}
+			ws, err := vendor.Client().Subscribe()
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+
+			if err := buyer.Client().Follow(vendor.PeerID()); err != nil {
+				return err
+			}
+			if _, err := waitNotification(ws, "follow", deadline); err != nil {
+				return err
+			}
+			if _, err := PlaceOrder(vendor, buyer, nil); err != nil {
+				return err
+			}
+			if _, err := waitNotification(ws, "order", deadline); err != nil {
+				return err
+			}
+
+			before, unread, err := vendor.Client().Notifications()
+			if err != nil {
+				return err
+			}
+			if len(before) < 2 {
+				return fmt.Errorf("expected at least 2 stored notifications, found %d", len(before))
+			}
+			if err := vendor.Client().MarkNotificationRead(before[len(before)-1].ID); err != nil {
+				return err
+			}
+			before[len(before)-1].Read = true
+			unread--
+
+			if err := restarter.Restart(ctx); err != nil {
+				return err
+			}
+			if _, err := ws.Next(deadline); err == nil {
+				return fmt.Errorf("websocket opened before the restart is still delivering")
+			}
+
+			after, unreadAfter, err := vendor.Client().Notifications()
+			if err != nil {
+				return err
+			}
+			if unreadAfter != unread {
+				return fmt.Errorf("unread count is %d after restart, expected %d", unreadAfter, unread)
+			}
+			if err := sameNotifications(before, after); err != nil {
+				return err
+			}
+
+			ws, err = vendor.Client().Subscribe()
+			if err != nil {
+				return fmt.Errorf("resubscribing: %s", err)
+			}
+			defer ws.Close()
+			if err := buyer.Client().Unfollow(vendor.PeerID()); err != nil {
+				return err
+			}
+			n, err := waitNotification(ws, "unfollow", deadline)
+			if err != nil {
+				return fmt.Errorf("after resubscribing: %s", err)
+			}
+			if dup, err := ws.NextNotification(5 * time.Second); err == nil && dup.ID == n.ID {
+				return fmt.Errorf("notification %s delivered twice", n.ID)
+			}
+			return nil
+		},
+	}
+}
+
+func waitNotification(ws *client.Socket, typ string, timeout time.Duration) (*client.Notification, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		n, err := ws.NextNotification(time.Until(deadline))
+		if err != nil {
+			return nil, fmt.Errorf("no %s notification on the websocket: %s", typ, err)
+		}
+		if n.Type == typ {
+			return n, nil
+		}
+	}
+}
+
+// sameNotifications checks that every notification from before is still
+// stored with the same read flag
+func sameNotifications(before, after []client.Notification) error {
+	stored := make(map[string]client.Notification)
+	for _, n := range after {
+		stored[n.ID] = n
+	}
+	for _, n := range before {
+		s, ok := stored[n.ID]
+		if !ok {
+			return fmt.Errorf("%s notification %s lost on restart", n.Type, n.ID)
+		}
+		if s.Read != n.Read {
+			return fmt.Errorf("%s notification %s has read=%t after restart, expected %t", n.Type, n.ID, s.Read, n.Read)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// paramOffline is the sweep parameter overriding how long the buyer stays
+// offline, e.g. offline=1m,10m,1h
+const paramOffline = "offline"
+
+// OfflineBuyer has the buyer go offline right after paying. While it is gone
+// the vendor fulfills, so the fulfillment can only reach the buyer through
+// the stored offline messages. Once the buyer is back it must rebuild the
+// order from them: the order moves to FULFILLED, exactly one fulfillment
+// notification prompts the buyer to complete, and completing succeeds on
+// both sides.
+//
+// The node has no clock that can be moved forward, so the buyer is really
+// down for the whole offline period. Keep it short for regular runs and
+// sweep it with -P offline=... for long absences.
+func OfflineBuyer(offline, settle time.Duration) Scenario {
+	if offline == 0 {
+		offline = 5 * time.Minute
+	}
+	if settle == 0 {
+		settle = 5 * time.Minute
+	}
+	return Scenario{
+		Name:        "offline-buyer",
+		Description: "a buyer offline since paying catches up on fulfillment when it returns",
+		Restarts:    true,
+		Stops:       true,
+		Params:      []string{paramOffline},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			if v, ok := Param(ctx, paramOffline); ok {
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return fmt.Errorf("bad %s parameter: %s", paramOffline, err)
+				}
+				offline = d
+			}
+			stopper, ok := buyer.(Stopper)
+			if !ok {
+				return fmt.Errorf("node %s cannot be stopped", buyer.Name())
+			}
+			restarter, ok := buyer.(Restarter)
+			if !ok {
+				return fmt.Errorf("node %s cannot be restarted", buyer.Name())
+			}
+
+			order, err := Checkout(ctx, vendor, buyer)
+			if err != nil {
+				return err
+			}
+			if err := stopper.Stop(ctx); err != nil {
+				return fmt.Errorf("stopping %s: %s", buyer.Name(), err)
+			}
+			if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, order.Slug)); err != nil {
+				return err
+			}
+			if err := WaitState(ctx, order.ID, "FULFILLED", vendor); err != nil {
+				return err
+			}
+
+			select {
+			case <-time.After(offline):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if err := restarter.Restart(ctx); err != nil {
+				return fmt.Errorf("bringing %s back after %s: %s", buyer.Name(), offline, err)
+			}
+
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			if err := WaitState(wait, order.ID, "FULFILLED", buyer); err != nil {
+				return fmt.Errorf("after %s offline: %s", offline, err)
+			}
+			if err := notifiedOnce(buyer, order.ID, "fulfillment"); err != nil {
+				return err
+			}
+			if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, order.Slug)); err != nil {
+				return fmt.Errorf("completing after %s offline: %s", offline, err)
+			}
+			if err := WaitState(wait, order.ID, "COMPLETED", buyer, vendor); err != nil {
+				return err
+			}
+			return notifiedOnce(vendor, order.ID, "orderComplete")
+		},
+	}
+}
+
+// notifiedOnce returns an error unless the node stored exactly one
+// notification of the given type for the order
+func notifiedOnce(n Node, orderID, typ string) error {
+	notifications, _, err := n.Client().Notifications()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, nt := range notifications {
+		if nt.OrderID == orderID && nt.Type == typ {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("%s stored %d %s notifications for order %s, expected 1", n.Name(), count, typ, orderID)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Partition splits the network into the given groups, the nodes in none of
+// them making one more. It is made with Ban, so the sides stay connected but
+// drop each other's marketplace messages until Heal. Partitioning again adds
+// to the bans already set; a simulated network can cut the links themselves
+// with sim.Network.Partition.
+func (n *Network) Partition(groups ...[]Node) error {
+	n.partitionLock.Lock()
+	defer n.partitionLock.Unlock()
+	if n.banned == nil {
+		n.banned = make(map[[2]Node]bool)
+	}
+	side := make(map[Node]int)
+	for _, nd := range n.Nodes {
+		side[nd] = -1
+	}
+	for i, group := range groups {
+		for _, nd := range group {
+			side[nd] = i
+		}
+	}
+	for _, a := range n.Nodes {
+		for _, b := range n.Nodes {
+			if a == b || side[a] == side[b] || n.banned[[2]Node{a, b}] {
+				continue
+			}
+			if err := Ban(a, b.PeerID()); err != nil {
+				return err
+			}
+			n.banned[[2]Node{a, b}] = true
+		}
+	}
+	return nil
+}
+
+// Heal lifts every ban Partition set
+func (n *Network) Heal() error {
+	n.partitionLock.Lock()
+	defer n.partitionLock.Unlock()
+	for pair := range n.banned {
+		if err := Unban(pair[0], pair[1].PeerID()); err != nil {
+			return err
+		}
+		delete(n.banned, pair)
+	}
+	return nil
+}
+
+// PartitionHeal splits the network in two halves, renames every node on
+// both sides while they are apart and heals it. Every node must then see
+// the new name of every other within converge, one minute if zero, the time
+// it takes being recorded as the converge step.
+func PartitionHeal(converge time.Duration) Scenario {
+	if converge == 0 {
+		converge = time.Minute
+	}
+	return Scenario{
+		Name:        "partition-heal",
+		Description: "profiles updated on both sides of a partition converge once it heals",
+		Run: func(ctx context.Context, net *Network) error {
+			if len(net.Nodes) < 2 {
+				return fmt.Errorf("scenario needs two nodes")
+			}
+			half := len(net.Nodes) / 2
+			if err := net.Partition(net.Nodes[:half], net.Nodes[half:]); err != nil {
+				return err
+			}
+			defer net.Heal()
+
+			names := make(map[Node]string)
+			err := net.Step("partitioned", func() error {
+				for _, nd := range net.Nodes {
+					names[nd] = fmt.Sprintf("%s partitioned at %s", nd.Name(), time.Now().Format(time.RFC3339Nano))
+					if err := rename(nd, names[nd]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := net.Heal(); err != nil {
+				return err
+			}
+
+			return net.Step("converge", func() error {
+				wait, cancel := context.WithTimeout(ctx, converge)
+				defer cancel()
+				return poll(wait, func() error {
+					var stale []string
+					for _, observer := range net.Nodes {
+						for _, owner := range net.Nodes {
+							if observer == owner {
+								continue
+							}
+							p, err := observer.Client().Profile(owner.PeerID(), false)
+							if err != nil || p.Name != names[owner] {
+								stale = append(stale, observer.Name()+" of "+owner.Name())
+							}
+						}
+					}
+					if len(stale) > 0 {
+						return fmt.Errorf("profiles not converged %s after healing: %s", converge, strings.Join(stale, ", "))
+					}
+					return nil
+				})
+			})
+		},
+	}
+}
+
+// rename sets the name on the node's profile, creating it if need be
+func rename(n Node, name string) error {
+	return setProfile(n, map[string]interface{}{"name": name})
+}
+
+// setProfile replaces the node's profile, creating it if need be
+func setProfile(n Node, profile map[string]interface{}) error {
+	if _, err := n.Client().Profile("", false); err != nil {
+		err = n.Client().CreateProfile(profile)
+		if err != nil {
+			return fmt.Errorf("creating the profile of %s: %s", n.Name(), err)
+		}
+		return nil
+	}
+	if err := n.Client().UpdateProfile(profile); err != nil {
+		return fmt.Errorf("updating the profile of %s: %s", n.Name(), err)
+	}
+	return nil
+}
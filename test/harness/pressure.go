@@ -0,0 +1,109 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/peers"
+)
+
+// PressureOptions configures the connection pressure scenario
+type PressureOptions struct {
+	// Peers is how many dummy peers connect to the vendor, 1000 if zero:
+	// past the high water mark of 900 connections go-ipfs connection
+	// managers default to
+	Peers int
+
+	// Hold is how long the crowd stays connected, 2 minutes if zero, long
+	// enough for a connection manager's grace period and trim to run
+	Hold time.Duration
+}
+
+// ConnectionPressure has the buyer place an order with the vendor and then
+// surrounds the vendor with a crowd of dummy peers, pushing it past where a
+// connection manager would start pruning. The order stays open throughout,
+// and the vendor's connection to the buyer, its counterparty, must never be
+// pruned while the crowd's may be. The vendor has to tell the harness its
+// swarm address, see Addresser.
+func ConnectionPressure(opts PressureOptions) Scenario {
+	if opts.Peers == 0 {
+		opts.Peers = 1000
+	}
+	if opts.Hold == 0 {
+		opts.Hold = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "connection-pressure",
+		Description: "a vendor crowded by a thousand peers keeps its connection to the counterparty of an open order",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			a, ok := vendor.(Addresser)
+			if !ok || len(a.SwarmAddrs()) == 0 {
+				return fmt.Errorf("scenario needs the swarm address of %s", vendor.Name())
+			}
+			order, err := PlaceOrder(vendor, buyer, nil)
+			if err != nil {
+				return err
+			}
+			if err := WaitState(ctx, order.ID, "AWAITING_PAYMENT", buyer, vendor); err != nil {
+				return err
+			}
+			wait, cancel := context.WithTimeout(ctx, time.Minute)
+			err = poll(wait, func() error {
+				if connected, err := vendor.Client().ConnectedTo(buyer.PeerID()); err != nil || !connected {
+					connect(buyer, vendor)
+					return fmt.Errorf("%s is not connected to %s before the crowd arrives", vendor.Name(), buyer.Name())
+				}
+				return nil
+			})
+			cancel()
+			if err != nil {
+				return err
+			}
+
+			var crowd *peers.Crowd
+			err = net.Step(fmt.Sprintf("crowd/%d", opts.Peers), func() error {
+				crowd, err = peers.NewCrowd(ctx, opts.Peers, a.SwarmAddrs()[0])
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			defer crowd.Close()
+
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			deadline := time.After(opts.Hold)
+			peak := 0
+			for {
+				addrs, err := vendor.Client().Peers()
+				if err != nil {
+					return fmt.Errorf("%s stopped answering under %d connections: %s", vendor.Name(), opts.Peers, err)
+				}
+				if len(addrs) > peak {
+					peak = len(addrs)
+				}
+				connected, err := vendor.Client().ConnectedTo(buyer.PeerID())
+				if err != nil {
+					return err
+				}
+				if !connected {
+					return fmt.Errorf("%s pruned its connection to %s, the buyer of open order %s, at %d peers (peak %d, %d of the crowd left)",
+						vendor.Name(), buyer.Name(), order.ID, len(addrs), peak, crowd.Connected())
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-deadline:
+					return vendor.Client().WaitOrderState(ctx, order.ID, "AWAITING_PAYMENT")
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+}
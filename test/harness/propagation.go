@@ -0,0 +1,56 @@
+package harness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// Propagation is how long each observer took to resolve a freshly published
+// listing. Observers that never resolved it are missing from Resolved.
+type Propagation struct {
+	Slug      string
+	Published time.Time
+	Resolved  map[string]time.Duration
+	Observers []string
+}
+
+// ListingPropagation publishes the fixture listing on the vendor and polls
+// every observer until it resolves the listing by slug through the vendor's
+// IPNS record or ctx is done. Observers are polled in parallel every
+// interval.
+func ListingPropagation(ctx context.Context, vendor Node, observers []Node, interval time.Duration) (*Propagation, error) {
+	slug, err := vendor.Client().CreateListing(fixtures.Listing())
+	if err != nil {
+		return nil, err
+	}
+	p := &Propagation{Slug: slug, Published: time.Now(), Resolved: make(map[string]time.Duration)}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, o := range observers {
+		p.Observers = append(p.Observers, o.Name())
+		wg.Add(1)
+		go func(o Node) {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if _, err := o.Client().Listing(vendor.PeerID(), slug); err == nil {
+					lock.Lock()
+					p.Resolved[o.Name()] = time.Since(p.Published)
+					lock.Unlock()
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(o)
+	}
+	wg.Wait()
+	return p, nil
+}
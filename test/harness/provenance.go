@@ -0,0 +1,26 @@
+package harness
+
+import "github.com/OpenBazaar/openbazaar-go/test/manifest"
+
+// Provenance records the nodes of the network in the manifest, with the
+// release, implementation and coin each runs
+func (n *Network) Provenance(m *manifest.Manifest) {
+	for _, nd := range n.Nodes {
+		c := nd.Client()
+		node := manifest.Node{
+			Name:           nd.Name(),
+			Role:           nd.Role(),
+			PeerID:         nd.PeerID(),
+			Implementation: ImplementationOf(nd).Name,
+			Version:        c.Version,
+		}
+		if cfg, err := c.Config(); err == nil {
+			node.Coin = cfg.CryptoCurrency
+			if cfg.Testnet {
+				node.Coin += " testnet"
+			}
+			m.AddCoin(node.Coin)
+		}
+		m.Nodes = append(m.Nodes, node)
+	}
+}
@@ -0,0 +1,88 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PeerReconnect restarts every restartable node in turn and waits for it to
+// reconnect to each network peer it was connected to before, failing when
+// that takes longer than deadline. Reconnection times are recorded as
+// reconnect_seconds per node.
+//
+// The node keeps peer addresses in an in-memory peerstore only, so after a
+// restart it finds its peers again through the bootstrap list and the DHT
+// rather than a persisted address book, and there is nothing to prune. The
+// deadline is what a persisted address book would have to beat.
+func PeerReconnect(deadline time.Duration) Scenario {
+	if deadline == 0 {
+		deadline = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "peer-reconnect",
+		Description: "a restarted node reconnects to the peers it knew within the deadline",
+		Restarts:    true,
+		Run: func(ctx context.Context, net *Network) error {
+			restarted := 0
+			for _, n := range net.Nodes {
+				r, ok := n.(Restarter)
+				if !ok {
+					continue
+				}
+				known, err := networkPeers(net, n)
+				if err != nil {
+					return err
+				}
+				if len(known) == 0 {
+					continue
+				}
+				if err := r.Restart(ctx); err != nil {
+					return fmt.Errorf("restarting %s: %s", n.Name(), err)
+				}
+				start := time.Now()
+				wait, cancel := context.WithTimeout(ctx, deadline)
+				err = poll(wait, func() error {
+					for _, p := range known {
+						connected, err := n.Client().ConnectedTo(p.PeerID())
+						if err != nil {
+							return err
+						}
+						if !connected {
+							return fmt.Errorf("%s not reconnected to %s after %s", n.Name(), p.Name(), deadline)
+						}
+					}
+					return nil
+				})
+				cancel()
+				if err != nil {
+					return err
+				}
+				net.Metrics.Timing("reconnect_seconds", time.Since(start), map[string]string{"node": n.Name()})
+				restarted++
+			}
+			if restarted == 0 {
+				return fmt.Errorf("no restartable node with network peers")
+			}
+			return nil
+		},
+	}
+}
+
+// networkPeers returns the other nodes of the network n is connected to
+func networkPeers(net *Network, n Node) ([]Node, error) {
+	var ret []Node
+	for _, p := range net.Nodes {
+		if p == n {
+			continue
+		}
+		connected, err := n.Client().ConnectedTo(p.PeerID())
+		if err != nil {
+			return nil, err
+		}
+		if connected {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
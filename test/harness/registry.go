@@ -0,0 +1,173 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/topology"
+)
+
+var (
+	registry   = make(map[string]Scenario)
+	registryMu sync.Mutex
+)
+
+// Register adds a scenario to the library run by the testnodes command.
+// Names are slash separated, e.g. regression/lost-chat-on-restart, and must be
+// unique.
+func Register(s Scenario) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[s.Name]; ok {
+		panic("harness: scenario " + s.Name + " registered twice")
+	}
+	registry[s.Name] = s
+}
+
+// Scenarios returns the registered scenarios whose name matches the shell
+// pattern, sorted by name
+func Scenarios(pattern string) ([]Scenario, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	var ret []Scenario
+	for name, s := range registry {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, s)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Result is the outcome of running one scenario
+type Result struct {
+	Scenario Scenario
+	Duration time.Duration
+	Err      error
+
+	// Features are the experimental features active on any node while the
+	// scenario ran
+	Features []string
+
+	// Topology is the network observed before and after the scenario
+	Topology []*topology.Snapshot
+
+	// Step is the innermost step that failed, the scenario name when the
+	// scenario used no steps of its own
+	Step string
+
+	// Skipped says why the scenario did not run, empty when it did
+	Skipped string
+
+	// Implementations are the server implementations the network ran
+	Implementations []string
+}
+
+func (r Result) String() string {
+	status := "ok"
+	switch {
+	case r.Err != nil:
+		status = "FAIL: " + r.Err.Error()
+	case r.Skipped != "":
+		status = "skipped: " + r.Skipped
+	}
+	if len(r.Features) > 0 {
+		status += " [features: " + strings.Join(r.Features, ",") + "]"
+	}
+	return fmt.Sprintf("%s (v%d) %s %s", r.Scenario.Name, r.Scenario.Version, r.Duration.Round(time.Millisecond), status)
+}
+
+// checkSchema returns the API drift seen since the last check as an error
+func (n *Network) checkSchema() error {
+	if n.Schema == nil {
+		return nil
+	}
+	violations := n.Schema.Take()
+	if len(violations) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Errorf("api schema drift: %s", strings.Join(msgs, "; "))
+}
+
+// Run runs the scenarios one after another against the network. A failing
+// scenario does not stop the ones after it, and scenarios requiring
+// capabilities some node's implementation lacks are skipped.
+func Run(ctx context.Context, net *Network, scenarios []Scenario) []Result {
+	var results []Result
+	impls := net.Implementations()
+	for _, s := range scenarios {
+		if why := net.unsupported(s); why != "" {
+			results = append(results, Result{Scenario: s, Skipped: why, Implementations: impls})
+			continue
+		}
+		before := net.Topology("before " + s.Name)
+		net.stepLock.Lock()
+		net.failedStep = ""
+		net.stepLock.Unlock()
+		start := time.Now()
+		var active []string
+		if net.Schema != nil {
+			net.Schema.Take()
+		}
+		err := net.Step(s.Name, func() error {
+			mark, err := net.markStrict()
+			if err != nil {
+				return fmt.Errorf("strict mode: %s", err)
+			}
+			var refs map[string]map[string]bool
+			if net.SweepContent {
+				var err error
+				if refs, err = net.contentRefs(ctx); err != nil {
+					return fmt.Errorf("content sweep: %s", err)
+				}
+			}
+			return net.withFeatures(ctx, s.Features, func(features []string) error {
+				active = features
+				return net.watchMemory(ctx, func(ctx context.Context) error {
+					if err := net.withFaults(ctx, s.Faults, s.Run); err != nil {
+						return err
+					}
+					if err := net.checkSchema(); err != nil {
+						return err
+					}
+					if err := net.checkStrict(mark, s.Notifications); err != nil {
+						return err
+					}
+					if net.SweepContent {
+						err := net.Step("content-sweep", func() error {
+							return net.sweepContent(ctx, refs)
+						})
+						if err != nil {
+							return err
+						}
+					}
+					return net.runCheckers(ctx)
+				})
+			})
+		})
+		results = append(results, Result{
+			Scenario: s,
+			Duration: time.Since(start),
+			Err:      err,
+			Features: active,
+			Topology: []*topology.Snapshot{before, net.Topology("after " + s.Name)},
+			Step:     net.failedStep,
+
+			Implementations: impls,
+		})
+	}
+	return results
+}
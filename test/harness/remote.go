@@ -0,0 +1,36 @@
+package harness
+
+import "github.com/OpenBazaar/openbazaar-go/test/client"
+
+// RemoteNode is a node started outside the harness and reached only through
+// its API. It can be neither stopped nor restarted.
+type RemoteNode struct {
+	name   string
+	role   string
+	peerID string
+	client *client.Client
+	impl   Implementation
+}
+
+// NewRemoteNode attaches to the node behind c and looks up its peer ID
+func NewRemoteNode(name, role string, c *client.Client) (*RemoteNode, error) {
+	peerID, err := c.PeerID()
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteNode{name: name, role: role, peerID: peerID, client: c, impl: OpenBazaarGo}, nil
+}
+
+// WithImplementation records that the node runs impl rather than
+// openbazaar-go
+func (n *RemoteNode) WithImplementation(impl Implementation) *RemoteNode {
+	n.impl = impl
+	return n
+}
+
+func (n *RemoteNode) Name() string           { return n.name }
+func (n *RemoteNode) Role() string           { return n.role }
+func (n *RemoteNode) PeerID() string         { return n.peerID }
+func (n *RemoteNode) Client() *client.Client { return n.client }
+
+func (n *RemoteNode) Implementation() Implementation { return n.impl }
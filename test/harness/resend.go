@@ -0,0 +1,160 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+)
+
+// MetricsPath is where nodes started with --metrics serve their Prometheus
+// metrics, see nodes.WithMetrics
+const MetricsPath = "/debug/metrics/prometheus"
+
+const (
+	sendAttemptsMetric   = "openbazaar_net_message_send_attempts_total"
+	resendIntervalMetric = "openbazaar_net_message_resend_interval_seconds_bucket"
+)
+
+// ResendLimits bounds how hard a node may retry delivering a message
+type ResendLimits struct {
+	// MaxRetries is how many times a message may be re-sent for every direct
+	// attempt, counting both stream retries and the offline fallback
+	MaxRetries float64
+
+	// MinSpacing is the shortest allowed time between two attempts at the
+	// same message. Zero disables the check.
+	MinSpacing time.Duration
+
+	// Types limits the check to these message types, e.g. ORDER. Empty
+	// checks every type.
+	Types []string
+}
+
+// DefaultResendLimits allow the single immediate stream retry plus the
+// offline fallback
+var DefaultResendLimits = ResendLimits{
+	MaxRetries: 2,
+	Types:      []string{"ORDER", "ORDER_CONFIRMATION", "ORDER_FULFILLMENT", "ORDER_COMPLETION", "ORDER_CANCEL", "ORDER_REJECT"},
+}
+
+// ResendStats are the send attempts a node made for one message type
+type ResendStats struct {
+	Direct  float64
+	Retry   float64
+	Offline float64
+
+	// TooClose counts re-sends that followed the previous attempt sooner
+	// than the limit's MinSpacing
+	TooClose float64
+}
+
+// Resends reads the send attempt counters of a node, keyed by message type
+func Resends(n Node, minSpacing time.Duration) (map[string]ResendStats, error) {
+	b, err := n.Client().GetBytes(MetricsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading metrics of %s: %s", n.Name(), err)
+	}
+	points, err := metrics.ParseExposition(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("reading metrics of %s: %s", n.Name(), err)
+	}
+	stats := make(map[string]ResendStats)
+	bucket := make(map[string]float64)
+	for _, p := range points {
+		t := p.Tags["type"]
+		switch p.Name {
+		case sendAttemptsMetric:
+			s := stats[t]
+			switch p.Tags["attempt"] {
+			case "direct":
+				s.Direct += p.Value
+			case "retry":
+				s.Retry += p.Value
+			case "offline":
+				s.Offline += p.Value
+			}
+			stats[t] = s
+		case resendIntervalMetric:
+			// Buckets are cumulative, so the widest one not exceeding the
+			// minimum spacing holds every interval known to be too short
+			le, err := strconv.ParseFloat(p.Tags["le"], 64)
+			if err != nil || math.IsInf(le, 1) || le > minSpacing.Seconds() {
+				continue
+			}
+			if le >= bucket[t] {
+				bucket[t] = le
+				s := stats[t]
+				s.TooClose = p.Value
+				stats[t] = s
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Check returns an error describing every way the attempts made between the
+// before and after snapshots exceed the limits
+func (l ResendLimits) Check(before, after map[string]ResendStats) error {
+	var errs []string
+	for t, a := range after {
+		if len(l.Types) > 0 && !contains(l.Types, t) {
+			continue
+		}
+		b := before[t]
+		direct := a.Direct - b.Direct
+		resent := (a.Retry - b.Retry) + (a.Offline - b.Offline)
+		if resent > l.MaxRetries*direct {
+			errs = append(errs, fmt.Sprintf("%s re-sent %.0f times for %.0f messages, limit is %.1f per message", t, resent, direct, l.MaxRetries))
+		}
+		if l.MinSpacing > 0 {
+			if fast := a.TooClose - b.TooClose; fast > 0 {
+				errs = append(errs, fmt.Sprintf("%s re-sent %.0f times within %s of the previous attempt", t, fast, l.MinSpacing))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// WithResendBounds wraps a scenario so it also fails when any node re-sent
+// messages more often or faster than the limits allow while it ran. Use it
+// around flaky network scenarios to catch message flood regressions.
+func WithResendBounds(s Scenario, limits ResendLimits) Scenario {
+	run := s.Run
+	s.Run = func(ctx context.Context, net *Network) error {
+		before := make(map[string]map[string]ResendStats)
+		for _, n := range net.Nodes {
+			stats, err := Resends(n, limits.MinSpacing)
+			if err != nil {
+				return err
+			}
+			before[n.Name()] = stats
+		}
+		if err := run(ctx, net); err != nil {
+			return err
+		}
+		var errs []string
+		for _, n := range net.Nodes {
+			after, err := Resends(n, limits.MinSpacing)
+			if err != nil {
+				return err
+			}
+			if err := limits.Check(before[n.Name()], after); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", n.Name(), err))
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("resend bounds exceeded: %s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+	return s
+}
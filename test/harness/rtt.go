@@ -0,0 +1,167 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// Impair impairs the traffic between a and b both ways, the zero Impairment
+// removing it. Both nodes must be Impairers.
+func Impair(ctx context.Context, a, b Node, i netem.Impairment) error {
+	for _, pair := range [][2]Node{{a, b}, {b, a}} {
+		im, ok := pair[0].(Impairer)
+		if !ok {
+			return fmt.Errorf("the traffic of %s cannot be impaired", pair[0].Name())
+		}
+		if err := im.ImpairTo(ctx, pair[1], i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRTT delays the traffic between a and b both ways so a round trip
+// between them takes rtt more, zero removing the delay. Both nodes must be
+// Delayers.
+func SetRTT(ctx context.Context, a, b Node, rtt time.Duration) error {
+	for _, pair := range [][2]Node{{a, b}, {b, a}} {
+		d, ok := pair[0].(Delayer)
+		if !ok {
+			return fmt.Errorf("the traffic of %s cannot be delayed", pair[0].Name())
+		}
+		if err := d.DelayTo(ctx, pair[1], rtt/2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrderRTT takes a direct order from purchase to completion once for each
+// round trip time set between the vendor and the buyer, 200, 350 and 500 ms
+// if none are given, recording each leg as a step such as rtt-200ms/payment.
+// The delays are removed when the scenario ends.
+func OrderRTT(rtts ...time.Duration) Scenario {
+	if len(rtts) == 0 {
+		rtts = []time.Duration{200 * time.Millisecond, 350 * time.Millisecond, 500 * time.Millisecond}
+	}
+	return Scenario{
+		Name:        "order-rtt",
+		Description: "orders complete between a vendor and a buyer 200 to 500 ms apart, timing each leg",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+			defer SetRTT(context.Background(), vendor, buyer, 0)
+			for _, rtt := range rtts {
+				if err := SetRTT(ctx, vendor, buyer, rtt); err != nil {
+					return err
+				}
+				if _, err := timedOrder(ctx, net, fmt.Sprintf("rtt-%s", rtt), vendor, buyer, hash, slug); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// MobileLink is a flaky mobile connection: 100 ms each way, give or take
+// 40 ms, and 2% of packets lost
+var MobileLink = netem.Impairment{Delay: 100 * time.Millisecond, Jitter: 40 * time.Millisecond, Loss: 2}
+
+// OrderFlakyLink takes a direct order from purchase to completion over a link
+// impaired as i both ways between the vendor and the buyer, MobileLink if i
+// is zero, recording each leg as a step such as flaky/payment. The
+// impairment is removed when the scenario ends.
+func OrderFlakyLink(i netem.Impairment) Scenario {
+	if i == (netem.Impairment{}) {
+		i = MobileLink
+	}
+	return Scenario{
+		Name:        "order-flaky-link",
+		Description: "an order completes between a vendor and a buyer whose link jitters and drops packets",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+			if err := Impair(ctx, vendor, buyer, i); err != nil {
+				return err
+			}
+			defer Impair(context.Background(), vendor, buyer, netem.Impairment{})
+			_, err = timedOrder(ctx, net, "flaky", vendor, buyer, hash, slug)
+			return err
+		},
+	}
+}
+
+// timedOrder has the buyer purchase the listing and takes the order through
+// payment, fulfillment and completion, each leg a step under prefix
+func timedOrder(ctx context.Context, net *Network, prefix string, vendor, buyer Node, hash, slug string) (*Order, error) {
+	step := func(leg string, fn func() error) error {
+		return net.Step(prefix+"/"+leg, fn)
+	}
+	var order *Order
+	err := step("purchase", func() error {
+		resp, err := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+		if err != nil {
+			return fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+		}
+		order = &Order{ID: resp.OrderID, ListingHash: hash, Slug: slug, Payment: resp}
+		return WaitState(ctx, order.ID, "AWAITING_PAYMENT", buyer, vendor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = step("payment", func() error {
+		if err := PayOrder(buyer, order); err != nil {
+			return err
+		}
+		return WaitState(ctx, order.ID, "AWAITING_FULFILLMENT", buyer, vendor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = step("fulfillment", func() error {
+		if err := vendor.Client().FulfillOrder(fixtures.Fulfillment(order.ID, slug)); err != nil {
+			return fmt.Errorf("fulfilling order %s on %s: %s", order.ID, vendor.Name(), err)
+		}
+		return WaitState(ctx, order.ID, "FULFILLED", vendor, buyer)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = step("completion", func() error {
+		if err := buyer.Client().CompleteOrder(fixtures.Completion(order.ID, slug)); err != nil {
+			return fmt.Errorf("completing order %s on %s: %s", order.ID, buyer.Name(), err)
+		}
+		return WaitState(ctx, order.ID, "COMPLETED", buyer, vendor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
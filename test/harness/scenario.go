@@ -0,0 +1,54 @@
+package harness
+
+import "context"
+
+// Scenario is a named sequence of actions and assertions run against a network
+type Scenario struct {
+	Name        string
+	Description string
+
+	// Version is bumped whenever the steps or assertions change, so results
+	// recorded under the same name stay comparable across runs
+	Version int
+
+	// Features are the experimental node features the scenario covers. Run
+	// enables them on every node before the scenario and records the
+	// features that were active in its result.
+	Features []string
+
+	// Params are the sweep parameters the scenario reads with Param, e.g.
+	// confirmations. Sweeps over any other parameter the harness does not
+	// apply itself are rejected.
+	Params []string
+
+	// Faults is a fault schedule played alongside Run, see package chaos,
+	// e.g. "at T+10s partition {vendor}, at T+30s heal". Targets are node
+	// names or roles. Every partition is healed and every paused node
+	// resumed once Run returns.
+	Faults string
+
+	// Requires are the implementation capabilities the scenario exercises,
+	// e.g. CapChat. Run skips it on networks with a node whose
+	// implementation lacks any of them.
+	Requires []string
+
+	// Restarts says the scenario restarts nodes. Run skips it on networks
+	// with a node that is not a Restarter, e.g. one attached by URL.
+	Restarts bool
+
+	// Stops says the scenario shuts nodes down. Run skips it on networks
+	// with a node that is not a Stopper. A fault schedule that stops nodes
+	// implies Stops, one that restarts them Restarts.
+	Stops bool
+
+	// RotatesIdentity says the scenario gives nodes a fresh identity key.
+	// Run skips it on networks with a node that is not an IdentityRotator.
+	RotatesIdentity bool
+
+	// Notifications are the notification types the scenario makes nodes
+	// receive, e.g. order. In strict mode a node notified of any other
+	// type fails it.
+	Notifications []string
+
+	Run func(ctx context.Context, net *Network) error
+}
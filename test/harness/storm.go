@@ -0,0 +1,115 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/peers"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+)
+
+// StormOptions configures the republish storm scenario
+type StormOptions struct {
+	// Vendors is how many vendor nodes are restarted at once
+	Vendors int
+
+	// Window is how long traffic is measured after the restart
+	Window time.Duration
+
+	// MaxIPNSPerSecond is the ceiling on IPNS record puts seen by the observers
+	MaxIPNSPerSecond float64
+
+	// MaxProvidesPerSecond is the ceiling on provider announcements seen by the observers
+	MaxProvidesPerSecond float64
+}
+
+// DefaultStormOptions are the ceilings the storm scenario enforces unless overridden
+var DefaultStormOptions = StormOptions{
+	Vendors:              50,
+	Window:               2 * time.Minute,
+	MaxIPNSPerSecond:     5,
+	MaxProvidesPerSecond: 50,
+}
+
+// RepublishStorm restarts every vendor at the same time and measures the IPNS
+// republish and provider announcement traffic that reaches the observer peers.
+// The observers must be bootstrap peers of the vendors so they sit close to
+// them in the DHT. Exceeding either ceiling fails the scenario, which catches
+// thundering herd regressions in the startup republish logic.
+func RepublishStorm(opts StormOptions, observers ...*peers.Peer) Scenario {
+	return Scenario{
+		Name:        "republish-storm",
+		Description: fmt.Sprintf("restart %d vendors at once and bound IPNS/provider traffic", opts.Vendors),
+		Restarts:    true,
+		Run: func(ctx context.Context, net *Network) error {
+			if len(observers) == 0 {
+				return fmt.Errorf("republish storm needs at least one observer peer")
+			}
+			vendors := net.Role("vendor")
+			if len(vendors) < opts.Vendors {
+				return fmt.Errorf("republish storm needs %d vendors, network has %d", opts.Vendors, len(vendors))
+			}
+			vendors = vendors[:opts.Vendors]
+
+			ipnsBefore, providesBefore := observed(observers)
+			if err := restartAll(ctx, vendors); err != nil {
+				return err
+			}
+			select {
+			case <-time.After(opts.Window):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			ipnsAfter, providesAfter := observed(observers)
+
+			secs := opts.Window.Seconds()
+			ipnsRate := float64(ipnsAfter-ipnsBefore) / secs
+			providesRate := float64(providesAfter-providesBefore) / secs
+			var errs []string
+			if ipnsRate > opts.MaxIPNSPerSecond {
+				errs = append(errs, fmt.Sprintf("IPNS puts %.2f/s exceed ceiling %.2f/s", ipnsRate, opts.MaxIPNSPerSecond))
+			}
+			if providesRate > opts.MaxProvidesPerSecond {
+				errs = append(errs, fmt.Sprintf("provider announcements %.2f/s exceed ceiling %.2f/s", providesRate, opts.MaxProvidesPerSecond))
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("republish storm: %s", strings.Join(errs, "; "))
+			}
+			return nil
+		},
+	}
+}
+
+func observed(observers []*peers.Peer) (ipns, provides int) {
+	for _, o := range observers {
+		ipns += o.DHT.Count(pb.Message_PUT_VALUE)
+		provides += o.DHT.Count(pb.Message_ADD_PROVIDER)
+	}
+	return ipns, provides
+}
+
+// restartAll restarts the nodes concurrently and returns the first error
+func restartAll(ctx context.Context, nodes []Node) error {
+	for _, n := range nodes {
+		if _, ok := n.(Restarter); !ok {
+			return fmt.Errorf("node %s cannot be restarted", n.Name())
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(nodes))
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n Node) {
+			defer wg.Done()
+			if err := n.(Restarter).Restart(ctx); err != nil {
+				errs <- fmt.Errorf("restarting %s: %s", n.Name(), err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
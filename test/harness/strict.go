@@ -0,0 +1,256 @@
+package harness
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// strictShown bounds the log lines and responses quoted per failure
+const strictShown = 5
+
+var (
+	// logLevel matches the lines openbazaard logs at WARNING or worse
+	logLevel = regexp.MustCompile(`\[(WARNING|ERROR|CRITICAL)\]`)
+
+	// colors are the terminal escapes around levels in the node's output
+	colors = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+// Strict fails scenarios on the signs of silent degradation that fail no
+// step by themselves: a warning or worse in the log of a node, an API
+// response outside 2xx that no step expected, see Network.Expect, or a
+// notification of a type the scenario does not list in Notifications. It
+// sees the responses of the clients it is the Observer of; logs are read
+// of the nodes that are Loggers.
+type Strict struct {
+	// Quiet are patterns of log lines that do not fail a scenario, e.g.
+	// warnings a release under test is known to log
+	Quiet []*regexp.Regexp
+
+	lock      sync.Mutex
+	expected  int
+	responses []string
+	seen      map[string]bool
+}
+
+// NewStrict returns a strict mode quieting log lines matching any of quiet
+func NewStrict(quiet ...*regexp.Regexp) *Strict {
+	return &Strict{Quiet: quiet, seen: make(map[string]bool)}
+}
+
+// Observe records a response outside 2xx unless a step expects them
+func (s *Strict) Observe(method, path string, status int, body []byte) {
+	if status >= 200 && status < 300 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.expected > 0 {
+		return
+	}
+	resp := fmt.Sprintf("%s %s: %d", method, path, status)
+	if !s.seen[resp] {
+		s.seen[resp] = true
+		s.responses = append(s.responses, resp)
+	}
+}
+
+// expect lets responses outside 2xx through until the returned func is
+// called
+func (s *Strict) expect() func() {
+	s.lock.Lock()
+	s.expected++
+	s.lock.Unlock()
+	return func() {
+		s.lock.Lock()
+		s.expected--
+		s.lock.Unlock()
+	}
+}
+
+// take returns the responses recorded since the last call and forgets them
+func (s *Strict) take() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := s.responses
+	s.responses = nil
+	s.seen = make(map[string]bool)
+	return ret
+}
+
+// Logger is implemented by nodes whose output can be read, see
+// nodes.Process.Log
+type Logger interface {
+	Log() string
+}
+
+// strictMark is where the logs and notifications of the nodes stood before
+// a scenario
+type strictMark struct {
+	logs          map[Node]int64
+	notifications map[Node]map[string]bool
+}
+
+// Expect runs fn as a named step whose API errors are expected, e.g. a
+// purchase meant to be refused, so strict mode does not fail the scenario
+// for them. Responses to other steps running at the same time are let
+// through too.
+func (n *Network) Expect(name string, fn func() error) error {
+	if n.Strict != nil {
+		defer n.Strict.expect()()
+	}
+	return n.Step(name, fn)
+}
+
+// markStrict records where the logs and notifications of the nodes stand,
+// and forgets the responses seen before
+func (n *Network) markStrict() (*strictMark, error) {
+	if n.Strict == nil {
+		return nil, nil
+	}
+	m := &strictMark{logs: make(map[Node]int64), notifications: make(map[Node]map[string]bool)}
+	err := n.Expect("strict/mark", func() error {
+		for _, nd := range n.Nodes {
+			if l, ok := nd.(Logger); ok {
+				info, err := os.Stat(l.Log())
+				switch {
+				case os.IsNotExist(err):
+				case err != nil:
+					return err
+				default:
+					m.logs[nd] = info.Size()
+				}
+			}
+			if !ImplementationOf(nd).Supports(CapNotifications) {
+				continue
+			}
+			notifications, _, err := nd.Client().Notifications()
+			if err != nil {
+				return fmt.Errorf("notifications of %s: %s", nd.Name(), err)
+			}
+			m.notifications[nd] = make(map[string]bool)
+			for _, nt := range notifications {
+				m.notifications[nd][nt.ID] = true
+			}
+		}
+		return nil
+	})
+	n.Strict.take()
+	return m, err
+}
+
+// checkStrict fails on what the nodes logged, answered or were notified of
+// since the mark that a scenario expecting the notification types would
+// not see
+func (n *Network) checkStrict(m *strictMark, expected []string) error {
+	if n.Strict == nil {
+		return nil
+	}
+	var problems []string
+	if responses := n.Strict.take(); len(responses) > 0 {
+		problems = append(problems, fmt.Sprintf("%d unexpected API errors: %s", len(responses), quote(responses)))
+	}
+	allowed := make(map[string]bool)
+	for _, typ := range expected {
+		allowed[typ] = true
+	}
+	err := n.Expect("strict/check", func() error {
+		for _, nd := range n.Nodes {
+			if l, ok := nd.(Logger); ok {
+				lines, err := n.Strict.warnings(l.Log(), m.logs[nd])
+				if err != nil {
+					return fmt.Errorf("log of %s: %s", nd.Name(), err)
+				}
+				if len(lines) > 0 {
+					problems = append(problems, fmt.Sprintf("%s logged %d warnings: %s", nd.Name(), len(lines), quote(lines)))
+				}
+			}
+			before, ok := m.notifications[nd]
+			if !ok {
+				continue
+			}
+			notifications, _, err := nd.Client().Notifications()
+			if err != nil {
+				return fmt.Errorf("notifications of %s: %s", nd.Name(), err)
+			}
+			counts := make(map[string]int)
+			for _, nt := range notifications {
+				if !before[nt.ID] && !allowed[nt.Type] {
+					counts[nt.Type]++
+				}
+			}
+			var types []string
+			for typ, count := range counts {
+				types = append(types, fmt.Sprintf("%d %s", count, typ))
+			}
+			sort.Strings(types)
+			if len(types) > 0 {
+				problems = append(problems, fmt.Sprintf("%s got unexpected notifications: %s", nd.Name(), strings.Join(types, ", ")))
+			}
+		}
+		return nil
+	})
+	n.Strict.take()
+	if err != nil {
+		return fmt.Errorf("strict mode: %s", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("strict mode: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// warnings returns the lines at WARNING or worse that were not quieted in
+// the log at path from offset on. A log shorter than offset was replaced
+// and is read from the start.
+func (s *Strict) warnings(path string, offset int64) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var ret []string
+	r := bufio.NewScanner(f)
+	r.Buffer(nil, 1<<20)
+lines:
+	for r.Scan() {
+		line := colors.ReplaceAllString(r.Text(), "")
+		if !logLevel.MatchString(line) {
+			continue
+		}
+		for _, q := range s.Quiet {
+			if q.MatchString(line) {
+				continue lines
+			}
+		}
+		ret = append(ret, strings.TrimSpace(line))
+	}
+	return ret, r.Err()
+}
+
+// quote joins the first few of items, saying how many more there are
+func quote(items []string) string {
+	if len(items) <= strictShown {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:strictShown], ", "), len(items)-strictShown)
+}
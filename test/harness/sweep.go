@@ -0,0 +1,68 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/sweep"
+)
+
+// Setup prepares the network a sweep runs one point on and returns a function
+// undoing whatever it changed, e.g. shaped traffic
+type Setup func(ctx context.Context, p sweep.Point) (*Network, func() error, error)
+
+type pointKey struct{}
+
+// Param returns the value of a sweep parameter inside a scenario run by Sweep
+func Param(ctx context.Context, name string) (string, bool) {
+	p, ok := ctx.Value(pointKey{}).(sweep.Point)
+	if !ok {
+		return "", false
+	}
+	return p.Get(name)
+}
+
+// Sweep runs the scenario once at every point of the grid on the network
+// setup returns for it, and returns one cell per point
+func Sweep(ctx context.Context, s Scenario, grid []sweep.Point, setup Setup) []sweep.Cell {
+	var cells []sweep.Cell
+	for _, p := range grid {
+		start := time.Now()
+		net, undo, err := setup(ctx, p)
+		if err != nil {
+			cells = append(cells, sweep.Cell{Point: p, Duration: time.Since(start), Err: fmt.Errorf("setup: %s", err)})
+			continue
+		}
+		r := Run(context.WithValue(ctx, pointKey{}, p), net, []Scenario{s})[0]
+		cell := sweep.Cell{Point: p, Duration: r.Duration, Err: r.Err}
+		if undo != nil {
+			if err := undo(); err != nil && cell.Err == nil {
+				cell.Err = fmt.Errorf("undoing setup: %s", err)
+			}
+		}
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// Subset returns a network of the first count nodes, sharing the metrics,
+// memory ceilings, schema checker and strict mode of n
+func (n *Network) Subset(count int) (*Network, error) {
+	if count < 1 || count > len(n.Nodes) {
+		return nil, fmt.Errorf("cannot pick %d of %d nodes", count, len(n.Nodes))
+	}
+	return &Network{
+		Nodes:        n.Nodes[:count:count],
+		Metrics:      n.Metrics,
+		ProfileDir:   n.ProfileDir,
+		Artifacts:    n.Artifacts,
+		Schema:       n.Schema,
+		SweepContent: n.SweepContent,
+		Strict:       n.Strict,
+		Verifiers:    n.Verifiers,
+		Clock:        n.Clock,
+		Guest:        n.Guest,
+		ceilings:     n.ceilings,
+	}, nil
+}
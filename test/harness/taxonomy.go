@@ -0,0 +1,151 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+)
+
+// StoreTaxonomy fills the vendor's store with listings spread over a
+// generated category tree and tag pool, then has the buyer filter the store
+// the way a client does. The listings endpoint has no filter parameters:
+// categories are filtered over the listing index and tags need every full
+// listing. Filtering by any category must return exactly the listings of its
+// subtree, every tag exactly the listings carrying it, and fetching the
+// index twice must list them in the same order.
+func StoreTaxonomy(tx fixtures.Taxonomy, listings int, settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 5 * time.Minute
+	}
+	return Scenario{
+		Name:        "store-taxonomy",
+		Description: "category and tag filters over a large store return exactly the matching listings",
+		Run: func(ctx context.Context, net *Network) error {
+			if err := tx.Validate(); err != nil {
+				return err
+			}
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+
+			byCategory := make(map[string][]string)
+			byTag := make(map[string][]string)
+			mine := make(map[string]bool)
+			err = net.Step("create listings", func() error {
+				for i := 0; i < listings; i++ {
+					slug, err := vendor.Client().CreateListing(tx.StoreListing(i))
+					if err != nil {
+						return fmt.Errorf("creating listing %d on %s: %s", i, vendor.Name(), err)
+					}
+					mine[slug] = true
+					for _, c := range tx.Path(i % tx.Leaves()) {
+						byCategory[c] = append(byCategory[c], slug)
+					}
+					for _, tag := range tx.ListingTags(i) {
+						byTag[tag] = append(byTag[tag], slug)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			var index []client.ListingSummary
+			err = poll(wait, func() error {
+				all, err := buyer.Client().Listings(vendor.PeerID())
+				if err != nil {
+					return err
+				}
+				index = storeListings(all, mine)
+				if len(index) != listings {
+					return fmt.Errorf("buyer sees %d of the %d store listings of %s", len(index), listings, vendor.Name())
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			again, err := buyer.Client().Listings(vendor.PeerID())
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(index, storeListings(again, mine)) {
+				return fmt.Errorf("listing index of %s changed order between two fetches", vendor.Name())
+			}
+
+			for _, c := range tx.Categories() {
+				var got []string
+				for _, l := range index {
+					if contains(l.Categories, c) {
+						got = append(got, l.Slug)
+					}
+				}
+				if err := sameSlugSet(got, byCategory[c]); err != nil {
+					return fmt.Errorf("category %s: %s", c, err)
+				}
+			}
+
+			gotTags := make(map[string][]string)
+			err = net.Step("fetch tags", func() error {
+				for _, l := range index {
+					tags, err := buyer.Client().ListingTags(vendor.PeerID(), l.Hash)
+					if err != nil {
+						return fmt.Errorf("fetching %s: %s", l.Slug, err)
+					}
+					for _, tag := range tags {
+						gotTags[tag] = append(gotTags[tag], l.Slug)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for tag, want := range byTag {
+				if err := sameSlugSet(gotTags[tag], want); err != nil {
+					return fmt.Errorf("tag %s: %s", tag, err)
+				}
+			}
+			for tag := range gotTags {
+				if _, ok := byTag[tag]; !ok {
+					return fmt.Errorf("tag %s was never set but is on %v", tag, gotTags[tag])
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// storeListings keeps the entries of the index whose slug is in mine, in
+// index order
+func storeListings(index []client.ListingSummary, mine map[string]bool) []client.ListingSummary {
+	var ret []client.ListingSummary
+	for _, l := range index {
+		if mine[l.Slug] {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+// sameSlugSet returns an error naming the difference between two sets of
+// slugs
+func sameSlugSet(got, want []string) error {
+	g := append([]string(nil), got...)
+	w := append([]string(nil), want...)
+	sort.Strings(g)
+	sort.Strings(w)
+	if reflect.DeepEqual(g, w) {
+		return nil
+	}
+	return fmt.Errorf("got %d listings %v, expected %d %v", len(g), g, len(w), w)
+}
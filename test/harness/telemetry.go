@@ -0,0 +1,59 @@
+package harness
+
+import (
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+	"github.com/OpenBazaar/openbazaar-go/test/peers"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+)
+
+// SampleDHT exports the DHT message counts seen by the observer peers
+func SampleDHT(rec *metrics.Recorder, observers ...*peers.Peer) {
+	for _, o := range observers {
+		counter := o.DHT
+		tags := map[string]string{"observer": o.ID().Pretty()}
+		for _, t := range []pb.Message_MessageType{pb.Message_PUT_VALUE, pb.Message_ADD_PROVIDER, pb.Message_GET_VALUE, pb.Message_GET_PROVIDERS, pb.Message_FIND_NODE} {
+			t := t
+			rec.Sample("dht_messages_total", withTag(tags, "type", t.String()), func() float64 {
+				return float64(counter.Count(t))
+			})
+		}
+	}
+}
+
+// SampleBandwidth exports the swarm traffic of the nodes as they report it.
+// Nodes whose stats cannot be read report zero.
+func SampleBandwidth(rec *metrics.Recorder, nodes ...Node) {
+	for _, n := range nodes {
+		c := n.Client()
+		tags := map[string]string{"node": n.Name()}
+		for _, m := range []struct {
+			name, direction string
+			read            func(*client.Bandwidth) float64
+		}{
+			{"bandwidth_bytes_total", "in", func(bw *client.Bandwidth) float64 { return float64(bw.TotalIn) }},
+			{"bandwidth_bytes_total", "out", func(bw *client.Bandwidth) float64 { return float64(bw.TotalOut) }},
+			{"bandwidth_bytes_per_second", "in", func(bw *client.Bandwidth) float64 { return bw.RateIn }},
+			{"bandwidth_bytes_per_second", "out", func(bw *client.Bandwidth) float64 { return bw.RateOut }},
+		} {
+			m := m
+			rec.Sample(m.name, withTag(tags, "direction", m.direction), func() float64 {
+				bw, err := c.Bandwidth()
+				if err != nil {
+					return 0
+				}
+				return m.read(bw)
+			})
+		}
+	}
+}
+
+// withTag returns a copy of tags with one more entry
+func withTag(tags map[string]string, k, v string) map[string]string {
+	ret := make(map[string]string, len(tags)+1)
+	for tk, tv := range tags {
+		ret[tk] = tv
+	}
+	ret[k] = v
+	return ret
+}
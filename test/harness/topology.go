@@ -0,0 +1,95 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/topology"
+)
+
+// Topology records which nodes are connected to each other right now. Nodes
+// whose peers cannot be listed appear without connections.
+func (n *Network) Topology(label string) *topology.Snapshot {
+	var peers []topology.Peers
+	for _, nd := range n.Nodes {
+		addrs, err := nd.Client().Peers()
+		peers = append(peers, topology.Peers{
+			Vertex: topology.Vertex{Name: nd.Name(), Role: nd.Role(), PeerID: nd.PeerID()},
+			Addrs:  addrs,
+			Err:    err,
+		})
+	}
+	return topology.Build(label, peers)
+}
+
+// Shape connects and disconnects the swarm peers of the network's nodes,
+// node i of the layout being n.Nodes[i], until the connections between them
+// are exactly the layout's. Nodes redial peers they need, so it keeps
+// correcting until the observed topology matches or ctx is done, and then
+// returns the connections still missing and extra. Connections to peers
+// outside the network are left alone.
+func (n *Network) Shape(ctx context.Context, l topology.Layout) error {
+	if l.Size() != len(n.Nodes) {
+		return fmt.Errorf("%s layout is for %d nodes, the network has %d", l.Name, l.Size(), len(n.Nodes))
+	}
+	names := make([]string, len(n.Nodes))
+	byName := make(map[string]Node)
+	for i, nd := range n.Nodes {
+		names[i] = nd.Name()
+		byName[nd.Name()] = nd
+	}
+	return poll(ctx, func() error {
+		missing, extra := l.Diff(n.Topology(l.Name), names)
+		if len(missing) == 0 && len(extra) == 0 {
+			return nil
+		}
+		for _, e := range missing {
+			connect(byName[e.From], byName[e.To])
+		}
+		for _, e := range extra {
+			disconnect(byName[e.From], byName[e.To])
+			disconnect(byName[e.To], byName[e.From])
+		}
+		return fmt.Errorf("%s topology did not converge: missing %s, extra %s", l.Name, edges(missing), edges(extra))
+	})
+}
+
+// connect dials to from from, at the addresses to knows itself by or else
+// by peer ID alone, leaving from to find it through its peerstore or the DHT
+func connect(from, to Node) {
+	addrs := []string{"/ipfs/" + to.PeerID()}
+	if a, ok := to.(Addresser); ok && len(a.SwarmAddrs()) > 0 {
+		addrs = a.SwarmAddrs()
+	}
+	for _, addr := range addrs {
+		if from.Client().ConnectPeer(addr) == nil {
+			return
+		}
+	}
+}
+
+// disconnect closes the connections from has to to, at the addresses from
+// reports them on
+func disconnect(from, to Node) {
+	peers, err := from.Client().Peers()
+	if err != nil {
+		return
+	}
+	for _, addr := range peers {
+		if strings.HasSuffix(addr, "/ipfs/"+to.PeerID()) {
+			from.Client().DisconnectPeer(addr)
+		}
+	}
+}
+
+func edges(es []topology.Edge) string {
+	if len(es) == 0 {
+		return "none"
+	}
+	s := make([]string, len(es))
+	for i, e := range es {
+		s[i] = e.From + " -- " + e.To
+	}
+	return strings.Join(s, ", ")
+}
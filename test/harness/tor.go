@@ -0,0 +1,126 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+	"github.com/OpenBazaar/openbazaar-go/test/onionsim"
+)
+
+// TorCheckout has the buyer fetch the vendor's listings and order from it
+// when either of them runs as an onion service of sim, see nodes.WithTor,
+// so Tor-only and dual-stack purchase flows can be compared. The order must
+// reach AWAITING_PAYMENT on both within settle, two minutes if zero, at
+// least one connection must have gone through sim, and a Tor-only node may
+// hold no connection at the clear address of another node.
+//
+// A Tor-only node can only bootstrap from onion addresses, so the node it
+// bootstraps from has to use Tor as well.
+func TorCheckout(sim *onionsim.Server, settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "tor-checkout",
+		Description: "a buyer orders from a vendor over onion services",
+		Requires:    []string{CapListings, CapOrders},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			if torMode(vendor) == nodes.NoTor && torMode(buyer) == nodes.NoTor {
+				return fmt.Errorf("scenario needs a vendor or buyer using Tor")
+			}
+			circuits := sim.Circuits()
+			slug, err := vendor.Client().CreateListing(fixtures.Listing())
+			if err != nil {
+				return err
+			}
+			hash, err := listingHash(vendor, slug)
+			if err != nil {
+				return err
+			}
+
+			wait, cancel := context.WithTimeout(ctx, settle)
+			defer cancel()
+			err = net.Step("tor/listings", func() error {
+				return poll(wait, func() error {
+					listings, err := buyer.Client().Listings(vendor.PeerID())
+					if err != nil {
+						return fmt.Errorf("%s (%s) fetching the listings of %s (%s): %s", buyer.Name(), torMode(buyer), vendor.Name(), torMode(vendor), err)
+					}
+					if len(listings) == 0 {
+						return fmt.Errorf("%s sees no listings of %s", buyer.Name(), vendor.Name())
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			err = net.Step("tor/purchase", func() error {
+				resp, err := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+				if err != nil {
+					return fmt.Errorf("purchase by %s: %s", buyer.Name(), err)
+				}
+				return WaitState(wait, resp.OrderID, "AWAITING_PAYMENT", buyer, vendor)
+			})
+			if err != nil {
+				return err
+			}
+
+			if sim.Circuits() == circuits {
+				return fmt.Errorf("no connection between %s and %s went through an onion service", buyer.Name(), vendor.Name())
+			}
+			for _, nd := range []Node{vendor, buyer} {
+				if torMode(nd) != nodes.TorOnly {
+					continue
+				}
+				if addr := clearPeer(net, nd); addr != "" {
+					return fmt.Errorf("%s is Tor-only but connected at the clear address %s", nd.Name(), addr)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// torMode is how the node uses Tor, NoTor if it cannot tell
+func torMode(n Node) nodes.Tor {
+	if o, ok := n.(Onioner); ok {
+		return o.Tor()
+	}
+	return nodes.NoTor
+}
+
+// clearPeer returns a connection the node holds at a clear swarm address of
+// another node of the network, if it holds one
+func clearPeer(net *Network, n Node) string {
+	peers, err := n.Client().Peers()
+	if err != nil {
+		return ""
+	}
+	for _, other := range net.Nodes {
+		a, ok := other.(Addresser)
+		if !ok || other == n {
+			continue
+		}
+		for _, own := range a.SwarmAddrs() {
+			if strings.HasPrefix(own, "/onion/") {
+				continue
+			}
+			for _, addr := range peers {
+				if addr == own || strings.HasPrefix(own, addr+"/") {
+					return addr
+				}
+			}
+		}
+	}
+	return ""
+}
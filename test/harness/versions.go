@@ -0,0 +1,46 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MixedVersions runs a direct order to COMPLETED between every vendor and
+// buyer that run different openbazaard releases, as told by their clients'
+// Version, so protocol changes that break older peers are caught. The
+// network must hold at least one such pair, e.g. nodes spawned
+// nodes.WithVersion from a manager with several registered binaries.
+func MixedVersions(settle time.Duration) Scenario {
+	if settle == 0 {
+		settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "mixed-versions",
+		Description: "vendors and buyers on different releases complete orders with each other",
+		Run: func(ctx context.Context, net *Network) error {
+			pairs := 0
+			for _, vendor := range net.Role("vendor") {
+				for _, buyer := range net.Role("buyer") {
+					v, b := vendor.Client().Version, buyer.Client().Version
+					if v == "" || b == "" || v == b {
+						continue
+					}
+					pairs++
+					step := fmt.Sprintf("%s vendor, %s buyer", v, b)
+					err := net.Step(step, func() error {
+						_, err := CompleteOrder(ctx, vendor, buyer, settle)
+						return err
+					})
+					if err != nil {
+						return fmt.Errorf("%s (%s) selling to %s (%s): %s", vendor.Name(), v, buyer.Name(), b, err)
+					}
+				}
+			}
+			if pairs == 0 {
+				return fmt.Errorf("no vendor and buyer on different releases")
+			}
+			return nil
+		},
+	}
+}
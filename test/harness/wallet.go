@@ -0,0 +1,168 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// walletQuiet is how long a wallet-only node is given to show a chat message
+// it must never get
+const walletQuiet = 15 * time.Second
+
+// WalletOnlyOptions configures the wallet-only scenario
+type WalletOnlyOptions struct {
+	// Fund sends bitcoin to an address and mines it, e.g.
+	// regtest.Bitcoind.Fund. It is required.
+	Fund func(address string, btc float64) error
+
+	// Settle bounds how long wallets may take to catch up, two minutes if
+	// zero
+	Settle time.Duration
+}
+
+// WalletOnly checks a node of role wallet, started with
+// nodes.WithWalletOnly, keeps its wallet consistent without the marketplace
+// running. Funds sent to it must show up, a spend to another node must
+// reach that node and leave the wallet with less, and a restart must keep
+// the balance. A chat message from the other node must never arrive, since
+// nothing handles marketplace messages.
+func WalletOnly(opts WalletOnlyOptions) Scenario {
+	if opts.Settle == 0 {
+		opts.Settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "wallet-only",
+		Description: "a node with the marketplace disabled keeps a consistent wallet",
+		Restarts:    true,
+		Requires:    []string{CapChat},
+		Run: func(ctx context.Context, net *Network) error {
+			if opts.Fund == nil {
+				return fmt.Errorf("scenario needs a way to fund wallets")
+			}
+			wallets := net.Role("wallet")
+			if len(wallets) == 0 {
+				return fmt.Errorf("scenario needs a node of role wallet")
+			}
+			wallet := wallets[0]
+			var peer Node
+			for _, nd := range net.Nodes {
+				if nd.Role() != "wallet" {
+					peer = nd
+					break
+				}
+			}
+			if peer == nil {
+				return fmt.Errorf("scenario needs a node with the marketplace running")
+			}
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+
+			var funded int64
+			err := net.Step("wallet/fund", func() error {
+				before, err := wallet.Client().Balance()
+				if err != nil {
+					return err
+				}
+				addr, err := wallet.Client().WalletAddress()
+				if err != nil {
+					return err
+				}
+				if err := opts.Fund(addr, 1); err != nil {
+					return fmt.Errorf("funding %s: %s", wallet.Name(), err)
+				}
+				return poll(wait, func() error {
+					b, err := wallet.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if funded = b.Total(); funded < before.Total()+1e8 {
+						return fmt.Errorf("wallet of %s holds %d, expected %d once funded", wallet.Name(), funded, before.Total()+1e8)
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			const spent = 1e7
+			err = net.Step("wallet/spend", func() error {
+				before, err := peer.Client().Balance()
+				if err != nil {
+					return err
+				}
+				addr, err := peer.Client().WalletAddress()
+				if err != nil {
+					return err
+				}
+				if err := wallet.Client().Spend(addr, spent); err != nil {
+					return fmt.Errorf("spending from %s: %s", wallet.Name(), err)
+				}
+				return poll(wait, func() error {
+					got, err := peer.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if got.Total() < before.Total()+spent {
+						return fmt.Errorf("wallet of %s holds %d, expected %d from %s", peer.Name(), got.Total(), before.Total()+spent, wallet.Name())
+					}
+					left, err := wallet.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if left.Total() > funded-spent {
+						return fmt.Errorf("wallet of %s still holds %d after spending %d of %d", wallet.Name(), left.Total(), int64(spent), funded)
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			if r, ok := wallet.(Restarter); ok {
+				err = net.Step("wallet/restart", func() error {
+					before, err := wallet.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if err := r.Restart(ctx); err != nil {
+						return err
+					}
+					return poll(wait, func() error {
+						after, err := wallet.Client().Balance()
+						if err != nil {
+							return err
+						}
+						if after.Total() != before.Total() {
+							return fmt.Errorf("wallet of %s holds %d after restarting, %d before", wallet.Name(), after.Total(), before.Total())
+						}
+						return nil
+					})
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return net.Step("wallet/no-marketplace", func() error {
+				id, err := peer.Client().SendChat(wallet.PeerID(), "", "to a node without a marketplace")
+				if err != nil {
+					// The message could not even be queued, so it cannot
+					// arrive either
+					return nil
+				}
+				select {
+				case <-time.After(walletQuiet):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if isStored(wallet, peer.PeerID(), id) {
+					return fmt.Errorf("%s got chat message %s with its marketplace disabled", wallet.Name(), id)
+				}
+				return nil
+			})
+		},
+	}
+}
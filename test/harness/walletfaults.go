@@ -0,0 +1,197 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// refusedSend is the reason the ledger gives for the send the wallet-faults
+// scenario makes fail
+const refusedSend = "refused by the test ledger"
+
+// Ledger is the programmable backend of mock wallets, see ledger.Ledger
+type Ledger interface {
+	SetBalance(address string, sat int64) error
+	DelayConfirmations(d time.Duration)
+	FailSends(address, reason string) error
+}
+
+// WalletFaultsOptions configures the wallet-faults scenario
+type WalletFaultsOptions struct {
+	// Ledger is the one the wallets of the nodes run on, see
+	// nodes.WithMockWallet. It is required.
+	Ledger Ledger
+
+	// Stuck is how long a payment is held unconfirmed, 10 seconds if zero
+	Stuck time.Duration
+
+	// Settle bounds how long wallets may take to catch up, a minute if zero
+	Settle time.Duration
+}
+
+// WalletFaults checks how a node's wallet handles what a healthy network
+// rarely produces, on the first two nodes, whose wallets must run on the
+// ledger. A spend of more than the wallet holds must fail as insufficient
+// funds, and one the network refuses must fail with its reason, both
+// leaving the balance as it was. A payment that does not confirm must show
+// as unconfirmed on the payee for as long as it is stuck, and as confirmed
+// once it is not.
+func WalletFaults(opts WalletFaultsOptions) Scenario {
+	if opts.Stuck == 0 {
+		opts.Stuck = 10 * time.Second
+	}
+	if opts.Settle == 0 {
+		opts.Settle = time.Minute
+	}
+	return Scenario{
+		Name:        "wallet-faults",
+		Description: "a wallet handles insufficient funds, refused sends and stuck payments",
+		Run: func(ctx context.Context, net *Network) error {
+			if opts.Ledger == nil {
+				return fmt.Errorf("scenario needs the ledger of the wallets")
+			}
+			if len(net.Nodes) < 2 {
+				return fmt.Errorf("scenario needs two nodes")
+			}
+			payer, payee := net.Nodes[0], net.Nodes[1]
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+			defer opts.Ledger.DelayConfirmations(0)
+
+			var from, to string
+			err := net.Step("wallet-faults/addresses", func() error {
+				var err error
+				if from, err = payer.Client().WalletAddress(); err != nil {
+					return err
+				}
+				to, err = payee.Client().WalletAddress()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			defer opts.Ledger.FailSends(from, "")
+
+			// balance sets the balance of the payer and waits for it to show
+			balance := func(sat int64) error {
+				if err := opts.Ledger.SetBalance(from, sat); err != nil {
+					return err
+				}
+				return poll(wait, func() error {
+					b, err := payer.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if b.Confirmed != sat || b.Unconfirmed != 0 {
+						return fmt.Errorf("wallet of %s holds %d confirmed and %d unconfirmed, expected %d confirmed", payer.Name(), b.Confirmed, b.Unconfirmed, sat)
+					}
+					return nil
+				})
+			}
+			// refused spends amount expecting the payer to refuse with
+			// reason and keep its balance
+			refused := func(amount uint64, reason string) error {
+				before, err := payer.Client().Balance()
+				if err != nil {
+					return err
+				}
+				err = payer.Client().Spend(to, amount)
+				if err == nil {
+					return fmt.Errorf("%s spent %d it should not have", payer.Name(), amount)
+				}
+				if !strings.Contains(err.Error(), reason) {
+					return fmt.Errorf("expected %s to refuse with %q, got %s", payer.Name(), reason, err)
+				}
+				after, err := payer.Client().Balance()
+				if err != nil {
+					return err
+				}
+				if after != before {
+					return fmt.Errorf("wallet of %s went from %+v to %+v on a refused spend", payer.Name(), before, after)
+				}
+				return nil
+			}
+
+			err = net.Step("wallet-faults/insufficient-funds", func() error {
+				if err := balance(5e4); err != nil {
+					return err
+				}
+				return net.Expect("wallet-faults/overspend", func() error {
+					return refused(1e6, "insuffient funds")
+				})
+			})
+			if err != nil {
+				return err
+			}
+
+			err = net.Step("wallet-faults/refused", func() error {
+				if err := balance(1e8); err != nil {
+					return err
+				}
+				if err := opts.Ledger.FailSends(from, refusedSend); err != nil {
+					return err
+				}
+				err := net.Expect("wallet-faults/refused-spend", func() error {
+					return refused(1e6, refusedSend)
+				})
+				if err != nil {
+					return err
+				}
+				return opts.Ledger.FailSends(from, "")
+			})
+			if err != nil {
+				return err
+			}
+
+			const paid = 1e6
+			return net.Step("wallet-faults/stuck", func() error {
+				before, err := payee.Client().Balance()
+				if err != nil {
+					return err
+				}
+				opts.Ledger.DelayConfirmations(-1)
+				if err := payer.Client().Spend(to, paid); err != nil {
+					return fmt.Errorf("spending from %s: %s", payer.Name(), err)
+				}
+				err = poll(wait, func() error {
+					b, err := payee.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if b.Unconfirmed < before.Unconfirmed+paid {
+						return fmt.Errorf("wallet of %s holds %d unconfirmed, expected %d once paid", payee.Name(), b.Unconfirmed, before.Unconfirmed+paid)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				select {
+				case <-time.After(opts.Stuck):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				b, err := payee.Client().Balance()
+				if err != nil {
+					return err
+				}
+				if b.Confirmed != before.Confirmed || b.Unconfirmed < before.Unconfirmed+paid {
+					return fmt.Errorf("wallet of %s went from %+v to %+v while the payment was stuck", payee.Name(), before, b)
+				}
+				opts.Ledger.DelayConfirmations(0)
+				return poll(wait, func() error {
+					b, err := payee.Client().Balance()
+					if err != nil {
+						return err
+					}
+					if b.Confirmed < before.Confirmed+paid {
+						return fmt.Errorf("wallet of %s holds %d confirmed, expected %d once the payment confirmed", payee.Name(), b.Confirmed, before.Confirmed+paid)
+					}
+					return nil
+				})
+			})
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/peers"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// WithholdingOptions configures the withholding provider scenario
+type WithholdingOptions struct {
+	// Deadline is how long a buyer may take to answer a listing fetch
+	Deadline time.Duration
+
+	// BlockRate, when set, makes the rogue peer fetch the blocks and serve
+	// them at this many bytes per second instead of withholding them
+	BlockRate int
+
+	// VendorOffline stops the vendor before fetching, leaving the withholding
+	// peer as the only announced provider
+	VendorOffline bool
+}
+
+// WithholdingProvider has a rogue peer announce every listing of the first
+// vendor without ever serving the blocks, or serving them extremely slowly.
+// Buyers must still answer within the deadline, either with the listing
+// fetched from another provider or, when the vendor is offline, with a clean
+// not found.
+func WithholdingProvider(withholder *peers.Peer, opts WithholdingOptions) Scenario {
+	if opts.Deadline == 0 {
+		opts.Deadline = 90 * time.Second
+	}
+	return Scenario{
+		Name:        "withholding-provider",
+		Description: "a provider that never sends blocks must not stall buyers",
+		Stops:       opts.VendorOffline,
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			listings, err := vendor.Client().Listings("")
+			if err != nil {
+				return err
+			}
+			if len(listings) == 0 {
+				return fmt.Errorf("vendor %s has no listings to withhold", vendor.Name())
+			}
+			for _, l := range listings {
+				c, err := cid.Decode(l.Hash)
+				if err != nil {
+					return err
+				}
+				if opts.BlockRate > 0 {
+					withholder.SetBlockRate(opts.BlockRate)
+					if _, err := withholder.Node.Blocks.GetBlock(ctx, c); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := withholder.Withhold(ctx, c); err != nil {
+					return err
+				}
+			}
+			if opts.VendorOffline {
+				s, ok := vendor.(Stopper)
+				if !ok {
+					return fmt.Errorf("node %s cannot be stopped", vendor.Name())
+				}
+				if err := s.Stop(ctx); err != nil {
+					return err
+				}
+			}
+
+			c := buyer.Client().WithTimeout(opts.Deadline)
+			for _, l := range listings {
+				start := time.Now()
+				resp, err := c.Get("/ob/listing/" + vendor.PeerID() + "/" + l.Hash)
+				if err != nil {
+					return fmt.Errorf("buyer did not answer for %s within %s: %s", l.Slug, opts.Deadline, err)
+				}
+				switch {
+				case resp.StatusCode == http.StatusOK && !opts.VendorOffline:
+				case resp.StatusCode == http.StatusNotFound && opts.VendorOffline:
+				default:
+					return fmt.Errorf("fetching %s returned %d after %s: %s", l.Slug, resp.StatusCode, time.Since(start), resp.Body)
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,261 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// wsReader is the function every websocket subscriber of a node runs in
+const wsReader = "api.(*connection).reader"
+
+// WebsocketStormOptions configures WebsocketStorm
+type WebsocketStormOptions struct {
+	// Clients keep dropping and reopening their socket, 20 when zero
+	Clients int
+
+	// Hold is the longest a client keeps a socket open, 2s when zero
+	Hold time.Duration
+
+	// Orders are walked to COMPLETED during the storm, 2 when zero
+	Orders int
+
+	// Settle bounds each wait for orders, notifications and subscribers
+	Settle time.Duration
+}
+
+// WebsocketStorm has many clients drop and reopen the vendor's websocket
+// while orders progress. A witness socket held open throughout must receive
+// every notification the vendor stores, each storming connection must see
+// the notifications pushed while it was open without gaps or repeats, and
+// once every socket is closed the vendor must be back to the subscribers it
+// had before. Counting subscribers needs the vendor to serve its runtime
+// profiles, see nodes.WithProfiling.
+func WebsocketStorm(opts WebsocketStormOptions) Scenario {
+	if opts.Clients == 0 {
+		opts.Clients = 20
+	}
+	if opts.Hold == 0 {
+		opts.Hold = 2 * time.Second
+	}
+	if opts.Orders == 0 {
+		opts.Orders = 2
+	}
+	if opts.Settle == 0 {
+		opts.Settle = 2 * time.Minute
+	}
+	return Scenario{
+		Name:        "websocket-storm",
+		Description: "notifications survive websocket clients reconnecting in a storm and subscribers do not leak",
+		Requires:    []string{CapNotifications},
+		Run: func(ctx context.Context, net *Network) error {
+			vendor, buyer, err := VendorAndBuyer(net)
+			if err != nil {
+				return err
+			}
+			c := vendor.Client()
+			baseline, err := c.Goroutines(wsReader)
+			if err != nil {
+				return fmt.Errorf("counting websocket subscribers on %s: %s", vendor.Name(), err)
+			}
+			stored, _, err := c.Notifications()
+			if err != nil {
+				return err
+			}
+			before := make(map[string]bool)
+			for _, s := range stored {
+				before[s.ID] = true
+			}
+			ws, err := c.Subscribe()
+			if err != nil {
+				return err
+			}
+			witness := collect(ws)
+			witnessOpen := true
+			defer func() {
+				if witnessOpen {
+					witness.close()
+				}
+			}()
+
+			storm := &wsStorm{node: vendor, hold: opts.Hold, stop: make(chan struct{})}
+			for i := 0; i < opts.Clients; i++ {
+				storm.wg.Add(1)
+				go storm.client(rand.New(rand.NewSource(int64(i))))
+			}
+			err = net.Step("orders", func() error {
+				for i := 0; i < opts.Orders; i++ {
+					if _, err := CompleteOrder(ctx, vendor, buyer, opts.Settle); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			storm.halt()
+			if err != nil {
+				return err
+			}
+			if err := storm.firstErr(); err != nil {
+				return err
+			}
+
+			wait, cancel := context.WithTimeout(ctx, opts.Settle)
+			defer cancel()
+			err = poll(wait, func() error {
+				return witnessedAll(vendor, witness, before)
+			})
+			if err != nil {
+				return err
+			}
+			order := witness.arrived()
+			for i, s := range storm.sessions {
+				if err := s.check(order); err != nil {
+					return fmt.Errorf("storm connection %d: %s", i, err)
+				}
+			}
+
+			witness.close()
+			witnessOpen = false
+			return poll(wait, func() error {
+				n, err := c.Goroutines(wsReader)
+				if err != nil {
+					return err
+				}
+				if n > baseline {
+					return fmt.Errorf("%s has %d websocket subscribers after the storm, %d before", vendor.Name(), n, baseline)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// witnessedAll returns an error unless the witness received exactly the
+// notifications the node stored since before
+func witnessedAll(n Node, witness *pushed, before map[string]bool) error {
+	stored, _, err := n.Client().Notifications()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, p := range witness.notifications() {
+		if p.ID != "" {
+			seen[p.ID] = true
+		}
+	}
+	for _, s := range stored {
+		if !before[s.ID] && !seen[s.ID] {
+			return fmt.Errorf("%s notification %s stored on %s but lost on the websocket", s.Type, s.ID, n.Name())
+		}
+		delete(seen, s.ID)
+	}
+	for id := range seen {
+		return fmt.Errorf("notification %s pushed by %s but never stored", id, n.Name())
+	}
+	return nil
+}
+
+// wsStorm is a set of clients reconnecting to one node until halted
+type wsStorm struct {
+	node Node
+	hold time.Duration
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lock     sync.Mutex
+	sessions []wsSession
+	err      error
+}
+
+// wsSession is what one storming connection received, in order
+type wsSession []string
+
+func (s *wsStorm) client(r *rand.Rand) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		ws, err := s.node.Client().Subscribe()
+		if err != nil {
+			s.fail(fmt.Errorf("reconnecting to %s: %s", s.node.Name(), err))
+			return
+		}
+		var session wsSession
+		deadline := time.Now().Add(time.Duration(r.Int63n(int64(s.hold))) + time.Millisecond)
+		for time.Now().Before(deadline) {
+			n, err := ws.NextNotification(time.Until(deadline))
+			if err != nil {
+				break
+			}
+			if n.ID != "" {
+				session = append(session, n.ID)
+			}
+		}
+		ws.Close()
+		s.lock.Lock()
+		s.sessions = append(s.sessions, session)
+		s.lock.Unlock()
+	}
+}
+
+func (s *wsStorm) fail(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *wsStorm) firstErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *wsStorm) halt() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// check returns an error if the session repeated a notification, or missed
+// one the witness received between the first and last the session got
+func (s wsSession) check(order []client.Notification) error {
+	if len(s) == 0 {
+		return nil
+	}
+	got := make(map[string]bool)
+	for _, id := range s {
+		if got[id] {
+			return fmt.Errorf("notification %s received twice", id)
+		}
+		got[id] = true
+	}
+	first, last := -1, -1
+	for i, n := range order {
+		if n.ID == s[0] {
+			first = i
+		}
+		if n.ID == s[len(s)-1] {
+			last = i
+		}
+	}
+	if first < 0 || last < 0 {
+		return fmt.Errorf("received notifications the witness never got")
+	}
+	if last < first {
+		return fmt.Errorf("received %s before %s, the witness got them the other way round", s[0], s[len(s)-1])
+	}
+	for _, n := range order[first : last+1] {
+		if !got[n.ID] {
+			return fmt.Errorf("notification %s missed while connected", n.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package identity replaces the identity key of a stopped node's repo while
+// keeping everything else in it
+package identity
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"github.com/OpenBazaar/openbazaar-go/ipfs"
+	"github.com/OpenBazaar/openbazaar-go/repo"
+	"github.com/OpenBazaar/openbazaar-go/repo/db"
+)
+
+type keySetter interface {
+	SetIdentityKey(identityKey []byte) error
+}
+
+// Rotate generates a fresh identity key for the repo at repoPath and returns
+// the peer ID the node will have once it is started again. The node must not
+// be running.
+func Rotate(repoPath, password string, testnet bool) (string, error) {
+	sqliteDB, err := db.Create(repoPath, password, testnet)
+	if err != nil {
+		return "", err
+	}
+	defer sqliteDB.Close()
+
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	key, err := ipfs.IdentityKeyFromSeed(seed, 4096)
+	if err != nil {
+		return "", err
+	}
+	identity, err := ipfs.IdentityFromKey(key)
+	if err != nil {
+		return "", err
+	}
+	setter, ok := sqliteDB.Config().(keySetter)
+	if !ok {
+		return "", errors.New("identity: datastore cannot replace the identity key")
+	}
+	if err := setter.SetIdentityKey(key); err != nil {
+		return "", err
+	}
+	if err := repo.InitializeIpnsKeyspace(repoPath, key); err != nil {
+		return "", err
+	}
+	return identity.PeerID, nil
+}
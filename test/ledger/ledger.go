@@ -0,0 +1,514 @@
+// Package ledger runs the stand-in for the bitcoin network that nodes
+// started with nodes.WithMockWallet keep their wallets on. Tests program it:
+// set what a wallet holds, send it coins, hold back or force confirmations
+// and refuse a wallet's sends, so insufficient funds and stuck payments can
+// be tested without a chain. It checks no signatures; an input is good if it
+// spends an output that exists and is unspent.
+package ledger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/bitcoin/mockwallet"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	btc "github.com/btcsuite/btcutil"
+)
+
+// mineInterval is how often the ledger mines the transactions that are due
+const mineInterval = 50 * time.Millisecond
+
+// Params are the network addresses are decoded for, the one nodes started
+// with nodes.WithMockWallet run on
+var Params = &chaincfg.RegressionNetParams
+
+// Ledger is a running ledger
+type Ledger struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+
+	lock     sync.Mutex
+	height   int32
+	txs      map[chainhash.Hash]*entry
+	order    []chainhash.Hash
+	outputs  map[wire.OutPoint]*output
+	wallets  map[string]*wallet
+	failures map[string]string
+	delay    time.Duration
+	minted   uint32
+}
+
+// entry is a transaction the ledger took
+type entry struct {
+	tx       *wire.MsgTx
+	raw      string
+	height   int32
+	seen     time.Time
+	confirm  bool
+	prevouts []mockwallet.Prevout
+}
+
+type output struct {
+	script string
+	value  int64
+	spent  bool
+}
+
+// wallet holds the scripts a wallet registered, hex encoded
+type wallet struct {
+	own   map[string]bool
+	watch map[string]bool
+}
+
+// Start runs a ledger on a free port of host, 127.0.0.1 when empty. Its
+// transactions confirm as soon as they are taken until DelayConfirmations
+// says otherwise.
+func Start(host string) (*Ledger, error) {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{
+		listener: ln,
+		done:     make(chan struct{}),
+		txs:      make(map[chainhash.Hash]*entry),
+		outputs:  make(map[wire.OutPoint]*output),
+		wallets:  make(map[string]*wallet),
+		failures: make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scripts", l.handleScripts)
+	mux.HandleFunc("/utxos", l.handleUtxos)
+	mux.HandleFunc("/txs", l.handleTxs)
+	mux.HandleFunc("/broadcast", l.handleBroadcast)
+	mux.HandleFunc("/tip", l.handleTip)
+	l.server = &http.Server{Handler: mux}
+	go l.server.Serve(ln)
+	go l.miner()
+	return l, nil
+}
+
+// Addr is the host:port wallets reach the ledger at
+func (l *Ledger) Addr() string {
+	return l.listener.Addr().String()
+}
+
+// Wallet returns the option running a node's wallet on the ledger
+func (l *Ledger) Wallet() nodes.Option {
+	return nodes.WithMockWallet(l.Addr())
+}
+
+// Close stops the ledger
+func (l *Ledger) Close() error {
+	close(l.done)
+	return l.server.Close()
+}
+
+// SetBalance makes the wallet owning address hold exactly sat, confirmed,
+// all of it paid to address. The coins the wallet held before are spent
+// away or topped up in one transaction, mined at once. An address no wallet
+// registered is set on its own.
+func (l *Ledger) SetBalance(address string, sat int64) error {
+	script, err := addressScript(address)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	scripts := map[string]bool{script: true}
+	for _, w := range l.wallets {
+		if w.own[script] {
+			scripts = w.own
+			break
+		}
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	var held int64
+	for _, op := range l.unspent(scripts) {
+		tx.AddTxIn(wire.NewTxIn(op, nil, nil))
+		held += l.outputs[*op].value
+	}
+	if held < sat {
+		tx.AddTxIn(l.mint())
+	}
+	pkScript, _ := hex.DecodeString(script)
+	if sat > 0 {
+		tx.AddTxOut(wire.NewTxOut(sat, pkScript))
+	}
+	if len(tx.TxIn) == 0 {
+		return nil
+	}
+	e := l.take(tx)
+	e.confirm = true
+	l.mine()
+	return nil
+}
+
+// Send sends sat to address from outside any wallet, making the ledger a
+// harness.Faucet
+func (l *Ledger) Send(address string, sat int64) error {
+	_, err := l.Inject(address, sat)
+	return err
+}
+
+// Inject sends sat to address from outside any wallet and returns the
+// txid. The transaction confirms like those the wallets send.
+func (l *Ledger) Inject(address string, sat int64) (string, error) {
+	script, err := addressScript(address)
+	if err != nil {
+		return "", err
+	}
+	pkScript, _ := hex.DecodeString(script)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	tx.AddTxIn(l.mint())
+	tx.AddTxOut(wire.NewTxOut(sat, pkScript))
+	e := l.take(tx)
+	return e.tx.TxHash().String(), nil
+}
+
+// DelayConfirmations mines transactions d after they were taken, pending
+// ones included. A negative d leaves them unconfirmed until Confirm or
+// another delay.
+func (l *Ledger) DelayConfirmations(d time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.delay = d
+}
+
+// Confirm mines a block with the transaction, whatever the delay
+func (l *Ledger) Confirm(txid string) error {
+	h, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e, ok := l.txs[*h]
+	if !ok {
+		return fmt.Errorf("no transaction %s", txid)
+	}
+	e.confirm = true
+	l.mine()
+	return nil
+}
+
+// Mine mines n blocks, the first with the transactions that are due
+func (l *Ledger) Mine(n int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i := 0; i < n; i++ {
+		l.mine()
+	}
+}
+
+// Confirmations returns how deep the transaction is buried, 0 while it is
+// unconfirmed
+func (l *Ledger) Confirmations(txid string) (int32, error) {
+	h, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return 0, err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	e, ok := l.txs[*h]
+	if !ok {
+		return 0, fmt.Errorf("no transaction %s", txid)
+	}
+	if e.height == 0 {
+		return 0, nil
+	}
+	return l.height - e.height + 1, nil
+}
+
+// FailSends refuses the transactions of the wallet owning address with
+// reason, which the wallet returns as its error. An empty reason lets them
+// through again.
+func (l *Ledger) FailSends(address, reason string) error {
+	script, err := addressScript(address)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if reason == "" {
+		delete(l.failures, script)
+	} else {
+		l.failures[script] = reason
+	}
+	return nil
+}
+
+func addressScript(address string) (string, error) {
+	addr, err := btc.DecodeAddress(address, Params)
+	if err != nil {
+		return "", err
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(script), nil
+}
+
+// mint returns an input creating coins, unique so the transactions it
+// funds are. The lock must be held.
+func (l *Ledger) mint() *wire.TxIn {
+	l.minted++
+	nonce := make([]byte, 4)
+	binary.BigEndian.PutUint32(nonce, l.minted)
+	return wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), nonce, nil)
+}
+
+// unspent returns the unspent outputs paying any of scripts. The lock must
+// be held.
+func (l *Ledger) unspent(scripts map[string]bool) []*wire.OutPoint {
+	var ret []*wire.OutPoint
+	for _, h := range l.order {
+		for i, out := range l.txs[h].tx.TxOut {
+			op := wire.NewOutPoint(&h, uint32(i))
+			if o := l.outputs[*op]; !o.spent && scripts[hex.EncodeToString(out.PkScript)] {
+				ret = append(ret, op)
+			}
+		}
+	}
+	return ret
+}
+
+// check returns why tx cannot be taken from the wallet with id, nil if it
+// can. The lock must be held.
+func (l *Ledger) check(id string, tx *wire.MsgTx) error {
+	if w, ok := l.wallets[id]; ok {
+		for script := range w.own {
+			if reason, ok := l.failures[script]; ok {
+				return errors.New(reason)
+			}
+		}
+	}
+	if len(tx.TxIn) == 0 {
+		return errors.New("transaction has no inputs")
+	}
+	var in, out int64
+	for _, txIn := range tx.TxIn {
+		o, ok := l.outputs[txIn.PreviousOutPoint]
+		switch {
+		case !ok:
+			return fmt.Errorf("input %s does not exist", txIn.PreviousOutPoint)
+		case o.spent:
+			return fmt.Errorf("input %s is spent", txIn.PreviousOutPoint)
+		}
+		in += o.value
+	}
+	for _, txOut := range tx.TxOut {
+		out += txOut.Value
+	}
+	if out > in {
+		return fmt.Errorf("outputs of %d exceed inputs of %d", out, in)
+	}
+	return nil
+}
+
+// take records tx as pending, spending its inputs. The lock must be held.
+func (l *Ledger) take(tx *wire.MsgTx) *entry {
+	var buf bytes.Buffer
+	tx.Serialize(&buf)
+	e := &entry{tx: tx, raw: hex.EncodeToString(buf.Bytes()), seen: time.Now()}
+	for _, txIn := range tx.TxIn {
+		prev := mockwallet.Prevout{}
+		if o, ok := l.outputs[txIn.PreviousOutPoint]; ok {
+			o.spent = true
+			prev = mockwallet.Prevout{Script: o.script, Value: o.value}
+		}
+		e.prevouts = append(e.prevouts, prev)
+	}
+	h := tx.TxHash()
+	for i, txOut := range tx.TxOut {
+		l.outputs[*wire.NewOutPoint(&h, uint32(i))] = &output{script: hex.EncodeToString(txOut.PkScript), value: txOut.Value}
+	}
+	l.txs[h] = e
+	l.order = append(l.order, h)
+	return e
+}
+
+// due tells whether a pending transaction goes into the next block. The
+// lock must be held.
+func (l *Ledger) due(e *entry) bool {
+	if e.height > 0 {
+		return false
+	}
+	return e.confirm || (l.delay >= 0 && !time.Now().Before(e.seen.Add(l.delay)))
+}
+
+// mine mines a block with the transactions that are due. The lock must be
+// held.
+func (l *Ledger) mine() {
+	l.height++
+	for _, e := range l.txs {
+		if l.due(e) {
+			e.height = l.height
+		}
+	}
+}
+
+// miner mines a block whenever a transaction is due
+func (l *Ledger) miner() {
+	t := time.NewTicker(mineInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-t.C:
+		}
+		l.lock.Lock()
+		for _, e := range l.txs {
+			if l.due(e) {
+				l.mine()
+				break
+			}
+		}
+		l.lock.Unlock()
+	}
+}
+
+// hash is the made up hash of the block at height
+func hash(height int32) chainhash.Hash {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(height))
+	return chainhash.Hash(sha256.Sum256(b))
+}
+
+func (l *Ledger) handleScripts(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if r.Method == "GET" {
+		var scripts []string
+		if wl, ok := l.wallets[r.URL.Query().Get("wallet")]; ok {
+			for s := range wl.own {
+				scripts = append(scripts, s)
+			}
+		}
+		reply(w, scripts)
+		return
+	}
+	var s mockwallet.Script
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wl, ok := l.wallets[s.Wallet]
+	if !ok {
+		wl = &wallet{own: make(map[string]bool), watch: make(map[string]bool)}
+		l.wallets[s.Wallet] = wl
+	}
+	if s.Own {
+		wl.own[s.Script] = true
+	} else {
+		wl.watch[s.Script] = true
+	}
+}
+
+func (l *Ledger) handleUtxos(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	utxos := []mockwallet.Utxo{}
+	if wl, ok := l.wallets[r.URL.Query().Get("wallet")]; ok {
+		for _, op := range l.unspent(wl.own) {
+			o := l.outputs[*op]
+			utxos = append(utxos, mockwallet.Utxo{
+				Txid:   op.Hash.String(),
+				Index:  op.Index,
+				Value:  o.value,
+				Script: o.script,
+				Height: l.txs[op.Hash].height,
+			})
+		}
+	}
+	reply(w, utxos)
+}
+
+func (l *Ledger) handleTxs(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	txs := []mockwallet.Tx{}
+	wl, ok := l.wallets[r.URL.Query().Get("wallet")]
+	if !ok {
+		reply(w, txs)
+		return
+	}
+	touches := func(script string) bool {
+		return wl.own[script] || wl.watch[script]
+	}
+	for _, h := range l.order {
+		e := l.txs[h]
+		relevant := false
+		for _, out := range e.tx.TxOut {
+			relevant = relevant || touches(hex.EncodeToString(out.PkScript))
+		}
+		for _, prev := range e.prevouts {
+			relevant = relevant || touches(prev.Script)
+		}
+		if relevant {
+			txs = append(txs, mockwallet.Tx{Txid: h.String(), Raw: e.raw, Height: e.height, Time: e.seen, Prevouts: e.prevouts})
+		}
+	}
+	reply(w, txs)
+}
+
+func (l *Ledger) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var b mockwallet.Broadcast
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, err := hex.DecodeString(b.Raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.txs[tx.TxHash()]; ok {
+		return
+	}
+	if err := l.check(b.Wallet, tx); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	l.take(tx)
+}
+
+func (l *Ledger) handleTip(w http.ResponseWriter, r *http.Request) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	h := hash(l.height)
+	reply(w, mockwallet.Tip{Height: uint32(l.height), Hash: h.String()})
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,163 @@
+package ledger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/bitcoin/mockwallet"
+	"github.com/OpenBazaar/spvwallet"
+)
+
+const (
+	mnemonicA = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	mnemonicB = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+)
+
+func start(t *testing.T) *Ledger {
+	l, err := Start("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// wait polls until the balance of w is confirmed and unconfirmed
+func wait(t *testing.T, w *mockwallet.MockWallet, confirmed, unconfirmed int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, u := w.Balance()
+		if c == confirmed && u == unconfirmed {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a balance of %d confirmed and %d unconfirmed, got %d and %d", confirmed, unconfirmed, c, u)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSetBalance(t *testing.T) {
+	l := start(t)
+	defer l.Close()
+	w := mockwallet.NewMockWallet(mnemonicA, Params, l.Addr())
+	addr := w.CurrentAddress(spvwallet.EXTERNAL).String()
+
+	if err := l.SetBalance(addr, 1e8); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, w, 1e8, 0)
+	change := w.NewAddress(spvwallet.EXTERNAL).String()
+	if _, err := l.Inject(change, 5e7); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, w, 15e7, 0)
+
+	// Setting the balance of one address sets the wallet's
+	if err := l.SetBalance(addr, 2e7); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, w, 2e7, 0)
+
+	// A restarted wallet finds the addresses it handed out
+	restarted := mockwallet.NewMockWallet(mnemonicA, Params, l.Addr())
+	if got := restarted.CurrentAddress(spvwallet.EXTERNAL).String(); got != change {
+		t.Errorf("Expected the restarted wallet to be at %s, got %s", change, got)
+	}
+	wait(t, restarted, 2e7, 0)
+}
+
+func TestSpend(t *testing.T) {
+	l := start(t)
+	defer l.Close()
+	a := mockwallet.NewMockWallet(mnemonicA, Params, l.Addr())
+	b := mockwallet.NewMockWallet(mnemonicB, Params, l.Addr())
+	received := make(chan spvwallet.TransactionCallback, 10)
+	b.AddTransactionListener(func(cb spvwallet.TransactionCallback) {
+		received <- cb
+	})
+	b.Start()
+	defer b.Close()
+	if err := l.SetBalance(a.CurrentAddress(spvwallet.EXTERNAL).String(), 1e6); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Spend(2e6, b.CurrentAddress(spvwallet.EXTERNAL), spvwallet.NORMAL); err != mockwallet.ErrInsufficientFunds {
+		t.Errorf("Expected spending more than the wallet holds to fail, got %v", err)
+	}
+
+	l.DelayConfirmations(-1)
+	txid, err := a.Spend(5e5, b.CurrentAddress(spvwallet.EXTERNAL), spvwallet.NORMAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait(t, b, 0, 5e5)
+	confirmed, unconfirmed := a.Balance()
+	if confirmed != 0 || unconfirmed <= 0 || unconfirmed >= 5e5 {
+		t.Errorf("Expected the change less the fee to be unconfirmed, got %d and %d", confirmed, unconfirmed)
+	}
+	select {
+	case cb := <-received:
+		if !bytes.Equal(cb.Txid, txid.CloneBytes()) || cb.Value != 5e5 || cb.Height != 0 {
+			t.Errorf("Expected an unconfirmed payment of 500000, got %+v", cb)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the payee to be told of the payment")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if confirms, _, err := b.GetConfirmations(*txid); err != nil || confirms != 0 {
+		t.Errorf("Expected the payment to be stuck, got %d confirmations, %v", confirms, err)
+	}
+	if err := l.Confirm(txid.String()); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, b, 5e5, 0)
+	select {
+	case cb := <-received:
+		if cb.Height == 0 {
+			t.Errorf("Expected the payee to be told of the confirmation, got %+v", cb)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the payee to be told of the confirmation")
+	}
+	l.Mine(2)
+	if confirms, _, err := b.GetConfirmations(*txid); err != nil || confirms != 3 {
+		t.Errorf("Expected 3 confirmations, got %d, %v", confirms, err)
+	}
+
+	// Pending transactions confirm once the delay is lifted
+	txid, err = a.Spend(1e5, b.CurrentAddress(spvwallet.EXTERNAL), spvwallet.NORMAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.DelayConfirmations(0)
+	wait(t, b, 6e5, 0)
+}
+
+func TestFailSends(t *testing.T) {
+	l := start(t)
+	defer l.Close()
+	a := mockwallet.NewMockWallet(mnemonicA, Params, l.Addr())
+	b := mockwallet.NewMockWallet(mnemonicB, Params, l.Addr())
+	addr := a.CurrentAddress(spvwallet.EXTERNAL).String()
+	if err := l.SetBalance(addr, 1e6); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.FailSends(addr, "node is offline"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Spend(5e5, b.CurrentAddress(spvwallet.EXTERNAL), spvwallet.NORMAL); err == nil || !strings.Contains(err.Error(), "node is offline") {
+		t.Errorf("Expected the send to be refused, got %v", err)
+	}
+	wait(t, a, 1e6, 0)
+
+	if err := l.FailSends(addr, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Spend(5e5, b.CurrentAddress(spvwallet.EXTERNAL), spvwallet.NORMAL); err != nil {
+		t.Errorf("Expected the send to go through again, got %v", err)
+	}
+	wait(t, b, 5e5, 0)
+}
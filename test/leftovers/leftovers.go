@@ -0,0 +1,299 @@
+// Package leftovers finds what test runs left behind on the host: node
+// processes, docker containers and networks, network namespaces, firewall
+// and traffic control rules and temp dirs. A snapshot taken after a run is
+// compared with one taken before, so what was there already is not blamed
+// on the run.
+package leftovers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of things a run can leave behind
+const (
+	Process   = "process"
+	Container = "container"
+	Network   = "network"
+	Namespace = "netns"
+	Rule      = "rule"
+	TempDir   = "temp dir"
+)
+
+// TempPrefixes are the prefixes of the temp dirs the harness, simulator and
+// tools make
+var TempPrefixes = []string{"testnodes", "sim", "peer-datastore", "k8s-config", "ob-startup-", "ob-migration-"}
+
+// Options says where to look
+type Options struct {
+	// Docker is the docker CLI, docker on the PATH when empty
+	Docker string
+
+	// Networks is the name prefix of the docker networks runs create,
+	// testnodes when empty
+	Networks string
+
+	// TempDir is where runs make their temp dirs, os.TempDir() when empty
+	TempDir string
+
+	// proc is where the proc filesystem is mounted, /proc when empty
+	proc string
+}
+
+// Item is one thing found on the host
+type Item struct {
+	Kind string
+	Name string
+}
+
+// Snapshot is what was found on the host at one time
+type Snapshot struct {
+	Items []Item
+
+	// Skipped are the kinds that could not be looked for and why, e.g.
+	// docker missing or iptables needing root
+	Skipped map[string]string
+}
+
+// Take looks for every kind of leftover, skipping the kinds it cannot
+// look for
+func Take(ctx context.Context, o Options) *Snapshot {
+	if o.Docker == "" {
+		o.Docker = "docker"
+	}
+	if o.Networks == "" {
+		o.Networks = "testnodes"
+	}
+	if o.TempDir == "" {
+		o.TempDir = os.TempDir()
+	}
+	if o.proc == "" {
+		o.proc = "/proc"
+	}
+	s := &Snapshot{Skipped: make(map[string]string)}
+	for _, probe := range []struct {
+		kind string
+		find func() ([]string, error)
+	}{
+		{Process, func() ([]string, error) { return processes(o.proc) }},
+		{Container, func() ([]string, error) {
+			return lines(ctx, o.Docker, "ps", "-a", "--filter", "name=openbazaard-", "--format", "{{.Names}}")
+		}},
+		{Network, func() ([]string, error) {
+			return lines(ctx, o.Docker, "network", "ls", "--filter", "name="+o.Networks, "--format", "{{.Name}}")
+		}},
+		{Namespace, namespaces},
+		{Rule, func() ([]string, error) { return rules(ctx) }},
+		{TempDir, func() ([]string, error) { return tempDirs(o.TempDir) }},
+	} {
+		names, err := probe.find()
+		if err != nil {
+			s.Skipped[probe.kind] = err.Error()
+			continue
+		}
+		for _, name := range names {
+			s.Items = append(s.Items, Item{probe.kind, name})
+		}
+	}
+	return s
+}
+
+// Since returns what the snapshot has that before did not. Kinds either
+// skipped stay skipped.
+func (s *Snapshot) Since(before *Snapshot) *Snapshot {
+	had := make(map[Item]bool)
+	for _, it := range before.Items {
+		had[it] = true
+	}
+	ret := &Snapshot{Skipped: make(map[string]string)}
+	for kind, why := range before.Skipped {
+		ret.Skipped[kind] = why
+	}
+	for kind, why := range s.Skipped {
+		ret.Skipped[kind] = why
+	}
+	for _, it := range s.Items {
+		if !had[it] && ret.Skipped[it.Kind] == "" {
+			ret.Items = append(ret.Items, it)
+		}
+	}
+	return ret
+}
+
+// Err fails if anything was found
+func (s *Snapshot) Err() error {
+	if len(s.Items) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	var kinds []string
+	for _, it := range s.Items {
+		if counts[it.Kind] == 0 {
+			kinds = append(kinds, it.Kind)
+		}
+		counts[it.Kind]++
+	}
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[kind], kind)
+	}
+	return fmt.Errorf("leftovers: left behind %s", strings.Join(parts, ", "))
+}
+
+// Write prints a cleanup report: one line for everything found and one for
+// every kind skipped
+func (s *Snapshot) Write(w io.Writer) error {
+	if len(s.Items) == 0 {
+		if _, err := fmt.Fprintln(w, "cleanup: nothing left behind"); err != nil {
+			return err
+		}
+	} else if _, err := fmt.Fprintf(w, "cleanup: %d left behind\n", len(s.Items)); err != nil {
+		return err
+	}
+	for _, it := range s.Items {
+		if _, err := fmt.Fprintf(w, "  %-10s %s\n", it.Kind, it.Name); err != nil {
+			return err
+		}
+	}
+	var skipped []string
+	for kind := range s.Skipped {
+		skipped = append(skipped, kind)
+	}
+	sort.Strings(skipped)
+	for _, kind := range skipped {
+		if _, err := fmt.Fprintf(w, "  %-10s not checked: %s\n", kind, s.Skipped[kind]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines runs a command and returns the non-empty lines it prints
+func lines(ctx context.Context, name string, args ...string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	var ret []string
+	for _, l := range strings.Split(string(out), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			ret = append(ret, l)
+		}
+	}
+	return ret, nil
+}
+
+// processes returns the openbazaard and bitcoind processes as their PID and
+// command line
+func processes(proc string) ([]string, error) {
+	entries, err := ioutil.ReadDir(proc)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join(proc, e.Name(), "cmdline"))
+		if err != nil {
+			// exited since
+			continue
+		}
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		if base := filepath.Base(args[0]); !strings.HasPrefix(base, "openbazaard") && base != "bitcoind" {
+			continue
+		}
+		ret = append(ret, e.Name()+" "+strings.Join(args, " "))
+	}
+	return ret, nil
+}
+
+// netnsDirs are where ip netns and docker keep network namespaces
+var netnsDirs = []string{"/var/run/netns", "/var/run/docker/netns"}
+
+// namespaces returns the named network namespaces
+func namespaces() ([]string, error) {
+	var ret []string
+	for _, dir := range netnsDirs {
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			ret = append(ret, filepath.Join(dir, e.Name()))
+		}
+	}
+	return ret, nil
+}
+
+// rules returns the iptables rules and the qdiscs on loopback, which
+// netem.Impair shapes
+func rules(ctx context.Context) ([]string, error) {
+	saved, err := lines(ctx, "iptables-save")
+	if err != nil {
+		return nil, err
+	}
+	qdiscs, err := lines(ctx, "tc", "qdisc", "show", "dev", "lo")
+	if err != nil {
+		return nil, err
+	}
+	ret := iptablesRules(saved)
+	for _, q := range qdiscs {
+		ret = append(ret, "tc "+q)
+	}
+	return ret, nil
+}
+
+// counters are the packet and byte counts iptables-save prints with chains
+var counters = regexp.MustCompile(` \[\d+:\d+\]$`)
+
+// iptablesRules keeps the tables, chains and rules of iptables-save output,
+// without comments and counters so unchanged rules compare equal
+func iptablesRules(saved []string) []string {
+	var ret []string
+	table := ""
+	for _, l := range saved {
+		switch {
+		case strings.HasPrefix(l, "#"), l == "COMMIT":
+		case strings.HasPrefix(l, "*"):
+			table = l[1:]
+		default:
+			ret = append(ret, "iptables -t "+table+" "+counters.ReplaceAllString(l, ""))
+		}
+	}
+	return ret
+}
+
+// tempDirs returns the temp dirs named like the ones runs make
+func tempDirs(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, prefix := range TempPrefixes {
+			if strings.HasPrefix(e.Name(), prefix) {
+				ret = append(ret, filepath.Join(dir, e.Name()))
+				break
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,112 @@
+package leftovers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSince(t *testing.T) {
+	before := &Snapshot{
+		Items:   []Item{{Process, "12 openbazaard start"}, {Rule, "iptables -t nat -A POSTROUTING -j MASQUERADE"}},
+		Skipped: map[string]string{Namespace: "permission denied"},
+	}
+	after := &Snapshot{
+		Items: []Item{
+			{Process, "12 openbazaard start"},
+			{Process, "34 openbazaard start -d /tmp/testnodes1/node-1"},
+			{Namespace, "/var/run/netns/ob"},
+			{TempDir, "/tmp/testnodes1"},
+		},
+		Skipped: map[string]string{Container: "docker not found"},
+	}
+	left := after.Since(before)
+	if len(left.Items) != 2 || left.Items[0].Name != "34 openbazaard start -d /tmp/testnodes1/node-1" || left.Items[1].Kind != TempDir {
+		t.Errorf("Expected only the new process and temp dir, got %v", left.Items)
+	}
+	if len(left.Skipped) != 2 {
+		t.Errorf("Expected the kinds skipped either time, got %v", left.Skipped)
+	}
+	if err := left.Err(); err == nil || err.Error() != "leftovers: left behind 1 process, 1 temp dir" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := before.Since(before).Err(); err != nil {
+		t.Errorf("Expected nothing left behind, got %s", err)
+	}
+
+	var b bytes.Buffer
+	if err := left.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 5 || lines[0] != "cleanup: 2 left behind" || !strings.Contains(lines[3], "not checked: docker not found") {
+		t.Errorf("Unexpected report:\n%s", b.String())
+	}
+}
+
+func TestTake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leftovers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	proc := filepath.Join(dir, "proc")
+	for pid, cmdline := range map[string]string{
+		"1":    "/sbin/init\x00",
+		"4242": "/usr/local/bin/openbazaard\x00start\x00-d\x00/tmp/node-1\x00",
+		"4343": "/usr/bin/bitcoind\x00-regtest\x00",
+		"self": "/usr/local/bin/openbazaard\x00",
+	} {
+		if err := os.MkdirAll(filepath.Join(proc, pid), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(proc, pid, "cmdline"), []byte(cmdline), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmp := filepath.Join(dir, "tmp")
+	for _, d := range []string{"testnodes123", "ob-migration-0.12-456", "unrelated"} {
+		if err := os.MkdirAll(filepath.Join(tmp, d), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := Take(context.Background(), Options{Docker: filepath.Join(dir, "no-docker"), TempDir: tmp, proc: proc})
+	var got []string
+	for _, it := range s.Items {
+		if it.Kind == Process || it.Kind == TempDir {
+			got = append(got, it.Kind+": "+it.Name)
+		}
+	}
+	want := []string{
+		"process: 4242 /usr/local/bin/openbazaard start -d /tmp/node-1",
+		"process: 4343 /usr/bin/bitcoind -regtest",
+		"temp dir: " + filepath.Join(tmp, "ob-migration-0.12-456"),
+		"temp dir: " + filepath.Join(tmp, "testnodes123"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if s.Skipped[Container] == "" || s.Skipped[Network] == "" {
+		t.Errorf("Expected docker to be skipped without a CLI, got %v", s.Skipped)
+	}
+}
+
+func TestIptablesRules(t *testing.T) {
+	saved := []string{
+		"# Generated by iptables-save v1.8.7",
+		"*nat",
+		":POSTROUTING ACCEPT [12:720]",
+		"-A POSTROUTING -s 10.77.1.0/24 ! -d 10.77.1.0/24 -j MASQUERADE",
+		"COMMIT",
+	}
+	got := strings.Join(iptablesRules(saved), "\n")
+	want := "iptables -t nat :POSTROUTING ACCEPT\niptables -t nat -A POSTROUTING -s 10.77.1.0/24 ! -d 10.77.1.0/24 -j MASQUERADE"
+	if got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,196 @@
+// Package manifest describes where a harness run came from: the binaries and
+// harness build, how nodes were run, the seeds and coin backends and the
+// host. Every artifact of a run carries its manifest, so results from
+// different environments can be told apart and a run reproduced.
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the commit the harness was built from, set at build time with
+// -ldflags "-X github.com/OpenBazaar/openbazaar-go/test/manifest.Version=$(git rev-parse HEAD)"
+var Version = "unknown"
+
+// Manifest is the provenance of one run
+type Manifest struct {
+	Run     string    `json:"run"`
+	Started time.Time `json:"started"`
+
+	// Command is the harness command line
+	Command []string `json:"command"`
+
+	Harness Harness `json:"harness"`
+
+	// Driver is how the nodes were run, e.g. local, docker, ssh or remote
+	// for nodes started outside the harness
+	Driver string `json:"driver"`
+
+	Binaries []Binary `json:"binaries,omitempty"`
+	Nodes    []Node   `json:"nodes,omitempty"`
+
+	// Seeds are the random seeds the run used, by what they seeded
+	Seeds map[string]int64 `json:"seeds,omitempty"`
+
+	// Coins are the coin backends of the nodes, e.g. BTC testnet, or of the
+	// wallets the harness funds from, e.g. bitcoind regtest
+	Coins []string `json:"coins,omitempty"`
+
+	Host Host `json:"host"`
+}
+
+// Harness is the build of the harness
+type Harness struct {
+	Version string `json:"version"`
+	Go      string `json:"go"`
+}
+
+// Binary is an openbazaard binary nodes ran
+type Binary struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256"`
+	Version string `json:"version,omitempty"`
+
+	// Commit is the HEAD of the git checkout the binary sits in, which is
+	// where go build leaves it, empty outside a checkout
+	Commit string `json:"commit,omitempty"`
+
+	// Dirty says the checkout had uncommitted changes
+	Dirty bool `json:"dirty,omitempty"`
+}
+
+// Node is a node of the run as it reported itself
+type Node struct {
+	Name           string `json:"name"`
+	Role           string `json:"role"`
+	PeerID         string `json:"peerID"`
+	Implementation string `json:"implementation,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Coin           string `json:"coin,omitempty"`
+}
+
+// Host is the machine the harness ran on
+type Host struct {
+	Name string `json:"name"`
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	CPUs int    `json:"cpus"`
+}
+
+// New starts the manifest of a run on this host
+func New(run, driver string) *Manifest {
+	hostname, _ := os.Hostname()
+	return &Manifest{
+		Run:     run,
+		Started: time.Now().UTC(),
+		Command: os.Args,
+		Harness: Harness{Version: Version, Go: runtime.Version()},
+		Driver:  driver,
+		Seeds:   make(map[string]int64),
+		Host:    Host{Name: hostname, OS: runtime.GOOS, Arch: runtime.GOARCH, CPUs: runtime.NumCPU()},
+	}
+}
+
+// AddBinary records the binary by content, the version it reports and the
+// commit of the checkout it sits in
+func (m *Manifest) AddBinary(path string) error {
+	bin, err := exec.LookPath(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(bin)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	b := Binary{Path: bin, SHA256: hex.EncodeToString(h.Sum(nil))}
+	if out, err := run(bin, "--version"); err == nil {
+		b.Version = out
+	}
+	dir := filepath.Dir(bin)
+	if out, err := run("git", "-C", dir, "rev-parse", "HEAD"); err == nil {
+		b.Commit = out
+		status, _ := run("git", "-C", dir, "status", "--porcelain", "--untracked-files=no")
+		b.Dirty = status != ""
+	}
+	m.Binaries = append(m.Binaries, b)
+	return nil
+}
+
+func run(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// AddCoin records a coin backend once
+func (m *Manifest) AddCoin(coin string) {
+	for _, c := range m.Coins {
+		if c == coin {
+			return
+		}
+	}
+	m.Coins = append(m.Coins, coin)
+	sort.Strings(m.Coins)
+}
+
+// Digest identifies the manifest by its content
+func (m *Manifest) Digest() string {
+	b, _ := json.Marshal(m)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Stamp is a one line summary of the manifest
+func (m *Manifest) Stamp() string {
+	versions := make([]string, len(m.Binaries))
+	for i, b := range m.Binaries {
+		versions[i] = b.Version
+		if b.Commit != "" {
+			versions[i] += "@" + short(b.Commit)
+		}
+	}
+	return fmt.Sprintf("run %s, harness %s, %s nodes on %s, openbazaard %s, manifest %s",
+		m.Run, short(m.Harness.Version), m.Driver, m.Host.Name, strings.Join(versions, ","), m.Digest())
+}
+
+func short(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// JSON returns the manifest indented
+func (m *Manifest) JSON() string {
+	b, _ := json.MarshalIndent(m, "", "  ")
+	return string(b)
+}
+
+// WriteComment writes the manifest as a # comment line, heading CSV and
+// other line based artifacts
+func (m *Manifest) WriteComment(w io.Writer) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# manifest %s\n", b)
+	return err
+}
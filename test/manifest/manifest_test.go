@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "openbazaard")
+	if err := ioutil.WriteFile(bin, []byte("#!/bin/sh\necho 0.8.0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := New("nightly", "local")
+	if err := m.AddBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	b := m.Binaries[0]
+	if b.Version != "0.8.0" || len(b.SHA256) != 64 || b.Commit != "" {
+		t.Errorf("Unexpected binary %+v", b)
+	}
+	if !strings.Contains(m.Stamp(), "run nightly, harness unknown, local nodes on ") || !strings.Contains(m.Stamp(), "openbazaard 0.8.0, manifest "+m.Digest()) {
+		t.Errorf("Unexpected stamp %s", m.Stamp())
+	}
+}
+
+func TestDigest(t *testing.T) {
+	m := New("nightly", "docker")
+	d := m.Digest()
+	if d != m.Digest() || len(d) != 12 {
+		t.Errorf("Expected a stable 12 digit digest, got %s", d)
+	}
+	m.Seeds["math/rand"] = 42
+	if m.Digest() == d {
+		t.Error("Expected the digest to change with the seeds")
+	}
+}
+
+func TestWriteComment(t *testing.T) {
+	m := New("nightly", "ssh")
+	m.AddCoin("BTC testnet")
+	m.AddCoin("BTC testnet")
+	var b bytes.Buffer
+	if err := m.WriteComment(&b); err != nil {
+		t.Fatal(err)
+	}
+	line := b.String()
+	if !strings.HasPrefix(line, "# manifest {") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("Expected one comment line, got %q", line)
+	}
+	var back Manifest
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "# manifest ")), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Digest() != m.Digest() || len(back.Coins) != 1 {
+		t.Errorf("Expected the manifest to round trip, got %+v", back)
+	}
+}
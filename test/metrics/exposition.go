@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseExposition reads samples in the Prometheus text exposition format, as
+// served by a node's /debug/metrics/prometheus endpoint. Comments and type
+// hints are skipped and every sample is stamped with the current time.
+func ParseExposition(r io.Reader) ([]Point, error) {
+	var points []Point
+	now := time.Now()
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		p.Time = now
+		points = append(points, p)
+	}
+	return points, s.Err()
+}
+
+// Sum adds up the value of every point with the given name whose tags include
+// all of the given ones
+func Sum(points []Point, name string, tags map[string]string) float64 {
+	var total float64
+	for _, p := range points {
+		if p.Name == name && hasTags(p, tags) {
+			total += p.Value
+		}
+	}
+	return total
+}
+
+func hasTags(p Point, tags map[string]string) bool {
+	for k, v := range tags {
+		if p.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func parseSample(line string) (Point, error) {
+	var p Point
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return p, fmt.Errorf("malformed sample %q", line)
+	}
+	p.Name = line[:end]
+	rest := line[end:]
+	if rest[0] == '{' {
+		tags, n, err := parseLabels(rest)
+		if err != nil {
+			return p, err
+		}
+		p.Tags = tags
+		rest = rest[n:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return p, fmt.Errorf("sample %s has no value", p.Name)
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return p, fmt.Errorf("sample %s: %s", p.Name, err)
+	}
+	p.Value = v
+	return p, nil
+}
+
+// parseLabels parses a {k="v",...} block at the start of s and returns the
+// labels and the number of bytes consumed
+func parseLabels(s string) (map[string]string, int, error) {
+	tags := make(map[string]string)
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated labels in %q", s)
+		}
+		if s[i] == '}' {
+			return tags, i + 1, nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, fmt.Errorf("malformed label in %q", s)
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+		var value []byte
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value = append(value, '\n')
+				default:
+					value = append(value, s[i])
+				}
+				continue
+			}
+			value = append(value, s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated label value in %q", s)
+		}
+		tags[key] = string(value)
+		i++
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+const exposition = `# HELP openbazaar_net_message_send_attempts_total Attempts at delivering a message
+# TYPE openbazaar_net_message_send_attempts_total counter
+openbazaar_net_message_send_attempts_total{attempt="direct",type="ORDER"} 3
+openbazaar_net_message_send_attempts_total{attempt="retry",type="ORDER"} 1
+openbazaar_net_message_send_attempts_total{attempt="direct",type="CHAT"} 7
+openbazaar_net_message_resend_interval_seconds_bucket{type="ORDER",le="0.05"} 1
+openbazaar_net_message_resend_interval_seconds_bucket{type="ORDER",le="+Inf"} 1
+escaped{path="a\"b\\c"} 2.5 1500000000000
+go_goroutines 42
+`
+
+func TestParseExposition(t *testing.T) {
+	points, err := ParseExposition(strings.NewReader(exposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 7 {
+		t.Fatalf("Expected 7 points, got %d", len(points))
+	}
+	if v := Sum(points, "openbazaar_net_message_send_attempts_total", map[string]string{"type": "ORDER"}); v != 4 {
+		t.Errorf("Expected 4 order attempts, got %f", v)
+	}
+	if v := Sum(points, "openbazaar_net_message_send_attempts_total", map[string]string{"attempt": "direct"}); v != 10 {
+		t.Errorf("Expected 10 direct attempts, got %f", v)
+	}
+	if points[4].Tags["le"] != "+Inf" {
+		t.Errorf("Expected le=+Inf, got %q", points[4].Tags["le"])
+	}
+	if points[5].Tags["path"] != `a"b\c` || points[5].Value != 2.5 {
+		t.Errorf("Escaped label parsed as %q = %f", points[5].Tags["path"], points[5].Value)
+	}
+	if points[6].Name != "go_goroutines" || points[6].Value != 42 {
+		t.Errorf("Unexpected point %+v", points[6])
+	}
+}
+
+func TestParseExpositionMalformed(t *testing.T) {
+	for _, line := range []string{"name{a=\"b\" 1", "name{a=b} 1", "name", "name{} x"} {
+		if _, err := ParseExposition(strings.NewReader(line)); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
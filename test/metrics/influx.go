@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxSink writes points to an InfluxDB 1.x /write endpoint using the line
+// protocol
+type InfluxSink struct {
+	URL      string
+	Database string
+	HTTP     *http.Client
+}
+
+// NewInfluxSink returns a sink for the database at the given server URL
+func NewInfluxSink(serverURL, database string) *InfluxSink {
+	return &InfluxSink{
+		URL:      strings.TrimRight(serverURL, "/"),
+		Database: database,
+		HTTP:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Write posts the points as one batch
+func (s *InfluxSink) Write(points []Point) error {
+	u := s.URL + "/write?precision=ns&db=" + url.QueryEscape(s.Database)
+	resp, err := s.HTTP.Post(u, "text/plain", bytes.NewBufferString(LineProtocol(points)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("influx write returned %d: %s", resp.StatusCode, b)
+	}
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// LineProtocol encodes points in the InfluxDB line protocol
+func LineProtocol(points []Point) string {
+	var b bytes.Buffer
+	for _, p := range points {
+		b.WriteString(measurementEscaper.Replace(p.Name))
+		for _, k := range sortedKeys(p.Tags) {
+			b.WriteString(",")
+			b.WriteString(tagEscaper.Replace(k))
+			b.WriteString("=")
+			b.WriteString(tagEscaper.Replace(p.Tags[k]))
+		}
+		b.WriteString(" value=")
+		b.WriteString(strconv.FormatFloat(p.Value, 'g', -1, 64))
+		b.WriteString(" ")
+		b.WriteString(strconv.FormatInt(p.Time.UnixNano(), 10))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
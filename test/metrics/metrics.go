@@ -0,0 +1,135 @@
+// Package metrics collects harness measurements and streams them to external
+// time series databases during long runs
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("metrics")
+
+// Point is a single measurement
+type Point struct {
+	Name  string
+	Tags  map[string]string
+	Value float64
+	Time  time.Time
+}
+
+// Sink receives batches of points
+type Sink interface {
+	Write(points []Point) error
+}
+
+// Recorder buffers points until they are flushed to its sinks. Sampled
+// sources are read on every flush so gauges such as message counts or node
+// memory show up as regular series.
+type Recorder struct {
+	lock    sync.Mutex
+	points  []Point
+	sources []source
+	sinks   []Sink
+}
+
+type source struct {
+	name string
+	tags map[string]string
+	read func() float64
+}
+
+// NewRecorder returns a recorder writing to the given sinks
+func NewRecorder(sinks ...Sink) *Recorder {
+	return &Recorder{sinks: sinks}
+}
+
+// AddSink registers another sink
+func (r *Recorder) AddSink(s Sink) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sinks = append(r.sinks, s)
+}
+
+// Record buffers a point stamped with the current time
+func (r *Recorder) Record(name string, value float64, tags map[string]string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.points = append(r.points, Point{Name: name, Tags: tags, Value: value, Time: time.Now()})
+}
+
+// Timing records a duration in seconds
+func (r *Recorder) Timing(name string, d time.Duration, tags map[string]string) {
+	r.Record(name, d.Seconds(), tags)
+}
+
+// Sample registers a source that is read on every flush
+func (r *Recorder) Sample(name string, tags map[string]string, read func() float64) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sources = append(r.sources, source{name: name, tags: tags, read: read})
+}
+
+// Flush reads the sampled sources and writes every buffered point to the sinks
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	now := time.Now()
+	points := r.points
+	r.points = nil
+	for _, s := range r.sources {
+		points = append(points, Point{Name: s.name, Tags: s.tags, Value: s.read(), Time: now})
+	}
+	sinks := r.sinks
+	r.lock.Unlock()
+
+	if len(points) == 0 {
+		return nil
+	}
+	var firstErr error
+	for _, s := range sinks {
+		if err := s.Write(points); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Export flushes the recorder every interval until ctx is done, then flushes
+// one last time. Write errors are logged rather than aborting the run since a
+// flaky TSDB must not fail a soak test.
+func (r *Recorder) Export(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := r.Flush(); err != nil {
+				log.Warningf("exporting metrics: %s", err)
+			}
+		case <-ctx.Done():
+			if err := r.Flush(); err != nil {
+				log.Warningf("exporting metrics: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// sortedKeys returns the tag names in a stable order
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
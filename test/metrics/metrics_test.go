@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	points []Point
+}
+
+func (m *memorySink) Write(points []Point) error {
+	m.points = append(m.points, points...)
+	return nil
+}
+
+func TestRecorderFlush(t *testing.T) {
+	sink := new(memorySink)
+	r := NewRecorder(sink)
+	r.Timing("step_latency", 1500*time.Millisecond, map[string]string{"step": "purchase"})
+	messages := 0.0
+	r.Sample("dht_messages", nil, func() float64 { return messages })
+	messages = 42
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(sink.points))
+	}
+	if sink.points[0].Value != 1.5 {
+		t.Errorf("Expected 1.5 seconds, got %f", sink.points[0].Value)
+	}
+	if sink.points[1].Value != 42 {
+		t.Errorf("Expected sampled value 42, got %f", sink.points[1].Value)
+	}
+
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.points) != 3 {
+		t.Errorf("Expected only the sampled point on the second flush, got %d points", len(sink.points))
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	r.Record("step_latency", 1, nil)
+	r.Timing("step_latency", time.Second, nil)
+	r.Sample("dht_messages", nil, func() float64 { return 0 })
+}
+
+func TestLineProtocol(t *testing.T) {
+	points := []Point{{
+		Name:  "step latency",
+		Tags:  map[string]string{"node": "vendor-1", "scenario": "a,b"},
+		Value: 0.25,
+		Time:  time.Unix(1, 5),
+	}}
+	expected := "step\\ latency,node=vendor-1,scenario=a\\,b value=0.25 1000000005\n"
+	if got := LineProtocol(points); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestWriteRequest(t *testing.T) {
+	points := []Point{{Name: "rss", Value: 1, Time: time.Unix(0, 0)}}
+	expected := []byte{
+		0x0a, 0x1e, // timeseries
+		0x0a, 0x0f, // label
+		0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_',
+		0x12, 0x03, 'r', 's', 's',
+		0x12, 0x0b, // sample
+		0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f,
+		0x10, 0x00,
+	}
+	got := WriteRequest(points)
+	if string(got) != string(expected) {
+		t.Errorf("Expected %x, got %x", expected, got)
+	}
+}
+
+func TestLabelsSorted(t *testing.T) {
+	// Upper case and digits sort before __name__, sanitizing can reorder
+	p := Point{Name: "rss", Tags: map[string]string{"node": "vendor-1", "Host": "a", "9x": "b", "a.z": "c", "a_b": "d"}}
+	var names []string
+	for _, l := range labels(p) {
+		names = append(names, l[0])
+	}
+	expected := []string{"9x", "Host", "__name__", "a_b", "a_z", "node"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected labels %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"gx/ipfs/QmNtxDQBrVzy88FEjVikyM5tAbyfnHm5jJbJZcUhVykusq/snappy"
+)
+
+// RemoteWriteSink pushes points to a Prometheus remote-write endpoint such as
+// Prometheus itself, Cortex, Thanos receive or VictoriaMetrics
+type RemoteWriteSink struct {
+	URL  string
+	HTTP *http.Client
+}
+
+// NewRemoteWriteSink returns a sink for the given remote-write URL
+func NewRemoteWriteSink(u string) *RemoteWriteSink {
+	return &RemoteWriteSink{
+		URL:  u,
+		HTTP: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Write posts the points as a snappy compressed WriteRequest
+func (s *RemoteWriteSink) Write(points []Point) error {
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(snappy.Encode(nil, WriteRequest(points))))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("remote write returned %d: %s", resp.StatusCode, b)
+	}
+	return nil
+}
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// WriteRequest encodes points as a prometheus.WriteRequest protobuf, one
+// time series per point. The message is small enough that hand encoding it
+// beats vendoring the generated prompb package.
+func WriteRequest(points []Point) []byte {
+	var req bytes.Buffer
+	for _, p := range points {
+		var ts bytes.Buffer
+		for _, l := range labels(p) {
+			writeLabel(&ts, l[0], l[1])
+		}
+		var sample bytes.Buffer
+		sample.WriteByte(1<<3 | 1) // value, fixed64
+		var f [8]byte
+		binary.LittleEndian.PutUint64(f[:], math.Float64bits(p.Value))
+		sample.Write(f[:])
+		sample.WriteByte(2<<3 | 0) // timestamp, varint
+		writeUvarint(&sample, uint64(p.Time.UnixNano()/int64(time.Millisecond)))
+		writeBytes(&ts, 2, sample.Bytes())
+
+		writeBytes(&req, 1, ts.Bytes())
+	}
+	return req.Bytes()
+}
+
+// labels returns the name and value of every label of the point, the metric
+// name included, sorted by name as remote-write receivers require
+func labels(p Point) [][2]string {
+	l := [][2]string{{"__name__", invalidMetricChars.ReplaceAllString(p.Name, "_")}}
+	for k, v := range p.Tags {
+		l = append(l, [2]string{invalidMetricChars.ReplaceAllString(k, "_"), v})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i][0] < l[j][0] })
+	return l
+}
+
+func writeLabel(b *bytes.Buffer, name, value string) {
+	var l bytes.Buffer
+	writeBytes(&l, 1, []byte(name))
+	writeBytes(&l, 2, []byte(value))
+	writeBytes(b, 1, l.Bytes())
+}
+
+// writeBytes writes a length delimited protobuf field
+func writeBytes(b *bytes.Buffer, field int, data []byte) {
+	writeUvarint(b, uint64(field<<3|2))
+	writeUvarint(b, uint64(len(data)))
+	b.Write(data)
+}
+
+func writeUvarint(b *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	b.Write(buf[:n])
+}
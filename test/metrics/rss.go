@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProcessRSS returns the resident set size of a process in bytes. It reads
+// /proc and so only works on Linux.
+func ProcessRSS(pid int) (uint64, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseRSS(f)
+}
+
+// parseRSS reads the VmRSS line of a /proc/<pid>/status file
+func parseRSS(r io.Reader) (uint64, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || fields[0] != "VmRSS:" {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if len(fields) > 2 && fields[2] != "kB" {
+			return 0, fmt.Errorf("unexpected VmRSS unit %s", fields[2])
+		}
+		return v * 1024, nil
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no VmRSS in process status")
+}
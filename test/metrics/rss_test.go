@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseRSS(t *testing.T) {
+	status := "Name:\topenbazaard\nVmPeak:\t  900000 kB\nVmRSS:\t  524288 kB\nThreads:\t40\n"
+	rss, err := parseRSS(strings.NewReader(status))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss != 512<<20 {
+		t.Errorf("Expected 512MB, got %d", rss)
+	}
+	if _, err := parseRSS(strings.NewReader("Name:\tkthreadd\n")); err == nil {
+		t.Error("Expected error for a status without VmRSS")
+	}
+}
+
+func TestProcessRSS(t *testing.T) {
+	if _, err := os.Stat("/proc/self/status"); err != nil {
+		t.Skip("no /proc on this system")
+	}
+	rss, err := ProcessRSS(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss == 0 {
+		t.Error("Expected non-zero RSS for the test process")
+	}
+}
@@ -0,0 +1,18 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// Boot starts binary on an extracted corpus repo, isolated from the real
+// network. A username authenticates every request, the readiness probe
+// included, to repos whose API requires it.
+func Boot(ctx context.Context, binary, repoDir string, testnet bool, username, password string) (*nodes.Process, error) {
+	opts := []nodes.Option{nodes.WithTestnet(testnet)}
+	if username != "" {
+		opts = append(opts, nodes.WithAuth(username, password))
+	}
+	return nodes.Start(ctx, binary, repoDir, opts...)
+}
@@ -0,0 +1,202 @@
+// Package migration boots the current openbazaard binary on repos captured
+// from earlier releases and checks that their records survive the upgrade.
+//
+// A corpus directory holds one sub directory per release:
+//
+//	corpus/0.7.0/repo.tar.gz   the data directory of a node run on 0.7.0
+//	corpus/0.7.0/expect.json   the records it held, see Expectations
+package migration
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	archiveFile = "repo.tar.gz"
+	expectFile  = "expect.json"
+)
+
+// Entry is one captured release in the corpus
+type Entry struct {
+	Version string
+	Dir     string
+}
+
+// Load returns the entries of the corpus ordered from oldest to newest release
+func Load(dir string) ([]Entry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		d := filepath.Join(dir, info.Name())
+		if _, err := os.Stat(filepath.Join(d, expectFile)); err != nil {
+			continue
+		}
+		entries = append(entries, Entry{Version: info.Name(), Dir: d})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return versionLess(entries[i].Version, entries[j].Version)
+	})
+	return entries, nil
+}
+
+// Expectations reads the records the entry's repo is known to hold
+func (e Entry) Expectations() (*Expectations, error) {
+	b, err := ioutil.ReadFile(filepath.Join(e.Dir, expectFile))
+	if err != nil {
+		return nil, err
+	}
+	exp := new(Expectations)
+	if err := json.Unmarshal(b, exp); err != nil {
+		return nil, fmt.Errorf("%s: %s", e.Version, err)
+	}
+	return exp, nil
+}
+
+// Extract unpacks the entry's repo into dst
+func (e Entry) Extract(dst string) error {
+	f, err := os.Open(filepath.Join(e.Dir, archiveFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
+			return fmt.Errorf("%s: archive entry %s escapes the repo", e.Version, hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Add stores a stopped node's data directory and its expectations in the
+// corpus under exp.Version
+func Add(corpus, repoDir string, exp *Expectations) error {
+	dir := filepath.Join(corpus, exp.Version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := archive(repoDir, filepath.Join(dir, archiveFile)); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(exp, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, expectFile), b, 0644)
+}
+
+func archive(src, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		// The lock file of a node that was not shut down cleanly would stop
+		// the next boot
+		if info.Name() == "repo.lock" {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// versionLess compares dotted release numbers numerically, so 0.10.0 sorts
+// after 0.9.2
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if aerr != nil || berr != nil {
+			if as[i] != bs[i] {
+				return as[i] < bs[i]
+			}
+			continue
+		}
+		if an != bn {
+			return an < bn
+		}
+	}
+	return len(as) < len(bs)
+}
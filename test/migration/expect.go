@@ -0,0 +1,188 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// Expectations are the records a captured repo holds. Every one of them must
+// still be served by the current binary after it migrated the repo.
+type Expectations struct {
+	// Version is the release the repo was captured from
+	Version string `json:"version"`
+
+	Testnet   bool                `json:"testnet"`
+	PeerID    string              `json:"peerID"`
+	Name      string              `json:"name"`
+	Listings  []Listing           `json:"listings"`
+	Purchases []Order             `json:"purchases"`
+	Sales     []Order             `json:"sales"`
+	Followers []string            `json:"followers"`
+	Chat      map[string][]string `json:"chat"`
+}
+
+// Listing is a listing expected in the index
+type Listing struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+// Order is a purchase or sale expected in the given state
+type Order struct {
+	OrderID string `json:"orderId"`
+	State   string `json:"state"`
+}
+
+// Capture reads the records of a running node. It is used once per release
+// to record the expectations stored next to the captured repo.
+func Capture(c *client.Client, version string) (*Expectations, error) {
+	exp := &Expectations{Version: version, Chat: make(map[string][]string)}
+	var cfg struct {
+		PeerID  string `json:"peerID"`
+		Testnet bool   `json:"testnet"`
+	}
+	if err := c.GetJSON("/ob/config", &cfg); err != nil {
+		return nil, err
+	}
+	exp.PeerID, exp.Testnet = cfg.PeerID, cfg.Testnet
+
+	var profile struct {
+		Name string `json:"name"`
+	}
+	if err := c.GetJSON("/ob/profile", &profile); err != nil {
+		return nil, err
+	}
+	exp.Name = profile.Name
+
+	listings, err := c.Listings("")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range listings {
+		exp.Listings = append(exp.Listings, Listing{Slug: l.Slug, Title: l.Title})
+	}
+	if exp.Purchases, err = orders(c, "/ob/purchases", "purchases"); err != nil {
+		return nil, err
+	}
+	if exp.Sales, err = orders(c, "/ob/sales", "sales"); err != nil {
+		return nil, err
+	}
+	if err := c.GetJSON("/ob/followers", &exp.Followers); err != nil {
+		return nil, err
+	}
+
+	var conversations []struct {
+		PeerID string `json:"peerId"`
+	}
+	if err := c.GetJSON("/ob/chatconversations", &conversations); err != nil {
+		return nil, err
+	}
+	for _, conv := range conversations {
+		messages, err := c.ChatMessages(conv.PeerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			exp.Chat[conv.PeerID] = append(exp.Chat[conv.PeerID], m.MessageID)
+		}
+	}
+	return exp, nil
+}
+
+// Check compares the node's records against the expectations and returns an
+// error listing every record that is missing or changed
+func Check(c *client.Client, exp *Expectations) error {
+	got, err := Capture(c, exp.Version)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	if got.PeerID != exp.PeerID {
+		errs = append(errs, fmt.Sprintf("peer ID is %s, expected %s", got.PeerID, exp.PeerID))
+	}
+	if got.Name != exp.Name {
+		errs = append(errs, fmt.Sprintf("profile name is %q, expected %q", got.Name, exp.Name))
+	}
+
+	listings := make(map[string]string)
+	for _, l := range got.Listings {
+		listings[l.Slug] = l.Title
+	}
+	for _, l := range exp.Listings {
+		title, ok := listings[l.Slug]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Sprintf("listing %s is missing", l.Slug))
+		case title != l.Title:
+			errs = append(errs, fmt.Sprintf("listing %s has title %q, expected %q", l.Slug, title, l.Title))
+		}
+	}
+	errs = append(errs, compareOrders("purchase", got.Purchases, exp.Purchases)...)
+	errs = append(errs, compareOrders("sale", got.Sales, exp.Sales)...)
+
+	for _, f := range missing(got.Followers, exp.Followers) {
+		errs = append(errs, fmt.Sprintf("follower %s is missing", f))
+	}
+	for peer, ids := range exp.Chat {
+		for _, id := range missing(got.Chat[peer], ids) {
+			errs = append(errs, fmt.Sprintf("chat message %s with %s is missing", id, peer))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s: %s", exp.Version, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func compareOrders(kind string, got, exp []Order) []string {
+	states := make(map[string]string)
+	for _, o := range got {
+		states[o.OrderID] = o.State
+	}
+	var errs []string
+	for _, o := range exp {
+		state, ok := states[o.OrderID]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Sprintf("%s %s is missing", kind, o.OrderID))
+		case state != o.State:
+			errs = append(errs, fmt.Sprintf("%s %s is %s, expected %s", kind, o.OrderID, state, o.State))
+		}
+	}
+	return errs
+}
+
+// missing returns the entries of want that are not in got
+func missing(got, want []string) []string {
+	have := make(map[string]bool)
+	for _, g := range got {
+		have[g] = true
+	}
+	var ret []string
+	for _, w := range want {
+		if !have[w] {
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
+
+func orders(c *client.Client, path, key string) ([]Order, error) {
+	var resp map[string]json.RawMessage
+	if err := c.GetJSON(path, &resp); err != nil {
+		return nil, err
+	}
+	var ret []Order
+	if len(resp[key]) == 0 {
+		return ret, nil
+	}
+	if err := json.Unmarshal(resp[key], &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,121 @@
+package migration
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"0.7.0", "0.8.0", true},
+		{"0.9.2", "0.10.0", true},
+		{"0.10.0", "0.9.2", false},
+		{"0.8", "0.8.1", true},
+		{"0.8.0", "0.8.0", false},
+	}
+	for _, test := range tests {
+		if versionLess(test.a, test.b) != test.less {
+			t.Errorf("versionLess(%s, %s) should be %t", test.a, test.b, test.less)
+		}
+	}
+}
+
+func TestAddAndExtract(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	repoDir := filepath.Join(tmp, "repo")
+	corpus := filepath.Join(tmp, "corpus")
+	if err := os.MkdirAll(filepath.Join(repoDir, "root", "listings"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(repoDir, "config"), []byte(`{"Addresses":{}}`), 0600)
+	ioutil.WriteFile(filepath.Join(repoDir, "root", "listings", "shirt.json"), []byte("{}"), 0644)
+	ioutil.WriteFile(filepath.Join(repoDir, "repo.lock"), nil, 0644)
+
+	for _, v := range []string{"0.10.0", "0.9.0"} {
+		if err := Add(corpus, repoDir, &Expectations{Version: v, PeerID: "Qm" + v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := Load(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Version != "0.9.0" || entries[1].Version != "0.10.0" {
+		t.Fatalf("Unexpected entries %v", entries)
+	}
+	exp, err := entries[1].Expectations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.PeerID != "Qm0.10.0" {
+		t.Errorf("Expected peer ID Qm0.10.0, got %s", exp.PeerID)
+	}
+
+	dst := filepath.Join(tmp, "extracted")
+	if err := entries[0].Extract(dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "root", "listings", "shirt.json")); err != nil {
+		t.Error("Listing missing from extracted repo")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "repo.lock")); err == nil {
+		t.Error("Lock file should not be archived")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	responses := map[string]string{
+		"/ob/config":               `{"peerID": "QmVendor", "testnet": true}`,
+		"/ob/profile":              `{"name": "Ron"}`,
+		"/ob/listings":             `[{"slug": "shirt", "title": "Ron Swanson Tshirt"}]`,
+		"/ob/purchases":            `{"queryCount": 1, "purchases": [{"orderId": "QmOrder", "state": "COMPLETED"}]}`,
+		"/ob/sales":                `{"queryCount": 0, "sales": []}`,
+		"/ob/followers":            `["QmFollower"]`,
+		"/ob/chatconversations":    `[{"peerId": "QmBuyer"}]`,
+		"/ob/chatmessages/QmBuyer": `[{"messageId": "QmMessage"}]`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	defer ts.Close()
+	c := client.New(ts.URL)
+
+	exp, err := Capture(c, "0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(c, exp); err != nil {
+		t.Errorf("Captured expectations should match: %s", err)
+	}
+
+	exp.Purchases[0].State = "FULFILLED"
+	exp.Chat["QmBuyer"] = append(exp.Chat["QmBuyer"], "QmLost")
+	err = Check(c, exp)
+	if err == nil {
+		t.Fatal("Expected changed records to be reported")
+	}
+	for _, want := range []string{"purchase QmOrder is COMPLETED, expected FULFILLED", "chat message QmLost with QmBuyer is missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %s", want, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Result is the outcome of migrating one corpus entry
+type Result struct {
+	Version  string
+	Duration time.Duration
+	Err      error
+}
+
+func (r Result) String() string {
+	status := "ok"
+	if r.Err != nil {
+		status = "FAIL: " + r.Err.Error()
+	}
+	return fmt.Sprintf("%s %s %s", r.Version, r.Duration.Round(time.Millisecond), status)
+}
+
+// Options configures a migration run
+type Options struct {
+	// Binary is the openbazaard under test
+	Binary string
+
+	// Username and Password are used when a captured repo has API
+	// authentication turned on
+	Username string
+	Password string
+
+	// Keep leaves the extracted repos on disk for inspection
+	Keep bool
+}
+
+// Run boots the binary on a fresh copy of every entry and checks its
+// expectations. Entries are independent, a failing one does not stop the rest.
+func Run(ctx context.Context, opts Options, entries []Entry) []Result {
+	var results []Result
+	for _, e := range entries {
+		start := time.Now()
+		err := migrate(ctx, opts, e)
+		results = append(results, Result{Version: e.Version, Duration: time.Since(start), Err: err})
+	}
+	return results
+}
+
+func migrate(ctx context.Context, opts Options, e Entry) error {
+	exp, err := e.Expectations()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "ob-migration-"+e.Version+"-")
+	if err != nil {
+		return err
+	}
+	if !opts.Keep {
+		defer os.RemoveAll(dir)
+	}
+	if err := e.Extract(dir); err != nil {
+		return err
+	}
+	p, err := Boot(ctx, opts.Binary, dir, exp.Testnet, opts.Username, opts.Password)
+	if err != nil {
+		return err
+	}
+	defer p.Stop()
+	return Check(p.Client, exp)
+}
@@ -0,0 +1,120 @@
+// Package model describes the order lifecycle as a state machine and
+// enumerates the action sequences it allows, for checking an implementation
+// against it step by step
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Actors of an action
+const (
+	Buyer  = "buyer"
+	Vendor = "vendor"
+)
+
+// Transition is an action the model allows in a state
+type Transition struct {
+	From   string
+	Action string
+	Actor  string
+	To     string
+}
+
+// Model is a state machine over order states
+type Model struct {
+	// Initial is the state an order is in once placed
+	Initial     string
+	Transitions []Transition
+}
+
+// DirectOrder is the lifecycle of a direct order placed while the vendor is
+// online, as the spec describes it. Confirming, rejecting and canceling only
+// apply to orders left PENDING by an offline vendor, so they are never
+// allowed here.
+func DirectOrder() Model {
+	return Model{
+		Initial: "AWAITING_PAYMENT",
+		Transitions: []Transition{
+			{"AWAITING_PAYMENT", "pay", Buyer, "AWAITING_FULFILLMENT"},
+			{"AWAITING_FULFILLMENT", "fulfill", Vendor, "FULFILLED"},
+			{"AWAITING_FULFILLMENT", "refund", Vendor, "REFUNDED"},
+			{"FULFILLED", "complete", Buyer, "COMPLETED"},
+		},
+	}
+}
+
+// Actions are all the order actions, allowed anywhere by a model or not,
+// sorted
+var Actions = []string{"cancel", "complete", "confirm", "fulfill", "pay", "refund", "reject"}
+
+// Allowed returns the transitions out of state, ordered by action
+func (m Model) Allowed(state string) []Transition {
+	var ret []Transition
+	for _, t := range m.Transitions {
+		if t.From == state {
+			ret = append(ret, t)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Action < ret[j].Action })
+	return ret
+}
+
+// Forbidden returns the actions of Actions the model does not allow in state
+func (m Model) Forbidden(state string) []string {
+	allowed := make(map[string]bool)
+	for _, t := range m.Allowed(state) {
+		allowed[t.Action] = true
+	}
+	var ret []string
+	for _, a := range Actions {
+		if !allowed[a] {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+// Sequence is a run of allowed transitions from the initial state
+type Sequence []Transition
+
+func (s Sequence) String() string {
+	var actions []string
+	for _, t := range s {
+		actions = append(actions, t.Action)
+	}
+	if len(actions) == 0 {
+		return "(placed)"
+	}
+	return strings.Join(actions, " > ")
+}
+
+// Sequences returns every sequence of allowed transitions from the initial
+// state that ends in a state without transitions or after depth steps,
+// ordered by action. Depth bounds models with cycles; zero means 10.
+func (m Model) Sequences(depth int) ([]Sequence, error) {
+	if depth == 0 {
+		depth = 10
+	}
+	for _, t := range m.Transitions {
+		if t.Actor != Buyer && t.Actor != Vendor {
+			return nil, fmt.Errorf("model: %s in %s has unknown actor %q", t.Action, t.From, t.Actor)
+		}
+	}
+	var ret []Sequence
+	var walk func(state string, seq Sequence)
+	walk = func(state string, seq Sequence) {
+		next := m.Allowed(state)
+		if len(next) == 0 || len(seq) == depth {
+			ret = append(ret, append(Sequence(nil), seq...))
+			return
+		}
+		for _, t := range next {
+			walk(t.To, append(seq, t))
+		}
+	}
+	walk(m.Initial, nil)
+	return ret, nil
+}
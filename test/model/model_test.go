@@ -0,0 +1,53 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDirectOrderSequences(t *testing.T) {
+	seqs, err := DirectOrder().Sequences(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range seqs {
+		got = append(got, s.String())
+	}
+	want := []string{"pay > fulfill > complete", "pay > refund"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if last := seqs[1][len(seqs[1])-1]; last.To != "REFUNDED" || last.Actor != Vendor {
+		t.Errorf("Expected the vendor to refund, got %+v", last)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	m := DirectOrder()
+	want := []string{"cancel", "complete", "confirm", "pay", "reject"}
+	if got := m.Forbidden("AWAITING_FULFILLMENT"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := m.Forbidden("COMPLETED"); !reflect.DeepEqual(got, Actions) {
+		t.Errorf("Expected every action forbidden, got %v", got)
+	}
+}
+
+func TestSequencesDepth(t *testing.T) {
+	m := Model{Initial: "A", Transitions: []Transition{
+		{"A", "x", Buyer, "B"},
+		{"B", "y", Vendor, "A"},
+	}}
+	seqs, err := m.Sequences(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != 1 || seqs[0].String() != "x > y > x" {
+		t.Errorf("Expected the cycle cut after 3 steps, got %v", seqs)
+	}
+	m.Transitions[0].Actor = "moderator"
+	if _, err := m.Sequences(3); err == nil {
+		t.Error("Expected an unknown actor to be rejected")
+	}
+}
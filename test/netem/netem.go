@@ -0,0 +1,192 @@
+// Package netem delays, jitters and drops traffic on a network interface with
+// the netem queueing discipline, all of it or per destination. It needs tc
+// from iproute2 and CAP_NET_ADMIN, see testnodes doctor.
+package netem
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Loopback is the interface local test nodes talk over
+const Loopback = "lo"
+
+// Impairment is what netem does to the packets it shapes
+type Impairment struct {
+	// Delay is added to every packet
+	Delay time.Duration
+
+	// Jitter varies the delay of each packet by up to this much either way,
+	// which reorders packets sent closer together than it
+	Jitter time.Duration
+
+	// Loss is the percentage of packets dropped, from 0 to 100
+	Loss float64
+}
+
+// netemArgs are the netem parameters of the impairment
+func (i Impairment) netemArgs() ([]string, error) {
+	if i.Delay < 0 || i.Jitter < 0 {
+		return nil, fmt.Errorf("netem: negative delay %s or jitter %s", i.Delay, i.Jitter)
+	}
+	if i.Loss < 0 || i.Loss > 100 {
+		return nil, fmt.Errorf("netem: loss of %g%% is not a percentage", i.Loss)
+	}
+	args := []string{"netem", "delay", fmt.Sprintf("%dus", i.Delay/time.Microsecond)}
+	if i.Jitter > 0 {
+		args = append(args, fmt.Sprintf("%dus", i.Jitter/time.Microsecond))
+	}
+	if i.Loss > 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", i.Loss))
+	}
+	return args, nil
+}
+
+// Delay adds d to every packet leaving dev, replacing the queueing discipline
+// already on it. On the loopback interface each direction is delayed, so the
+// round trip between two local nodes grows by 2*d. The returned function
+// restores the default discipline.
+func Delay(ctx context.Context, dev string, d time.Duration) (restore func() error, err error) {
+	return Impair(ctx, dev, Impairment{Delay: d})
+}
+
+// Impair is Delay with jitter and packet loss. On the loopback interface a
+// packet is lost going either way, so a round trip between two local nodes
+// sees about twice the loss.
+func Impair(ctx context.Context, dev string, i Impairment) (restore func() error, err error) {
+	args, err := impairArgs(dev, i)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc(ctx, args...); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return tc(context.Background(), "qdisc", "del", "dev", dev, "root")
+	}, nil
+}
+
+func impairArgs(dev string, i Impairment) ([]string, error) {
+	args, err := i.netemArgs()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"qdisc", "replace", "dev", dev, "root"}, args...), nil
+}
+
+// MaxLinks is how many links Shape can impair on one interface: a prio qdisc
+// has at most 16 bands and the first 3 carry the unimpaired traffic
+const MaxLinks = 13
+
+// Link is the impairment of the traffic to one destination IP
+type Link struct {
+	Dst string
+	Impairment
+}
+
+// Caps limit the bandwidth of an interface in bits per second, zero leaving a
+// direction uncapped
+type Caps struct {
+	// Egress caps the packets leaving the interface, a node's upload. Packets
+	// over the rate queue for up to half a second.
+	Egress uint64
+
+	// Ingress caps the packets arriving, a node's download. Packets over the
+	// rate are dropped, there being no queue on the way in.
+	Ingress uint64
+}
+
+// Shape caps the bandwidth of dev and impairs the packets leaving it as the
+// link to their destination says, leaving packets to other destinations
+// alone and replacing the queueing disciplines already on dev. Each link only
+// shapes one direction, so the round trip between two hosts goes through
+// their links to each other. prefix runs tc elsewhere, e.g. docker exec
+// <container>; with none it runs on this host. No caps and no links restore
+// the default disciplines.
+func Shape(ctx context.Context, prefix []string, dev string, caps Caps, links []Link) error {
+	cmds, err := shapeArgs(dev, caps, links)
+	if err != nil {
+		return err
+	}
+	// Deleting fails when the default discipline is on dev already
+	tcIn(ctx, prefix, "qdisc", "del", "dev", dev, "root")
+	tcIn(ctx, prefix, "qdisc", "del", "dev", dev, "ingress")
+	for _, args := range cmds {
+		if err := tcIn(ctx, prefix, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shapeArgs are the tc commands shaping dev: a token bucket at the root for
+// the egress cap, under it a prio qdisc with a band and netem child per link
+// and a filter steering the link's destination into its band, and a policer
+// on ingress for the ingress cap
+func shapeArgs(dev string, caps Caps, links []Link) ([][]string, error) {
+	if len(links) > MaxLinks {
+		return nil, fmt.Errorf("netem: %d links on %s, at most %d fit", len(links), dev, MaxLinks)
+	}
+	var cmds [][]string
+	parent, handle := []string{"root"}, 1
+	if caps.Egress > 0 {
+		cmds = append(cmds, []string{"qdisc", "add", "dev", dev, "root", "handle", "1:", "tbf", "rate", bits(caps.Egress), "burst", burst(caps.Egress), "latency", "500ms"})
+		parent, handle = []string{"parent", "1:1"}, 2
+	}
+	if len(links) > 0 {
+		prio := append(append([]string{"qdisc", "add", "dev", dev}, parent...), "handle", fmt.Sprintf("%d:", handle), "prio", "bands", fmt.Sprint(3+len(links)))
+		cmds = append(cmds, prio)
+	}
+	for i, l := range links {
+		if net.ParseIP(l.Dst) == nil || net.ParseIP(l.Dst).To4() == nil {
+			return nil, fmt.Errorf("netem: link destination %q is not an IPv4 address", l.Dst)
+		}
+		args, err := l.netemArgs()
+		if err != nil {
+			return nil, err
+		}
+		band := 4 + i
+		cmds = append(cmds,
+			append([]string{"qdisc", "add", "dev", dev, "parent", fmt.Sprintf("%d:%d", handle, band), "handle", fmt.Sprintf("%d:", 10+band)}, args...),
+			[]string{"filter", "add", "dev", dev, "protocol", "ip", "parent", fmt.Sprintf("%d:0", handle), "prio", "1", "u32", "match", "ip", "dst", l.Dst + "/32", "flowid", fmt.Sprintf("%d:%d", handle, band)},
+		)
+	}
+	if caps.Ingress > 0 {
+		cmds = append(cmds,
+			[]string{"qdisc", "add", "dev", dev, "handle", "ffff:", "ingress"},
+			[]string{"filter", "add", "dev", dev, "parent", "ffff:", "protocol", "ip", "prio", "1", "u32", "match", "u32", "0", "0", "police", "rate", bits(caps.Ingress), "burst", burst(caps.Ingress), "drop", "flowid", ":1"},
+		)
+	}
+	return cmds, nil
+}
+
+func bits(rate uint64) string {
+	return fmt.Sprintf("%dbit", rate)
+}
+
+// burst is how many bytes may pass at once at rate: a tenth of a second's
+// worth, but at least a full frame
+func burst(rate uint64) string {
+	b := rate / 8 / 10
+	if b < 1600 {
+		b = 1600
+	}
+	return fmt.Sprint(b)
+}
+
+func tc(ctx context.Context, args ...string) error {
+	return tcIn(ctx, nil, args...)
+}
+
+func tcIn(ctx context.Context, prefix []string, args ...string) error {
+	argv := append(append(append([]string(nil), prefix...), "tc"), args...)
+	out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %s", strings.Join(argv, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
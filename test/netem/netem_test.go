@@ -0,0 +1,89 @@
+package netem
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImpairArgs(t *testing.T) {
+	for _, c := range []struct {
+		i    Impairment
+		want string
+	}{
+		{Impairment{Delay: 150 * time.Millisecond}, "qdisc replace dev lo root netem delay 150000us"},
+		{Impairment{Delay: 100 * time.Millisecond, Jitter: 40 * time.Millisecond}, "qdisc replace dev lo root netem delay 100000us 40000us"},
+		{Impairment{Loss: 2.5}, "qdisc replace dev lo root netem delay 0us loss 2.5%"},
+	} {
+		args, err := impairArgs(Loopback, c.i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(args, " "); got != c.want {
+			t.Errorf("Expected %q, got %q", c.want, got)
+		}
+	}
+	for _, i := range []Impairment{{Loss: -1}, {Loss: 101}, {Jitter: -time.Millisecond}} {
+		if _, err := impairArgs(Loopback, i); err == nil {
+			t.Errorf("Expected %+v to be rejected", i)
+		}
+	}
+}
+
+func TestShapeArgs(t *testing.T) {
+	cmds, err := shapeArgs("eth0", Caps{}, []Link{
+		{"172.30.0.3", Impairment{Delay: 100 * time.Millisecond}},
+		{"172.30.0.4", Impairment{Delay: 250 * time.Millisecond, Jitter: 50 * time.Millisecond, Loss: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cmds {
+		got = append(got, strings.Join(c, " "))
+	}
+	want := []string{
+		"qdisc add dev eth0 root handle 1: prio bands 5",
+		"qdisc add dev eth0 parent 1:4 handle 14: netem delay 100000us",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 172.30.0.3/32 flowid 1:4",
+		"qdisc add dev eth0 parent 1:5 handle 15: netem delay 250000us 50000us loss 5%",
+		"filter add dev eth0 protocol ip parent 1:0 prio 1 u32 match ip dst 172.30.0.4/32 flowid 1:5",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if cmds, err := shapeArgs("eth0", Caps{}, nil); err != nil || len(cmds) != 0 {
+		t.Errorf("Expected no commands for no links, got %v, %v", cmds, err)
+	}
+	if _, err := shapeArgs("eth0", Caps{}, []Link{{"::1", Impairment{Delay: time.Millisecond}}}); err == nil {
+		t.Error("Expected an IPv6 destination to be rejected")
+	}
+	if _, err := shapeArgs("eth0", Caps{}, make([]Link, MaxLinks+1)); err == nil {
+		t.Error("Expected too many links to be rejected")
+	}
+}
+
+func TestShapeArgsCaps(t *testing.T) {
+	cmds, err := shapeArgs("eth0", Caps{Egress: 256000, Ingress: 1000000}, []Link{{"172.30.0.3", Impairment{Delay: 100 * time.Millisecond}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cmds {
+		got = append(got, strings.Join(c, " "))
+	}
+	want := []string{
+		"qdisc add dev eth0 root handle 1: tbf rate 256000bit burst 3200 latency 500ms",
+		"qdisc add dev eth0 parent 1:1 handle 2: prio bands 4",
+		"qdisc add dev eth0 parent 2:4 handle 14: netem delay 100000us",
+		"filter add dev eth0 protocol ip parent 2:0 prio 1 u32 match ip dst 172.30.0.3/32 flowid 2:4",
+		"qdisc add dev eth0 handle ffff: ingress",
+		"filter add dev eth0 parent ffff: protocol ip prio 1 u32 match u32 0 0 police rate 1000000bit burst 12500 drop flowid :1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if cmds, _ := shapeArgs("eth0", Caps{Egress: 8000}, nil); len(cmds) != 1 || cmds[0][11] != "1600" {
+		t.Errorf("Expected a single token bucket with a burst of a full frame, got %v", cmds)
+	}
+}
@@ -0,0 +1,26 @@
+package nodes
+
+import (
+	"context"
+	"io"
+
+	"github.com/OpenBazaar/openbazaar-go/test/backup"
+)
+
+// Backup stops the node and writes its repo to w as an archive Restore can
+// start a node from
+func (p *Process) Backup(w io.Writer) error {
+	if err := p.Stop(); err != nil {
+		return err
+	}
+	return backup.Create(p.RepoDir, w)
+}
+
+// Restore unpacks an archive written by Backup into repoDir and starts the
+// node it holds, with the same identity and store
+func Restore(ctx context.Context, binary string, r io.Reader, repoDir string, opts ...Option) (*Process, error) {
+	if err := backup.Restore(r, repoDir); err != nil {
+		return nil, err
+	}
+	return Start(ctx, binary, repoDir, opts...)
+}
@@ -0,0 +1,32 @@
+package nodes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blocklistFile is api.BlocklistFile, the list of CIDs and peer IDs the
+// gateway refuses to serve
+const blocklistFile = "blocklist"
+
+// SetBlocklist replaces the gateway blocklist of the node. The node rereads
+// the file on its next gateway request; no entries unblocks everything.
+func (p *Process) SetBlocklist(entries ...string) error {
+	path := filepath.Join(p.RepoDir, blocklistFile)
+	tmp := path + ".tmp"
+	content := strings.Join(entries, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := ioutil.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return err
+	}
+	// Rename so the node never reads a half written list
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
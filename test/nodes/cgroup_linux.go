@@ -0,0 +1,70 @@
+package nodes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// CgroupRoot is the cgroup v2 directory local nodes with limits get a cgroup
+// under. It is created along with the per node cgroups, so the harness has
+// to run as root or be delegated its parent.
+var CgroupRoot = "/sys/fs/cgroup/testnodes"
+
+// cpuPeriod is the cpu.max period quotas are given in, in microseconds
+const cpuPeriod = 100000
+
+// cgroupFiles are the control files setting the limits
+func cgroupFiles(l Limits) map[string]string {
+	files := map[string]string{
+		"cpu.max":         fmt.Sprintf("max %d", cpuPeriod),
+		"memory.max":      "max",
+		"memory.swap.max": "max",
+	}
+	if l.CPUs > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int(l.CPUs*cpuPeriod), cpuPeriod)
+	}
+	if l.Memory > 0 {
+		files["memory.max"] = fmt.Sprint(l.Memory)
+		files["memory.swap.max"] = "0"
+	}
+	return files
+}
+
+// newCgroup creates the cgroup of the node on repoDir with the limits and
+// returns its directory
+func newCgroup(repoDir string, l Limits) (string, error) {
+	if err := os.MkdirAll(CgroupRoot, 0755); err != nil {
+		return "", fmt.Errorf("nodes: creating the cgroup root: %s", err)
+	}
+	// The cpu and memory controllers have to be enabled from the parent of
+	// the root down
+	for _, dir := range []string{filepath.Dir(CgroupRoot), CgroupRoot} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644); err != nil {
+			return "", fmt.Errorf("nodes: enabling cgroup controllers in %s: %s", dir, err)
+		}
+	}
+	dir := filepath.Join(CgroupRoot, containerName(repoDir))
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	for name, value := range cgroupFiles(l) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+		// Kernels without swap accounting have no memory.swap.max
+		if err != nil && !(name == "memory.swap.max" && os.IsNotExist(err)) {
+			os.Remove(dir)
+			return "", fmt.Errorf("nodes: setting %s: %s", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// inCgroup returns a command running binary in the cgroup. A shell moves
+// itself into the cgroup and then execs the binary, so the node is limited
+// from its first instruction and keeps the pid the harness signals.
+func inCgroup(cgroup, binary string, args []string) *exec.Cmd {
+	script := `echo $$ > "$1/cgroup.procs" && shift && exec "$@"`
+	return exec.Command("/bin/sh", append([]string{"-c", script, "sh", cgroup, binary}, args...)...)
+}
@@ -0,0 +1,42 @@
+package nodes
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCgroupFiles(t *testing.T) {
+	files := cgroupFiles(Limits{CPUs: 0.5, Memory: 512 << 20})
+	if files["cpu.max"] != "50000 100000" || files["memory.max"] != "536870912" || files["memory.swap.max"] != "0" {
+		t.Errorf("Unexpected control files %v", files)
+	}
+	files = cgroupFiles(Limits{CPUs: 2})
+	if files["cpu.max"] != "200000 100000" || files["memory.max"] != "max" {
+		t.Errorf("Expected memory left unlimited, got %v", files)
+	}
+}
+
+func TestInCgroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := inCgroup(dir, "echo", []string{"it's", "here"})
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "it's here\n" {
+		t.Errorf("Expected the arguments passed through, got %q", out)
+	}
+	procs, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(procs)) != strconv.Itoa(cmd.Process.Pid) {
+		t.Errorf("Expected pid %d moved into the cgroup, got %s", cmd.Process.Pid, procs)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package nodes
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Limits on local nodes need cgroups
+func newCgroup(repoDir string, l Limits) (string, error) {
+	return "", errors.New("nodes: limiting local nodes needs cgroups, which only Linux has")
+}
+
+func inCgroup(cgroup, binary string, args []string) *exec.Cmd {
+	return exec.Command(binary, args...)
+}
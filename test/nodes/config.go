@@ -0,0 +1,308 @@
+package nodes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/yawning/bulb/utils/pkcs1"
+)
+
+// configure rewrites the listen addresses, bootstrap list and wallet peers of
+// the repo config, the Tor control port and onion address of Tor nodes, and
+// whatever reaches beyond the LAN for LANOnly nodes.
+// It writes the swarm key of the node's private swarm, removing one left by
+// an earlier run when the node is in the public swarm.
+func configure(repoDir, gateway string, swarm []string, o Options) error {
+	keyPath := filepath.Join(repoDir, SwarmKeyFile)
+	if o.Swarm != "" {
+		if err := ioutil.WriteFile(keyPath, SwarmKey(o.Swarm), 0600); err != nil {
+			return err
+		}
+	} else if err := os.Remove(keyPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	cfgPath := filepath.Join(repoDir, "config")
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return err
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+	addrs, ok := cfg["Addresses"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s has no Addresses section", cfgPath)
+	}
+	addrs["Gateway"] = gateway
+	if addrs["Swarm"], err = o.torSwarm(repoDir, swarm); err != nil {
+		return err
+	}
+	if o.Tor != NoTor {
+		cfg["Tor-config"] = map[string]interface{}{"Password": "", "TorControl": o.TorControl}
+	}
+	bootstrap := []string{}
+	cfg["Bootstrap"] = append(bootstrap, o.Bootstrap...)
+	if o.LANOnly {
+		lan, err := lanOnly(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %s", cfgPath, err)
+		}
+		cfg = lan
+	}
+	if o.TrustedPeer != "" {
+		wallet, ok := cfg["Wallet"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s has no Wallet section", cfgPath)
+		}
+		wallet["TrustedPeer"] = o.TrustedPeer
+		wallet["FeeAPI"] = o.FeeAPI
+		if o.Wallet != "" {
+			wallet["Type"] = o.Wallet
+		}
+	}
+	if len(o.CoinWallets) > 0 {
+		if err := coinWallets(cfg, o.CoinWallets, o.FeeAPI); err != nil {
+			return fmt.Errorf("%s: %s", cfgPath, err)
+		}
+	}
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cfgPath, out, 0600)
+}
+
+// coinWallets points the wallet of every coin in peers at its trusted peer
+// and feeAPI, in the Wallets section releases running a wallet per coin have
+func coinWallets(cfg map[string]interface{}, peers map[string]string, feeAPI string) error {
+	wallets, ok := cfg["Wallets"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("no Wallets section, the node runs a bitcoin wallet only")
+	}
+	for coin, peer := range peers {
+		wallet, ok := wallets[coin].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("no %s wallet", coin)
+		}
+		// Regtest daemons serve SPV wallets, there is no API to point
+		// API wallets at
+		wallet["Type"] = "SPV"
+		wallet["TrustedPeer"] = peer
+		wallet["FeeAPI"] = feeAPI
+	}
+	return nil
+}
+
+// onionPort is the virtual port of onion services, the one openbazaard uses
+const onionPort = 4003
+
+// onionAddr returns the onion swarm address of the repo, creating the hidden
+// service key openbazaard keeps there if it has none yet
+func onionAddr(repoDir string) (string, error) {
+	keys, err := filepath.Glob(filepath.Join(repoDir, "*.onion_key"))
+	if err != nil {
+		return "", err
+	}
+	if len(keys) > 0 {
+		id := strings.TrimSuffix(filepath.Base(keys[0]), ".onion_key")
+		return fmt.Sprintf("/onion/%s:%d", id, onionPort), nil
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		return "", err
+	}
+	id, err := pkcs1.OnionAddr(&priv.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	der, err := pkcs1.EncodePrivateKeyDER(priv)
+	if err != nil {
+		return "", err
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(repoDir, id+".onion_key"), key, 0600); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/onion/%s:%d", id, onionPort), nil
+}
+
+// torSwarm returns the swarm addresses of a node on repoDir given its clear
+// ones: the onion address alone for TorOnly nodes, both for TorDualStack
+func (o Options) torSwarm(repoDir string, clear []string) ([]string, error) {
+	if o.Tor == NoTor {
+		return clear, nil
+	}
+	onion, err := onionAddr(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	if o.Tor == TorOnly {
+		return []string{onion}, nil
+	}
+	return append(append([]string(nil), clear...), onion), nil
+}
+
+// SwarmKeyFile is where in the repo the node reads the key of its private
+// swarm from
+const SwarmKeyFile = "swarm.key"
+
+// SwarmKey returns the pre-shared key of the named private swarm in the
+// format of SwarmKeyFile. It is derived from the name, so every node given
+// the same name ends up in the same swarm.
+func SwarmKey(name string) []byte {
+	sum := sha256.Sum256([]byte("openbazaar-test-nodes swarm " + name))
+	return []byte("/key/swarm/psk/1.0.0/\n/base16/\n" + hex.EncodeToString(sum[:]) + "\n")
+}
+
+// lanSettings are the config values of LANOnly nodes by dotted path
+var lanSettings = []struct {
+	path  string
+	value interface{}
+}{
+	{"Bootstrap", []interface{}{}},
+	{"Discovery.MDNS.Enabled", true},
+	{"Discovery.MDNS.Interval", 1},
+	{"Swarm.DisableNatPortMap", true},
+	{"Crosspost-gateways", []interface{}{}},
+	{"Resolver", ""},
+}
+
+// lanOnly returns the config with lanSettings applied
+func lanOnly(cfg map[string]interface{}) (map[string]interface{}, error) {
+	var updated interface{} = cfg
+	for _, s := range lanSettings {
+		var err error
+		if updated, err = setPath(updated, strings.Split(s.path, "."), s.value); err != nil {
+			return nil, fmt.Errorf("%s: %s", s.path, err)
+		}
+	}
+	return updated.(map[string]interface{}), nil
+}
+
+// Configure isolates the repo in repoDir the way Launch does, with the given
+// API and swarm listen addresses, for runners that start nodes themselves
+func Configure(repoDir, gateway string, swarm []string, opts ...Option) error {
+	return configure(repoDir, gateway, swarm, newOptions(opts))
+}
+
+// RepoPeerID reads the peer ID of the repo's identity from its config
+func RepoPeerID(repoDir string) (string, error) {
+	cfgPath := filepath.Join(repoDir, "config")
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return "", err
+	}
+	var cfg struct {
+		Identity struct {
+			PeerID string
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return "", err
+	}
+	if cfg.Identity.PeerID == "" {
+		return "", fmt.Errorf("%s has no peer ID", cfgPath)
+	}
+	return cfg.Identity.PeerID, nil
+}
+
+// setConfig sets the value at the dotted path of the repo config, e.g.
+// Resolver or Addresses.Swarm.1, and reports whether the config changed. A
+// nil value deletes the key or array element.
+func setConfig(repoDir, path string, value interface{}) (bool, error) {
+	cfgPath := filepath.Join(repoDir, "config")
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		return false, err
+	}
+	var cfg interface{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return false, err
+	}
+	// Round trip the value so it compares equal to what the config holds
+	if value != nil {
+		vb, err := json.Marshal(value)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(vb, &value); err != nil {
+			return false, err
+		}
+	}
+	updated, err := setPath(cfg, strings.Split(path, "."), value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", path, err)
+	}
+	if reflect.DeepEqual(updated, cfg) {
+		return false, nil
+	}
+	out, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(cfgPath, out, 0600)
+}
+
+// setPath returns a copy of node with the value at path set, or deleted if
+// value is nil. Missing objects along the path are created.
+func setPath(node interface{}, path []string, value interface{}) (interface{}, error) {
+	key := path[0]
+	switch n := node.(type) {
+	case nil:
+		if value == nil {
+			return nil, nil
+		}
+		return setPath(map[string]interface{}{}, path, value)
+	case map[string]interface{}:
+		if _, ok := n[key]; !ok && value == nil {
+			return n, nil
+		}
+		ret := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			ret[k] = v
+		}
+		if len(path) > 1 {
+			child, err := setPath(n[key], path[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			ret[key] = child
+		} else if value == nil {
+			delete(ret, key)
+		} else {
+			ret[key] = value
+		}
+		return ret, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return nil, fmt.Errorf("no element %s in an array of %d", key, len(n))
+		}
+		ret := append([]interface{}(nil), n...)
+		if len(path) > 1 {
+			child, err := setPath(n[i], path[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = child
+		} else if value == nil {
+			ret = append(ret[:i], ret[i+1:]...)
+		} else {
+			ret[i] = value
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("%s is inside a %T", key, node)
+}
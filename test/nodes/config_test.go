@@ -0,0 +1,447 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yawning/bulb/utils/pkcs1"
+)
+
+const repoConfig = `{
+	"Addresses": {
+		"Gateway": "/ip4/127.0.0.1/tcp/4002",
+		"Swarm": ["/ip4/0.0.0.0/tcp/4001", "/ip6/::/tcp/4001"]
+	},
+	"Bootstrap": ["/ip4/107.170.133.32/tcp/4001/ipfs/QmUZRGLhcKXF1JyuaHgKm23LvqcoMYwtb9jmh8CkP4og3K"]
+}`
+
+func TestConfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	seed := "/ip6/::1/tcp/5001/ipfs/QmUZRGLhcKXF1JyuaHgKm23LvqcoMYwtb9jmh8CkP4og3K"
+	for _, c := range []struct {
+		opts    []Option
+		gateway string
+		swarm   []interface{}
+		boot    []interface{}
+	}{
+		{nil, "/ip4/127.0.0.1/tcp/6002", []interface{}{"/ip4/127.0.0.1/tcp/6001"}, []interface{}{}},
+		{[]Option{WithIPv6Only(), WithBootstrap(seed)}, "/ip6/::1/tcp/6002", []interface{}{"/ip6/::1/tcp/6001"}, []interface{}{seed}},
+		{[]Option{WithDualStack()}, "/ip4/127.0.0.1/tcp/6002", []interface{}{"/ip4/127.0.0.1/tcp/6001", "/ip6/::1/tcp/6001"}, []interface{}{}},
+	} {
+		cfgPath := filepath.Join(dir, "config")
+		if err := ioutil.WriteFile(cfgPath, []byte(repoConfig), 0600); err != nil {
+			t.Fatal(err)
+		}
+		o := newOptions(c.opts)
+		if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(cfgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg struct {
+			Addresses struct {
+				Gateway string
+				Swarm   []interface{}
+			}
+			Bootstrap []interface{}
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Addresses.Gateway != c.gateway {
+			t.Errorf("%s: expected gateway %s, got %s", o.Family, c.gateway, cfg.Addresses.Gateway)
+		}
+		if !reflect.DeepEqual(cfg.Addresses.Swarm, c.swarm) {
+			t.Errorf("%s: expected swarm %v, got %v", o.Family, c.swarm, cfg.Addresses.Swarm)
+		}
+		if !reflect.DeepEqual(cfg.Bootstrap, c.boot) {
+			t.Errorf("%s: expected bootstrap %v, got %v", o.Family, c.boot, cfg.Bootstrap)
+		}
+	}
+}
+
+func TestUnixSocketAPI(t *testing.T) {
+	gateway, c, err := newOptions([]Option{WithUnixSocket()}).api("/tmp/node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway != "/unix/tmp/node1/api.sock" {
+		t.Errorf("Expected gateway /unix/tmp/node1/api.sock, got %s", gateway)
+	}
+	if c.SocketPath != "/tmp/node1/api.sock" {
+		t.Errorf("Expected client on /tmp/node1/api.sock, got %s", c.SocketPath)
+	}
+}
+
+func TestConfigureRegtestWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config")
+	withWallet := repoConfig[:len(repoConfig)-1] + `, "Wallet": {"TrustedPeer": "", "FeeAPI": "https://btc.fees.openbazaar.org"}}`
+	if err := ioutil.WriteFile(cfgPath, []byte(withWallet), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions([]Option{WithRegtestWallet("127.0.0.1:18444")})
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Wallet struct {
+			TrustedPeer string
+			FeeAPI      string
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Wallet.TrustedPeer != "127.0.0.1:18444" || cfg.Wallet.FeeAPI != "" {
+		t.Errorf("Expected the wallet to sync from 127.0.0.1:18444 without a fee API, got %+v", cfg.Wallet)
+	}
+}
+
+func TestConfigureMockWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config")
+	withWallet := repoConfig[:len(repoConfig)-1] + `, "Wallet": {"Type": "spvwallet", "TrustedPeer": ""}}`
+	if err := ioutil.WriteFile(cfgPath, []byte(withWallet), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions([]Option{WithMockWallet("127.0.0.1:7000")})
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Wallet struct {
+			Type        string
+			TrustedPeer string
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Wallet.Type != MockWallet || cfg.Wallet.TrustedPeer != "127.0.0.1:7000" {
+		t.Errorf("Expected a mock wallet on the ledger at 127.0.0.1:7000, got %+v", cfg.Wallet)
+	}
+}
+
+func TestConfigureCoinWallets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config")
+	withWallets := repoConfig[:len(repoConfig)-1] + `, "Wallets": {"BTC": {"Type": "API"}, "LTC": {"Type": "API"}, "ZEC": {"Type": "API"}}}`
+	if err := ioutil.WriteFile(cfgPath, []byte(withWallets), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions([]Option{WithCoinWallet("ltc", "127.0.0.1:19444"), WithCoinWallet("ZEC", "127.0.0.1:18344")})
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Wallets map[string]struct {
+			Type        string
+			TrustedPeer string
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if w := cfg.Wallets["LTC"]; w.Type != "SPV" || w.TrustedPeer != "127.0.0.1:19444" {
+		t.Errorf("Expected an SPV LTC wallet syncing from 127.0.0.1:19444, got %+v", w)
+	}
+	if w := cfg.Wallets["ZEC"]; w.Type != "SPV" || w.TrustedPeer != "127.0.0.1:18344" {
+		t.Errorf("Expected an SPV ZEC wallet syncing from 127.0.0.1:18344, got %+v", w)
+	}
+	if w := cfg.Wallets["BTC"]; w.Type != "API" || w.TrustedPeer != "" {
+		t.Errorf("Expected the BTC wallet left alone, got %+v", w)
+	}
+
+	// A release with a single wallet cannot run other coins
+	if err := ioutil.WriteFile(cfgPath, []byte(repoConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err == nil || !strings.Contains(err.Error(), "bitcoin wallet only") {
+		t.Errorf("Expected a config without Wallets to be refused, got %v", err)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(repoConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		path    string
+		value   interface{}
+		changed bool
+	}{
+		{"Resolver", "https://resolver.example.org", true},
+		{"Resolver", "https://resolver.example.org", false},
+		{"Addresses.Swarm.1", nil, true},
+		{"Bootstrap", []string{}, true},
+		{"Tor-config.TorControl", "127.0.0.1:9151", true},
+		{"Dropbox-api-token.Token", nil, false},
+	} {
+		changed, err := setConfig(dir, c.path, c.value)
+		if err != nil {
+			t.Fatalf("%s: %s", c.path, err)
+		}
+		if changed != c.changed {
+			t.Errorf("%s: expected changed %v, got %v", c.path, c.changed, changed)
+		}
+	}
+	if _, err := setConfig(dir, "Addresses.Swarm.3", "/ip4/0.0.0.0/tcp/4001"); err == nil {
+		t.Error("Expected setting past the end of an array to fail")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"Addresses": map[string]interface{}{
+			"Gateway": "/ip4/127.0.0.1/tcp/4002",
+			"Swarm":   []interface{}{"/ip4/0.0.0.0/tcp/4001"},
+		},
+		"Bootstrap":  []interface{}{},
+		"Resolver":   "https://resolver.example.org",
+		"Tor-config": map[string]interface{}{"TorControl": "127.0.0.1:9151"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Expected config %v, got %v", want, cfg)
+	}
+
+	// A stopped node only has its config changed
+	exited := make(chan struct{})
+	close(exited)
+	p := &Process{RepoDir: dir, exited: exited}
+	if err := p.SetConfig(context.Background(), "Resolver", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigureLANOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(cfgPath, []byte(repoConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	seed := "/ip4/127.0.0.1/tcp/5001/ipfs/QmUZRGLhcKXF1JyuaHgKm23LvqcoMYwtb9jmh8CkP4og3K"
+	o := newOptions([]Option{WithLANOnly(), WithBootstrap(seed)})
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if boot := cfg["Bootstrap"].([]interface{}); len(boot) != 0 {
+		t.Errorf("Expected no bootstrap peers, got %v", boot)
+	}
+	mdns := cfg["Discovery"].(map[string]interface{})["MDNS"].(map[string]interface{})
+	if mdns["Enabled"] != true || mdns["Interval"] != float64(1) {
+		t.Errorf("Expected mDNS every second, got %v", mdns)
+	}
+	if cfg["Swarm"].(map[string]interface{})["DisableNatPortMap"] != true || cfg["Resolver"] != "" {
+		t.Errorf("Expected nothing reaching beyond the LAN, got %s", b)
+	}
+	if addrs := cfg["Addresses"].(map[string]interface{}); addrs["Gateway"] != "/ip4/127.0.0.1/tcp/6002" {
+		t.Errorf("Expected the addresses set as usual, got %v", addrs)
+	}
+}
+
+func TestConfigureSwarm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(repoConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, SwarmKeyFile)
+	for _, swarm := range []string{"a", "b", ""} {
+		o := newOptions([]Option{WithSwarm(swarm)})
+		if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+			t.Fatal(err)
+		}
+		key, err := ioutil.ReadFile(keyPath)
+		if swarm == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected no swarm key in the public swarm, got %q, %v", key, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(key, SwarmKey(swarm)) {
+			t.Errorf("Swarm %s: expected key %q, got %q", swarm, SwarmKey(swarm), key)
+		}
+		lines := strings.Split(string(key), "\n")
+		if len(lines) != 4 || lines[0] != "/key/swarm/psk/1.0.0/" || lines[1] != "/base16/" || len(lines[2]) != 64 {
+			t.Errorf("Swarm %s: malformed key %q", swarm, key)
+		}
+	}
+	if bytes.Equal(SwarmKey("a"), SwarmKey("b")) {
+		t.Error("Expected swarms a and b to have different keys")
+	}
+}
+
+func TestConfigureTor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(cfgPath, []byte(repoConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var onion string
+	for _, c := range []struct {
+		tor   Tor
+		swarm int
+	}{
+		{TorOnly, 1},
+		{TorDualStack, 2},
+		{TorOnly, 1},
+	} {
+		o := newOptions([]Option{WithTor(c.tor, "127.0.0.1:9151")})
+		if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(cfgPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg struct {
+			Addresses struct {
+				Swarm []string
+			}
+			Tor map[string]string `json:"Tor-config"`
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			t.Fatal(err)
+		}
+		swarm := cfg.Addresses.Swarm
+		if len(swarm) != c.swarm || !strings.HasPrefix(swarm[len(swarm)-1], "/onion/") {
+			t.Fatalf("%s: expected %d swarm addresses ending with the onion one, got %v", c.tor, c.swarm, swarm)
+		}
+		if onion == "" {
+			onion = swarm[len(swarm)-1]
+		} else if swarm[len(swarm)-1] != onion {
+			t.Errorf("%s: expected the onion address to stay %s, got %s", c.tor, onion, swarm[len(swarm)-1])
+		}
+		if cfg.Tor["TorControl"] != "127.0.0.1:9151" {
+			t.Errorf("%s: expected the control port set, got %v", c.tor, cfg.Tor)
+		}
+	}
+
+	id := strings.TrimSuffix(strings.TrimPrefix(onion, "/onion/"), ":4003")
+	b, err := ioutil.ReadFile(filepath.Join(dir, id+".onion_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatalf("Expected a PEM key, got %q", b)
+	}
+	priv, _, err := pkcs1.DecodePrivateKeyDER(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID, _ := pkcs1.OnionAddr(&priv.PublicKey); keyID != id {
+		t.Errorf("Expected the key of %s, got the key of %s", id, keyID)
+	}
+}
+
+func TestConfigureFeeAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config")
+	withWallet := repoConfig[:len(repoConfig)-1] + `, "Wallet": {"TrustedPeer": "", "FeeAPI": "https://btc.fees.openbazaar.org"}}`
+	if err := ioutil.WriteFile(cfgPath, []byte(withWallet), 0600); err != nil {
+		t.Fatal(err)
+	}
+	feeAPI := "http://127.0.0.1:8089/api/v1/fees/recommended"
+	o := newOptions([]Option{WithRegtestWallet("127.0.0.1:18444"), WithFeeAPI(feeAPI)})
+	if err := configure(dir, o.Family.apiAddr(6002), o.Family.swarmAddrs(6001), o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Wallet struct {
+			FeeAPI string
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Wallet.FeeAPI != feeAPI {
+		t.Errorf("Expected the wallet to query %s, got %q", feeAPI, cfg.Wallet.FeeAPI)
+	}
+}
@@ -0,0 +1,561 @@
+package nodes
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// Ports nodes listen on inside their container
+const (
+	dockerAPIPort   = 4002
+	dockerSwarmPort = 4001
+)
+
+// dockerBinary is where the openbazaard binary is mounted in containers
+const dockerBinary = "/usr/local/bin/openbazaard"
+
+// natSubnet is the subnet of the network of the k-th NATed node, which
+// gets the .3 address behind its router at .2
+const natSubnet = "10.77.%d.0/24"
+
+// DockerOptions configures a DockerRunner
+type DockerOptions struct {
+	// Image provides what openbazaard links against; the binary itself is
+	// mounted into the container
+	Image string
+
+	// Network is the bridge network the containers join, created on a new
+	// runner if it does not exist. testnodes when empty.
+	Network string
+
+	// Subnet is the IPv4 subnet nodes get their addresses from when the
+	// runner creates the network, 172.30.0.0/16 when empty
+	Subnet string
+
+	// Docker is the docker CLI, docker on the PATH when empty
+	Docker string
+
+	// Shaping gives containers CAP_NET_ADMIN so the links between nodes can
+	// be delayed and made lossy with Process.ImpairTo, and the bandwidth of
+	// nodes capped with Limits. The image needs tc from iproute2.
+	Shaping bool
+}
+
+// DockerRunner runs every node in a container of its own on one bridge
+// network, so each has its own network namespace and file descriptors.
+// Repos are mounted at their path on the host and the API is published on a
+// loopback port, or served on the unix socket in the repo. A NATed node is
+// put on a bridge network of its own instead, behind a router container on
+// the test network. Docker's resolver
+// answers for the hostname of every node on the network. Nodes listen on
+// IPv4 only and reach the host at the network's gateway, which is where a
+// regtest bitcoind has to be given for WithRegtestWallet.
+type DockerRunner struct {
+	opts    DockerOptions
+	created bool
+
+	lock   sync.Mutex
+	subnet *net.IPNet
+	next   net.IP
+	ips    map[string]net.IP
+	ports  map[string]int
+	// links are the link impairments of each node by destination IP
+	links map[string]map[string]netem.Impairment
+	// caps are the bandwidth caps of each node
+	caps map[string]netem.Caps
+	// nats are the gateways of the NATed nodes, and gateways every one
+	// made so far
+	nats     map[string]*natGateway
+	gateways []*natGateway
+}
+
+// natGateway is the router a NATed node reaches the test network through
+type natGateway struct {
+	nat     NAT
+	network string
+	subnet  string
+	router  string
+	// public is the address of the router on the test network, private on
+	// the network of the node
+	public  net.IP
+	private net.IP
+
+	lock    sync.Mutex
+	started bool
+}
+
+// NewDockerRunner returns a runner for containers of opts.Image, creating
+// the network unless it exists
+func NewDockerRunner(ctx context.Context, opts DockerOptions) (*DockerRunner, error) {
+	if opts.Image == "" {
+		return nil, errors.New("nodes: docker runner needs an image")
+	}
+	if opts.Network == "" {
+		opts.Network = "testnodes"
+	}
+	if opts.Subnet == "" {
+		opts.Subnet = "172.30.0.0/16"
+	}
+	if opts.Docker == "" {
+		opts.Docker = "docker"
+	}
+	r := &DockerRunner{
+		opts:  opts,
+		ips:   make(map[string]net.IP),
+		ports: make(map[string]int),
+		links: make(map[string]map[string]netem.Impairment),
+		caps:  make(map[string]netem.Caps),
+		nats:  make(map[string]*natGateway),
+	}
+	subnet, err := r.docker(ctx, "network", "inspect", "--format", "{{range .IPAM.Config}}{{.Subnet}} {{end}}", opts.Network)
+	if err != nil {
+		if _, err := r.docker(ctx, "network", "create", "--subnet", opts.Subnet, opts.Network); err != nil {
+			return nil, err
+		}
+		r.created = true
+		subnet = opts.Subnet
+	}
+	fields := strings.Fields(subnet)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nodes: docker network %s has no subnet", opts.Network)
+	}
+	if _, r.subnet, err = net.ParseCIDR(fields[0]); err != nil {
+		return nil, err
+	}
+	if r.subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("nodes: docker network %s is not IPv4", opts.Network)
+	}
+	// .1 is the gateway
+	r.next = nextIP(nextIP(r.subnet.IP.To4()))
+	return r, nil
+}
+
+// docker runs a docker command and returns its trimmed output
+func (r *DockerRunner) docker(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, r.opts.Docker, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP(nil), ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// mounts returns the arguments running the binary on repoDir, from the
+// working directory of the harness so relative repo paths resolve
+func (r *DockerRunner) mounts(binary, repoDir string) ([]string, error) {
+	bin, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, err
+	}
+	if bin, err = filepath.Abs(bin); err != nil {
+		return nil, err
+	}
+	repo, err := filepath.Abs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"-v", repo + ":" + repo,
+		"-v", bin + ":" + dockerBinary + ":ro",
+		"-w", wd,
+	}, nil
+}
+
+// Init creates the repo in a throwaway container without network
+func (r *DockerRunner) Init(ctx context.Context, binary, repoDir string, testnet bool) error {
+	if err := os.MkdirAll(repoDir, 0700); err != nil {
+		return err
+	}
+	mounts, err := r.mounts(binary, repoDir)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"run", "--rm", "--network", "none"}, mounts...)
+	args = append(args, r.opts.Image, dockerBinary, "init", "-d", repoDir, "-f")
+	if testnet {
+		args = append(args, "-t")
+	}
+	_, err = r.docker(ctx, args...)
+	return err
+}
+
+// Endpoints gives the node on repoDir the next address of the network, or
+// its NAT gateway the address and the node one behind it. The node keeps
+// them when restarted, as long as it is not launched again.
+func (r *DockerRunner) Endpoints(repoDir string, o Options) (Endpoints, error) {
+	if o.Family != IPv4 {
+		return Endpoints{}, fmt.Errorf("nodes: docker nodes listen on IPv4 only, not %s", o.Family)
+	}
+	if o.Tor != NoTor {
+		return Endpoints{}, errors.New("nodes: Tor reaches onion services on the loopback of their node, which only local nodes share with it")
+	}
+	r.lock.Lock()
+	ip := r.next
+	if !r.subnet.Contains(ip) {
+		r.lock.Unlock()
+		return Endpoints{}, fmt.Errorf("nodes: docker network %s is out of addresses", r.opts.Network)
+	}
+	r.next = nextIP(ip)
+	delete(r.ports, repoDir)
+	delete(r.nats, repoDir)
+	if o.NAT != NoNAT {
+		k := len(r.gateways) + 1
+		if k > 255 {
+			r.lock.Unlock()
+			return Endpoints{}, errors.New("nodes: out of NAT subnets")
+		}
+		_, subnet, _ := net.ParseCIDR(fmt.Sprintf(natSubnet, k))
+		router := nextIP(nextIP(subnet.IP.To4()))
+		gw := &natGateway{
+			nat:     o.NAT,
+			network: fmt.Sprintf("%s-nat-%d", r.opts.Network, k),
+			subnet:  subnet.String(),
+			router:  containerName(repoDir) + "-nat",
+			public:  ip,
+			private: router,
+		}
+		r.gateways = append(r.gateways, gw)
+		r.nats[repoDir] = gw
+		ip = nextIP(router)
+	}
+	r.ips[repoDir] = ip
+	r.lock.Unlock()
+
+	ep := Endpoints{
+		Gateway:    fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", dockerAPIPort),
+		Swarm:      []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", dockerSwarmPort)},
+		SwarmAddrs: []string{fmt.Sprintf("/ip4/%s/tcp/%d", ip, dockerSwarmPort)},
+	}
+	if o.UnixSocket {
+		gateway, c, err := o.api(repoDir)
+		if err != nil {
+			return Endpoints{}, err
+		}
+		ep.Gateway, ep.Client = gateway, c
+		return ep, nil
+	}
+	port, err := freePort(IPv4)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	r.lock.Lock()
+	r.ports[repoDir] = port
+	r.lock.Unlock()
+	ep.Client = client.New(fmt.Sprintf("http://127.0.0.1:%d", port))
+	return ep, nil
+}
+
+// containerName names the container of the node on repoDir, the same on
+// every run
+func containerName(repoDir string) string {
+	abs, err := filepath.Abs(repoDir)
+	if err != nil {
+		abs = repoDir
+	}
+	sum := sha1.Sum([]byte(abs))
+	return fmt.Sprintf("openbazaard-%s-%x", filepath.Base(abs), sum[:4])
+}
+
+// Run starts the node's container attached, so its output goes to the log
+// and it is removed once it exits
+func (r *DockerRunner) Run(c Command) (Instance, error) {
+	r.lock.Lock()
+	ip, port, gw := r.ips[c.RepoDir], r.ports[c.RepoDir], r.nats[c.RepoDir]
+	r.lock.Unlock()
+	if ip == nil {
+		return nil, fmt.Errorf("nodes: %s has no docker endpoints", c.RepoDir)
+	}
+	caps := c.Limits.caps()
+	if caps != (netem.Caps{}) && !r.opts.Shaping {
+		return nil, errors.New("nodes: capping bandwidth needs a docker runner with Shaping")
+	}
+	mounts, err := r.mounts(c.Binary, c.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+	network := r.opts.Network
+	if gw != nil {
+		if err := r.startGateway(gw); err != nil {
+			return nil, err
+		}
+		network = gw.network
+	}
+	name := containerName(c.RepoDir)
+	// The container of a killed run may not be removed yet
+	r.docker(context.Background(), "rm", "-f", name)
+
+	args := append([]string{"run", "--rm", "--name", name, "--network", network, "--ip", ip.String()}, mounts...)
+	if c.Host != "" {
+		args = append(args, "--hostname", c.Host, "--network-alias", c.Host)
+	}
+	if port != 0 {
+		args = append(args, "-p", fmt.Sprintf("127.0.0.1:%d:%d", port, dockerAPIPort))
+	}
+	for _, e := range c.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, dockerLimits(c.Limits)...)
+	if r.opts.Shaping {
+		args = append(args, "--cap-add", "NET_ADMIN")
+	}
+	args = append(args, r.opts.Image, dockerBinary)
+	args = append(args, c.Args...)
+
+	log, err := openLog(c.Log)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(r.opts.Docker, args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	// A new container starts without shaping
+	r.lock.Lock()
+	delete(r.links, c.RepoDir)
+	r.caps[c.RepoDir] = caps
+	r.lock.Unlock()
+	ct := &container{runner: r, name: name, cmd: cmd, log: log}
+	if caps != (netem.Caps{}) || gw != nil {
+		if err := r.prepare(ct, c.RepoDir, gw); err != nil {
+			ct.Kill()
+			ct.Wait()
+			return nil, err
+		}
+	}
+	return ct, nil
+}
+
+// prepare routes the node on repoDir through its NAT gateway, if any, and
+// applies its bandwidth caps once its container runs, which is only shortly
+// after docker run starts
+func (r *DockerRunner) prepare(c *container, repoDir string, gw *natGateway) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for {
+		_, err := r.docker(ctx, "exec", c.name, "true")
+		if err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("nodes: container %s never ran to be prepared: %s", c.name, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if gw != nil {
+		if _, err := r.docker(ctx, "exec", "--privileged", c.name, "ip", "route", "replace", "default", "via", gw.private.String()); err != nil {
+			return err
+		}
+	}
+	r.lock.Lock()
+	caps := r.caps[repoDir]
+	r.lock.Unlock()
+	if caps == (netem.Caps{}) {
+		return nil
+	}
+	return r.shape(ctx, repoDir)
+}
+
+// startGateway creates the network of a NATed node and starts its router,
+// unless they run already. The router masquerades what the node sends to
+// the test network, with random ports for a SymmetricNAT, and forwards
+// nothing the other way but replies.
+func (r *DockerRunner) startGateway(gw *natGateway) error {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+	if gw.started {
+		return nil
+	}
+	ctx := context.Background()
+	// A router left by a killed harness would hold the addresses
+	r.docker(ctx, "rm", "-f", gw.router)
+	r.docker(ctx, "network", "rm", gw.network)
+	if _, err := r.docker(ctx, "network", "create", "--subnet", gw.subnet, gw.network); err != nil {
+		return err
+	}
+	gw.started = true
+	masquerade := []string{"exec", gw.router, "iptables", "-t", "nat", "-A", "POSTROUTING", "-s", gw.subnet, "!", "-d", gw.subnet, "-j", "MASQUERADE"}
+	if gw.nat == SymmetricNAT {
+		masquerade = append(masquerade, "--random")
+	}
+	for _, args := range [][]string{
+		{"run", "-d", "--rm", "--name", gw.router, "--network", gw.network, "--ip", gw.private.String(),
+			"--cap-add", "NET_ADMIN", "--sysctl", "net.ipv4.ip_forward=1", r.opts.Image, "tail", "-f", "/dev/null"},
+		{"network", "connect", "--ip", gw.public.String(), r.opts.Network, gw.router},
+		masquerade,
+	} {
+		if _, err := r.docker(ctx, args...); err != nil {
+			return fmt.Errorf("nodes: starting the %s NAT gateway %s: %s", gw.nat, gw.router, err)
+		}
+	}
+	return nil
+}
+
+// dockerLimits are the docker run arguments applying the limits. Swap is
+// capped along with memory.
+func dockerLimits(l Limits) []string {
+	var args []string
+	if l.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(l.CPUs, 'f', -1, 64))
+	}
+	if l.Memory > 0 {
+		mem := strconv.FormatUint(l.Memory, 10)
+		args = append(args, "--memory", mem, "--memory-swap", mem)
+	}
+	return args
+}
+
+// impair sets the impairment of the packets the node on from sends to the
+// node on to, the zero Impairment removing it, and reshapes from
+func (r *DockerRunner) impair(ctx context.Context, from, to string, i netem.Impairment) error {
+	if !r.opts.Shaping {
+		return errors.New("nodes: impairing links needs a docker runner with Shaping")
+	}
+	r.lock.Lock()
+	dst := r.ips[to]
+	if r.ips[from] == nil || dst == nil {
+		r.lock.Unlock()
+		return fmt.Errorf("nodes: %s or %s has no docker endpoints", from, to)
+	}
+	impaired := r.links[from]
+	if impaired == nil {
+		impaired = make(map[string]netem.Impairment)
+		r.links[from] = impaired
+	}
+	if i == (netem.Impairment{}) {
+		delete(impaired, dst.String())
+	} else {
+		impaired[dst.String()] = i
+	}
+	r.lock.Unlock()
+	return r.shape(ctx, from)
+}
+
+// shape applies the bandwidth caps and link impairments of the node on
+// repoDir to its container
+func (r *DockerRunner) shape(ctx context.Context, repoDir string) error {
+	r.lock.Lock()
+	var links []netem.Link
+	for ip, i := range r.links[repoDir] {
+		links = append(links, netem.Link{Dst: ip, Impairment: i})
+	}
+	caps := r.caps[repoDir]
+	r.lock.Unlock()
+	sort.Slice(links, func(i, j int) bool { return links[i].Dst < links[j].Dst })
+	return netem.Shape(ctx, []string{r.opts.Docker, "exec", containerName(repoDir)}, "eth0", caps, links)
+}
+
+// Close removes the NAT gateways, and the network if the runner created it.
+// Nodes have to be stopped first.
+func (r *DockerRunner) Close() error {
+	var first error
+	r.lock.Lock()
+	gateways := r.gateways
+	r.lock.Unlock()
+	for _, gw := range gateways {
+		gw.lock.Lock()
+		if gw.started {
+			r.docker(context.Background(), "rm", "-f", gw.router)
+			if _, err := r.docker(context.Background(), "network", "rm", gw.network); err != nil && first == nil {
+				first = err
+			}
+		}
+		gw.lock.Unlock()
+	}
+	if !r.created {
+		return first
+	}
+	if _, err := r.docker(context.Background(), "network", "rm", r.opts.Network); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+// container is a node running in docker
+type container struct {
+	runner *DockerRunner
+	name   string
+	cmd    *exec.Cmd
+	log    *os.File
+}
+
+func (c *container) Wait() error {
+	defer c.log.Close()
+	return c.cmd.Wait()
+}
+
+func (c *container) do(args ...string) error {
+	_, err := c.runner.docker(context.Background(), append(args, c.name)...)
+	return err
+}
+
+func (c *container) Kill() error   { return c.do("kill") }
+func (c *container) Pause() error  { return c.do("pause") }
+func (c *container) Resume() error { return c.do("unpause") }
+
+// MemoryUsage returns the memory docker accounts to the container
+func (c *container) MemoryUsage() (uint64, error) {
+	out, err := c.runner.docker(context.Background(), "stats", "--no-stream", "--format", "{{.MemUsage}}", c.name)
+	if err != nil {
+		return 0, err
+	}
+	return parseMemUsage(out)
+}
+
+// memUnits are the suffixes docker stats prints sizes with
+var memUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseMemUsage reads the usage from a docker stats MemUsage column, e.g.
+// 52.3MiB / 1.944GiB
+func parseMemUsage(s string) (uint64, error) {
+	usage := strings.TrimSpace(strings.SplitN(s, "/", 2)[0])
+	for _, u := range memUnits {
+		if strings.HasSuffix(usage, u.suffix) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(usage, u.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("nodes: memory usage %q: %s", s, err)
+			}
+			return uint64(f * u.scale), nil
+		}
+	}
+	return 0, fmt.Errorf("nodes: memory usage %q has no unit", s)
+}
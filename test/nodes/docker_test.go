@@ -0,0 +1,230 @@
+package nodes
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+func TestParseMemUsage(t *testing.T) {
+	for s, want := range map[string]uint64{
+		"52.5MiB / 1.944GiB": 52.5 * (1 << 20),
+		"1GiB / 2GiB":        1 << 30,
+		"800kB / 1GB":        800e3,
+		"12B / 1GiB":         12,
+	} {
+		got, err := parseMemUsage(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %d, got %d", s, want, got)
+		}
+	}
+	for _, s := range []string{"", "--", "12 parsecs / 1GiB"} {
+		if _, err := parseMemUsage(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestDockerEndpoints(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/30")
+	r := &DockerRunner{subnet: subnet, next: net.ParseIP("172.30.0.2").To4(), ips: make(map[string]net.IP), ports: make(map[string]int)}
+	ep, err := r.Endpoints("/tmp/node-1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.SwarmAddrs[0] != "/ip4/172.30.0.2/tcp/4001" || ep.Swarm[0] != "/ip4/0.0.0.0/tcp/4001" {
+		t.Errorf("Unexpected swarm addresses %v, listening on %v", ep.SwarmAddrs, ep.Swarm)
+	}
+	if !strings.HasPrefix(ep.Client.BaseURL, "http://127.0.0.1:") {
+		t.Errorf("Expected the API on loopback, got %s", ep.Client.BaseURL)
+	}
+	ep, err = r.Endpoints("/tmp/node-2", Options{UnixSocket: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Client.SocketPath != "/tmp/node-2/api.sock" || ep.SwarmAddrs[0] != "/ip4/172.30.0.3/tcp/4001" {
+		t.Errorf("Unexpected endpoints %+v", ep)
+	}
+	if _, err := r.Endpoints("/tmp/node-3", Options{}); err == nil {
+		t.Error("Expected the subnet to be exhausted")
+	}
+	if _, err := r.Endpoints("/tmp/node-4", Options{Family: IPv6}); err == nil {
+		t.Error("Expected IPv6 to be rejected")
+	}
+	if a, b := containerName("/tmp/node-1"), containerName("/tmp/node-1"); a != b || !strings.HasPrefix(a, "openbazaard-node-1-") {
+		t.Errorf("Expected a stable container name, got %s and %s", a, b)
+	}
+}
+
+func TestDockerLimits(t *testing.T) {
+	args := strings.Join(dockerLimits(Limits{CPUs: 0.5, Memory: 512 << 20}), " ")
+	if args != "--cpus 0.5 --memory 536870912 --memory-swap 536870912" {
+		t.Errorf("Unexpected limits %s", args)
+	}
+	if len(dockerLimits(Limits{})) != 0 {
+		t.Error("Expected no limits by default")
+	}
+}
+
+func TestDockerImpair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-impair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The fake docker logs its arguments
+	fake := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "log") + "\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	r := &DockerRunner{
+		opts:  DockerOptions{Docker: fake},
+		ips:   map[string]net.IP{"/tmp/a": net.ParseIP("172.30.0.2"), "/tmp/b": net.ParseIP("172.30.0.3")},
+		links: make(map[string]map[string]netem.Impairment),
+		caps:  map[string]netem.Caps{"/tmp/a": {Egress: 256000}},
+	}
+	lossy := netem.Impairment{Delay: 100 * time.Millisecond, Loss: 3}
+	if err := r.impair(context.Background(), "/tmp/a", "/tmp/b", lossy); err == nil {
+		t.Error("Expected impairing without Shaping to fail")
+	}
+	r.opts.Shaping = true
+	if err := r.impair(context.Background(), "/tmp/a", "/tmp/c", lossy); err == nil {
+		t.Error("Expected impairing the link to an unknown node to fail")
+	}
+	if err := r.impair(context.Background(), "/tmp/a", "/tmp/b", lossy); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.impair(context.Background(), "/tmp/a", "/tmp/b", netem.Impairment{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := "exec " + containerName("/tmp/a") + " tc "
+	// The cap of the node stays when its last link is cleared
+	want := []string{
+		exec + "qdisc del dev eth0 root",
+		exec + "qdisc del dev eth0 ingress",
+		exec + "qdisc add dev eth0 root handle 1: tbf rate 256000bit burst 3200 latency 500ms",
+		exec + "qdisc add dev eth0 parent 1:1 handle 2: prio bands 4",
+		exec + "qdisc add dev eth0 parent 2:4 handle 14: netem delay 100000us loss 3%",
+		exec + "filter add dev eth0 protocol ip parent 2:0 prio 1 u32 match ip dst 172.30.0.3/32 flowid 2:4",
+		exec + "qdisc del dev eth0 root",
+		exec + "qdisc del dev eth0 ingress",
+		exec + "qdisc add dev eth0 root handle 1: tbf rate 256000bit burst 3200 latency 500ms",
+	}
+	if got := strings.TrimSpace(string(b)); got != strings.Join(want, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), got)
+	}
+}
+
+func TestDockerHostname(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-hostname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fake := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "log") + "\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(dir, "vendor-1")
+	host := Hostname(repo)
+	if host != "vendor-1.obtest.local" {
+		t.Errorf("Expected the repo to name the host, got %s", host)
+	}
+	r := &DockerRunner{
+		opts:  DockerOptions{Docker: fake, Network: "testnodes", Image: "image"},
+		ips:   map[string]net.IP{repo: net.ParseIP("172.30.0.2")},
+		links: make(map[string]map[string]netem.Impairment),
+		caps:  make(map[string]netem.Caps),
+	}
+	inst, err := r.Run(Command{Binary: fake, RepoDir: repo, Log: filepath.Join(dir, "node.log"), Host: host})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "--hostname "+host+" --network-alias "+host) {
+		t.Errorf("Expected the container to be resolvable as %s, ran\n%s", host, b)
+	}
+}
+
+func TestDockerNAT(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-nat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fake := filepath.Join(dir, "docker")
+	script := "#!/bin/sh\necho \"$@\" >> " + filepath.Join(dir, "log") + "\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	r := &DockerRunner{
+		opts:   DockerOptions{Docker: fake, Network: "testnodes", Image: "image"},
+		subnet: subnet,
+		next:   net.ParseIP("172.30.0.2").To4(),
+		ips:    make(map[string]net.IP),
+		ports:  make(map[string]int),
+		links:  make(map[string]map[string]netem.Impairment),
+		caps:   make(map[string]netem.Caps),
+		nats:   make(map[string]*natGateway),
+	}
+	repo := filepath.Join(dir, "vendor-1")
+	ep, err := r.Endpoints(repo, Options{NAT: SymmetricNAT, UnixSocket: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.SwarmAddrs[0] != "/ip4/10.77.1.3/tcp/4001" {
+		t.Errorf("Expected the node behind its gateway, got %v", ep.SwarmAddrs)
+	}
+	inst, err := r.Run(Command{Binary: fake, RepoDir: repo, Log: filepath.Join(dir, "node.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(b)
+	node, router := containerName(repo), containerName(repo)+"-nat"
+	for _, want := range []string{
+		"network create --subnet 10.77.1.0/24 testnodes-nat-1",
+		"run -d --rm --name " + router + " --network testnodes-nat-1 --ip 10.77.1.2 ",
+		"network connect --ip 172.30.0.2 testnodes " + router,
+		"exec " + router + " iptables -t nat -A POSTROUTING -s 10.77.1.0/24 ! -d 10.77.1.0/24 -j MASQUERADE --random",
+		"run --rm --name " + node + " --network testnodes-nat-1 --ip 10.77.1.3 ",
+		"exec --privileged " + node + " ip route replace default via 10.77.1.2",
+		"network rm testnodes-nat-1",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("Expected %q among\n%s", want, log)
+		}
+	}
+}
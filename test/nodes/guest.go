@@ -0,0 +1,44 @@
+package nodes
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+)
+
+// Guest is a throwaway node for a one-off visit, e.g. a guest checkout: a
+// fresh identity on a temp repo that never gets a profile, and is removed
+// when the guest is discarded so nothing of it outlives the visit
+type Guest struct {
+	*Process
+	dir string
+}
+
+// StartGuest inits a repo in a new temp dir and starts a node on it like
+// Start, usually WithBootstrap the nodes the guest is to reach
+func StartGuest(ctx context.Context, binary string, opts ...Option) (*Guest, error) {
+	dir, err := ioutil.TempDir("", "testnodes-guest")
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	if err := o.runner().Init(ctx, binary, dir, o.Testnet); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	p, err := Start(ctx, binary, dir, opts...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &Guest{Process: p, dir: dir}, nil
+}
+
+// Discard stops the guest and removes its repo
+func (g *Guest) Discard() error {
+	err := g.Stop()
+	if rerr := os.RemoveAll(g.dir); err == nil {
+		err = rerr
+	}
+	return err
+}
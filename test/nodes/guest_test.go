@@ -0,0 +1,29 @@
+package nodes
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestStartGuestCleansUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", dir)
+
+	if _, err := StartGuest(context.Background(), "/nonexistent/openbazaard"); err == nil {
+		t.Fatal("Expected a guest without a binary to fail")
+	}
+	left, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Errorf("Expected the guest repo to be removed, found %s", left[0].Name())
+	}
+}
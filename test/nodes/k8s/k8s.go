@@ -0,0 +1,389 @@
+// Package k8s runs test networks as pods on a Kubernetes cluster, for runs
+// too large for one host. A controller creates the pods, waits for every
+// node to initialize its repo, collects their peer IDs and pod addresses and
+// wires the bootstrap lists before any node starts, so the network comes up
+// connected without a well-known seed.
+//
+// The cluster is driven through kubectl. Images need openbazaard, sh and cat.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// Ports nodes listen on inside their pod
+const (
+	apiPort   = 4002
+	swarmPort = 4001
+)
+
+// repoDir is the repo of a node inside its pod, on an emptyDir volume
+const repoDir = "/repo"
+
+// Options configures a Cluster
+type Options struct {
+	// Image runs the nodes and has openbazaard on its PATH
+	Image string
+
+	// Namespace the pods are created in, default when empty
+	Namespace string
+
+	// Name prefixes the pod names and labels the pods of the run,
+	// testnodes when empty
+	Name string
+
+	// Testnet runs the nodes on testnet. Wallets are always disabled.
+	Testnet bool
+
+	// Features are the experimental features enabled on every node
+	Features []string
+
+	// Bootstrap is how many peers each node bootstraps from, 8 when zero
+	Bootstrap int
+
+	// Seed picks the bootstrap peers, so runs with the same seed wire the
+	// same network
+	Seed int64
+
+	// CPU and Memory are the resource requests of each pod, e.g. 100m and
+	// 256Mi, none when empty
+	CPU    string
+	Memory string
+
+	// InCluster reaches the APIs at the pod addresses, for a harness
+	// running inside the cluster. Otherwise every node gets a kubectl
+	// port-forward.
+	InCluster bool
+
+	// Parallel bounds the kubectl calls in flight, 16 when zero
+	Parallel int
+
+	// ReadyTimeout bounds how long a spawn may take, 10 minutes when zero
+	ReadyTimeout time.Duration
+
+	// Kubectl is the kubectl binary, kubectl on the PATH when empty
+	Kubectl string
+}
+
+// Cluster spawns nodes as pods and removes them together
+type Cluster struct {
+	opts Options
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	nodes []*Node
+}
+
+// New returns a cluster creating pods with opts
+func New(opts Options) (*Cluster, error) {
+	if opts.Image == "" {
+		return nil, errors.New("k8s: an image is needed")
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.Name == "" {
+		opts.Name = "testnodes"
+	}
+	if opts.Bootstrap == 0 {
+		opts.Bootstrap = 8
+	}
+	if opts.Parallel == 0 {
+		opts.Parallel = 16
+	}
+	if opts.ReadyTimeout == 0 {
+		opts.ReadyTimeout = 10 * time.Minute
+	}
+	if opts.Kubectl == "" {
+		opts.Kubectl = "kubectl"
+	}
+	return &Cluster{opts: opts, rand: rand.New(rand.NewSource(opts.Seed))}, nil
+}
+
+// kubectl runs a kubectl command in the namespace with stdin, returning its
+// trimmed output
+func (c *Cluster) kubectl(ctx context.Context, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.opts.Kubectl, append([]string{"-n", c.opts.Namespace}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubectl %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Node is a node running in a pod
+type Node struct {
+	name    string
+	role    string
+	peerID  string
+	ip      string
+	client  *client.Client
+	cluster *Cluster
+	forward *exec.Cmd
+}
+
+func (n *Node) Name() string           { return n.name }
+func (n *Node) Role() string           { return n.role }
+func (n *Node) PeerID() string         { return n.peerID }
+func (n *Node) Client() *client.Client { return n.client }
+
+// PodIP returns the address of the node's pod
+func (n *Node) PodIP() string { return n.ip }
+
+// SwarmAddr returns the address other nodes dial the node at
+func (n *Node) SwarmAddr() string {
+	return fmt.Sprintf("/ip4/%s/tcp/%d/ipfs/%s", n.ip, swarmPort, n.peerID)
+}
+
+// Logs returns what the node printed
+func (n *Node) Logs(ctx context.Context) (string, error) {
+	return n.cluster.kubectl(ctx, nil, "logs", n.name)
+}
+
+// Stop deletes the node's pod. Its repo goes with it, so there is no
+// restarting the node.
+func (n *Node) Stop(ctx context.Context) error {
+	if n.forward != nil {
+		n.forward.Process.Kill()
+		n.forward.Wait()
+	}
+	_, err := n.cluster.kubectl(ctx, nil, "delete", "pod", n.name, "--ignore-not-found", "--wait=false")
+	return err
+}
+
+// Nodes returns every node spawned so far, in spawn order
+func (c *Cluster) Nodes() []*Node {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]*Node(nil), c.nodes...)
+}
+
+// Spawn starts n nodes with the role and waits until all of them answer.
+// Each node bootstraps from the one spawned before it and from random other
+// nodes, old or new, so the network stays connected as it grows.
+func (c *Cluster) Spawn(ctx context.Context, role string, n int) ([]*Node, error) {
+	if n < 1 {
+		return nil, errors.New("k8s: spawn at least one node")
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.opts.ReadyTimeout)
+	defer cancel()
+
+	c.lock.Lock()
+	first := len(c.nodes)
+	batch := make([]*Node, n)
+	for i := range batch {
+		batch[i] = &Node{name: fmt.Sprintf("%s-%s-%d", c.opts.Name, role, first+i+1), role: role, cluster: c}
+	}
+	all := append(append([]*Node(nil), c.nodes...), batch...)
+	wiring := wire(first, len(all), c.opts.Bootstrap, c.rand)
+	c.nodes = all
+	c.lock.Unlock()
+
+	err := c.each(batch, func(i int, nd *Node) error { return c.create(ctx, nd) })
+	if err == nil {
+		err = c.each(batch, func(i int, nd *Node) error {
+			var addrs []string
+			for _, j := range wiring[first+i] {
+				addrs = append(addrs, all[j].SwarmAddr())
+			}
+			return c.start(ctx, nd, addrs)
+		})
+	}
+	if err == nil {
+		err = c.each(batch, func(i int, nd *Node) error { return c.waitReady(ctx, nd) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// each runs fn on the nodes, at most Parallel at a time, and returns the
+// errors of all that failed
+func (c *Cluster) each(batch []*Node, fn func(int, *Node) error) error {
+	sem := make(chan struct{}, c.opts.Parallel)
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, nd := range batch {
+		wg.Add(1)
+		go func(i int, nd *Node) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = fn(i, nd)
+		}(i, nd)
+	}
+	wg.Wait()
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, batch[i].name+": "+err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// create creates the pod and waits until it has an address and the node
+// initialized its repo, then reads the peer ID
+func (c *Cluster) create(ctx context.Context, n *Node) error {
+	manifest, err := c.manifest(n)
+	if err != nil {
+		return err
+	}
+	if _, err := c.kubectl(ctx, manifest, "create", "-f", "-"); err != nil {
+		return err
+	}
+	err = poll(ctx, func() error {
+		ip, err := c.kubectl(ctx, nil, "get", "pod", n.name, "-o", "jsonpath={.status.podIP}")
+		if err != nil {
+			return err
+		}
+		if ip == "" {
+			return errors.New("pod has no address yet")
+		}
+		n.ip = ip
+		_, err = c.kubectl(ctx, nil, "exec", n.name, "--", "test", "-f", repoDir+"/"+initialized)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return c.withConfig(ctx, n, false, func(dir string) error {
+		n.peerID, err = nodes.RepoPeerID(dir)
+		return err
+	})
+}
+
+// start wires the bootstrap list and listen addresses into the node's
+// config and lets it start
+func (c *Cluster) start(ctx context.Context, n *Node, bootstrap []string) error {
+	err := c.withConfig(ctx, n, true, func(dir string) error {
+		opts := []nodes.Option{nodes.WithBootstrap(bootstrap...), nodes.WithTestnet(c.opts.Testnet)}
+		return nodes.Configure(dir,
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", apiPort),
+			[]string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", swarmPort)},
+			opts...)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := c.kubectl(ctx, nil, "exec", n.name, "--", "touch", repoDir+"/"+startFlag); err != nil {
+		return err
+	}
+	if c.opts.InCluster {
+		n.client = client.New(fmt.Sprintf("http://%s:%d", n.ip, apiPort))
+		return nil
+	}
+	return c.portForward(n)
+}
+
+// withConfig copies the node's config into a temp repo dir for fn and, if
+// write is set, back into the pod
+func (c *Cluster) withConfig(ctx context.Context, n *Node, write bool, fn func(dir string) error) error {
+	cfg, err := c.kubectl(ctx, nil, "exec", n.name, "--", "cat", repoDir+"/config")
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "k8s-config")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(cfg), 0600); err != nil {
+		return err
+	}
+	if err := fn(dir); err != nil || !write {
+		return err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = c.kubectl(ctx, b, "exec", "-i", n.name, "--", "sh", "-c", "cat > "+repoDir+"/config")
+	return err
+}
+
+// portForward forwards a free local port to the node's API
+func (c *Cluster) portForward(n *Node) error {
+	cmd := exec.Command(c.opts.Kubectl, "-n", c.opts.Namespace, "port-forward", "pod/"+n.name, fmt.Sprintf(":%d", apiPort))
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	addr, err := forwardedAddr(out)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	n.forward = cmd
+	n.client = client.New("http://" + addr)
+	return nil
+}
+
+// waitReady waits until the node's API answers as the node
+func (c *Cluster) waitReady(ctx context.Context, n *Node) error {
+	return poll(ctx, func() error {
+		id, err := n.client.PeerID()
+		if err != nil {
+			return err
+		}
+		if id != n.peerID {
+			return fmt.Errorf("api answers as %s, expected %s", id, n.peerID)
+		}
+		return nil
+	})
+}
+
+// poll calls fn until it succeeds or ctx is done, returning the last error
+func poll(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Close deletes every pod of the run
+func (c *Cluster) Close() error {
+	for _, n := range c.Nodes() {
+		if n.forward != nil {
+			n.forward.Process.Kill()
+			n.forward.Wait()
+		}
+	}
+	_, err := c.kubectl(context.Background(), nil, "delete", "pods", "-l", "testnodes.run="+c.opts.Name, "--ignore-not-found", "--wait=false")
+	return err
+}
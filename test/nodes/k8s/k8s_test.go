@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestWire(t *testing.T) {
+	const total, k = 50, 4
+	wiring := wire(0, total, k, rand.New(rand.NewSource(1)))
+	for i := 0; i < total; i++ {
+		peers := wiring[i]
+		if len(peers) != k {
+			t.Fatalf("Expected node %d to get %d peers, got %v", i, k, peers)
+		}
+		seen := make(map[int]bool)
+		for _, j := range peers {
+			if j == i || seen[j] || j < 0 || j >= total {
+				t.Fatalf("Node %d got bad peers %v", i, peers)
+			}
+			seen[j] = true
+		}
+		if i > 0 && !seen[i-1] {
+			t.Errorf("Expected node %d to bootstrap from %d, got %v", i, i-1, peers)
+		}
+	}
+
+	again := wire(0, total, k, rand.New(rand.NewSource(1)))
+	for i := range wiring {
+		if len(again[i]) != len(wiring[i]) {
+			t.Fatal("Expected the same seed to wire the same network")
+		}
+		for j := range wiring[i] {
+			if again[i][j] != wiring[i][j] {
+				t.Fatal("Expected the same seed to wire the same network")
+			}
+		}
+	}
+
+	small := wire(1, 3, k, rand.New(rand.NewSource(1)))
+	if len(small) != 2 || len(small[1]) != 2 || len(small[2]) != 2 {
+		t.Errorf("Expected only new nodes, each wired to both others, got %v", small)
+	}
+}
+
+func TestForwardedAddr(t *testing.T) {
+	out := "Forwarding from 127.0.0.1:41235 -> 4002\nForwarding from [::1]:41235 -> 4002\n"
+	addr, err := forwardedAddr(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "127.0.0.1:41235" {
+		t.Errorf("Expected 127.0.0.1:41235, got %s", addr)
+	}
+	if _, err := forwardedAddr(strings.NewReader("error: pod not found\n")); err == nil {
+		t.Error("Expected an error without a forwarding line")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	c, err := New(Options{Image: "openbazaar/server", Testnet: true, Features: []string{"a", "b"}, Memory: "256Mi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.manifest(&Node{name: "testnodes-vendor-1", role: "vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pod struct {
+		Metadata struct {
+			Name   string
+			Labels map[string]string
+		}
+		Spec struct {
+			Containers []struct {
+				Image     string
+				Command   []string
+				Env       []struct{ Name, Value string }
+				Resources struct{ Requests map[string]string }
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &pod); err != nil {
+		t.Fatal(err)
+	}
+	if pod.Metadata.Name != "testnodes-vendor-1" || pod.Metadata.Labels["testnodes.role"] != "vendor" || pod.Metadata.Labels["testnodes.run"] != "testnodes" {
+		t.Errorf("Unexpected metadata %+v", pod.Metadata)
+	}
+	ct := pod.Spec.Containers[0]
+	script := ct.Command[len(ct.Command)-1]
+	if !strings.Contains(script, "init -d /repo -f -t") || !strings.Contains(script, "--testnet") {
+		t.Errorf("Expected a testnet node, got %s", script)
+	}
+	if len(ct.Env) != 1 || ct.Env[0].Value != "a,b" {
+		t.Errorf("Expected the features in the environment, got %v", ct.Env)
+	}
+	if ct.Resources.Requests["memory"] != "256Mi" {
+		t.Errorf("Expected a memory request, got %v", ct.Resources.Requests)
+	}
+}
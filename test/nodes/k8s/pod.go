@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// Flag files the pod script and the controller meet on
+const (
+	initialized = ".initialized"
+	startFlag   = ".start"
+)
+
+// script is what a pod runs: it initializes the repo, then waits for the
+// controller to configure it before starting the node
+func (c *Cluster) script() string {
+	init := "openbazaard init -d " + repoDir + " -f"
+	start := "exec openbazaard start -d " + repoDir + " --disablewallet --disableexchangerates"
+	if c.opts.Testnet {
+		init += " -t"
+		start += " --testnet"
+	}
+	return strings.Join([]string{
+		"set -e",
+		init,
+		"touch " + repoDir + "/" + initialized,
+		"until [ -f " + repoDir + "/" + startFlag + " ]; do sleep 1; done",
+		start,
+	}, "\n")
+}
+
+// manifest returns the pod of the node
+func (c *Cluster) manifest(n *Node) ([]byte, error) {
+	container := map[string]interface{}{
+		"name":    "openbazaard",
+		"image":   c.opts.Image,
+		"command": []string{"sh", "-c", c.script()},
+		"ports": []map[string]interface{}{
+			{"name": "api", "containerPort": apiPort},
+			{"name": "swarm", "containerPort": swarmPort},
+		},
+		"volumeMounts": []map[string]interface{}{{"name": "repo", "mountPath": repoDir}},
+	}
+	if len(c.opts.Features) > 0 {
+		container["env"] = []map[string]interface{}{
+			{"name": nodes.FeaturesEnv, "value": strings.Join(c.opts.Features, ",")},
+		}
+	}
+	requests := make(map[string]string)
+	if c.opts.CPU != "" {
+		requests["cpu"] = c.opts.CPU
+	}
+	if c.opts.Memory != "" {
+		requests["memory"] = c.opts.Memory
+	}
+	if len(requests) > 0 {
+		container["resources"] = map[string]interface{}{"requests": requests}
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name": n.name,
+			"labels": map[string]string{
+				"app":            "openbazaard",
+				"testnodes.run":  c.opts.Name,
+				"testnodes.role": n.role,
+			},
+		},
+		"spec": map[string]interface{}{
+			"restartPolicy": "Never",
+			"containers":    []interface{}{container},
+			"volumes":       []map[string]interface{}{{"name": "repo", "emptyDir": map[string]interface{}{}}},
+		},
+	}, "", "  ")
+}
+
+// forwarding is the line kubectl port-forward prints once it listens
+var forwarding = regexp.MustCompile(`^Forwarding from (127\.0\.0\.1:\d+) ->`)
+
+// forwardedAddr reads the output of kubectl port-forward until it tells the
+// local address it forwards from
+func forwardedAddr(r io.Reader) (string, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if m := forwarding.FindStringSubmatch(s.Text()); m != nil {
+			// Keep draining so kubectl never blocks on a full pipe
+			go io.Copy(ioutil.Discard, r)
+			return m[1], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("kubectl port-forward exited without forwarding")
+}
+
+// wire picks the bootstrap peers of nodes first to total-1, as indexes into
+// all nodes. Every node bootstraps from the one before it, which keeps the
+// network connected, and from random others up to k peers.
+func wire(first, total, k int, r *rand.Rand) map[int][]int {
+	ret := make(map[int][]int)
+	for i := first; i < total; i++ {
+		want := k
+		if want > total-1 {
+			want = total - 1
+		}
+		picked := make(map[int]bool)
+		var peers []int
+		if i > 0 && want > 0 {
+			picked[i-1] = true
+			peers = append(peers, i-1)
+		}
+		for _, j := range r.Perm(total) {
+			if len(peers) >= want {
+				break
+			}
+			if j != i && !picked[j] {
+				picked[j] = true
+				peers = append(peers, j)
+			}
+		}
+		ret[i] = peers
+	}
+	return ret
+}
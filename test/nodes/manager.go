@@ -0,0 +1,241 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Init creates a new unencrypted repo in repoDir
+func Init(ctx context.Context, binary, repoDir string, testnet bool) error {
+	args := []string{"init", "-d", repoDir, "-f"}
+	if testnet {
+		args = append(args, "-t")
+	}
+	out, err := exec.CommandContext(ctx, binary, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("openbazaard init: %s: %s", err, out)
+	}
+	return nil
+}
+
+// Manager spawns nodes on fresh repos under one directory and shuts them
+// down together. Every node bootstraps from the nodes spawned before it, or
+// only from the bootstrap nodes once some were spawned AsBootstrap, so they
+// form one network isolated from the real one.
+type Manager struct {
+	binary   string
+	binaries *Binaries
+	runner   Runner
+	opts     []Option
+	dir      string
+	temp     bool
+
+	lock      sync.Mutex
+	procs     []*Process
+	bootstrap []*Process
+}
+
+// NewManager runs nodes with binary on repos created in dir, or in a temp
+// dir removed by Close when dir is empty
+func NewManager(binary, dir string) (*Manager, error) {
+	m := &Manager{binary: binary, runner: Local, dir: dir}
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "testnodes")
+		if err != nil {
+			return nil, err
+		}
+		m.dir, m.temp = tmp, true
+	}
+	return m, nil
+}
+
+// WithBinaries sets the release binaries nodes spawned WithVersion run and
+// returns the manager
+func (m *Manager) WithBinaries(b *Binaries) *Manager {
+	m.binaries = b
+	return m
+}
+
+// WithRunner runs every spawned node with r and returns the manager. Options
+// given to Spawn still override it.
+func (m *Manager) WithRunner(r Runner) *Manager {
+	m.runner = r
+	return m
+}
+
+// WithOptions applies opts to every spawned node, e.g. the wallet option
+// of a regtest chain, and returns the manager. Options given to Spawn still
+// override them.
+func (m *Manager) WithOptions(opts ...Option) *Manager {
+	m.opts = append(m.opts, opts...)
+	return m
+}
+
+// binaryFor returns the binary nodes with the options run
+func (m *Manager) binaryFor(o Options) (string, error) {
+	if o.Version == "" {
+		return m.binary, nil
+	}
+	if m.binaries == nil {
+		return "", fmt.Errorf("nodes: version %s asked for without registered binaries", o.Version)
+	}
+	return m.binaries.Binary(o.Version)
+}
+
+// Spawn initializes n repos and starts a node on each concurrently, then
+// waits until all of them are ready. Options apply to every node; a
+// WithBootstrap among them replaces the nodes spawned before as bootstrap
+// peers, and AsBootstrap makes the nodes bootstrap nodes. Nodes launched
+// before an error stay running until Close.
+func (m *Manager) Spawn(ctx context.Context, n int, opts ...Option) ([]*Process, error) {
+	if n < 1 {
+		return nil, errors.New("nodes: spawn at least one node")
+	}
+	opts = append(append([]Option{WithRunner(m.runner)}, m.opts...), opts...)
+	o := newOptions(opts)
+	binary, err := m.binaryFor(o)
+	if err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	first := len(m.procs)
+	// Reserve the names before the slow part
+	m.procs = append(m.procs, make([]*Process, n)...)
+	m.lock.Unlock()
+
+	repoDir := func(i int) string {
+		return filepath.Join(m.dir, fmt.Sprintf("node-%d", first+i+1))
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = o.runner().Init(ctx, binary, repoDir(i), o.Testnet)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Launching is quick and gives each node the addresses of the ones
+	// before it, ready or not, as its swarm retries bootstrap peers
+	ret := make([]*Process, n)
+	for i := range ret {
+		launch := opts
+		if len(o.Bootstrap) == 0 {
+			launch = append([]Option{WithBootstrap(m.bootstrapAddrs()...)}, opts...)
+		}
+		p, err := Launch(binary, repoDir(i), launch...)
+		if err != nil {
+			return nil, fmt.Errorf("starting %s: %s", filepath.Base(repoDir(i)), err)
+		}
+		m.lock.Lock()
+		m.procs[first+i] = p
+		if o.BootstrapNode {
+			m.bootstrap = append(m.bootstrap, p)
+		}
+		m.lock.Unlock()
+		ret[i] = p
+	}
+	if err := WaitAllReady(ctx, o.readyTimeout(), ret...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// bootstrapAddrs are the swarm addresses spawned nodes bootstrap from: those
+// of the bootstrap nodes if there are any, else of every node
+func (m *Manager) bootstrapAddrs() []string {
+	procs := m.Bootstrap()
+	if len(procs) == 0 {
+		procs = m.Processes()
+	}
+	var addrs []string
+	for _, p := range procs {
+		addrs = append(addrs, p.SwarmAddrs...)
+	}
+	return addrs
+}
+
+// Bootstrap returns the nodes spawned AsBootstrap
+func (m *Manager) Bootstrap() []*Process {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*Process(nil), m.bootstrap...)
+}
+
+// WaitAllReady waits for every spawned node to become ready, giving up
+// after timeout
+func (m *Manager) WaitAllReady(ctx context.Context, timeout time.Duration) error {
+	return WaitAllReady(ctx, timeout, m.Processes()...)
+}
+
+// WaitAllReady waits concurrently for the nodes to become ready and returns
+// the errors of all that did not within timeout
+func WaitAllReady(ctx context.Context, timeout time.Duration, procs ...*Process) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	errs := make([]error, len(procs))
+	var wg sync.WaitGroup
+	for i, p := range procs {
+		wg.Add(1)
+		go func(i int, p *Process) {
+			defer wg.Done()
+			errs[i] = p.WaitReady(ctx)
+		}(i, p)
+	}
+	wg.Wait()
+	var msgs []string
+	for i, err := range errs {
+		if err != nil {
+			msgs = append(msgs, procs[i].name()+": "+err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// Processes returns every running node spawned so far, in spawn order
+func (m *Manager) Processes() []*Process {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var ret []*Process
+	for _, p := range m.procs {
+		if p != nil {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// Close stops every spawned node and removes the repos if the manager
+// created their directory
+func (m *Manager) Close() error {
+	var first error
+	for _, p := range m.Processes() {
+		if err := p.Stop(); err != nil && first == nil {
+			first = err
+		}
+	}
+	if m.temp {
+		if err := os.RemoveAll(m.dir); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,96 @@
+package nodes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+func TestManagerInitFailure(t *testing.T) {
+	m, err := NewManager(filepath.Join(os.TempDir(), "no-such-openbazaard"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Spawn(context.Background(), 0); err == nil {
+		t.Error("Expected spawning no nodes to fail")
+	}
+	started, err := m.Spawn(context.Background(), 2)
+	if err == nil || len(started) != 0 {
+		t.Errorf("Expected init with a missing binary to fail, got %d nodes, %v", len(started), err)
+	}
+	if len(m.Processes()) != 0 {
+		t.Error("Expected no running nodes")
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(m.dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the temp dir to be removed, got %v", err)
+	}
+}
+
+func TestWaitAllReady(t *testing.T) {
+	// The API answers at once, the gateway only from the third request
+	var gatewayHits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ob/config":
+			w.Write([]byte(`{"peerID": "QmReady"}`))
+		case "/ipfs/" + emptyDir:
+			if atomic.AddInt32(&gatewayHits, 1) < 3 {
+				http.Error(w, "starting", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	ready := &Process{Client: client.New(ts.URL), RepoDir: "ready", PeerID: "QmReady", exited: make(chan struct{})}
+	if err := WaitAllReady(context.Background(), 5*time.Second, ready); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&gatewayHits) < 3 {
+		t.Error("Expected readiness to wait for the gateway")
+	}
+
+	other := &Process{Client: client.New(ts.URL), RepoDir: "other", PeerID: "QmOther", exited: make(chan struct{})}
+	err := WaitAllReady(context.Background(), 600*time.Millisecond, ready, other)
+	if err == nil || !strings.HasPrefix(err.Error(), "other: ") {
+		t.Errorf("Expected only the node answering with the wrong peer ID to fail, got %v", err)
+	}
+
+	exited := &Process{Client: client.New("http://127.0.0.1:1"), RepoDir: "exited", exited: make(chan struct{})}
+	close(exited.exited)
+	start := time.Now()
+	if err := WaitAllReady(context.Background(), time.Minute, exited); err == nil || time.Since(start) > 10*time.Second {
+		t.Errorf("Expected an exited node to fail at once, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestBootstrapAddrs(t *testing.T) {
+	m := &Manager{}
+	if addrs := m.bootstrapAddrs(); len(addrs) != 0 {
+		t.Errorf("Expected the first node to bootstrap from nothing, got %v", addrs)
+	}
+	a := &Process{SwarmAddrs: []string{"/ip4/127.0.0.1/tcp/4001/ipfs/QmA"}}
+	b := &Process{SwarmAddrs: []string{"/ip4/127.0.0.1/tcp/4002/ipfs/QmB"}}
+	m.procs = []*Process{a, nil, b}
+	if addrs := m.bootstrapAddrs(); len(addrs) != 2 {
+		t.Errorf("Expected every node to be a bootstrap peer, got %v", addrs)
+	}
+	m.bootstrap = []*Process{b}
+	if addrs := m.bootstrapAddrs(); len(addrs) != 1 || addrs[0] != b.SwarmAddrs[0] {
+		t.Errorf("Expected only the bootstrap node, got %v", addrs)
+	}
+}
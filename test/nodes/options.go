@@ -0,0 +1,537 @@
+package nodes
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// Family selects the address families a node listens on
+type Family int
+
+const (
+	// IPv4 listens on 127.0.0.1 only
+	IPv4 Family = iota
+
+	// IPv6 listens on ::1 only, API included
+	IPv6
+
+	// DualStack puts the swarm on both 127.0.0.1 and ::1 and the API on
+	// 127.0.0.1
+	DualStack
+)
+
+func (f Family) String() string {
+	switch f {
+	case IPv6:
+		return "ipv6"
+	case DualStack:
+		return "dual-stack"
+	default:
+		return "ipv4"
+	}
+}
+
+func (f Family) apiHost(port int) string {
+	if f == IPv6 {
+		return fmt.Sprintf("[::1]:%d", port)
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+func (f Family) apiAddr(port int) string {
+	if f == IPv6 {
+		return fmt.Sprintf("/ip6/::1/tcp/%d", port)
+	}
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
+}
+
+func (f Family) swarmAddrs(port int) []string {
+	v4 := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)
+	v6 := fmt.Sprintf("/ip6/::1/tcp/%d", port)
+	switch f {
+	case IPv6:
+		return []string{v6}
+	case DualStack:
+		return []string{v4, v6}
+	default:
+		return []string{v4}
+	}
+}
+
+// Options configures a started node
+type Options struct {
+	Testnet   bool
+	Family    Family
+	Bootstrap []string
+
+	// Features are the experimental features enabled on the node
+	Features []string
+
+	// UnixSocket serves the API on SocketName inside the repo instead of a
+	// TCP port
+	UnixSocket bool
+
+	// TrustedPeer is the regtest bitcoind the wallet syncs from, or the
+	// ledger of a MockWallet. The wallet is disabled when it is empty.
+	TrustedPeer string
+
+	// Wallet is the type of wallet the node runs, the one in its config
+	// when empty, see WithMockWallet
+	Wallet string
+
+	// CoinWallets are the regtest daemons the wallets of a node running a
+	// wallet per coin sync from, by currency code, see WithCoinWallet
+	CoinWallets map[string]string
+
+	// FeeAPI is the fee estimate API the wallets query, none when empty,
+	// which has them pay their default fees, see WithFeeAPI
+	FeeAPI string
+
+	// Username and Password are what the API of a repo with authentication
+	// on takes, see WithAuth
+	Username string
+	Password string
+
+	// WalletOnly runs the node with its marketplace disabled, see
+	// WithWalletOnly
+	WalletOnly bool
+
+	// Version selects the release binary a Manager runs the node with from
+	// its Binaries, its own binary when empty
+	Version string
+
+	// Profile serves Go runtime profiles under /debug/pprof on the API
+	Profile bool
+
+	// Metrics serves Prometheus metrics under /debug/metrics/prometheus on
+	// the API
+	Metrics bool
+
+	// ReadyTimeout bounds how long Start and Spawn wait for the node to
+	// become ready, 3 minutes when zero
+	ReadyTimeout time.Duration
+
+	// Runner runs the node, Local when nil
+	Runner Runner
+
+	// Limits caps the CPU, memory and bandwidth the node may use
+	Limits Limits
+
+	// BootstrapNode makes a node spawned by a Manager one of the only
+	// bootstrap peers of the nodes spawned after it
+	BootstrapNode bool
+
+	// NAT is what the node sits behind, only docker nodes can be NATed
+	NAT NAT
+
+	// LANOnly makes the node find its peers with mDNS alone and reach out
+	// to nothing beyond them, see WithLANOnly
+	LANOnly bool
+
+	// Tor is how the node uses Tor through the control port at
+	// TorControl, see WithTor
+	Tor        Tor
+	TorControl string
+
+	// Swarm is the private swarm the node joins, see WithSwarm, the public
+	// one when empty
+	Swarm string
+
+	// Hostname names the node in logs and errors, and in DNS for the other
+	// nodes where the runner has a resolver. Hostname of the repo when
+	// empty.
+	Hostname string
+}
+
+// Domain is the domain of node hostnames, which no real resolver answers for
+const Domain = "obtest.local"
+
+// Hostname is the hostname a node on repoDir gets by default, the name of
+// the repo under Domain, e.g. vendor-1.obtest.local
+func Hostname(repoDir string) string {
+	return filepath.Base(repoDir) + "." + Domain
+}
+
+// NAT is a kind of NAT a node can be put behind, to see how it copes on a
+// home network
+type NAT int
+
+const (
+	// NoNAT puts the node on the test network directly
+	NoNAT NAT = iota
+
+	// ConeNAT keeps the source port of connections where it can, so one
+	// node port maps to one gateway port whoever it talks to, as home
+	// routers do. Only replies get in.
+	ConeNAT
+
+	// SymmetricNAT maps every connection to a random gateway port, as
+	// carrier grade NATs do. Only replies get in.
+	SymmetricNAT
+)
+
+func (n NAT) String() string {
+	switch n {
+	case ConeNAT:
+		return "cone"
+	case SymmetricNAT:
+		return "symmetric"
+	default:
+		return "none"
+	}
+}
+
+// Tor is how a node uses Tor, see WithTor
+type Tor int
+
+const (
+	// NoTor keeps the node on clear addresses only
+	NoTor Tor = iota
+
+	// TorOnly has the node listen on its onion address alone and dial
+	// everything through Tor, as openbazaard --tor does
+	TorOnly
+
+	// TorDualStack adds the onion address to the clear ones and dials onion
+	// addresses through Tor, as openbazaard --dualstack does
+	TorDualStack
+)
+
+func (t Tor) String() string {
+	switch t {
+	case TorOnly:
+		return "tor-only"
+	case TorDualStack:
+		return "dual-stack"
+	default:
+		return "none"
+	}
+}
+
+// Limits caps the resources of a node, to see how it copes on small
+// hardware. Zero fields are unlimited.
+type Limits struct {
+	// CPUs is how many cores' worth of CPU time the node gets, e.g. 0.5
+	CPUs float64
+
+	// Memory is the most memory the node may use in bytes, swap included
+	Memory uint64
+
+	// Upload and Download cap the bandwidth of the node in bits per second,
+	// e.g. 256000 for a slow home connection. Only docker nodes with
+	// Shaping can be capped.
+	Upload   uint64
+	Download uint64
+}
+
+func (l Limits) none() bool {
+	return l.CPUs == 0 && l.Memory == 0 && l.caps() == (netem.Caps{})
+}
+
+func (l Limits) caps() netem.Caps {
+	return netem.Caps{Egress: l.Upload, Ingress: l.Download}
+}
+
+// Option changes the options of a started node
+type Option func(*Options)
+
+func newOptions(opts []Option) Options {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o Options) readyTimeout() time.Duration {
+	if o.ReadyTimeout == 0 {
+		return bootTimeout
+	}
+	return o.ReadyTimeout
+}
+
+func (o Options) hostname(repoDir string) string {
+	if o.Hostname == "" {
+		return Hostname(repoDir)
+	}
+	return o.Hostname
+}
+
+// hasWallet tells whether the node runs a wallet
+func (o Options) hasWallet() bool {
+	return o.TrustedPeer != "" || len(o.CoinWallets) > 0
+}
+
+func (o Options) runner() Runner {
+	if o.Runner == nil {
+		return Local
+	}
+	return o.Runner
+}
+
+// WithTestnet runs the node on testnet
+func WithTestnet(testnet bool) Option {
+	return func(o *Options) {
+		o.Testnet = testnet
+	}
+}
+
+// WithRegtestWallet runs the node on regtest with its wallet enabled,
+// syncing from the bitcoind at trustedPeer, e.g. 127.0.0.1:18444
+func WithRegtestWallet(trustedPeer string) Option {
+	return func(o *Options) {
+		o.TrustedPeer = trustedPeer
+	}
+}
+
+// WithCoinWallet runs the node on regtest with its wallet for coin enabled,
+// syncing from the daemon at trustedPeer. Only releases running a wallet per
+// coin have one for other coins than bitcoin, and their wallets are
+// configured by coin, so it replaces WithRegtestWallet on those.
+func WithCoinWallet(coin, trustedPeer string) Option {
+	return func(o *Options) {
+		if o.CoinWallets == nil {
+			o.CoinWallets = make(map[string]string)
+		}
+		o.CoinWallets[strings.ToUpper(coin)] = trustedPeer
+	}
+}
+
+// WithFeeAPI points the wallets of the node at the fee estimate API at
+// url, e.g. one started with feeapi.Start, instead of none
+func WithFeeAPI(url string) Option {
+	return func(o *Options) {
+		o.FeeAPI = url
+	}
+}
+
+// WithAuth has the node's client, readiness probe included, send the
+// credentials of a repo whose API requires them, e.g. a captured one
+func WithAuth(username, password string) Option {
+	return func(o *Options) {
+		o.Username = username
+		o.Password = password
+	}
+}
+
+// MockWallet is the wallet type of nodes started WithMockWallet
+const MockWallet = "mock"
+
+// WithMockWallet runs the node on regtest with a mock wallet keeping its
+// coins on the ledger at addr, e.g. one started with ledger.Start, instead
+// of syncing from bitcoind
+func WithMockWallet(addr string) Option {
+	return func(o *Options) {
+		o.Wallet = MockWallet
+		o.TrustedPeer = addr
+	}
+}
+
+// WithWalletOnly runs the node with the marketplace disabled: IPFS and the
+// wallet run, the marketplace protocol, message retriever and pointer
+// republisher do not, so wallet behaviour can be tested on its own. The
+// node needs its wallet, see WithRegtestWallet, WithCoinWallet and
+// WithMockWallet.
+func WithWalletOnly() Option {
+	return func(o *Options) {
+		o.WalletOnly = true
+	}
+}
+
+// WithIPv6Only makes the node listen on ::1 only, for its swarm and API
+func WithIPv6Only() Option {
+	return func(o *Options) {
+		o.Family = IPv6
+	}
+}
+
+// WithDualStack makes the swarm listen on both 127.0.0.1 and ::1
+func WithDualStack() Option {
+	return func(o *Options) {
+		o.Family = DualStack
+	}
+}
+
+// WithBootstrap sets the bootstrap peers of the node, usually the
+// SwarmAddrs of nodes started before it
+func WithBootstrap(addrs ...string) Option {
+	return func(o *Options) {
+		o.Bootstrap = append(o.Bootstrap, addrs...)
+	}
+}
+
+// AsBootstrap designates the nodes a Manager spawns as bootstrap nodes: the
+// nodes it spawns later bootstrap from them alone rather than from every
+// node before them
+func AsBootstrap() Option {
+	return func(o *Options) {
+		o.BootstrapNode = true
+	}
+}
+
+// WithFeatures enables experimental features on the node through
+// FeaturesEnv
+func WithFeatures(features ...string) Option {
+	return func(o *Options) {
+		o.Features = append(o.Features, features...)
+	}
+}
+
+// FeaturesEnv is core.FeaturesEnv, the variable openbazaard reads its
+// experimental features from
+const FeaturesEnv = "OPENBAZAAR_FEATURES"
+
+// WithUnixSocket serves the API on a unix socket inside the repo, which saves
+// a port per node when running hundreds of them on one host
+func WithUnixSocket() Option {
+	return func(o *Options) {
+		o.UnixSocket = true
+	}
+}
+
+// WithVersion runs the node with the binary of a release registered in the
+// manager's Binaries
+func WithVersion(version string) Option {
+	return func(o *Options) {
+		o.Version = version
+	}
+}
+
+// WithProfiling serves the node's runtime profiles, which heap captures and
+// goroutine counts read
+func WithProfiling() Option {
+	return func(o *Options) {
+		o.Profile = true
+	}
+}
+
+// WithMetrics serves the node's Prometheus metrics, which resend bounds
+// and comparisons read
+func WithMetrics() Option {
+	return func(o *Options) {
+		o.Metrics = true
+	}
+}
+
+// WithLimits caps the CPU and memory of the node, e.g. one core and 512MiB
+// to stand in for a Raspberry Pi. Local nodes are put in a cgroup, which
+// only works on Linux, docker nodes get container limits. Bandwidth caps
+// need a DockerRunner with Shaping.
+func WithLimits(l Limits) Option {
+	return func(o *Options) {
+		o.Limits = l
+	}
+}
+
+// WithReadyTimeout changes how long starting the node may take before its
+// API and gateway answer
+func WithReadyTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ReadyTimeout = d
+	}
+}
+
+// WithNAT puts the node behind a NAT of its own, whose gateway is on the
+// test network. Other nodes cannot dial it, only it them. Needs a
+// DockerRunner.
+func WithNAT(n NAT) Option {
+	return func(o *Options) {
+		o.NAT = n
+	}
+}
+
+// WithTor runs the node as an onion service of the Tor whose control port
+// is at control, e.g. an onionsim.Server. The onion address is added to the
+// swarm addresses rather than with the --tor and --dualstack flags, whose
+// clear ports are fixed. Tor connects onion services to a loopback port of
+// the node, so only the Local runner can run it.
+func WithTor(t Tor, control string) Option {
+	return func(o *Options) {
+		o.Tor = t
+		o.TorControl = control
+	}
+}
+
+// WithLANOnly runs the node as a local network marketplace: no bootstrap
+// peers, those given included, mDNS discovery every second, and no NAT port
+// mapping, crosspost gateways or name resolver to reach out to. Its DHT
+// only ever learns of the peers mDNS finds. Multicast has to work between
+// the nodes, which it does on a docker network but often not on loopback.
+func WithLANOnly() Option {
+	return func(o *Options) {
+		o.LANOnly = true
+	}
+}
+
+// WithSwarm puts the node in the private swarm of the given name. Nodes
+// only connect to nodes of the same swarm, so two marketplaces can run side
+// by side on one host without seeing each other.
+func WithSwarm(name string) Option {
+	return func(o *Options) {
+		o.Swarm = name
+	}
+}
+
+// WithHostname names the node host instead of after its repo
+func WithHostname(host string) Option {
+	return func(o *Options) {
+		o.Hostname = host
+	}
+}
+
+// WithRunner runs the node with r instead of as a child process
+func WithRunner(r Runner) Option {
+	return func(o *Options) {
+		o.Runner = r
+	}
+}
+
+// SocketName is the file name of the API socket of nodes started
+// WithUnixSocket
+const SocketName = "api.sock"
+
+// api returns the gateway address of a node on repoDir and a client for it
+func (o Options) api(repoDir string) (string, *client.Client, error) {
+	if o.UnixSocket {
+		abs, err := filepath.Abs(repoDir)
+		if err != nil {
+			return "", nil, err
+		}
+		sock := filepath.Join(abs, SocketName)
+		return "/unix" + sock, client.NewUnix(sock), nil
+	}
+	port, err := freePort(o.Family)
+	if err != nil {
+		return "", nil, err
+	}
+	return o.Family.apiAddr(port), client.New("http://" + o.Family.apiHost(port)), nil
+}
+
+// freePort returns a loopback TCP port that is free on the family's API
+// address and, for dual stack, on both families
+func freePort(f Family) (int, error) {
+	host := "127.0.0.1"
+	if f == IPv6 {
+		host = "::1"
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+	if f == DualStack {
+		l6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", port))
+		if err != nil {
+			return freePort(f)
+		}
+		l6.Close()
+	}
+	return port, nil
+}
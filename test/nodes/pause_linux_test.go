@@ -0,0 +1,57 @@
+package nodes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// procState returns the state letter of pid from /proc, e.g. S or T
+func procState(pid int) (string, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(b)[strings.LastIndex(string(b), ")")+1:])
+	return fields[0], nil
+}
+
+func TestPause(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &Process{runner: Local, command: Command{Binary: "sleep", Args: []string{"30"}, Log: filepath.Join(dir, "log")}}
+	if err := p.run(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.kill()
+	pid := p.inst.(*localProcess).cmd.Process.Pid
+
+	for _, c := range []struct {
+		do    func() error
+		state string
+	}{{p.Pause, "T"}, {p.Pause, "T"}, {p.Resume, "S"}} {
+		if err := c.do(); err != nil {
+			t.Fatal(err)
+		}
+		var state string
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			if state, err = procState(pid); err != nil {
+				t.Fatal(err)
+			}
+			if state == c.state {
+				break
+			}
+		}
+		if state != c.state {
+			t.Errorf("Expected state %s, got %s", c.state, state)
+		}
+	}
+}
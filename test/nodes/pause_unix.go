@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package nodes
+
+import (
+	"os"
+	"syscall"
+)
+
+func stopSignal() os.Signal     { return syscall.SIGSTOP }
+func continueSignal() os.Signal { return syscall.SIGCONT }
@@ -0,0 +1,7 @@
+package nodes
+
+import "os"
+
+// Windows cannot suspend a process with a signal, Pause fails there
+func stopSignal() os.Signal     { return nil }
+func continueSignal() os.Signal { return nil }
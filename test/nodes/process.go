@@ -0,0 +1,426 @@
+// Package nodes starts openbazaard processes on isolated repos for the
+// harness, as child processes, in containers or on remote hosts
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// bootTimeout bounds how long a node may take to become ready unless
+// overridden with WithReadyTimeout
+const bootTimeout = 3 * time.Minute
+
+// shutdownTimeout is how long Stop waits for a node to exit after the
+// shutdown before killing it
+var shutdownTimeout = 30 * time.Second
+
+// killTimeout bounds how long a killed node may take to exit
+const killTimeout = 10 * time.Second
+
+// Process is a running openbazaard
+type Process struct {
+	Client *client.Client
+
+	// RepoDir is the data directory the node runs on
+	RepoDir string
+
+	// Hostname is the stable name of the node, e.g. vendor-1.obtest.local,
+	// which its ports and peer ID are not
+	Hostname string
+
+	// NAT is what the node sits behind, see WithNAT
+	NAT NAT
+
+	// Tor is how the node uses Tor, see WithTor
+	Tor Tor
+
+	// Swarm is the private swarm the node is in, see WithSwarm
+	Swarm string
+
+	// Features are the experimental features the node was started with
+	Features []string
+
+	// PeerID is the node's base58 encoded peer ID
+	PeerID string
+
+	// Version is the release the binary reports, empty if it reports none
+	Version string
+
+	// SwarmAddrs are the addresses other nodes can dial, including the
+	// /ipfs/<peer ID> suffix
+	SwarmAddrs []string
+
+	runner       Runner
+	command      Command
+	readyTimeout time.Duration
+
+	lock   sync.Mutex
+	paused bool
+	inst   Instance
+	// exited is closed once the current run exited with err
+	exited chan struct{}
+	err    error
+}
+
+// Start runs binary on repoDir like Launch and waits until the node is ready
+// or the ready timeout passes, killing it then
+func Start(ctx context.Context, binary, repoDir string, opts ...Option) (*Process, error) {
+	p, err := Launch(binary, repoDir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, newOptions(opts).readyTimeout())
+	defer cancel()
+	if err := p.WaitReady(ctx); err != nil {
+		p.kill()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Launch runs binary on repoDir without waiting for it to answer. The repo
+// is isolated first: its API and swarm listen where the runner puts them,
+// free loopback ports for Local, or a unix socket for the API and it
+// bootstraps only from the addresses given with WithBootstrap, so it never
+// talks to the real network or clashes with a local node. Exchange rates
+// are disabled and so is the wallet, unless the node is started
+// WithRegtestWallet, WithCoinWallet or WithMockWallet. The peer ID and swarm
+// addresses are read from the repo, so other nodes can bootstrap from the
+// node before it is ready.
+func Launch(binary, repoDir string, opts ...Option) (*Process, error) {
+	o := newOptions(opts)
+	if o.WalletOnly && !o.hasWallet() {
+		return nil, fmt.Errorf("nodes: a wallet-only node needs its wallet, see WithRegtestWallet, WithCoinWallet or WithMockWallet")
+	}
+	ep, err := o.runner().Endpoints(repoDir, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := configure(repoDir, ep.Gateway, ep.Swarm, o); err != nil {
+		return nil, err
+	}
+	peerID, err := RepoPeerID(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"start", "-d", repoDir, "--disableexchangerates"}
+	if o.WalletOnly {
+		args = append(args, "--disablemarketplace")
+	}
+	switch {
+	case o.hasWallet():
+		args = append(args, "--regtest")
+	case o.Testnet:
+		args = append(args, "--disablewallet", "--testnet")
+	default:
+		args = append(args, "--disablewallet")
+	}
+	if o.Profile {
+		args = append(args, "--profile")
+	}
+	if o.Metrics {
+		args = append(args, "--metrics")
+	}
+	version, _ := BinaryVersion(binary)
+	ep.Client.Version = version
+	if o.Username != "" {
+		ep.Client.WithAuth(o.Username, o.Password)
+	}
+	p := &Process{
+		Client:   ep.Client,
+		RepoDir:  repoDir,
+		Hostname: o.hostname(repoDir),
+		NAT:      o.NAT,
+		Tor:      o.Tor,
+		Swarm:    o.Swarm,
+		Features: o.Features,
+		PeerID:   peerID,
+		Version:  version,
+		runner:   o.runner(),
+		command: Command{
+			Binary:  binary,
+			Args:    args,
+			RepoDir: repoDir,
+			Log:     filepath.Join(repoDir, "openbazaard.log"),
+			Host:    o.hostname(repoDir),
+			Limits:  o.Limits,
+		},
+		readyTimeout: o.readyTimeout(),
+	}
+	if len(o.Features) > 0 {
+		p.command.Env = []string{FeaturesEnv + "=" + strings.Join(o.Features, ",")}
+	}
+	dialable, err := o.torSwarm(repoDir, ep.SwarmAddrs)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range dialable {
+		p.SwarmAddrs = append(p.SwarmAddrs, a+"/ipfs/"+peerID)
+	}
+	if err := p.run(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// name is the hostname of the node, or the name of its repo for a process
+// not launched
+func (p *Process) name() string {
+	if p.Hostname == "" {
+		return filepath.Base(p.RepoDir)
+	}
+	return p.Hostname
+}
+
+// run starts the process on the configured repo, appending to its log
+func (p *Process) run() error {
+	inst, err := p.runner.Run(p.command)
+	if err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	p.lock.Lock()
+	p.inst, p.exited, p.err, p.paused = inst, exited, nil, false
+	p.lock.Unlock()
+	go func() {
+		err := inst.Wait()
+		p.lock.Lock()
+		p.err = err
+		p.lock.Unlock()
+		close(exited)
+	}()
+	return nil
+}
+
+// current returns the instance of the current run and its exit channel
+func (p *Process) current() (Instance, chan struct{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.inst, p.exited
+}
+
+func (p *Process) kill() {
+	inst, _ := p.current()
+	inst.Kill()
+}
+
+// exitErr returns how the last run exited
+func (p *Process) exitErr() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.err
+}
+
+// emptyDir is the empty unixfs directory every repo holds from init, which
+// the gateway can serve without touching the network
+const emptyDir = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+
+// WaitReady blocks until both the REST API and the gateway of the node
+// answer, failing early if the process exits
+func (p *Process) WaitReady(ctx context.Context) error {
+	_, exited := p.current()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := p.ready()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("openbazaard exited during boot: %v, see %s", p.exitErr(), p.command.Log)
+		case <-ctx.Done():
+			return fmt.Errorf("openbazaard not ready: %s, see %s", err, p.command.Log)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Process) ready() error {
+	peerID, err := p.Client.PeerID()
+	if err != nil {
+		return fmt.Errorf("api: %s", err)
+	}
+	if peerID != p.PeerID {
+		return fmt.Errorf("api answers as %s, expected %s", peerID, p.PeerID)
+	}
+	if _, err := p.Client.GetBytes("/ipfs/" + emptyDir); err != nil {
+		return fmt.Errorf("gateway: %s", err)
+	}
+	return nil
+}
+
+// Pause suspends the node, with SIGSTOP when run locally. Its connections
+// stay open but it answers nothing, like a hung peer, until Resume.
+func (p *Process) Pause() error {
+	return p.suspend(true)
+}
+
+// Resume continues a paused node
+func (p *Process) Resume() error {
+	return p.suspend(false)
+}
+
+func (p *Process) suspend(pause bool) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.paused == pause {
+		return nil
+	}
+	do := p.inst.Resume
+	if pause {
+		do = p.inst.Pause
+	}
+	if err := do(); err != nil {
+		return err
+	}
+	p.paused = pause
+	return nil
+}
+
+// Log is the file the output of the node goes to
+func (p *Process) Log() string {
+	return p.command.Log
+}
+
+// Stop shuts the node down through the API and kills it if it does not
+// exit. A node whose API refuses the shutdown is killed right away and the
+// refusal returned. The repo is kept, so Restart brings the same node back.
+func (p *Process) Stop() error {
+	inst, exited := p.current()
+	select {
+	case <-exited:
+		return nil
+	default:
+	}
+	// A paused node would only exit on the kill
+	if err := p.Resume(); err != nil {
+		return err
+	}
+	if _, err := p.Client.Post("/ob/shutdown", nil); err != nil {
+		select {
+		case <-exited:
+			return nil
+		default:
+		}
+		if err := p.killWait(inst, exited); err != nil {
+			return err
+		}
+		return fmt.Errorf("nodes: %s did not take the shutdown and was killed: %s", p.name(), err)
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(shutdownTimeout):
+		return p.killWait(inst, exited)
+	}
+}
+
+// killWait kills the instance and waits until it exited, so the repo lock
+// and ports are free for the next run
+func (p *Process) killWait(inst Instance, exited chan struct{}) error {
+	err := inst.Kill()
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(killTimeout):
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("nodes: %s did not exit %s after it was killed", p.name(), killTimeout)
+}
+
+// Restart stops the node if it is running and starts it again on the same
+// repo, ports and options, so it keeps its identity, store and messages. It
+// returns once the node is ready.
+func (p *Process) Restart(ctx context.Context) error {
+	if err := p.Stop(); err != nil {
+		return err
+	}
+	if err := p.run(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.readyTimeout)
+	defer cancel()
+	if err := p.WaitReady(ctx); err != nil {
+		p.kill()
+		return err
+	}
+	return nil
+}
+
+// SetConfig sets the value at the dotted path of the node's config, e.g.
+// Bootstrap, Resolver or Tor-config.TorControl; a nil value deletes it and
+// numbers index arrays, so Bootstrap.0 drops the first bootstrap peer.
+// openbazaard reads its config only when it boots, so a running node is
+// restarted and SetConfig returns once it is ready on the new config. A
+// stopped node picks the change up when restarted. Launch rewrites the
+// addresses and bootstrap list, so changes to those last until the repo is
+// launched again.
+func (p *Process) SetConfig(ctx context.Context, path string, value interface{}) error {
+	changed, err := setConfig(p.RepoDir, path, value)
+	if err != nil || !changed {
+		return err
+	}
+	_, exited := p.current()
+	select {
+	case <-exited:
+		return nil
+	default:
+	}
+	return p.Restart(ctx)
+}
+
+// SetPeerID records the new peer ID of a stopped node whose identity key
+// was replaced, in its config and the swarm addresses it is dialed at, so
+// Restart waits for the node to answer as it
+func (p *Process) SetPeerID(peerID string) error {
+	if _, err := setConfig(p.RepoDir, "Identity.PeerID", peerID); err != nil {
+		return err
+	}
+	for i, a := range p.SwarmAddrs {
+		p.SwarmAddrs[i] = strings.TrimSuffix(a, "/ipfs/"+p.PeerID) + "/ipfs/" + peerID
+	}
+	p.PeerID = peerID
+	return nil
+}
+
+// MemoryUsage returns the resident memory of the node process in bytes
+func (p *Process) MemoryUsage() (uint64, error) {
+	inst, _ := p.current()
+	return inst.MemoryUsage()
+}
+
+// impairer is implemented by runners that can impair the packets between
+// two of their nodes
+type impairer interface {
+	impair(ctx context.Context, from, to string, i netem.Impairment) error
+}
+
+// DelayTo delays every packet the node sends to peer by d, replacing any
+// impairment of the link and zero removing it, see ImpairTo
+func (p *Process) DelayTo(ctx context.Context, peer *Process, d time.Duration) error {
+	return p.ImpairTo(ctx, peer, netem.Impairment{Delay: d})
+}
+
+// ImpairTo delays, jitters and drops the packets the node sends to peer as i
+// says, the zero Impairment removing it. Packets from peer are left alone,
+// so a round trip goes through the impairments both ways, and restarting
+// the node removes its impairments. Only a DockerRunner with Shaping can
+// impair single links; for all the nodes on one host see netem.Impair.
+func (p *Process) ImpairTo(ctx context.Context, peer *Process, i netem.Impairment) error {
+	im, ok := p.runner.(impairer)
+	if !ok || p.runner != peer.runner {
+		return fmt.Errorf("nodes: the link from %s to %s cannot be impaired", p.RepoDir, peer.RepoDir)
+	}
+	return im.impair(ctx, p.RepoDir, peer.RepoDir, i)
+}
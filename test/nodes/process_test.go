@@ -0,0 +1,189 @@
+package nodes
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+func TestLaunchWalletOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "wallet-1")
+	if err := os.Mkdir(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	withWallet := repoConfig[:len(repoConfig)-1] + `, "Identity": {"PeerID": "QmWallet"}, "Wallet": {"TrustedPeer": ""}}`
+	if err := ioutil.WriteFile(filepath.Join(repo, "config"), []byte(withWallet), 0600); err != nil {
+		t.Fatal(err)
+	}
+	argsPath := filepath.Join(dir, "args")
+	fake := filepath.Join(dir, "openbazaard")
+	script := "#!/bin/sh\necho \"$@\" >> " + argsPath + "\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Launch(fake, repo, WithWalletOnly()); err == nil {
+		t.Error("Expected a wallet-only node without a wallet to be refused")
+	}
+	p, err := Launch(fake, repo, WithWalletOnly(), WithRegtestWallet("127.0.0.1:18444"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := ioutil.ReadFile(argsPath)
+		if args := string(b); strings.Contains(args, "start -d "+repo) {
+			if !strings.Contains(args, "--disablemarketplace") || strings.Contains(args, "--disablewallet") {
+				t.Errorf("Expected the marketplace disabled and the wallet kept, ran %q", args)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The node was never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.Stop()
+}
+
+func TestSetPeerID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-peerid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	withID := repoConfig[:len(repoConfig)-1] + `, "Identity": {"PeerID": "QmOld"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(withID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Process{RepoDir: dir, PeerID: "QmOld", SwarmAddrs: []string{"/ip4/127.0.0.1/tcp/4001/ipfs/QmOld"}}
+	if err := p.SetPeerID("QmNew"); err != nil {
+		t.Fatal(err)
+	}
+	if p.PeerID != "QmNew" || p.SwarmAddrs[0] != "/ip4/127.0.0.1/tcp/4001/ipfs/QmNew" {
+		t.Errorf("Expected the node dialed as QmNew, got %s at %v", p.PeerID, p.SwarmAddrs)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "config"))
+	if !strings.Contains(string(b), `"PeerID": "QmNew"`) {
+		t.Errorf("Expected the config to carry the new peer ID, got %s", b)
+	}
+}
+
+func TestLaunchAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "captured")
+	if err := os.Mkdir(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	withID := repoConfig[:len(repoConfig)-1] + `, "Identity": {"PeerID": "QmCaptured"}}`
+	if err := ioutil.WriteFile(filepath.Join(repo, "config"), []byte(withID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := filepath.Join(dir, "openbazaard")
+	if err := ioutil.WriteFile(fake, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Launch(fake, repo, WithAuth("alice", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	if p.Client.Username != "alice" || p.Client.Password != "secret" {
+		t.Errorf("Expected the client to authenticate as alice, got %q", p.Client.Username)
+	}
+}
+
+func TestStopRefused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "hung")
+	if err := os.Mkdir(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	withID := repoConfig[:len(repoConfig)-1] + `, "Identity": {"PeerID": "QmHung"}}`
+	if err := ioutil.WriteFile(filepath.Join(repo, "config"), []byte(withID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The fake never serves the API, so the shutdown cannot reach it
+	fake := filepath.Join(dir, "openbazaard")
+	script := "#!/bin/sh\n[ \"$1\" = start ] && exec sleep 60\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Launch(fake, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := p.Stop(); err == nil || !strings.Contains(err.Error(), "shutdown") {
+		t.Errorf("Expected the failed shutdown returned, got %v", err)
+	}
+	if took := time.Since(start); took > 10*time.Second {
+		t.Errorf("Expected the node killed right away, took %s", took)
+	}
+	if err := p.Stop(); err != nil {
+		t.Errorf("Expected stopping a stopped node to succeed, got %v", err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	repo := filepath.Join(dir, "stuck")
+	if err := os.Mkdir(repo, 0700); err != nil {
+		t.Fatal(err)
+	}
+	withID := repoConfig[:len(repoConfig)-1] + `, "Identity": {"PeerID": "QmStuck"}}`
+	if err := ioutil.WriteFile(filepath.Join(repo, "config"), []byte(withID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := filepath.Join(dir, "openbazaard")
+	script := "#!/bin/sh\n[ \"$1\" = start ] && exec sleep 60\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Launch(fake, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The API takes the shutdown but the node never exits
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer api.Close()
+	p.Client = client.New(api.URL)
+	defer func(d time.Duration) { shutdownTimeout = d }(shutdownTimeout)
+	shutdownTimeout = 100 * time.Millisecond
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	_, exited := p.current()
+	select {
+	case <-exited:
+	default:
+		t.Error("Expected the node to have exited when Stop returns")
+	}
+}
@@ -0,0 +1,167 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/metrics"
+	"github.com/OpenBazaar/openbazaar-go/test/netem"
+)
+
+// Runner runs openbazaard for nodes. Local runs child processes, a
+// DockerRunner runs containers and an SSHRunner runs on remote hosts.
+type Runner interface {
+	// Init creates a new unencrypted repo in repoDir
+	Init(ctx context.Context, binary, repoDir string, testnet bool) error
+
+	// Endpoints picks where the node on repoDir listens and how it is
+	// reached
+	Endpoints(repoDir string, o Options) (Endpoints, error)
+
+	// Run starts openbazaard, appending its output to the log
+	Run(cmd Command) (Instance, error)
+}
+
+// Endpoints are where a node listens and how it is reached
+type Endpoints struct {
+	// Gateway and Swarm are the listen addresses written to the repo
+	Gateway string
+	Swarm   []string
+
+	// Client reaches the API from the harness
+	Client *client.Client
+
+	// SwarmAddrs are the addresses other nodes dial, without the /ipfs/
+	// suffix
+	SwarmAddrs []string
+}
+
+// Command is one run of openbazaard
+type Command struct {
+	Binary  string
+	Args    []string
+	RepoDir string
+	Log     string
+
+	// Host is the hostname the node goes by, see Options.Hostname
+	Host string
+
+	// Env are the variables set on top of the runner's environment
+	Env []string
+
+	// Limits cap the resources of the run
+	Limits Limits
+}
+
+// Instance is a running openbazaard
+type Instance interface {
+	// Wait blocks until the run exits and returns how it did
+	Wait() error
+	Kill() error
+
+	// Pause suspends the node in place until Resume
+	Pause() error
+	Resume() error
+
+	// MemoryUsage returns the resident memory of the node in bytes
+	MemoryUsage() (uint64, error)
+}
+
+// Local runs nodes as child processes listening on loopback
+var Local Runner = localRunner{}
+
+type localRunner struct{}
+
+func (localRunner) Init(ctx context.Context, binary, repoDir string, testnet bool) error {
+	return Init(ctx, binary, repoDir, testnet)
+}
+
+func (localRunner) Endpoints(repoDir string, o Options) (Endpoints, error) {
+	if o.NAT != NoNAT {
+		return Endpoints{}, errors.New("nodes: local nodes share the loopback interface, putting them behind a NAT needs a docker runner")
+	}
+	gateway, c, err := o.api(repoDir)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	swarmPort, err := freePort(o.Family)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	swarm := o.Family.swarmAddrs(swarmPort)
+	return Endpoints{Gateway: gateway, Swarm: swarm, Client: c, SwarmAddrs: swarm}, nil
+}
+
+func (localRunner) Run(c Command) (Instance, error) {
+	var cgroup string
+	cmd := exec.Command(c.Binary, c.Args...)
+	if c.Limits.caps() != (netem.Caps{}) {
+		return nil, errors.New("nodes: local nodes share the loopback interface, capping their bandwidth needs a docker runner with Shaping")
+	}
+	if !c.Limits.none() {
+		var err error
+		if cgroup, err = newCgroup(c.RepoDir, c.Limits); err != nil {
+			return nil, err
+		}
+		cmd = inCgroup(cgroup, c.Binary, c.Args)
+	}
+	log, err := openLog(c.Log)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	return &localProcess{cmd: cmd, log: log, cgroup: cgroup}, nil
+}
+
+// openLog opens the log of a node for appending
+func openLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// localProcess is an openbazaard child process
+type localProcess struct {
+	cmd *exec.Cmd
+	log *os.File
+
+	// cgroup limits the process when set, and is removed once it exits
+	cgroup string
+}
+
+func (p *localProcess) Wait() error {
+	defer p.log.Close()
+	err := p.cmd.Wait()
+	if p.cgroup != "" {
+		os.Remove(p.cgroup)
+	}
+	return err
+}
+
+func (p *localProcess) Kill() error { return p.cmd.Process.Kill() }
+
+// Pause suspends the process with SIGSTOP
+func (p *localProcess) Pause() error { return p.signal(stopSignal()) }
+
+// Resume continues the process with SIGCONT
+func (p *localProcess) Resume() error { return p.signal(continueSignal()) }
+
+func (p *localProcess) signal(sig os.Signal) error {
+	if sig == nil {
+		return errors.New("nodes: pausing a process is not supported on this platform")
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *localProcess) MemoryUsage() (uint64, error) {
+	return metrics.ProcessRSS(p.cmd.Process.Pid)
+}
@@ -0,0 +1,420 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// SSHHost is a machine nodes run on
+type SSHHost struct {
+	// Dest is what ssh connects to, e.g. ubuntu@eu-west.example.org. Ports,
+	// keys and jump hosts come from the ssh config.
+	Dest string
+
+	// Addr is the IPv4 address other nodes dial the host's nodes at, the
+	// address the host name of Dest resolves to when empty
+	Addr string
+}
+
+// SSHOptions configures an SSHRunner
+type SSHOptions struct {
+	// Hosts are the machines nodes are spread over, in turn
+	Hosts []SSHHost
+
+	// Dir is where binaries and repos go on every host, testnodes in the
+	// remote home when empty
+	Dir string
+
+	// BasePort is where the ports nodes listen on remotely start, 40000
+	// when zero. Each node takes the next two.
+	BasePort int
+
+	// SSH is the ssh CLI, ssh on the PATH when empty
+	SSH string
+}
+
+// SSHRunner runs nodes on remote hosts over ssh, to spread a network across
+// machines and regions. The binary is copied to each host once, repos live
+// on the hosts and the API of every node is tunnelled to a loopback port of
+// the harness. The local repo dir only mirrors the config: it is fetched
+// after init and pushed before every run, so options and bootstrap lists
+// apply, but backups, restores and blocklists of the local repo do not
+// reach the node. Nodes listen on IPv4 only; the swarm ports have to be
+// reachable between the hosts.
+type SSHRunner struct {
+	opts SSHOptions
+
+	lock     sync.Mutex
+	next     int
+	binaries map[string]string
+	nodes    map[string]*sshNode
+	ports    map[string]int
+}
+
+// sshNode is where the node on a local repo dir runs
+type sshNode struct {
+	host   SSHHost
+	repo   string
+	api    int
+	tunnel int
+	swarm  int
+}
+
+// NewSSHRunner returns a runner spreading nodes over opts.Hosts
+func NewSSHRunner(opts SSHOptions) (*SSHRunner, error) {
+	if len(opts.Hosts) == 0 {
+		return nil, errors.New("nodes: ssh runner needs hosts")
+	}
+	if opts.Dir == "" {
+		opts.Dir = "testnodes"
+	}
+	if opts.BasePort == 0 {
+		opts.BasePort = 40000
+	}
+	if opts.SSH == "" {
+		opts.SSH = "ssh"
+	}
+	hosts := make([]SSHHost, len(opts.Hosts))
+	for i, h := range opts.Hosts {
+		if h.Addr == "" {
+			addr, err := resolveHost(h.Dest)
+			if err != nil {
+				return nil, fmt.Errorf("nodes: no address for %s, give one: %s", h.Dest, err)
+			}
+			h.Addr = addr
+		}
+		hosts[i] = h
+	}
+	opts.Hosts = hosts
+	return &SSHRunner{
+		opts:     opts,
+		binaries: make(map[string]string),
+		nodes:    make(map[string]*sshNode),
+		ports:    make(map[string]int),
+	}, nil
+}
+
+// resolveHost looks up the IPv4 address of the host in an ssh destination
+func resolveHost(dest string) (string, error) {
+	host := dest
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			return v4.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no IPv4 address", host)
+}
+
+// shellQuote quotes s for the remote shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// shellCommand joins the words into a command line for the remote shell
+func shellCommand(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = shellQuote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// sshArgs are the ssh arguments running the remote command line on host
+func (r *SSHRunner) sshArgs(host SSHHost, command string) []string {
+	return []string{"-o", "BatchMode=yes", "-o", "ServerAliveInterval=15", host.Dest, "--", command}
+}
+
+// ssh runs the command line on host with stdin, returning its trimmed output
+func (r *SSHRunner) ssh(ctx context.Context, host SSHHost, stdin io.Reader, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, r.opts.SSH, r.sshArgs(host, command)...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ssh %s: %s: %s", host.Dest, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// node returns where the node on repoDir runs, putting new nodes on the
+// hosts in turn
+func (r *SSHRunner) node(repoDir string) *sshNode {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if n, ok := r.nodes[repoDir]; ok {
+		return n
+	}
+	host := r.opts.Hosts[r.next%len(r.opts.Hosts)]
+	r.next++
+	n := &sshNode{host: host, repo: r.opts.Dir + "/repos/" + containerName(repoDir)}
+	r.nodes[repoDir] = n
+	return n
+}
+
+// binary copies the binary to the host unless it is there already and
+// returns its remote path. Copies are named by content, so hosts keep one
+// per build across runs.
+func (r *SSHRunner) binary(ctx context.Context, host SSHHost, binary string) (string, error) {
+	bin, err := exec.LookPath(binary)
+	if err != nil {
+		return "", err
+	}
+	if bin, err = filepath.Abs(bin); err != nil {
+		return "", err
+	}
+	key := host.Dest + "\x00" + bin
+	r.lock.Lock()
+	remote, ok := r.binaries[key]
+	r.lock.Unlock()
+	if ok {
+		return remote, nil
+	}
+
+	f, err := os.Open(bin)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	remote = fmt.Sprintf("%s/bin/openbazaard-%x", r.opts.Dir, h.Sum(nil)[:6])
+	if _, err := r.ssh(ctx, host, nil, "test -x "+shellQuote(remote)); err != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		tmp := remote + ".tmp"
+		copyCmd := fmt.Sprintf("mkdir -p %s && cat > %s && chmod 755 %s && mv %s %s",
+			shellQuote(r.opts.Dir+"/bin"), shellQuote(tmp), shellQuote(tmp), shellQuote(tmp), shellQuote(remote))
+		if _, err := r.ssh(ctx, host, f, copyCmd); err != nil {
+			return "", err
+		}
+	}
+	r.lock.Lock()
+	r.binaries[key] = remote
+	r.lock.Unlock()
+	return remote, nil
+}
+
+// Init copies the binary to the node's host, creates the repo there and
+// fetches its config into repoDir
+func (r *SSHRunner) Init(ctx context.Context, binary, repoDir string, testnet bool) error {
+	n := r.node(repoDir)
+	bin, err := r.binary(ctx, n.host, binary)
+	if err != nil {
+		return err
+	}
+	words := []string{bin, "init", "-d", n.repo, "-f"}
+	if testnet {
+		words = append(words, "-t")
+	}
+	if _, err := r.ssh(ctx, n.host, nil, "mkdir -p "+shellQuote(n.repo)+" && "+shellCommand(words...)); err != nil {
+		return err
+	}
+	cfg, err := r.ssh(ctx, n.host, nil, "cat "+shellQuote(n.repo+"/config"))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(repoDir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(repoDir, "config"), []byte(cfg), 0600)
+}
+
+// Endpoints gives the node on repoDir the next two ports of its host and a
+// free local port its API is tunnelled to. The node keeps them when
+// restarted, as long as it is not launched again.
+func (r *SSHRunner) Endpoints(repoDir string, o Options) (Endpoints, error) {
+	if o.Family != IPv4 {
+		return Endpoints{}, fmt.Errorf("nodes: ssh nodes listen on IPv4 only, not %s", o.Family)
+	}
+	if o.UnixSocket {
+		return Endpoints{}, errors.New("nodes: ssh nodes cannot serve the API on a unix socket")
+	}
+	if o.NAT != NoNAT {
+		return Endpoints{}, errors.New("nodes: putting ssh nodes behind a NAT needs a docker runner")
+	}
+	if o.Tor != NoTor {
+		return Endpoints{}, errors.New("nodes: Tor reaches onion services on the loopback of their node, which only local nodes share with it")
+	}
+	n := r.node(repoDir)
+	tunnel, err := freePort(IPv4)
+	if err != nil {
+		return Endpoints{}, err
+	}
+	r.lock.Lock()
+	base := r.ports[n.host.Dest]
+	if base == 0 {
+		base = r.opts.BasePort
+	}
+	r.ports[n.host.Dest] = base + 2
+	n.swarm, n.api, n.tunnel = base, base+1, tunnel
+	r.lock.Unlock()
+
+	return Endpoints{
+		Gateway:    fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", n.api),
+		Swarm:      []string{fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", n.swarm)},
+		SwarmAddrs: []string{fmt.Sprintf("/ip4/%s/tcp/%d", n.host.Addr, n.swarm)},
+		Client:     client.New(fmt.Sprintf("http://127.0.0.1:%d", tunnel)),
+	}, nil
+}
+
+// startCommand is the remote command line running the node. It records the
+// pid so the node can be signalled, then execs the binary, so the node
+// exits with the ssh session.
+func startCommand(bin, pidFile string, env, args []string) string {
+	words := append([]string{"env"}, env...)
+	words = append(words, bin)
+	words = append(words, args...)
+	return fmt.Sprintf("echo $$ > %s && exec %s", shellQuote(pidFile), shellCommand(words...))
+}
+
+// Run pushes the config to the node's host and starts the node in an ssh
+// session whose output goes to the log, along with a second session
+// tunnelling the API
+func (r *SSHRunner) Run(c Command) (Instance, error) {
+	r.lock.Lock()
+	n, ok := r.nodes[c.RepoDir]
+	r.lock.Unlock()
+	if !ok || n.tunnel == 0 {
+		return nil, fmt.Errorf("nodes: %s has no ssh endpoints", c.RepoDir)
+	}
+	if !c.Limits.none() {
+		return nil, errors.New("nodes: ssh nodes run on real hardware and take no resource limits")
+	}
+	ctx := context.Background()
+	bin, err := r.binary(ctx, n.host, c.Binary)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ioutil.ReadFile(filepath.Join(c.RepoDir, "config"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.ssh(ctx, n.host, bytes.NewReader(cfg), "cat > "+shellQuote(n.repo+"/config")); err != nil {
+		return nil, err
+	}
+	// The harness names the repo by its local path
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		if a == c.RepoDir {
+			a = n.repo
+		}
+		args[i] = a
+	}
+
+	log, err := openLog(c.Log)
+	if err != nil {
+		return nil, err
+	}
+	pidFile := n.repo + "/openbazaard.pid"
+	cmd := exec.Command(r.opts.SSH, r.sshArgs(n.host, startCommand(bin, pidFile, c.Env, args))...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return nil, err
+	}
+	tunnel := exec.Command(r.opts.SSH, "-N", "-o", "BatchMode=yes", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=15",
+		"-L", fmt.Sprintf("127.0.0.1:%d:127.0.0.1:%d", n.tunnel, n.api), n.host.Dest)
+	tunnel.Stderr = log
+	if err := tunnel.Start(); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		log.Close()
+		return nil, err
+	}
+	return &sshProcess{runner: r, host: n.host, pidFile: pidFile, cmd: cmd, tunnel: tunnel, log: log}, nil
+}
+
+// Close removes the repos of every node from their hosts, keeping the
+// binaries for the next run. Nodes have to be stopped first.
+func (r *SSHRunner) Close() error {
+	r.lock.Lock()
+	repos := make(map[SSHHost][]string)
+	for _, n := range r.nodes {
+		repos[n.host] = append(repos[n.host], n.repo)
+	}
+	r.lock.Unlock()
+	var msgs []string
+	for host, dirs := range repos {
+		if _, err := r.ssh(context.Background(), host, nil, "rm -rf "+shellCommand(dirs...)); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// sshProcess is a node running in an ssh session
+type sshProcess struct {
+	runner  *SSHRunner
+	host    SSHHost
+	pidFile string
+	cmd     *exec.Cmd
+	tunnel  *exec.Cmd
+	log     *os.File
+}
+
+func (p *sshProcess) Wait() error {
+	defer p.log.Close()
+	err := p.cmd.Wait()
+	p.tunnel.Process.Kill()
+	p.tunnel.Wait()
+	return err
+}
+
+// signal sends the signal to the node on its host
+func (p *sshProcess) signal(sig string) error {
+	_, err := p.runner.ssh(context.Background(), p.host, nil, fmt.Sprintf("kill -%s $(cat %s)", sig, shellQuote(p.pidFile)))
+	return err
+}
+
+// Kill kills the node on its host; killing the session alone would leave
+// it running if the connection is gone
+func (p *sshProcess) Kill() error {
+	err := p.signal("KILL")
+	p.cmd.Process.Kill()
+	return err
+}
+
+func (p *sshProcess) Pause() error  { return p.signal("STOP") }
+func (p *sshProcess) Resume() error { return p.signal("CONT") }
+
+// MemoryUsage returns the resident memory ps reports for the node
+func (p *sshProcess) MemoryUsage() (uint64, error) {
+	out, err := p.runner.ssh(context.Background(), p.host, nil, fmt.Sprintf("ps -o rss= -p $(cat %s)", shellQuote(p.pidFile)))
+	if err != nil {
+		return 0, err
+	}
+	kib, err := strconv.ParseUint(out, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("nodes: resident memory %q: %s", out, err)
+	}
+	return kib << 10, nil
+}
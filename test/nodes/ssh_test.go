@@ -0,0 +1,48 @@
+package nodes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellCommand(t *testing.T) {
+	got := shellCommand("openbazaard", "-d", "it's here")
+	if want := `'openbazaard' '-d' 'it'\''s here'`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	got = startCommand("bin/openbazaard", "repo/openbazaard.pid", []string{"OB_FEATURES=a,b"}, []string{"start", "-d", "repo"})
+	if want := `echo $$ > 'repo/openbazaard.pid' && exec 'env' 'OB_FEATURES=a,b' 'bin/openbazaard' 'start' '-d' 'repo'`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestSSHEndpoints(t *testing.T) {
+	r, err := NewSSHRunner(SSHOptions{Hosts: []SSHHost{
+		{Dest: "ubuntu@eu-west", Addr: "203.0.113.10"},
+		{Dest: "ubuntu@us-east", Addr: "198.51.100.20"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var swarm []string
+	for _, dir := range []string{"/tmp/node-1", "/tmp/node-2", "/tmp/node-3"} {
+		ep, err := r.Endpoints(dir, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(ep.Client.BaseURL, "http://127.0.0.1:") {
+			t.Errorf("Expected the API tunnelled to loopback, got %s", ep.Client.BaseURL)
+		}
+		swarm = append(swarm, ep.SwarmAddrs[0])
+	}
+	want := []string{"/ip4/203.0.113.10/tcp/40000", "/ip4/198.51.100.20/tcp/40000", "/ip4/203.0.113.10/tcp/40002"}
+	if strings.Join(swarm, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected nodes spread over the hosts at %v, got %v", want, swarm)
+	}
+	if n := r.nodes["/tmp/node-3"]; n.api != 40003 || !strings.HasPrefix(n.repo, "testnodes/repos/openbazaard-node-3-") {
+		t.Errorf("Unexpected node %+v", n)
+	}
+	if _, err := r.Endpoints("/tmp/node-4", Options{UnixSocket: true}); err == nil {
+		t.Error("Expected a unix socket API to be refused")
+	}
+}
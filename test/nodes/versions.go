@@ -0,0 +1,82 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// BinaryVersion returns the release version binary reports with --version
+func BinaryVersion(binary string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %s", binary, err)
+	}
+	v := strings.TrimSpace(string(out))
+	if v == "" || strings.ContainsAny(v, " \n") {
+		return "", fmt.Errorf("%s --version printed %q", binary, v)
+	}
+	return v, nil
+}
+
+// Binaries is a registry of openbazaard release binaries by version, so one
+// network can mix releases, e.g. a 0.12 vendor with a 0.13 buyer
+type Binaries struct {
+	lock      sync.Mutex
+	byVersion map[string]string
+}
+
+// NewBinaries registers every binary under the version it reports
+func NewBinaries(binaries ...string) (*Binaries, error) {
+	b := &Binaries{byVersion: make(map[string]string)}
+	for _, bin := range binaries {
+		if _, err := b.Add(bin); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Add registers binary under the version it reports and returns that
+// version. A later binary of the same version replaces the earlier one.
+func (b *Binaries) Add(binary string) (string, error) {
+	v, err := BinaryVersion(binary)
+	if err != nil {
+		return "", err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.byVersion[v] = binary
+	return v, nil
+}
+
+// Binary returns the binary registered for version
+func (b *Binaries) Binary(version string) (string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	bin, ok := b.byVersion[strings.TrimPrefix(version, "v")]
+	if !ok {
+		return "", fmt.Errorf("no openbazaard %s registered", version)
+	}
+	return bin, nil
+}
+
+// Versions returns the registered versions, oldest first
+func (b *Binaries) Versions() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	ret := make([]string, 0, len(b.byVersion))
+	for v := range b.byVersion {
+		ret = append(ret, v)
+	}
+	sort.Slice(ret, func(i, j int) bool { return client.CompareVersions(ret[i], ret[j]) < 0 })
+	return ret
+}
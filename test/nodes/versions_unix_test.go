@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+package nodes
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBinaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes-versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fake := func(name, version string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	old, cur := fake("old", "0.9.4"), fake("cur", "0.13.0")
+
+	b, err := NewBinaries(cur, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Versions(); !reflect.DeepEqual(got, []string{"0.9.4", "0.13.0"}) {
+		t.Errorf("Expected versions oldest first, got %v", got)
+	}
+	if bin, err := b.Binary("v0.9.4"); err != nil || bin != old {
+		t.Errorf("Expected %s for v0.9.4, got %s, %v", old, bin, err)
+	}
+	if _, err := b.Binary("0.12.0"); err == nil {
+		t.Error("Expected an unregistered version to fail")
+	}
+	if _, err := b.Add(fake("broken", "")); err == nil {
+		t.Error("Expected a binary printing no version to be rejected")
+	}
+
+	m, err := NewManager(cur, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.Spawn(context.Background(), 1, WithVersion("0.9.4")); err == nil {
+		t.Error("Expected spawning a version without registered binaries to fail")
+	}
+}
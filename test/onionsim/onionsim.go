@@ -0,0 +1,420 @@
+// Package onionsim stands in for a Tor daemon, so nodes can run as onion
+// services in CI without a Tor network. It speaks just enough of the control
+// protocol for the node's onion transport, PROTOCOLINFO, AUTHENTICATE,
+// GETINFO net/listeners/socks, ADD_ONION and DEL_ONION, and runs the SOCKS5
+// proxy the control port points to. The proxy connects .onion addresses to
+// the port their service was added with and refuses everything else, as
+// though no exit would carry it.
+package onionsim
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yawning/bulb/utils/pkcs1"
+)
+
+// Server is a running simulator
+type Server struct {
+	control net.Listener
+	socks   net.Listener
+
+	lock     sync.Mutex
+	services map[string]map[uint16]string
+	circuits int
+	refused  []string
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Start listens for controllers and SOCKS clients on two ports of host,
+// e.g. 127.0.0.1. Onion services are added with a bare port as their
+// target, so the nodes must run on the same host.
+func Start(host string) (*Server, error) {
+	control, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	socks, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	s := &Server{
+		control:  control,
+		socks:    socks,
+		services: make(map[string]map[uint16]string),
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(2)
+	go s.serve(control, s.controlSession)
+	go s.serve(socks, s.socksSession)
+	return s, nil
+}
+
+// Control is the host:port of the control port to put in the node's Tor
+// config
+func (s *Server) Control() string {
+	return s.control.Addr().String()
+}
+
+// SOCKS is the host:port of the SOCKS5 proxy
+func (s *Server) SOCKS() string {
+	return s.socks.Addr().String()
+}
+
+// Close stops both listeners and ends open sessions and circuits
+func (s *Server) Close() error {
+	err := s.control.Close()
+	if serr := s.socks.Close(); err == nil {
+		err = serr
+	}
+	s.lock.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Services returns the IDs of the onion services published, sorted
+func (s *Server) Services() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret []string
+	for id := range s.services {
+		ret = append(ret, id)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Circuits is how many connections to onion services the proxy carried
+func (s *Server) Circuits() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.circuits
+}
+
+// Refused returns the host:port of every connection the proxy refused for
+// not going to an onion service, oldest first
+func (s *Server) Refused() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.refused...)
+}
+
+func (s *Server) serve(l net.Listener, session func(net.Conn)) {
+	defer s.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			session(conn)
+			conn.Close()
+			s.untrack(conn)
+		}()
+	}
+}
+
+// track records an open connection for Close, failing once closed
+func (s *Server) track(c net.Conn) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = true
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.lock.Lock()
+	delete(s.conns, c)
+	s.lock.Unlock()
+}
+
+// controlSession runs one controller connection. Onion services it added
+// go away with it, as they do with Tor unless detached.
+func (s *Server) controlSession(conn net.Conn) {
+	c := textproto.NewConn(conn)
+	authenticated := false
+	var added []string
+	defer func() {
+		s.lock.Lock()
+		for _, id := range added {
+			delete(s.services, id)
+		}
+		s.lock.Unlock()
+	}()
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		verb = strings.ToUpper(verb)
+		if !authenticated && verb != "PROTOCOLINFO" && verb != "AUTHENTICATE" && verb != "QUIT" {
+			c.PrintfLine("514 Authentication required.")
+			return
+		}
+		switch verb {
+		case "PROTOCOLINFO":
+			c.PrintfLine("250-PROTOCOLINFO 1")
+			c.PrintfLine("250-AUTH METHODS=NULL")
+			c.PrintfLine(`250-VERSION Tor="0.3.1.9-onionsim"`)
+			c.PrintfLine("250 OK")
+		case "AUTHENTICATE":
+			authenticated = true
+			c.PrintfLine("250 OK")
+		case "GETINFO":
+			if arg != "net/listeners/socks" {
+				c.PrintfLine("552 Unrecognized key %q", arg)
+				continue
+			}
+			c.PrintfLine("250-net/listeners/socks=%q", s.SOCKS())
+			c.PrintfLine("250 OK")
+		case "ADD_ONION":
+			id, key, err := s.addOnion(arg)
+			if err != nil {
+				c.PrintfLine("%s", err)
+				continue
+			}
+			added = append(added, id)
+			c.PrintfLine("250-ServiceID=%s", id)
+			if key != "" {
+				c.PrintfLine("250-PrivateKey=RSA1024:%s", key)
+			}
+			c.PrintfLine("250 OK")
+		case "DEL_ONION":
+			s.lock.Lock()
+			_, ok := s.services[arg]
+			delete(s.services, arg)
+			s.lock.Unlock()
+			if !ok {
+				c.PrintfLine("552 Unknown Onion Service id")
+				continue
+			}
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("250 closing connection")
+			return
+		default:
+			c.PrintfLine("510 Unrecognized command %q", verb)
+		}
+	}
+}
+
+// addOnion publishes the service ADD_ONION describes, returning its ID and,
+// for a new key that is not to be discarded, the key. Errors are the reply
+// to send.
+func (s *Server) addOnion(arg string) (id, newKey string, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) < 2 {
+		return "", "", fmt.Errorf("512 Missing argument to ADD_ONION")
+	}
+	var priv *rsa.PrivateKey
+	discard := false
+	ports := make(map[uint16]string)
+	for _, f := range fields[1:] {
+		switch {
+		case strings.HasPrefix(f, "Port="):
+			spec := strings.SplitN(strings.TrimPrefix(f, "Port="), ",", 2)
+			virt, perr := strconv.ParseUint(spec[0], 10, 16)
+			if perr != nil || virt == 0 {
+				return "", "", fmt.Errorf("512 Invalid VIRTPORT/TARGET")
+			}
+			target := "127.0.0.1:" + spec[0]
+			if len(spec) == 2 {
+				target = spec[1]
+				if !strings.Contains(target, ":") {
+					target = "127.0.0.1:" + target
+				}
+			}
+			ports[uint16(virt)] = target
+		case strings.HasPrefix(f, "Flags="):
+			for _, flag := range strings.Split(strings.TrimPrefix(f, "Flags="), ",") {
+				discard = discard || flag == "DiscardPK"
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return "", "", fmt.Errorf("512 Missing 'Port' argument")
+	}
+	switch key := fields[0]; {
+	case key == "NEW:BEST" || key == "NEW:RSA1024":
+		if priv, err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+			return "", "", fmt.Errorf("551 Failed to generate onion key")
+		}
+		if !discard {
+			der, err := pkcs1.EncodePrivateKeyDER(priv)
+			if err != nil {
+				return "", "", fmt.Errorf("551 Failed to encode onion key")
+			}
+			newKey = base64.StdEncoding.EncodeToString(der)
+		}
+	case strings.HasPrefix(key, "RSA1024:"):
+		der, derr := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, "RSA1024:"))
+		if derr == nil {
+			priv, _, derr = pkcs1.DecodePrivateKeyDER(der)
+		}
+		if derr != nil {
+			return "", "", fmt.Errorf("512 Failed to decode RSA key")
+		}
+	default:
+		return "", "", fmt.Errorf("513 Invalid key type")
+	}
+	if id, err = pkcs1.OnionAddr(&priv.PublicKey); err != nil {
+		return "", "", fmt.Errorf("551 Failed to derive onion address")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.services[id]; ok {
+		return "", "", fmt.Errorf("550 Onion address collision")
+	}
+	s.services[id] = ports
+	return id, newKey, nil
+}
+
+// SOCKS5 replies
+const (
+	socksOK          = 0
+	socksFailure     = 1
+	socksNotAllowed  = 2
+	socksUnreachable = 4
+	socksRefused     = 5
+	socksNoCommand   = 7
+	socksNoAddrType  = 8
+)
+
+// socksSession runs one SOCKS5 connection, carrying it to an onion service
+func (s *Server) socksSession(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil || hdr[0] != 5 {
+		return
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+	if strings.IndexByte(string(methods), 0) < 0 {
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(r, req[:]); err != nil {
+		return
+	}
+	if req[1] != 1 {
+		reply(conn, socksNoCommand)
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if req[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return
+		}
+		host = ip.String()
+	case 3:
+		n, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		reply(conn, socksNoAddrType)
+		return
+	}
+	var port uint16
+	if err := binary.Read(r, binary.BigEndian, &port); err != nil {
+		return
+	}
+
+	dest := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	if !strings.HasSuffix(host, ".onion") {
+		s.lock.Lock()
+		s.refused = append(s.refused, dest)
+		s.lock.Unlock()
+		reply(conn, socksNotAllowed)
+		return
+	}
+	s.lock.Lock()
+	target, ok := s.services[strings.TrimSuffix(host, ".onion")][port]
+	s.lock.Unlock()
+	if !ok {
+		reply(conn, socksUnreachable)
+		return
+	}
+	service, err := net.Dial("tcp", target)
+	if err != nil {
+		reply(conn, socksRefused)
+		return
+	}
+	defer service.Close()
+	if !s.track(service) {
+		reply(conn, socksFailure)
+		return
+	}
+	defer s.untrack(service)
+	if err := reply(conn, socksOK); err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.circuits++
+	s.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(conn, service)
+		conn.Close()
+		close(done)
+	}()
+	io.Copy(service, r)
+	service.Close()
+	<-done
+}
+
+// reply answers a SOCKS5 request, with a zero bound address
+func reply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,128 @@
+package onionsim
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yawning/bulb"
+	"github.com/yawning/bulb/utils/pkcs1"
+)
+
+func dial(t *testing.T, s *Server) *bulb.Conn {
+	c, err := bulb.Dial("tcp4", s.Control())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestOnionService drives the simulator the way the node's onion transport
+// drives Tor
+func TestOnionService(t *testing.T) {
+	s, err := Start("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := pkcs1.OnionAddr(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := dial(t, s)
+	defer service.Close()
+	l, err := service.Listener(4003, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := l.Addr().String(); addr != id+".onion:4003" {
+		t.Errorf("Expected the service at %s.onion:4003, got %s", id, addr)
+	}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("over tor"))
+		conn.Close()
+	}()
+
+	client := dial(t, s)
+	defer client.Close()
+	dialer, err := client.Dialer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.Dial("tcp4", id+".onion:4003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(conn)
+	conn.Close()
+	if err != nil || string(got) != "over tor" {
+		t.Errorf("Expected to read from the service, got %q, %v", got, err)
+	}
+	if s.Circuits() != 1 {
+		t.Errorf("Expected 1 circuit, got %d", s.Circuits())
+	}
+
+	if _, err := dialer.Dial("tcp4", "127.0.0.1:4001"); err == nil {
+		t.Error("Expected a clearnet connection to be refused")
+	}
+	if _, err := dialer.Dial("tcp4", id+".onion:4001"); err == nil {
+		t.Error("Expected a connection to an unpublished port to fail")
+	}
+	if refused := s.Refused(); len(refused) != 1 || refused[0] != "127.0.0.1:4001" {
+		t.Errorf("Expected the clearnet connection recorded, got %v", refused)
+	}
+
+	if services := s.Services(); len(services) != 1 || services[0] != id {
+		t.Errorf("Expected service %s, got %v", id, services)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if services := s.Services(); len(services) != 0 {
+		t.Errorf("Expected no services once the listener closed, got %v", services)
+	}
+}
+
+func TestServicesGoWithTheController(t *testing.T) {
+	s, err := Start("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := dial(t, s)
+	info, err := c.AddOnion([]bulb.OnionPortSpec{{VirtPort: 80, Target: "8080"}}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PrivateKey == nil {
+		t.Error("Expected the new key back")
+	}
+	if _, err := c.AddOnion([]bulb.OnionPortSpec{{VirtPort: 80}}, info.PrivateKey, false); err == nil || !strings.Contains(err.Error(), "collision") {
+		t.Errorf("Expected adding the service twice to collide, got %v", err)
+	}
+	c.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for len(s.Services()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if services := s.Services(); len(services) != 0 {
+		t.Errorf("Expected the services of a closed controller gone, got %v", services)
+	}
+}
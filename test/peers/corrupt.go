@@ -0,0 +1,74 @@
+package peers
+
+import (
+	"encoding/binary"
+
+	pb "github.com/ipfs/go-ipfs/exchange/bitswap/message/pb"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+// SetCorrupt makes the peer flip every byte of the blocks it sends, so the
+// data no longer matches the multihash the requester asked for
+func (p *Peer) SetCorrupt(corrupt bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.corrupt = corrupt
+}
+
+// Corrupting reports whether the peer currently sends garbage blocks
+func (p *Peer) Corrupting() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.corrupt
+}
+
+// corruptStream buffers outgoing bitswap frames and rewrites the block data
+// of each one before it hits the wire
+type corruptStream struct {
+	inet.Stream
+	buf []byte
+}
+
+func (s *corruptStream) Write(b []byte) (int, error) {
+	s.buf = append(s.buf, b...)
+	for {
+		size, k := binary.Uvarint(s.buf)
+		if k <= 0 || len(s.buf) < k+int(size) {
+			return len(b), nil
+		}
+		frame := s.buf[k : k+int(size)]
+		s.buf = s.buf[k+int(size):]
+		if err := s.writeFrame(frame); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (s *corruptStream) writeFrame(frame []byte) error {
+	msg := new(pb.Message)
+	if err := proto.Unmarshal(frame, msg); err == nil {
+		for _, b := range msg.Blocks {
+			flip(b)
+		}
+		for _, b := range msg.Payload {
+			flip(b.Data)
+		}
+		if out, err := proto.Marshal(msg); err == nil {
+			frame = out
+		}
+	}
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(frame)))
+	if _, err := s.Stream.Write(prefix[:n]); err != nil {
+		return err
+	}
+	_, err := s.Stream.Write(frame)
+	return err
+}
+
+func flip(b []byte) {
+	for i := range b {
+		b[i] ^= 0xff
+	}
+}
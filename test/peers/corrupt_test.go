@@ -0,0 +1,85 @@
+package peers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	pb "github.com/ipfs/go-ipfs/exchange/bitswap/message/pb"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+)
+
+// readFrames splits varint delimited frames
+func readFrames(t *testing.T, b []byte) [][]byte {
+	var frames [][]byte
+	for len(b) > 0 {
+		size, k := binary.Uvarint(b)
+		if k <= 0 || len(b) < k+int(size) {
+			t.Fatalf("Expected whole frames, got %x", b)
+		}
+		frames = append(frames, b[k:k+int(size)])
+		b = b[k+int(size):]
+	}
+	return frames
+}
+
+func TestCorruptStream(t *testing.T) {
+	block, payload := []byte("listing"), []byte("image")
+	msg := &pb.Message{
+		Blocks:  [][]byte{append([]byte(nil), block...)},
+		Payload: []*pb.Message_Block{{Prefix: []byte{1, 0x55}, Data: append([]byte(nil), payload...)}},
+	}
+	in := frame(t, msg)
+	in = append(in, frame(t, msg)...)
+
+	// Frames split across writes are rewritten whole
+	out := &fakeStream{}
+	s := &corruptStream{Stream: out}
+	for _, part := range [][]byte{in[:1], in[1:9], in[9:]} {
+		n, err := s.Write(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(part) {
+			t.Errorf("Expected %d bytes written, got %d", len(part), n)
+		}
+	}
+	frames := readFrames(t, out.out.Bytes())
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	for _, f := range frames {
+		got := new(pb.Message)
+		if err := proto.Unmarshal(f, got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Blocks) != 1 || !bytes.Equal(got.Blocks[0], flipped(block)) {
+			t.Errorf("Expected the block flipped, got %q", got.Blocks)
+		}
+		if len(got.Payload) != 1 || !bytes.Equal(got.Payload[0].Data, flipped(payload)) {
+			t.Errorf("Expected the payload data flipped, got %v", got.Payload)
+		}
+		if len(got.Payload) == 1 && !bytes.Equal(got.Payload[0].Prefix, []byte{1, 0x55}) {
+			t.Errorf("Expected the payload prefix kept, got %x", got.Payload[0].Prefix)
+		}
+	}
+}
+
+func TestCorruptStreamGarbage(t *testing.T) {
+	// What is no bitswap message goes out as it came
+	in := []byte{0x02, 0xff, 0xff}
+	out := &fakeStream{}
+	s := &corruptStream{Stream: out}
+	if _, err := s.Write(in); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.out.Bytes(), in) {
+		t.Errorf("Expected %x written, got %x", in, out.out.Bytes())
+	}
+}
+
+func flipped(b []byte) []byte {
+	c := append([]byte(nil), b...)
+	flip(c)
+	return c
+}
@@ -0,0 +1,103 @@
+package peers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/OpenBazaar/openbazaar-go/ipfs"
+	libp2p "gx/ipfs/QmP1DfoUjiWH2ZBo1PBH6FupdBucbDepx3HpWmEY6JMUpY/go-libp2p-crypto"
+	bhost "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/host/basic"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	swarm "gx/ipfs/QmVkDnNm71vYyY6s6rXwtmyDYis3WkKyrEhMECwT6R12uJ/go-libp2p-swarm"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	metrics "gx/ipfs/QmdibiN2wzuuXXz4JvqQ1ZGW3eUkoAy1AWznHFau6iePCc/go-libp2p-metrics"
+)
+
+// Crowd is a set of bare libp2p hosts that only hold a connection to one
+// node. They run no DHT, bitswap or OpenBazaar protocol and do not listen, so
+// hundreds fit in a test process where full peers would not.
+type Crowd struct {
+	target peer.ID
+	hosts  []p2phost.Host
+	cancel context.CancelFunc
+}
+
+// NewCrowd starts n hosts and connects each to the node at addr, a
+// multiaddr ending in /ipfs/<peerID>. It fails if any host cannot connect.
+func NewCrowd(ctx context.Context, n int, addr string) (*Crowd, error) {
+	pi, err := ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	c := &Crowd{target: pi.ID, cancel: cancel}
+	for i := 0; i < n; i++ {
+		h, err := newBareHost(cctx)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.hosts = append(c.hosts, h)
+		if err := h.Connect(ctx, pi); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("peers: crowd host %d of %d connecting to %s: %s", i+1, n, pi.ID.Pretty(), err)
+		}
+	}
+	return c, nil
+}
+
+func newBareHost(ctx context.Context) (p2phost.Host, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	b, err := ipfs.IdentityKeyFromSeed(seed, 256)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := libp2p.UnmarshalPrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	ps := pstore.NewPeerstore()
+	ps.AddPrivKey(id, sk)
+	ps.AddPubKey(id, sk.GetPublic())
+	network, err := swarm.NewNetwork(ctx, nil, id, ps, metrics.NewBandwidthCounter())
+	if err != nil {
+		return nil, err
+	}
+	return bhost.New(network), nil
+}
+
+// Size is the number of hosts in the crowd
+func (c *Crowd) Size() int { return len(c.hosts) }
+
+// Connected returns how many hosts of the crowd are still connected to the
+// node, the rest having been pruned by it
+func (c *Crowd) Connected() int {
+	n := 0
+	for _, h := range c.hosts {
+		if len(h.Network().ConnsToPeer(c.target)) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Close disconnects and stops every host
+func (c *Crowd) Close() error {
+	var first error
+	for _, h := range c.hosts {
+		if err := h.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	c.cancel()
+	return first
+}
@@ -0,0 +1,194 @@
+// Package peers runs lightweight in-process libp2p peers that join a test
+// network under harness control, either to observe traffic or to misbehave.
+package peers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/OpenBazaar/openbazaar-go/ipfs"
+	"github.com/OpenBazaar/openbazaar-go/test/datastore"
+	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	ipnet "gx/ipfs/QmPsBptED6X43GYg3347TAUruN3UfsAhaGTP9xbinYX7uf/go-libp2p-interface-pnet"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	pstore "gx/ipfs/QmXZSd1qR5BxZkPyuwfT5jpqQFScZccoZvDneXsKzCNHWX/go-libp2p-peerstore"
+	ma "gx/ipfs/QmcyqRMCAXVtYPS4DiBrA7sezL9rRGfW8Ctx7cywL4TXJj/go-multiaddr"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+	metrics "gx/ipfs/QmdibiN2wzuuXXz4JvqQ1ZGW3eUkoAy1AWznHFau6iePCc/go-libp2p-metrics"
+	smux "gx/ipfs/QmeZBgYBHvxMukGK5ojg28BCNLB9SeXqT7XXg6o7r2GbJy/go-stream-muxer"
+)
+
+// DefaultListenAddr is the swarm address peers listen on unless told otherwise
+const DefaultListenAddr = "/ip4/127.0.0.1/tcp/0"
+
+// Options configures a new peer
+type Options struct {
+	// ListenAddr is the swarm listen address, DefaultListenAddr if empty
+	ListenAddr string
+
+	// Bootstrap is a list of /ipfs/ multiaddrs to connect to on start
+	Bootstrap []string
+
+	// Datastore is the IPFS datastore backend, datastore.Memory if empty
+	Datastore datastore.Backend
+
+	// Dir holds the files of on-disk datastores. A temporary directory,
+	// removed on Close, is used when empty.
+	Dir string
+}
+
+// Peer is an in-process IPFS node speaking the OpenBazaar protocols
+type Peer struct {
+	Node *core.IpfsNode
+
+	// DHT counts the DHT requests this peer received
+	DHT *DHTCounter
+
+	cancel    context.CancelFunc
+	datastore datastore.Datastore
+	tmpDir    string
+
+	lock      sync.Mutex
+	blockRate int
+	corrupt   bool
+}
+
+// New starts a peer and connects it to the bootstrap addresses
+func New(ctx context.Context, opts Options) (*Peer, error) {
+	if opts.ListenAddr == "" {
+		opts.ListenAddr = DefaultListenAddr
+	}
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	key, err := ipfs.IdentityKeyFromSeed(seed, 256)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := ipfs.IdentityFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	p := &Peer{DHT: newDHTCounter()}
+	dir := opts.Dir
+	if dir == "" && opts.Datastore != datastore.Memory && opts.Datastore != "" {
+		if dir, err = ioutil.TempDir("", "peer-datastore"); err != nil {
+			return nil, err
+		}
+		p.tmpDir = dir
+	}
+	d, err := datastore.Open(opts.Datastore, dir)
+	if err != nil {
+		p.removeDir()
+		return nil, err
+	}
+	p.datastore = d
+	r := &repo.Mock{
+		D: d,
+		C: config.Config{
+			Identity:  identity,
+			Addresses: config.Addresses{Swarm: []string{opts.ListenAddr}},
+			Discovery: config.Discovery{MDNS: config.MDNS{Enabled: false}},
+		},
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	p.Node, err = core.NewNode(cctx, &core.BuildCfg{
+		Repo:   r,
+		Online: true,
+		Host:   p.hostOption,
+	})
+	if err != nil {
+		cancel()
+		d.Close()
+		p.removeDir()
+		return nil, err
+	}
+	for _, addr := range opts.Bootstrap {
+		if err := p.Connect(ctx, addr); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// hostOption wraps the default libp2p host so the peer can observe and
+// tamper with the streams of the DHT and bitswap protocols
+func (p *Peer) hostOption(ctx context.Context, id peer.ID, ps pstore.Peerstore, bwr metrics.Reporter, fs []*net.IPNet, tpt smux.Transport, protc ipnet.Protector, opts *core.ConstructPeerHostOpts) (p2phost.Host, error) {
+	h, err := core.DefaultHostOption(ctx, id, ps, bwr, fs, tpt, protc, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &tapHost{Host: h, peer: p}, nil
+}
+
+// ID returns the peer ID
+func (p *Peer) ID() peer.ID {
+	return p.Node.Identity
+}
+
+// Addrs returns the dialable /ipfs/ multiaddrs of the peer
+func (p *Peer) Addrs() []string {
+	var addrs []string
+	for _, a := range p.Node.PeerHost.Addrs() {
+		addrs = append(addrs, a.String()+"/ipfs/"+p.ID().Pretty())
+	}
+	return addrs
+}
+
+// Connect dials a peer given a multiaddr ending in /ipfs/<peerID>
+func (p *Peer) Connect(ctx context.Context, addr string) error {
+	pi, err := ParseAddr(addr)
+	if err != nil {
+		return err
+	}
+	return p.Node.PeerHost.Connect(ctx, pi)
+}
+
+// Close shuts the peer down
+func (p *Peer) Close() error {
+	p.cancel()
+	err := p.Node.Close()
+	// The mock repo does not close its datastore
+	p.datastore.Close()
+	p.removeDir()
+	return err
+}
+
+func (p *Peer) removeDir() {
+	if p.tmpDir != "" {
+		os.RemoveAll(p.tmpDir)
+	}
+}
+
+// ParseAddr splits a multiaddr ending in /ipfs/<peerID> into peer info
+func ParseAddr(addr string) (pstore.PeerInfo, error) {
+	i := strings.LastIndex(addr, "/ipfs/")
+	if i < 0 {
+		return pstore.PeerInfo{}, fmt.Errorf("peers: %s has no /ipfs/ component", addr)
+	}
+	id, err := peer.IDB58Decode(addr[i+len("/ipfs/"):])
+	if err != nil {
+		return pstore.PeerInfo{}, err
+	}
+	pi := pstore.PeerInfo{ID: id}
+	if i > 0 {
+		transport, err := ma.NewMultiaddr(addr[:i])
+		if err != nil {
+			return pstore.PeerInfo{}, err
+		}
+		pi.Addrs = []ma.Multiaddr{transport}
+	}
+	return pi, nil
+}
@@ -0,0 +1,142 @@
+package peers
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	dht "github.com/ipfs/go-ipfs/routing/dht"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	p2phost "gx/ipfs/QmUywuGNZoUKV8B9iyvup9bPkLiMrhTsyVMkeSXW5VxAfC/go-libp2p-host"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// maxFrameSize bounds the frames a tap buffers before giving up on a stream
+const maxFrameSize = 4 << 20
+
+// DHTCounter counts inbound DHT requests by message type and sender
+type DHTCounter struct {
+	lock   sync.Mutex
+	total  map[pb.Message_MessageType]int
+	byPeer map[peer.ID]map[pb.Message_MessageType]int
+}
+
+func newDHTCounter() *DHTCounter {
+	return &DHTCounter{
+		total:  make(map[pb.Message_MessageType]int),
+		byPeer: make(map[peer.ID]map[pb.Message_MessageType]int),
+	}
+}
+
+// Count returns how many requests of the given type were received
+func (c *DHTCounter) Count(t pb.Message_MessageType) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.total[t]
+}
+
+// CountFrom returns how many requests of the given type a peer sent
+func (c *DHTCounter) CountFrom(p peer.ID, t pb.Message_MessageType) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.byPeer[p][t]
+}
+
+// Senders returns every peer that sent at least one request
+func (c *DHTCounter) Senders() []peer.ID {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var ids []peer.ID
+	for id := range c.byPeer {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (c *DHTCounter) add(from peer.ID, t pb.Message_MessageType) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.total[t]++
+	if c.byPeer[from] == nil {
+		c.byPeer[from] = make(map[pb.Message_MessageType]int)
+	}
+	c.byPeer[from][t]++
+}
+
+// tapHost wraps the DHT stream handlers so every inbound message is counted
+// before the real handler sees it, and throttles outgoing bitswap streams
+type tapHost struct {
+	p2phost.Host
+	peer *Peer
+}
+
+func (h *tapHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (inet.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	if !isBitswap(s.Protocol()) {
+		return s, nil
+	}
+	if h.peer.Corrupting() {
+		s = &corruptStream{Stream: s}
+	}
+	if rate := h.peer.BlockRate(); rate > 0 {
+		s = &slowStream{Stream: s, rate: rate}
+	}
+	return s, nil
+}
+
+func (h *tapHost) SetStreamHandler(pid protocol.ID, handler inet.StreamHandler) {
+	if pid != dht.ProtocolDHT && pid != dht.ProtocolDHTOld {
+		h.Host.SetStreamHandler(pid, handler)
+		return
+	}
+	h.Host.SetStreamHandler(pid, func(s inet.Stream) {
+		handler(&tapStream{Stream: s, counter: h.peer.DHT, from: s.Conn().RemotePeer()})
+	})
+}
+
+// tapStream decodes the varint delimited DHT messages as they are read
+type tapStream struct {
+	inet.Stream
+	counter *DHTCounter
+	from    peer.ID
+	buf     []byte
+	broken  bool
+}
+
+func (s *tapStream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	if n > 0 && !s.broken {
+		s.buf = append(s.buf, b[:n]...)
+		s.decode()
+	}
+	return n, err
+}
+
+func (s *tapStream) decode() {
+	for {
+		size, k := binary.Uvarint(s.buf)
+		if k == 0 {
+			return
+		}
+		if k < 0 || size > maxFrameSize {
+			s.broken = true
+			s.buf = nil
+			return
+		}
+		end := k + int(size)
+		if len(s.buf) < end {
+			return
+		}
+		msg := new(pb.Message)
+		if err := proto.Unmarshal(s.buf[k:end], msg); err == nil {
+			s.counter.add(s.from, msg.GetType())
+		}
+		s.buf = s.buf[end:]
+	}
+}
@@ -0,0 +1,109 @@
+package peers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	proto "gx/ipfs/QmZ4Qi3GaRbjcx28Sme5eMH7RQjGkt8wHxt2a65oLaeFEV/gogo-protobuf/proto"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// fakeStream reads from in, chunk bytes at a time when chunk is set, and
+// records every write
+type fakeStream struct {
+	inet.Stream
+	in     *bytes.Reader
+	chunk  int
+	out    bytes.Buffer
+	writes []int
+}
+
+func (s *fakeStream) Read(b []byte) (int, error) {
+	if s.chunk > 0 && len(b) > s.chunk {
+		b = b[:s.chunk]
+	}
+	return s.in.Read(b)
+}
+
+func (s *fakeStream) Write(b []byte) (int, error) {
+	s.writes = append(s.writes, len(b))
+	return s.out.Write(b)
+}
+
+// frame encodes msg varint delimited, as libp2p protocols send it
+func frame(t *testing.T, msg proto.Message) []byte {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(data)))
+	return append(prefix[:n], data...)
+}
+
+func TestTapStream(t *testing.T) {
+	var in []byte
+	for _, typ := range []pb.Message_MessageType{pb.Message_FIND_NODE, pb.Message_GET_PROVIDERS, pb.Message_FIND_NODE} {
+		in = append(in, frame(t, pb.NewMessage(typ, "key", 0))...)
+	}
+	from := peer.ID("vendor")
+	for _, chunk := range []int{0, 1, 7} {
+		counter := newDHTCounter()
+		s := &tapStream{Stream: &fakeStream{in: bytes.NewReader(in), chunk: chunk}, counter: counter, from: from}
+		// The handler must read the stream unchanged
+		read, err := ioutil.ReadAll(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(read, in) {
+			t.Errorf("Expected the frames read unchanged in chunks of %d", chunk)
+		}
+		if n := counter.Count(pb.Message_FIND_NODE); n != 2 {
+			t.Errorf("Expected 2 FIND_NODE in chunks of %d, got %d", chunk, n)
+		}
+		if n := counter.CountFrom(from, pb.Message_GET_PROVIDERS); n != 1 {
+			t.Errorf("Expected 1 GET_PROVIDERS from the vendor in chunks of %d, got %d", chunk, n)
+		}
+		if senders := counter.Senders(); len(senders) != 1 || senders[0] != from {
+			t.Errorf("Expected the vendor as only sender, got %v", senders)
+		}
+	}
+}
+
+func TestTapStreamOversizedFrame(t *testing.T) {
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, maxFrameSize+1)
+	in := append(prefix[:n], frame(t, pb.NewMessage(pb.Message_PING, "", 0))...)
+	counter := newDHTCounter()
+	s := &tapStream{Stream: &fakeStream{in: bytes.NewReader(in)}, counter: counter, from: peer.ID("buyer")}
+	read, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read, in) {
+		t.Error("Expected a stream the tap gave up on still read unchanged")
+	}
+	if !s.broken || s.buf != nil {
+		t.Error("Expected the tap to give up on an oversized frame and drop its buffer")
+	}
+	if n := counter.Count(pb.Message_PING); n != 0 {
+		t.Errorf("Expected nothing counted past an oversized frame, got %d", n)
+	}
+}
+
+func TestTapStreamGarbage(t *testing.T) {
+	// A frame that is no DHT message is skipped, the next one still counted
+	in := append([]byte{0x02, 0xff, 0xff}, frame(t, pb.NewMessage(pb.Message_PUT_VALUE, "key", 0))...)
+	counter := newDHTCounter()
+	s := &tapStream{Stream: &fakeStream{in: bytes.NewReader(in)}, counter: counter, from: peer.ID("buyer")}
+	if _, err := ioutil.ReadAll(s); err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.Count(pb.Message_PUT_VALUE); n != 1 {
+		t.Errorf("Expected the PUT_VALUE after garbage counted, got %d", n)
+	}
+}
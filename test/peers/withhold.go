@@ -0,0 +1,67 @@
+package peers
+
+import (
+	"context"
+	"time"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	inet "gx/ipfs/QmRscs8KxrSmSv4iuevHv8JfuUzHBMoqiaHzxfDRiksd6e/go-libp2p-net"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+// Withhold announces the peer as a provider of c without holding the block.
+// Nodes that pick this provider send it a want that is never answered.
+func (p *Peer) Withhold(ctx context.Context, c *cid.Cid) error {
+	return p.Node.Routing.Provide(ctx, c, true)
+}
+
+// ServeSlowly stores data as a block, announces it and limits every block the
+// peer sends to bytesPerSecond
+func (p *Peer) ServeSlowly(data []byte, bytesPerSecond int) (*cid.Cid, error) {
+	p.SetBlockRate(bytesPerSecond)
+	return p.Node.Blocks.AddBlock(blocks.NewBlock(data))
+}
+
+// SetBlockRate limits outgoing bitswap streams to bytesPerSecond, zero
+// removes the limit
+func (p *Peer) SetBlockRate(bytesPerSecond int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.blockRate = bytesPerSecond
+}
+
+// BlockRate returns the current bitswap send limit in bytes per second
+func (p *Peer) BlockRate() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.blockRate
+}
+
+func isBitswap(pid protocol.ID) bool {
+	return pid == bsnet.ProtocolBitswap || pid == bsnet.ProtocolBitswapOne || pid == bsnet.ProtocolBitswapNoVers
+}
+
+// slowStream trickles writes out at a fixed rate
+type slowStream struct {
+	inet.Stream
+	rate int
+}
+
+func (s *slowStream) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		chunk := len(b) - written
+		if chunk > s.rate {
+			chunk = s.rate
+		}
+		n, err := s.Stream.Write(b[written : written+chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		time.Sleep(time.Duration(chunk) * time.Second / time.Duration(s.rate))
+	}
+	return written, nil
+}
@@ -0,0 +1,42 @@
+package peers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSlowStream(t *testing.T) {
+	out := &fakeStream{}
+	s := &slowStream{Stream: out, rate: 50}
+	data := bytes.Repeat([]byte{7}, 75)
+	start := time.Now()
+	n, err := s.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) || !bytes.Equal(out.out.Bytes(), data) {
+		t.Errorf("Expected all %d bytes written, got %d", len(data), n)
+	}
+	if len(out.writes) != 2 || out.writes[0] != 50 || out.writes[1] != 25 {
+		t.Errorf("Expected writes of at most the rate, got %v", out.writes)
+	}
+	if took := time.Since(start); took < 1400*time.Millisecond {
+		t.Errorf("Expected 75 bytes at 50 per second to take 1.5s, took %s", took)
+	}
+}
+
+func TestBlockRate(t *testing.T) {
+	p := &Peer{}
+	if p.BlockRate() != 0 || p.Corrupting() {
+		t.Error("Expected a new peer to send blocks as they are")
+	}
+	p.SetBlockRate(1024)
+	p.SetCorrupt(true)
+	if p.BlockRate() != 1024 {
+		t.Errorf("Expected a rate of 1024, got %d", p.BlockRate())
+	}
+	if !p.Corrupting() {
+		t.Error("Expected the peer to corrupt blocks")
+	}
+}
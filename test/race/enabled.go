@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package race
+
+// Enabled is true when the binary was built with the race detector
+const Enabled = true
@@ -0,0 +1,232 @@
+// Package race fires conflicting API mutations at the same instant and checks
+// that the node settles on a consistent final state.
+//
+// Run the in-process suites with `go test -race` so data races found while the
+// mutations overlap fail the test as well; RequireDetector skips otherwise.
+package race
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// Settle bounds how long the losing party of a conflict between two nodes
+// may take to hear of the outcome
+var Settle = time.Minute
+
+// Mutation is a single API call fired as part of a conflict
+type Mutation struct {
+	Name   string
+	Client *client.Client
+	Method string
+	Path   string
+	Body   interface{}
+}
+
+// Result is the outcome of one mutation
+type Result struct {
+	Mutation Mutation
+	Response *client.Response
+	Err      error
+}
+
+// Succeeded reports whether the mutation returned a 2xx response
+func (r Result) Succeeded() bool {
+	return r.Err == nil && r.Response.OK()
+}
+
+// Conflict is a group of mutations that must not be applied on top of each
+// other, plus a check run against the final state once all of them returned
+type Conflict struct {
+	Name      string
+	Mutations []Mutation
+	Check     func(results []Result) error
+}
+
+// RequireDetector skips the test unless the binary was built with -race
+func RequireDetector(t *testing.T) {
+	if !Enabled {
+		t.Skip("race detector not enabled, run with go test -race")
+	}
+}
+
+// Fire issues every mutation of the conflict concurrently. All requests are
+// built up front and released together so they reach the node as close to
+// simultaneously as possible.
+func Fire(conflict Conflict) []Result {
+	results := make([]Result, len(conflict.Mutations))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for n, m := range conflict.Mutations {
+		results[n].Mutation = m
+		req, err := m.Client.NewRequest(m.Method, m.Path, m.Body)
+		if err != nil {
+			results[n].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(n int, m Mutation) {
+			defer wg.Done()
+			<-start
+			results[n].Response, results[n].Err = m.Client.Send(req)
+		}(n, m)
+	}
+	close(start)
+	wg.Wait()
+	return results
+}
+
+// Run fires the conflict next returns for each of the given number of rounds
+// and returns the first consistency violation. Conflicts over an order leave
+// it in a terminal state, so next places a fresh order every round.
+func Run(rounds int, next func(round int) (Conflict, error)) error {
+	for i := 1; i <= rounds; i++ {
+		conflict, err := next(i)
+		if err != nil {
+			return fmt.Errorf("round %d: %s", i, err)
+		}
+		results := Fire(conflict)
+		if conflict.Check == nil {
+			continue
+		}
+		if err := conflict.Check(results); err != nil {
+			return fmt.Errorf("%s (round %d): %s", conflict.Name, i, err)
+		}
+	}
+	return nil
+}
+
+// ExactlyOneWins returns an error unless exactly one mutation succeeded
+func ExactlyOneWins(results []Result) (Result, error) {
+	var winner Result
+	wins := 0
+	for _, r := range results {
+		if r.Succeeded() {
+			winner = r
+			wins++
+		}
+	}
+	if wins != 1 {
+		return winner, fmt.Errorf("expected exactly one mutation to succeed, %d did", wins)
+	}
+	return winner, nil
+}
+
+// DoubleFulfillment sends the same fulfillment twice from the vendor. Only one
+// may be accepted and the sale must end up FULFILLED.
+func DoubleFulfillment(vendor *client.Client, orderID string, fulfillment interface{}) Conflict {
+	return Conflict{
+		Name: "double fulfillment",
+		Mutations: []Mutation{
+			{Name: "fulfill-1", Client: vendor, Method: "POST", Path: "/ob/orderfulfillment", Body: fulfillment},
+			{Name: "fulfill-2", Client: vendor, Method: "POST", Path: "/ob/orderfulfillment", Body: fulfillment},
+		},
+		Check: func(results []Result) error {
+			if _, err := ExactlyOneWins(results); err != nil {
+				return err
+			}
+			return expectState(vendor, orderID, "FULFILLED")
+		},
+	}
+}
+
+// CancelVsFulfill races a buyer cancel against a vendor fulfillment. Whichever
+// wins, both parties must agree on the resulting state, the losing one within
+// Settle of hearing of it.
+func CancelVsFulfill(buyer, vendor *client.Client, orderID string, fulfillment interface{}) Conflict {
+	return Conflict{
+		Name: "cancel vs fulfill",
+		Mutations: []Mutation{
+			{Name: "cancel", Client: buyer, Method: "POST", Path: "/ob/ordercancel", Body: map[string]string{"orderId": orderID}},
+			{Name: "fulfill", Client: vendor, Method: "POST", Path: "/ob/orderfulfillment", Body: fulfillment},
+		},
+		Check: func(results []Result) error {
+			winner, err := ExactlyOneWins(results)
+			if err != nil {
+				return err
+			}
+			want, from, to := "FULFILLED", vendor, buyer
+			if winner.Mutation.Name == "cancel" {
+				want, from, to = "CANCELED", buyer, vendor
+			}
+			if err := expectState(from, orderID, want); err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), Settle)
+			defer cancel()
+			return to.WaitOrderState(ctx, orderID, want)
+		},
+	}
+}
+
+// ProfileUpdates sends every profile as a simultaneous PUT /ob/profile. The
+// stored profile must afterwards equal one of the submitted ones rather than
+// a mix of fields from several.
+func ProfileUpdates(c *client.Client, profiles ...map[string]interface{}) Conflict {
+	var mutations []Mutation
+	for n, p := range profiles {
+		mutations = append(mutations, Mutation{
+			Name:   fmt.Sprintf("profile-%d", n+1),
+			Client: c,
+			Method: "PUT",
+			Path:   "/ob/profile",
+			Body:   p,
+		})
+	}
+	return Conflict{
+		Name:      "simultaneous profile updates",
+		Mutations: mutations,
+		Check: func(results []Result) error {
+			resp, err := c.Get("/ob/profile")
+			if err != nil {
+				return err
+			}
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			var stored map[string]interface{}
+			if err := resp.Decode(&stored); err != nil {
+				return err
+			}
+			for _, p := range profiles {
+				if subsetOf(p, stored) {
+					return nil
+				}
+			}
+			return errors.New("stored profile does not match any submitted profile")
+		},
+	}
+}
+
+func expectState(c *client.Client, orderID, want string) error {
+	state, err := c.OrderState(orderID)
+	if err != nil {
+		return err
+	}
+	if state != want {
+		return fmt.Errorf("order %s is %s on %s, expected %s", orderID, state, c.BaseURL, want)
+	}
+	return nil
+}
+
+// subsetOf reports whether every top level string field in want has the same
+// value in got. The node fills in computed fields so a full comparison would
+// never match.
+func subsetOf(want, got map[string]interface{}) bool {
+	for k, v := range want {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if got[k] != s {
+			return false
+		}
+	}
+	return true
+}
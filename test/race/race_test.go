@@ -0,0 +1,150 @@
+package race
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+)
+
+// node is a fake API keeping order states. Strict, it moves an order out of
+// AWAITING_FULFILLMENT once, like a correct node; otherwise it accepts every
+// fulfillment.
+type node struct {
+	strict bool
+
+	lock    sync.Mutex
+	orders  map[string]string
+	profile map[string]interface{}
+}
+
+func newNode(strict bool) (*node, *client.Client, func()) {
+	n := &node{strict: strict, orders: make(map[string]string)}
+	srv := httptest.NewServer(n)
+	return n, client.New(srv.URL), srv.Close
+}
+
+// place adds an order awaiting fulfillment and returns its ID
+func (n *node) place() string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	id := fmt.Sprintf("order-%d", len(n.orders)+1)
+	n.orders[id] = "AWAITING_FULFILLMENT"
+	return id
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	id, _ := body["orderId"].(string)
+	move := func(to string) {
+		if n.orders[id] != "AWAITING_FULFILLMENT" && (n.strict || n.orders[id] != to) {
+			http.Error(w, `{"success": false}`, http.StatusBadRequest)
+			return
+		}
+		n.orders[id] = to
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/ob/orderfulfillment":
+		move("FULFILLED")
+	case r.Method == "POST" && r.URL.Path == "/ob/ordercancel":
+		move("CANCELED")
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/ob/order/"):
+		json.NewEncoder(w).Encode(map[string]string{"state": n.orders[strings.TrimPrefix(r.URL.Path, "/ob/order/")]})
+	case r.Method == "PUT" && r.URL.Path == "/ob/profile":
+		n.profile = body
+	case r.Method == "GET" && r.URL.Path == "/ob/profile":
+		json.NewEncoder(w).Encode(n.profile)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func fulfillment(orderID string) map[string]string {
+	return map[string]string{"orderId": orderID}
+}
+
+func TestRunDoubleFulfillment(t *testing.T) {
+	n, vendor, done := newNode(true)
+	defer done()
+	err := Run(3, func(int) (Conflict, error) {
+		id := n.place()
+		return DoubleFulfillment(vendor, id, fulfillment(id)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.orders) != 3 {
+		t.Errorf("Expected an order per round, got %d", len(n.orders))
+	}
+
+	// A fulfilled order accepts no more fulfillments, so a round reusing it
+	// has no winner
+	id := n.place()
+	err = Run(2, func(int) (Conflict, error) {
+		return DoubleFulfillment(vendor, id, fulfillment(id)), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "round 2") {
+		t.Errorf("Expected the second round on the same order to fail, got %v", err)
+	}
+}
+
+func TestDoubleFulfillmentAccepted(t *testing.T) {
+	n, vendor, done := newNode(false)
+	defer done()
+	id := n.place()
+	err := Run(1, func(int) (Conflict, error) {
+		return DoubleFulfillment(vendor, id, fulfillment(id)), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "2 did") {
+		t.Errorf("Expected both fulfillments accepted to fail the check, got %v", err)
+	}
+}
+
+func TestRunCancelVsFulfill(t *testing.T) {
+	n, c, done := newNode(true)
+	defer done()
+	err := Run(5, func(int) (Conflict, error) {
+		id := n.place()
+		return CancelVsFulfill(c, c, id, fulfillment(id)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, state := range n.orders {
+		if state != "FULFILLED" && state != "CANCELED" {
+			t.Errorf("Expected %s to settle, got %s", id, state)
+		}
+	}
+}
+
+func TestRunSetupError(t *testing.T) {
+	err := Run(2, func(round int) (Conflict, error) {
+		return Conflict{}, fmt.Errorf("no order")
+	})
+	if err == nil || err.Error() != "round 1: no order" {
+		t.Errorf("Expected the failed setup reported, got %v", err)
+	}
+}
+
+func TestProfileUpdates(t *testing.T) {
+	_, c, done := newNode(true)
+	defer done()
+	profiles := []map[string]interface{}{
+		{"name": "Alice", "handle": "@alice"},
+		{"name": "Bob", "handle": "@bob"},
+	}
+	err := Run(3, func(int) (Conflict, error) {
+		return ProfileUpdates(c, profiles...), nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
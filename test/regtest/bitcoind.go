@@ -0,0 +1,156 @@
+// Package regtest drives the bitcoind a regtest network of test nodes syncs
+// its wallets from
+package regtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// MaturityBlocks are mined on a fresh chain so the coinbase of the first
+// block is spendable
+const MaturityBlocks = 101
+
+// Bitcoind is a JSON-RPC client for bitcoind
+type Bitcoind struct {
+	URL      string
+	Username string
+	Password string
+	HTTP     *http.Client
+
+	// LegacyGenerate mines with generate, for daemons forked before
+	// generatetoaddress, like zcashd
+	LegacyGenerate bool
+
+	id int64
+}
+
+// New returns a client for the RPC server at url, e.g. http://127.0.0.1:18443
+func New(url, username, password string) *Bitcoind {
+	return &Bitcoind{
+		URL:      url,
+		Username: username,
+		Password: password,
+		HTTP:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Call invokes method and decodes its result into v, which may be nil
+func (b *Bitcoind) Call(method string, v interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      atomic.AddInt64(&b.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", b.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.Username != "" {
+		req.SetBasicAuth(b.Username, b.Password)
+	}
+	resp, err := b.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errors.New("bitcoind rejected the RPC credentials")
+	}
+	var ret struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return fmt.Errorf("%s: bitcoind returned %d: %s", method, resp.StatusCode, err)
+	}
+	if ret.Error != nil {
+		return fmt.Errorf("%s: %s (%d)", method, ret.Error.Message, ret.Error.Code)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(ret.Result, v)
+}
+
+// BlockCount returns the height of the chain
+func (b *Bitcoind) BlockCount() (int, error) {
+	var n int
+	err := b.Call("getblockcount", &n)
+	return n, err
+}
+
+// Wait polls bitcoind until it answers RPCs, e.g. right after its container
+// was started
+func (b *Bitcoind) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		_, err := b.BlockCount()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("bitcoind at %s not answering: %s", b.URL, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Generate mines n blocks to the bitcoind wallet
+func (b *Bitcoind) Generate(n int) error {
+	if b.LegacyGenerate {
+		return b.Call("generate", nil, n)
+	}
+	var addr string
+	if err := b.Call("getnewaddress", &addr); err != nil {
+		return err
+	}
+	return b.Call("generatetoaddress", nil, n, addr)
+}
+
+// Mature mines enough blocks on a fresh chain for the bitcoind wallet to
+// have coins to spend
+func (b *Bitcoind) Mature() error {
+	n, err := b.BlockCount()
+	if err != nil {
+		return err
+	}
+	if n >= MaturityBlocks {
+		return nil
+	}
+	return b.Generate(MaturityBlocks - n)
+}
+
+// Fund sends btc to address and mines a block to confirm it
+func (b *Bitcoind) Fund(address string, btc float64) error {
+	if err := b.Call("sendtoaddress", nil, address, btc); err != nil {
+		return err
+	}
+	return b.Generate(1)
+}
+
+// Send sends sat satoshis to address and mines a block to confirm it
+func (b *Bitcoind) Send(address string, sat int64) error {
+	return b.Fund(address, float64(sat)/1e8)
+}
@@ -0,0 +1,100 @@
+package regtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type call struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+func fakeBitcoind(height int, calls *[]call) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var c call
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*calls = append(*calls, c)
+		var result interface{}
+		switch c.Method {
+		case "getblockcount":
+			result = height
+		case "getnewaddress":
+			result = "mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M"
+		case "sendtoaddress":
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": -6, "message": "Insufficient funds"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+}
+
+func TestMature(t *testing.T) {
+	var calls []call
+	srv := fakeBitcoind(1, &calls)
+	defer srv.Close()
+
+	if err := New(srv.URL, "user", "pass").Mature(); err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, c := range calls {
+		methods = append(methods, c.Method)
+	}
+	if want := []string{"getblockcount", "getnewaddress", "generatetoaddress"}; !reflect.DeepEqual(methods, want) {
+		t.Fatalf("Expected calls %v, got %v", want, methods)
+	}
+	if n := calls[2].Params[0]; n != float64(MaturityBlocks-1) {
+		t.Errorf("Expected %d blocks to be mined, got %v", MaturityBlocks-1, n)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	var calls []call
+	srv := fakeBitcoind(200, &calls)
+	defer srv.Close()
+
+	err := New(srv.URL, "user", "pass").Fund("mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M", 1)
+	if err == nil || err.Error() != "sendtoaddress: Insufficient funds (-6)" {
+		t.Errorf("Expected the RPC error, got %v", err)
+	}
+	if err := New(srv.URL, "user", "wrong").Generate(1); err == nil {
+		t.Error("Expected bad credentials to fail")
+	}
+}
+
+func TestLegacyGenerate(t *testing.T) {
+	var calls []call
+	srv := fakeBitcoind(1, &calls)
+	defer srv.Close()
+
+	b := New(srv.URL, "user", "pass")
+	b.LegacyGenerate = true
+	if err := b.Generate(3); err != nil {
+		t.Fatal(err)
+	}
+	if want := []call{{Method: "generate", Params: []interface{}{float64(3)}}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected calls %+v, got %+v", want, calls)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var calls []call
+	srv := fakeBitcoind(200, &calls)
+	defer srv.Close()
+
+	New(srv.URL, "user", "pass").Send("mwmTnxPVNRx4ixTDQ5cpXiv5YyqgCFSX2M", 12345678)
+	if len(calls) == 0 || calls[0].Method != "sendtoaddress" || calls[0].Params[1] != 0.12345678 {
+		t.Errorf("Expected 0.12345678 BTC sent, got %+v", calls)
+	}
+}
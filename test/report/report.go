@@ -0,0 +1,158 @@
+// Package report renders the outcome of a harness run as a self contained
+// HTML page
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+	"github.com/OpenBazaar/openbazaar-go/test/topology"
+)
+
+// Report is everything shown on the page
+type Report struct {
+	Title     string
+	Started   time.Time
+	Duration  time.Duration
+	Scenarios []Scenario
+	Endpoints []client.EndpointLatency
+
+	// Implementations are the server implementations the nodes ran
+	Implementations []string
+
+	// Manifest is where the run came from, it may be nil
+	Manifest *manifest.Manifest
+}
+
+// Scenario is the outcome of one scenario
+type Scenario struct {
+	Name     string
+	Version  int
+	Duration time.Duration
+	Err      string
+
+	// Skipped says why the scenario did not run
+	Skipped string
+
+	// Features are the experimental node features active during the run
+	Features []string
+
+	// Topology is the network observed during the scenario
+	Topology []*topology.Snapshot
+}
+
+// Failed returns how many scenarios failed
+func (r *Report) Failed() int {
+	n := 0
+	for _, s := range r.Scenarios {
+		if s.Err != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Skipped returns how many scenarios did not run
+func (r *Report) Skipped() int {
+	n := 0
+	for _, s := range r.Scenarios {
+		if s.Skipped != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteHTML renders the report
+func (r *Report) WriteHTML(w io.Writer) error {
+	return page.Execute(w, r)
+}
+
+// bucketLabels names the histogram columns
+func bucketLabels() []string {
+	var labels []string
+	for _, b := range client.LatencyBuckets {
+		labels = append(labels, "≤"+b.String())
+	}
+	last := client.LatencyBuckets[len(client.LatencyBuckets)-1]
+	return append(labels, ">"+last.String())
+}
+
+// barWidth scales a bucket count to a percentage of the endpoint's requests
+func barWidth(count, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(count)*100/float64(total))
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
+
+// svg inlines a topology drawing, which escapes its own labels
+func svg(s *topology.Snapshot) template.HTML {
+	return template.HTML(s.SVG())
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"buckets": bucketLabels,
+	"width":   barWidth,
+	"round":   round,
+	"svg":     svg,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 13px; }
+.fail { color: #b00; }
+.ok { color: #070; }
+.skip { color: #888; }
+.cell { position: relative; min-width: 50px; }
+.bar { position: absolute; left: 0; top: 0; bottom: 0; background: #cde; z-index: -1; }
+.topology { display: inline-block; vertical-align: top; margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Started {{.Started.Format "2006-01-02 15:04:05 MST"}}, took {{round .Duration}}. {{len .Scenarios}} scenarios, {{.Failed}} failed, {{.Skipped}} skipped.{{if .Implementations}} Implementations: {{range $i, $n := .Implementations}}{{if $i}}, {{end}}{{$n}}{{end}}.{{end}}</p>
+{{with .Manifest}}<details><summary>{{.Stamp}}</summary><pre>{{.JSON}}</pre></details>{{end}}
+
+<h2>Scenarios</h2>
+<table>
+<tr><th>Scenario</th><th>Version</th><th>Duration</th><th>Features</th><th>Result</th></tr>
+{{range .Scenarios}}<tr>
+<td>{{.Name}}</td><td>{{.Version}}</td><td>{{round .Duration}}</td><td>{{range $i, $f := .Features}}{{if $i}}, {{end}}{{$f}}{{end}}</td>
+<td>{{if .Err}}<span class="fail">{{.Err}}</span>{{else if .Skipped}}<span class="skip">skipped: {{.Skipped}}</span>{{else}}<span class="ok">ok</span>{{end}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Topology</h2>
+{{range .Scenarios}}{{if .Topology}}<details{{if .Err}} open{{end}}>
+<summary>{{.Name}}{{if .Err}} <span class="fail">failed</span>{{end}}</summary>
+{{range .Topology}}<div class="topology">
+<p>{{.Label}}: {{len .Vertices}} nodes, {{len .Edges}} connections, {{len .Components}} partition(s)</p>
+{{svg .}}
+<details><summary>DOT</summary><pre>{{.DOT}}</pre></details>
+</div>{{end}}
+</details>
+{{end}}{{end}}
+<h2>API response times</h2>
+<table>
+<tr><th>Endpoint</th><th>Requests</th><th>p50</th><th>p95</th><th>p99</th><th>Max</th>{{range buckets}}<th>{{.}}</th>{{end}}</tr>
+{{range .Endpoints}}{{$total := .Count}}<tr>
+<td>{{.Endpoint}}</td><td>{{.Count}}</td><td>{{round .P50}}</td><td>{{round .P95}}</td><td>{{round .P99}}</td><td>{{round .Max}}</td>
+{{range .Buckets}}<td class="cell"><div class="bar" style="width: {{width . $total}}"></div>{{.}}</td>{{end}}
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/manifest"
+	"github.com/OpenBazaar/openbazaar-go/test/topology"
+)
+
+func TestWriteHTML(t *testing.T) {
+	lat := client.NewLatencies()
+	lat.Observe("GET", "/ob/listings/QmQBKFBLDuvz3NZNeSPqWCGRYH2wjxT7BQEE6CWdRkBEBf", 40*time.Millisecond)
+	lat.Observe("GET", "/ob/listings/QmdxZ3kVhqUQsHFjfnnk6Mm9gE8eUKqaT8aBAxrWfSk6MD", 3*time.Second)
+	r := &Report{
+		Title:   "nightly <run>",
+		Started: time.Now(),
+		Scenarios: []Scenario{
+			{Name: "regression/stuck-awaiting-payment", Version: 1, Duration: time.Minute},
+			{Name: "regression/lost-chat-on-restart", Version: 1, Duration: time.Second, Err: "chat message missing", Features: []string{"fast-sync", "new-chat"}, Topology: []*topology.Snapshot{
+				topology.Build("after lost-chat-on-restart", []topology.Peers{{Vertex: topology.Vertex{Name: "vendor-1", Role: "vendor", PeerID: "QmVendor"}}}),
+			}},
+			{Name: "regression/dispute-timeout", Version: 1, Skipped: "vendor-1 (other-daemon) lacks disputes"},
+		},
+		Endpoints:       lat.Endpoints(),
+		Implementations: []string{"openbazaar-go", "other-daemon"},
+		Manifest:        manifest.New("nightly", "docker"),
+	}
+	if r.Failed() != 1 {
+		t.Errorf("Expected 1 failed scenario, got %d", r.Failed())
+	}
+	if r.Skipped() != 1 {
+		t.Errorf("Expected 1 skipped scenario, got %d", r.Skipped())
+	}
+	var b bytes.Buffer
+	if err := r.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"nightly &lt;run&gt;", "GET /ob/listings/:id", "chat message missing", "fast-sync, new-chat", "width: 50.0%", "<svg", "1 partition(s)", "skipped: vendor-1 (other-daemon) lacks disputes", "openbazaar-go, other-daemon", "run nightly, harness unknown, docker nodes", `&#34;driver&#34;: &#34;docker&#34;`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package races registers scenarios firing conflicting API mutations at the
+// same instant, round after round, and checking the nodes settle on a
+// consistent state. See package race for the conflicts themselves.
+package races
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/race"
+)
+
+// Rounds is how many times each scenario fires its conflict
+var Rounds = 5
+
+// settleTimeout bounds how long an order may take to be funded on both sides
+const settleTimeout = 2 * time.Minute
+
+func init() {
+	harness.Register(DoubleFulfillment)
+	harness.Register(CancelVsFulfill)
+	harness.Register(ProfileUpdates)
+}
+
+// DoubleFulfillment has the vendor send the same fulfillment of a funded
+// order twice at once
+var DoubleFulfillment = harness.Scenario{
+	Name:        "races/double-fulfillment",
+	Description: "the same fulfillment sent twice at once must be accepted once",
+	Version:     1,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendor, buyer, err := harness.VendorAndBuyer(net)
+		if err != nil {
+			return err
+		}
+		return race.Run(Rounds, func(int) (race.Conflict, error) {
+			order, err := funded(ctx, vendor, buyer)
+			if err != nil {
+				return race.Conflict{}, err
+			}
+			return race.DoubleFulfillment(vendor.Client(), order.ID, fixtures.Fulfillment(order.ID, order.Slug)), nil
+		})
+	},
+}
+
+// CancelVsFulfill has the buyer cancel a funded order while the vendor
+// fulfills it
+var CancelVsFulfill = harness.Scenario{
+	Name:        "races/cancel-vs-fulfill",
+	Description: "a cancel racing a fulfillment must leave buyer and vendor agreeing on the winner",
+	Version:     1,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendor, buyer, err := harness.VendorAndBuyer(net)
+		if err != nil {
+			return err
+		}
+		return race.Run(Rounds, func(int) (race.Conflict, error) {
+			order, err := funded(ctx, vendor, buyer)
+			if err != nil {
+				return race.Conflict{}, err
+			}
+			return race.CancelVsFulfill(buyer.Client(), vendor.Client(), order.ID, fixtures.Fulfillment(order.ID, order.Slug)), nil
+		})
+	},
+}
+
+// ProfileUpdates has the vendor replace its profile with two different ones
+// at once
+var ProfileUpdates = harness.Scenario{
+	Name:        "races/profile-updates",
+	Description: "simultaneous profile updates must store one of them whole",
+	Version:     1,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendors := net.Role("vendor")
+		if len(vendors) == 0 {
+			return fmt.Errorf("scenario needs a vendor")
+		}
+		c := vendors[0].Client()
+		if _, err := c.Profile("", false); err != nil {
+			if err := c.CreateProfile(profile("initial", 0)); err != nil {
+				return err
+			}
+		}
+		return race.Run(Rounds, func(round int) (race.Conflict, error) {
+			return race.ProfileUpdates(c, profile("first", round), profile("second", round)), nil
+		})
+	},
+}
+
+func funded(ctx context.Context, vendor, buyer harness.Node) (*harness.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, settleTimeout)
+	defer cancel()
+	return harness.Checkout(ctx, vendor, buyer)
+}
+
+// profile returns a profile whose every field names it, so one mixing the
+// fields of two is told apart
+func profile(name string, round int) map[string]interface{} {
+	tag := fmt.Sprintf("%s %d", name, round)
+	return map[string]interface{}{
+		"name":             tag,
+		"about":            "about " + tag,
+		"location":         "location " + tag,
+		"shortDescription": "short " + tag,
+	}
+}
@@ -0,0 +1,31 @@
+package races
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/race"
+	"github.com/OpenBazaar/openbazaar-go/test/sim"
+)
+
+func TestInProcess(t *testing.T) {
+	race.RequireDetector(t)
+	for _, s := range []harness.Scenario{DoubleFulfillment, CancelVsFulfill, ProfileUpdates} {
+		t.Run(s.Name, func(t *testing.T) {
+			net, err := sim.New(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer net.Close()
+			for _, role := range []string{"vendor", "buyer"} {
+				if _, err := net.Add(role, role); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Run(context.Background(), net.Harness()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Package regression registers scenarios reproducing bugs that shipped in a
+// release. Each one must keep passing once the bug is fixed; change a
+// scenario only together with its Version.
+package regression
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/race"
+)
+
+// settleTimeout bounds how long an order may take to reach the next state
+const settleTimeout = 2 * time.Minute
+
+func init() {
+	harness.Register(StuckAwaitingPayment)
+	harness.Register(DuplicateDisputePayout)
+	harness.Register(LostChatOnRestart)
+}
+
+// StuckAwaitingPayment pays a direct order while the vendor restarts. The
+// vendor used to miss the payment and leave the sale in AWAITING_PAYMENT
+// forever.
+var StuckAwaitingPayment = harness.Scenario{
+	Name:        "regression/stuck-awaiting-payment",
+	Description: "a payment made while the vendor restarts must move the order to AWAITING_FULFILLMENT",
+	Version:     2,
+	Restarts:    true,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendor, buyer, err := harness.VendorAndBuyer(net)
+		if err != nil {
+			return err
+		}
+		restarter, ok := vendor.(harness.Restarter)
+		if !ok {
+			return fmt.Errorf("node %s cannot be restarted", vendor.Name())
+		}
+		order, err := harness.PlaceOrder(vendor, buyer, nil)
+		if err != nil {
+			return err
+		}
+		if err := harness.WaitState(ctx, order.ID, "AWAITING_PAYMENT", buyer, vendor); err != nil {
+			return err
+		}
+		if err := harness.PayOrder(buyer, order); err != nil {
+			return err
+		}
+		if err := restarter.Restart(ctx); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, settleTimeout)
+		defer cancel()
+		return harness.WaitState(ctx, order.ID, "AWAITING_FULFILLMENT", buyer, vendor)
+	},
+}
+
+// DuplicateDisputePayout has the moderator close the same dispute twice at
+// once and the buyer release the funds twice at once. Both requests used to
+// be accepted, paying the dispute out twice.
+var DuplicateDisputePayout = harness.Scenario{
+	Name:        "regression/duplicate-dispute-payout",
+	Description: "concurrent dispute resolutions and releases must pay out exactly once",
+	Version:     1,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendor, buyer, err := harness.VendorAndBuyer(net)
+		if err != nil {
+			return err
+		}
+		moderators := net.Role("moderator")
+		if len(moderators) == 0 {
+			return fmt.Errorf("scenario needs a moderator")
+		}
+		moderator := moderators[0]
+
+		order, err := harness.PlaceOrder(vendor, buyer, moderator)
+		if err != nil {
+			return err
+		}
+		if err := harness.PayOrder(buyer, order); err != nil {
+			return err
+		}
+		settle, cancel := context.WithTimeout(ctx, settleTimeout)
+		defer cancel()
+		if err := harness.WaitState(settle, order.ID, "AWAITING_FULFILLMENT", buyer, vendor); err != nil {
+			return err
+		}
+		if err := buyer.Client().OpenDispute(order.ID, "never arrived"); err != nil {
+			return err
+		}
+		if err := harness.WaitState(settle, order.ID, "DISPUTED", buyer, vendor); err != nil {
+			return err
+		}
+		if err := moderator.Client().WaitCaseState(settle, order.ID, "DISPUTED"); err != nil {
+			return err
+		}
+
+		resolution := client.CloseDisputeRequest(order.ID, "split", 50, 50)
+		if err := exactlyOnce(moderator.Client(), "/ob/closedispute", resolution); err != nil {
+			return fmt.Errorf("closing dispute: %s", err)
+		}
+		if err := harness.WaitState(settle, order.ID, "DECIDED", buyer, vendor); err != nil {
+			return err
+		}
+		release := map[string]string{"OrderID": order.ID}
+		if err := exactlyOnce(buyer.Client(), "/ob/releasefunds", release); err != nil {
+			return fmt.Errorf("releasing funds: %s", err)
+		}
+		return harness.WaitState(settle, order.ID, "RESOLVED", buyer, vendor)
+	},
+}
+
+// LostChatOnRestart sends a message in each direction and restarts both
+// parties. Messages that had not been read yet used to disappear from the
+// recipient's conversation after a restart.
+var LostChatOnRestart = harness.Scenario{
+	Name:        "regression/lost-chat-on-restart",
+	Description: "chat messages must survive a restart of sender and recipient",
+	Version:     1,
+	Restarts:    true,
+	Run: func(ctx context.Context, net *harness.Network) error {
+		vendor, buyer, err := harness.VendorAndBuyer(net)
+		if err != nil {
+			return err
+		}
+		rv, ok := vendor.(harness.Restarter)
+		if !ok {
+			return fmt.Errorf("node %s cannot be restarted", vendor.Name())
+		}
+		rb, ok := buyer.(harness.Restarter)
+		if !ok {
+			return fmt.Errorf("node %s cannot be restarted", buyer.Name())
+		}
+
+		toVendor, err := buyer.Client().SendChat(vendor.PeerID(), "", "is this still available?")
+		if err != nil {
+			return err
+		}
+		toBuyer, err := vendor.Client().SendChat(buyer.PeerID(), "", "yes it is")
+		if err != nil {
+			return err
+		}
+		settle, cancel := context.WithTimeout(ctx, settleTimeout)
+		defer cancel()
+		if err := waitMessage(settle, vendor, buyer.PeerID(), toVendor); err != nil {
+			return err
+		}
+		if err := waitMessage(settle, buyer, vendor.PeerID(), toBuyer); err != nil {
+			return err
+		}
+
+		if err := rv.Restart(ctx); err != nil {
+			return err
+		}
+		if err := rb.Restart(ctx); err != nil {
+			return err
+		}
+		for _, c := range []struct {
+			node    harness.Node
+			peer    string
+			message string
+		}{
+			{vendor, buyer.PeerID(), toVendor},
+			{vendor, buyer.PeerID(), toBuyer},
+			{buyer, vendor.PeerID(), toVendor},
+			{buyer, vendor.PeerID(), toBuyer},
+		} {
+			found, err := hasMessage(c.node, c.peer, c.message)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("message %s lost by %s after restart", c.message, c.node.Name())
+			}
+		}
+		return nil
+	},
+}
+
+// exactlyOnce posts the same body twice at once and returns an error unless
+// exactly one request was accepted
+func exactlyOnce(c *client.Client, path string, body interface{}) error {
+	_, err := race.ExactlyOneWins(race.Fire(race.Conflict{
+		Name: path,
+		Mutations: []race.Mutation{
+			{Name: "first", Client: c, Method: "POST", Path: path, Body: body},
+			{Name: "second", Client: c, Method: "POST", Path: path, Body: body},
+		},
+	}))
+	return err
+}
+
+func waitMessage(ctx context.Context, n harness.Node, peerID, messageID string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		found, err := hasMessage(n, peerID, messageID)
+		if err == nil && found {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("message %s never reached %s", messageID, n.Name())
+		case <-ticker.C:
+		}
+	}
+}
+
+func hasMessage(n harness.Node, peerID, messageID string) (bool, error) {
+	messages, err := n.Client().ChatMessages(peerID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range messages {
+		if m.MessageID == messageID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,304 @@
+// Package schema checks live API responses against the committed OpenAPI
+// description of the node API and reports drift: fields the description does
+// not type, required fields that vanished and values of the wrong type.
+//
+// Only the subset of OpenAPI 3 used by openapi.json is understood: paths with
+// {parameters}, per status or default responses with an application/json
+// schema, and schemas made of type, nullable, properties, required, items,
+// additionalProperties and local $refs.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Schema is a JSON schema as written in openapi.json
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Nullable   bool               `json:"nullable"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+
+	// AdditionalProperties is either true, allowing any extra field, or
+	// the schema every extra field must match
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type response struct {
+	Content map[string]mediaType `json:"content"`
+}
+
+type operation struct {
+	Responses map[string]response `json:"responses"`
+}
+
+// Spec is a parsed API description
+type Spec struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Load reads an API description from a file
+func Load(path string) (*Spec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse decodes an API description
+func Parse(b []byte) (*Spec, error) {
+	spec := new(Spec)
+	if err := json.Unmarshal(b, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Lookup returns the response schema for a request, the route it matched
+// and whether the description covers it at all
+func (s *Spec) Lookup(method, path string, status int) (*Schema, string, bool) {
+	for route, ops := range s.Paths {
+		if !matchRoute(route, path) {
+			continue
+		}
+		op, ok := ops[strings.ToLower(method)]
+		if !ok {
+			return nil, route, false
+		}
+		resp, ok := op.Responses[strconv.Itoa(status)]
+		if !ok {
+			resp, ok = op.Responses["default"]
+		}
+		if !ok {
+			return nil, route, false
+		}
+		mt, ok := resp.Content["application/json"]
+		return mt.Schema, route, ok && mt.Schema != nil
+	}
+	return nil, "", false
+}
+
+// matchRoute reports whether path matches a route like /ob/profile/{peerId}
+func matchRoute(route, path string) bool {
+	r := strings.Split(strings.Trim(route, "/"), "/")
+	p := strings.Split(strings.Trim(path, "/"), "/")
+	if len(r) != len(p) {
+		return false
+	}
+	for n := range r {
+		if strings.HasPrefix(r[n], "{") && strings.HasSuffix(r[n], "}") {
+			if p[n] == "" {
+				return false
+			}
+			continue
+		}
+		if r[n] != p[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// Violation is a difference between a response and its description
+type Violation struct {
+	// Endpoint is the method and route, e.g. GET /ob/profile/{peerId}
+	Endpoint string
+	Status   int
+
+	// Field is the JSON path of the offending value, e.g. $.items[0].title
+	Field   string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %d %s: %s", v.Endpoint, v.Status, v.Field, v.Message)
+}
+
+// Validate checks a decoded JSON value against a schema
+func (s *Spec) Validate(v interface{}, schema *Schema) []Violation {
+	var ret []Violation
+	s.validate(v, schema, "$", &ret)
+	return ret
+}
+
+func (s *Spec) resolve(schema *Schema) (*Schema, error) {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		next, ok := s.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %s", schema.Ref)
+		}
+		schema = next
+	}
+	return schema, nil
+}
+
+func (s *Spec) validate(v interface{}, schema *Schema, field string, ret *[]Violation) {
+	fail := func(format string, args ...interface{}) {
+		*ret = append(*ret, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	schema, err := s.resolve(schema)
+	if err != nil {
+		fail("%s", err)
+		return
+	}
+	if v == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("null, expected %s", schema.Type)
+		}
+		return
+	}
+	switch schema.Type {
+	case "":
+		return
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("%s, expected object", typeOf(v))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				*ret = append(*ret, Violation{Field: field + "." + name, Message: "required field missing"})
+			}
+		}
+		var extra *Schema
+		freeForm := false
+		if len(schema.AdditionalProperties) > 0 {
+			if string(schema.AdditionalProperties) == "true" {
+				freeForm = true
+			} else if string(schema.AdditionalProperties) != "false" {
+				extra = new(Schema)
+				if err := json.Unmarshal(schema.AdditionalProperties, extra); err != nil {
+					fail("invalid additionalProperties: %s", err)
+					return
+				}
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			switch {
+			case ok:
+				s.validate(obj[name], prop, field+"."+name, ret)
+			case extra != nil:
+				s.validate(obj[name], extra, field+"."+name, ret)
+			case !freeForm:
+				*ret = append(*ret, Violation{Field: field + "." + name, Message: fmt.Sprintf("untyped field (%s)", typeOf(obj[name]))})
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			fail("%s, expected array", typeOf(v))
+			return
+		}
+		if schema.Items == nil {
+			return
+		}
+		for n, item := range arr {
+			s.validate(item, schema.Items, fmt.Sprintf("%s[%d]", field, n), ret)
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != float64(int64(f)) {
+			fail("%s, expected integer", typeOf(v))
+		}
+	default:
+		if t := typeOf(v); t != schema.Type && !(schema.Type == "number" && t == "integer") {
+			fail("%s, expected %s", t, schema.Type)
+		}
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if x == float64(int64(x)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Checker collects the violations of every response shown to it
+type Checker struct {
+	spec *Spec
+
+	lock       sync.Mutex
+	violations []Violation
+	seen       map[string]bool
+}
+
+// NewChecker returns a checker validating against spec
+func NewChecker(spec *Spec) *Checker {
+	return &Checker{spec: spec, seen: make(map[string]bool)}
+}
+
+// Observe validates a response. Endpoints and statuses the description does
+// not cover, and bodies that are not JSON, are ignored.
+func (c *Checker) Observe(method, path string, status int, body []byte) {
+	schema, route, ok := c.spec.Lookup(method, path, status)
+	if !ok {
+		return
+	}
+	endpoint := strings.ToUpper(method) + " " + route
+	var v interface{}
+	var violations []Violation
+	if err := json.Unmarshal(body, &v); err != nil {
+		violations = []Violation{{Field: "$", Message: "invalid JSON: " + err.Error()}}
+	} else {
+		violations = c.spec.Validate(v, schema)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, viol := range violations {
+		viol.Endpoint, viol.Status = endpoint, status
+		if key := viol.String(); !c.seen[key] {
+			c.seen[key] = true
+			c.violations = append(c.violations, viol)
+		}
+	}
+}
+
+// Take returns the violations found since the last call and forgets them
+func (c *Checker) Take() []Violation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.violations
+	c.violations = nil
+	c.seen = make(map[string]bool)
+	return ret
+}
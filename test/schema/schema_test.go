@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	spec, err := Load("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestRefsResolve(t *testing.T) {
+	spec := loadSpec(t)
+	for route, ops := range spec.Paths {
+		for method, op := range ops {
+			for status, resp := range op.Responses {
+				for _, mt := range resp.Content {
+					if _, err := spec.resolve(mt.Schema); err != nil {
+						t.Errorf("%s %s %s: %s", method, route, status, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	for _, c := range []struct {
+		method, path string
+		status       int
+		body         string
+		want         []string
+	}{
+		{"GET", "/ob/config", 200, `{"peerID": "QmX", "cryptoCurrency": "TBTC", "testnet": true, "tor": false, "features": null}`, nil},
+		{"GET", "/ob/config", 200, `{"peerID": "QmX", "cryptoCurrency": "TBTC", "testnet": true, "tor": false, "features": [], "fiat": "USD"}`,
+			[]string{"GET /ob/config 200 $.fiat: untyped field (string)"}},
+		{"GET", "/ob/config", 200, `{"peerID": "QmX", "cryptoCurrency": "TBTC", "testnet": true}`,
+			[]string{"GET /ob/config 200 $.tor: required field missing"}},
+		{"GET", "/wallet/balance", 200, `{"confirmed": "100", "unconfirmed": 0.5}`,
+			[]string{"GET /wallet/balance 200 $.confirmed: string, expected integer", "GET /wallet/balance 200 $.unconfirmed: number, expected integer"}},
+		{"GET", "/ob/listings/QmX", 200, `[{"hash": "zb2", "slug": "shirt", "title": "Shirt", "price": {"currencyCode": "BTC", "amount": 12}, "shipsTo": null, "rating": 5}]`,
+			[]string{"GET /ob/listings/{peerId} 200 $[0].rating: untyped field (integer)"}},
+		{"GET", "/ob/notifications", 200, `{"unread": 1, "total": 1, "notifications": [{"notification": {"type": "order", "buyerId": "QmX"}, "timestamp": "2017-01-01T00:00:00Z", "read": false}]}`, nil},
+		{"POST", "/ob/purchase", 400, `{"success": false, "reason": "listing not found"}`, nil},
+		{"POST", "/ob/purchase", 500, `not json`, []string{"POST /ob/purchase 500 $: invalid JSON: invalid character 'o' in literal null (expecting 'u')"}},
+		{"GET", "/ob/images/zb2", 200, `binary`, nil},
+		{"DELETE", "/ob/config", 200, `{}`, nil},
+	} {
+		checker := NewChecker(loadSpec(t))
+		checker.Observe(c.method, c.path, c.status, []byte(c.body))
+		checker.Observe(c.method, c.path, c.status, []byte(c.body))
+		var got []string
+		for _, v := range checker.Take() {
+			got = append(got, v.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s %s %s:\nexpected %q\ngot      %q", c.method, c.path, c.body, c.want, got)
+		}
+		if len(checker.Take()) != 0 {
+			t.Error("Expected Take to forget the violations")
+		}
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	for _, c := range []struct {
+		route, path string
+		want        bool
+	}{
+		{"/ob/listings", "/ob/listings", true},
+		{"/ob/listings", "/ob/listings/", true},
+		{"/ob/listings/{peerId}", "/ob/listings/QmX", true},
+		{"/ob/listings/{peerId}", "/ob/listings", false},
+		{"/ob/listings/{peerId}", "/ob/listing/QmX", false},
+	} {
+		if got := matchRoute(c.route, c.path); got != c.want {
+			t.Errorf("matchRoute(%s, %s) = %v, expected %v", c.route, c.path, got, c.want)
+		}
+	}
+}
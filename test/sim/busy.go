@@ -0,0 +1,104 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/dbfault"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+)
+
+// busySettle bounds how long an order may take to reach a state with its
+// writes failing first
+const busySettle = 30 * time.Second
+
+// busyTimes is how many times in a row each order write fails, fewer than
+// the node tries it
+const busyTimes = 2
+
+// orderWrites are the statements writing an order to the sales and
+// purchases tables, and whether they fail locked rather than busy
+var orderWrites = []struct {
+	match  string
+	locked bool
+}{
+	{"insert or replace into sales", false},
+	{"insert or replace into purchases", true},
+	{"update sales set funded", true},
+	{"update purchases set funded", false},
+}
+
+// OrderBusy places a direct order and pays it while every write of the
+// order to the sales and purchases tables first fails busy or locked. Each
+// write must be tried again rather than dropped, leaving the order
+// AWAITING_FULFILLMENT on both sides.
+//
+// The nodes must be added after injector is installed, so their databases
+// are opened through it.
+func OrderBusy(injector *dbfault.Injector) harness.Scenario {
+	return harness.Scenario{
+		Name:        "order-busy-database",
+		Description: "order updates failing on a busy or locked database are retried rather than dropped",
+		Version:     1,
+		Run: func(ctx context.Context, net *harness.Network) error {
+			vendors, buyers := net.Role("vendor"), net.Role("buyer")
+			if len(vendors) == 0 || len(buyers) == 0 {
+				return fmt.Errorf("scenario needs a vendor and a buyer")
+			}
+			vendor, ok := vendors[0].(*Node)
+			buyer, ok2 := buyers[0].(*Node)
+			if !ok || !ok2 {
+				return fmt.Errorf("scenario needs simulated nodes")
+			}
+			hash, err := publish(vendor)
+			if err != nil {
+				return err
+			}
+			defer injector.Reset()
+			injector.Reset()
+			for _, w := range orderWrites {
+				if w.locked {
+					injector.Locked(w.match, busyTimes)
+				} else {
+					injector.Busy(w.match, busyTimes)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, busySettle)
+			defer cancel()
+			var orderID string
+			err = net.Step("busy/purchase", func() error {
+				resp, err := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+				if err != nil {
+					return err
+				}
+				orderID = resp.OrderID
+				if err := vendor.Client().WaitOrderState(ctx, orderID, "AWAITING_PAYMENT"); err != nil {
+					return err
+				}
+				if err := buyer.Client().WaitOrderState(ctx, orderID, "AWAITING_PAYMENT"); err != nil {
+					return err
+				}
+				return buyer.Pay(resp.PaymentAddress, resp.Amount)
+			})
+			if err != nil {
+				return err
+			}
+			return net.Step("busy/funded", func() error {
+				for _, n := range []*Node{vendor, buyer} {
+					if err := n.Client().WaitOrderState(ctx, orderID, "AWAITING_FULFILLMENT"); err != nil {
+						return fmt.Errorf("%s: %s", n.Name(), err)
+					}
+				}
+				for _, w := range orderWrites {
+					if err := injector.CheckRetried(w.match); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+}
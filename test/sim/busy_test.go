@@ -0,0 +1,29 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/test/dbfault"
+)
+
+func TestOrderBusy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts two in-process nodes")
+	}
+	injector := dbfault.Install()
+	defer injector.Uninstall()
+	net, err := New(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer net.Close()
+	for _, role := range []string{"vendor", "buyer"} {
+		if _, err := net.Add(role, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := OrderBusy(injector).Run(context.Background(), net.Harness()); err != nil {
+		t.Error(err)
+	}
+}
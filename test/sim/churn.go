@@ -0,0 +1,171 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	cid "gx/ipfs/QmYhQaCYEcaPPjxJX7YcPcVKkQfRy6sJ7B3XmGFk82XYdQ/go-cid"
+)
+
+// lookupTimeout bounds one provider lookup of an observer
+const lookupTimeout = 5 * time.Second
+
+// ChurnOptions configures the provider churn scenario
+type ChurnOptions struct {
+	// TTL is the provider record lifetime the network was set up with, see
+	// ProviderTTL. It should be well over a minute plus the network's
+	// Reprovide, the first reprovide coming a minute after a node starts.
+	TTL time.Duration
+
+	// Watch is how long the observers keep looking the content up, a
+	// CleanupInterval and 2 TTLs when zero, so every DHT dropped the
+	// records the vendor first provided
+	Watch time.Duration
+
+	// MaxGap is the longest any observer may go without finding the vendor
+	// providing its content, none when zero
+	MaxGap time.Duration
+}
+
+// ProviderChurn publishes a listing on the vendor, which stays online, and
+// has every other node look up the providers of the listing through the DHT
+// every second while the provider records for it expire. The vendor's
+// reprovides must keep it findable: an observer not finding the vendor is a
+// discovery gap, recorded as discovery_gap_seconds and failing the scenario
+// when longer than MaxGap.
+//
+// ProviderTTL must be set to opts.TTL and the network built with a Reprovide
+// shorter than it, otherwise records outlive the scenario.
+func ProviderChurn(opts ChurnOptions) harness.Scenario {
+	return harness.Scenario{
+		Name:        "provider-churn",
+		Description: "an online vendor's reprovides keep its content discoverable while provider records expire",
+		Version:     2,
+		Run: func(ctx context.Context, net *harness.Network) error {
+			if opts.TTL <= 0 {
+				return fmt.Errorf("scenario needs the provider record TTL the network was built with")
+			}
+			watch := opts.Watch
+			if watch == 0 {
+				watch = CleanupInterval + 2*opts.TTL
+			}
+			vendors := net.Role("vendor")
+			if len(vendors) == 0 {
+				return fmt.Errorf("scenario needs a vendor")
+			}
+			vendor, ok := vendors[0].(*Node)
+			if !ok {
+				return fmt.Errorf("scenario needs simulated nodes")
+			}
+			var observers []*Node
+			for _, nd := range net.Nodes {
+				if o, ok := nd.(*Node); ok && o != vendor {
+					observers = append(observers, o)
+				}
+			}
+			if len(observers) == 0 {
+				return fmt.Errorf("scenario needs a node besides the vendor")
+			}
+			hash, err := publish(vendor)
+			if err != nil {
+				return err
+			}
+			c, err := cid.Decode(hash)
+			if err != nil {
+				return fmt.Errorf("listing hash %s: %s", hash, err)
+			}
+
+			err = net.Step("provide", func() error {
+				wait, cancel := context.WithTimeout(ctx, 30*time.Second)
+				defer cancel()
+				for _, o := range observers {
+					err := poll(wait, func() error {
+						if !o.finds(ctx, vendor, c) {
+							return fmt.Errorf("%s never found %s providing listing %s", o.Name(), vendor.Name(), hash)
+						}
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			gaps := make([]gap, len(observers))
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			deadline := time.After(watch)
+			for done := false; !done; {
+				for i, o := range observers {
+					gaps[i].sample(o.finds(ctx, vendor, c), func(d time.Duration) {
+						net.Metrics.Timing("discovery_gap_seconds", d, map[string]string{"observer": o.Name()})
+					})
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-deadline:
+					done = true
+				case <-ticker.C:
+				}
+			}
+
+			var wrong []string
+			for i, o := range observers {
+				gaps[i].sample(true, func(d time.Duration) {
+					net.Metrics.Timing("discovery_gap_seconds", d, map[string]string{"observer": o.Name(), "open": "true"})
+				})
+				if gaps[i].longest > opts.MaxGap {
+					wrong = append(wrong, fmt.Sprintf("%s for up to %s over %d gaps", o.Name(), gaps[i].longest, gaps[i].count))
+				}
+			}
+			if len(wrong) > 0 {
+				return fmt.Errorf("%s could not be found providing listing %s with records living %s: %s",
+					vendor.Name(), hash, opts.TTL, strings.Join(wrong, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// finds reports whether a DHT lookup by the node finds vendor providing c
+func (n *Node) finds(ctx context.Context, vendor *Node, c *cid.Cid) bool {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	for pi := range n.ob.IpfsNode.Routing.FindProvidersAsync(ctx, c, 20) {
+		if pi.ID == vendor.id() {
+			return true
+		}
+	}
+	return false
+}
+
+// gap tracks the stretches an observer could not find the content for
+type gap struct {
+	since   time.Time
+	longest time.Duration
+	count   int
+}
+
+// sample records one lookup, passing the length of a gap it ends to closed
+func (g *gap) sample(found bool, closed func(time.Duration)) {
+	switch {
+	case !found && g.since.IsZero():
+		g.since = time.Now()
+	case found && !g.since.IsZero():
+		d := time.Since(g.since)
+		g.since = time.Time{}
+		g.count++
+		if d > g.longest {
+			g.longest = d
+		}
+		closed(d)
+	}
+}
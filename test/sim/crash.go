@@ -0,0 +1,192 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/dbfault"
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+)
+
+// maxCrashPoints bounds the statements a node may run while persisting an
+// order before the scenario gives up on reaching the end of them
+const maxCrashPoints = 200
+
+// crashSettle bounds how long a purchase may take to be persisted on both
+// sides or to hit the crash point
+const crashSettle = 30 * time.Second
+
+// OrderCrash places a direct order again and again, killing the buyer's or
+// the vendor's database at each statement it runs in turn, until an order
+// goes through without reaching the crash point. After every crash the
+// node is restarted on the database as it was left and every order it lists
+// must be whole: its contract readable, in AWAITING_PAYMENT and with a
+// total. An order the node never persisted is fine, half of one is not.
+//
+// The nodes must be added after injector is installed, so their databases
+// are opened through it. Sim nodes run nothing in the background, so the
+// statements counted are those of the purchase.
+func OrderCrash(injector *dbfault.Injector) harness.Scenario {
+	return harness.Scenario{
+		Name:        "order-crash-consistency",
+		Description: "a node killed at any statement of persisting an order restarts with the order whole or absent",
+		Version:     1,
+		Run: func(ctx context.Context, net *harness.Network) error {
+			vendors, buyers := net.Role("vendor"), net.Role("buyer")
+			if len(vendors) == 0 || len(buyers) == 0 {
+				return fmt.Errorf("scenario needs a vendor and a buyer")
+			}
+			vendor, ok := vendors[0].(*Node)
+			buyer, ok2 := buyers[0].(*Node)
+			if !ok || !ok2 {
+				return fmt.Errorf("scenario needs simulated nodes")
+			}
+			hash, err := publish(vendor)
+			if err != nil {
+				return err
+			}
+			for _, side := range []struct {
+				node *Node
+				rows func(*client.Client) ([]client.Transaction, error)
+			}{
+				{buyer, (*client.Client).Purchases},
+				{vendor, (*client.Client).Sales},
+			} {
+				err := net.Step("crash/"+side.node.Name(), func() error {
+					return crashEverywhere(ctx, injector, vendor, buyer, hash, side.node, side.rows)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func publish(vendor *Node) (string, error) {
+	slug, err := vendor.Client().CreateListing(fixtures.Listing())
+	if err != nil {
+		return "", err
+	}
+	listings, err := vendor.Client().Listings("")
+	if err != nil {
+		return "", err
+	}
+	for _, l := range listings {
+		if l.Slug == slug {
+			return l.Hash, nil
+		}
+	}
+	return "", fmt.Errorf("listing %s missing from the index of %s", slug, vendor.Name())
+}
+
+// crashEverywhere crashes victim at every statement of a purchase in turn
+func crashEverywhere(ctx context.Context, injector *dbfault.Injector, vendor, buyer *Node, hash string, victim *Node, rows func(*client.Client) ([]client.Transaction, error)) error {
+	defer injector.Reset()
+	for point := 1; point <= maxCrashPoints; point++ {
+		before, err := orderIDs(victim, rows)
+		if err != nil {
+			return err
+		}
+		injector.Reset()
+		injector.Crash(victim.Repo(), "", point)
+		resp, purchaseErr := buyer.Client().Purchase(fixtures.DirectOrder(hash))
+
+		wait, cancel := context.WithTimeout(ctx, crashSettle)
+		err = poll(wait, func() error {
+			if injector.Crashed() {
+				return nil
+			}
+			if purchaseErr != nil {
+				return purchaseErr
+			}
+			for _, n := range []*Node{buyer, vendor} {
+				state, err := n.Client().OrderState(resp.OrderID)
+				if err != nil {
+					return err
+				}
+				if state != "AWAITING_PAYMENT" {
+					return fmt.Errorf("%s has order %s in %s", n.Name(), resp.OrderID, state)
+				}
+			}
+			return nil
+		})
+		cancel()
+		if !injector.Crashed() {
+			// The purchase ran to the end without reaching the point
+			if err != nil {
+				return fmt.Errorf("purchase with no crash: %s", err)
+			}
+			return nil
+		}
+
+		injector.Reset()
+		if err := victim.Restart(ctx); err != nil {
+			return fmt.Errorf("crash at statement %d: %s", point, err)
+		}
+		if err := checkWhole(victim, rows, before); err != nil {
+			return fmt.Errorf("crash at statement %d: %s", point, err)
+		}
+	}
+	return fmt.Errorf("%s still reached the crash point after %d statements", victim.Name(), maxCrashPoints)
+}
+
+func orderIDs(n *Node, rows func(*client.Client) ([]client.Transaction, error)) (map[string]bool, error) {
+	ts, err := rows(n.Client())
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, t := range ts {
+		ids[t.OrderID] = true
+	}
+	return ids, nil
+}
+
+// checkWhole checks the orders the node lists that it did not before
+func checkWhole(n *Node, rows func(*client.Client) ([]client.Transaction, error), before map[string]bool) error {
+	ts, err := rows(n.Client())
+	if err != nil {
+		return fmt.Errorf("%s cannot list its orders after restarting: %s", n.Name(), err)
+	}
+	for _, t := range ts {
+		if before[t.OrderID] {
+			continue
+		}
+		if t.State != "AWAITING_PAYMENT" || t.Total == 0 {
+			return fmt.Errorf("%s has order %s half written: state %q, total %d", n.Name(), t.OrderID, t.State, t.Total)
+		}
+		raw, err := n.Client().Order(t.OrderID)
+		if err != nil {
+			return fmt.Errorf("%s lists order %s but cannot load it: %s", n.Name(), t.OrderID, err)
+		}
+		var order struct {
+			Contract json.RawMessage `json:"contract"`
+		}
+		if err := json.Unmarshal(raw, &order); err != nil || len(order.Contract) == 0 || string(order.Contract) == "null" {
+			return fmt.Errorf("%s has order %s without a contract", n.Name(), t.OrderID)
+		}
+	}
+	return nil
+}
+
+func poll(ctx context.Context, fn func() error) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,456 @@
+// Package sim runs networks of in-process nodes on a virtual libp2p network.
+// Nodes talk over an in-memory mocknet, keep their IPFS blocks in memory and
+// serve their API over in-memory pipes, so a multi-node scenario needs no
+// socket and starts in a fraction of the time real processes take. Node
+// identities and wallets derive from the network seed, and fault schedules
+// play on a virtual clock that moves only when advanced.
+//
+// Simulation is meant for logic-level tests where real sockets add nothing
+// but flake. The nodes themselves still read the wall clock, and there is no
+// wallet backend, gateway, offline message retrieval or republishing: orders
+// are funded by telling the nodes of a payment with Pay.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/api"
+	lis "github.com/OpenBazaar/openbazaar-go/bitcoin/listeners"
+	"github.com/OpenBazaar/openbazaar-go/core"
+	"github.com/OpenBazaar/openbazaar-go/ipfs"
+	obnet "github.com/OpenBazaar/openbazaar-go/net"
+	"github.com/OpenBazaar/openbazaar-go/net/service"
+	"github.com/OpenBazaar/openbazaar-go/repo"
+	"github.com/OpenBazaar/openbazaar-go/repo/db"
+	"github.com/OpenBazaar/openbazaar-go/storage/selfhosted"
+	"github.com/OpenBazaar/openbazaar-go/test/client"
+	"github.com/OpenBazaar/openbazaar-go/test/datastore"
+	"github.com/OpenBazaar/openbazaar-go/test/harness"
+	"github.com/OpenBazaar/openbazaar-go/test/vclock"
+	"github.com/OpenBazaar/spvwallet"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	commands "github.com/ipfs/go-ipfs/commands"
+	ipfscore "github.com/ipfs/go-ipfs/core"
+	ipfsrepo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/routing/dht/providers"
+	"github.com/op/go-logging"
+	"github.com/tyler-smith/go-bip39"
+	mocknet "gx/ipfs/QmQA5mdxru8Bh6dpC9PJfSkumqnmHgJX7knxSgBo5Lpime/go-libp2p/p2p/net/mock"
+	peer "gx/ipfs/QmdS9KpbDyPrieswibZhkod1oXqRwZJrUPzxCofAMWpFGq/go-libp2p-peer"
+)
+
+// cookie authenticates nothing, the API of simulated nodes is open
+var cookie = http.Cookie{Name: "OpenBazaar_Auth_Cookie", Value: "sim"}
+
+// Network is a set of simulated nodes sharing a virtual network and clock
+type Network struct {
+	// Clock is the virtual clock fault schedules are played on
+	Clock *vclock.Clock
+
+	// Reprovide is how often nodes added from now on provide their blocks to
+	// the DHT again, go-ipfs's 12 hours when zero. The first reprovide comes
+	// a minute after a node starts.
+	Reprovide time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	mn     mocknet.Mocknet
+	dir    string
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	nodes []*Node
+}
+
+// New returns an empty network. Nodes added to networks with the same seed
+// get the same identities and wallets, in the order they are added.
+func New(ctx context.Context, seed int64) (*Network, error) {
+	dir, err := ioutil.TempDir("", "sim")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Network{
+		Clock:  vclock.New(vclock.Epoch),
+		ctx:    ctx,
+		cancel: cancel,
+		mn:     mocknet.New(ctx),
+		dir:    dir,
+		rand:   rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// CleanupInterval is how often the DHT of a node drops the provider records
+// it holds that expired, fixed by go-ipfs. A record outlives its TTL by up
+// to this much.
+const CleanupInterval = time.Hour
+
+// ProviderTTL makes the DHT of every in-process node keep the provider
+// records it is sent for ttl rather than a day, records already held
+// included, dropping them at its next cleanup once they expired. The
+// returned function restores the default.
+func ProviderTTL(ttl time.Duration) (restore func()) {
+	validity := providers.ProvideValidity
+	providers.ProvideValidity = ttl
+	return func() {
+		providers.ProvideValidity = validity
+	}
+}
+
+// Node is an OpenBazaar node running in-process on the virtual network
+type Node struct {
+	name     string
+	role     string
+	ob       *core.OpenBazaarNode
+	net      *Network
+	dir      string
+	mnemonic string
+	blocks   datastore.Datastore
+
+	listener *pipeListener
+	gateway  *api.Gateway
+	client   *client.Client
+	payments *lis.TransactionListener
+	txns     spvwallet.Txns
+}
+
+func (n *Node) Name() string           { return n.name }
+func (n *Node) Role() string           { return n.role }
+func (n *Node) PeerID() string         { return n.ob.IpfsNode.Identity.Pretty() }
+func (n *Node) Client() *client.Client { return n.client }
+
+// OpenBazaarNode returns the node itself, e.g. to wrap its network service
+func (n *Node) OpenBazaarNode() *core.OpenBazaarNode { return n.ob }
+
+func (n *Node) id() peer.ID { return n.ob.IpfsNode.Identity }
+
+// Repo returns the directory of the node's repo, its SQLite database
+// included
+func (n *Node) Repo() string { return n.dir }
+
+// Restart stops the node and starts it on its repo again, as a process
+// restarted after being killed would: the SQLite database is reopened from
+// disk, losing whatever was not committed. IPFS blocks are kept in memory
+// across the restart.
+func (n *Node) Restart(ctx context.Context) error {
+	s := n.net
+	for _, other := range s.Nodes() {
+		if other != n && len(s.mn.LinksBetweenPeers(n.id(), other.id())) > 0 {
+			s.mn.UnlinkPeers(n.id(), other.id())
+		}
+	}
+	n.stop()
+	restarted, err := s.start(n.name, n.role, n.dir, n.mnemonic, n.blocks, false)
+	if err != nil {
+		return fmt.Errorf("sim: restarting %s: %s", n.name, err)
+	}
+	*n = *restarted
+	for _, other := range s.Nodes() {
+		if other != n {
+			if err := s.link(n, other); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DelayTo sets the latency of the virtual link between the node and peer,
+// another node of the network, zero removing it. The link is shared, so
+// traffic both ways is delayed by d and a round trip takes 2d. Restarting
+// either node relinks it without latency.
+func (n *Node) DelayTo(ctx context.Context, peer harness.Node, d time.Duration) error {
+	p, ok := peer.(*Node)
+	if !ok || p.net != n.net {
+		return fmt.Errorf("sim: %s has no link to %s", n.name, peer.Name())
+	}
+	links := n.net.mn.LinksBetweenPeers(n.id(), p.id())
+	if len(links) == 0 {
+		return fmt.Errorf("sim: %s has no link to %s", n.name, p.name)
+	}
+	for _, l := range links {
+		o := l.Options()
+		o.Latency = d
+		l.SetOptions(o)
+	}
+	return nil
+}
+
+func (n *Node) stop() {
+	n.gateway.Close()
+	n.ob.IpfsNode.Close()
+	n.ob.Datastore.Close()
+}
+
+// Add starts a node, links it to every node of the network and connects it
+// to them
+func (s *Network) Add(name, role string) (*Node, error) {
+	s.lock.Lock()
+	entropy := make([]byte, 16)
+	s.rand.Read(entropy)
+	s.lock.Unlock()
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	blocks, err := datastore.Open(datastore.Memory, "")
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.start(name, role, dir, mnemonic, blocks, true)
+	if err != nil {
+		return nil, fmt.Errorf("sim: starting %s: %s", name, err)
+	}
+	for _, other := range s.Nodes() {
+		if err := s.link(n, other); err != nil {
+			return nil, err
+		}
+	}
+	s.lock.Lock()
+	s.nodes = append(s.nodes, n)
+	s.lock.Unlock()
+	return n, nil
+}
+
+// link links and connects two nodes unless they are linked already
+func (s *Network) link(a, b *Node) error {
+	if len(s.mn.LinksBetweenPeers(a.id(), b.id())) > 0 {
+		return nil
+	}
+	if _, err := s.mn.LinkPeers(a.id(), b.id()); err != nil {
+		return err
+	}
+	_, err := s.mn.ConnectPeers(a.id(), b.id())
+	return err
+}
+
+// start runs a node on the repo in dir, initializing the repo first when
+// fresh
+func (s *Network) start(name, role, dir, mnemonic string, blocks datastore.Datastore, fresh bool) (*Node, error) {
+	sqlite, err := db.Create(dir, "", true)
+	if err != nil {
+		return nil, err
+	}
+	if fresh {
+		err = repo.DoInit(dir, 4096, true, "", mnemonic, s.Clock.Now(), sqlite.Config().Init)
+		if err != nil {
+			return nil, err
+		}
+	}
+	identityKey, err := ipfs.IdentityKeyFromSeed(bip39.NewSeed(mnemonic, "Secret Passphrase"), 4096)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := ipfs.IdentityFromKey(identityKey)
+	if err != nil {
+		return nil, err
+	}
+	var reprovider config.Reprovider
+	if s.Reprovide > 0 {
+		reprovider.Interval = s.Reprovide.String()
+	}
+	ipfsNode, err := ipfscore.NewNode(s.ctx, &ipfscore.BuildCfg{
+		Repo: &ipfsrepo.Mock{
+			D: blocks,
+			C: config.Config{
+				Identity: identity,
+				// go-ipfs refuses to start without an address to listen
+				// on, the mocknet ignores it
+				Addresses:  config.Addresses{Swarm: []string{"/ip4/127.0.0.1/tcp/4001"}},
+				Discovery:  config.Discovery{MDNS: config.MDNS{Enabled: false}},
+				Reprovider: reprovider,
+			},
+		},
+		Online: true,
+		Host:   ipfs.MockHostOption(s.mn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	cmdCtx := commands.Context{
+		Online:     true,
+		ConfigRoot: dir,
+		LoadConfig: func(string) (*config.Config, error) {
+			return ipfsNode.Repo.Config()
+		},
+		ConstructNode: func() (*ipfscore.IpfsNode, error) {
+			return ipfsNode, nil
+		},
+	}
+	wallet, err := spvwallet.NewSPVWallet(&spvwallet.Config{
+		Mnemonic:  mnemonic,
+		Params:    &chaincfg.TestNet3Params,
+		MaxFee:    50000,
+		LowFee:    8000,
+		MediumFee: 16000,
+		HighFee:   24000,
+		RepoPath:  dir,
+		DB:        sqlite,
+		UserAgent: "OpenBazaar",
+		Logger:    logging.NewLogBackend(ioutil.Discard, "", 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ob := &core.OpenBazaarNode{
+		Context:        cmdCtx,
+		IpfsNode:       ipfsNode,
+		RepoPath:       dir,
+		Datastore:      sqlite,
+		Wallet:         wallet,
+		MessageStorage: selfhosted.NewSelfHostedStorage(dir, cmdCtx, nil, nil),
+		UserAgent:      "OpenBazaar",
+		BanManager:     obnet.NewBanManager([]peer.ID{}),
+	}
+	// The gateway sets up the notification channel the service hands its
+	// handlers, so it comes first as in openbazaard
+	l := newPipeListener(name)
+	gateway, err := api.NewGateway(ob, cookie, l, repo.APIConfig{Enabled: true}, logging.NewLogBackend(ioutil.Discard, "", 0))
+	if err != nil {
+		return nil, err
+	}
+	ob.Service = service.New(ob, cmdCtx, sqlite)
+	go gateway.Serve()
+	return &Node{
+		name:     name,
+		role:     role,
+		ob:       ob,
+		net:      s,
+		dir:      dir,
+		mnemonic: mnemonic,
+		blocks:   blocks,
+		listener: l,
+		gateway:  gateway,
+		client:   client.NewDialer("http://"+name, l.Dial),
+		payments: lis.NewTransactionListener(sqlite, ob.Broadcast, wallet),
+		txns:     sqlite.Txns(),
+	}, nil
+}
+
+// Nodes returns the nodes in the order they were added
+func (s *Network) Nodes() []*Node {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*Node(nil), s.nodes...)
+}
+
+// Harness returns the network for running scenarios on, with fault
+// schedules played on the virtual clock
+func (s *Network) Harness() *harness.Network {
+	net := &harness.Network{Clock: s.Clock}
+	for _, n := range s.Nodes() {
+		net.Nodes = append(net.Nodes, n)
+	}
+	return net
+}
+
+// Pay has the network pay amount to addr, as the buyer would from its wallet
+func (n *Node) Pay(addr string, amount uint64) error {
+	return n.net.Pay(addr, int64(amount))
+}
+
+// Pay tells every node of an unconfirmed transaction paying value to addr,
+// as their wallets would once it reached the network. The buyer and the
+// vendor of the order addr belongs to record the payment and move the order
+// on, the other nodes ignore it.
+func (s *Network) Pay(addr string, value int64) error {
+	var prev chainhash.Hash
+	s.lock.Lock()
+	s.rand.Read(prev[:])
+	s.lock.Unlock()
+	for _, n := range s.Nodes() {
+		a, err := n.ob.Wallet.DecodeAddress(addr)
+		if err != nil {
+			return err
+		}
+		script, err := n.ob.Wallet.AddressToScript(a)
+		if err != nil {
+			return err
+		}
+		_, _, _, _, saleErr := n.ob.Datastore.Sales().GetByPaymentAddress(a)
+		_, _, _, _, purchaseErr := n.ob.Datastore.Purchases().GetByPaymentAddress(a)
+		if saleErr != nil && purchaseErr != nil {
+			continue
+		}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prev, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(value, script))
+		// The node looks the payment up in its wallet to list it
+		if err := n.txns.Put(tx, int(value), 0, time.Now(), true); err != nil {
+			return err
+		}
+		txid := tx.TxHash()
+		n.payments.OnTransactionReceived(spvwallet.TransactionCallback{
+			Txid:      txid.CloneBytes(),
+			Outputs:   []spvwallet.TransactionOutput{{ScriptPubKey: script, Value: value}},
+			Timestamp: time.Now(),
+			Value:     value,
+			WatchOnly: true,
+		})
+	}
+	return nil
+}
+
+// Partition cuts the links between the groups and between each group and
+// the nodes in none, closing their connections. Unlike a ban, partitioned
+// nodes cannot reach each other at all.
+func (s *Network) Partition(groups ...[]*Node) error {
+	side := make(map[*Node]int)
+	for i, g := range groups {
+		for _, n := range g {
+			side[n] = i + 1
+		}
+	}
+	nodes := s.Nodes()
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			if side[a] == side[b] || len(s.mn.LinksBetweenPeers(a.id(), b.id())) == 0 {
+				continue
+			}
+			if err := s.mn.UnlinkPeers(a.id(), b.id()); err != nil {
+				return err
+			}
+			if err := s.mn.DisconnectPeers(a.id(), b.id()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Heal links and connects every node again
+func (s *Network) Heal() error {
+	nodes := s.Nodes()
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			if err := s.link(a, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close stops every node and removes their repos
+func (s *Network) Close() error {
+	for _, n := range s.Nodes() {
+		n.stop()
+	}
+	s.cancel()
+	return os.RemoveAll(s.dir)
+}
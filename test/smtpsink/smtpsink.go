@@ -0,0 +1,226 @@
+// Package smtpsink is an SMTP server that accepts every mail and keeps it in
+// memory, so the email notifications of test nodes can be read back. It
+// speaks just enough SMTP for net/smtp.SendMail, which the node uses.
+package smtpsink
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a mail received by the sink
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+	Time    time.Time
+}
+
+// Server is a running sink
+type Server struct {
+	listener net.Listener
+
+	lock     sync.Mutex
+	messages []Message
+	arrived  chan struct{}
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// Start listens on addr, e.g. 127.0.0.1:0. The node only sends credentials
+// over plain text to localhost, so the sink must listen on a loopback
+// address.
+func Start(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: l, arrived: make(chan struct{}), conns: make(map[net.Conn]bool)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the host:port to put in the node's SMTP settings
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops accepting mail and ends open sessions
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.lock.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns the mail received so far, oldest first
+func (s *Server) Messages() []Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// To returns the mail received so far for recipient
+func (s *Server) To(recipient string) []Message {
+	var ret []Message
+	for _, m := range s.Messages() {
+		for _, r := range m.To {
+			if strings.EqualFold(r, recipient) {
+				ret = append(ret, m)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Wait blocks until at least n messages were received or the timeout passes
+func (s *Server) Wait(n int, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		s.lock.Lock()
+		got, arrived := len(s.messages), s.arrived
+		s.lock.Unlock()
+		if got >= n {
+			return nil
+		}
+		select {
+		case <-arrived:
+		case <-deadline:
+			return fmt.Errorf("smtpsink: received %d of %d messages", got, n)
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(textproto.NewConn(conn))
+			conn.Close()
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+func (s *Server) add(m Message) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages = append(s.messages, m)
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+}
+
+// session runs one SMTP conversation
+func (s *Server) session(c *textproto.Conn) {
+	c.PrintfLine("220 smtpsink ready")
+	var from string
+	var to []string
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-smtpsink")
+			c.PrintfLine("250 AUTH PLAIN")
+		case "HELO":
+			c.PrintfLine("250 smtpsink")
+		case "AUTH":
+			// net/smtp sends PLAIN with the initial response, anything
+			// else is asked for it
+			if !strings.Contains(arg, " ") {
+				c.PrintfLine("334 ")
+				if _, err := c.ReadLine(); err != nil {
+					return
+				}
+			}
+			c.PrintfLine("235 authenticated")
+		case "MAIL":
+			from, to = address(arg), nil
+			c.PrintfLine("250 ok")
+		case "RCPT":
+			to = append(to, address(arg))
+			c.PrintfLine("250 ok")
+		case "DATA":
+			c.PrintfLine("354 end with <CR><LF>.<CR><LF>")
+			data, err := ioutil.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			s.add(parse(from, to, data))
+			c.PrintfLine("250 queued")
+		case "RSET":
+			from, to = "", nil
+			c.PrintfLine("250 ok")
+		case "NOOP":
+			c.PrintfLine("250 ok")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// address extracts the mailbox from FROM:<a@b> or TO:<a@b>
+func address(arg string) string {
+	if i := strings.IndexByte(arg, ':'); i >= 0 {
+		arg = arg[i+1:]
+	}
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		arg = arg[:i]
+	}
+	return strings.Trim(arg, "<>")
+}
+
+func parse(from string, to []string, data []byte) Message {
+	m := Message{From: from, To: to, Time: time.Now()}
+	msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		m.Body = string(data)
+		return m
+	}
+	m.Subject = msg.Header.Get("Subject")
+	body, _ := ioutil.ReadAll(msg.Body)
+	m.Body = string(body)
+	return m
+}
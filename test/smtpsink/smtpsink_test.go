@@ -0,0 +1,51 @@
+package smtpsink
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendMail(t *testing.T) {
+	s, err := Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// The same shape of message the node's SMTP notifier sends
+	body := "From: node@test\r\nTo: buyer@test\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\nSubject: [OpenBazaar] Order fulfilled\r\n\r\nOrder \"QmOrder\" was marked as fulfilled.\r\n"
+	auth := smtp.PlainAuth("", "node@test", "secret", "127.0.0.1")
+	if err := smtp.SendMail(s.Addr(), auth, "node@test", []string{"buyer@test"}, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Wait(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	msgs := s.To("buyer@test")
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message for buyer@test, got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.From != "node@test" || m.Subject != "[OpenBazaar] Order fulfilled" {
+		t.Errorf("Unexpected message %+v", m)
+	}
+	if !strings.Contains(m.Body, "QmOrder") {
+		t.Errorf("Expected the order ID in the body, got %q", m.Body)
+	}
+	if len(s.To("vendor@test")) != 0 {
+		t.Error("Expected no messages for vendor@test")
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	s, err := Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Wait(1, 50*time.Millisecond); err == nil {
+		t.Error("Expected a timeout with no mail sent")
+	}
+}
@@ -0,0 +1,87 @@
+package startup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/bench"
+)
+
+// Baseline holds the accepted boot times per repo size name
+type Baseline map[string]Recorded
+
+// Recorded is the accepted timing of one repo size
+type Recorded struct {
+	ColdStart bench.Duration `json:"coldStart"`
+	Warm      bench.Duration `json:"warm"`
+}
+
+// LoadBaseline reads a baseline written by Save
+func LoadBaseline(path string) (Baseline, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make(Baseline)
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return baseline, nil
+}
+
+// Save writes the baseline as indented JSON
+func (b Baseline) Save(path string) error {
+	out, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// Record sets the accepted timing of a repo size
+func (b Baseline) Record(size string, t Timing) {
+	b[size] = Recorded{ColdStart: bench.Duration{Duration: t.ColdStart()}, Warm: bench.Duration{Duration: t.Warm}}
+}
+
+// Check returns an error when the timing is more than tolerance, e.g. 0.2
+// for 20%, slower than the baseline. Sizes missing from the baseline pass.
+func (b Baseline) Check(size string, t Timing, tolerance float64) error {
+	r, ok := b[size]
+	if !ok {
+		return nil
+	}
+	var errs []string
+	check := func(name string, got, base time.Duration) {
+		limit := time.Duration(float64(base) * (1 + tolerance))
+		if base > 0 && got > limit {
+			errs = append(errs, fmt.Sprintf("%s %s is slower than baseline %s", name, got.Round(time.Millisecond), base))
+		}
+	}
+	check("cold start", t.ColdStart(), r.ColdStart.Duration)
+	check("warm restart", t.Warm, r.Warm.Duration)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", size, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Median returns the median of each field of the timings
+func Median(timings []Timing) Timing {
+	field := func(get func(Timing) time.Duration) time.Duration {
+		var d []time.Duration
+		for _, t := range timings {
+			d = append(d, get(t))
+		}
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		return bench.Percentile(d, 50)
+	}
+	return Timing{
+		Init: field(func(t Timing) time.Duration { return t.Init }),
+		Cold: field(func(t Timing) time.Duration { return t.Cold }),
+		Warm: field(func(t Timing) time.Duration { return t.Warm }),
+	}
+}
@@ -0,0 +1,52 @@
+package startup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBaselineCheck(t *testing.T) {
+	b := make(Baseline)
+	b.Record("empty", Timing{Init: time.Second, Cold: 4 * time.Second, Warm: 3 * time.Second})
+
+	dir, err := ioutil.TempDir("", "startup-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded["empty"].ColdStart.Duration != 5*time.Second {
+		t.Errorf("Expected 5s cold start, got %s", loaded["empty"].ColdStart)
+	}
+
+	if err := loaded.Check("empty", Timing{Cold: 5500 * time.Millisecond, Warm: 3 * time.Second}, 0.2); err != nil {
+		t.Error(err)
+	}
+	if err := loaded.Check("empty", Timing{Cold: 5 * time.Second, Warm: 4 * time.Second}, 0.2); err == nil {
+		t.Error("Expected warm restart regression")
+	}
+	if err := loaded.Check("10k-orders", Timing{Cold: time.Hour}, 0.2); err != nil {
+		t.Error("Expected sizes missing from the baseline to pass")
+	}
+}
+
+func TestMedian(t *testing.T) {
+	m := Median([]Timing{
+		{Cold: 3 * time.Second, Warm: time.Second},
+		{Cold: time.Second, Warm: 2 * time.Second},
+		{Cold: 2 * time.Second, Warm: 3 * time.Second},
+	})
+	if m.Cold != 2*time.Second || m.Warm != 2*time.Second {
+		t.Errorf("Unexpected median %+v", m)
+	}
+}
@@ -0,0 +1,73 @@
+package startup
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/OpenBazaar/jsonpb"
+	"github.com/OpenBazaar/openbazaar-go/pb"
+	"github.com/OpenBazaar/openbazaar-go/test/graph"
+	"github.com/golang/protobuf/ptypes"
+	_ "github.com/mutecomm/go-sqlcipher"
+)
+
+// SeedOrders writes n completed sales into the database of a stopped node.
+// The contracts carry only the fields the sales index reads.
+func SeedOrders(repoPath, password string, testnet bool, n int) error {
+	name := "mainnet.db"
+	if testnet {
+		name = "testnet.db"
+	}
+	conn, err := sql.Open("sqlite3", path.Join(repoPath, "datastore", name))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if password != "" {
+		conn.Exec("pragma key='" + password + "';")
+	}
+	now := time.Now()
+	ts, err := ptypes.TimestampProto(now)
+	if err != nil {
+		return err
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("insert into sales(orderID, contract, state, read, timestamp, total, thumbnail, buyerID, buyerBlockchainID, title, shippingName, shippingAddress, paymentAddr) values(?,?,?,?,?,?,?,?,?,?,?,?,?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	m := jsonpb.Marshaler{Indent: "    "}
+	for i := 0; i < n; i++ {
+		title := fmt.Sprintf("Startup order %d", i)
+		buyer := graph.RandomPeerID()
+		contract := &pb.RicardianContract{
+			VendorListings: []*pb.Listing{{
+				Slug: fmt.Sprintf("startup-%d", i),
+				Item: &pb.Listing_Item{Title: title},
+			}},
+			BuyerOrder: &pb.Order{
+				BuyerID:   &pb.ID{PeerID: buyer},
+				Timestamp: ts,
+				Payment:   &pb.Order_Payment{Method: pb.Order_Payment_DIRECT, Amount: 100000},
+			},
+		}
+		out, err := m.MarshalToString(contract)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = stmt.Exec(fmt.Sprintf("startup-order-%d", i), out, int(pb.OrderState_COMPLETED), 1, now.Unix(), 100000, "", buyer, "", title, "", "", "")
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
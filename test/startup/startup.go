@@ -0,0 +1,103 @@
+// Package startup measures how long openbazaard takes to boot on repos of
+// different sizes and compares the timings against a recorded baseline
+package startup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/OpenBazaar/openbazaar-go/test/fixtures"
+	"github.com/OpenBazaar/openbazaar-go/test/migration"
+	"github.com/OpenBazaar/openbazaar-go/test/nodes"
+)
+
+// RepoSize describes the contents of a repo booted by the benchmark
+type RepoSize struct {
+	Name     string
+	Listings int
+	Orders   int
+}
+
+// Sizes are the repos measured by default
+var Sizes = []RepoSize{
+	{Name: "empty"},
+	{Name: "1k-listings", Listings: 1000},
+	{Name: "10k-orders", Orders: 10000},
+}
+
+// Timing is one measurement of a repo size
+type Timing struct {
+	// Init is how long openbazaard init took
+	Init time.Duration
+
+	// Cold is the first boot of the freshly prepared repo, from starting
+	// the process until the API answers
+	Cold time.Duration
+
+	// Warm is a boot right after the node was shut down cleanly
+	Warm time.Duration
+}
+
+// ColdStart is the time from init until the API first answers
+func (t Timing) ColdStart() time.Duration {
+	return t.Init + t.Cold
+}
+
+// Measure creates a repo of the given size in dir and times its init, a
+// cold boot and a warm restart. The repo is filled while the node runs and
+// then stopped, so the cold boot is the first start after filling it.
+func Measure(ctx context.Context, binary, dir string, size RepoSize, testnet bool) (Timing, error) {
+	var t Timing
+	start := time.Now()
+	if err := Init(ctx, binary, dir, testnet); err != nil {
+		return t, err
+	}
+	t.Init = time.Since(start)
+	if err := Fill(ctx, binary, dir, size, testnet); err != nil {
+		return t, fmt.Errorf("filling %s repo: %s", size.Name, err)
+	}
+	for _, d := range []*time.Duration{&t.Cold, &t.Warm} {
+		start := time.Now()
+		p, err := migration.Boot(ctx, binary, dir, testnet, "", "")
+		if err != nil {
+			return t, err
+		}
+		*d = time.Since(start)
+		if err := p.Stop(); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// Init creates a new unencrypted repo in dir
+func Init(ctx context.Context, binary, dir string, testnet bool) error {
+	return nodes.Init(ctx, binary, dir, testnet)
+}
+
+// Fill adds the listings of size through the API of a running node, then
+// stops it and writes the orders straight into its database
+func Fill(ctx context.Context, binary, dir string, size RepoSize, testnet bool) error {
+	if size.Listings > 0 {
+		p, err := migration.Boot(ctx, binary, dir, testnet, "", "")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size.Listings; i++ {
+			l := fixtures.Listing()
+			l["slug"] = fmt.Sprintf("startup-%d", i)
+			if _, err := p.Client.CreateListing(l); err != nil {
+				p.Stop()
+				return fmt.Errorf("listing %d: %s", i, err)
+			}
+		}
+		if err := p.Stop(); err != nil {
+			return err
+		}
+	}
+	if size.Orders > 0 {
+		return SeedOrders(dir, "", testnet, size.Orders)
+	}
+	return nil
+}
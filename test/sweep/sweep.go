@@ -0,0 +1,198 @@
+// Package sweep expands scenario parameters into a grid of points and writes
+// the outcome of running a scenario at every point as a matrix
+package sweep
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Param is a parameter and the values it is swept over
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// ParseParam parses name=v1,v2,...
+func ParseParam(s string) (Param, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Param{}, fmt.Errorf("invalid parameter %q, expected name=value,value", s)
+	}
+	p := Param{Name: parts[0]}
+	for _, v := range strings.Split(parts[1], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			p.Values = append(p.Values, v)
+		}
+	}
+	if len(p.Values) == 0 {
+		return Param{}, fmt.Errorf("parameter %s has no values", p.Name)
+	}
+	return p, nil
+}
+
+// Value is the value of one parameter at a point
+type Value struct {
+	Name  string
+	Value string
+}
+
+// Point is one combination of parameter values, in the order the parameters
+// were given
+type Point []Value
+
+// Get returns the value of the named parameter
+func (p Point) Get(name string) (string, bool) {
+	for _, v := range p {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return "", false
+}
+
+// Int returns the value of the named parameter as an int, def if unset
+func (p Point) Int(name string, def int) (int, error) {
+	s, ok := p.Get(name)
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: %s", name, err)
+	}
+	return n, nil
+}
+
+// Duration returns the value of the named parameter as a duration, def if
+// unset
+func (p Point) Duration(name string, def time.Duration) (time.Duration, error) {
+	s, ok := p.Get(name)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: %s", name, err)
+	}
+	return d, nil
+}
+
+// Float returns the value of the named parameter as a float, def if unset
+func (p Point) Float(name string, def float64) (float64, error) {
+	s, ok := p.Get(name)
+	if !ok {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s: %s", name, err)
+	}
+	return f, nil
+}
+
+func (p Point) String() string {
+	parts := make([]string, len(p))
+	for n, v := range p {
+		parts[n] = v.Name + "=" + v.Value
+	}
+	return strings.Join(parts, " ")
+}
+
+// Grid returns every combination of the parameter values. The last parameter
+// varies fastest.
+func Grid(params ...Param) []Point {
+	points := []Point{nil}
+	for _, param := range params {
+		var next []Point
+		for _, p := range points {
+			for _, v := range param.Values {
+				point := make(Point, len(p), len(p)+1)
+				copy(point, p)
+				next = append(next, append(point, Value{param.Name, v}))
+			}
+		}
+		points = next
+	}
+	return points
+}
+
+// Cell is the outcome of the scenario at one point
+type Cell struct {
+	Point    Point
+	Duration time.Duration
+	Err      error
+}
+
+func (c Cell) status() string {
+	if c.Err != nil {
+		return "FAIL"
+	}
+	return "ok"
+}
+
+// WriteMatrix writes one aligned row per point with a column for every
+// parameter, the outcome and the duration, followed by the errors
+func WriteMatrix(w io.Writer, params []Param, cells []Cell) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	var header []string
+	for _, p := range params {
+		header = append(header, p.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(append(header, "result", "duration"), "\t"))
+	failed := 0
+	for _, c := range cells {
+		var row []string
+		for _, p := range params {
+			v, _ := c.Point.Get(p.Name)
+			row = append(row, v)
+		}
+		fmt.Fprintln(tw, strings.Join(append(row, c.status(), c.Duration.Round(time.Millisecond).String()), "\t"))
+		if c.Err != nil {
+			failed++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed == 0 {
+		_, err := fmt.Fprintf(w, "\nall %d points passed\n", len(cells))
+		return err
+	}
+	fmt.Fprintf(w, "\n%d of %d points failed\n", failed, len(cells))
+	for _, c := range cells {
+		if c.Err != nil {
+			fmt.Fprintf(w, "  %s: %s\n", c.Point, c.Err)
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the matrix as CSV with the error message in the last column
+func WriteCSV(w io.Writer, params []Param, cells []Cell) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, p := range params {
+		header = append(header, p.Name)
+	}
+	cw.Write(append(header, "result", "seconds", "error"))
+	for _, c := range cells {
+		var row []string
+		for _, p := range params {
+			v, _ := c.Point.Get(p.Name)
+			row = append(row, v)
+		}
+		msg := ""
+		if c.Err != nil {
+			msg = c.Err.Error()
+		}
+		cw.Write(append(row, c.status(), strconv.FormatFloat(c.Duration.Seconds(), 'f', 3, 64), msg))
+	}
+	cw.Flush()
+	return cw.Error()
+}
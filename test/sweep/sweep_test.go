@@ -0,0 +1,82 @@
+package sweep
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrid(t *testing.T) {
+	nodes, err := ParseParam("nodes=2,4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	latency, err := ParseParam("latency=0s, 100ms ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid := Grid(nodes, latency)
+	var got []string
+	for _, p := range grid {
+		got = append(got, p.String())
+	}
+	want := "nodes=2 latency=0s|nodes=2 latency=100ms|nodes=4 latency=0s|nodes=4 latency=100ms"
+	if strings.Join(got, "|") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, "|"))
+	}
+	if n, err := grid[3].Int("nodes", 0); err != nil || n != 4 {
+		t.Errorf("Expected 4 nodes, got %d (%v)", n, err)
+	}
+	if d, err := grid[3].Duration("latency", 0); err != nil || d != 100*time.Millisecond {
+		t.Errorf("Expected 100ms latency, got %s (%v)", d, err)
+	}
+	if d, err := grid[3].Duration("jitter", time.Second); err != nil || d != time.Second {
+		t.Errorf("Expected the default for an unset parameter, got %s (%v)", d, err)
+	}
+	if f, err := (Point{{"loss", "2.5"}}).Float("loss", 0); err != nil || f != 2.5 {
+		t.Errorf("Expected 2.5%% loss, got %g (%v)", f, err)
+	}
+	if _, err := (Point{{"loss", "2.5%"}}).Float("loss", 0); err == nil {
+		t.Error("Expected a loss with a percent sign to be rejected")
+	}
+	if len(Grid()) != 1 {
+		t.Error("Expected an empty grid to be a single point")
+	}
+}
+
+func TestParseParamErrors(t *testing.T) {
+	for _, s := range []string{"nodes", "=1,2", "nodes=", "nodes=,"} {
+		if _, err := ParseParam(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	params := []Param{{Name: "nodes", Values: []string{"2", "4"}}}
+	grid := Grid(params...)
+	cells := []Cell{
+		{Point: grid[0], Duration: 1500 * time.Millisecond},
+		{Point: grid[1], Duration: time.Minute, Err: errors.New("order stuck in AWAITING_PAYMENT")},
+	}
+	var b bytes.Buffer
+	if err := WriteMatrix(&b, params, cells); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"nodes  result  duration", "2      ok      1.5s", "4      FAIL    1m0s", "1 of 2 points failed", "nodes=4: order stuck"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	b.Reset()
+	if err := WriteCSV(&b, params, cells); err != nil {
+		t.Fatal(err)
+	}
+	if want := "nodes,result,seconds,error\n2,ok,1.500,\n4,FAIL,60.000,order stuck in AWAITING_PAYMENT\n"; b.String() != want {
+		t.Errorf("Expected CSV\n%s\ngot\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,143 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Layout is a declared topology: which of n nodes, by index, should be
+// connected to each other
+type Layout struct {
+	Name string
+	adj  [][]bool
+}
+
+func newLayout(name string, n int) Layout {
+	l := Layout{Name: name, adj: make([][]bool, n)}
+	for i := range l.adj {
+		l.adj[i] = make([]bool, n)
+	}
+	return l
+}
+
+func (l Layout) connect(i, j int) {
+	if i != j {
+		l.adj[i][j], l.adj[j][i] = true, true
+	}
+}
+
+// Star connects node 0 to every other node and no others to each other
+func Star(n int) Layout {
+	l := newLayout("star", n)
+	for i := 1; i < n; i++ {
+		l.connect(0, i)
+	}
+	return l
+}
+
+// Ring connects every node to the next, and the last to the first
+func Ring(n int) Layout {
+	l := newLayout("ring", n)
+	if n < 2 {
+		return l
+	}
+	for i := 0; i < n; i++ {
+		l.connect(i, (i+1)%n)
+	}
+	return l
+}
+
+// FullMesh connects every node to every other
+func FullMesh(n int) Layout {
+	l := newLayout("full-mesh", n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			l.connect(i, j)
+		}
+	}
+	return l
+}
+
+// Matrix is a custom layout from an adjacency matrix. It has to be square and
+// symmetric, connections being undirected, with nothing on the diagonal.
+func Matrix(m [][]bool) (Layout, error) {
+	l := newLayout("custom", len(m))
+	for i, row := range m {
+		if len(row) != len(m) {
+			return Layout{}, fmt.Errorf("topology: row %d of the matrix has %d entries, not %d", i, len(row), len(m))
+		}
+		for j, c := range row {
+			switch {
+			case i == j && c:
+				return Layout{}, fmt.Errorf("topology: node %d is connected to itself", i)
+			case c != m[j][i]:
+				return Layout{}, fmt.Errorf("topology: matrix is not symmetric at %d,%d", i, j)
+			case c:
+				l.connect(i, j)
+			}
+		}
+	}
+	return l, nil
+}
+
+// Size is the number of nodes the layout is for
+func (l Layout) Size() int { return len(l.adj) }
+
+// Connected reports whether nodes i and j should be connected
+func (l Layout) Connected(i, j int) bool { return l.adj[i][j] }
+
+// Pairs returns the connected pairs of nodes, lower index first
+func (l Layout) Pairs() [][2]int {
+	var pairs [][2]int
+	for i := range l.adj {
+		for j := i + 1; j < len(l.adj); j++ {
+			if l.adj[i][j] {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// Diff compares the snapshot to the layout, names being the vertex names of
+// the nodes by index. Missing are declared connections the snapshot lacks,
+// extra are connections between the nodes the layout does not declare.
+// Connections to peers outside names are ignored.
+func (l Layout) Diff(s *Snapshot, names []string) (missing, extra []Edge) {
+	index := make(map[string]int)
+	for i, name := range names {
+		index[name] = i
+	}
+	seen := make(map[[2]int]bool)
+	for _, e := range s.Edges {
+		i, ok := index[e.From]
+		j, ok2 := index[e.To]
+		if !ok || !ok2 || i == j {
+			continue
+		}
+		if i > j {
+			i, j = j, i
+		}
+		seen[[2]int{i, j}] = true
+		if !l.adj[i][j] {
+			extra = append(extra, edge(names[i], names[j]))
+		}
+	}
+	for _, p := range l.Pairs() {
+		if !seen[p] {
+			missing = append(missing, edge(names[p[0]], names[p[1]]))
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return extra[i].From < extra[j].From || extra[i].From == extra[j].From && extra[i].To < extra[j].To
+	})
+	return missing, extra
+}
+
+// edge orders the ends by name, as Build does
+func edge(a, b string) Edge {
+	if a > b {
+		a, b = b, a
+	}
+	return Edge{From: a, To: b}
+}
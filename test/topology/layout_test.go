@@ -0,0 +1,65 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayouts(t *testing.T) {
+	for _, c := range []struct {
+		layout Layout
+		want   [][2]int
+	}{
+		{Star(4), [][2]int{{0, 1}, {0, 2}, {0, 3}}},
+		{Ring(4), [][2]int{{0, 1}, {0, 3}, {1, 2}, {2, 3}}},
+		{Ring(2), [][2]int{{0, 1}}},
+		{FullMesh(3), [][2]int{{0, 1}, {0, 2}, {1, 2}}},
+		{Star(1), nil},
+	} {
+		if got := c.layout.Pairs(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Expected %s of %d to connect %v, got %v", c.layout.Name, c.layout.Size(), c.want, got)
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	l, err := Matrix([][]bool{
+		{false, true, false},
+		{true, false, true},
+		{false, true, false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l.Pairs(), [][2]int{{0, 1}, {1, 2}}) {
+		t.Errorf("Unexpected pairs %v", l.Pairs())
+	}
+	for _, m := range [][][]bool{
+		{{false, true}, {false, false}},
+		{{true, false}, {false, false}},
+		{{false, true}, {true}},
+	} {
+		if _, err := Matrix(m); err == nil {
+			t.Errorf("Expected %v to be rejected", m)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	names := []string{"vendor-1", "buyer-1", "buyer-2"}
+	s := &Snapshot{Edges: []Edge{
+		{From: "buyer-1", To: "vendor-1"},
+		{From: "buyer-1", To: "buyer-2"},
+		{From: "Qm…r12345", To: "buyer-2"},
+	}}
+	missing, extra := Star(3).Diff(s, names)
+	if want := []Edge{{From: "buyer-2", To: "vendor-1"}}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("Expected missing %v, got %v", want, missing)
+	}
+	if want := []Edge{{From: "buyer-1", To: "buyer-2"}}; !reflect.DeepEqual(extra, want) {
+		t.Errorf("Expected extra %v, got %v", want, extra)
+	}
+	if missing, extra := Ring(3).Diff(s, names); len(extra) != 0 || len(missing) != 1 {
+		t.Errorf("Expected only buyer-2 -- vendor-1 missing from the ring, got %v and %v", missing, extra)
+	}
+}
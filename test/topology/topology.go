@@ -0,0 +1,182 @@
+// Package topology records which test nodes were connected to each other and
+// renders the observed network as DOT or SVG
+package topology
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Vertex is a node of the network, or an outside peer one of them was
+// connected to
+type Vertex struct {
+	Name   string
+	Role   string
+	PeerID string
+}
+
+// Edge is a connection between two vertices, identified by name
+type Edge struct {
+	From, To string
+
+	// Relay is set when the connection goes through a circuit relay
+	Relay bool
+}
+
+// Snapshot is the topology at one point of a run
+type Snapshot struct {
+	Label    string
+	Taken    time.Time
+	Vertices []Vertex
+	Edges    []Edge
+}
+
+// Peers are the swarm addresses a node reports connections to, as returned
+// by GET /ob/peers
+type Peers struct {
+	Vertex Vertex
+	Addrs  []string
+	Err    error
+}
+
+// Build turns the peers of every node into a snapshot. Connections are
+// undirected, so a connection both ends report is one edge. Peers outside
+// the network become vertices of role "external".
+func Build(label string, peers []Peers) *Snapshot {
+	s := &Snapshot{Label: label, Taken: time.Now()}
+	names := make(map[string]string)
+	for _, p := range peers {
+		s.Vertices = append(s.Vertices, p.Vertex)
+		names[p.Vertex.PeerID] = p.Vertex.Name
+	}
+	seen := make(map[[2]string]int)
+	for _, p := range peers {
+		for _, addr := range p.Addrs {
+			i := strings.LastIndex(addr, "/ipfs/")
+			if i < 0 {
+				continue
+			}
+			id := addr[i+len("/ipfs/"):]
+			name, ok := names[id]
+			if !ok {
+				name = short(id)
+				names[id] = name
+				s.Vertices = append(s.Vertices, Vertex{Name: name, Role: "external", PeerID: id})
+			}
+			key := [2]string{p.Vertex.Name, name}
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+			relay := strings.Contains(addr, "/p2p-circuit")
+			if n, ok := seen[key]; ok {
+				s.Edges[n].Relay = s.Edges[n].Relay || relay
+				continue
+			}
+			seen[key] = len(s.Edges)
+			s.Edges = append(s.Edges, Edge{From: key[0], To: key[1], Relay: relay})
+		}
+	}
+	return s
+}
+
+// Components returns the names of the vertices of every connected component,
+// largest first. More than one component means the network was partitioned.
+func (s *Snapshot) Components() [][]string {
+	adj := make(map[string][]string)
+	for _, e := range s.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+		adj[e.To] = append(adj[e.To], e.From)
+	}
+	visited := make(map[string]bool)
+	var components [][]string
+	for _, v := range s.Vertices {
+		if visited[v.Name] {
+			continue
+		}
+		var component []string
+		stack := []string{v.Name}
+		visited[v.Name] = true
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, n)
+			for _, m := range adj[n] {
+				if !visited[m] {
+					visited[m] = true
+					stack = append(stack, m)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+	return components
+}
+
+// DOT renders the snapshot for graphviz. Relayed connections are dashed.
+func (s *Snapshot) DOT() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "graph %q {\n", s.Label)
+	for _, v := range s.Vertices {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", v.Name, v.Name+"\n"+v.Role)
+	}
+	for _, e := range s.Edges {
+		style := ""
+		if e.Relay {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "  %q -- %q%s;\n", e.From, e.To, style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// palette colours the components of a partitioned network
+var palette = []string{"#8cb4e0", "#e0a98c", "#9fd69b", "#d6c79b", "#c69bd6", "#9bd6d1"}
+
+// SVG draws the vertices on a circle, coloured by connected component.
+// Relayed connections are dashed.
+func (s *Snapshot) SVG() string {
+	const size, radius, r = 360.0, 140.0, 18.0
+	pos := make(map[string][2]float64)
+	for i, v := range s.Vertices {
+		a := 2 * math.Pi * float64(i) / float64(len(s.Vertices))
+		pos[v.Name] = [2]float64{size/2 + radius*math.Cos(a), size/2 + radius*math.Sin(a)}
+	}
+	colour := make(map[string]string)
+	for i, c := range s.Components() {
+		for _, n := range c {
+			colour[n] = palette[i%len(palette)]
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" font-size="10" font-family="sans-serif">`, size, size)
+	for _, e := range s.Edges {
+		dash := ""
+		if e.Relay {
+			dash = ` stroke-dasharray="4 3"`
+		}
+		from, to := pos[e.From], pos[e.To]
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#666"%s/>`, from[0], from[1], to[0], to[1], dash)
+	}
+	for _, v := range s.Vertices {
+		p := pos[v.Name]
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="%.0f" fill="%s"><title>%s</title></circle>`, p[0], p[1], r, colour[v.Name], html.EscapeString(v.PeerID))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, p[0], p[1]+3, html.EscapeString(v.Name))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+func short(peerID string) string {
+	if len(peerID) <= 8 {
+		return peerID
+	}
+	return peerID[:2] + "…" + peerID[len(peerID)-6:]
+}
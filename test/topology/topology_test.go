@@ -0,0 +1,45 @@
+package topology
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	s := Build("after checkout", []Peers{
+		{Vertex: Vertex{Name: "vendor-1", Role: "vendor", PeerID: "QmVendor"}, Addrs: []string{
+			"/ip4/127.0.0.1/tcp/4001/ipfs/QmBuyer",
+			"/ip4/10.0.0.9/tcp/4001/ipfs/QmRelay/p2p-circuit/ipfs/QmModerator",
+		}},
+		{Vertex: Vertex{Name: "buyer-1", Role: "buyer", PeerID: "QmBuyer"}, Addrs: []string{
+			"/ip4/127.0.0.1/tcp/4002/ipfs/QmVendor",
+		}},
+		{Vertex: Vertex{Name: "moderator-1", Role: "moderator", PeerID: "QmModerator"}},
+		{Vertex: Vertex{Name: "buyer-2", Role: "buyer", PeerID: "QmLonely"}, Addrs: []string{
+			"/ip4/1.2.3.4/tcp/4001/ipfs/QmOutsidePeer12345",
+		}},
+	})
+	want := []Edge{
+		{From: "buyer-1", To: "vendor-1"},
+		{From: "moderator-1", To: "vendor-1", Relay: true},
+		{From: "Qm…r12345", To: "buyer-2"},
+	}
+	if !reflect.DeepEqual(s.Edges, want) {
+		t.Errorf("Expected edges %v, got %v", want, s.Edges)
+	}
+	components := s.Components()
+	if len(components) != 2 {
+		t.Fatalf("Expected 2 partitions, got %v", components)
+	}
+	if !reflect.DeepEqual(components[0], []string{"buyer-1", "moderator-1", "vendor-1"}) {
+		t.Errorf("Unexpected largest component %v", components[0])
+	}
+	dot := s.DOT()
+	if !strings.Contains(dot, `"moderator-1" -- "vendor-1" [style=dashed];`) {
+		t.Errorf("Expected a dashed relay edge in\n%s", dot)
+	}
+	if svg := s.SVG(); strings.Count(svg, "<circle") != 5 || !strings.Contains(svg, "stroke-dasharray") {
+		t.Errorf("Expected 5 vertices and a relay edge in %s", svg)
+	}
+}
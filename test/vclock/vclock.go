@@ -0,0 +1,126 @@
+// Package vclock is a virtual clock for simulated networks. Time stands
+// still until it is advanced, and timers fire in deadline order, ties in the
+// order they were set, so a run driven by the clock plays out the same way
+// every time and takes no longer than its work.
+package vclock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Epoch is where clocks created with New(time.Time{}) start
+var Epoch = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a virtual clock, safe for concurrent use
+type Clock struct {
+	lock   sync.Mutex
+	now    time.Time
+	seq    int
+	timers []timer
+}
+
+type timer struct {
+	at  time.Time
+	seq int
+	c   chan time.Time
+}
+
+// New returns a clock standing at start, or at Epoch when start is zero
+func New(start time.Time) *Clock {
+	if start.IsZero() {
+		start = Epoch
+	}
+	return &Clock{now: start}
+}
+
+// Now returns the virtual time
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Since returns the virtual time elapsed since t
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// After returns a channel receiving the virtual time once the clock was
+// advanced by d. It fires right away when d is not positive.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.seq++
+	c.timers = append(c.timers, timer{at: c.now.Add(d), seq: c.seq, c: ch})
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].at.Equal(c.timers[j].at) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	return ch
+}
+
+// Sleep blocks until the clock was advanced by d
+func (c *Clock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Pending returns how many timers have not fired yet
+func (c *Clock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.timers)
+}
+
+// Advance moves the clock forward by d, firing every timer due on the way
+// at its own deadline
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	end := c.now.Add(d)
+	c.lock.Unlock()
+	for c.fireNext(end) {
+	}
+	c.lock.Lock()
+	if end.After(c.now) {
+		c.now = end
+	}
+	c.lock.Unlock()
+}
+
+// Next moves the clock to the earliest pending timer and fires it, along
+// with any other timer due then. It returns false if no timer is pending.
+func (c *Clock) Next() bool {
+	c.lock.Lock()
+	if len(c.timers) == 0 {
+		c.lock.Unlock()
+		return false
+	}
+	at := c.timers[0].at
+	c.lock.Unlock()
+	for c.fireNext(at) {
+	}
+	return true
+}
+
+// fireNext fires the earliest timer due by end and reports whether there
+// was one
+func (c *Clock) fireNext(end time.Time) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.timers) == 0 || c.timers[0].at.After(end) {
+		return false
+	}
+	t := c.timers[0]
+	c.timers = c.timers[1:]
+	c.now = t.at
+	t.c <- t.at
+	return true
+}
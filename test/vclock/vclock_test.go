@@ -0,0 +1,58 @@
+package vclock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdvance(t *testing.T) {
+	c := New(time.Time{})
+	late := c.After(2 * time.Second)
+	early := c.After(time.Second)
+	tie := c.After(time.Second)
+	select {
+	case <-early:
+		t.Fatal("Expected the timer to wait for the clock")
+	default:
+	}
+	c.Advance(1500 * time.Millisecond)
+	if at := <-early; !at.Equal(Epoch.Add(time.Second)) {
+		t.Errorf("Expected the timer to fire at its deadline, got %s", at)
+	}
+	<-tie
+	if c.Pending() != 1 {
+		t.Errorf("Expected one pending timer, got %d", c.Pending())
+	}
+	if d := c.Since(Epoch); d != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s to have passed, got %s", d)
+	}
+	c.Advance(time.Second)
+	<-late
+	if d := c.Since(Epoch); d != 2500*time.Millisecond {
+		t.Errorf("Expected 2.5s to have passed, got %s", d)
+	}
+}
+
+func TestNext(t *testing.T) {
+	c := New(time.Time{})
+	if c.Next() {
+		t.Error("Expected no timer to fire")
+	}
+	a := c.After(time.Hour)
+	b := c.After(time.Minute)
+	if !c.Next() {
+		t.Fatal("Expected a timer to fire")
+	}
+	<-b
+	select {
+	case <-a:
+		t.Error("Expected only the earliest timer to fire")
+	default:
+	}
+	if d := c.Since(Epoch); d != time.Minute {
+		t.Errorf("Expected the clock to jump to the timer, got %s", d)
+	}
+	if at := <-c.After(0); !at.Equal(Epoch.Add(time.Minute)) {
+		t.Errorf("Expected an immediate timer, got %s", at)
+	}
+}
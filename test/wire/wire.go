@@ -0,0 +1,191 @@
+// Package wire decodes messages of the OpenBazaar app protocol, as captured
+// from /openbazaar/app/1.0.0 streams, into typed values so tests can assert
+// on the fields that actually went over the wire rather than on what the API
+// reports afterwards
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/OpenBazaar/openbazaar-go/pb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// MaxFrameSize is inet.MessageSizeMax, the largest frame a node reads
+const MaxFrameSize = 4 << 20
+
+// Message is a decoded app protocol message. Which payload field is set
+// depends on Type; the others are nil.
+type Message struct {
+	Type       pb.Message_MessageType
+	RequestID  int32
+	IsResponse bool
+
+	// Contract is set for ORDER, ORDER_CONFIRMATION, ORDER_FULFILLMENT,
+	// ORDER_COMPLETION, REFUND, DISPUTE_OPEN and DISPUTE_CLOSE
+	Contract *pb.RicardianContract
+
+	Chat          *pb.Chat
+	Reject        *pb.OrderReject
+	DisputeUpdate *pb.DisputeUpdate
+
+	// SignedData and the Command it carries are set for FOLLOW, UNFOLLOW,
+	// MODERATOR_ADD and MODERATOR_REMOVE
+	SignedData *pb.SignedData
+	Command    *pb.SignedData_Command
+
+	// OrderID is set for ORDER_CANCEL
+	OrderID string
+
+	// PointerID is set for OFFLINE_ACK
+	PointerID string
+
+	// Ciphertext is set for OFFLINE_RELAY, encrypted to the recipient
+	Ciphertext []byte
+
+	// Error is set for ERROR
+	Error string
+
+	// Raw is the message as received
+	Raw *pb.Message
+}
+
+func (m *Message) String() string {
+	s := m.Type.String()
+	if m.IsResponse {
+		s += " response"
+	}
+	switch {
+	case m.Chat != nil:
+		s += fmt.Sprintf(" %s %q", m.Chat.Flag, m.Chat.Message)
+	case m.OrderID != "":
+		s += " " + m.OrderID
+	case m.Error != "":
+		s += " " + m.Error
+	}
+	return s
+}
+
+// Decode decodes a single serialized message
+func Decode(b []byte) (*Message, error) {
+	raw := new(pb.Message)
+	if err := proto.Unmarshal(b, raw); err != nil {
+		return nil, err
+	}
+	return FromProto(raw)
+}
+
+// FromProto decodes the payload of an already unmarshalled message
+func FromProto(raw *pb.Message) (*Message, error) {
+	m := &Message{
+		Type:       raw.MessageType,
+		RequestID:  raw.RequestId,
+		IsResponse: raw.IsResponse,
+		Raw:        raw,
+	}
+	if raw.Payload == nil {
+		if raw.MessageType == pb.Message_PING {
+			return m, nil
+		}
+		return m, fmt.Errorf("%s without payload", raw.MessageType)
+	}
+	var err error
+	switch raw.MessageType {
+	case pb.Message_PING:
+	case pb.Message_ORDER, pb.Message_ORDER_CONFIRMATION, pb.Message_ORDER_FULFILLMENT, pb.Message_ORDER_COMPLETION,
+		pb.Message_REFUND, pb.Message_DISPUTE_OPEN, pb.Message_DISPUTE_CLOSE:
+		m.Contract = new(pb.RicardianContract)
+		err = ptypes.UnmarshalAny(raw.Payload, m.Contract)
+	case pb.Message_CHAT:
+		m.Chat = new(pb.Chat)
+		err = ptypes.UnmarshalAny(raw.Payload, m.Chat)
+	case pb.Message_ORDER_REJECT:
+		m.Reject = new(pb.OrderReject)
+		err = ptypes.UnmarshalAny(raw.Payload, m.Reject)
+	case pb.Message_DISPUTE_UPDATE:
+		m.DisputeUpdate = new(pb.DisputeUpdate)
+		err = ptypes.UnmarshalAny(raw.Payload, m.DisputeUpdate)
+	case pb.Message_FOLLOW, pb.Message_UNFOLLOW, pb.Message_MODERATOR_ADD, pb.Message_MODERATOR_REMOVE:
+		m.SignedData = new(pb.SignedData)
+		if err = ptypes.UnmarshalAny(raw.Payload, m.SignedData); err == nil {
+			m.Command = new(pb.SignedData_Command)
+			err = proto.Unmarshal(m.SignedData.SerializedData, m.Command)
+		}
+	case pb.Message_ORDER_CANCEL:
+		m.OrderID = string(raw.Payload.Value)
+	case pb.Message_OFFLINE_ACK:
+		m.PointerID = string(raw.Payload.Value)
+	case pb.Message_OFFLINE_RELAY:
+		m.Ciphertext = raw.Payload.Value
+	case pb.Message_ERROR:
+		m.Error = string(raw.Payload.Value)
+	default:
+		err = errors.New("unknown message type")
+	}
+	if err != nil {
+		return m, fmt.Errorf("decoding %s: %s", raw.MessageType, err)
+	}
+	return m, nil
+}
+
+// Reader decodes the varint delimited messages of a captured stream
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a reader decoding the stream r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message, or io.EOF at the end of the stream
+func (r *Reader) Next() (*Message, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds %d", size, MaxFrameSize)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// DecodeStream decodes every message of a captured stream
+func DecodeStream(b []byte) ([]*Message, error) {
+	r := NewReader(bytes.NewReader(b))
+	var ret []*Message
+	for {
+		m, err := r.Next()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, m)
+	}
+}
+
+// Filter returns the messages of the given type
+func Filter(messages []*Message, t pb.Message_MessageType) []*Message {
+	var ret []*Message
+	for _, m := range messages {
+		if m.Type == t {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,101 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/OpenBazaar/openbazaar-go/pb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func frame(t *testing.T, m *pb.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := make([]byte, binary.MaxVarintLen64)
+	return append(size[:binary.PutUvarint(size, uint64(len(b)))], b...)
+}
+
+func TestDecodeStream(t *testing.T) {
+	chat, err := ptypes.MarshalAny(&pb.Chat{MessageId: "QmMsg", Message: "is this still available?", Flag: pb.Chat_MESSAGE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reject, err := ptypes.MarshalAny(&pb.OrderReject{OrderID: "QmOrder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := proto.Marshal(&pb.SignedData_Command{PeerID: "QmVendor", Type: pb.Message_FOLLOW})
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow, err := ptypes.MarshalAny(&pb.SignedData{SerializedData: cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream []byte
+	for _, m := range []*pb.Message{
+		{MessageType: pb.Message_PING},
+		{MessageType: pb.Message_CHAT, Payload: chat},
+		{MessageType: pb.Message_ORDER_REJECT, Payload: reject},
+		{MessageType: pb.Message_ORDER_CANCEL, Payload: &any.Any{Value: []byte("QmOrder")}},
+		{MessageType: pb.Message_FOLLOW, Payload: follow},
+		{MessageType: pb.Message_ERROR, Payload: &any.Any{Value: []byte("not a vendor")}, IsResponse: true, RequestId: 7},
+	} {
+		stream = append(stream, frame(t, m)...)
+	}
+
+	messages, err := DecodeStream(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 6 {
+		t.Fatalf("Expected 6 messages, got %d", len(messages))
+	}
+	if c := messages[1].Chat; c == nil || c.Message != "is this still available?" || c.Flag != pb.Chat_MESSAGE {
+		t.Errorf("Unexpected chat %v", c)
+	}
+	if r := messages[2].Reject; r == nil || r.OrderID != "QmOrder" {
+		t.Errorf("Unexpected reject %v", r)
+	}
+	if messages[3].OrderID != "QmOrder" {
+		t.Errorf("Expected the canceled order ID, got %q", messages[3].OrderID)
+	}
+	if c := messages[4].Command; c == nil || c.PeerID != "QmVendor" {
+		t.Errorf("Unexpected follow command %v", c)
+	}
+	if m := messages[5]; m.Error != "not a vendor" || !m.IsResponse || m.RequestID != 7 {
+		t.Errorf("Unexpected error response %v", m)
+	}
+	if got := Filter(messages, pb.Message_CHAT); len(got) != 1 || got[0] != messages[1] {
+		t.Errorf("Expected the chat message, got %v", got)
+	}
+	if s := messages[1].String(); s != `CHAT MESSAGE "is this still available?"` {
+		t.Errorf("Unexpected string %s", s)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	// a chat payload sent as an order must not decode as a contract
+	chat, err := ptypes.MarshalAny(&pb.Chat{Message: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromProto(&pb.Message{MessageType: pb.Message_ORDER, Payload: chat}); err == nil {
+		t.Error("Expected a payload of the wrong type to fail")
+	}
+	if _, err := FromProto(&pb.Message{MessageType: pb.Message_CHAT}); err == nil {
+		t.Error("Expected a missing payload to fail")
+	}
+
+	truncated := frame(t, &pb.Message{MessageType: pb.Message_ORDER_CANCEL, Payload: &any.Any{Value: []byte("QmOrder")}})
+	_, err = NewReader(bytes.NewReader(truncated[:len(truncated)-2])).Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected a truncated frame to fail with ErrUnexpectedEOF, got %v", err)
+	}
+}